@@ -2044,6 +2044,7 @@ type ResponseCheckTx struct {
 	GasUsed              int64           `protobuf:"varint,6,opt,name=gas_used,json=gasUsed,proto3" json:"gas_used,omitempty"`
 	Tags                 []common.KVPair `protobuf:"bytes,7,rep,name=tags" json:"tags,omitempty"`
 	Codespace            string          `protobuf:"bytes,8,opt,name=codespace,proto3" json:"codespace,omitempty"`
+	Priority             int64           `protobuf:"varint,9,opt,name=priority,proto3" json:"priority,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
 	XXX_unrecognized     []byte          `json:"-"`
 	XXX_sizecache        int32           `json:"-"`
@@ -2138,6 +2139,13 @@ func (m *ResponseCheckTx) GetCodespace() string {
 	return ""
 }
 
+func (m *ResponseCheckTx) GetPriority() int64 {
+	if m != nil {
+		return m.Priority
+	}
+	return 0
+}
+
 type ResponseDeliverTx struct {
 	Code                 uint32          `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
 	Data                 []byte          `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
@@ -4621,6 +4629,9 @@ func (this *ResponseCheckTx) Equal(that interface{}) bool {
 	if this.Codespace != that1.Codespace {
 		return false
 	}
+	if this.Priority != that1.Priority {
+		return false
+	}
 	if !bytes.Equal(this.XXX_unrecognized, that1.XXX_unrecognized) {
 		return false
 	}
@@ -6775,6 +6786,11 @@ func (m *ResponseCheckTx) MarshalTo(dAtA []byte) (int, error) {
 		i = encodeVarintTypes(dAtA, i, uint64(len(m.Codespace)))
 		i += copy(dAtA[i:], m.Codespace)
 	}
+	if m.Priority != 0 {
+		dAtA[i] = 0x48
+		i++
+		i = encodeVarintTypes(dAtA, i, uint64(m.Priority))
+	}
 	if m.XXX_unrecognized != nil {
 		i += copy(dAtA[i:], m.XXX_unrecognized)
 	}
@@ -8027,8 +8043,12 @@ func NewPopulatedResponseCheckTx(r randyTypes, easy bool) *ResponseCheckTx {
 		}
 	}
 	this.Codespace = string(randStringTypes(r))
+	this.Priority = int64(r.Int63())
+	if r.Intn(2) == 0 {
+		this.Priority *= -1
+	}
 	if !easy && r.Intn(10) != 0 {
-		this.XXX_unrecognized = randUnrecognizedTypes(r, 9)
+		this.XXX_unrecognized = randUnrecognizedTypes(r, 10)
 	}
 	return this
 }
@@ -9185,6 +9205,9 @@ func (m *ResponseCheckTx) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovTypes(uint64(l))
 	}
+	if m.Priority != 0 {
+		n += 1 + sovTypes(uint64(m.Priority))
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -12856,6 +12879,25 @@ func (m *ResponseCheckTx) Unmarshal(dAtA []byte) error {
 			}
 			m.Codespace = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 9:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Priority", wireType)
+			}
+			m.Priority = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTypes
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Priority |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTypes(dAtA[iNdEx:])
@@ -15363,143 +15405,144 @@ func init() {
 }
 
 var fileDescriptor_types_a177e47fab90f91d = []byte{
-	// 2203 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xdc, 0x58, 0xcf, 0x73, 0x1c, 0x47,
-	0xf5, 0xd7, 0xec, 0xef, 0x79, 0xab, 0xfd, 0xe1, 0xb6, 0x6c, 0xaf, 0xf7, 0x9b, 0xaf, 0xe4, 0x1a,
-	0x43, 0x22, 0x11, 0x67, 0x95, 0x28, 0x98, 0x92, 0xe3, 0x40, 0x95, 0x56, 0x36, 0x48, 0x95, 0x00,
-	0x62, 0x6c, 0x8b, 0x0b, 0x55, 0x53, 0xbd, 0x3b, 0xad, 0xdd, 0x29, 0xed, 0xce, 0x4c, 0x66, 0x7a,
-	0x95, 0x15, 0x47, 0xce, 0x39, 0xe4, 0xc0, 0x9f, 0xc0, 0x81, 0x3f, 0x21, 0x47, 0x4e, 0x54, 0x8e,
-	0x1c, 0x38, 0x1b, 0x10, 0xc5, 0x01, 0xae, 0x14, 0x55, 0x1c, 0xa9, 0x7e, 0xdd, 0xf3, 0x53, 0xb3,
-	0x26, 0x0e, 0x9c, 0xb8, 0x48, 0xd3, 0xfd, 0x3e, 0xaf, 0x7f, 0xbc, 0x7d, 0xef, 0x7d, 0xde, 0x6b,
-	0xb8, 0x4d, 0x47, 0x63, 0x67, 0x97, 0x5f, 0xfa, 0x2c, 0x94, 0x7f, 0x07, 0x7e, 0xe0, 0x71, 0x8f,
-	0x54, 0x71, 0xd0, 0x7f, 0x67, 0xe2, 0xf0, 0xe9, 0x62, 0x34, 0x18, 0x7b, 0xf3, 0xdd, 0x89, 0x37,
-	0xf1, 0x76, 0x51, 0x3a, 0x5a, 0x9c, 0xe1, 0x08, 0x07, 0xf8, 0x25, 0xb5, 0xfa, 0x8f, 0x53, 0x70,
-	0xce, 0x5c, 0x9b, 0x05, 0x73, 0xc7, 0xe5, 0xe9, 0xcf, 0x71, 0x70, 0xe9, 0x73, 0x6f, 0x77, 0xce,
-	0x82, 0xf3, 0x19, 0x53, 0xff, 0x94, 0xf2, 0xfe, 0xbf, 0x55, 0x9e, 0x39, 0xa3, 0x70, 0x77, 0xec,
-	0xcd, 0xe7, 0x9e, 0x9b, 0x3e, 0x6c, 0x7f, 0x6b, 0xe2, 0x79, 0x93, 0x19, 0x4b, 0x0e, 0xc7, 0x9d,
-	0x39, 0x0b, 0x39, 0x9d, 0xfb, 0x12, 0x60, 0xfc, 0xb6, 0x02, 0x75, 0x93, 0x7d, 0xb2, 0x60, 0x21,
-	0x27, 0xdb, 0x50, 0x61, 0xe3, 0xa9, 0xd7, 0x2b, 0xdd, 0xd3, 0xb6, 0x9b, 0x7b, 0x64, 0x20, 0x17,
-	0x52, 0xd2, 0xa7, 0xe3, 0xa9, 0x77, 0xb4, 0x66, 0x22, 0x82, 0xbc, 0x0d, 0xd5, 0xb3, 0xd9, 0x22,
-	0x9c, 0xf6, 0xca, 0x08, 0xbd, 0x99, 0x85, 0x7e, 0x5f, 0x88, 0x8e, 0xd6, 0x4c, 0x89, 0x11, 0xcb,
-	0x3a, 0xee, 0x99, 0xd7, 0xab, 0x14, 0x2d, 0x7b, 0xec, 0x9e, 0xe1, 0xb2, 0x02, 0x41, 0xf6, 0x01,
-	0x42, 0xc6, 0x2d, 0xcf, 0xe7, 0x8e, 0xe7, 0xf6, 0xaa, 0x88, 0xbf, 0x93, 0xc5, 0x3f, 0x63, 0xfc,
-	0xc7, 0x28, 0x3e, 0x5a, 0x33, 0xf5, 0x30, 0x1a, 0x08, 0x4d, 0xc7, 0x75, 0xb8, 0x35, 0x9e, 0x52,
-	0xc7, 0xed, 0xd5, 0x8a, 0x34, 0x8f, 0x5d, 0x87, 0x1f, 0x0a, 0xb1, 0xd0, 0x74, 0xa2, 0x81, 0xb8,
-	0xca, 0x27, 0x0b, 0x16, 0x5c, 0xf6, 0xea, 0x45, 0x57, 0xf9, 0x89, 0x10, 0x89, 0xab, 0x20, 0x86,
-	0x3c, 0x86, 0xe6, 0x88, 0x4d, 0x1c, 0xd7, 0x1a, 0xcd, 0xbc, 0xf1, 0x79, 0xaf, 0x81, 0x2a, 0xbd,
-	0xac, 0xca, 0x50, 0x00, 0x86, 0x42, 0x7e, 0xb4, 0x66, 0xc2, 0x28, 0x1e, 0x91, 0x3d, 0x68, 0x8c,
-	0xa7, 0x6c, 0x7c, 0x6e, 0xf1, 0x65, 0x4f, 0x47, 0xcd, 0x5b, 0x59, 0xcd, 0x43, 0x21, 0x7d, 0xbe,
-	0x3c, 0x5a, 0x33, 0xeb, 0x63, 0xf9, 0x49, 0x1e, 0x82, 0xce, 0x5c, 0x5b, 0x6d, 0xd7, 0x44, 0xa5,
-	0xdb, 0xb9, 0xdf, 0xc5, 0xb5, 0xa3, 0xcd, 0x1a, 0x4c, 0x7d, 0x93, 0x01, 0xd4, 0x84, 0x33, 0x38,
-	0xbc, 0xb7, 0x8e, 0x3a, 0x1b, 0xb9, 0x8d, 0x50, 0x76, 0xb4, 0x66, 0x2a, 0x94, 0x30, 0x9f, 0xcd,
-	0x66, 0xce, 0x05, 0x0b, 0xc4, 0xe1, 0x6e, 0x16, 0x99, 0xef, 0x89, 0x94, 0xe3, 0xf1, 0x74, 0x3b,
-	0x1a, 0x0c, 0xeb, 0x50, 0xbd, 0xa0, 0xb3, 0x05, 0x33, 0xde, 0x82, 0x66, 0xca, 0x53, 0x48, 0x0f,
-	0xea, 0x73, 0x16, 0x86, 0x74, 0xc2, 0x7a, 0xda, 0x3d, 0x6d, 0x5b, 0x37, 0xa3, 0xa1, 0xd1, 0x86,
-	0xf5, 0xb4, 0x9f, 0x18, 0xf3, 0x58, 0x51, 0xf8, 0x82, 0x50, 0xbc, 0x60, 0x41, 0x28, 0x1c, 0x40,
-	0x29, 0xaa, 0x21, 0xb9, 0x0f, 0x2d, 0xb4, 0x83, 0x15, 0xc9, 0x85, 0x9f, 0x56, 0xcc, 0x75, 0x9c,
-	0x3c, 0x55, 0xa0, 0x2d, 0x68, 0xfa, 0x7b, 0x7e, 0x0c, 0x29, 0x23, 0x04, 0xfc, 0x3d, 0x5f, 0x01,
-	0x8c, 0x0f, 0xa0, 0x9b, 0x77, 0x25, 0xd2, 0x85, 0xf2, 0x39, 0xbb, 0x54, 0xfb, 0x89, 0x4f, 0xb2,
-	0xa1, 0xae, 0x85, 0x7b, 0xe8, 0xa6, 0xba, 0xe3, 0xe7, 0xa5, 0x58, 0x39, 0xf6, 0x26, 0xb2, 0x0f,
-	0x15, 0x11, 0x54, 0xa8, 0xdd, 0xdc, 0xeb, 0x0f, 0x64, 0xc4, 0x0d, 0xa2, 0x88, 0x1b, 0x3c, 0x8f,
-	0x22, 0x6e, 0xd8, 0xf8, 0xf2, 0xe5, 0xd6, 0xda, 0xe7, 0x7f, 0xd8, 0xd2, 0x4c, 0xd4, 0x20, 0x77,
-	0x85, 0x43, 0x50, 0xc7, 0xb5, 0x1c, 0x5b, 0xed, 0x53, 0xc7, 0xf1, 0xb1, 0x4d, 0x0e, 0xa0, 0x3b,
-	0xf6, 0xdc, 0x90, 0xb9, 0xe1, 0x22, 0xb4, 0x7c, 0x1a, 0xd0, 0x79, 0xa8, 0x62, 0x2d, 0xfa, 0xf9,
-	0x0f, 0x23, 0xf1, 0x09, 0x4a, 0xcd, 0xce, 0x38, 0x3b, 0x41, 0x3e, 0x04, 0xb8, 0xa0, 0x33, 0xc7,
-	0xa6, 0xdc, 0x0b, 0xc2, 0x5e, 0xe5, 0x5e, 0x39, 0xa5, 0x7c, 0x1a, 0x09, 0x5e, 0xf8, 0x36, 0xe5,
-	0x6c, 0x58, 0x11, 0x27, 0x33, 0x53, 0x78, 0xf2, 0x26, 0x74, 0xa8, 0xef, 0x5b, 0x21, 0xa7, 0x9c,
-	0x59, 0xa3, 0x4b, 0xce, 0x42, 0x8c, 0xc7, 0x75, 0xb3, 0x45, 0x7d, 0xff, 0x99, 0x98, 0x1d, 0x8a,
-	0x49, 0xc3, 0x8e, 0x7f, 0x4d, 0x0c, 0x15, 0x42, 0xa0, 0x62, 0x53, 0x4e, 0xd1, 0x1a, 0xeb, 0x26,
-	0x7e, 0x8b, 0x39, 0x9f, 0xf2, 0xa9, 0xba, 0x23, 0x7e, 0x93, 0xdb, 0x50, 0x9b, 0x32, 0x67, 0x32,
-	0xe5, 0x78, 0xad, 0xb2, 0xa9, 0x46, 0xc2, 0xf0, 0x7e, 0xe0, 0x5d, 0x30, 0xcc, 0x16, 0x0d, 0x53,
-	0x0e, 0x8c, 0xbf, 0x68, 0x70, 0xe3, 0x5a, 0x78, 0x89, 0x75, 0xa7, 0x34, 0x9c, 0x46, 0x7b, 0x89,
-	0x6f, 0xf2, 0xb6, 0x58, 0x97, 0xda, 0x2c, 0x50, 0x59, 0xac, 0xa5, 0x6e, 0x7c, 0x84, 0x93, 0xea,
-	0xa2, 0x0a, 0x42, 0x9e, 0x42, 0x77, 0x46, 0x43, 0x6e, 0xc9, 0x28, 0xb0, 0x30, 0x4b, 0x95, 0x33,
-	0x91, 0xf9, 0x31, 0x8d, 0xa2, 0x45, 0x38, 0xa7, 0x52, 0x6f, 0xcf, 0x32, 0xb3, 0xe4, 0x08, 0x36,
-	0x46, 0x97, 0x3f, 0xa7, 0x2e, 0x77, 0x5c, 0x66, 0x5d, 0xb3, 0x79, 0x47, 0x2d, 0xf5, 0xf4, 0xc2,
-	0xb1, 0x99, 0x3b, 0x8e, 0x8c, 0x7d, 0x33, 0x56, 0x89, 0x7f, 0x8c, 0xd0, 0xb8, 0x07, 0xed, 0x6c,
-	0x2e, 0x20, 0x6d, 0x28, 0xf1, 0xa5, 0xba, 0x61, 0x89, 0x2f, 0x0d, 0x23, 0xf6, 0xc0, 0x38, 0x20,
-	0xaf, 0x61, 0x76, 0xa0, 0x93, 0x4b, 0x0e, 0x29, 0x73, 0x6b, 0x69, 0x73, 0x1b, 0x1d, 0x68, 0x65,
-	0x72, 0x82, 0xf1, 0x59, 0x15, 0x1a, 0x26, 0x0b, 0x7d, 0xe1, 0x4c, 0x64, 0x1f, 0x74, 0xb6, 0x1c,
-	0x33, 0x99, 0x8e, 0xb5, 0x5c, 0xb2, 0x93, 0x98, 0xa7, 0x91, 0x5c, 0xa4, 0x85, 0x18, 0x4c, 0x76,
-	0x32, 0x54, 0x72, 0x33, 0xaf, 0x94, 0xe6, 0x92, 0x07, 0x59, 0x2e, 0xd9, 0xc8, 0x61, 0x73, 0x64,
-	0xb2, 0x93, 0x21, 0x93, 0xfc, 0xc2, 0x19, 0x36, 0x79, 0x54, 0xc0, 0x26, 0xf9, 0xe3, 0xaf, 0xa0,
-	0x93, 0x47, 0x05, 0x74, 0xd2, 0xbb, 0xb6, 0x57, 0x21, 0x9f, 0x3c, 0xc8, 0xf2, 0x49, 0xfe, 0x3a,
-	0x39, 0x42, 0xf9, 0xb0, 0x88, 0x50, 0xee, 0xe6, 0x74, 0x56, 0x32, 0xca, 0xfb, 0xd7, 0x18, 0xe5,
-	0x76, 0x4e, 0xb5, 0x80, 0x52, 0x1e, 0x65, 0x72, 0x3d, 0x14, 0xde, 0xad, 0x38, 0xd9, 0x93, 0xef,
-	0x5c, 0x67, 0xa3, 0x3b, 0xf9, 0x9f, 0xb6, 0x88, 0x8e, 0x76, 0x73, 0x74, 0x74, 0x2b, 0x7f, 0xca,
-	0x1c, 0x1f, 0x25, 0xac, 0xb2, 0x23, 0xe2, 0x3e, 0xe7, 0x69, 0x22, 0x47, 0xb0, 0x20, 0xf0, 0x02,
-	0x95, 0xb0, 0xe5, 0xc0, 0xd8, 0x16, 0x99, 0x28, 0xf1, 0xaf, 0x57, 0x30, 0x10, 0x3a, 0x7d, 0xca,
-	0xbb, 0x8c, 0x2f, 0xb4, 0x44, 0x17, 0x23, 0x3a, 0x9d, 0xc5, 0x74, 0x95, 0xc5, 0x52, 0xc4, 0x54,
-	0xca, 0x12, 0xd3, 0x16, 0x34, 0x45, 0xae, 0xcc, 0x71, 0x0e, 0xf5, 0x23, 0xce, 0x21, 0xdf, 0x82,
-	0x1b, 0x98, 0x67, 0x24, 0x7d, 0xa9, 0x40, 0xac, 0x60, 0x20, 0x76, 0x84, 0x40, 0x5a, 0x4c, 0x26,
-	0xc0, 0x77, 0xe0, 0x66, 0x0a, 0x2b, 0xd6, 0xc5, 0x1c, 0x27, 0x93, 0x6f, 0x37, 0x46, 0x1f, 0xf8,
-	0xfe, 0x11, 0x0d, 0xa7, 0xc6, 0x0f, 0x13, 0x03, 0x25, 0x7c, 0x46, 0xa0, 0x32, 0xf6, 0x6c, 0x79,
-	0xef, 0x96, 0x89, 0xdf, 0x82, 0xe3, 0x66, 0xde, 0x04, 0x0f, 0xa7, 0x9b, 0xe2, 0x53, 0xa0, 0xe2,
-	0x50, 0xd2, 0x65, 0xcc, 0x18, 0xbf, 0xd4, 0x92, 0xf5, 0x12, 0x8a, 0x2b, 0x62, 0x23, 0xed, 0x3f,
-	0x61, 0xa3, 0xd2, 0xeb, 0xb1, 0x91, 0x71, 0xa5, 0x25, 0x3f, 0x59, 0xcc, 0x33, 0x5f, 0xef, 0x8a,
-	0xc2, 0x7b, 0x1c, 0xd7, 0x66, 0x4b, 0x34, 0x69, 0xd9, 0x94, 0x83, 0xa8, 0x04, 0xa8, 0xa1, 0x99,
-	0xb3, 0x25, 0x40, 0x1d, 0xe7, 0xe4, 0x80, 0xdc, 0x47, 0x7e, 0xf2, 0xce, 0x54, 0xa8, 0xb6, 0x06,
-	0xaa, 0x50, 0x3f, 0x11, 0x93, 0xa6, 0x94, 0xa5, 0xb2, 0xad, 0x9e, 0x21, 0xb7, 0x37, 0x40, 0x17,
-	0x07, 0x0d, 0x7d, 0x3a, 0x66, 0x18, 0x79, 0xba, 0x99, 0x4c, 0x18, 0x27, 0x40, 0xae, 0x47, 0x3c,
-	0xf9, 0x00, 0x2a, 0x9c, 0x4e, 0x84, 0xbd, 0x85, 0xc9, 0xda, 0x03, 0x59, 0xe4, 0x0f, 0x3e, 0x3a,
-	0x3d, 0xa1, 0x4e, 0x30, 0xbc, 0x2d, 0x4c, 0xf5, 0xb7, 0x97, 0x5b, 0x6d, 0x81, 0x79, 0xe0, 0xcd,
-	0x1d, 0xce, 0xe6, 0x3e, 0xbf, 0x34, 0x51, 0xc7, 0xf8, 0xbb, 0x26, 0x98, 0x20, 0x93, 0x09, 0x0a,
-	0x0d, 0x17, 0xb9, 0x7b, 0x29, 0x45, 0xda, 0x5f, 0xcd, 0x98, 0xff, 0x0f, 0x30, 0xa1, 0xa1, 0xf5,
-	0x29, 0x75, 0x39, 0xb3, 0x95, 0x45, 0xf5, 0x09, 0x0d, 0x7f, 0x8a, 0x13, 0xa2, 0xc2, 0x11, 0xe2,
-	0x45, 0xc8, 0x6c, 0x34, 0x6d, 0xd9, 0xac, 0x4f, 0x68, 0xf8, 0x22, 0x64, 0x76, 0x7c, 0xaf, 0xfa,
-	0xeb, 0xdf, 0x2b, 0x6b, 0xc7, 0x46, 0xde, 0x8e, 0xff, 0x48, 0xf9, 0x70, 0x42, 0x92, 0xff, 0xfb,
-	0xf7, 0xfe, 0xab, 0x26, 0x6a, 0x83, 0x6c, 0x1a, 0x26, 0xc7, 0x70, 0x23, 0x8e, 0x23, 0x6b, 0x81,
-	0xf1, 0x15, 0xf9, 0xd2, 0xab, 0xc3, 0xaf, 0x7b, 0x91, 0x9d, 0x0e, 0xc9, 0x8f, 0xe0, 0x4e, 0x2e,
-	0x0b, 0xc4, 0x0b, 0x96, 0x5e, 0x99, 0x0c, 0x6e, 0x65, 0x93, 0x41, 0xb4, 0x5e, 0x64, 0x89, 0xf2,
-	0xd7, 0xf0, 0xec, 0x6f, 0x88, 0x42, 0x29, 0x4d, 0x1e, 0x45, 0xbf, 0xa5, 0xf1, 0x2b, 0x0d, 0x3a,
-	0xb9, 0xc3, 0x90, 0x6d, 0xa8, 0x4a, 0xfe, 0xd2, 0x32, 0xed, 0x28, 0x5a, 0x4b, 0x9d, 0x57, 0x02,
-	0xc8, 0x7b, 0xd0, 0x60, 0xaa, 0x66, 0x53, 0x17, 0xbc, 0x95, 0x2b, 0xe5, 0x14, 0x3e, 0x86, 0x91,
-	0x6f, 0x83, 0x1e, 0x9b, 0x2d, 0x57, 0xaf, 0xc7, 0x56, 0x56, 0x4a, 0x09, 0xd0, 0x38, 0x84, 0x66,
-	0x6a, 0x7b, 0xf2, 0x7f, 0xa0, 0xcf, 0xe9, 0x52, 0x15, 0xdd, 0xb2, 0x5c, 0x6b, 0xcc, 0xe9, 0x12,
-	0xeb, 0x6d, 0x72, 0x07, 0xea, 0x42, 0x38, 0xa1, 0xd2, 0xe8, 0x65, 0xb3, 0x36, 0xa7, 0xcb, 0x1f,
-	0xd0, 0xd0, 0xd8, 0x81, 0x76, 0xf6, 0x58, 0x11, 0x34, 0x22, 0x40, 0x09, 0x3d, 0x98, 0x30, 0xe3,
-	0x21, 0x74, 0x72, 0xa7, 0x21, 0x06, 0xb4, 0xfc, 0xc5, 0xc8, 0x3a, 0x67, 0x97, 0x16, 0x1e, 0x17,
-	0x5d, 0x44, 0x37, 0x9b, 0xfe, 0x62, 0xf4, 0x11, 0xbb, 0x7c, 0x2e, 0xa6, 0x8c, 0x67, 0xd0, 0xce,
-	0x96, 0xc3, 0x22, 0x45, 0x06, 0xde, 0xc2, 0xb5, 0x71, 0xfd, 0xaa, 0x29, 0x07, 0xa2, 0xa3, 0xbe,
-	0xf0, 0xa4, 0x57, 0xa4, 0xeb, 0xdf, 0x53, 0x8f, 0xb3, 0x54, 0x11, 0x2d, 0x31, 0xc6, 0x2f, 0xaa,
-	0x50, 0x93, 0xb5, 0x39, 0x19, 0x64, 0x3b, 0x3f, 0xe1, 0x12, 0x4a, 0x53, 0xce, 0x2a, 0xc5, 0x98,
-	0x76, 0xdf, 0xcc, 0xb7, 0x4f, 0xc3, 0xe6, 0xd5, 0xcb, 0xad, 0x3a, 0x52, 0xd6, 0xf1, 0x93, 0xa4,
-	0x97, 0x5a, 0xd5, 0x6a, 0x44, 0x8d, 0x5b, 0xe5, 0xb5, 0x1b, 0xb7, 0x3b, 0x50, 0x77, 0x17, 0x73,
-	0x8b, 0x2f, 0x43, 0x15, 0xfa, 0x35, 0x77, 0x31, 0x7f, 0xbe, 0xc4, 0x9f, 0x8e, 0x7b, 0x9c, 0xce,
-	0x50, 0x24, 0x03, 0xbf, 0x81, 0x13, 0x42, 0xb8, 0x0f, 0xad, 0x14, 0xb3, 0x3b, 0xb6, 0xaa, 0x10,
-	0xdb, 0x69, 0x0f, 0x3c, 0x7e, 0xa2, 0x6e, 0xd9, 0x8c, 0x99, 0xfe, 0xd8, 0x26, 0xdb, 0xd9, 0x3e,
-	0x05, 0x0b, 0x82, 0x06, 0xfa, 0x79, 0xaa, 0x15, 0x11, 0xe5, 0x80, 0x38, 0x80, 0xf0, 0x7c, 0x09,
-	0xd1, 0x11, 0xd2, 0x10, 0x13, 0x28, 0x7c, 0x0b, 0x3a, 0x09, 0xa7, 0x4a, 0x08, 0xc8, 0x55, 0x92,
-	0x69, 0x04, 0xbe, 0x0b, 0x1b, 0x2e, 0x5b, 0x72, 0x2b, 0x8f, 0x6e, 0x22, 0x9a, 0x08, 0xd9, 0x69,
-	0x56, 0xe3, 0x9b, 0xd0, 0x4e, 0x72, 0x03, 0x62, 0xd7, 0x65, 0xb7, 0x18, 0xcf, 0x22, 0xec, 0x2e,
-	0x34, 0xe2, 0x8a, 0xa6, 0x85, 0x80, 0x3a, 0x95, 0x85, 0x4c, 0x5c, 0x23, 0x05, 0x2c, 0x5c, 0xcc,
-	0xb8, 0x5a, 0xa4, 0x8d, 0x18, 0xac, 0x91, 0x4c, 0x39, 0x8f, 0xd8, 0xfb, 0xd0, 0x8a, 0x42, 0x4e,
-	0xe2, 0x3a, 0x88, 0x5b, 0x8f, 0x26, 0x11, 0xb4, 0x03, 0x5d, 0x3f, 0xf0, 0x7c, 0x2f, 0x64, 0x81,
-	0x45, 0x6d, 0x3b, 0x60, 0x61, 0xd8, 0xeb, 0xca, 0xf5, 0xa2, 0xf9, 0x03, 0x39, 0x6d, 0xbc, 0x07,
-	0xf5, 0xa8, 0x54, 0xdb, 0x80, 0xea, 0x30, 0x4e, 0x0f, 0x15, 0x53, 0x0e, 0x04, 0x29, 0x1c, 0xf8,
-	0xbe, 0x7a, 0x70, 0x10, 0x9f, 0xc6, 0xcf, 0xa0, 0xae, 0x7e, 0xb0, 0xc2, 0x36, 0xf4, 0xbb, 0xb0,
-	0xee, 0xd3, 0x40, 0x5c, 0x23, 0xdd, 0x8c, 0x46, 0xcd, 0xc0, 0x09, 0x0d, 0xf8, 0x33, 0xc6, 0x33,
-	0x3d, 0x69, 0x13, 0xf1, 0x72, 0xca, 0x78, 0x04, 0xad, 0x0c, 0x46, 0x1c, 0x0b, 0xfd, 0x28, 0x8a,
-	0x34, 0x1c, 0xc4, 0x3b, 0x97, 0x92, 0x9d, 0x8d, 0xc7, 0xa0, 0xc7, 0xbf, 0x8d, 0xa8, 0x59, 0xa3,
-	0xab, 0x6b, 0xca, 0xdc, 0x72, 0x88, 0x7d, 0xb6, 0xf7, 0x29, 0x0b, 0x54, 0x4c, 0xc8, 0x81, 0xf1,
-	0x22, 0x95, 0x19, 0x64, 0x9a, 0x26, 0x0f, 0xa0, 0xae, 0x32, 0x83, 0x8a, 0xca, 0xa8, 0xa3, 0x3e,
-	0xc1, 0xd4, 0x10, 0x75, 0xd4, 0x32, 0x51, 0x24, 0xcb, 0x96, 0xd2, 0xcb, 0xce, 0xa0, 0x11, 0x45,
-	0x7f, 0x36, 0x45, 0xca, 0x15, 0xbb, 0xf9, 0x14, 0xa9, 0x16, 0x4d, 0x80, 0xc2, 0x3b, 0x42, 0x67,
-	0xe2, 0x32, 0xdb, 0x4a, 0x42, 0x08, 0xf7, 0x68, 0x98, 0x1d, 0x29, 0xf8, 0x38, 0x8a, 0x17, 0xe3,
-	0x5d, 0xa8, 0xc9, 0xb3, 0x09, 0xfb, 0x88, 0x95, 0xa3, 0x32, 0x5e, 0x7c, 0x17, 0xf2, 0xc4, 0xef,
-	0x35, 0x68, 0x44, 0xc9, 0xb3, 0x50, 0x29, 0x73, 0xe8, 0xd2, 0x57, 0x3d, 0xf4, 0x7f, 0x3f, 0xf1,
-	0x3c, 0x00, 0x22, 0xf3, 0xcb, 0x85, 0xc7, 0x1d, 0x77, 0x62, 0x49, 0x5b, 0xcb, 0x1c, 0xd4, 0x45,
-	0xc9, 0x29, 0x0a, 0x4e, 0xc4, 0xfc, 0xde, 0x67, 0x55, 0xe8, 0x1c, 0x0c, 0x0f, 0x8f, 0x0f, 0x7c,
-	0x7f, 0xe6, 0x8c, 0x29, 0xb6, 0x06, 0xbb, 0x50, 0xc1, 0xee, 0xa8, 0xe0, 0x75, 0xb7, 0x5f, 0xd4,
-	0xa6, 0x93, 0x3d, 0xa8, 0x62, 0x93, 0x44, 0x8a, 0x1e, 0x79, 0xfb, 0x85, 0xdd, 0xba, 0xd8, 0x44,
-	0xb6, 0x51, 0xd7, 0xdf, 0x7a, 0xfb, 0x45, 0x2d, 0x3b, 0xf9, 0x1e, 0xe8, 0x49, 0xf7, 0xb2, 0xea,
-	0xc5, 0xb7, 0xbf, 0xb2, 0x79, 0x17, 0xfa, 0x49, 0xa5, 0xb7, 0xea, 0xe1, 0xb2, 0xbf, 0xb2, 0xcb,
-	0x25, 0xfb, 0x50, 0x8f, 0xea, 0xe3, 0xe2, 0x37, 0xd9, 0xfe, 0x8a, 0xc6, 0x5a, 0x98, 0x47, 0x36,
-	0x24, 0x45, 0x0f, 0xc7, 0xfd, 0xc2, 0xee, 0x9f, 0x3c, 0x84, 0x9a, 0x2a, 0x5a, 0x0a, 0xdf, 0x65,
-	0xfb, 0xc5, 0xed, 0xb1, 0xb8, 0x64, 0xd2, 0x92, 0xad, 0x7a, 0xdc, 0xee, 0xaf, 0x7c, 0xa6, 0x20,
-	0x07, 0x00, 0xa9, 0xbe, 0x62, 0xe5, 0xab, 0x75, 0x7f, 0xf5, 0xf3, 0x03, 0x79, 0x0c, 0x8d, 0xe4,
-	0x49, 0xa9, 0xf8, 0x1d, 0xba, 0xbf, 0xea, 0x45, 0x60, 0xf8, 0xc6, 0x3f, 0xff, 0xb4, 0xa9, 0xfd,
-	0xfa, 0x6a, 0x53, 0xfb, 0xe2, 0x6a, 0x53, 0xfb, 0xf2, 0x6a, 0x53, 0xfb, 0xdd, 0xd5, 0xa6, 0xf6,
-	0xc7, 0xab, 0x4d, 0xed, 0x37, 0x7f, 0xde, 0xd4, 0x46, 0x35, 0x74, 0xff, 0xf7, 0xff, 0x15, 0x00,
-	0x00, 0xff, 0xff, 0x38, 0x2d, 0x52, 0x86, 0x77, 0x19, 0x00, 0x00,
+	// 2213 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xdc, 0x58, 0xcf, 0x73, 0x1b, 0x49,
+	0xf5, 0xf7, 0xe8, 0x87, 0x25, 0x3d, 0xfd, 0x74, 0xc7, 0x49, 0x14, 0x7d, 0xf7, 0x6b, 0xa7, 0x26,
+	0xb0, 0x6b, 0xb3, 0x59, 0x79, 0xd7, 0x4b, 0x28, 0x67, 0xb3, 0x50, 0x65, 0x25, 0x01, 0xbb, 0x76,
+	0x01, 0x33, 0x49, 0xcc, 0x85, 0xaa, 0xa9, 0x96, 0xa6, 0x23, 0x4d, 0x45, 0x9a, 0x99, 0x9d, 0x69,
+	0x79, 0x65, 0x8e, 0x9c, 0xb7, 0x8a, 0x3d, 0xf0, 0x27, 0x70, 0xe0, 0x4f, 0xd8, 0x23, 0x27, 0x6a,
+	0x8f, 0x1c, 0x38, 0x07, 0x30, 0xc5, 0x01, 0xee, 0x54, 0x71, 0xa4, 0xfa, 0x75, 0xf7, 0x68, 0x66,
+	0x34, 0x0a, 0x9b, 0x85, 0x13, 0x17, 0x7b, 0xfa, 0xbd, 0xcf, 0xeb, 0xee, 0xf7, 0xf4, 0x7e, 0x36,
+	0xdc, 0xa0, 0xc3, 0x91, 0x7b, 0xc0, 0x2f, 0x03, 0x16, 0xc9, 0xbf, 0xfd, 0x20, 0xf4, 0xb9, 0x4f,
+	0xca, 0xb8, 0xe8, 0xbd, 0x33, 0x76, 0xf9, 0x64, 0x3e, 0xec, 0x8f, 0xfc, 0xd9, 0xc1, 0xd8, 0x1f,
+	0xfb, 0x07, 0xc8, 0x1d, 0xce, 0x9f, 0xe3, 0x0a, 0x17, 0xf8, 0x25, 0xa5, 0x7a, 0x0f, 0x12, 0x70,
+	0xce, 0x3c, 0x87, 0x85, 0x33, 0xd7, 0xe3, 0xc9, 0xcf, 0x51, 0x78, 0x19, 0x70, 0xff, 0x60, 0xc6,
+	0xc2, 0x17, 0x53, 0xa6, 0xfe, 0x29, 0xe1, 0xa3, 0x7f, 0x2b, 0x3c, 0x75, 0x87, 0xd1, 0xc1, 0xc8,
+	0x9f, 0xcd, 0x7c, 0x2f, 0x79, 0xd9, 0xde, 0xee, 0xd8, 0xf7, 0xc7, 0x53, 0xb6, 0xbc, 0x1c, 0x77,
+	0x67, 0x2c, 0xe2, 0x74, 0x16, 0x48, 0x80, 0xf9, 0xbb, 0x12, 0x54, 0x2c, 0xf6, 0xc9, 0x9c, 0x45,
+	0x9c, 0xec, 0x41, 0x89, 0x8d, 0x26, 0x7e, 0xb7, 0x70, 0xdb, 0xd8, 0xab, 0x1f, 0x92, 0xbe, 0xdc,
+	0x48, 0x71, 0x1f, 0x8f, 0x26, 0xfe, 0xc9, 0x86, 0x85, 0x08, 0xf2, 0x36, 0x94, 0x9f, 0x4f, 0xe7,
+	0xd1, 0xa4, 0x5b, 0x44, 0xe8, 0xb5, 0x34, 0xf4, 0xfb, 0x82, 0x75, 0xb2, 0x61, 0x49, 0x8c, 0xd8,
+	0xd6, 0xf5, 0x9e, 0xfb, 0xdd, 0x52, 0xde, 0xb6, 0xa7, 0xde, 0x73, 0xdc, 0x56, 0x20, 0xc8, 0x11,
+	0x40, 0xc4, 0xb8, 0xed, 0x07, 0xdc, 0xf5, 0xbd, 0x6e, 0x19, 0xf1, 0x37, 0xd3, 0xf8, 0x27, 0x8c,
+	0xff, 0x18, 0xd9, 0x27, 0x1b, 0x56, 0x2d, 0xd2, 0x0b, 0x21, 0xe9, 0x7a, 0x2e, 0xb7, 0x47, 0x13,
+	0xea, 0x7a, 0xdd, 0xcd, 0x3c, 0xc9, 0x53, 0xcf, 0xe5, 0x0f, 0x05, 0x5b, 0x48, 0xba, 0x7a, 0x21,
+	0x54, 0xf9, 0x64, 0xce, 0xc2, 0xcb, 0x6e, 0x25, 0x4f, 0x95, 0x9f, 0x08, 0x96, 0x50, 0x05, 0x31,
+	0xe4, 0x01, 0xd4, 0x87, 0x6c, 0xec, 0x7a, 0xf6, 0x70, 0xea, 0x8f, 0x5e, 0x74, 0xab, 0x28, 0xd2,
+	0x4d, 0x8b, 0x0c, 0x04, 0x60, 0x20, 0xf8, 0x27, 0x1b, 0x16, 0x0c, 0xe3, 0x15, 0x39, 0x84, 0xea,
+	0x68, 0xc2, 0x46, 0x2f, 0x6c, 0xbe, 0xe8, 0xd6, 0x50, 0xf2, 0x7a, 0x5a, 0xf2, 0xa1, 0xe0, 0x3e,
+	0x5d, 0x9c, 0x6c, 0x58, 0x95, 0x91, 0xfc, 0x24, 0xf7, 0xa0, 0xc6, 0x3c, 0x47, 0x1d, 0x57, 0x47,
+	0xa1, 0x1b, 0x99, 0xdf, 0xc5, 0x73, 0xf4, 0x61, 0x55, 0xa6, 0xbe, 0x49, 0x1f, 0x36, 0x85, 0x33,
+	0xb8, 0xbc, 0xdb, 0x40, 0x99, 0xed, 0xcc, 0x41, 0xc8, 0x3b, 0xd9, 0xb0, 0x14, 0x4a, 0x98, 0xcf,
+	0x61, 0x53, 0xf7, 0x82, 0x85, 0xe2, 0x72, 0xd7, 0xf2, 0xcc, 0xf7, 0x48, 0xf2, 0xf1, 0x7a, 0x35,
+	0x47, 0x2f, 0x06, 0x15, 0x28, 0x5f, 0xd0, 0xe9, 0x9c, 0x99, 0x6f, 0x41, 0x3d, 0xe1, 0x29, 0xa4,
+	0x0b, 0x95, 0x19, 0x8b, 0x22, 0x3a, 0x66, 0x5d, 0xe3, 0xb6, 0xb1, 0x57, 0xb3, 0xf4, 0xd2, 0x6c,
+	0x41, 0x23, 0xe9, 0x27, 0xe6, 0x0c, 0xea, 0x09, 0x5f, 0x10, 0x82, 0x17, 0x2c, 0x8c, 0x84, 0x03,
+	0x28, 0x41, 0xb5, 0x24, 0x77, 0xa0, 0x89, 0x76, 0xb0, 0x35, 0x5f, 0xf8, 0x69, 0xc9, 0x6a, 0x20,
+	0xf1, 0x5c, 0x81, 0x76, 0xa1, 0x1e, 0x1c, 0x06, 0x31, 0xa4, 0x88, 0x10, 0x08, 0x0e, 0x03, 0x05,
+	0x30, 0x3f, 0x80, 0x4e, 0xd6, 0x95, 0x48, 0x07, 0x8a, 0x2f, 0xd8, 0xa5, 0x3a, 0x4f, 0x7c, 0x92,
+	0x6d, 0xa5, 0x16, 0x9e, 0x51, 0xb3, 0x94, 0x8e, 0x9f, 0x17, 0xa0, 0x93, 0xf5, 0x26, 0x72, 0x04,
+	0x25, 0x11, 0x54, 0x28, 0x5d, 0x3f, 0xec, 0xf5, 0x65, 0xc4, 0xf5, 0x75, 0xc4, 0xf5, 0x9f, 0xea,
+	0x88, 0x1b, 0x54, 0xbf, 0x7c, 0xb9, 0xbb, 0xf1, 0xf9, 0x1f, 0x77, 0x0d, 0x0b, 0x25, 0xc8, 0x2d,
+	0xe1, 0x10, 0xd4, 0xf5, 0x6c, 0xd7, 0x51, 0xe7, 0x54, 0x70, 0x7d, 0xea, 0x90, 0x63, 0xe8, 0x8c,
+	0x7c, 0x2f, 0x62, 0x5e, 0x34, 0x8f, 0xec, 0x80, 0x86, 0x74, 0x16, 0x75, 0x8b, 0xa9, 0x9f, 0xff,
+	0xa1, 0x66, 0x9f, 0x21, 0xd7, 0x6a, 0x8f, 0xd2, 0x04, 0xf2, 0x21, 0xc0, 0x05, 0x9d, 0xba, 0x0e,
+	0xe5, 0x7e, 0x18, 0x75, 0x4b, 0xb7, 0x8b, 0x09, 0xe1, 0x73, 0xcd, 0x78, 0x16, 0x38, 0x94, 0xb3,
+	0x41, 0x49, 0xdc, 0xcc, 0x4a, 0xe0, 0xc9, 0x9b, 0xd0, 0xa6, 0x41, 0x60, 0x47, 0x9c, 0x72, 0x66,
+	0x0f, 0x2f, 0x39, 0x8b, 0x30, 0x1e, 0x1b, 0x56, 0x93, 0x06, 0xc1, 0x13, 0x41, 0x1d, 0x08, 0xa2,
+	0xe9, 0x40, 0x23, 0x19, 0x2a, 0x84, 0x40, 0xc9, 0xa1, 0x9c, 0xa2, 0x35, 0x1a, 0x16, 0x7e, 0x0b,
+	0x5a, 0x40, 0xf9, 0x44, 0xe9, 0x88, 0xdf, 0xe4, 0x06, 0x6c, 0x4e, 0x98, 0x3b, 0x9e, 0x70, 0x54,
+	0xab, 0x68, 0xa9, 0x95, 0x30, 0x7c, 0x10, 0xfa, 0x17, 0x0c, 0xb3, 0x45, 0xd5, 0x92, 0x0b, 0xf3,
+	0xaf, 0x06, 0x6c, 0xad, 0x84, 0x97, 0xd8, 0x77, 0x42, 0xa3, 0x89, 0x3e, 0x4b, 0x7c, 0x93, 0xb7,
+	0xc5, 0xbe, 0xd4, 0x61, 0xa1, 0xca, 0x62, 0x4d, 0xa5, 0xf1, 0x09, 0x12, 0x95, 0xa2, 0x0a, 0x42,
+	0x1e, 0x43, 0x67, 0x4a, 0x23, 0x6e, 0xcb, 0x28, 0xb0, 0x31, 0x4b, 0x15, 0x53, 0x91, 0xf9, 0x31,
+	0xd5, 0xd1, 0x22, 0x9c, 0x53, 0x89, 0xb7, 0xa6, 0x29, 0x2a, 0x39, 0x81, 0xed, 0xe1, 0xe5, 0xcf,
+	0xa9, 0xc7, 0x5d, 0x8f, 0xd9, 0x2b, 0x36, 0x6f, 0xab, 0xad, 0x1e, 0x5f, 0xb8, 0x0e, 0xf3, 0x46,
+	0xda, 0xd8, 0xd7, 0x62, 0x91, 0xf8, 0xc7, 0x88, 0xcc, 0xdb, 0xd0, 0x4a, 0xe7, 0x02, 0xd2, 0x82,
+	0x02, 0x5f, 0x28, 0x0d, 0x0b, 0x7c, 0x61, 0x9a, 0xd0, 0xc9, 0x06, 0xe4, 0x0a, 0x66, 0x1f, 0xda,
+	0x99, 0xe4, 0x90, 0x30, 0xb7, 0x91, 0x34, 0xb7, 0xd9, 0x86, 0x66, 0x2a, 0x27, 0x98, 0x9f, 0x95,
+	0xa1, 0x6a, 0xb1, 0x28, 0x10, 0xce, 0x44, 0x8e, 0xa0, 0xc6, 0x16, 0x23, 0x26, 0xd3, 0xb1, 0x91,
+	0x49, 0x76, 0x12, 0xf3, 0x58, 0xf3, 0x45, 0x5a, 0x88, 0xc1, 0x64, 0x3f, 0x55, 0x4a, 0xae, 0x65,
+	0x85, 0x92, 0xb5, 0xe4, 0x6e, 0xba, 0x96, 0x6c, 0x67, 0xb0, 0x99, 0x62, 0xb2, 0x9f, 0x2a, 0x26,
+	0xd9, 0x8d, 0x53, 0xd5, 0xe4, 0x7e, 0x4e, 0x35, 0xc9, 0x5e, 0x7f, 0x4d, 0x39, 0xb9, 0x9f, 0x53,
+	0x4e, 0xba, 0x2b, 0x67, 0xe5, 0xd6, 0x93, 0xbb, 0xe9, 0x7a, 0x92, 0x55, 0x27, 0x53, 0x50, 0x3e,
+	0xcc, 0x2b, 0x28, 0xb7, 0x32, 0x32, 0x6b, 0x2b, 0xca, 0xfb, 0x2b, 0x15, 0xe5, 0x46, 0x46, 0x34,
+	0xa7, 0xa4, 0xdc, 0x4f, 0xe5, 0x7a, 0xc8, 0xd5, 0x2d, 0x3f, 0xd9, 0x93, 0xef, 0xac, 0x56, 0xa3,
+	0x9b, 0xd9, 0x9f, 0x36, 0xaf, 0x1c, 0x1d, 0x64, 0xca, 0xd1, 0xf5, 0xec, 0x2d, 0x33, 0xf5, 0x68,
+	0x59, 0x55, 0xf6, 0x61, 0x4b, 0x83, 0x62, 0x4f, 0x13, 0x39, 0x82, 0x85, 0xa1, 0x1f, 0xaa, 0x84,
+	0x2d, 0x17, 0xe6, 0x1e, 0x34, 0x62, 0xe8, 0xab, 0x2b, 0x10, 0x3a, 0x7d, 0xc2, 0xbb, 0xcc, 0x2f,
+	0x0c, 0x68, 0x24, 0x5d, 0x28, 0x95, 0xc5, 0x6a, 0x2a, 0x8b, 0x25, 0x0a, 0x53, 0x21, 0x5d, 0x98,
+	0x76, 0xa1, 0x2e, 0x72, 0x65, 0xa6, 0xe6, 0xd0, 0x40, 0xd7, 0x1c, 0xf2, 0x2d, 0xd8, 0xc2, 0x3c,
+	0x23, 0xcb, 0x97, 0x0a, 0xc4, 0x12, 0x06, 0x62, 0x5b, 0x30, 0xa4, 0xc5, 0x90, 0x4c, 0xde, 0x81,
+	0x6b, 0x09, 0xac, 0xd8, 0x17, 0x73, 0x9c, 0x4c, 0xbe, 0x9d, 0x18, 0x7d, 0x1c, 0x04, 0x27, 0x34,
+	0x9a, 0x98, 0x3f, 0x84, 0xad, 0x15, 0x5f, 0x16, 0xd7, 0x1f, 0xf9, 0x8e, 0xd4, 0xbb, 0x69, 0xe1,
+	0xb7, 0xa8, 0x71, 0x53, 0x7f, 0x8c, 0x97, 0xab, 0x59, 0xe2, 0x53, 0xa0, 0xe2, 0x50, 0xaa, 0xc9,
+	0x98, 0x31, 0x7f, 0x65, 0xc0, 0xd6, 0x8a, 0x83, 0xe7, 0x56, 0x23, 0xe3, 0x3f, 0xa9, 0x46, 0x85,
+	0xd7, 0xab, 0x46, 0xe6, 0x95, 0x01, 0xcd, 0x54, 0x04, 0x7d, 0x7d, 0x15, 0x85, 0xf7, 0xb8, 0x9e,
+	0xc3, 0x16, 0x68, 0xd2, 0xa2, 0x25, 0x17, 0xba, 0x05, 0xd8, 0x44, 0x33, 0xa7, 0x5b, 0x80, 0x0a,
+	0xd2, 0xe4, 0x82, 0xdc, 0xc1, 0xfa, 0xe4, 0x3f, 0x57, 0xa1, 0xda, 0xec, 0xab, 0x46, 0xfd, 0x4c,
+	0x10, 0x2d, 0xc9, 0x4b, 0x64, 0xdb, 0x5a, 0xaa, 0xb8, 0xbd, 0x01, 0x35, 0x71, 0xd1, 0x28, 0xa0,
+	0x23, 0x86, 0x91, 0x57, 0xb3, 0x96, 0x04, 0xf3, 0x0c, 0xc8, 0x6a, 0xc4, 0x93, 0x0f, 0xa0, 0xc4,
+	0xe9, 0x58, 0xd8, 0x5b, 0x98, 0xac, 0xd5, 0x97, 0x4d, 0x7e, 0xff, 0xa3, 0xf3, 0x33, 0xea, 0x86,
+	0x83, 0x1b, 0xc2, 0x54, 0x7f, 0x7f, 0xb9, 0xdb, 0x12, 0x98, 0xbb, 0xfe, 0xcc, 0xe5, 0x6c, 0x16,
+	0xf0, 0x4b, 0x0b, 0x65, 0xcc, 0x5f, 0x16, 0xa0, 0xad, 0xb7, 0xd4, 0x05, 0x25, 0xcf, 0x70, 0xda,
+	0xdd, 0x0b, 0x89, 0xa2, 0xfd, 0xd5, 0x8c, 0xf9, 0xff, 0x00, 0x63, 0x1a, 0xd9, 0x9f, 0x52, 0x8f,
+	0x33, 0x47, 0x59, 0xb4, 0x36, 0xa6, 0xd1, 0x4f, 0x91, 0x20, 0x3a, 0x1c, 0xc1, 0x9e, 0x47, 0xcc,
+	0x41, 0xd3, 0x16, 0xad, 0xca, 0x98, 0x46, 0xcf, 0x22, 0xe6, 0xc4, 0x7a, 0x55, 0x5e, 0x5f, 0xaf,
+	0xb4, 0x1d, 0xab, 0x19, 0x3b, 0x92, 0x1e, 0x54, 0x83, 0xd0, 0xf5, 0x43, 0x97, 0x5f, 0x2a, 0xfb,
+	0xc7, 0x6b, 0xf3, 0x1f, 0x09, 0xff, 0x5e, 0x16, 0xd0, 0xff, 0x79, 0x9b, 0x98, 0x7f, 0x33, 0xa0,
+	0xa3, 0xf5, 0x8e, 0x9b, 0x82, 0x53, 0xd8, 0x8a, 0x63, 0xcc, 0x9e, 0x63, 0xec, 0x69, 0x3f, 0x7b,
+	0x75, 0x68, 0x76, 0x2e, 0xd2, 0xe4, 0x88, 0xfc, 0x08, 0x6e, 0x66, 0x32, 0x44, 0xbc, 0x61, 0xe1,
+	0x95, 0x89, 0xe2, 0x7a, 0x3a, 0x51, 0xe8, 0xfd, 0xb4, 0x25, 0x8a, 0x5f, 0xc3, 0xeb, 0xbf, 0x01,
+	0x2d, 0xad, 0xaa, 0x2c, 0x2c, 0x79, 0xbf, 0xa5, 0xf9, 0x6b, 0x03, 0xda, 0x99, 0xcb, 0x90, 0x3d,
+	0x28, 0xcb, 0xda, 0x66, 0xa4, 0x46, 0x55, 0xb4, 0x96, 0xba, 0xaf, 0x04, 0x90, 0xf7, 0xa0, 0xca,
+	0x54, 0x3f, 0xd7, 0x2d, 0xa4, 0x6a, 0x9a, 0x6e, 0xf3, 0x14, 0x3e, 0x86, 0x91, 0x6f, 0x43, 0x2d,
+	0x36, 0x5b, 0xa6, 0x97, 0x8f, 0xad, 0xac, 0x84, 0x96, 0x40, 0xf3, 0x21, 0xd4, 0x13, 0xc7, 0x93,
+	0xff, 0x83, 0xda, 0x8c, 0x2e, 0x54, 0x43, 0x2e, 0x5b, 0xb9, 0xea, 0x8c, 0x2e, 0xb0, 0x17, 0x27,
+	0x37, 0xa1, 0x22, 0x98, 0x63, 0x2a, 0x8d, 0x5e, 0xb4, 0x36, 0x67, 0x74, 0xf1, 0x03, 0x1a, 0x99,
+	0xfb, 0xd0, 0x4a, 0x5f, 0x4b, 0x43, 0x75, 0x71, 0x94, 0xd0, 0xe3, 0x31, 0x33, 0xef, 0x41, 0x3b,
+	0x73, 0x1b, 0x62, 0x42, 0x33, 0x98, 0x0f, 0xed, 0x17, 0xec, 0xd2, 0xc6, 0xeb, 0xa2, 0x8b, 0xd4,
+	0xac, 0x7a, 0x30, 0x1f, 0x7e, 0xc4, 0x2e, 0x9f, 0x0a, 0x92, 0xf9, 0x04, 0x5a, 0xe9, 0x56, 0x59,
+	0xa4, 0xcf, 0xd0, 0x9f, 0x7b, 0x0e, 0xee, 0x5f, 0xb6, 0xe4, 0x42, 0x4c, 0xdb, 0x17, 0xbe, 0xf4,
+	0x8a, 0x64, 0x6f, 0x7c, 0xee, 0x73, 0x96, 0x68, 0xb0, 0x25, 0xc6, 0xfc, 0x45, 0x19, 0x36, 0x65,
+	0xdf, 0x4e, 0xfa, 0xe9, 0xa9, 0x50, 0xb8, 0x84, 0x92, 0x94, 0x54, 0x25, 0xa8, 0x41, 0xe4, 0xcd,
+	0xec, 0x68, 0x35, 0xa8, 0x5f, 0xbd, 0xdc, 0xad, 0x60, 0x39, 0x3b, 0x7d, 0xb4, 0x9c, 0xb3, 0xd6,
+	0x8d, 0x21, 0x7a, 0xa8, 0x2b, 0xbd, 0xf6, 0x50, 0x77, 0x13, 0x2a, 0xde, 0x7c, 0x66, 0xf3, 0x45,
+	0xa4, 0x42, 0x7f, 0xd3, 0x9b, 0xcf, 0x9e, 0x2e, 0xf0, 0xa7, 0xe3, 0x3e, 0xa7, 0x53, 0x64, 0xc9,
+	0xc0, 0xaf, 0x22, 0x41, 0x30, 0x8f, 0xa0, 0x99, 0xa8, 0xfa, 0xae, 0xd3, 0xad, 0xa4, 0xb4, 0x44,
+	0x17, 0x38, 0x7d, 0xa4, 0xb4, 0xac, 0xc7, 0x5d, 0xc0, 0xa9, 0x43, 0xf6, 0xd2, 0x33, 0x0c, 0x36,
+	0x0b, 0x55, 0xf4, 0xf3, 0xc4, 0x98, 0x22, 0x5a, 0x05, 0x71, 0x01, 0xe1, 0xf9, 0x12, 0x52, 0x43,
+	0x48, 0x55, 0x10, 0x90, 0xf9, 0x16, 0xb4, 0x97, 0xf5, 0x56, 0x42, 0x40, 0xee, 0xb2, 0x24, 0x23,
+	0xf0, 0x5d, 0xd8, 0xf6, 0xd8, 0x82, 0xdb, 0x59, 0x74, 0x1d, 0xd1, 0x44, 0xf0, 0xce, 0xd3, 0x12,
+	0xdf, 0x84, 0xd6, 0x32, 0x37, 0x20, 0xb6, 0x21, 0x27, 0xc9, 0x98, 0x8a, 0xb0, 0x5b, 0x50, 0x8d,
+	0xbb, 0x9d, 0x26, 0x02, 0x2a, 0x54, 0x36, 0x39, 0x71, 0xff, 0x14, 0xb2, 0x68, 0x3e, 0xe5, 0x6a,
+	0x93, 0x16, 0x62, 0xb0, 0x7f, 0xb2, 0x24, 0x1d, 0xb1, 0x77, 0xa0, 0xa9, 0x43, 0x4e, 0xe2, 0xda,
+	0x88, 0x6b, 0x68, 0x22, 0x82, 0xf6, 0xa1, 0x13, 0x84, 0x7e, 0xe0, 0x47, 0x2c, 0xb4, 0xa9, 0xe3,
+	0x84, 0x2c, 0x8a, 0xba, 0x1d, 0xb9, 0x9f, 0xa6, 0x1f, 0x4b, 0xb2, 0xf9, 0x1e, 0x54, 0x74, 0x1b,
+	0xb7, 0x0d, 0xe5, 0x41, 0x9c, 0x1e, 0x4a, 0x96, 0x5c, 0x88, 0xa2, 0x70, 0x1c, 0x04, 0xea, 0x31,
+	0x42, 0x7c, 0x9a, 0x3f, 0x83, 0x8a, 0xfa, 0xc1, 0x72, 0x47, 0xd4, 0xef, 0x42, 0x23, 0xa0, 0xa1,
+	0x50, 0x23, 0x39, 0xa8, 0xea, 0x41, 0xe1, 0x8c, 0x86, 0xe2, 0x65, 0x22, 0x35, 0xaf, 0xd6, 0x11,
+	0x2f, 0x49, 0xe6, 0x7d, 0x68, 0xa6, 0x30, 0xe2, 0x5a, 0xe8, 0x47, 0x3a, 0xd2, 0x70, 0x11, 0x9f,
+	0x5c, 0x58, 0x9e, 0x6c, 0x3e, 0x80, 0x5a, 0xfc, 0xdb, 0x88, 0x7e, 0x56, 0xab, 0x6e, 0x28, 0x73,
+	0xcb, 0xa5, 0xd8, 0x30, 0xf0, 0x3f, 0x65, 0xa1, 0x8a, 0x09, 0xb9, 0x30, 0x9f, 0x25, 0x32, 0x83,
+	0x4c, 0xd3, 0xe4, 0x2e, 0x54, 0x54, 0x66, 0xe8, 0x1a, 0xa9, 0x69, 0xfb, 0x0c, 0x53, 0x83, 0x9e,
+	0xb6, 0x65, 0xa2, 0x58, 0x6e, 0x5b, 0x48, 0x6e, 0x3b, 0x85, 0xaa, 0x8e, 0xfe, 0x74, 0x8a, 0x94,
+	0x3b, 0x76, 0xb2, 0x29, 0x52, 0x6d, 0xba, 0x04, 0x0a, 0xef, 0x88, 0xdc, 0xb1, 0xc7, 0x1c, 0x7b,
+	0x19, 0x42, 0x78, 0x46, 0xd5, 0x6a, 0x4b, 0xc6, 0xc7, 0x3a, 0x5e, 0xcc, 0x77, 0x61, 0x53, 0xde,
+	0x4d, 0xd8, 0x47, 0xec, 0xac, 0x5b, 0x7c, 0xf1, 0x9d, 0x5b, 0x27, 0xfe, 0x60, 0x40, 0x55, 0x27,
+	0xcf, 0x5c, 0xa1, 0xd4, 0xa5, 0x0b, 0x5f, 0xf5, 0xd2, 0xff, 0xfd, 0xc4, 0x73, 0x17, 0x88, 0xcc,
+	0x2f, 0x17, 0x3e, 0x77, 0xbd, 0xb1, 0x2d, 0x6d, 0x2d, 0x73, 0x50, 0x07, 0x39, 0xe7, 0xc8, 0x38,
+	0x13, 0xf4, 0xc3, 0xcf, 0xca, 0xd0, 0x3e, 0x1e, 0x3c, 0x3c, 0x3d, 0x0e, 0x82, 0xa9, 0x3b, 0xa2,
+	0x38, 0x36, 0x1c, 0x40, 0x09, 0x27, 0xa7, 0x9c, 0x97, 0xdf, 0x5e, 0xde, 0x08, 0x4f, 0x0e, 0xa1,
+	0x8c, 0x03, 0x14, 0xc9, 0x7b, 0x00, 0xee, 0xe5, 0x4e, 0xf2, 0xe2, 0x10, 0x39, 0x62, 0xad, 0xbe,
+	0x03, 0xf7, 0xf2, 0xc6, 0x79, 0xf2, 0x3d, 0xa8, 0x2d, 0x27, 0x9b, 0x75, 0xaf, 0xc1, 0xbd, 0xb5,
+	0x83, 0xbd, 0x90, 0x5f, 0x76, 0x7a, 0xeb, 0x1e, 0x35, 0x7b, 0x6b, 0x27, 0x60, 0x72, 0x04, 0x15,
+	0xdd, 0x3b, 0xe7, 0xbf, 0xd7, 0xf6, 0xd6, 0x0c, 0xdd, 0xc2, 0x3c, 0x72, 0x58, 0xc9, 0x7b, 0x54,
+	0xee, 0xe5, 0xbe, 0x0c, 0x90, 0x7b, 0xb0, 0xa9, 0x9a, 0x96, 0xdc, 0x37, 0xdb, 0x5e, 0xfe, 0xe8,
+	0x2c, 0x94, 0x5c, 0x8e, 0x6b, 0xeb, 0x1e, 0xbe, 0x7b, 0x6b, 0x9f, 0x30, 0xc8, 0x31, 0x40, 0x62,
+	0xe6, 0x58, 0xfb, 0xa2, 0xdd, 0x5b, 0xff, 0x34, 0x41, 0x1e, 0x40, 0x75, 0xf9, 0xdc, 0x94, 0xff,
+	0x46, 0xdd, 0x5b, 0xf7, 0x5a, 0x30, 0x78, 0xe3, 0x9f, 0x7f, 0xde, 0x31, 0x7e, 0x73, 0xb5, 0x63,
+	0x7c, 0x71, 0xb5, 0x63, 0x7c, 0x79, 0xb5, 0x63, 0xfc, 0xfe, 0x6a, 0xc7, 0xf8, 0xd3, 0xd5, 0x8e,
+	0xf1, 0xdb, 0xbf, 0xec, 0x18, 0xc3, 0x4d, 0x74, 0xff, 0xf7, 0xff, 0x35, 0x00, 0xe9, 0x1d, 0x70,
+	0x1d, 0x93, 0x19, 0x00, 0x00,
 }
@@ -1,7 +1,6 @@
 package cell
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"net"
@@ -18,14 +17,21 @@ import (
 
 	bc "github.com/teragrid/dgrid/core/blockchain"
 	"github.com/teragrid/dgrid/core/blockchain/p2p"
+	"github.com/teragrid/dgrid/core/blockchain/p2p/conn"
 	"github.com/teragrid/dgrid/core/blockchain/p2p/pex"
+	"github.com/teragrid/dgrid/core/checkpoint"
 	cfg "github.com/teragrid/dgrid/core/config"
-	cs "github.com/teragrid/dgrid/core/consensus"
+	csm "github.com/teragrid/dgrid/core/consensus/manager"
+	"github.com/teragrid/dgrid/core/consensus/protocols"
+	"github.com/teragrid/dgrid/core/governance"
 	"github.com/teragrid/dgrid/core/types"
 	ttime "github.com/teragrid/dgrid/core/types/time"
 	"github.com/teragrid/dgrid/evidence"
 	cmn "github.com/teragrid/dgrid/pkg/common"
+	"github.com/teragrid/dgrid/pkg/crypto"
 	"github.com/teragrid/dgrid/pkg/crypto/ed25519"
+	"github.com/teragrid/dgrid/pkg/crypto/keyfile"
+	"github.com/teragrid/dgrid/pkg/crypto/multisig"
 	dbm "github.com/teragrid/dgrid/pkg/db"
 	"github.com/teragrid/dgrid/pkg/log"
 	tpubsub "github.com/teragrid/dgrid/pkg/pubsub"
@@ -36,7 +42,6 @@ import (
 	rpcserver "github.com/teragrid/dgrid/rpc/lib/server"
 	sm "github.com/teragrid/dgrid/state"
 	"github.com/teragrid/dgrid/state/txindex"
-	"github.com/teragrid/dgrid/state/txindex/kv"
 	"github.com/teragrid/dgrid/state/txindex/null"
 	storage "github.com/teragrid/dgrid/storage"
 	"github.com/teragrid/dgrid/third_party/amino"
@@ -79,11 +84,22 @@ func DefaultGenesisDocProviderFunc(config *cfg.Config) GenesisDocProvider {
 type CellProvider func(*cfg.Config, log.Logger) (*Cell, error)
 
 // DefaultNewCell returns a Dgrid cell with default settings for the
-// Validator, ClientCreator, GenesisDoc, and DBProvider.
+// Validator, ClientCreator, GenesisDoc, and DBProvider, taking part in the
+// leagues of the league registry of config.RootDir as well.
 // It implements CellProvider.
 func DefaultNewCell(config *cfg.Config, logger log.Logger) (*Cell, error) {
+	// Passphrase of the encrypted key files, if any
+	var passphrase keyfile.Passphrase
+	if config.KeyPassphrase != "" {
+		var err error
+		passphrase, err = keyfile.NewPassphrase(config.KeyPassphrase)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Generate cell PrivKey
-	cellKey, err := p2p.LoadOrGenCellKey(config.CellKeyFile())
+	cellKey, err := p2p.LoadOrGenNodeKeyWithPassphrase(config.CellKeyFile(), passphrase)
 	if err != nil {
 		return nil, err
 	}
@@ -105,31 +121,54 @@ func DefaultNewCell(config *cfg.Config, logger log.Logger) (*Cell, error) {
 		oldPV.Upgrade(newPrivValKey, newPrivValState)
 	}
 
+	// Take part in the leagues of the registry as well
+	registry, err := cfg.LoadLeagueRegistry(config.RootDir)
+	if err != nil {
+		return nil, err
+	}
+	var options []CellOption
+	for _, rec := range registry.Leagues() {
+		leagueConfig, err := rec.LoadConfig()
+		if err != nil {
+			return nil, err
+		}
+		if rec.Type == cfg.BaseLeagueType {
+			options = append(options, WithBaseLeague(rec.ID))
+		}
+		options = append(options, WithLeague(
+			leagueConfig,
+			validator.LoadOrGenFilePVWithPassphrase(leagueConfig.ValidatorKeyFile(),
+				leagueConfig.ValidatorStateFile(), passphrase),
+			proxy.DefaultClientCreator(leagueConfig.ProxyApp, leagueConfig.Asura, leagueConfig.DBDir()),
+		))
+	}
+
 	return NewCell(config,
-		validator.LoadOrGenFilePV(newPrivValKey, newPrivValState),
+		validator.LoadOrGenFilePVWithPassphrase(newPrivValKey, newPrivValState, passphrase),
 		cellKey,
 		proxy.DefaultClientCreator(config.ProxyApp, config.Asura, config.DBDir()),
 		DefaultGenesisDocProviderFunc(config),
 		DefaultDBProvider,
 		DefaultMetricsProvider(config.Instrumentation),
 		logger,
+		options...,
 	)
 }
 
 // MetricsProvider returns a consensus, p2p and storage Metrics.
-type MetricsProvider func(leagueID string) (*cs.Metrics, *p2p.Metrics, *storage.Metrics, *sm.Metrics)
+type MetricsProvider func(leagueID string) (*protocols.Metrics, *p2p.Metrics, *storage.Metrics, *sm.Metrics)
 
 // DefaultMetricsProvider returns Metrics build using Prometheus client library
 // if Prometheus is enabled. Otherwise, it returns no-op Metrics.
 func DefaultMetricsProvider(config *cfg.InstrumentationConfig) MetricsProvider {
-	return func(leagueID string) (*cs.Metrics, *p2p.Metrics, *storage.Metrics, *sm.Metrics) {
+	return func(leagueID string) (*protocols.Metrics, *p2p.Metrics, *storage.Metrics, *sm.Metrics) {
 		if config.Prometheus {
-			return cs.PrometheusMetrics(config.Namespace, "chain_id", leagueID),
+			return protocols.PrometheusMetrics(config.Namespace, "chain_id", leagueID),
 				p2p.PrometheusMetrics(config.Namespace, "chain_id", leagueID),
 				storage.PrometheusMetrics(config.Namespace, "chain_id", leagueID),
 				sm.PrometheusMetrics(config.Namespace, "chain_id", leagueID)
 		}
-		return cs.NopMetrics(), p2p.NopMetrics(), storage.NopMetrics(), sm.NopMetrics()
+		return protocols.NopMetrics(), p2p.NopMetrics(), storage.NopMetrics(), sm.NopMetrics()
 	}
 }
 
@@ -137,38 +176,49 @@ func DefaultMetricsProvider(config *cfg.InstrumentationConfig) MetricsProvider {
 
 // Cell is the highest level interface to a full Dgrid cell.
 // It includes all configuration information and running services.
+// A Cell takes part in one or more leagues; the main league, whose config
+// the Cell is created with, serves the RPC, and the accessors return its
+// services.
 type Cell struct {
 	cmn.BaseService
 
-	// config
-	config     *cfg.Config
-	genesisDoc *types.GenesisDoc // initial validator set
-	validator  types.Validator   // local cell's validator key
-
-	// network
-	transport   *p2p.MultiplexTransport
-	sw          *p2p.Switch  // p2p connections
-	addrBook    pex.AddrBook // known peers
-	cellInfo    p2p.CellInfo
-	cellKey     *p2p.CellKey // our cell privkey
-	isListening bool
-
-	// services
-	eventBus         *types.EventBus // pub/sub for services
-	stateDB          dbm.DB
-	blockStore       *bc.BlockStore          // store the blockchain to disk
-	bcReactor        *bc.BlockchainReactor   // for fast-syncing
-	storageReactor   *storage.StorageReactor // for gossipping transactions
-	consensusState   *cs.ConsensusState      // latest consensus state
-	consensusReactor *cs.ConsensusReactor    // for participating in the consensus
-	evidencePool     *evidence.EvidencePool  // tracking evidence
-	proxyApp         proxy.AppConns          // connection to the application
-	rpcListeners     []net.Listener          // rpc servers
-	txIndexer        txindex.TxIndexer
-	indexerService   *txindex.IndexerService
+	// the main league
+	*cellLeague
+
+	leagues          []*cellLeague // all the leagues, the main one first
+	extraLeagues     []leagueParams
+	cellKey          *p2p.CellKey // our cell privkey
+	consensusManager *csm.Manager // runs the engines of the leagues
+	baseLeagueID     string
+	governance       *governance.Manager // nil unless the Cell runs the Base league
+	checkpoints      *checkpoint.Manager // nil unless the Cell runs the Base league
+	rpcListeners     []net.Listener
 	prometheusSrv    *http.Server
 }
 
+// leagueParams are the parameters of a league other than the main one.
+type leagueParams struct {
+	config        *cfg.Config
+	validator     types.Validator
+	clientCreator proxy.ClientCreator
+}
+
+// CellOption sets an optional parameter on the Cell.
+type CellOption func(*Cell)
+
+// WithLeague makes the Cell take part in the league of config as well, with
+// its own validator and application. The league shares the cell key and the
+// consensus manager of the Cell.
+func WithLeague(config *cfg.Config, validator types.Validator, clientCreator proxy.ClientCreator) CellOption {
+	return func(n *Cell) {
+		n.extraLeagues = append(n.extraLeagues, leagueParams{
+			config:        config,
+			validator:     validator,
+			clientCreator: clientCreator,
+		})
+	}
+}
+
 // NewCell returns a new, ready to go, Dgrid Cell.
 func NewCell(config *cfg.Config,
 	validator types.Validator,
@@ -177,339 +227,74 @@ func NewCell(config *cfg.Config,
 	genesisDocProvider GenesisDocProvider,
 	dbProvider DBProvider,
 	metricsProvider MetricsProvider,
-	logger log.Logger) (*Cell, error) {
-
-	// Get BlockStore
-	blockStoreDB, err := dbProvider(&DBContext{"blockstore", config})
-	if err != nil {
-		return nil, err
-	}
-	blockStore := bc.NewBlockStore(blockStoreDB)
-
-	// Get State
-	stateDB, err := dbProvider(&DBContext{"state", config})
-	if err != nil {
-		return nil, err
-	}
+	logger log.Logger,
+	options ...CellOption) (*Cell, error) {
 
-	// Get genesis doc
-	// TODO: move to state package?
-	genDoc, err := loadGenesisDoc(stateDB)
-	if err != nil {
-		genDoc, err = genesisDocProvider()
-		if err != nil {
-			return nil, err
-		}
-		// save genesis doc to prevent a certain class of user errors (e.g. when it
-		// was changed, accidentally or not). Also good for audit trail.
-		saveGenesisDoc(stateDB, genDoc)
+	cell := &Cell{cellKey: cellKey}
+	cell.BaseService = *cmn.NewBaseService(logger, "Cell", cell)
+	for _, option := range options {
+		option(cell)
 	}
 
-	state, err := sm.LoadStateFromDBOrGenesisDoc(stateDB, genDoc)
+	// The manager replaces the engine of a league when it migrates to another
+	// protocol, and the reactor of the league switches to the new one.
+	// The reconfigurations are persisted, so that a restarted cell runs the
+	// protocol in effect at its height.
+	consensusLogger := logger.With("module", "consensus")
+	consensusReactors := make(map[string]*protocols.ConsensusReactor)
+	reconfigurationDB, err := dbProvider(&DBContext{"reconfigurations", config})
 	if err != nil {
 		return nil, err
 	}
+	cell.consensusManager = csm.NewManager(
+		csm.WithEngineListener(func(leagueID string, engine protocols.ConsensusEngine) {
+			conR, ok := consensusReactors[leagueID]
+			if !ok {
+				return
+			}
+			if err := conR.SwitchEngine(engine); err != nil {
+				consensusLogger.Error("Error switching consensus engine", "league", leagueID, "err", err)
+			}
+		}),
+		csm.WithReconfigurationStore(csm.NewReconfigurationStore(reconfigurationDB)),
+	)
+	cell.consensusManager.SetLogger(consensusLogger)
 
-	// Create the proxyApp and establish connections to the Asura app (consensus, storage, query).
-	proxyApp := proxy.NewAppConns(clientCreator)
-	proxyApp.SetLogger(logger.With("module", "proxy"))
-	if err := proxyApp.Start(); err != nil {
-		return nil, fmt.Errorf("Error starting proxy app connections: %v", err)
-	}
-
-	// EventBus and IndexerService must be started before the handshake because
-	// we might need to index the txs of the replayed block as this might not have happened
-	// when the cell stopped last time (i.e. the cell stopped after it saved the block
-	// but before it indexed the txs, or, endblocker panicked)
-	eventBus := types.NewEventBus()
-	eventBus.SetLogger(logger.With("module", "events"))
-
-	err = eventBus.Start()
+	mainLeague, err := newCellLeague(config, validator, cellKey, clientCreator, genesisDocProvider,
+		dbProvider, metricsProvider, cell.consensusManager, logger)
 	if err != nil {
 		return nil, err
 	}
-
-	// Transaction indexing
-	var txIndexer txindex.TxIndexer
-	switch config.TxIndex.Indexer {
-	case "kv":
-		store, err := dbProvider(&DBContext{"tx_index", config})
-		if err != nil {
-			return nil, err
+	cell.cellLeague = mainLeague
+	cell.leagues = append(cell.leagues, mainLeague)
+	consensusReactors[mainLeague.genesisDoc.LeagueID] = mainLeague.consensusReactor
+
+	for _, params := range cell.extraLeagues {
+		league, err := newCellLeague(params.config, params.validator, cellKey, params.clientCreator,
+			DefaultGenesisDocProviderFunc(params.config), dbProvider, metricsProvider,
+			cell.consensusManager, logger)
+		if err == csm.ErrLeagueExists {
+			logger.Info("Skipping league run by the cell already", "home", params.config.RootDir)
+			continue
 		}
-		if config.TxIndex.IndexTags != "" {
-			txIndexer = kv.NewTxIndex(store, kv.IndexTags(splitAndTrimEmpty(config.TxIndex.IndexTags, ",", " ")))
-		} else if config.TxIndex.IndexAllTags {
-			txIndexer = kv.NewTxIndex(store, kv.IndexAllTags())
-		} else {
-			txIndexer = kv.NewTxIndex(store)
-		}
-	default:
-		txIndexer = &null.TxIndex{}
-	}
-
-	indexerService := txindex.NewIndexerService(txIndexer, eventBus)
-	indexerService.SetLogger(logger.With("module", "txindex"))
-
-	err = indexerService.Start()
-	if err != nil {
-		return nil, err
-	}
-
-	// Create the handshaker, which calls RequestInfo, sets the AppVersion on the state,
-	// and replays any blocks as necessary to sync teragrid with the app.
-	consensusLogger := logger.With("module", "consensus")
-	handshaker := cs.NewHandshaker(stateDB, state, blockStore, genDoc)
-	handshaker.SetLogger(consensusLogger)
-	handshaker.SetEventBus(eventBus)
-	if err := handshaker.Handshake(proxyApp); err != nil {
-		return nil, fmt.Errorf("Error during handshake: %v", err)
-	}
-
-	// Reload the state. It will have the Version.Consensus.App set by the
-	// Handshake, and may have other modifications as well (ie. depending on
-	// what happened during block replay).
-	state = sm.LoadState(stateDB)
-
-	// Log the version info.
-	logger.Info("Version info",
-		"software", version.TMCoreSemVer,
-		"block", version.BlockProtocol,
-		"p2p", version.P2PProtocol,
-	)
-
-	// If the state and software differ in block version, at least log it.
-	if state.Version.Consensus.Block != version.BlockProtocol {
-		logger.Info("Software and state have different block protocols",
-			"software", version.BlockProtocol,
-			"state", state.Version.Consensus.Block,
-		)
-	}
-
-	if config.ValidatorListenAddr != "" {
-		// If an address is provided, listen on the socket for a connection from an
-		// external signing process.
-		// FIXME: we should start services inside OnStart
-		validator, err = createAndStartValidatorSocketClient(config.ValidatorListenAddr, logger)
 		if err != nil {
-			return nil, errors.Wrap(err, "Error with private validator socket client")
+			return nil, errors.Wrapf(err, "Error setting up league at %s", params.config.RootDir)
 		}
+		cell.leagues = append(cell.leagues, league)
+		consensusReactors[league.genesisDoc.LeagueID] = league.consensusReactor
 	}
 
-	// Decide whether to fast-sync or not
-	// We don't fast-sync when the only validator is us.
-	fastSync := config.FastSync
-	if state.Validators.Size() == 1 {
-		addr, _ := state.Validators.GetByIndex(0)
-		privValAddr := validator.GetPubKey().Address()
-		if bytes.Equal(privValAddr, addr) {
-			fastSync = false
+	// Govern the configuration of the leagues through the Base league, and
+	// anchor their history into it
+	if base := cell.league(cell.baseLeagueID); base != nil {
+		if cell.governance, err = cell.newGovernanceManager(base, dbProvider); err != nil {
+			return nil, err
+		}
+		if cell.checkpoints, err = cell.newCheckpointManager(base, dbProvider); err != nil {
+			return nil, err
 		}
 	}
 
-	pubKey := validator.GetPubKey()
-	addr := pubKey.Address()
-	// Log whether this cell is a validator or an observer
-	if state.Validators.HasAddress(addr) {
-		consensusLogger.Info("This cell is a validator", "addr", addr, "pubKey", pubKey)
-	} else {
-		consensusLogger.Info("This cell is not a validator", "addr", addr, "pubKey", pubKey)
-	}
-
-	csMetrics, p2pMetrics, memplMetrics, smMetrics := metricsProvider(genDoc.LeagueID)
-
-	// Make StorageReactor
-	storage := storage.NewStorage(
-		config.Storage,
-		proxyApp.Storage(),
-		state.LastBlockHeight,
-		storage.WithMetrics(memplMetrics),
-		storage.WithPreCheck(sm.TxPreCheck(state)),
-		storage.WithPostCheck(sm.TxPostCheck(state)),
-	)
-	storageLogger := logger.With("module", "storage")
-	storage.SetLogger(storageLogger)
-	if config.Storage.WalEnabled() {
-		storage.InitWAL() // no need to have the storage wal during tests
-	}
-	storageReactor := storage.NewStorageReactor(config.Storage, storage)
-	storageReactor.SetLogger(storageLogger)
-
-	if config.Consensus.WaitForTxs() {
-		storage.EnableTxsAvailable()
-	}
-
-	// Make Evidence Reactor
-	evidenceDB, err := dbProvider(&DBContext{"evidence", config})
-	if err != nil {
-		return nil, err
-	}
-	evidenceLogger := logger.With("module", "evidence")
-	evidencePool := evidence.NewEvidencePool(stateDB, evidenceDB)
-	evidencePool.SetLogger(evidenceLogger)
-	evidenceReactor := evidence.NewEvidenceReactor(evidencePool)
-	evidenceReactor.SetLogger(evidenceLogger)
-
-	blockExecLogger := logger.With("module", "state")
-	// make block executor for consensus and blockchain reactors to execute blocks
-	blockExec := sm.NewBlockExecutor(
-		stateDB,
-		blockExecLogger,
-		proxyApp.Consensus(),
-		storage,
-		evidencePool,
-		sm.BlockExecutorWithMetrics(smMetrics),
-	)
-
-	// Make BlockchainReactor
-	bcReactor := bc.NewBlockchainReactor(state.Copy(), blockExec, blockStore, fastSync)
-	bcReactor.SetLogger(logger.With("module", "blockchain"))
-
-	// Make ConsensusReactor
-	consensusState := cs.NewConsensusState(
-		config.Consensus,
-		state.Copy(),
-		blockExec,
-		blockStore,
-		storage,
-		evidencePool,
-		cs.StateMetrics(csMetrics),
-	)
-	consensusState.SetLogger(consensusLogger)
-	if validator != nil {
-		consensusState.SetValidator(validator)
-	}
-	consensusReactor := cs.NewConsensusReactor(consensusState, fastSync, cs.ReactorMetrics(csMetrics))
-	consensusReactor.SetLogger(consensusLogger)
-
-	// services which will be publishing and/or subscribing for messages (events)
-	// consensusReactor will set it on consensusState and blockExecutor
-	consensusReactor.SetEventBus(eventBus)
-
-	p2pLogger := logger.With("module", "p2p")
-	cellInfo, err := makeCellInfo(
-		config,
-		cellKey.ID(),
-		txIndexer,
-		genDoc.LeagueID,
-		p2p.NewProtocolVersion(
-			version.P2PProtocol, // global
-			state.Version.Consensus.Block,
-			state.Version.Consensus.App,
-		),
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	// Setup Transport.
-	var (
-		mConnConfig = p2p.MConnConfig(config.P2P)
-		transport   = p2p.NewMultiplexTransport(cellInfo, *cellKey, mConnConfig)
-		connFilters = []p2p.ConnFilterFunc{}
-		peerFilters = []p2p.PeerFilterFunc{}
-	)
-
-	if !config.P2P.AllowDuplicateIP {
-		connFilters = append(connFilters, p2p.ConnDuplicateIPFilter())
-	}
-
-	// Filter peers by addr or pubkey with an Asura query.
-	// If the query return code is OK, add peer.
-	if config.FilterPeers {
-		connFilters = append(
-			connFilters,
-			// Asura query for address filtering.
-			func(_ p2p.ConnSet, c net.Conn, _ []net.IP) error {
-				res, err := proxyApp.Query().QuerySync(asura.RequestQuery{
-					Path: fmt.Sprintf("/p2p/filter/addr/%s", c.RemoteAddr().String()),
-				})
-				if err != nil {
-					return err
-				}
-				if res.IsErr() {
-					return fmt.Errorf("Error querying asura app: %v", res)
-				}
-
-				return nil
-			},
-		)
-
-		peerFilters = append(
-			peerFilters,
-			// Asura query for ID filtering.
-			func(_ p2p.IPeerSet, p p2p.Peer) error {
-				res, err := proxyApp.Query().QuerySync(asura.RequestQuery{
-					Path: fmt.Sprintf("/p2p/filter/id/%s", p.ID()),
-				})
-				if err != nil {
-					return err
-				}
-				if res.IsErr() {
-					return fmt.Errorf("Error querying asura app: %v", res)
-				}
-
-				return nil
-			},
-		)
-	}
-
-	p2p.MultiplexTransportConnFilters(connFilters...)(transport)
-
-	// Setup Switch.
-	sw := p2p.NewSwitch(
-		config.P2P,
-		transport,
-		p2p.WithMetrics(p2pMetrics),
-		p2p.SwitchPeerFilters(peerFilters...),
-	)
-	sw.SetLogger(p2pLogger)
-	sw.AddReactor("MEMPOOL", storageReactor)
-	sw.AddReactor("BLOCKCHAIN", bcReactor)
-	sw.AddReactor("CONSENSUS", consensusReactor)
-	sw.AddReactor("EVIDENCE", evidenceReactor)
-	sw.SetCellInfo(cellInfo)
-	sw.SetCellKey(cellKey)
-
-	p2pLogger.Info("P2P Cell ID", "ID", cellKey.ID(), "file", config.CellKeyFile())
-
-	// Optionally, start the pex reactor
-	//
-	// TODO:
-	//
-	// We need to set Seeds and PersistentPeers on the switch,
-	// since it needs to be able to use these (and their DNS names)
-	// even if the PEX is off. We can include the DNS name in the NetAddress,
-	// but it would still be nice to have a clear list of the current "PersistentPeers"
-	// somewhere that we can return with net_info.
-	//
-	// If PEX is on, it should handle dialing the seeds. Otherwise the switch does it.
-	// Note we currently use the addrBook regardless at least for AddOurAddress
-	addrBook := pex.NewAddrBook(config.P2P.AddrBookFile(), config.P2P.AddrBookStrict)
-
-	// Add ourselves to addrbook to prevent dialing ourselves
-	addrBook.AddOurAddress(sw.NetAddress())
-
-	addrBook.SetLogger(p2pLogger.With("book", config.P2P.AddrBookFile()))
-	if config.P2P.PexReactor {
-		// TODO persistent peers ? so we can have their DNS addrs saved
-		pexReactor := pex.NewPEXReactor(addrBook,
-			&pex.PEXReactorConfig{
-				Seeds:    splitAndTrimEmpty(config.P2P.Seeds, ",", " "),
-				SeedMode: config.P2P.SeedMode,
-				// See consensus/reactor.go: blocksToContributeToBecomeGoodPeer 10000
-				// blocks assuming 10s blocks ~ 28 hours.
-				// TODO (melekes): make it dynamic based on the actual block latencies
-				// from the live network.
-				// https://github.com/teragrid/dgrid/issues/3523
-				SeedDisconnectWaitPeriod: 28 * time.Hour,
-			})
-		pexReactor.SetLogger(logger.With("module", "pex"))
-		sw.AddReactor("PEX", pexReactor)
-	}
-
-	sw.SetAddrBook(addrBook)
-
 	// run the profile server
 	profileHost := config.ProfListenAddress
 	if profileHost != "" {
@@ -518,30 +303,6 @@ func NewCell(config *cfg.Config,
 		}()
 	}
 
-	cell := &Cell{
-		config:     config,
-		genesisDoc: genDoc,
-		validator:  validator,
-
-		transport: transport,
-		sw:        sw,
-		addrBook:  addrBook,
-		cellInfo:  cellInfo,
-		cellKey:   cellKey,
-
-		stateDB:          stateDB,
-		blockStore:       blockStore,
-		bcReactor:        bcReactor,
-		storageReactor:   storageReactor,
-		consensusState:   consensusState,
-		consensusReactor: consensusReactor,
-		evidencePool:     evidencePool,
-		proxyApp:         proxyApp,
-		txIndexer:        txIndexer,
-		indexerService:   indexerService,
-		eventBus:         eventBus,
-	}
-	cell.BaseService = *cmn.NewBaseService(logger, "Cell", cell)
 	return cell, nil
 }
 
@@ -554,9 +315,6 @@ func (n *Cell) OnStart() error {
 		time.Sleep(genTime.Sub(now))
 	}
 
-	// Add private IDs to addrbook to block those peers being added
-	n.addrBook.AddPrivateIDs(splitAndTrimEmpty(n.config.P2P.PrivatePeerIDs, ",", " "))
-
 	// Start the RPC server before the P2P server
 	// so we can eg. receive txs for the first block
 	if n.config.RPC.ListenAddress != "" {
@@ -572,31 +330,26 @@ func (n *Cell) OnStart() error {
 		n.prometheusSrv = n.startPrometheusServer(n.config.Instrumentation.PrometheusListenAddr)
 	}
 
-	// Start the transport.
-	addr, err := p2p.NewNetAddressStringWithOptionalID(n.config.P2P.ListenAddress)
-	if err != nil {
-		return err
-	}
-	if err := n.transport.Listen(*addr); err != nil {
-		return err
+	for _, league := range n.leagues {
+		if err := league.start(); err != nil {
+			return errors.Wrapf(err, "Error starting league %s", league.genesisDoc.LeagueID)
+		}
 	}
 
-	n.isListening = true
-
-	// Start the switch (the P2P server).
-	err = n.sw.Start()
-	if err != nil {
+	// Start the engines of the leagues which don't fast sync. The others are
+	// started by their reactor once they are synced.
+	if err := n.consensusManager.Start(); err != nil {
 		return err
 	}
 
-	// Always connect to persistent peers
-	if n.config.P2P.PersistentPeers != "" {
-		err = n.sw.DialPeersAsync(n.addrBook, splitAndTrimEmpty(n.config.P2P.PersistentPeers, ",", " "), true)
-		if err != nil {
+	if n.governance != nil {
+		if err := n.startGovernance(); err != nil {
 			return err
 		}
 	}
-
+	if n.checkpoints != nil {
+		return n.startCheckpoints()
+	}
 	return nil
 }
 
@@ -606,24 +359,17 @@ func (n *Cell) OnStop() {
 
 	n.Logger.Info("Stopping Cell")
 
-	// first stop the non-reactor services
-	n.eventBus.Stop()
-	n.indexerService.Stop()
-
-	// now stop the reactors
-	// TODO: gracefully disconnect from peers.
-	n.sw.Stop()
-
-	// stop storage WAL
-	if n.config.Storage.WalEnabled() {
-		n.storageReactor.Storage.CloseWAL()
+	// first stop the consensus engines, then the services of the leagues
+	if n.governance != nil {
+		n.governance.Stop()
 	}
-
-	if err := n.transport.Close(); err != nil {
-		n.Logger.Error("Error closing transport", "err", err)
+	if n.checkpoints != nil {
+		n.checkpoints.Stop()
+	}
+	n.consensusManager.Stop()
+	for _, league := range n.leagues {
+		league.stop()
 	}
-
-	n.isListening = false
 
 	// finally stop the listeners / external services
 	for _, l := range n.rpcListeners {
@@ -633,10 +379,6 @@ func (n *Cell) OnStop() {
 		}
 	}
 
-	if pvsc, ok := n.validator.(cmn.Service); ok {
-		pvsc.Stop()
-	}
-
 	if n.prometheusSrv != nil {
 		if err := n.prometheusSrv.Shutdown(context.Background()); err != nil {
 			// Error from closing listeners, or context timeout:
@@ -650,7 +392,7 @@ func (n *Cell) OnStop() {
 func (n *Cell) ConfigureRPC() {
 	rpccore.SetStateDB(n.stateDB)
 	rpccore.SetBlockStore(n.blockStore)
-	rpccore.SetConsensusState(n.consensusState)
+	rpccore.SetConsensusState(n.consensusReactor.Engine())
 	rpccore.SetStorage(n.storageReactor.Storage)
 	rpccore.SetEvidencePool(n.evidencePool)
 	rpccore.SetP2PPeers(n.sw)
@@ -787,13 +529,30 @@ func (n *Cell) BlockStore() *bc.BlockStore {
 	return n.blockStore
 }
 
-// ConsensusState returns the Cell's ConsensusState.
-func (n *Cell) ConsensusState() *cs.ConsensusState {
-	return n.consensusState
+// ConsensusEngine returns the Cell's ConsensusEngine.
+func (n *Cell) ConsensusEngine() protocols.ConsensusEngine {
+	return n.consensusReactor.Engine()
+}
+
+// ConsensusManager returns the Cell's consensus Manager.
+func (n *Cell) ConsensusManager() *csm.Manager {
+	return n.consensusManager
+}
+
+// MigrateConsensus proposes to switch a league of the Cell to the given
+// consensus config, eg. from BFT to FBA, from the given height on, and
+// returns the ID of the proposal. Once the validators of the Base league
+// approved it, every cell of the league commits the blocks up to height-1
+// with the current engine, whose last state seeds the new one.
+func (n *Cell) MigrateConsensus(leagueID string, height int64, config cfg.Config) ([]byte, error) {
+	if n.governance == nil {
+		return nil, ErrNoGovernance
+	}
+	return n.governance.ProposeConfig(leagueID, height, config)
 }
 
 // ConsensusReactor returns the Cell's ConsensusReactor.
-func (n *Cell) ConsensusReactor() *cs.ConsensusReactor {
+func (n *Cell) ConsensusReactor() *protocols.ConsensusReactor {
 	return n.consensusReactor
 }
 
@@ -855,6 +614,7 @@ func makeCellInfo(
 	cellID p2p.ID,
 	txIndexer txindex.TxIndexer,
 	leagueID string,
+	consensusChannels []*conn.ChannelDescriptor,
 	protocolVersion p2p.ProtocolVersion,
 ) (p2p.CellInfo, error) {
 	txIndexerStatus := "on"
@@ -866,19 +626,22 @@ func makeCellInfo(
 		ID_:             cellID,
 		Network:         leagueID,
 		Version:         version.TMCoreSemVer,
-		Channels: []byte{
-			bc.BlockchainChannel,
-			cs.StateChannel, cs.DataChannel, cs.VoteChannel, cs.VoteSetBitsChannel,
-			storage.StorageChannel,
-			evidence.EvidenceChannel,
-		},
-		Moniker: config.Moniker,
+		Channels:        []byte{bc.BlockchainChannel},
+		Moniker:         config.Moniker,
 		Other: p2p.DefaultCellInfoOther{
 			TxIndex:    txIndexerStatus,
 			RPCAddress: config.RPC.ListenAddress,
 		},
 	}
 
+	for _, desc := range consensusChannels {
+		cellInfo.Channels = append(cellInfo.Channels, desc.ID)
+	}
+	cellInfo.Channels = append(cellInfo.Channels, storage.StorageChannel, evidence.EvidenceChannel)
+	if config.Storage.AnnounceTxs {
+		cellInfo.Channels = append(cellInfo.Channels, storage.StorageAnnounceChannel)
+	}
+
 	if config.P2P.PexReactor {
 		cellInfo.Channels = append(cellInfo.Channels, pex.PexChannel)
 	}
@@ -926,11 +689,45 @@ func saveGenesisDoc(db dbm.DB, genDoc *types.GenesisDoc) {
 
 func createAndStartValidatorSocketClient(
 	listenAddr string,
+	leagueID string,
+	nodePrivKey crypto.PrivKey,
 	logger log.Logger,
 ) (types.Validator, error) {
+	pvsc, err := newValidatorSocketClient(listenAddr, leagueID, nodePrivKey, logger)
+	if err != nil {
+		return nil, err
+	}
+	if err := pvsc.Start(); err != nil {
+		return nil, errors.Wrap(err, "failed to start private validator")
+	}
+
+	return pvsc, nil
+}
+
+// newValidatorSocketClient returns the client of the external signing process
+// of listenAddr. A "grpc://ID@host:port" address is the one of a signer
+// serving the Signer gRPC service with the node key of ID, which is dialed
+// and authenticated with nodePrivKey. Otherwise Dgrid listens on the address
+// for a connection from the signer.
+func newValidatorSocketClient(
+	listenAddr string,
+	leagueID string,
+	nodePrivKey crypto.PrivKey,
+	logger log.Logger,
+) (validator.FailoverEndpoint, error) {
 	var listener net.Listener
 
 	protocol, address := cmn.ProtocolAndAddress(listenAddr)
+	if protocol == "grpc" {
+		signerAddr, err := p2p.NewNetAddressString(listenAddr)
+		if err != nil {
+			return nil, err
+		}
+		creds := validator.NewSecretConnCredentials(nodePrivKey, []p2p.ID{signerAddr.ID})
+		pvgc := validator.NewSignerGRPCClient(logger.With("module", "validator"), signerAddr.DialString(), creds)
+		validator.SignerGRPCClientSetLeague(leagueID, nil)(pvgc)
+		return pvgc, nil
+	}
 	ln, err := net.Listen(protocol, address)
 	if err != nil {
 		return nil, err
@@ -944,17 +741,139 @@ func createAndStartValidatorSocketClient(
 		listener = validator.NewTCPListener(ln, ed25519.GenPrivKey())
 	default:
 		return nil, fmt.Errorf(
-			"Wrong listen address: expected either 'tcp' or 'unix' protocols, got %s",
+			"Wrong listen address: expected either 'tcp', 'unix' or 'grpc' protocols, got %s",
 			protocol,
 		)
 	}
 
 	pvsc := validator.NewSignerValidatorEndpoint(logger.With("module", "validator"), listener)
-	if err := pvsc.Start(); err != nil {
-		return nil, errors.Wrap(err, "failed to start private validator")
+	validator.SignerValidatorEndpointSetLeague(leagueID, nil)(pvsc)
+	return pvsc, nil
+}
+
+// createAndStartFailoverSigner returns once a signer is connected on one of
+// listenAddrs. The signers must share their sign state, see
+// validator.SignStateStore, and the messages are checked against the sign
+// state of stateFile before any of them signs.
+func createAndStartFailoverSigner(
+	listenAddrs []string,
+	stateFile string,
+	leagueID string,
+	nodePrivKey crypto.PrivKey,
+	logger log.Logger,
+) (types.Validator, error) {
+	endpoints := make([]validator.FailoverEndpoint, len(listenAddrs))
+	for i, listenAddr := range listenAddrs {
+		endpoint, err := newValidatorSocketClient(listenAddr, leagueID, nodePrivKey, logger.With("signer", i))
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error with signer %s", listenAddr)
+		}
+		endpoints[i] = endpoint
+	}
+	fs := validator.NewFailoverSigner(logger.With("module", "validator"), endpoints)
+	validator.FailoverSignerSetSignStateStore(validator.NewFileSignStateStore(stateFile))(fs)
+	if err := fs.Start(); err != nil {
+		return nil, errors.Wrap(err, "failed to start failover signer")
 	}
+	return fs, nil
+}
 
-	return pvsc, nil
+// createAndStartThresholdSigner starts the co-signers concurrently, and
+// returns once threshold of them are connected if the threshold validator
+// is one of vals, whose key contains the keys of the co-signers. The others
+// are added when they connect. Otherwise it waits for the connection of each
+// co-signer, since their keys make the key of the threshold validator.
+func createAndStartThresholdSigner(
+	listenAddrs []string,
+	threshold int,
+	leagueID string,
+	nodePrivKey crypto.PrivKey,
+	vals *types.ValidatorSet,
+	logger log.Logger,
+) (types.Validator, error) {
+	type result struct {
+		cosigner types.Validator
+		err      error
+	}
+	results := make(chan result, len(listenAddrs))
+	for i, listenAddr := range listenAddrs {
+		go func(i int, listenAddr string) {
+			cosigner, err := createAndStartValidatorSocketClient(listenAddr, leagueID, nodePrivKey,
+				logger.With("cosigner", i))
+			results <- result{cosigner, errors.Wrapf(err, "Error with co-signer %s", listenAddr)}
+		}(i, listenAddr)
+	}
+
+	var cosigners []types.Validator
+	for range listenAddrs {
+		res := <-results
+		if res.err != nil {
+			return nil, res.err
+		}
+		cosigners = append(cosigners, res.cosigner)
+		if len(cosigners) < threshold || len(cosigners) == len(listenAddrs) {
+			continue
+		}
+		pubKey, ok := thresholdValidatorKey(vals, threshold, len(listenAddrs), cosigners)
+		if !ok {
+			continue
+		}
+		ts, err := validator.NewThresholdSignerWithKey(pubKey, cosigners)
+		if err != nil {
+			return nil, err
+		}
+		go func(missing int) {
+			for i := 0; i < missing; i++ {
+				res := <-results
+				if res.err == nil {
+					res.err = ts.AddCosigner(res.cosigner)
+				}
+				if res.err != nil {
+					logger.Error("Co-signer not added to the threshold validator", "err", res.err)
+				}
+			}
+		}(len(listenAddrs) - len(cosigners))
+		return ts, nil
+	}
+	return validator.NewThresholdSigner(threshold, cosigners)
+}
+
+// thresholdValidatorKey returns the key of the validator of vals which is a
+// threshold of n key, containing the keys of cosigners.
+func thresholdValidatorKey(
+	vals *types.ValidatorSet,
+	threshold, n int,
+	cosigners []types.Validator,
+) (multisig.PubKeyMultisigThreshold, bool) {
+	if vals == nil {
+		return multisig.PubKeyMultisigThreshold{}, false
+	}
+	for _, val := range vals.Validators {
+		pubKey, ok := val.PubKey.(multisig.PubKeyMultisigThreshold)
+		if !ok || int(pubKey.K) != threshold || len(pubKey.PubKeys) != n {
+			continue
+		}
+		if containsKeys(pubKey.PubKeys, cosigners) {
+			return pubKey, true
+		}
+	}
+	return multisig.PubKeyMultisigThreshold{}, false
+}
+
+func containsKeys(pubKeys []crypto.PubKey, cosigners []types.Validator) bool {
+	for _, cosigner := range cosigners {
+		found := false
+		for _, pubKey := range pubKeys {
+			if pubKey.Equals(cosigner.GetPubKey()) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
 }
 
 // splitAndTrimEmpty slices s into all subslices separated by sep and returns a
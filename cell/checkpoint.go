@@ -0,0 +1,54 @@
+package cell
+
+import (
+	"github.com/teragrid/dgrid/core/checkpoint"
+	"github.com/teragrid/dgrid/core/types"
+	sm "github.com/teragrid/dgrid/state"
+)
+
+// newCheckpointManager returns the manager anchoring the history of the
+// leagues of the Cell into the Base league, whose blocks it reads. The
+// checkpoints of a league are verified against the genesis validators
+// registered in the Base league. The storage of the Base league rejects the
+// checkpoint transactions which would have no effect, and the registrations
+// conflicting with the genesis of the leagues the Cell runs.
+func (n *Cell) newCheckpointManager(base *cellLeague, dbProvider DBProvider) (*checkpoint.Manager, error) {
+	checkpointDB, err := dbProvider(&DBContext{"checkpoints", base.config})
+	if err != nil {
+		return nil, err
+	}
+	m := checkpoint.NewManager(base.eventBus, base.blockStore, checkpoint.NewStore(checkpointDB))
+	m.SetLogger(n.Logger.With("module", "checkpoint"))
+	m.SetTxBroadcaster(func(tx types.Tx) error {
+		return base.storageReactor.Storage.CheckTx(tx, nil)
+	})
+	base.storageReactor.Storage.AddTxFilter(m.CheckTx)
+	return m, nil
+}
+
+// startCheckpoints starts the manager, and registers the leagues of the
+// Cell other than the Base league with their genesis validators and submits
+// their checkpoints, from the latest anchored one.
+func (n *Cell) startCheckpoints() error {
+	if err := n.checkpoints.Start(); err != nil {
+		return err
+	}
+	for _, league := range n.leagues {
+		leagueID := league.genesisDoc.LeagueID
+		if leagueID == n.baseLeagueID {
+			continue
+		}
+		genesisState, err := sm.MakeGenesisState(league.genesisDoc)
+		if err != nil {
+			return err
+		}
+		stateDB := league.stateDB
+		if err := n.checkpoints.TrackLeague(leagueID, league.eventBus, league.blockStore, genesisState.Validators,
+			func(height int64) (*types.ValidatorSet, error) {
+				return sm.LoadValidators(stateDB, height)
+			}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
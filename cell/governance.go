@@ -0,0 +1,77 @@
+package cell
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/teragrid/dgrid/core/governance"
+	"github.com/teragrid/dgrid/core/types"
+	sm "github.com/teragrid/dgrid/state"
+)
+
+// ErrNoGovernance is returned when the Cell doesn't run the Base league
+// governing the configuration of the leagues.
+var ErrNoGovernance = errors.New("Cell doesn't run the Base league")
+
+// WithBaseLeague sets the Base league governing the configuration of the
+// leagues of the Cell. The governance is enabled if the Cell takes part in
+// the Base league.
+func WithBaseLeague(leagueID string) CellOption {
+	return func(n *Cell) { n.baseLeagueID = leagueID }
+}
+
+// league returns the league of the Cell with the given ID, or nil.
+func (n *Cell) league(leagueID string) *cellLeague {
+	for _, league := range n.leagues {
+		if league.genesisDoc.LeagueID == leagueID {
+			return league
+		}
+	}
+	return nil
+}
+
+// newGovernanceManager returns the manager governing the configuration of
+// the leagues of the Cell through the Base league, whose blocks it reads.
+// The consensus manager holds the leagues until the proposals are decided,
+// and schedules the approved updates.
+func (n *Cell) newGovernanceManager(base *cellLeague, dbProvider DBProvider) (*governance.Manager, error) {
+	governanceDB, err := dbProvider(&DBContext{"governance", base.config})
+	if err != nil {
+		return nil, err
+	}
+	m := governance.NewManager(
+		base.genesisDoc.LeagueID,
+		base.eventBus,
+		base.blockStore,
+		governance.NewProposalStore(governanceDB),
+		func(height int64) (*types.ValidatorSet, error) {
+			return sm.LoadValidators(base.stateDB, height)
+		},
+		n.consensusManager,
+	)
+	m.SetLogger(n.Logger.With("module", "governance"))
+	if base.validator != nil {
+		m.SetValidator(base.validator)
+	}
+	m.SetTxBroadcaster(func(tx types.Tx) error {
+		return base.storageReactor.Storage.CheckTx(tx, nil)
+	})
+	return m, nil
+}
+
+// startGovernance governs the configuration of the leagues of the Cell
+// other than the Base league, from their last height, and starts the
+// manager. The consensus manager must run the leagues already.
+func (n *Cell) startGovernance() error {
+	for _, league := range n.leagues {
+		leagueID := league.genesisDoc.LeagueID
+		if leagueID == n.baseLeagueID {
+			continue
+		}
+		state := sm.LoadState(league.stateDB)
+		if _, err := n.governance.TrackLeague(leagueID, league.config.Consensus,
+			state.LastBlockHeight, league.eventBus); err != nil {
+			return err
+		}
+	}
+	return n.governance.Start()
+}
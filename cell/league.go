@@ -0,0 +1,576 @@
+package cell
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+
+	bc "github.com/teragrid/dgrid/core/blockchain"
+	"github.com/teragrid/dgrid/core/blockchain/p2p"
+	"github.com/teragrid/dgrid/core/blockchain/p2p/pex"
+	cfg "github.com/teragrid/dgrid/core/config"
+	cs "github.com/teragrid/dgrid/core/consensus"
+	csm "github.com/teragrid/dgrid/core/consensus/manager"
+	"github.com/teragrid/dgrid/core/consensus/protocols"
+	"github.com/teragrid/dgrid/core/types"
+	"github.com/teragrid/dgrid/evidence"
+	cmn "github.com/teragrid/dgrid/pkg/common"
+	dbm "github.com/teragrid/dgrid/pkg/db"
+	"github.com/teragrid/dgrid/pkg/log"
+	"github.com/teragrid/dgrid/proxy"
+	sm "github.com/teragrid/dgrid/state"
+	"github.com/teragrid/dgrid/state/txindex"
+	"github.com/teragrid/dgrid/state/txindex/kv"
+	"github.com/teragrid/dgrid/state/txindex/null"
+	storage "github.com/teragrid/dgrid/storage"
+	"github.com/teragrid/dgrid/third_party/amino"
+	"github.com/teragrid/dgrid/version"
+)
+
+// cellLeague holds the services a Cell runs for one of its leagues. Each
+// league has its own config, stores, application connection and p2p
+// network, while the engines of all the leagues are run by the consensus
+// manager of the Cell.
+type cellLeague struct {
+	// config
+	config     *cfg.Config
+	genesisDoc *types.GenesisDoc // initial validator set
+	validator  types.Validator   // local cell's validator key
+	logger     log.Logger
+
+	// network
+	transport   *p2p.MultiplexTransport
+	sw          *p2p.Switch  // p2p connections
+	addrBook    pex.AddrBook // known peers
+	cellInfo    p2p.CellInfo
+	isListening bool
+
+	// services
+	eventBus         *types.EventBus // pub/sub for services
+	stateDB          dbm.DB
+	blockStore       *bc.BlockStore              // store the blockchain to disk
+	bcReactor        *bc.BlockchainReactor       // for fast-syncing
+	storageReactor   *storage.StorageReactor     // for gossipping transactions
+	consensusReactor *protocols.ConsensusReactor // for participating in the consensus
+	evidencePool     *evidence.EvidencePool      // tracking evidence
+	proxyApp         proxy.AppConns              // connection to the application
+	txIndexer        txindex.TxIndexer
+	indexerService   *txindex.IndexerService
+}
+
+// newCellLeague sets up the services of a league, and adds the league to
+// consensusManager. The engine of the league is started by the manager, or
+// by the consensus reactor once the league is done fast syncing.
+func newCellLeague(config *cfg.Config,
+	validator types.Validator,
+	cellKey *p2p.CellKey,
+	clientCreator proxy.ClientCreator,
+	genesisDocProvider GenesisDocProvider,
+	dbProvider DBProvider,
+	metricsProvider MetricsProvider,
+	consensusManager *csm.Manager,
+	logger log.Logger) (*cellLeague, error) {
+
+	// Get BlockStore
+	blockStoreDB, err := dbProvider(&DBContext{"blockstore", config})
+	if err != nil {
+		return nil, err
+	}
+	blockStore := bc.NewBlockStore(blockStoreDB)
+
+	// Get State
+	stateDB, err := dbProvider(&DBContext{"state", config})
+	if err != nil {
+		return nil, err
+	}
+
+	// Get genesis doc
+	// TODO: move to state package?
+	genDoc, err := loadGenesisDoc(stateDB)
+	if err != nil {
+		genDoc, err = genesisDocProvider()
+		if err != nil {
+			return nil, err
+		}
+		// save genesis doc to prevent a certain class of user errors (e.g. when it
+		// was changed, accidentally or not). Also good for audit trail.
+		saveGenesisDoc(stateDB, genDoc)
+	}
+	if _, ok := consensusManager.Engine(genDoc.LeagueID); ok {
+		return nil, csm.ErrLeagueExists
+	}
+	logger = logger.With("league", genDoc.LeagueID)
+
+	// the sign state saved by the validator before the sign states were
+	// kept by league is the one of this league
+	if pv, ok := validator.(interface{ AssignLeague(leagueID string) error }); ok {
+		if err := pv.AssignLeague(genDoc.LeagueID); err != nil {
+			return nil, err
+		}
+	}
+
+	state, err := sm.LoadStateFromDBOrGenesisDoc(stateDB, genDoc)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the proxyApp and establish connections to the Asura app (consensus, storage, query).
+	proxyApp := proxy.NewAppConns(clientCreator)
+	proxyApp.SetLogger(logger.With("module", "proxy"))
+	if err := proxyApp.Start(); err != nil {
+		return nil, fmt.Errorf("Error starting proxy app connections: %v", err)
+	}
+
+	// EventBus and IndexerService must be started before the handshake because
+	// we might need to index the txs of the replayed block as this might not have happened
+	// when the cell stopped last time (i.e. the cell stopped after it saved the block
+	// but before it indexed the txs, or, endblocker panicked)
+	eventBus := types.NewEventBus()
+	eventBus.SetLogger(logger.With("module", "events"))
+
+	err = eventBus.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	// Transaction indexing
+	var txIndexer txindex.TxIndexer
+	switch config.TxIndex.Indexer {
+	case "kv":
+		store, err := dbProvider(&DBContext{"tx_index", config})
+		if err != nil {
+			return nil, err
+		}
+		if config.TxIndex.IndexTags != "" {
+			txIndexer = kv.NewTxIndex(store, kv.IndexTags(splitAndTrimEmpty(config.TxIndex.IndexTags, ",", " ")))
+		} else if config.TxIndex.IndexAllTags {
+			txIndexer = kv.NewTxIndex(store, kv.IndexAllTags())
+		} else {
+			txIndexer = kv.NewTxIndex(store)
+		}
+	default:
+		txIndexer = &null.TxIndex{}
+	}
+
+	indexerService := txindex.NewIndexerService(txIndexer, eventBus)
+	indexerService.SetLogger(logger.With("module", "txindex"))
+
+	err = indexerService.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	consensusLogger := logger.With("module", "consensus")
+
+	// A league pipelining the execution may have stopped before the
+	// execution of its last block ended. The block is validated against
+	// its pipelined state, which the handshake doesn't know, so it is
+	// executed before.
+	state, err = protocols.ReplayPipelinedBlock(stateDB, state, blockStore,
+		sm.NewBlockExecutor(stateDB, consensusLogger, proxyApp.Consensus(), sm.MockMempool{}, sm.MockEvidencePool{}))
+	if err != nil {
+		return nil, fmt.Errorf("Error replaying the pipelined block: %v", err)
+	}
+
+	// Create the handshaker, which calls RequestInfo, sets the AppVersion on the state,
+	// and replays any blocks as necessary to sync teragrid with the app.
+	handshaker := cs.NewHandshaker(stateDB, state, blockStore, genDoc)
+	handshaker.SetLogger(consensusLogger)
+	handshaker.SetEventBus(eventBus)
+	if err := handshaker.Handshake(proxyApp); err != nil {
+		return nil, fmt.Errorf("Error during handshake: %v", err)
+	}
+
+	// Reload the state. It will have the Version.Consensus.App set by the
+	// Handshake, and may have other modifications as well (ie. depending on
+	// what happened during block replay).
+	state = sm.LoadState(stateDB)
+
+	// Log the version info.
+	logger.Info("Version info",
+		"software", version.TMCoreSemVer,
+		"block", version.BlockProtocol,
+		"p2p", version.P2PProtocol,
+	)
+
+	// If the state and software differ in block version, at least log it.
+	if state.Version.Consensus.Block != version.BlockProtocol {
+		logger.Info("Software and state have different block protocols",
+			"software", version.BlockProtocol,
+			"state", state.Version.Consensus.Block,
+		)
+	}
+
+	if config.ValidatorListenAddr != "" {
+		// If an address is provided, listen on the socket for a connection from an
+		// external signing process.
+		// FIXME: we should start services inside OnStart
+		validator, err = createAndStartValidatorSocketClient(config.ValidatorListenAddr, genDoc.LeagueID,
+			cellKey.PrivKey, logger)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error with private validator socket client")
+		}
+	} else if len(config.ValidatorFailoverListenAddrs) > 0 {
+		// If several addresses are provided, sign with one of the external
+		// signing processes of the key connecting on them, and fail over.
+		validator, err = createAndStartFailoverSigner(config.ValidatorFailoverListenAddrs,
+			config.ValidatorFailoverStateFile(), genDoc.LeagueID,
+			cellKey.PrivKey, logger)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error with failover private validator")
+		}
+	} else if len(config.ValidatorCosignerListenAddrs) > 0 {
+		// If several addresses are provided, sign with a threshold of the
+		// external signing processes connecting on them.
+		validator, err = createAndStartThresholdSigner(config.ValidatorCosignerListenAddrs,
+			config.ValidatorThreshold, genDoc.LeagueID, cellKey.PrivKey, state.Validators, logger)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error with threshold validator")
+		}
+	}
+
+	// Decide whether to fast-sync or not
+	// We don't fast-sync when the only validator is us.
+	fastSync := config.FastSync
+	if state.Validators.Size() == 1 {
+		addr, _ := state.Validators.GetByIndex(0)
+		privValAddr := validator.GetPubKey().Address()
+		if bytes.Equal(privValAddr, addr) {
+			fastSync = false
+		}
+	}
+	// Fast sync executes the blocks as if the league didn't pipeline the
+	// execution.
+	if fastSync && state.ConsensusParams.Execution.Pipelined {
+		consensusLogger.Info("Not fast syncing, the league pipelines the execution")
+		fastSync = false
+	}
+
+	pubKey := validator.GetPubKey()
+	addr := pubKey.Address()
+	// Log whether this cell is a validator or an observer
+	if state.Validators.HasAddress(addr) {
+		consensusLogger.Info("This cell is a validator", "addr", addr, "pubKey", pubKey)
+	} else {
+		consensusLogger.Info("This cell is not a validator", "addr", addr, "pubKey", pubKey)
+	}
+
+	csMetrics, p2pMetrics, memplMetrics, smMetrics := metricsProvider(genDoc.LeagueID)
+
+	// Make StorageReactor
+	storage := storage.NewStorage(
+		config.Storage,
+		proxyApp.Storage(),
+		state.LastBlockHeight,
+		storage.WithMetrics(memplMetrics),
+		storage.WithPreCheck(sm.TxPreCheck(state)),
+		storage.WithPostCheck(sm.TxPostCheck(state)),
+		storage.WithEventBus(eventBus),
+	)
+	storageLogger := logger.With("module", "storage")
+	storage.SetLogger(storageLogger)
+	if config.Storage.WalEnabled() {
+		storage.InitWAL() // no need to have the storage wal during tests
+		if err := storage.ReplayWAL(); err != nil {
+			return nil, err
+		}
+	}
+	storageReactor := storage.NewStorageReactor(config.Storage, storage)
+	storageReactor.SetLogger(storageLogger)
+
+	if config.Consensus.WaitForTxs() {
+		storage.EnableTxsAvailable()
+	}
+
+	// Make Evidence Reactor
+	evidenceDB, err := dbProvider(&DBContext{"evidence", config})
+	if err != nil {
+		return nil, err
+	}
+	evidenceLogger := logger.With("module", "evidence")
+	evidencePool := evidence.NewEvidencePool(stateDB, evidenceDB)
+	evidencePool.SetLogger(evidenceLogger)
+	evidenceReactor := evidence.NewEvidenceReactor(evidencePool)
+	evidenceReactor.SetLogger(evidenceLogger)
+
+	blockExecLogger := logger.With("module", "state")
+	// make block executor for consensus and blockchain reactors to execute blocks
+	blockExec := sm.NewBlockExecutor(
+		stateDB,
+		blockExecLogger,
+		proxyApp.Consensus(),
+		storage,
+		evidencePool,
+		sm.BlockExecutorWithMetrics(smMetrics),
+	)
+
+	// Make BlockchainReactor
+	bcReactor := bc.NewBlockchainReactor(state.Copy(), blockExec, blockStore, fastSync)
+	bcReactor.SetLogger(logger.With("module", "blockchain"))
+
+	// Make ConsensusReactor for the engine of the configured protocol.
+	// The manager replaces the engine when the league migrates to another
+	// protocol, and the reactor switches to the new one.
+	// The consensus_protocol of the config selects the engine, the manager
+	// converts the consensus config if it belongs to another protocol.
+	protocol, err := csm.ProtocolOf(config.Consensus)
+	if config.ConsensusProtocol != "" {
+		protocol, err = cfg.ParseConsensusProtocol(config.ConsensusProtocol)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// a byzantine test validator signs its conflicting messages with the
+	// key of the local validator. The signer is only built with the
+	// byzantine tag; without it, the behaviors signing are refused by the
+	// consensus manager.
+	var conflictingSigner types.Validator
+	if bftConfig, ok := config.Consensus.(*cfg.BFTConsensusConfig); ok && len(bftConfig.ByzantineBehaviors) > 0 {
+		consensusLogger.Error("This validator is byzantine, only run it on a testnet", "behaviors", bftConfig.ByzantineBehaviors)
+		if signer, ok := validator.(interface{ ConflictingSigner() types.Validator }); ok {
+			conflictingSigner = signer.ConflictingSigner()
+		}
+	}
+	consensusEngine, err := consensusManager.AddLeague(csm.League{
+		ID:           genDoc.LeagueID,
+		Protocol:     protocol,
+		Config:       config.Consensus,
+		State:        state.Copy(),
+		BlockExec:    blockExec,
+		BlockStore:   blockStore,
+		TxNotifier:   storage,
+		EvidencePool: evidencePool,
+		EventBus:     eventBus,
+		Validator:    validator,
+		QuorumSets:   genDoc.QuorumSets(),
+		FastSync:     fastSync,
+		Metrics:      csMetrics,
+		StateDB:      stateDB,
+
+		ConflictingSigner: conflictingSigner,
+	})
+	if err != nil {
+		return nil, err
+	}
+	leagueID := genDoc.LeagueID
+	consensusReactor := protocols.NewConsensusReactor(consensusEngine, fastSync,
+		protocols.ReactorEngineStarter(func() error {
+			return consensusManager.StartLeague(leagueID)
+		}))
+	consensusReactor.SetLogger(consensusLogger)
+
+	// services which will be publishing and/or subscribing for messages (events)
+	// consensusReactor will set it on consensusEngine and blockExecutor
+	consensusReactor.SetEventBus(eventBus)
+
+	p2pLogger := logger.With("module", "p2p")
+	cellInfo, err := makeCellInfo(
+		config,
+		cellKey.ID(),
+		txIndexer,
+		genDoc.LeagueID,
+		consensusReactor.GetChannels(),
+		p2p.NewProtocolVersion(
+			version.P2PProtocol, // global
+			state.Version.Consensus.Block,
+			state.Version.Consensus.App,
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Setup Transport.
+	var (
+		mConnConfig = p2p.MConnConfig(config.P2P)
+		transport   = p2p.NewMultiplexTransport(cellInfo, *cellKey, mConnConfig)
+		connFilters = []p2p.ConnFilterFunc{}
+		peerFilters = []p2p.PeerFilterFunc{}
+	)
+
+	if !config.P2P.AllowDuplicateIP {
+		connFilters = append(connFilters, p2p.ConnDuplicateIPFilter())
+	}
+
+	// Filter peers by addr or pubkey with an Asura query.
+	// If the query return code is OK, add peer.
+	if config.FilterPeers {
+		connFilters = append(
+			connFilters,
+			// Asura query for address filtering.
+			func(_ p2p.ConnSet, c net.Conn, _ []net.IP) error {
+				res, err := proxyApp.Query().QuerySync(asura.RequestQuery{
+					Path: fmt.Sprintf("/p2p/filter/addr/%s", c.RemoteAddr().String()),
+				})
+				if err != nil {
+					return err
+				}
+				if res.IsErr() {
+					return fmt.Errorf("Error querying asura app: %v", res)
+				}
+
+				return nil
+			},
+		)
+
+		peerFilters = append(
+			peerFilters,
+			// Asura query for ID filtering.
+			func(_ p2p.IPeerSet, p p2p.Peer) error {
+				res, err := proxyApp.Query().QuerySync(asura.RequestQuery{
+					Path: fmt.Sprintf("/p2p/filter/id/%s", p.ID()),
+				})
+				if err != nil {
+					return err
+				}
+				if res.IsErr() {
+					return fmt.Errorf("Error querying asura app: %v", res)
+				}
+
+				return nil
+			},
+		)
+	}
+
+	p2p.MultiplexTransportConnFilters(connFilters...)(transport)
+
+	// Setup Switch.
+	sw := p2p.NewSwitch(
+		config.P2P,
+		transport,
+		p2p.WithMetrics(p2pMetrics),
+		p2p.SwitchPeerFilters(peerFilters...),
+	)
+	sw.SetLogger(p2pLogger)
+	sw.AddReactor("MEMPOOL", storageReactor)
+	sw.AddReactor("BLOCKCHAIN", bcReactor)
+	sw.AddReactor("CONSENSUS", consensusReactor)
+	sw.AddReactor("EVIDENCE", evidenceReactor)
+	sw.SetCellInfo(cellInfo)
+	sw.SetCellKey(cellKey)
+
+	p2pLogger.Info("P2P Cell ID", "ID", cellKey.ID(), "file", config.CellKeyFile())
+
+	// Optionally, start the pex reactor
+	//
+	// TODO:
+	//
+	// We need to set Seeds and PersistentPeers on the switch,
+	// since it needs to be able to use these (and their DNS names)
+	// even if the PEX is off. We can include the DNS name in the NetAddress,
+	// but it would still be nice to have a clear list of the current "PersistentPeers"
+	// somewhere that we can return with net_info.
+	//
+	// If PEX is on, it should handle dialing the seeds. Otherwise the switch does it.
+	// Note we currently use the addrBook regardless at least for AddOurAddress
+	addrBook := pex.NewAddrBook(config.P2P.AddrBookFile(), config.P2P.AddrBookStrict)
+
+	// Add ourselves to addrbook to prevent dialing ourselves
+	addrBook.AddOurAddress(sw.NetAddress())
+
+	addrBook.SetLogger(p2pLogger.With("book", config.P2P.AddrBookFile()))
+	if config.P2P.PexReactor {
+		// TODO persistent peers ? so we can have their DNS addrs saved
+		pexReactor := pex.NewPEXReactor(addrBook,
+			&pex.PEXReactorConfig{
+				Seeds:    splitAndTrimEmpty(config.P2P.Seeds, ",", " "),
+				SeedMode: config.P2P.SeedMode,
+				// See consensus/reactor.go: blocksToContributeToBecomeGoodPeer 10000
+				// blocks assuming 10s blocks ~ 28 hours.
+				// TODO (melekes): make it dynamic based on the actual block latencies
+				// from the live network.
+				// https://github.com/teragrid/dgrid/issues/3523
+				SeedDisconnectWaitPeriod: 28 * time.Hour,
+			})
+		pexReactor.SetLogger(logger.With("module", "pex"))
+		sw.AddReactor("PEX", pexReactor)
+	}
+
+	sw.SetAddrBook(addrBook)
+
+	return &cellLeague{
+		config:     config,
+		genesisDoc: genDoc,
+		validator:  validator,
+		logger:     logger,
+
+		transport: transport,
+		sw:        sw,
+		addrBook:  addrBook,
+		cellInfo:  cellInfo,
+
+		stateDB:          stateDB,
+		blockStore:       blockStore,
+		bcReactor:        bcReactor,
+		storageReactor:   storageReactor,
+		consensusReactor: consensusReactor,
+		evidencePool:     evidencePool,
+		proxyApp:         proxyApp,
+		txIndexer:        txIndexer,
+		indexerService:   indexerService,
+		eventBus:         eventBus,
+	}, nil
+}
+
+// start starts the p2p network of the league, whose reactors start the
+// consensus engine unless the league fast syncs.
+func (l *cellLeague) start() error {
+	// Add private IDs to addrbook to block those peers being added
+	l.addrBook.AddPrivateIDs(splitAndTrimEmpty(l.config.P2P.PrivatePeerIDs, ",", " "))
+
+	// Start the transport.
+	addr, err := p2p.NewNetAddressStringWithOptionalID(l.config.P2P.ListenAddress)
+	if err != nil {
+		return err
+	}
+	if err := l.transport.Listen(*addr); err != nil {
+		return err
+	}
+
+	l.isListening = true
+
+	// Start the switch (the P2P server).
+	err = l.sw.Start()
+	if err != nil {
+		return err
+	}
+
+	// Always connect to persistent peers
+	if l.config.P2P.PersistentPeers != "" {
+		err = l.sw.DialPeersAsync(l.addrBook, splitAndTrimEmpty(l.config.P2P.PersistentPeers, ",", " "), true)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stop stops the services of the league.
+func (l *cellLeague) stop() {
+	// first stop the non-reactor services
+	l.eventBus.Stop()
+	l.indexerService.Stop()
+
+	// now stop the reactors
+	// TODO: gracefully disconnect from peers.
+	l.sw.Stop()
+
+	// stop storage WAL
+	if l.config.Storage.WalEnabled() {
+		l.storageReactor.Storage.CloseWAL()
+	}
+
+	if err := l.transport.Close(); err != nil {
+		l.logger.Error("Error closing transport", "err", err)
+	}
+
+	l.isListening = false
+
+	if pvsc, ok := l.validator.(cmn.Service); ok {
+		pvsc.Stop()
+	}
+}
@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/teragrid/dgrid/core/consensus/quorum"
+	"github.com/teragrid/dgrid/core/types"
+)
+
+var quorumGenesisFile string
+
+func init() {
+	CheckQuorumCmd.Flags().StringVar(&quorumGenesisFile, "genesis", "",
+		"Genesis file declaring the quorum sets (default: the genesis file of this node)")
+}
+
+// CheckQuorumCmd checks offline that all the quorums of an FBA league
+// intersect, or prints two disjoint quorums.
+var CheckQuorumCmd = &cobra.Command{
+	Use:   "check_quorum",
+	Short: "Check the quorums declared in the genesis file intersect",
+	RunE:  checkQuorum,
+}
+
+func checkQuorum(cmd *cobra.Command, args []string) error {
+	genFile := quorumGenesisFile
+	if genFile == "" {
+		genFile = config.GenesisFile()
+	}
+	genDoc, err := types.GenesisDocFromFile(genFile)
+	if err != nil {
+		return err
+	}
+
+	res, err := quorum.CheckIntersection(genDoc.QuorumSets())
+	if err != nil {
+		return err
+	}
+	if !res.Intersecting {
+		fmt.Println("Quorum A:")
+		for _, addr := range res.QuorumA {
+			fmt.Println(" ", addr)
+		}
+		fmt.Println("Quorum B:")
+		for _, addr := range res.QuorumB {
+			fmt.Println(" ", addr)
+		}
+		return errors.New("Quorum intersection does not hold, the league can fork")
+	}
+	fmt.Println(res)
+	return nil
+}
@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/spf13/cobra"
+
+	"github.com/teragrid/dgrid/core/blockchain/p2p"
+	cfg "github.com/teragrid/dgrid/core/config"
+	"github.com/teragrid/dgrid/core/consensus/validator"
+	cmn "github.com/teragrid/dgrid/pkg/common"
+	"github.com/teragrid/dgrid/pkg/crypto/keyfile"
+)
+
+var encryptKeysPassphrase string
+
+func init() {
+	EncryptKeysCmd.Flags().StringVar(&encryptKeysPassphrase, "key_passphrase", "",
+		"Source of the passphrase (prompt | env:NAME | fd:N) (default: key_passphrase of the config, or prompt)")
+}
+
+// EncryptKeysCmd encrypts the plaintext validator and node key files of
+// this node with a passphrase, see keyfile.
+var EncryptKeysCmd = &cobra.Command{
+	Use:     "encrypt_keys",
+	Aliases: []string{"encrypt-keys"},
+	Short:   "Encrypt the validator and node key files with a passphrase",
+	RunE:    encryptKeys,
+}
+
+func encryptKeys(cmd *cobra.Command, args []string) error {
+	passphrase, err := newKeysPassphrase()
+	if err != nil {
+		return err
+	}
+
+	validatorKeyFile := config.ValidatorKeyFile()
+	if encrypt, err := needsEncryption(validatorKeyFile); err != nil {
+		return err
+	} else if encrypt {
+		validator.LoadFilePVEmptyState(validatorKeyFile, "").EncryptKey(passphrase)
+		logger.Info("Encrypted validator key", "path", validatorKeyFile)
+	}
+
+	nodeKeyFile := config.CellKeyFile()
+	if encrypt, err := needsEncryption(nodeKeyFile); err != nil {
+		return err
+	} else if encrypt {
+		nodeKey, err := p2p.LoadNodeKey(nodeKeyFile)
+		if err != nil {
+			return err
+		}
+		if err := nodeKey.SaveAs(nodeKeyFile, passphrase); err != nil {
+			return err
+		}
+		logger.Info("Encrypted node key", "path", nodeKeyFile)
+	}
+	return nil
+}
+
+// needsEncryption returns true if the key file exists and isn't encrypted.
+func needsEncryption(filePath string) (bool, error) {
+	if !cmn.FileExists(filePath) {
+		logger.Info("No key file", "path", filePath)
+		return false, nil
+	}
+	data, err := keyfile.ReadFile(filePath, nil)
+	if err == keyfile.ErrEncrypted {
+		logger.Info("Key file is already encrypted", "path", filePath)
+		return false, nil
+	}
+	return err == nil && len(data) > 0, err
+}
+
+// keysPassphrase returns the passphrase of the encrypted key files, from the
+// key_passphrase of the config, or nil if there is none. Every key file is
+// loaded with it, so the commands work after encrypt_keys, and the keys they
+// generate are encrypted.
+func keysPassphrase(config *cfg.Config) (keyfile.Passphrase, error) {
+	if config.KeyPassphrase == "" {
+		return nil, nil
+	}
+	return keyfile.NewPassphrase(config.KeyPassphrase)
+}
+
+// newKeysPassphrase returns the passphrase to encrypt the key files with.
+// A prompted passphrase is asked twice.
+func newKeysPassphrase() (keyfile.Passphrase, error) {
+	source := encryptKeysPassphrase
+	if source == "" {
+		source = config.KeyPassphrase
+	}
+	if source != "" && source != keyfile.SourcePrompt {
+		return keyfile.NewPassphrase(source)
+	}
+	pass, err := keyfile.PromptPassphrase("Enter the passphrase to encrypt the key files with: ")
+	if err != nil {
+		return nil, err
+	}
+	confirm, err := keyfile.PromptPassphrase("Repeat the passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(pass, confirm) {
+		return nil, errors.New("The passphrases don't match")
+	}
+	return keyfile.StaticPassphrase(pass), nil
+}
@@ -18,12 +18,16 @@ var GenNodeKeyCmd = &cobra.Command{
 }
 
 func genNodeKey(cmd *cobra.Command, args []string) error {
-	nodeKeyFile := config.NodeKeyFile()
+	nodeKeyFile := config.CellKeyFile()
 	if cmn.FileExists(nodeKeyFile) {
 		return fmt.Errorf("node key at %s already exists", nodeKeyFile)
 	}
 
-	nodeKey, err := p2p.LoadOrGenNodeKey(nodeKeyFile)
+	passphrase, err := keysPassphrase(config)
+	if err != nil {
+		return err
+	}
+	nodeKey, err := p2p.LoadOrGenNodeKeyWithPassphrase(nodeKeyFile, passphrase)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/teragrid/dgrid/core/consensus/validator"
+	"github.com/teragrid/dgrid/pkg/crypto"
+)
+
+var validatorThreshold int
+
+func init() {
+	GenThresholdValidatorCmd.Flags().IntVar(&validatorThreshold, "threshold", 0,
+		"Number of co-signers signing each vote (default: all of them)")
+}
+
+// GenThresholdValidatorCmd prints the key of a threshold validator, made of
+// the keys of its co-signers, as printed by show_validator.
+var GenThresholdValidatorCmd = &cobra.Command{
+	Use:   "gen_threshold_validator [cosigner-pubkey-json...]",
+	Short: "Generate the key of a threshold validator from the keys of its co-signers",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  genThresholdValidator,
+}
+
+func genThresholdValidator(cmd *cobra.Command, args []string) error {
+	pubKeys := make([]crypto.PubKey, len(args))
+	for i, arg := range args {
+		if err := cdc.UnmarshalJSON([]byte(arg), &pubKeys[i]); err != nil {
+			return fmt.Errorf("Invalid co-signer key %v: %v", arg, err)
+		}
+	}
+	threshold := validatorThreshold
+	if threshold == 0 {
+		threshold = len(pubKeys)
+	}
+	pubKey, err := validator.ThresholdPubKey(threshold, pubKeys)
+	if err != nil {
+		return err
+	}
+	jsbz, err := cdc.MarshalJSON(pubKey)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(jsbz))
+	return nil
+}
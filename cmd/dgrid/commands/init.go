@@ -22,23 +22,26 @@ func initFiles(cmd *cobra.Command, args []string) error {
 }
 
 func initFilesWithConfig(config *cfg.Config) error {
+	passphrase, err := keysPassphrase(config)
+	if err != nil {
+		return err
+	}
+
 	// private validator
-	validatorFile := config.ValidatorFile()
-	var fVal *validator.FilePV
-	if cmn.FileExists(validatorFile) {
-		fVal = validator.LoadFilePV(validatorFile)
+	validatorFile := config.ValidatorKeyFile()
+	found := cmn.FileExists(validatorFile)
+	fVal := validator.LoadOrGenFilePVWithPassphrase(validatorFile, config.ValidatorStateFile(), passphrase)
+	if found {
 		logger.Info("Found private validator", "path", validatorFile)
 	} else {
-		fVal = validator.GenFilePV(validatorFile)
-		fVal.Save()
 		logger.Info("Generated private validator", "path", validatorFile)
 	}
 
-	nodeKeyFile := config.NodeKeyFile()
+	nodeKeyFile := config.CellKeyFile()
 	if cmn.FileExists(nodeKeyFile) {
 		logger.Info("Found node key", "path", nodeKeyFile)
 	} else {
-		if _, err := p2p.LoadOrGenNodeKey(nodeKeyFile); err != nil {
+		if _, err := p2p.LoadOrGenNodeKeyWithPassphrase(nodeKeyFile, passphrase); err != nil {
 			return err
 		}
 		logger.Info("Generated node key", "path", nodeKeyFile)
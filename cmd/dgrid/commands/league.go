@@ -0,0 +1,196 @@
+package commands
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/spf13/cobra"
+
+	cfg "github.com/teragrid/dgrid/core/config"
+	"github.com/teragrid/dgrid/core/consensus/validator"
+	"github.com/teragrid/dgrid/core/types"
+	cmn "github.com/teragrid/dgrid/pkg/common"
+)
+
+var (
+	leagueType     string
+	leagueProtocol string
+	leagueGenesis  string
+	leaguePurge    bool
+)
+
+func init() {
+	LeagueCreateCmd.Flags().StringVar(&leagueType, "type", "regular",
+		"League type: base | regular")
+	LeagueCreateCmd.Flags().StringVar(&leagueProtocol, "protocol", "",
+		"Consensus protocol: bft | fba (default: the default protocol of the league type)")
+	LeagueCreateCmd.Flags().StringVar(&leagueGenesis, "genesis", "",
+		"Genesis file to copy (default: a new genesis file with this node as the only validator)")
+	LeagueRemoveCmd.Flags().BoolVar(&leaguePurge, "purge", false,
+		"Delete the config and data directories of the league")
+
+	LeagueCmd.AddCommand(
+		LeagueCreateCmd,
+		LeagueListCmd,
+		LeagueShowCmd,
+		LeagueRemoveCmd,
+	)
+}
+
+// LeagueCmd manages the registry of the leagues run by this node.
+var LeagueCmd = &cobra.Command{
+	Use:   "league",
+	Short: "Manage the leagues run by this node",
+}
+
+// LeagueCreateCmd lays out the directories of a new league and registers it.
+var LeagueCreateCmd = &cobra.Command{
+	Use:   "create [league-id]",
+	Short: "Create the config and data directories of a league and register it",
+	Args:  cobra.ExactArgs(1),
+	RunE:  createLeague,
+}
+
+// LeagueListCmd lists the registered leagues.
+var LeagueListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the leagues run by this node",
+	Args:  cobra.NoArgs,
+	RunE:  listLeagues,
+}
+
+// LeagueShowCmd prints the registry entry of a league.
+var LeagueShowCmd = &cobra.Command{
+	Use:   "show [league-id]",
+	Short: "Show a league run by this node",
+	Args:  cobra.ExactArgs(1),
+	RunE:  showLeague,
+}
+
+// LeagueRemoveCmd removes a league from the registry.
+var LeagueRemoveCmd = &cobra.Command{
+	Use:   "remove [league-id]",
+	Short: "Remove a league from this node",
+	Args:  cobra.ExactArgs(1),
+	RunE:  removeLeague,
+}
+
+func createLeague(cmd *cobra.Command, args []string) error {
+	lType, err := cfg.ParseLeagueType(leagueType)
+	if err != nil {
+		return err
+	}
+	protocol := cfg.DefaultLeagueProtocol(lType)
+	if leagueProtocol != "" {
+		if protocol, err = cfg.ParseConsensusProtocol(leagueProtocol); err != nil {
+			return err
+		}
+	}
+
+	reg, err := cfg.LoadLeagueRegistry(config.RootDir)
+	if err != nil {
+		return err
+	}
+	rec, err := reg.CreateLeague(args[0], lType, protocol)
+	if err != nil {
+		return err
+	}
+	logger.Info("Created league", "id", rec.ID, "type", rec.Type, "protocol", rec.Protocol, "home", rec.HomeDir)
+
+	if err := writeLeagueGenesis(rec); err != nil {
+		// leave no half-created league in the registry
+		if rmErr := reg.RemoveLeague(rec.ID, true); rmErr != nil {
+			logger.Error("Failed to remove league", "id", rec.ID, "err", rmErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// writeLeagueGenesis copies the genesis file given on the command line,
+// or generates one with the validator of this node.
+func writeLeagueGenesis(rec *cfg.LeagueRecord) error {
+	if cmn.FileExists(rec.GenesisFile) {
+		logger.Info("Found genesis file", "path", rec.GenesisFile)
+		return nil
+	}
+
+	if leagueGenesis != "" {
+		genDoc, err := types.GenesisDocFromFile(leagueGenesis)
+		if err != nil {
+			return err
+		}
+		if genDoc.LeagueID != rec.ID {
+			return fmt.Errorf("Genesis file %s is for league %q, not %q", leagueGenesis, genDoc.LeagueID, rec.ID)
+		}
+		bz, err := ioutil.ReadFile(leagueGenesis)
+		if err != nil {
+			return err
+		}
+		if err := cmn.WriteFileAtomic(rec.GenesisFile, bz, 0644); err != nil {
+			return err
+		}
+		logger.Info("Copied genesis file", "from", leagueGenesis, "path", rec.GenesisFile)
+		return nil
+	}
+
+	passphrase, err := keysPassphrase(config)
+	if err != nil {
+		return err
+	}
+	fVal := validator.LoadOrGenFilePVWithPassphrase(config.ValidatorKeyFile(), config.ValidatorStateFile(), passphrase)
+	genDoc := types.GenesisDoc{
+		LeagueID: rec.ID,
+	}
+	genDoc.Validators = []types.GenesisValidator{{
+		PubKey: fVal.GetPubKey(),
+		Power:  10,
+	}}
+	if err := genDoc.SaveAs(rec.GenesisFile); err != nil {
+		return err
+	}
+	logger.Info("Generated genesis file", "path", rec.GenesisFile)
+	return nil
+}
+
+func listLeagues(cmd *cobra.Command, args []string) error {
+	reg, err := cfg.LoadLeagueRegistry(config.RootDir)
+	if err != nil {
+		return err
+	}
+	for _, rec := range reg.Leagues() {
+		fmt.Printf("%s\t%s\t%s\t%s\n", rec.ID, rec.Type, rec.Protocol, rec.HomeDir)
+	}
+	return nil
+}
+
+func showLeague(cmd *cobra.Command, args []string) error {
+	reg, err := cfg.LoadLeagueRegistry(config.RootDir)
+	if err != nil {
+		return err
+	}
+	rec, ok := reg.GetLeague(args[0])
+	if !ok {
+		return cfg.ErrUnknownLeague
+	}
+	fmt.Println("ID:          ", rec.ID)
+	fmt.Println("Type:        ", rec.Type)
+	fmt.Println("Protocol:    ", rec.Protocol)
+	fmt.Println("Home:        ", rec.HomeDir)
+	fmt.Println("Config file: ", rec.ConfigFile)
+	fmt.Println("Genesis file:", rec.GenesisFile)
+	fmt.Println("Data dir:    ", rec.DataDir())
+	return nil
+}
+
+func removeLeague(cmd *cobra.Command, args []string) error {
+	reg, err := cfg.LoadLeagueRegistry(config.RootDir)
+	if err != nil {
+		return err
+	}
+	if err := reg.RemoveLeague(args[0], leaguePurge); err != nil {
+		return err
+	}
+	logger.Info("Removed league", "id", args[0], "purged", leaguePurge)
+	return nil
+}
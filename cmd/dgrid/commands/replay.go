@@ -3,15 +3,15 @@ package commands
 import (
 	"github.com/spf13/cobra"
 
-	"github.com/teragrid/dgrid/core/consensus"
+	"github.com/teragrid/dgrid/core/consensus/manager"
 )
 
 // ReplayCmd allows replaying of messages from the WAL.
 var ReplayCmd = &cobra.Command{
 	Use:   "replay",
 	Short: "Replay messages from WAL",
-	Run: func(cmd *cobra.Command, args []string) {
-		consensus.RunReplayFile(config.BaseConfig, config.Consensus, false)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return manager.RunReplayFile(config.BaseConfig, config.Consensus, false)
 	},
 }
 
@@ -20,7 +20,7 @@ var ReplayCmd = &cobra.Command{
 var ReplayConsoleCmd = &cobra.Command{
 	Use:   "replay_console",
 	Short: "Replay messages from WAL in a console",
-	Run: func(cmd *cobra.Command, args []string) {
-		consensus.RunReplayFile(config.BaseConfig, config.Consensus, true)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return manager.RunReplayFile(config.BaseConfig, config.Consensus, true)
 	},
 }
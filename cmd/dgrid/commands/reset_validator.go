@@ -5,7 +5,8 @@ import (
 
 	"github.com/spf13/cobra"
 
-	pvm "github.com/teragrid/dgrid/core/types/validator"
+	pvm "github.com/teragrid/dgrid/core/consensus/validator"
+	"github.com/teragrid/dgrid/pkg/crypto/keyfile"
 	"github.com/teragrid/dgrid/pkg/log"
 )
 
@@ -24,10 +25,11 @@ var ResetValidatorCmd = &cobra.Command{
 	Run:   resetValidator,
 }
 
-// ResetAll removes the validator files.
+// ResetAll removes the validator files. The validator key file is decrypted
+// with passphrase if it is encrypted.
 // Exported so other CLI tools can use it.
-func ResetAll(dbDir, validatorFile string, logger log.Logger) {
-	resetFilePV(validatorFile, logger)
+func ResetAll(dbDir, validatorKeyFile, validatorStateFile string, passphrase keyfile.Passphrase, logger log.Logger) {
+	resetFilePV(validatorKeyFile, validatorStateFile, passphrase, logger)
 	if err := os.RemoveAll(dbDir); err != nil {
 		logger.Error("Error removing directory", "err", err)
 		return
@@ -38,24 +40,33 @@ func ResetAll(dbDir, validatorFile string, logger log.Logger) {
 // XXX: this is totally unsafe.
 // it's only suitable for testnets.
 func resetAll(cmd *cobra.Command, args []string) {
-	ResetAll(config.DBDir(), config.ValidatorFile(), logger)
+	passphrase, err := keysPassphrase(config)
+	if err != nil {
+		logger.Error("Error reading the key passphrase", "err", err)
+		return
+	}
+	ResetAll(config.DBDir(), config.ValidatorKeyFile(), config.ValidatorStateFile(), passphrase, logger)
 }
 
 // XXX: this is totally unsafe.
 // it's only suitable for testnets.
 func resetValidator(cmd *cobra.Command, args []string) {
-	resetFilePV(config.ValidatorFile(), logger)
+	passphrase, err := keysPassphrase(config)
+	if err != nil {
+		logger.Error("Error reading the key passphrase", "err", err)
+		return
+	}
+	resetFilePV(config.ValidatorKeyFile(), config.ValidatorStateFile(), passphrase, logger)
 }
 
-func resetFilePV(validatorFile string, logger log.Logger) {
+func resetFilePV(keyFile, stateFile string, passphrase keyfile.Passphrase, logger log.Logger) {
 	// Get Validator
-	if _, err := os.Stat(validatorFile); err == nil {
-		pv := pvm.LoadFilePV(validatorFile)
+	if _, err := os.Stat(keyFile); err == nil {
+		pv := pvm.LoadFilePVWithPassphrase(keyFile, stateFile, passphrase)
 		pv.Reset()
-		logger.Info("Reset Validator", "file", validatorFile)
+		logger.Info("Reset Validator", "file", keyFile)
 	} else {
-		pv := pvm.GenFilePV(validatorFile)
-		pv.Save()
-		logger.Info("Generated Validator", "file", validatorFile)
+		pvm.LoadOrGenFilePVWithPassphrase(keyFile, stateFile, passphrase)
+		logger.Info("Generated Validator", "file", keyFile)
 	}
 }
@@ -19,6 +19,7 @@ func AddCellFlags(cmd *cobra.Command) {
 
 	// priv val flags
 	cmd.Flags().String("validator_laddr", config.ValidatorListenAddr, "Socket address to listen on for connections from external validator process")
+	cmd.Flags().String("key_passphrase", config.KeyPassphrase, "Source of the passphrase of the encrypted key files (prompt | env:NAME | fd:N)")
 
 	// cell flags
 	cmd.Flags().Bool("fast_sync", config.FastSync, "Fast blockchain syncing")
@@ -6,7 +6,6 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/teragrid/dgrid/core/blockchain/p2p"
-
 )
 
 // ShowNodeIDCmd dumps node's ID to the standard output.
@@ -17,8 +16,11 @@ var ShowNodeIDCmd = &cobra.Command{
 }
 
 func showNodeID(cmd *cobra.Command, args []string) error {
-
-	nodeKey, err := p2p.LoadNodeKey(config.NodeKeyFile())
+	passphrase, err := keysPassphrase(config)
+	if err != nil {
+		return err
+	}
+	nodeKey, err := p2p.LoadNodeKeyWithPassphrase(config.CellKeyFile(), passphrase)
 	if err != nil {
 		return err
 	}
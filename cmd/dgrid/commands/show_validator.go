@@ -2,20 +2,26 @@ package commands
 
 import (
 	"fmt"
+
 	"github.com/spf13/cobra"
 
-	validator "github.com/teragrid/dgrid/core/types/validator"
+	"github.com/teragrid/dgrid/core/consensus/validator"
 )
 
 // ShowValidatorCmd adds capabilities for showing the validator info.
 var ShowValidatorCmd = &cobra.Command{
 	Use:   "show_validator",
 	Short: "Show this node's validator info",
-	Run:   showValidator,
+	RunE:  showValidator,
 }
 
-func showValidator(cmd *cobra.Command, args []string) {
-	validator := validator.LoadOrGenFilePV(config.ValidatorFile())
-	pubKeyJSONBytes, _ := cdc.MarshalJSON(validator.GetPubKey())
+func showValidator(cmd *cobra.Command, args []string) error {
+	passphrase, err := keysPassphrase(config)
+	if err != nil {
+		return err
+	}
+	pv := validator.LoadOrGenFilePVWithPassphrase(config.ValidatorKeyFile(), config.ValidatorStateFile(), passphrase)
+	pubKeyJSONBytes, _ := cdc.MarshalJSON(pv.GetPubKey())
 	fmt.Println(string(pubKeyJSONBytes))
+	return nil
 }
@@ -0,0 +1,155 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	cfg "github.com/teragrid/dgrid/core/config"
+	"github.com/teragrid/dgrid/core/consensus/validator"
+	"github.com/teragrid/dgrid/core/types"
+	cmn "github.com/teragrid/dgrid/pkg/common"
+	"github.com/teragrid/dgrid/pkg/crypto/ed25519"
+	"github.com/teragrid/dgrid/pkg/crypto/keyfile"
+)
+
+// signerConnTimeout is the read/write timeout of the connection to the
+// validator.
+const signerConnTimeout = 3 * time.Second
+
+var (
+	signerAddr           string
+	signerKeys           []string
+	signerSharedStateDir string
+)
+
+func init() {
+	SignerCmd.Flags().StringVar(&signerAddr, "addr", "",
+		"Address of the validator to dial (tcp://host:port | unix://path)")
+	SignerCmd.Flags().StringArrayVar(&signerKeys, "key", nil,
+		"Key of a league to sign with, as <league_id>=<key_file>,<state_file> (default: the validator keys of the leagues of this node)")
+	SignerCmd.Flags().StringVar(&signerSharedStateDir, "shared_state_dir", "",
+		"Directory of the sign states shared with the other signers of the keys in high availability, eg. on a shared file system")
+	SignerCmd.Flags().String("key_passphrase", config.KeyPassphrase,
+		"Source of the passphrase of the encrypted key files (prompt | env:NAME | fd:N)")
+}
+
+// SignerCmd runs an external signing process holding the validator keys of
+// one or more leagues. It dials the validator_laddr of a cell, and serves
+// the signature requests of the validators of the leagues.
+var SignerCmd = &cobra.Command{
+	Use:   "signer",
+	Short: "Run an external signer for the validators of one or more leagues",
+	RunE:  runSigner,
+}
+
+func runSigner(cmd *cobra.Command, args []string) error {
+	if signerAddr == "" {
+		return errors.New("The address of the validator is missing, see --addr")
+	}
+	passphrase, err := keysPassphrase(config)
+	if err != nil {
+		return err
+	}
+	keys, err := loadSignerKeys(passphrase)
+	if err != nil {
+		return err
+	}
+
+	var dialer validator.SocketDialer
+	protocol, address := cmn.ProtocolAndAddress(signerAddr)
+	switch protocol {
+	case "unix":
+		dialer = validator.DialUnixFn(address)
+	case "tcp":
+		dialer = validator.DialTCPFn(address, signerConnTimeout, ed25519.GenPrivKey())
+	default:
+		return fmt.Errorf("Unknown protocol %v of the validator address", protocol)
+	}
+
+	se := validator.NewMultiSignerServiceEndpoint(logger.With("module", "signer"), keys, dialer)
+	if err := se.Start(); err != nil {
+		return err
+	}
+	cmn.TrapSignal(logger, func() {
+		se.Stop()
+	})
+	logger.Info("Started signer", "addr", signerAddr)
+
+	// Run forever.
+	select {}
+}
+
+// loadSignerKeys returns the keys given by --key, or the validator keys of
+// the leagues of this node: the main one and the ones of its registry. A
+// key file shared by several leagues is loaded once, so its sign states
+// are kept in the same FilePV. With --shared_state_dir, each key signs
+// against the sign state of its address in the directory, see
+// validator.SignStateStore.
+func loadSignerKeys(passphrase keyfile.Passphrase) (*validator.SignerKeys, error) {
+	keys := validator.NewSignerKeys()
+	pvs := make(map[string]*validator.FilePV)
+	add := func(leagueID, keyFile, stateFile string) error {
+		pv, ok := pvs[keyFile]
+		if !ok {
+			pv = validator.LoadFilePVWithPassphrase(keyFile, stateFile, passphrase)
+			if signerSharedStateDir != "" {
+				stateFile := filepath.Join(signerSharedStateDir, fmt.Sprintf("%X.json", pv.GetAddress()))
+				pv.SetSignStateStore(validator.NewFileSignStateStore(stateFile))
+			}
+			pvs[keyFile] = pv
+		}
+		return keys.Add(leagueID, pv)
+	}
+
+	if len(signerKeys) > 0 {
+		for _, key := range signerKeys {
+			parts := strings.SplitN(key, "=", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("Wrong key %q, expected <league_id>=<key_file>,<state_file>", key)
+			}
+			files := strings.Split(parts[1], ",")
+			if len(files) != 2 {
+				return nil, fmt.Errorf("Wrong key %q, expected <league_id>=<key_file>,<state_file>", key)
+			}
+			if err := add(parts[0], files[0], files[1]); err != nil {
+				return nil, err
+			}
+		}
+		return keys, nil
+	}
+
+	configs := []*cfg.Config{config}
+	registry, err := cfg.LoadLeagueRegistry(config.RootDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range registry.Leagues() {
+		leagueConfig, err := rec.LoadConfig()
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, leagueConfig)
+	}
+	leagues := make(map[string]bool)
+	for _, c := range configs {
+		genDoc, err := types.GenesisDocFromFile(c.GenesisFile())
+		if err != nil {
+			return nil, err
+		}
+		// the main league may be in the registry as well
+		if leagues[genDoc.LeagueID] {
+			continue
+		}
+		leagues[genDoc.LeagueID] = true
+		if err := add(genDoc.LeagueID, c.ValidatorKeyFile(), c.ValidatorStateFile()); err != nil {
+			return nil, err
+		}
+		logger.Info("Signing for league", "league", genDoc.LeagueID, "key", c.ValidatorKeyFile())
+	}
+	return keys, nil
+}
@@ -13,7 +13,7 @@ import (
 	cfg "github.com/teragrid/dgrid/core/config"
 	"github.com/teragrid/dgrid/core/blockchain/p2p"
 	"github.com/teragrid/dgrid/core/types"
-	pvm "github.com/teragrid/dgrid/core/types/validator"
+	pvm "github.com/teragrid/dgrid/core/consensus/validator"
 	cmn "github.com/teragrid/dgrid/pkg/common"
 )
 
@@ -27,6 +27,9 @@ var (
 	hostnamePrefix          string
 	startingIPAddress       string
 	p2pPort                 int
+
+	nByzantineValidators int
+	byzantineBehaviors   []string
 )
 
 const (
@@ -51,6 +54,12 @@ func init() {
 		"Starting IP address (192.168.0.1 results in persistent peers list ID0@192.168.0.1:46656, ID1@192.168.0.2:46656, ...)")
 	TestnetFilesCmd.Flags().IntVar(&p2pPort, "p2p-port", 46656,
 		"P2P Port")
+
+	TestnetFilesCmd.Flags().IntVar(&nByzantineValidators, "byzantine-validators", 0,
+		"Number of validators misbehaving as given by --byzantine-behaviors (the first ones: node0, node1, ...). "+
+			"They must run a dgrid built with the byzantine tag")
+	TestnetFilesCmd.Flags().StringSliceVar(&byzantineBehaviors, "byzantine-behaviors", nil,
+		fmt.Sprintf("Comma-separated misbehaviors of the byzantine validators, among %v", cfg.ByzantineBehaviors))
 }
 
 // TestnetFilesCmd allows initialisation of files for a dgrid testnet.
@@ -61,6 +70,16 @@ var TestnetFilesCmd = &cobra.Command{
 }
 
 func testnetFiles(cmd *cobra.Command, args []string) error {
+	if nByzantineValidators < 0 || nByzantineValidators > nValidators {
+		return fmt.Errorf("Number of byzantine validators must be between 0 and %d", nValidators)
+	}
+	if nByzantineValidators > 0 && len(byzantineBehaviors) == 0 {
+		return fmt.Errorf("Byzantine validators need --byzantine-behaviors")
+	}
+	if err := cfg.ValidateByzantineBehaviors(byzantineBehaviors); err != nil {
+		return err
+	}
+
 	config := cfg.DefaultConfig()
 	genVals := make([]types.GenesisValidator, nValidators)
 
@@ -68,6 +87,7 @@ func testnetFiles(cmd *cobra.Command, args []string) error {
 		nodeDirName := cmn.Fmt("%s%d", nodeDirPrefix, i)
 		nodeDir := filepath.Join(outputDir, nodeDirName)
 		config.SetRoot(nodeDir)
+		setByzantineBehaviors(config, i)
 
 		err := os.MkdirAll(filepath.Join(nodeDir, "config"), nodeDirPerm)
 		if err != nil {
@@ -75,10 +95,17 @@ func testnetFiles(cmd *cobra.Command, args []string) error {
 			return err
 		}
 
-		initFilesWithConfig(config)
+		if err := initFilesWithConfig(config); err != nil {
+			_ = os.RemoveAll(outputDir)
+			return err
+		}
 
-		pvFile := filepath.Join(nodeDir, config.BaseConfig.Validator)
-		pv := pvm.LoadFilePV(pvFile)
+		passphrase, err := keysPassphrase(config)
+		if err != nil {
+			_ = os.RemoveAll(outputDir)
+			return err
+		}
+		pv := pvm.LoadFilePVWithPassphrase(config.ValidatorKeyFile(), config.ValidatorStateFile(), passphrase)
 		genVals[i] = types.GenesisValidator{
 			PubKey: pv.GetPubKey(),
 			Power:  1,
@@ -89,6 +116,7 @@ func testnetFiles(cmd *cobra.Command, args []string) error {
 	for i := 0; i < nNonValidators; i++ {
 		nodeDir := filepath.Join(outputDir, cmn.Fmt("%s%d", nodeDirPrefix, i+nValidators))
 		config.SetRoot(nodeDir)
+		setByzantineBehaviors(config, i+nValidators)
 
 		err := os.MkdirAll(filepath.Join(nodeDir, "config"), nodeDirPerm)
 		if err != nil {
@@ -127,6 +155,20 @@ func testnetFiles(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// setByzantineBehaviors makes the i-th node of the testnet misbehave if it
+// is one of the byzantine validators.
+func setByzantineBehaviors(config *cfg.Config, i int) {
+	bftConfig, ok := config.Consensus.(*cfg.BFTConsensusConfig)
+	if !ok {
+		return
+	}
+	if i < nByzantineValidators {
+		bftConfig.ByzantineBehaviors = byzantineBehaviors
+	} else {
+		bftConfig.ByzantineBehaviors = nil
+	}
+}
+
 func hostnameOrIP(i int) string {
 	if startingIPAddress != "" {
 		ip := net.ParseIP(startingIPAddress)
@@ -150,7 +192,11 @@ func populatePersistentPeersInConfigAndWriteIt(config *cfg.Config) error {
 	for i := 0; i < nValidators+nNonValidators; i++ {
 		nodeDir := filepath.Join(outputDir, cmn.Fmt("%s%d", nodeDirPrefix, i))
 		config.SetRoot(nodeDir)
-		nodeKey, err := p2p.LoadNodeKey(config.NodeKeyFile())
+		passphrase, err := keysPassphrase(config)
+		if err != nil {
+			return err
+		}
+		nodeKey, err := p2p.LoadNodeKeyWithPassphrase(config.CellKeyFile(), passphrase)
 		if err != nil {
 			return err
 		}
@@ -162,6 +208,7 @@ func populatePersistentPeersInConfigAndWriteIt(config *cfg.Config) error {
 		nodeDir := filepath.Join(outputDir, cmn.Fmt("%s%d", nodeDirPrefix, i))
 		config.SetRoot(nodeDir)
 		config.P2P.PersistentPeers = persistentPeersList
+		setByzantineBehaviors(config, i)
 
 		// overwrite default config
 		cfg.WriteConfigFile(filepath.Join(nodeDir, "config", "config.toml"), config)
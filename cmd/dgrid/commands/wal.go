@@ -0,0 +1,167 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/teragrid/dgrid/core/consensus/protocols"
+	"github.com/teragrid/dgrid/core/types"
+	amino "github.com/teragrid/dgrid/third_party/amino"
+)
+
+var walFile string
+
+// walCdc prints the messages of the WAL as JSON.
+var walCdc = amino.NewCodec()
+
+func init() {
+	protocols.RegisterConsensusMessages(walCdc)
+	protocols.RegisterWALMessages(walCdc)
+	types.RegisterBlockAmino(walCdc)
+
+	WALCmd.PersistentFlags().StringVar(&walFile, "wal", "",
+		"WAL file (default: the WAL of the consensus config)")
+
+	WALCmd.AddCommand(
+		WALDumpCmd,
+		WALSearchCmd,
+		WALRepairCmd,
+	)
+}
+
+// WALCmd inspects and repairs the consensus write-ahead log.
+// The node must not be running.
+var WALCmd = &cobra.Command{
+	Use:   "wal",
+	Short: "Inspect and repair the consensus WAL",
+}
+
+// WALDumpCmd prints all the messages of the WAL.
+var WALDumpCmd = &cobra.Command{
+	Use:   "dump",
+	Short: "Print the messages of the WAL as JSON, one per line",
+	Args:  cobra.NoArgs,
+	RunE:  dumpWAL,
+}
+
+// WALSearchCmd prints the messages of a height.
+var WALSearchCmd = &cobra.Command{
+	Use:   "search [height]",
+	Short: "Print the messages of the WAL for a height as JSON, one per line",
+	Args:  cobra.ExactArgs(1),
+	RunE:  searchWAL,
+}
+
+// WALRepairCmd drops a tail torn by a crash.
+var WALRepairCmd = &cobra.Command{
+	Use:   "repair",
+	Short: "Drop the truncated tail of the WAL, keeping a copy of the original file",
+	Args:  cobra.NoArgs,
+	RunE:  repairWAL,
+}
+
+// consensusWALFile returns the WAL file given on the command line, or the
+// one of the consensus config.
+func consensusWALFile() (string, error) {
+	if walFile != "" {
+		return walFile, nil
+	}
+	filer, ok := config.Consensus.(interface{ WalFile() string })
+	if !ok {
+		return "", fmt.Errorf("Consensus config %T has no WAL", config.Consensus)
+	}
+	return filer.WalFile(), nil
+}
+
+func openWAL() (*protocols.BaseWAL, error) {
+	file, err := consensusWALFile()
+	if err != nil {
+		return nil, err
+	}
+	wal, err := protocols.NewWAL(file)
+	if err != nil {
+		return nil, err
+	}
+	wal.SetLogger(logger.With("module", "wal"))
+	return wal, nil
+}
+
+// printWALMessages prints the messages of dec until the EndHeightMessage
+// of endHeight, or the end of the WAL if endHeight is negative.
+func printWALMessages(dec protocols.WALDecoder, endHeight int64) error {
+	for {
+		msg, err := dec.Decode()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		bz, err := walCdc.MarshalJSON(msg)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(bz))
+		if m, ok := msg.Msg.(protocols.EndHeightMessage); ok && m.Height == endHeight {
+			return nil
+		}
+	}
+}
+
+func dumpWAL(cmd *cobra.Command, args []string) error {
+	wal, err := openWAL()
+	if err != nil {
+		return err
+	}
+	defer wal.Group().Close()
+
+	gr, err := wal.Group().NewReader(wal.Group().MinIndex())
+	if err != nil {
+		return err
+	}
+	dec := protocols.NewWALDecoder(gr)
+	defer dec.Close()
+	return printWALMessages(dec, -1)
+}
+
+func searchWAL(cmd *cobra.Command, args []string) error {
+	height, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || height <= 0 {
+		return fmt.Errorf("Invalid height %q", args[0])
+	}
+	wal, err := openWAL()
+	if err != nil {
+		return err
+	}
+	defer wal.Group().Close()
+
+	// the messages of a height follow the end of the previous one
+	dec, found, err := wal.SearchForEndHeight(height - 1)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("WAL has no messages for height %d", height)
+	}
+	defer dec.Close()
+	return printWALMessages(dec, height)
+}
+
+func repairWAL(cmd *cobra.Command, args []string) error {
+	file, err := consensusWALFile()
+	if err != nil {
+		return err
+	}
+	repaired, err := protocols.RepairWAL(file)
+	if err != nil {
+		return err
+	}
+	if repaired {
+		logger.Info("Dropped the truncated tail of the WAL", "wal", file, "backup", file+".CORRUPTED")
+	} else {
+		logger.Info("WAL is intact", "wal", file)
+	}
+	return nil
+}
@@ -30,17 +30,23 @@ func main() {
 	rootCmd := cmd.RootCmd
 	rootCmd.AddCommand(
 		cmd.GenValidatorCmd,
+		cmd.GenThresholdValidatorCmd,
 		cmd.InitFilesCmd,
 		cmd.ProbeUpnpCmd,
 		cmd.LiteCmd,
 		cmd.ReplayCmd,
 		cmd.ReplayConsoleCmd,
+		cmd.WALCmd,
 		cmd.ResetAllCmd,
 		cmd.ResetValidatorCmd,
 		cmd.ShowValidatorCmd,
+		cmd.CheckQuorumCmd,
+		cmd.LeagueCmd,
 		cmd.TestnetFilesCmd,
 		cmd.ShowNodeIDCmd,
 		cmd.GenNodeKeyCmd,
+		cmd.EncryptKeysCmd,
+		cmd.SignerCmd,
 		cmd.VersionCmd)
 
 	// NOTE:
@@ -0,0 +1,122 @@
+package baseleague
+
+import (
+	"context"
+
+	"github.com/teragrid/dgrid/core/types"
+	cmn "github.com/teragrid/dgrid/pkg/common"
+	"github.com/teragrid/dgrid/pkg/log"
+)
+
+// BlockStore loads the blocks of the Base league committed while a
+// Follower was not running.
+type BlockStore interface {
+	Height() int64
+	LoadBlock(height int64) *types.Block
+}
+
+// BlockProcessor processes the block of the Base league following the last
+// one processed.
+type BlockProcessor func(block *types.Block)
+
+// Follower calls a BlockProcessor for every block of the Base league. The
+// blocks committed since the last one processed, eg. while the cell was
+// down, are replayed from the block store first, and so are the blocks
+// missed before a block published on the event bus.
+type Follower struct {
+	cmn.BaseService
+
+	subscriber string
+	eventBus   types.EventBusSubscriber
+	blockStore BlockStore
+	lastHeight func() int64
+	process    BlockProcessor
+}
+
+// NewFollower returns a new Follower subscribing to the blocks published on
+// the event bus of the Base league as subscriber. lastHeight returns the
+// last height of the Base league processed.
+func NewFollower(subscriber string, eventBus types.EventBusSubscriber, blockStore BlockStore,
+	lastHeight func() int64, process BlockProcessor) *Follower {
+	f := &Follower{
+		subscriber: subscriber,
+		eventBus:   eventBus,
+		blockStore: blockStore,
+		lastHeight: lastHeight,
+		process:    process,
+	}
+	f.BaseService = *cmn.NewBaseService(nil, "BaseLeagueFollower", f)
+	return f
+}
+
+// OnStart implements cmn.Service.
+func (f *Follower) OnStart() error {
+	sub, err := f.eventBus.Subscribe(context.Background(), f.subscriber, types.EventQueryNewBlock)
+	if err != nil {
+		return err
+	}
+	go func() {
+		f.replayBlocks(f.blockStore.Height())
+		BlockRoutine(sub, f.Quit(), f.Logger, f.applyBlock)
+	}()
+	return nil
+}
+
+// OnStop implements cmn.Service.
+func (f *Follower) OnStop() {
+	f.eventBus.UnsubscribeAll(context.Background(), f.subscriber)
+}
+
+// applyBlock processes a block of the Base league. The blocks missed before
+// it are processed first.
+func (f *Follower) applyBlock(block *types.Block) {
+	lastHeight := f.lastHeight()
+	if block.Height <= lastHeight {
+		return
+	}
+	if block.Height > lastHeight+1 {
+		if !f.replayBlocks(block.Height - 1) {
+			return
+		}
+	}
+	f.process(block)
+}
+
+// replayBlocks processes the blocks of the block store up to height, which
+// were not processed yet. It returns false if a block is missing.
+func (f *Follower) replayBlocks(height int64) bool {
+	for h := f.lastHeight() + 1; h <= height; h++ {
+		block := f.blockStore.LoadBlock(h)
+		if block == nil {
+			f.Logger.Error("Base league block is missing", "height", h)
+			return false
+		}
+		f.process(block)
+	}
+	return true
+}
+
+// LeagueSubscriber returns the subscriber of a service to the blocks of a
+// league. The Base league may share its event bus with a league, so their
+// subscribers differ.
+func LeagueSubscriber(subscriber, leagueID string) string {
+	return subscriber + "/league/" + leagueID
+}
+
+// BlockRoutine calls apply for every block published on a subscription,
+// until the subscription is cancelled or quit is closed.
+func BlockRoutine(sub types.Subscription, quit <-chan struct{}, logger log.Logger, apply func(block *types.Block)) {
+	for {
+		select {
+		case msg := <-sub.Out():
+			apply(msg.Data().(types.EventDataNewBlock).Block)
+		case <-sub.Cancelled():
+			if sub.Err() != nil {
+				logger.Error("Block subscription was cancelled", "err", sub.Err())
+			}
+			return
+		case <-quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package baseleague
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teragrid/dgrid/core/types"
+)
+
+// testBlockStore holds the blocks of the Base league by height.
+type testBlockStore map[int64]*types.Block
+
+func (store testBlockStore) Height() int64 {
+	return int64(len(store))
+}
+
+func (store testBlockStore) LoadBlock(height int64) *types.Block {
+	return store[height]
+}
+
+func makeBlock(height int64) *types.Block {
+	block := &types.Block{}
+	block.Height = height
+	return block
+}
+
+// testProcessor records the heights of the blocks processed.
+type testProcessor struct {
+	mtx     sync.Mutex
+	heights []int64
+}
+
+func (p *testProcessor) lastHeight() int64 {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if len(p.heights) == 0 {
+		return 0
+	}
+	return p.heights[len(p.heights)-1]
+}
+
+func (p *testProcessor) process(block *types.Block) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.heights = append(p.heights, block.Height)
+}
+
+func (p *testProcessor) waitHeight(t *testing.T, height int64) {
+	for i := 0; i < 50; i++ {
+		if p.lastHeight() >= height {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("Base league height %d was not processed", height)
+}
+
+func TestFollower(t *testing.T) {
+	eventBus := types.NewEventBus()
+	require.NoError(t, eventBus.Start())
+	defer eventBus.Stop()
+
+	blockStore := testBlockStore{1: makeBlock(1), 2: makeBlock(2)}
+	p := new(testProcessor)
+	f := NewFollower("test", eventBus, blockStore, p.lastHeight, p.process)
+
+	// the blocks committed while the follower was not running are replayed
+	require.NoError(t, f.Start())
+	defer f.Stop()
+	p.waitHeight(t, 2)
+
+	// and the blocks missed are loaded before a new one
+	blockStore[3] = makeBlock(3)
+	blockStore[4] = makeBlock(4)
+	require.NoError(t, eventBus.PublishEventNewBlock(types.EventDataNewBlock{Block: blockStore[4]}))
+	p.waitHeight(t, 4)
+
+	// a block processed already is ignored
+	require.NoError(t, eventBus.PublishEventNewBlock(types.EventDataNewBlock{Block: blockStore[3]}))
+	require.NoError(t, eventBus.PublishEventNewBlock(types.EventDataNewBlock{Block: makeBlock(5)}))
+	p.waitHeight(t, 5)
+	assert.Equal(t, []int64{1, 2, 3, 4, 5}, p.heights)
+}
+
+func TestTxSubmitter(t *testing.T) {
+	var s TxSubmitter
+	assert.Equal(t, ErrNoTxBroadcaster, s.SubmitTx(types.Tx("tx")))
+
+	var submitted []types.Tx
+	s.SetTxBroadcaster(func(tx types.Tx) error {
+		submitted = append(submitted, tx)
+		return nil
+	})
+	require.NoError(t, s.SubmitTx(types.Tx("tx")))
+	assert.Equal(t, []types.Tx{types.Tx("tx")}, submitted)
+}
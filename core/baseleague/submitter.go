@@ -0,0 +1,40 @@
+package baseleague
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/teragrid/dgrid/core/types"
+)
+
+// ErrNoTxBroadcaster is returned when transactions can't be submitted
+var ErrNoTxBroadcaster = errors.New("No transaction broadcaster")
+
+// TxBroadcaster submits a transaction to the Base league.
+type TxBroadcaster func(tx types.Tx) error
+
+// TxSubmitter submits transactions to the Base league through a
+// TxBroadcaster, which may be set once the storage of the Base league runs.
+type TxSubmitter struct {
+	mtx         sync.Mutex
+	broadcastTx TxBroadcaster
+}
+
+// SetTxBroadcaster sets how transactions are submitted.
+func (s *TxSubmitter) SetTxBroadcaster(broadcastTx TxBroadcaster) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.broadcastTx = broadcastTx
+}
+
+// SubmitTx submits a transaction to the Base league. It returns
+// ErrNoTxBroadcaster if no TxBroadcaster is set.
+func (s *TxSubmitter) SubmitTx(tx types.Tx) error {
+	s.mtx.Lock()
+	broadcastTx := s.broadcastTx
+	s.mtx.Unlock()
+	if broadcastTx == nil {
+		return ErrNoTxBroadcaster
+	}
+	return broadcastTx(tx)
+}
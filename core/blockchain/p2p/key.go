@@ -4,10 +4,10 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
-	"io/ioutil"
 
 	"github.com/teragrid/dgrid/pkg/crypto"
 	"github.com/teragrid/dgrid/pkg/crypto/ed25519"
+	"github.com/teragrid/dgrid/pkg/crypto/keyfile"
 	cmn "github.com/teragrid/dgrid/pkg/common"
 )
 
@@ -20,7 +20,6 @@ const IDByteLength = crypto.AddressSize
 
 //------------------------------------------------------------------------------
 // Persistent peer ID
-// TODO: encrypt on disk
 
 // NodeKey is the persistent peer key.
 // It contains the nodes private key for authentication.
@@ -47,20 +46,34 @@ func PubKeyToID(pubKey crypto.PubKey) ID {
 // LoadOrGenNodeKey attempts to load the NodeKey from the given filePath.
 // If the file does not exist, it generates and saves a new NodeKey.
 func LoadOrGenNodeKey(filePath string) (*NodeKey, error) {
+	return LoadOrGenNodeKeyWithPassphrase(filePath, nil)
+}
+
+// LoadOrGenNodeKeyWithPassphrase attempts to load the NodeKey from the
+// given filePath, decrypting it with passphrase if it is encrypted. If the
+// file does not exist, it generates a new NodeKey, and saves it encrypted
+// with passphrase, unless it is nil.
+func LoadOrGenNodeKeyWithPassphrase(filePath string, passphrase keyfile.Passphrase) (*NodeKey, error) {
 	if cmn.FileExists(filePath) {
-		nodeKey, err := LoadNodeKey(filePath)
+		nodeKey, err := LoadNodeKeyWithPassphrase(filePath, passphrase)
 		if err != nil {
 			return nil, err
 		}
 		return nodeKey, nil
 	}
-	return genNodeKey(filePath)
+	return genNodeKey(filePath, passphrase)
 }
 
 func LoadNodeKey(filePath string) (*NodeKey, error) {
-	jsonBytes, err := ioutil.ReadFile(filePath)
+	return LoadNodeKeyWithPassphrase(filePath, nil)
+}
+
+// LoadNodeKeyWithPassphrase loads the NodeKey from the given filePath,
+// decrypting it with passphrase if it is encrypted.
+func LoadNodeKeyWithPassphrase(filePath string, passphrase keyfile.Passphrase) (*NodeKey, error) {
+	jsonBytes, err := keyfile.ReadFile(filePath, passphrase)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("Error reading NodeKey from %v: %v", filePath, err)
 	}
 	nodeKey := new(NodeKey)
 	err = cdc.UnmarshalJSON(jsonBytes, nodeKey)
@@ -70,21 +83,26 @@ func LoadNodeKey(filePath string) (*NodeKey, error) {
 	return nodeKey, nil
 }
 
-func genNodeKey(filePath string) (*NodeKey, error) {
+func genNodeKey(filePath string, passphrase keyfile.Passphrase) (*NodeKey, error) {
 	privKey := ed25519.GenPrivKey()
 	nodeKey := &NodeKey{
 		PrivKey: privKey,
 	}
 
-	jsonBytes, err := cdc.MarshalJSON(nodeKey)
-	if err != nil {
+	if err := nodeKey.SaveAs(filePath, passphrase); err != nil {
 		return nil, err
 	}
-	err = ioutil.WriteFile(filePath, jsonBytes, 0600)
+	return nodeKey, nil
+}
+
+// SaveAs saves the NodeKey to filePath, encrypted with passphrase unless it
+// is nil.
+func (nodeKey *NodeKey) SaveAs(filePath string, passphrase keyfile.Passphrase) error {
+	jsonBytes, err := cdc.MarshalJSON(nodeKey)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return nodeKey, nil
+	return keyfile.WriteFile(filePath, jsonBytes, passphrase)
 }
 
 //------------------------------------------------------------------------------
@@ -8,6 +8,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	cmn "github.com/teragrid/dgrid/pkg/common"
+	"github.com/teragrid/dgrid/pkg/crypto/keyfile"
 )
 
 func TestLoadOrGenNodeKey(t *testing.T) {
@@ -22,6 +23,30 @@ func TestLoadOrGenNodeKey(t *testing.T) {
 	assert.Equal(t, nodeKey, nodeKey2)
 }
 
+func TestLoadOrGenNodeKeyWithPassphrase(t *testing.T) {
+	filePath := filepath.Join(os.TempDir(), cmn.RandStr(12)+"_peer_id.json")
+	passphrase := keyfile.StaticPassphrase([]byte("passphrase"))
+
+	nodeKey, err := LoadOrGenNodeKeyWithPassphrase(filePath, passphrase)
+	assert.Nil(t, err)
+
+	_, err = LoadNodeKey(filePath)
+	assert.NotNil(t, err)
+
+	nodeKey2, err := LoadOrGenNodeKeyWithPassphrase(filePath, passphrase)
+	assert.Nil(t, err)
+	assert.Equal(t, nodeKey, nodeKey2)
+
+	// a plaintext node key can be encrypted
+	assert.Nil(t, nodeKey.SaveAs(filePath, nil))
+	nodeKey2, err = LoadNodeKey(filePath)
+	assert.Nil(t, err)
+	assert.Nil(t, nodeKey2.SaveAs(filePath, passphrase))
+	nodeKey2, err = LoadNodeKeyWithPassphrase(filePath, passphrase)
+	assert.Nil(t, err)
+	assert.Equal(t, nodeKey, nodeKey2)
+}
+
 //----------------------------------------------------------
 
 func padBytes(bz []byte, targetBytes int) []byte {
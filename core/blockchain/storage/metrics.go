@@ -22,6 +22,12 @@ type Metrics struct {
 	TxSizeBytes metrics.Histogram
 	// Number of failed transactions.
 	FailedTxs metrics.Counter
+	// Number of transactions evicted for transactions of higher priority.
+	EvictedTxs metrics.Counter
+	// Number of transactions dropped because they expired.
+	ExpiredTxs metrics.Counter
+	// Number of transactions dropped because their peer sent too many.
+	RateLimitedTxs metrics.Counter
 	// Number of times transactions are rechecked in the storage.
 	RecheckTimes metrics.Counter
 }
@@ -54,6 +60,24 @@ func PrometheusMetrics(namespace string, labelsAndValues ...string) *Metrics {
 			Name:      "failed_txs",
 			Help:      "Number of failed transactions.",
 		}, labels).With(labelsAndValues...),
+		EvictedTxs: prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: MetricsSubsystem,
+			Name:      "evicted_txs",
+			Help:      "Number of transactions evicted for transactions of higher priority.",
+		}, labels).With(labelsAndValues...),
+		ExpiredTxs: prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: MetricsSubsystem,
+			Name:      "expired_txs",
+			Help:      "Number of transactions dropped because they expired.",
+		}, labels).With(labelsAndValues...),
+		RateLimitedTxs: prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: MetricsSubsystem,
+			Name:      "rate_limited_txs",
+			Help:      "Number of transactions dropped because their peer sent too many.",
+		}, labels).With(labelsAndValues...),
 		RecheckTimes: prometheus.NewCounterFrom(stdprometheus.CounterOpts{
 			Namespace: namespace,
 			Subsystem: MetricsSubsystem,
@@ -66,9 +90,12 @@ func PrometheusMetrics(namespace string, labelsAndValues ...string) *Metrics {
 // NopMetrics returns no-op Metrics.
 func NopMetrics() *Metrics {
 	return &Metrics{
-		Size:         discard.NewGauge(),
-		TxSizeBytes:  discard.NewHistogram(),
-		FailedTxs:    discard.NewCounter(),
-		RecheckTimes: discard.NewCounter(),
+		Size:           discard.NewGauge(),
+		TxSizeBytes:    discard.NewHistogram(),
+		FailedTxs:      discard.NewCounter(),
+		EvictedTxs:     discard.NewCounter(),
+		ExpiredTxs:     discard.NewCounter(),
+		RateLimitedTxs: discard.NewCounter(),
+		RecheckTimes:   discard.NewCounter(),
 	}
 }
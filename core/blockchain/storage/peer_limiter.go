@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	cfg "github.com/teragrid/dgrid/core/config"
+	"github.com/teragrid/dgrid/core/types"
+)
+
+// tokenBucket holds up to capacity tokens, and is refilled with rate tokens
+// per second. A bucket of rate 0 never runs out of tokens.
+type tokenBucket struct {
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+// newTokenBucket returns a full bucket.
+func newTokenBucket(rate, capacity float64, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		rate:     rate,
+		capacity: capacity,
+		tokens:   capacity,
+		last:     now,
+	}
+}
+
+// take takes n tokens from the bucket at now. It returns false and takes none
+// if there are not enough.
+func (tb *tokenBucket) take(n float64, now time.Time) bool {
+	if tb.rate == 0 {
+		return true
+	}
+	if elapsed := now.Sub(tb.last); elapsed > 0 {
+		tb.tokens += elapsed.Seconds() * tb.rate
+		if tb.tokens > tb.capacity {
+			tb.tokens = tb.capacity
+		}
+		tb.last = now
+	}
+	if tb.tokens < n {
+		return false
+	}
+	tb.tokens -= n
+	return true
+}
+
+// put puts back n tokens taken from the bucket.
+func (tb *tokenBucket) put(n float64) {
+	tb.tokens += n
+	if tb.tokens > tb.capacity {
+		tb.tokens = tb.capacity
+	}
+}
+
+// peerLimiter limits the txs the StorageReactor receives from a peer, and the
+// requested txs it sends to it, and counts the invalid and duplicate ones.
+// Each limit is a token bucket allowing a burst of one second of txs and
+// bytes, or one minute of invalid and duplicate txs. The txs sent have the
+// same limits as the ones received.
+type peerLimiter struct {
+	mtx         sync.Mutex
+	txs         *tokenBucket
+	bytes       *tokenBucket
+	servedTxs   *tokenBucket
+	servedBytes *tokenBucket
+	invalid     *tokenBucket
+	duplicate   *tokenBucket
+
+	// whether the peer already went over a threshold
+	reported bool
+}
+
+func newPeerLimiter(config *cfg.StorageConfig, now time.Time) *peerLimiter {
+	perMin := func(n int) *tokenBucket {
+		return newTokenBucket(float64(n)/60, float64(n), now)
+	}
+	// a tx as large as possible must fit in the bytes bucket
+	bytesCapacity := config.PeerMaxBytesPerSec
+	if bytesCapacity < maxTxSize {
+		bytesCapacity = maxTxSize
+	}
+	return &peerLimiter{
+		txs:         newTokenBucket(float64(config.PeerMaxTxsPerSec), float64(config.PeerMaxTxsPerSec), now),
+		bytes:       newTokenBucket(float64(config.PeerMaxBytesPerSec), float64(bytesCapacity), now),
+		servedTxs:   newTokenBucket(float64(config.PeerMaxTxsPerSec), float64(config.PeerMaxTxsPerSec), now),
+		servedBytes: newTokenBucket(float64(config.PeerMaxBytesPerSec), float64(bytesCapacity), now),
+		invalid:     perMin(config.PeerMaxInvalidTxsPerMin),
+		duplicate:   perMin(config.PeerMaxDuplicateTxsPerMin),
+	}
+}
+
+// allowTx returns true if tx, received from the peer, is within its rate
+// limits.
+func (pl *peerLimiter) allowTx(tx types.Tx, now time.Time) bool {
+	return pl.allow(pl.txs, pl.bytes, tx, now)
+}
+
+// allowServedTx returns true if tx, requested by the peer, is within its
+// rate limits.
+func (pl *peerLimiter) allowServedTx(tx types.Tx, now time.Time) bool {
+	return pl.allow(pl.servedTxs, pl.servedBytes, tx, now)
+}
+
+func (pl *peerLimiter) allow(txs, bytes *tokenBucket, tx types.Tx, now time.Time) bool {
+	pl.mtx.Lock()
+	defer pl.mtx.Unlock()
+
+	if !txs.take(1, now) {
+		return false
+	}
+	if !bytes.take(float64(len(tx)), now) {
+		txs.put(1)
+		return false
+	}
+	return true
+}
+
+// invalidTx counts an invalid tx sent by the peer. It returns an error the
+// first time the peer sends too many.
+func (pl *peerLimiter) invalidTx(now time.Time) error {
+	return pl.badTx(pl.invalid, "invalid", now)
+}
+
+// duplicateTx counts a duplicate tx sent by the peer. It returns an error the
+// first time the peer sends too many.
+func (pl *peerLimiter) duplicateTx(now time.Time) error {
+	return pl.badTx(pl.duplicate, "duplicate", now)
+}
+
+func (pl *peerLimiter) badTx(bucket *tokenBucket, kind string, now time.Time) error {
+	pl.mtx.Lock()
+	defer pl.mtx.Unlock()
+
+	if bucket.take(1, now) || pl.reported {
+		return nil
+	}
+	pl.reported = true
+	return fmt.Errorf("Peer sent too many %s txs (max %v per minute)", kind, bucket.capacity)
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	cfg "github.com/teragrid/dgrid/core/config"
+	"github.com/teragrid/dgrid/core/types"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	tb := newTokenBucket(2, 4, now)
+	assert.True(t, tb.take(3, now))
+	assert.False(t, tb.take(2, now))
+	assert.True(t, tb.take(1, now))
+	assert.False(t, tb.take(1, now))
+
+	// refilled with 2 tokens per second, up to the capacity
+	assert.True(t, tb.take(1, now.Add(500*time.Millisecond)))
+	assert.False(t, tb.take(1, now.Add(500*time.Millisecond)))
+	assert.True(t, tb.take(4, now.Add(time.Minute)))
+
+	// no limit
+	tb = newTokenBucket(0, 0, now)
+	assert.True(t, tb.take(100, now))
+}
+
+func TestPeerLimiter(t *testing.T) {
+	config := cfg.TestConfig().Storage
+	config.PeerMaxTxsPerSec = 2
+	config.PeerMaxBytesPerSec = 2 * maxTxSize
+	config.PeerMaxInvalidTxsPerMin = 1
+	config.PeerMaxDuplicateTxsPerMin = 0
+	now := time.Now()
+	pl := newPeerLimiter(config, now)
+
+	// the tokens are not taken for a tx over the bytes limit
+	assert.True(t, pl.allowTx(make(types.Tx, maxTxSize), now))
+	assert.False(t, pl.allowTx(make(types.Tx, maxTxSize+1), now))
+	assert.True(t, pl.allowTx(types.Tx("foo"), now))
+	assert.False(t, pl.allowTx(types.Tx("foo"), now))
+	assert.True(t, pl.allowTx(types.Tx("foo"), now.Add(time.Second)))
+
+	// the txs sent to the peer have their own limits
+	assert.True(t, pl.allowServedTx(types.Tx("foo"), now))
+	assert.True(t, pl.allowServedTx(types.Tx("foo"), now))
+	assert.False(t, pl.allowServedTx(types.Tx("foo"), now))
+
+	// the peer is reported once
+	assert.NoError(t, pl.invalidTx(now))
+	assert.Error(t, pl.invalidTx(now))
+	assert.NoError(t, pl.invalidTx(now))
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, pl.duplicateTx(now))
+	}
+}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"math"
 	"reflect"
@@ -9,6 +10,7 @@ import (
 
 	amino "github.com/teragrid/dgrid/third_party/amino"
 
+	asura "github.com/teragrid/dgrid/asura/types"
 	cfg "github.com/teragrid/dgrid/core/config"
 	"github.com/teragrid/dgrid/pkg/clist"
 	"github.com/teragrid/dgrid/pkg/log"
@@ -18,6 +20,10 @@ import (
 
 const (
 	StorageChannel = byte(0x30)
+	// StorageAnnounceChannel is the channel of the txs gossiped by hash
+	// announcements. The peers which don't report it get the txs on
+	// StorageChannel.
+	StorageAnnounceChannel = byte(0x31)
 
 	maxMsgSize = 1048576        // 1MB TODO make it configurable
 	maxTxSize  = maxMsgSize - 8 // account for amino overhead of TxMessage
@@ -29,16 +35,58 @@ const (
 	UnknownPeerID uint16 = 0
 
 	maxActiveIDs = math.MaxUint16
+
+	// maxAnnouncedTxs is the max number of tx hashes of a TxsAnnounceMessage
+	// or a TxsRequestMessage.
+	maxAnnouncedTxs = 1000
+
+	// txRequestTimeout is the time after which a tx requested from a peer is
+	// requested again from another peer announcing it.
+	txRequestTimeout = 10 * time.Second
+
+	// txRequestCheckInterval is the interval at which the timed out requests
+	// are sent again.
+	txRequestCheckInterval = time.Second
+
+	// maxRequestedTxs is the max number of txs announced and not received yet.
+	// The txs announced over it are not requested.
+	maxRequestedTxs = 10000
+
+	// maxTxAnnouncers is the max number of peers a tx is requested from.
+	maxTxAnnouncers = 10
+
+	// peerLimiterKey is the key of the peerLimiter of a peer.
+	peerLimiterKey = "StorageReactor.peerLimiter"
 )
 
 // StorageReactor handles storage tx broadcasting amongst peers.
 // It maintains a map from peer ID to counter, to prevent gossiping txs to the
 // peers you received it from.
+//
+// When both ends report StorageAnnounceChannel, the txs are not pushed to the
+// peer: their hashes are announced in batches, and the peer requests the txs
+// it doesn't have, which are sent in batches too. A tx not received within
+// txRequestTimeout is requested from another peer which announced it, and
+// each peer is only sent the txs announced to it, once.
+//
+// The txs received from a peer over its rate limits are dropped, and the peer
+// is stopped if it sends too many invalid or duplicate txs. See peerLimiter.
 type StorageReactor struct {
 	p2p.BaseReactor
 	config  *cfg.StorageConfig
 	Storage *Storage
 	ids     *storageIDs
+
+	// txs announced by peers, requested from one of them at a time
+	mtx       sync.Mutex
+	requested map[[sha256.Size]byte]*txRequest
+}
+
+// txRequest is a tx announced by peers, and not received yet.
+type txRequest struct {
+	peer       p2p.ID    // the peer the tx is requested from, if any
+	time       time.Time // the time of the request
+	announcers []p2p.ID  // the other peers which announced the tx
 }
 
 type storageIDs struct {
@@ -107,9 +155,10 @@ func newStorageIDs() *storageIDs {
 // NewStorageReactor returns a new StorageReactor with the given config and storage.
 func NewStorageReactor(config *cfg.StorageConfig, storage *Storage) *StorageReactor {
 	memR := &StorageReactor{
-		config:  config,
-		Storage: storage,
-		ids:     newStorageIDs(),
+		config:    config,
+		Storage:   storage,
+		ids:       newStorageIDs(),
+		requested: make(map[[sha256.Size]byte]*txRequest),
 	}
 	memR.BaseReactor = *p2p.NewBaseReactor("StorageReactor", memR)
 	return memR
@@ -126,30 +175,60 @@ func (memR *StorageReactor) OnStart() error {
 	if !memR.config.Broadcast {
 		memR.Logger.Info("Tx broadcasting is disabled")
 	}
+	if memR.config.AnnounceTxs {
+		go memR.requestTimeoutRoutine()
+	}
 	return nil
 }
 
 // GetChannels implements Reactor.
 // It returns the list of channels for this reactor.
 func (memR *StorageReactor) GetChannels() []*p2p.ChannelDescriptor {
-	return []*p2p.ChannelDescriptor{
+	channels := []*p2p.ChannelDescriptor{
 		{
 			ID:       StorageChannel,
 			Priority: 5,
 		},
 	}
+	if memR.config.AnnounceTxs {
+		channels = append(channels, &p2p.ChannelDescriptor{
+			ID:       StorageAnnounceChannel,
+			Priority: 5,
+		})
+	}
+	return channels
 }
 
 // AddPeer implements Reactor.
 // It starts a broadcast routine ensuring all txs are forwarded to the given peer.
 func (memR *StorageReactor) AddPeer(peer p2p.Peer) {
 	memR.ids.ReserveForPeer(peer)
-	go memR.broadcastTxRoutine(peer)
+	peer.Set(peerLimiterKey, newPeerLimiter(memR.config, time.Now()))
+	if memR.config.AnnounceTxs && peerHasChannel(peer, StorageAnnounceChannel) {
+		go memR.announceTxsRoutine(peer)
+	} else {
+		go memR.broadcastTxRoutine(peer)
+	}
+}
+
+// peerHasChannel returns true if the peer reported knowing about chID.
+func peerHasChannel(peer p2p.Peer, chID byte) bool {
+	nodeInfo, ok := peer.NodeInfo().(p2p.DefaultNodeInfo)
+	if !ok {
+		return false
+	}
+	for _, ch := range nodeInfo.Channels {
+		if ch == chID {
+			return true
+		}
+	}
+	return false
 }
 
 // RemovePeer implements Reactor.
 func (memR *StorageReactor) RemovePeer(peer p2p.Peer, reason interface{}) {
 	memR.ids.Reclaim(peer)
+	memR.Storage.removePeer(peer.ID())
 	// broadcast routine checks if peer is gone and returns
 }
 
@@ -166,17 +245,81 @@ func (memR *StorageReactor) Receive(chID byte, src p2p.Peer, msgBytes []byte) {
 
 	switch msg := msg.(type) {
 	case *TxMessage:
-		peerID := memR.ids.GetForPeer(src)
-		err := memR.Storage.CheckTxWithInfo(msg.Tx, nil, TxInfo{PeerID: peerID})
-		if err != nil {
-			memR.Logger.Info("Could not check tx", "tx", TxID(msg.Tx), "err", err)
-		}
+		memR.checkTxs(src, []types.Tx{msg.Tx})
 		// broadcasting happens from go routines per peer
+	case *TxsAnnounceMessage:
+		if err := validateTxHashes(msg.Hashes); err != nil {
+			memR.Switch.StopPeerForError(src, err)
+			return
+		}
+		memR.requestTxs(src, msg.Hashes)
+	case *TxsRequestMessage:
+		if err := validateTxHashes(msg.Hashes); err != nil {
+			memR.Switch.StopPeerForError(src, err)
+			return
+		}
+		memR.sendTxs(src, msg.Hashes)
+	case *TxsMessage:
+		memR.mtx.Lock()
+		for _, tx := range msg.Txs {
+			delete(memR.requested, txKey(tx))
+		}
+		memR.mtx.Unlock()
+		memR.checkTxs(src, msg.Txs)
 	default:
 		memR.Logger.Error(fmt.Sprintf("Unknown message type %v", reflect.TypeOf(msg)))
 	}
 }
 
+// checkTxs adds the txs received from peer to the storage, dropping the ones
+// over the rate limits of the peer. The peer is stopped if it sent too many
+// txs rejected by the application, or already sent.
+func (memR *StorageReactor) checkTxs(peer p2p.Peer, txs []types.Tx) {
+	limiter, ok := peer.Get(peerLimiterKey).(*peerLimiter)
+	if !ok {
+		// the peer was not added yet
+		return
+	}
+	peerID := memR.ids.GetForPeer(peer)
+	// the txs rejected by the storage itself, e.g. when full, are not the
+	// fault of the peer
+	cb := func(res *asura.Response) {
+		r := res.GetCheckTx()
+		if r == nil || r.Code == asura.CodeTypeOK || r.Codespace == CodespaceStorage {
+			return
+		}
+		if err := limiter.invalidTx(time.Now()); err != nil {
+			// not from the callback, which holds the storage lock
+			go memR.Switch.StopPeerForError(peer, err)
+		}
+	}
+	for _, tx := range txs {
+		if !limiter.allowTx(tx, time.Now()) {
+			memR.Logger.Debug("Dropped tx over the peer rate limits", "peer", peer, "tx", TxID(tx))
+			memR.Storage.metrics.RateLimitedTxs.Add(1)
+			continue
+		}
+
+		err := memR.Storage.CheckTxWithInfo(tx, cb, TxInfo{PeerID: peerID, SenderP2PID: peer.ID()})
+		if err == nil {
+			continue
+		}
+		memR.Logger.Info("Could not check tx", "tx", TxID(tx), "err", err)
+
+		var badErr error
+		switch {
+		case err == ErrTxResent:
+			badErr = limiter.duplicateTx(time.Now())
+		case IsPreCheckError(err):
+			badErr = limiter.invalidTx(time.Now())
+		}
+		if badErr != nil {
+			memR.Switch.StopPeerForError(peer, badErr)
+			return
+		}
+	}
+}
+
 // PeerState describes the state of a peer.
 type PeerState interface {
 	GetHeight() int64
@@ -229,8 +372,9 @@ func (memR *StorageReactor) broadcastTxRoutine(peer p2p.Peer) {
 			continue
 		}
 
-		// ensure peer hasn't already sent us this tx
-		if _, ok := memTx.senders.Load(peerID); !ok {
+		// ensure peer hasn't already sent us this tx, or got it from us
+		_, sent := memTx.sentTo.Load(peer.ID())
+		if _, ok := memTx.senders.Load(peerID); !ok && !sent {
 			// send memTx
 			msg := &TxMessage{Tx: memTx.tx}
 			success := peer.Send(StorageChannel, cdc.MustMarshalBinaryBare(msg))
@@ -238,6 +382,86 @@ func (memR *StorageReactor) broadcastTxRoutine(peer p2p.Peer) {
 				time.Sleep(peerCatchupSleepIntervalMS * time.Millisecond)
 				continue
 			}
+			memTx.sentTo.Store(peer.ID(), true)
+		}
+
+		select {
+		case <-next.NextWaitChan():
+			// see the start of the for loop for nil check
+			next = next.Next()
+		case <-peer.Quit():
+			return
+		case <-memR.Quit():
+			return
+		}
+	}
+}
+
+// Announce the hashes of new storage txs to peer, which requests the ones
+// it doesn't have. See requestTxs and sendTxs.
+func (memR *StorageReactor) announceTxsRoutine(peer p2p.Peer) {
+	if !memR.config.Broadcast {
+		return
+	}
+
+	peerID := memR.ids.GetForPeer(peer)
+	var (
+		next   *clist.CElement
+		hashes [][]byte
+	)
+	for {
+		// In case of both next.NextWaitChan() and peer.Quit() are variable at the same time
+		if !memR.IsRunning() || !peer.IsRunning() {
+			return
+		}
+		// This happens because the CElement we were looking at got garbage
+		// collected (removed). That is, .NextWait() returned nil. Go ahead and
+		// start from the beginning.
+		if next == nil {
+			select {
+			case <-memR.Storage.TxsWaitChan(): // Wait until a tx is available
+				if next = memR.Storage.TxsFront(); next == nil {
+					continue
+				}
+			case <-peer.Quit():
+				return
+			case <-memR.Quit():
+				return
+			}
+		}
+
+		memTx := next.Value.(*storageTx)
+
+		// make sure the peer is up to date, see broadcastTxRoutine
+		peerState, ok := peer.Get(types.PeerStateKey).(PeerState)
+		if !ok {
+			time.Sleep(peerCatchupSleepIntervalMS * time.Millisecond)
+			continue
+		}
+		if peerState.GetHeight() < memTx.Height()-1 { // Allow for a lag of 1 block
+			time.Sleep(peerCatchupSleepIntervalMS * time.Millisecond)
+			continue
+		}
+
+		// ensure peer hasn't already sent or announced us this tx, or got
+		// its hash from us
+		_, sent := memTx.sentTo.Load(peer.ID())
+		if _, ok := memTx.senders.Load(peerID); !ok && !sent {
+			key := txKey(memTx.tx)
+			hashes = append(hashes, key[:])
+			memTx.announced.Store(peer.ID(), true)
+			memTx.sentTo.Store(peer.ID(), true)
+		}
+
+		// announce the hashes when there are no more txs for now, or enough
+		if len(hashes) > 0 && (next.Next() == nil || len(hashes) >= maxAnnouncedTxs) {
+			msg := &TxsAnnounceMessage{Hashes: hashes}
+			success := peer.Send(StorageAnnounceChannel, cdc.MustMarshalBinaryBare(msg))
+			if !success {
+				time.Sleep(peerCatchupSleepIntervalMS * time.Millisecond)
+				continue
+			}
+			hashes = nil
 		}
 
 		select {
@@ -252,6 +476,178 @@ func (memR *StorageReactor) broadcastTxRoutine(peer p2p.Peer) {
 	}
 }
 
+// requestTxs requests from peer the txs it announced which are not in the
+// storage, weren't seen recently, or already requested from another peer. The
+// txs requested from another peer are requested from peer if they are not
+// received in time, see retryTxRequests.
+func (memR *StorageReactor) requestTxs(peer p2p.Peer, hashes [][]byte) {
+	peerID := memR.ids.GetForPeer(peer)
+	now := time.Now()
+
+	memR.mtx.Lock()
+	var wanted [][]byte
+	for _, hash := range hashes {
+		var key [sha256.Size]byte
+		copy(key[:], hash)
+		if memTx, ok := memR.Storage.txByKey(key); ok {
+			// the peer has the tx, don't announce it back
+			memTx.senders.Store(peerID, true)
+			continue
+		}
+		if memR.Storage.cache.Has(key) {
+			continue
+		}
+		if req, ok := memR.requested[key]; ok {
+			if req.peer != peer.ID() && len(req.announcers) < maxTxAnnouncers && !containsPeer(req.announcers, peer.ID()) {
+				req.announcers = append(req.announcers, peer.ID())
+			}
+			continue
+		}
+		if len(memR.requested) >= maxRequestedTxs {
+			memR.Logger.Debug("Too many requested txs, dropped announced tx", "peer", peer)
+			continue
+		}
+		memR.requested[key] = &txRequest{peer: peer.ID(), time: now}
+		wanted = append(wanted, hash)
+	}
+	memR.mtx.Unlock()
+
+	memR.sendTxsRequest(peer, wanted)
+}
+
+// sendTxsRequest requests the txs of hashes from peer. If the request can't
+// be sent, the txs are requested again from the next peer announcing them.
+func (memR *StorageReactor) sendTxsRequest(peer p2p.Peer, hashes [][]byte) {
+	if len(hashes) == 0 {
+		return
+	}
+	msg := &TxsRequestMessage{Hashes: hashes}
+	if peer.Send(StorageAnnounceChannel, cdc.MustMarshalBinaryBare(msg)) {
+		return
+	}
+	memR.Logger.Info("Could not request txs", "peer", peer, "numtxs", len(hashes))
+
+	memR.mtx.Lock()
+	defer memR.mtx.Unlock()
+	for _, hash := range hashes {
+		var key [sha256.Size]byte
+		copy(key[:], hash)
+		if req, ok := memR.requested[key]; ok && req.peer == peer.ID() {
+			// the other peers first, peer later
+			req.peer = ""
+			req.announcers = append(req.announcers, peer.ID())
+		}
+	}
+}
+
+// requestTimeoutRoutine requests again the txs which were not received in
+// time, until the reactor stops.
+func (memR *StorageReactor) requestTimeoutRoutine() {
+	ticker := time.NewTicker(txRequestCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			memR.retryTxRequests(now)
+		case <-memR.Quit():
+			return
+		}
+	}
+}
+
+// retryTxRequests requests the txs not received by now, or whose request
+// could not be sent, from the next peer announcing them. The txs received
+// meanwhile, or announced by no other peer, are forgotten.
+func (memR *StorageReactor) retryTxRequests(now time.Time) {
+	wanted := make(map[p2p.ID][][]byte)
+
+	memR.mtx.Lock()
+	for key, req := range memR.requested {
+		if req.peer != "" && now.Sub(req.time) < txRequestTimeout {
+			continue
+		}
+		if _, ok := memR.Storage.txByKey(key); ok || memR.Storage.cache.Has(key) || len(req.announcers) == 0 {
+			delete(memR.requested, key)
+			continue
+		}
+		next := req.announcers[0]
+		if len(wanted[next]) >= maxAnnouncedTxs {
+			// the next time
+			req.peer = ""
+			continue
+		}
+		req.peer, req.announcers, req.time = next, req.announcers[1:], now
+		hash := key
+		wanted[next] = append(wanted[next], hash[:])
+	}
+	memR.mtx.Unlock()
+
+	for peerID, hashes := range wanted {
+		if peer := memR.Switch.Peers().Get(peerID); peer != nil {
+			memR.sendTxsRequest(peer, hashes)
+		}
+		// otherwise the txs are requested from the next peer on timeout
+	}
+}
+
+// containsPeer returns true if ids contains id.
+func containsPeer(ids []p2p.ID, id p2p.ID) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
+
+// sendTxs sends to peer the txs it requested which are in the storage and
+// were announced to it, in as few messages as possible. Each tx is sent once
+// per announcement, and the txs over the rate limits of the peer are not
+// sent.
+func (memR *StorageReactor) sendTxs(peer p2p.Peer, hashes [][]byte) {
+	limiter, ok := peer.Get(peerLimiterKey).(*peerLimiter)
+	if !ok {
+		// the peer was not added yet
+		return
+	}
+	var (
+		txs  []types.Tx
+		size = txsMessageOverhead
+	)
+	send := func() {
+		msg := &TxsMessage{Txs: txs}
+		if !peer.Send(StorageAnnounceChannel, cdc.MustMarshalBinaryBare(msg)) {
+			memR.Logger.Info("Could not send txs", "peer", peer, "numtxs", len(txs))
+		}
+		txs, size = nil, txsMessageOverhead
+	}
+	for _, hash := range hashes {
+		var key [sha256.Size]byte
+		copy(key[:], hash)
+		memTx, ok := memR.Storage.txByKey(key)
+		if !ok {
+			continue
+		}
+		if _, announced := memTx.announced.Load(peer.ID()); !announced {
+			continue
+		}
+		if !limiter.allowServedTx(memTx.tx, time.Now()) {
+			memR.Logger.Debug("Dropped requested tx over the peer rate limits", "peer", peer, "tx", TxID(memTx.tx))
+			continue
+		}
+		memTx.announced.Delete(peer.ID())
+		txSize := txsMessageTxSize(memTx.tx)
+		if len(txs) > 0 && size+txSize > maxMsgSize {
+			send()
+		}
+		txs = append(txs, memTx.tx)
+		size += txSize
+	}
+	if len(txs) > 0 {
+		send()
+	}
+}
+
 //-----------------------------------------------------------------------------
 // Messages
 
@@ -261,6 +657,9 @@ type StorageMessage interface{}
 func RegisterStorageMessages(cdc *amino.Codec) {
 	cdc.RegisterInterface((*StorageMessage)(nil), nil)
 	cdc.RegisterConcrete(&TxMessage{}, "teragrid/storage/TxMessage", nil)
+	cdc.RegisterConcrete(&TxsAnnounceMessage{}, "teragrid/storage/TxsAnnounceMessage", nil)
+	cdc.RegisterConcrete(&TxsRequestMessage{}, "teragrid/storage/TxsRequestMessage", nil)
+	cdc.RegisterConcrete(&TxsMessage{}, "teragrid/storage/TxsMessage", nil)
 }
 
 func decodeMsg(bz []byte) (msg StorageMessage, err error) {
@@ -282,3 +681,60 @@ type TxMessage struct {
 func (m *TxMessage) String() string {
 	return fmt.Sprintf("[TxMessage %v]", m.Tx)
 }
+
+//-------------------------------------
+
+// TxsAnnounceMessage is a StorageMessage announcing the hashes of txs. See
+// txKey.
+type TxsAnnounceMessage struct {
+	Hashes [][]byte
+}
+
+// String returns a string representation of the TxsAnnounceMessage.
+func (m *TxsAnnounceMessage) String() string {
+	return fmt.Sprintf("[TxsAnnounceMessage %d txs]", len(m.Hashes))
+}
+
+// TxsRequestMessage is a StorageMessage requesting the txs of hashes, which
+// were announced. See txKey.
+type TxsRequestMessage struct {
+	Hashes [][]byte
+}
+
+// String returns a string representation of the TxsRequestMessage.
+func (m *TxsRequestMessage) String() string {
+	return fmt.Sprintf("[TxsRequestMessage %d txs]", len(m.Hashes))
+}
+
+// TxsMessage is a StorageMessage containing requested transactions.
+type TxsMessage struct {
+	Txs []types.Tx
+}
+
+// String returns a string representation of the TxsMessage.
+func (m *TxsMessage) String() string {
+	return fmt.Sprintf("[TxsMessage %d txs]", len(m.Txs))
+}
+
+// txsMessageOverhead is the size of the amino prefix of a TxsMessage.
+const txsMessageOverhead = 4
+
+// txsMessageTxSize returns the size of tx in an amino-encoded TxsMessage: the
+// field key, the length and the tx.
+func txsMessageTxSize(tx types.Tx) int {
+	return 1 + amino.ByteSliceSize(tx)
+}
+
+// validateTxHashes returns an error if hashes are not tx hashes, or too many
+// for a message.
+func validateTxHashes(hashes [][]byte) error {
+	if len(hashes) > maxAnnouncedTxs {
+		return fmt.Errorf("Too many tx hashes (%d > %d)", len(hashes), maxAnnouncedTxs)
+	}
+	for _, hash := range hashes {
+		if len(hash) != sha256.Size {
+			return fmt.Errorf("Wrong tx hash size (%d != %d)", len(hash), sha256.Size)
+		}
+	}
+	return nil
+}
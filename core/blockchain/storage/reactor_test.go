@@ -44,6 +44,18 @@ func storageLogger() log.Logger {
 
 // connect N storage reactors through N switches
 func makeAndConnectStorageReactors(config *cfg.Config, N int) []*StorageReactor {
+	return makeAndConnectStorageReactorsWithConfigs(config, N, func(int) *cfg.StorageConfig {
+		return config.Storage
+	})
+}
+
+// connect N storage reactors, with the storage config of storageConfig(i),
+// through N switches
+func makeAndConnectStorageReactorsWithConfigs(
+	config *cfg.Config,
+	N int,
+	storageConfig func(i int) *cfg.StorageConfig,
+) []*StorageReactor {
 	reactors := make([]*StorageReactor, N)
 	logger := storageLogger()
 	for i := 0; i < N; i++ {
@@ -52,7 +64,7 @@ func makeAndConnectStorageReactors(config *cfg.Config, N int) []*StorageReactor
 		storage, cleanup := newStorageWithApp(cc)
 		defer cleanup()
 
-		reactors[i] = NewStorageReactor(config.Storage, storage) // so we dont start the consensus states
+		reactors[i] = NewStorageReactor(storageConfig(i), storage) // so we dont start the consensus states
 		reactors[i].SetLogger(logger.With("validator", i))
 	}
 
@@ -134,6 +146,56 @@ func TestReactorBroadcastTxMessage(t *testing.T) {
 	waitForTxs(t, txs, reactors)
 }
 
+func TestReactorBroadcastTxMessagePush(t *testing.T) {
+	config := cfg.TestConfig()
+	config.Storage.AnnounceTxs = false
+	const N = 4
+	reactors := makeAndConnectStorageReactors(config, N)
+	defer func() {
+		for _, r := range reactors {
+			r.Stop()
+		}
+	}()
+	for _, r := range reactors {
+		for _, peer := range r.Switch.Peers().List() {
+			peer.Set(types.PeerStateKey, peerState{1})
+			assert.False(t, peerHasChannel(peer, StorageAnnounceChannel))
+		}
+	}
+
+	txs := checkTxs(t, reactors[0].Storage, NUM_TXS, UnknownPeerID)
+	waitForTxs(t, txs, reactors)
+}
+
+func TestReactorBroadcastTxMessageMixed(t *testing.T) {
+	config := cfg.TestConfig()
+	pushConfig := *config.Storage
+	pushConfig.AnnounceTxs = false
+	const N = 3
+	// the reactor 1 only pushes the txs, and gets them pushed
+	reactors := makeAndConnectStorageReactorsWithConfigs(config, N, func(i int) *cfg.StorageConfig {
+		if i == 1 {
+			return &pushConfig
+		}
+		return config.Storage
+	})
+	defer func() {
+		for _, r := range reactors {
+			r.Stop()
+		}
+	}()
+	pushID := reactors[1].Switch.NodeInfo().ID()
+	for _, r := range reactors {
+		for _, peer := range r.Switch.Peers().List() {
+			peer.Set(types.PeerStateKey, peerState{1})
+			assert.Equal(t, peer.ID() != pushID, peerHasChannel(peer, StorageAnnounceChannel))
+		}
+	}
+
+	txs := checkTxs(t, reactors[0].Storage, NUM_TXS, UnknownPeerID)
+	waitForTxs(t, txs, reactors)
+}
+
 func TestReactorNoBroadcastToSender(t *testing.T) {
 	config := cfg.TestConfig()
 	const N = 2
@@ -150,6 +212,113 @@ func TestReactorNoBroadcastToSender(t *testing.T) {
 	ensureNoTxs(t, reactors[1], 100*time.Millisecond)
 }
 
+func TestReactorPeerRateLimits(t *testing.T) {
+	config := cfg.TestConfig()
+	config.Storage.PeerMaxTxsPerSec = 1
+	const N = 2
+	reactors := makeAndConnectStorageReactors(config, N)
+	defer func() {
+		for _, r := range reactors {
+			r.Stop()
+		}
+	}()
+
+	// the second tx is dropped
+	peer := reactors[0].Switch.Peers().List()[0]
+	reactors[0].Receive(StorageChannel, peer, cdc.MustMarshalBinaryBare(&TxMessage{Tx: types.Tx("foo")}))
+	reactors[0].Receive(StorageChannel, peer, cdc.MustMarshalBinaryBare(&TxMessage{Tx: types.Tx("bar")}))
+	assert.Equal(t, types.Txs{types.Tx("foo")}, reactors[0].Storage.ReapMaxTxs(-1))
+	assert.True(t, reactors[0].Switch.Peers().Has(peer.ID()))
+}
+
+func TestReactorStopsPeerSendingDuplicates(t *testing.T) {
+	config := cfg.TestConfig()
+	config.Storage.PeerMaxDuplicateTxsPerMin = 1
+	const N = 2
+	reactors := makeAndConnectStorageReactors(config, N)
+	defer func() {
+		for _, r := range reactors {
+			r.Stop()
+		}
+	}()
+
+	// the tx, a duplicate within the limit, then one over it
+	peer := reactors[0].Switch.Peers().List()[0]
+	msg := cdc.MustMarshalBinaryBare(&TxMessage{Tx: types.Tx("foo")})
+	reactors[0].Receive(StorageChannel, peer, msg)
+	reactors[0].Receive(StorageChannel, peer, msg)
+	assert.True(t, reactors[0].Switch.Peers().Has(peer.ID()))
+	reactors[0].Receive(StorageChannel, peer, msg)
+	assert.False(t, reactors[0].Switch.Peers().Has(peer.ID()))
+}
+
+func TestReactorNoResendAfterRemovals(t *testing.T) {
+	config := cfg.TestConfig()
+	config.Storage.AnnounceTxs = false
+	config.Storage.PeerMaxDuplicateTxsPerMin = 1
+	const N = 2
+	reactors := makeAndConnectStorageReactors(config, N)
+	defer func() {
+		for _, r := range reactors {
+			r.Stop()
+		}
+	}()
+	for _, r := range reactors {
+		for _, peer := range r.Switch.Peers().List() {
+			peer.Set(types.PeerStateKey, peerState{1})
+		}
+	}
+
+	txs := checkTxs(t, reactors[0].Storage, 10, UnknownPeerID)
+	waitForTxs(t, txs, reactors)
+
+	// the broadcast routine restarts from the front of the storage when the
+	// tx it waits on is removed, without resending the txs
+	reactors[0].Storage.Lock()
+	err := reactors[0].Storage.Update(1, txs[len(txs)-1:], nil, nil)
+	reactors[0].Storage.Unlock()
+	assert.NoError(t, err)
+	assert.NoError(t, reactors[0].Storage.CheckTx(types.Tx("foo"), nil))
+	waitForTxs(t, append(txs[:len(txs):len(txs)], types.Tx("foo")), reactors[1:])
+	assert.True(t, reactors[1].Switch.Peers().Has(reactors[0].Switch.NodeInfo().ID()))
+}
+
+func TestReactorRequestsTxsFromAnotherAnnouncer(t *testing.T) {
+	config := cfg.TestConfig()
+	const N = 3
+	reactors := makeAndConnectStorageReactors(config, N)
+	defer func() {
+		for _, r := range reactors {
+			r.Stop()
+		}
+	}()
+
+	// the reactors 1 and 2 announced the tx to the reactor 0, but only the
+	// reactor 2 marked it announced, so the reactor 1 doesn't send it
+	tx := types.Tx("foo")
+	key := txKey(tx)
+	announceMsg := cdc.MustMarshalBinaryBare(&TxsAnnounceMessage{Hashes: [][]byte{key[:]}})
+	for _, r := range reactors[1:] {
+		assert.NoError(t, r.Storage.CheckTx(tx, nil))
+	}
+	memTx, ok := reactors[2].Storage.txByKey(key)
+	assert.True(t, ok)
+	memTx.announced.Store(reactors[0].Switch.NodeInfo().ID(), true)
+	for _, r := range reactors[1:] {
+		peer := reactors[0].Switch.Peers().Get(r.Switch.NodeInfo().ID())
+		reactors[0].Receive(StorageAnnounceChannel, peer, announceMsg)
+	}
+	ensureNoTxs(t, reactors[0], 100*time.Millisecond)
+
+	// the tx is requested from the reactor 2 on timeout
+	reactors[0].retryTxRequests(time.Now().Add(txRequestTimeout))
+	waitForTxs(t, types.Txs{tx}, reactors[:1])
+
+	// and is sent once per announcement
+	_, ok = memTx.announced.Load(reactors[0].Switch.NodeInfo().ID())
+	assert.False(t, ok)
+}
+
 func TestBroadcastTxForPeerStopsWhenPeerStops(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping test in short mode.")
@@ -224,3 +393,19 @@ func TestStorageIDsPanicsIfNodeRequestsOvermaxActiveIDs(t *testing.T) {
 		ids.ReserveForPeer(peer)
 	})
 }
+
+func TestValidateTxHashes(t *testing.T) {
+	hash := txKey(types.Tx("foo"))
+	assert.NoError(t, validateTxHashes([][]byte{hash[:], hash[:]}))
+	assert.Error(t, validateTxHashes([][]byte{hash[:], hash[1:]}))
+	assert.Error(t, validateTxHashes(make([][]byte, maxAnnouncedTxs+1)))
+}
+
+func TestTxsMessageSize(t *testing.T) {
+	txs := []types.Tx{types.Tx("foo"), make(types.Tx, 300), make(types.Tx, maxTxSize)}
+	for _, tx := range txs {
+		size := txsMessageOverhead + txsMessageTxSize(tx)
+		assert.Equal(t, size, len(cdc.MustMarshalBinaryBare(&TxsMessage{Txs: []types.Tx{tx}})))
+		assert.True(t, size <= maxMsgSize)
+	}
+}
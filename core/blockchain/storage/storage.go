@@ -5,6 +5,7 @@ import (
 	"container/list"
 	"crypto/sha256"
 	"fmt"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -13,11 +14,11 @@ import (
 
 	asura "github.com/teragrid/dgrid/asura/types"
 	cfg "github.com/teragrid/dgrid/core/config"
-	auto "github.com/teragrid/dgrid/pkg/autofile"
 	"github.com/teragrid/dgrid/pkg/clist"
 	cmn "github.com/teragrid/dgrid/pkg/common"
 	"github.com/teragrid/dgrid/pkg/log"
 	"github.com/teragrid/dgrid/proxy"
+	"github.com/teragrid/dgrid/core/blockchain/p2p"
 	"github.com/teragrid/dgrid/core/types"
 )
 
@@ -37,6 +38,9 @@ type TxInfo struct {
 	// We don't use p2p.ID here because it's too big. The gain is to store max 2
 	// bytes with each tx to identify the sender rather than 20 bytes.
 	PeerID uint16
+	// SenderP2PID is the p2p ID of the peer, to detect the txs it resends.
+	// Unlike PeerID, it is not reused for another peer.
+	SenderP2PID p2p.ID
 }
 
 /*
@@ -63,6 +67,22 @@ Garbage collection of old elements from storage.txs is handlde via
 the DetachPrev() call, which makes old elements not reachable by
 peer broadcastTxRoutine() automatically garbage collected.
 
+The linked-list keeps the txs in the order they arrived, which is the order
+they are gossiped in. They are reaped in the order of the priority the
+application gave them in the CheckTx response, and when the storage is full, a
+new tx evicts the txs of lower priority to make room, or is rejected if there
+are not enough of them.
+
+The txs which stay in the storage for more than TTLNumBlocks blocks or
+TTLDuration are dropped on Update(), and an EventTxExpired is fired for each
+of them.
+
+The txs are written to the WAL before they are checked, and Update() ends
+each height with a marker of the txs it removed from the storage. The WAL is an
+autofile.Group: the files before the one of the oldest tx left in the storage
+are removed on Update(). ReplayWAL() checks the pending txs again on startup,
+so they survive a restart.
+
 TODO: Better handle asura client errors. (make it automatically handle connection errors)
 
 */
@@ -71,10 +91,22 @@ var (
 	// ErrTxInCache is returned to the client if we saw tx earlier
 	ErrTxInCache = errors.New("Tx already exists in cache")
 
+	// ErrTxResent is returned to the reactor if a peer sent us a tx which is
+	// in the storage and that it already sent
+	ErrTxResent = errors.New("Tx already sent by the peer")
+
 	// ErrTxTooLarge means the tx is too big to be sent in a message to other peers
 	ErrTxTooLarge = fmt.Errorf("Tx too large. Max size is %d", maxTxSize)
 )
 
+// CodespaceStorage is the codespace of the CheckTx responses of the txs the
+// storage rejected after the application accepted them.
+const CodespaceStorage = "storage"
+
+// CodeTypeStorageIsFull is the code of the CheckTx response of a tx rejected
+// because the storage is full of txs of higher or equal priority.
+const CodeTypeStorageIsFull uint32 = 1
+
 // ErrStorageIsFull means Tendermint & an application can't handle that much load
 type ErrStorageIsFull struct {
 	numTxs int
@@ -158,6 +190,9 @@ func txKey(tx types.Tx) [sha256.Size]byte {
 // round. Transaction validity is checked using the CheckTx asura message before the transaction is
 // added to the pool. The Storage uses a concurrent list structure for storing transactions that
 // can be efficiently accessed by multiple concurrent readers.
+// Transactions are reaped by decreasing priority, as given by the application in the CheckTx
+// response, and a full Storage evicts the transactions of lowest priority for the ones of higher
+// priority.
 type Storage struct {
 	config *cfg.StorageConfig
 
@@ -166,6 +201,7 @@ type Storage struct {
 	txs          *clist.CList // concurrent linked-list of good txs
 	preCheck     PreCheckFunc
 	postCheck    PostCheckFunc
+	txFilters    []PreCheckFunc // kept across Update(), unlike preCheck
 
 	// Track whether we're rechecking txs.
 	// These are not protected by a mutex and are expected to be mutated
@@ -186,16 +222,25 @@ type Storage struct {
 	rechecking int32 // for re-checking filtered txs on Update()
 	txsBytes   int64 // total size of storage, in bytes
 
+	// limits the txs checked while the storage is full
+	fullCheckTxs *tokenBucket
+
 	// Keep a cache of already-seen txs.
 	// This reduces the pressure on the proxyApp.
 	cache txCache
 
 	// A log of storage txs
-	wal *auto.AutoFile
+	wal *storageWAL
+	// txs evicted or invalidated by a recheck since the last WAL marker,
+	// written to the next one
+	walRemovedMtx sync.Mutex
+	walRemoved    []types.Tx
 
 	logger log.Logger
 
 	metrics *Metrics
+
+	eventBus types.StorageEventPublisher
 }
 
 // StorageOption sets an optional parameter on the Storage.
@@ -218,6 +263,9 @@ func NewStorage(
 		recheckEnd:    nil,
 		logger:        log.NewNopLogger(),
 		metrics:       NopMetrics(),
+		eventBus:      types.NopEventBus{},
+		fullCheckTxs: newTokenBucket(float64(config.FullMaxCheckTxsPerSec),
+			float64(config.FullMaxCheckTxsPerSec), time.Now()),
 	}
 	if config.CacheSize > 0 {
 		storage.cache = newMapTxCache(config.CacheSize)
@@ -249,6 +297,15 @@ func WithPreCheck(f PreCheckFunc) StorageOption {
 	return func(mem *Storage) { mem.preCheck = f }
 }
 
+// AddTxFilter adds a filter for the storage to reject a tx if f(tx) returns
+// an error. It is ran before CheckTx, after the PreCheckFunc, which is
+// replaced on Update() while the filters are kept.
+func (mem *Storage) AddTxFilter(f PreCheckFunc) {
+	mem.proxyMtx.Lock()
+	defer mem.proxyMtx.Unlock()
+	mem.txFilters = append(mem.txFilters, f)
+}
+
 // WithPostCheck sets a filter for the storage to reject a tx if f(tx) returns
 // false. This is ran after CheckTx.
 func WithPostCheck(f PostCheckFunc) StorageOption {
@@ -260,6 +317,11 @@ func WithMetrics(metrics *Metrics) StorageOption {
 	return func(mem *Storage) { mem.metrics = metrics }
 }
 
+// WithEventBus sets the event bus the expired txs are published to.
+func WithEventBus(eventBus types.StorageEventPublisher) StorageOption {
+	return func(mem *Storage) { mem.eventBus = eventBus }
+}
+
 // InitWAL creates a directory for the WAL file and opens a file itself.
 //
 // *panics* if can't create directory or open file.
@@ -270,11 +332,87 @@ func (mem *Storage) InitWAL() {
 	if err != nil {
 		panic(errors.Wrap(err, "Error ensuring Storage WAL dir"))
 	}
-	af, err := auto.OpenAutoFile(walDir + "/wal")
+	wal, err := openStorageWAL(walDir + "/wal")
 	if err != nil {
 		panic(errors.Wrap(err, "Error opening Storage WAL file"))
 	}
-	mem.wal = af
+	mem.wal = wal
+}
+
+// ReplayWAL checks again the pending txs of the WAL, written before the node
+// stopped, to add them back to the storage. A torn or altered entry ends the
+// replay. The replayed txs are written to a new head file, and the files
+// before it are removed.
+//
+// *not thread safe*
+func (mem *Storage) ReplayWAL() error {
+	if mem.wal == nil {
+		return nil
+	}
+	txs, err := mem.wal.pendingTxs()
+	if err != nil {
+		mem.logger.Error("Error reading WAL, stopping the replay", "err", err)
+	}
+	replayIndex := mem.wal.rotate()
+
+	for _, tx := range txs {
+		if err := mem.CheckTx(tx, nil); err != nil && err != ErrTxInCache {
+			mem.logger.Info("Could not replay tx", "tx", TxID(tx), "err", err)
+		}
+	}
+	mem.logger.Info("Replayed WAL", "numtxs", len(txs))
+
+	if err := mem.FlushAppConn(); err != nil {
+		return err
+	}
+	if err := mem.wal.writeMarker(mem.height, nil); err != nil {
+		mem.logger.Error("Error writing to WAL", "err", err)
+	}
+	if err := mem.wal.removeBefore(replayIndex); err != nil {
+		mem.logger.Error("Error removing WAL files", "err", err)
+	}
+	return nil
+}
+
+// writeWALMarker ends height in the WAL, with the txs it removed from the
+// storage and the ones evicted or invalidated since the last marker, and
+// removes the files before the one of the oldest tx left in the storage.
+// The errors are only logged, so the WAL never halts the consensus.
+func (mem *Storage) writeWALMarker(height int64, removed []types.Tx) {
+	mem.walRemovedMtx.Lock()
+	for _, tx := range mem.walRemoved {
+		// a tx sent again since is pending again
+		if _, ok := mem.txsMap.Load(txKey(tx)); !ok {
+			removed = append(removed, tx)
+		}
+	}
+	mem.walRemoved = nil
+	mem.walRemovedMtx.Unlock()
+
+	if err := mem.wal.writeMarker(height, removed); err != nil {
+		// TODO: Notify administrators when WAL fails
+		mem.logger.Error("Error writing to WAL", "err", err)
+		return
+	}
+	// the txs are in the order they were written
+	index := mem.wal.headIndex()
+	if e := mem.txs.Front(); e != nil {
+		index = e.Value.(*storageTx).walIndex
+	}
+	if err := mem.wal.removeBefore(index); err != nil {
+		mem.logger.Error("Error removing WAL files", "err", err)
+	}
+}
+
+// walRemoveTx records a tx removed from the storage between two heights, to
+// keep it out of the replay of the WAL from the next marker on.
+func (mem *Storage) walRemoveTx(tx types.Tx) {
+	if mem.wal == nil {
+		return
+	}
+	mem.walRemovedMtx.Lock()
+	mem.walRemoved = append(mem.walRemoved, tx)
+	mem.walRemovedMtx.Unlock()
 }
 
 // CloseWAL closes and discards the underlying WAL file.
@@ -283,9 +421,10 @@ func (mem *Storage) CloseWAL() {
 	mem.proxyMtx.Lock()
 	defer mem.proxyMtx.Unlock()
 
-	if err := mem.wal.Close(); err != nil {
-		mem.logger.Error("Error closing WAL", "err", err)
+	if mem.wal == nil {
+		return
 	}
+	mem.wal.close()
 	mem.wal = nil
 }
 
@@ -331,6 +470,29 @@ func (mem *Storage) Flush() {
 	_ = atomic.SwapInt64(&mem.txsBytes, 0)
 }
 
+// txByKey returns the tx of the storage with the given txKey, if any.
+func (mem *Storage) txByKey(key [sha256.Size]byte) (*storageTx, bool) {
+	e, ok := mem.txsMap.Load(key)
+	if !ok {
+		return nil, false
+	}
+	return e.(*clist.CElement).Value.(*storageTx), true
+}
+
+// removePeer forgets a disconnected peer. The txs are sent to it again if it
+// reconnects, as it may have restarted with an empty storage, and the txs it
+// sends again on the new connection aren't resent ones.
+func (mem *Storage) removePeer(id p2p.ID) {
+	for e := mem.txs.Front(); e != nil; e = e.Next() {
+		memTx := e.Value.(*storageTx)
+		// don't-resend bookkeeping of the broadcast routines
+		memTx.sentTo.Delete(id)
+		memTx.announced.Delete(id)
+		// duplicates detection, see ErrTxResent
+		memTx.p2pSenders.Delete(id)
+	}
+}
+
 // TxsFront returns the first transaction in the ordered list for peer
 // goroutines to call .NextWait() on.
 func (mem *Storage) TxsFront() *clist.CElement {
@@ -357,20 +519,20 @@ func (mem *Storage) CheckTx(tx types.Tx, cb func(*asura.Response)) (err error) {
 // CheckTxWithInfo performs the same operation as CheckTx, but with extra meta data about the tx.
 // Currently this metadata is the peer who sent it,
 // used to prevent the tx from being gossiped back to them.
+// It returns ErrTxResent if the peer already sent the tx, which is still in the
+// storage.
 func (mem *Storage) CheckTxWithInfo(tx types.Tx, cb func(*asura.Response), txInfo TxInfo) (err error) {
 	mem.proxyMtx.Lock()
 	// use defer to unlock mutex because application (*local client*) might panic
 	defer mem.proxyMtx.Unlock()
 
-	var (
-		memSize  = mem.Size()
-		txsBytes = mem.TxsBytes()
-	)
-	if memSize >= mem.config.Size ||
-		int64(len(tx))+txsBytes > mem.config.MaxTxsBytes {
+	// A full storage may still make room for the tx, depending on its
+	// priority, see resCbFirstTime. It can't if the tx doesn't fit in it
+	// even when empty.
+	if mem.config.Size <= 0 || int64(len(tx)) > mem.config.MaxTxsBytes {
 		return ErrStorageIsFull{
-			memSize, mem.config.Size,
-			txsBytes, mem.config.MaxTxsBytes}
+			mem.Size(), mem.config.Size,
+			mem.TxsBytes(), mem.config.MaxTxsBytes}
 	}
 
 	// The size of the corresponding amino-encoded TxMessage
@@ -385,6 +547,11 @@ func (mem *Storage) CheckTxWithInfo(tx types.Tx, cb func(*asura.Response), txInf
 			return ErrPreCheck{err}
 		}
 	}
+	for _, filter := range mem.txFilters {
+		if err := filter(tx); err != nil {
+			return ErrPreCheck{err}
+		}
+	}
 
 	// CACHE
 	if !mem.cache.Push(tx) {
@@ -394,10 +561,12 @@ func (mem *Storage) CheckTxWithInfo(tx types.Tx, cb func(*asura.Response), txInf
 		// so we only record the sender for txs still in the storage.
 		if e, ok := mem.txsMap.Load(txKey(tx)); ok {
 			memTx := e.(*clist.CElement).Value.(*storageTx)
-			if _, loaded := memTx.senders.LoadOrStore(txInfo.PeerID, true); loaded {
-				// TODO: consider punishing peer for dups,
-				// its non-trivial since invalid txs can become valid,
-				// but they can spam the same tx with little cost to them atm.
+			memTx.senders.Store(txInfo.PeerID, true)
+			if txInfo.SenderP2PID != "" {
+				if _, loaded := memTx.p2pSenders.LoadOrStore(txInfo.SenderP2PID, true); loaded {
+					// let the reactor punish the peer for dups, see StorageReactor
+					return ErrTxResent
+				}
 			}
 		}
 
@@ -405,14 +574,22 @@ func (mem *Storage) CheckTxWithInfo(tx types.Tx, cb func(*asura.Response), txInf
 	}
 	// END CACHE
 
+	// A full storage only makes room for txs of higher priority than some of
+	// its txs, which only the application knows, so they are checked at a
+	// limited rate.
+	if mem.isFull(int64(len(tx))) && !mem.fullCheckTxs.take(1, time.Now()) {
+		// the tx can be sent again
+		mem.cache.Remove(tx)
+		return ErrStorageIsFull{
+			mem.Size(), mem.config.Size,
+			mem.TxsBytes(), mem.config.MaxTxsBytes}
+	}
+
 	// WAL
+	var walIndex int
 	if mem.wal != nil {
 		// TODO: Notify administrators when WAL fails
-		_, err := mem.wal.Write([]byte(tx))
-		if err != nil {
-			mem.logger.Error("Error writing to WAL", "err", err)
-		}
-		_, err = mem.wal.Write([]byte("\n"))
+		walIndex, err = mem.wal.writeTx(tx)
 		if err != nil {
 			mem.logger.Error("Error writing to WAL", "err", err)
 		}
@@ -425,7 +602,7 @@ func (mem *Storage) CheckTxWithInfo(tx types.Tx, cb func(*asura.Response), txInf
 	}
 
 	reqRes := mem.proxyAppConn.CheckTxAsync(tx)
-	reqRes.SetCallback(mem.reqResCb(tx, txInfo.PeerID, cb))
+	reqRes.SetCallback(mem.reqResCb(tx, txInfo, walIndex, cb))
 
 	return nil
 }
@@ -458,14 +635,14 @@ func (mem *Storage) globalCb(req *asura.Request, res *asura.Response) {
 // when all other response processing is complete.
 //
 // Used in CheckTxWithInfo to record PeerID who sent us the tx.
-func (mem *Storage) reqResCb(tx []byte, peerID uint16, externalCb func(*asura.Response)) func(res *asura.Response) {
+func (mem *Storage) reqResCb(tx []byte, txInfo TxInfo, walIndex int, externalCb func(*asura.Response)) func(res *asura.Response) {
 	return func(res *asura.Response) {
 		if mem.recheckCursor != nil {
 			// this should never happen
 			panic("recheck cursor is not nil in reqResCb")
 		}
 
-		mem.resCbFirstTime(tx, peerID, res)
+		mem.resCbFirstTime(tx, txInfo, walIndex, res)
 
 		// update metrics
 		mem.metrics.Size.Set(float64(mem.Size()))
@@ -504,7 +681,7 @@ func (mem *Storage) removeTx(tx types.Tx, elem *clist.CElement, removeFromCache
 //
 // The case where the app checks the tx for the second and subsequent times is
 // handled by the resCbRecheck callback.
-func (mem *Storage) resCbFirstTime(tx []byte, peerID uint16, res *asura.Response) {
+func (mem *Storage) resCbFirstTime(tx []byte, txInfo TxInfo, walIndex int, res *asura.Response) {
 	switch r := res.Value.(type) {
 	case *asura.Response_CheckTx:
 		var postCheckErr error
@@ -515,9 +692,26 @@ func (mem *Storage) resCbFirstTime(tx []byte, peerID uint16, res *asura.Response
 			memTx := &storageTx{
 				height:    mem.height,
 				gasWanted: r.CheckTx.GasWanted,
+				priority:  r.CheckTx.Priority,
+				timestamp: time.Now(),
 				tx:        tx,
+				walIndex:  walIndex,
+			}
+			if err := mem.makeRoom(memTx); err != nil {
+				mem.logger.Info("Rejected good transaction", "tx", TxID(tx), "priority", memTx.priority, "err", err)
+				mem.metrics.FailedTxs.Add(1)
+				// remove from cache (there might be room later)
+				mem.cache.Remove(tx)
+				// let the caller of CheckTx know the tx was not added
+				r.CheckTx.Code = CodeTypeStorageIsFull
+				r.CheckTx.Codespace = CodespaceStorage
+				r.CheckTx.Log = err.Error()
+				return
+			}
+			memTx.senders.Store(txInfo.PeerID, true)
+			if txInfo.SenderP2PID != "" {
+				memTx.p2pSenders.Store(txInfo.SenderP2PID, true)
 			}
-			memTx.senders.Store(peerID, true)
 			mem.addTx(memTx)
 			mem.logger.Info("Added good transaction",
 				"tx", TxID(tx),
@@ -538,6 +732,69 @@ func (mem *Storage) resCbFirstTime(tx []byte, peerID uint16, res *asura.Response
 	}
 }
 
+// isFull returns true if the storage has no room for a tx of txBytes bytes
+// without evicting others.
+func (mem *Storage) isFull(txBytes int64) bool {
+	return mem.Size() >= mem.config.Size || mem.TxsBytes()+txBytes > mem.config.MaxTxsBytes
+}
+
+// makeRoom evicts the txs of lowest priority, and of lower priority than
+// memTx, for memTx to fit in the storage. Among the txs of the same priority,
+// the ones which arrived last are evicted first. If evicting all the txs of
+// lower priority than memTx isn't enough, none is evicted and
+// ErrStorageIsFull is returned.
+//
+// Called from:
+//  - resCbFirstTime (lock not held) if tx is valid
+func (mem *Storage) makeRoom(memTx *storageTx) error {
+	var (
+		memSize  = mem.Size()
+		txsBytes = mem.TxsBytes()
+		txBytes  = int64(len(memTx.tx))
+	)
+	if !mem.isFull(txBytes) {
+		return nil
+	}
+
+	var candidates []*clist.CElement
+	for e := mem.txs.Back(); e != nil; e = e.Prev() {
+		if e.Value.(*storageTx).priority < memTx.priority {
+			candidates = append(candidates, e)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Value.(*storageTx).priority < candidates[j].Value.(*storageTx).priority
+	})
+
+	var (
+		numEvicted   int
+		evictedBytes int64
+	)
+	for _, e := range candidates {
+		if memSize-numEvicted < mem.config.Size && txBytes+txsBytes-evictedBytes <= mem.config.MaxTxsBytes {
+			break
+		}
+		numEvicted++
+		evictedBytes += int64(len(e.Value.(*storageTx).tx))
+	}
+	if memSize-numEvicted >= mem.config.Size || txBytes+txsBytes-evictedBytes > mem.config.MaxTxsBytes {
+		return ErrStorageIsFull{
+			memSize, mem.config.Size,
+			txsBytes, mem.config.MaxTxsBytes}
+	}
+
+	for _, e := range candidates[:numEvicted] {
+		evicted := e.Value.(*storageTx)
+		mem.logger.Info("Evicted transaction", "tx", TxID(evicted.tx), "priority", evicted.priority,
+			"for", TxID(memTx.tx), "forPriority", memTx.priority)
+		// NOTE: we remove tx from the cache so it can be sent again
+		mem.removeTx(evicted.tx, e, true)
+		mem.walRemoveTx(evicted.tx)
+		mem.metrics.EvictedTxs.Add(1)
+	}
+	return nil
+}
+
 // callback, which is called after the app rechecked the tx.
 //
 // The case where the app checks the tx for the first time is handled by the
@@ -564,6 +821,7 @@ func (mem *Storage) resCbRecheck(req *asura.Request, res *asura.Response) {
 			mem.logger.Info("Tx is no longer valid", "tx", TxID(tx), "res", r, "err", postCheckErr)
 			// NOTE: we remove tx from the cache because it might be good later
 			mem.removeTx(tx, mem.recheckCursor, true)
+			mem.walRemoveTx(tx)
 		}
 		if mem.recheckCursor == mem.recheckEnd {
 			mem.recheckCursor = nil
@@ -606,8 +864,22 @@ func (mem *Storage) notifyTxsAvailable() {
 	}
 }
 
-// ReapMaxBytesMaxGas reaps transactions from the storage up to maxBytes bytes total
-// with the condition that the total gasWanted must be less than maxGas.
+// txsByPriority returns the txs of the storage by decreasing priority, and in
+// the order they arrived for the same priority.
+func (mem *Storage) txsByPriority() []*storageTx {
+	memTxs := make([]*storageTx, 0, mem.txs.Len())
+	for e := mem.txs.Front(); e != nil; e = e.Next() {
+		memTxs = append(memTxs, e.Value.(*storageTx))
+	}
+	sort.SliceStable(memTxs, func(i, j int) bool {
+		return memTxs[i].priority > memTxs[j].priority
+	})
+	return memTxs
+}
+
+// ReapMaxBytesMaxGas reaps transactions from the storage, by decreasing priority,
+// up to maxBytes bytes total with the condition that the total gasWanted must be less
+// than maxGas.
 // If both maxes are negative, there is no cap on the size of all returned
 // transactions (~ all available transactions).
 func (mem *Storage) ReapMaxBytesMaxGas(maxBytes, maxGas int64) types.Txs {
@@ -625,8 +897,7 @@ func (mem *Storage) ReapMaxBytesMaxGas(maxBytes, maxGas int64) types.Txs {
 	// size per tx, and set the initial capacity based off of that.
 	// txs := make([]types.Tx, 0, cmn.MinInt(mem.txs.Len(), max/mem.avgTxSize))
 	txs := make([]types.Tx, 0, mem.txs.Len())
-	for e := mem.txs.Front(); e != nil; e = e.Next() {
-		memTx := e.Value.(*storageTx)
+	for _, memTx := range mem.txsByPriority() {
 		// Check total size requirement
 		aminoOverhead := types.ComputeAminoOverhead(memTx.tx, 1)
 		if maxBytes > -1 && totalBytes+int64(len(memTx.tx))+aminoOverhead > maxBytes {
@@ -647,7 +918,7 @@ func (mem *Storage) ReapMaxBytesMaxGas(maxBytes, maxGas int64) types.Txs {
 	return txs
 }
 
-// ReapMaxTxs reaps up to max transactions from the storage.
+// ReapMaxTxs reaps up to max transactions from the storage, by decreasing priority.
 // If max is negative, there is no cap on the size of all returned
 // transactions (~ all available transactions).
 func (mem *Storage) ReapMaxTxs(max int) types.Txs {
@@ -664,8 +935,10 @@ func (mem *Storage) ReapMaxTxs(max int) types.Txs {
 	}
 
 	txs := make([]types.Tx, 0, cmn.MinInt(mem.txs.Len(), max))
-	for e := mem.txs.Front(); e != nil && len(txs) <= max; e = e.Next() {
-		memTx := e.Value.(*storageTx)
+	for _, memTx := range mem.txsByPriority() {
+		if len(txs) > max {
+			break
+		}
 		txs = append(txs, memTx.tx)
 	}
 	return txs
@@ -699,6 +972,17 @@ func (mem *Storage) Update(
 	// Remove committed transactions.
 	txsLeft := mem.removeTxs(txs)
 
+	// Remove expired transactions, so they are not rechecked.
+	var expired []types.Tx
+	if mem.config.TTLNumBlocks > 0 || mem.config.TTLDuration > 0 {
+		txsLeft, expired = mem.purgeExpiredTxs(height)
+	}
+
+	// End the height in the WAL, so the removed txs are not replayed.
+	if mem.wal != nil {
+		mem.writeWALMarker(height, append(expired, txs...))
+	}
+
 	// Either recheck non-committed txs to see if they became invalid
 	// or just notify there're some txs left.
 	if len(txsLeft) > 0 {
@@ -741,6 +1025,30 @@ func (mem *Storage) removeTxs(txs types.Txs) []types.Tx {
 	return txsLeft
 }
 
+// purgeExpiredTxs removes the txs which were added more than TTLNumBlocks
+// blocks or TTLDuration ago, and returns the txs left and the expired ones.
+func (mem *Storage) purgeExpiredTxs(height int64) (txsLeft, expired []types.Tx) {
+	now := time.Now()
+	txsLeft = make([]types.Tx, 0, mem.txs.Len())
+	for e := mem.txs.Front(); e != nil; e = e.Next() {
+		memTx := e.Value.(*storageTx)
+		if (mem.config.TTLNumBlocks > 0 && height-memTx.Height() > mem.config.TTLNumBlocks) ||
+			(mem.config.TTLDuration > 0 && now.Sub(memTx.timestamp) > mem.config.TTLDuration) {
+			mem.logger.Info("Tx expired", "tx", TxID(memTx.tx), "height", memTx.Height(), "timestamp", memTx.timestamp)
+			// NOTE: we remove tx from the cache so it can be sent again
+			mem.removeTx(memTx.tx, e, true)
+			mem.metrics.ExpiredTxs.Add(1)
+			if err := mem.eventBus.PublishEventTxExpired(types.EventDataTxExpired{Tx: memTx.tx, Height: height}); err != nil {
+				mem.logger.Error("Error publishing tx expired event", "err", err)
+			}
+			expired = append(expired, memTx.tx)
+			continue
+		}
+		txsLeft = append(txsLeft, memTx.tx)
+	}
+	return txsLeft, expired
+}
+
 // NOTE: pass in txs because mem.txs can mutate concurrently.
 func (mem *Storage) recheckTxs(txs []types.Tx) {
 	if len(txs) == 0 {
@@ -762,13 +1070,29 @@ func (mem *Storage) recheckTxs(txs []types.Tx) {
 
 // storageTx is a transaction that successfully ran
 type storageTx struct {
-	height    int64    // height that this tx had been validated in
-	gasWanted int64    // amount of gas this tx states it will require
-	tx        types.Tx //
+	height    int64     // height that this tx had been validated in
+	gasWanted int64     // amount of gas this tx states it will require
+	priority  int64     // priority given by the application, for reaping and eviction
+	timestamp time.Time // time this tx was added to the storage
+	tx        types.Tx  //
+	walIndex  int       // index of the WAL file this tx was written to
 
 	// ids of peers who've sent us this tx (as a map for quick lookups).
 	// senders: PeerID -> bool
 	senders sync.Map
+
+	// peers who've sent us this tx, to detect the ones resending it.
+	// p2pSenders: p2p.ID -> bool
+	p2pSenders sync.Map
+
+	// peers this tx was pushed or announced to, so it is not sent again when
+	// the broadcast routines restart from the front of the storage.
+	// sentTo: p2p.ID -> bool
+	sentTo sync.Map
+
+	// peers this tx was announced to, and which didn't request it yet.
+	// announced: p2p.ID -> bool
+	announced sync.Map
 }
 
 // Height returns the height for this transaction
@@ -782,6 +1106,7 @@ type txCache interface {
 	Reset()
 	Push(tx types.Tx) bool
 	Remove(tx types.Tx)
+	Has(key [sha256.Size]byte) bool
 }
 
 // mapTxCache maintains a LRU cache of transactions. This only stores the hash
@@ -851,10 +1176,20 @@ func (cache *mapTxCache) Remove(tx types.Tx) {
 	cache.mtx.Unlock()
 }
 
+// Has returns true if the tx with the given txKey is in the cache.
+func (cache *mapTxCache) Has(key [sha256.Size]byte) bool {
+	cache.mtx.Lock()
+	defer cache.mtx.Unlock()
+
+	_, exists := cache.map_[key]
+	return exists
+}
+
 type nopTxCache struct{}
 
 var _ txCache = (*nopTxCache)(nil)
 
-func (nopTxCache) Reset()             {}
-func (nopTxCache) Push(types.Tx) bool { return true }
-func (nopTxCache) Remove(types.Tx)    {}
+func (nopTxCache) Reset()                     {}
+func (nopTxCache) Push(types.Tx) bool         { return true }
+func (nopTxCache) Remove(types.Tx)            {}
+func (nopTxCache) Has([sha256.Size]byte) bool { return false }
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/binary"
@@ -21,6 +22,7 @@ import (
 	"github.com/teragrid/dgrid/asura/example/kvstore"
 	asuraserver "github.com/teragrid/dgrid/asura/server"
 	asura "github.com/teragrid/dgrid/asura/types"
+	"github.com/teragrid/dgrid/core/blockchain/p2p"
 	cfg "github.com/teragrid/dgrid/core/config"
 	cmn "github.com/teragrid/dgrid/pkg/common"
 	"github.com/teragrid/dgrid/pkg/log"
@@ -177,6 +179,27 @@ func TestStorageFilters(t *testing.T) {
 	}
 }
 
+func TestStorageTxFilter(t *testing.T) {
+	app := kvstore.NewKVStoreApplication()
+	cc := proxy.NewLocalClientCreator(app)
+	storage, cleanup := newStorageWithApp(cc)
+	defer cleanup()
+
+	storage.AddTxFilter(func(tx types.Tx) error {
+		if tx[0] == 0x01 {
+			return fmt.Errorf("Filtered tx %X", tx)
+		}
+		return nil
+	})
+	assert.True(t, IsPreCheckError(storage.CheckTx(types.Tx{0x01}, nil)))
+	require.NoError(t, storage.CheckTx(types.Tx{0x02}, nil))
+
+	// the filter is kept when the pre check is replaced
+	storage.Update(1, []types.Tx{{0x02}}, PreCheckAminoMaxBytes(100), nil)
+	assert.True(t, IsPreCheckError(storage.CheckTx(types.Tx{0x01, 0x01}, nil)))
+	require.NoError(t, storage.CheckTx(types.Tx{0x03}, nil))
+}
+
 func TestStorageUpdateAddsTxsToCache(t *testing.T) {
 	app := kvstore.NewKVStoreApplication()
 	cc := proxy.NewLocalClientCreator(app)
@@ -189,6 +212,36 @@ func TestStorageUpdateAddsTxsToCache(t *testing.T) {
 	}
 }
 
+func TestStorageTxResent(t *testing.T) {
+	app := kvstore.NewKVStoreApplication()
+	cc := proxy.NewLocalClientCreator(app)
+	storage, cleanup := newStorageWithApp(cc)
+	defer cleanup()
+
+	tx := types.Tx{0x01}
+	require.NoError(t, storage.CheckTxWithInfo(tx, nil, TxInfo{PeerID: 1, SenderP2PID: "a"}))
+	assert.Equal(t, ErrTxInCache, storage.CheckTxWithInfo(tx, nil, TxInfo{PeerID: 2, SenderP2PID: "b"}))
+	assert.Equal(t, ErrTxResent, storage.CheckTxWithInfo(tx, nil, TxInfo{PeerID: 1, SenderP2PID: "a"}))
+	assert.Equal(t, ErrTxInCache, storage.CheckTx(tx, nil))
+	assert.Equal(t, ErrTxInCache, storage.CheckTx(tx, nil))
+
+	// a new peer reusing the ID of a peer didn't resend its txs
+	assert.Equal(t, ErrTxInCache, storage.CheckTxWithInfo(tx, nil, TxInfo{PeerID: 1, SenderP2PID: "c"}))
+
+	// a reconnected peer gets the txs again, and may send them again
+	memTx, ok := storage.txByKey(txKey(tx))
+	require.True(t, ok)
+	memTx.sentTo.Store(p2p.ID("a"), true)
+	memTx.announced.Store(p2p.ID("a"), true)
+	storage.removePeer("a")
+	_, sent := memTx.sentTo.Load(p2p.ID("a"))
+	assert.False(t, sent)
+	_, announced := memTx.announced.Load(p2p.ID("a"))
+	assert.False(t, announced)
+	assert.Equal(t, ErrTxInCache, storage.CheckTxWithInfo(tx, nil, TxInfo{PeerID: 1, SenderP2PID: "a"}))
+	assert.Equal(t, ErrTxResent, storage.CheckTxWithInfo(tx, nil, TxInfo{PeerID: 1, SenderP2PID: "a"}))
+}
+
 func TestTxsAvailable(t *testing.T) {
 	app := kvstore.NewKVStoreApplication()
 	cc := proxy.NewLocalClientCreator(app)
@@ -372,11 +425,11 @@ func TestStorageCloseWAL(t *testing.T) {
 
 	// 5. Write some contents to the WAL
 	storage.CheckTx(types.Tx([]byte("foo")), nil)
-	walFilepath := storage.wal.Path
+	walFilepath := storage.wal.group.Head.Path
 	sum1 := checksumFile(walFilepath, t)
 
 	// 6. Sanity check to ensure that the written TX matches the expectation.
-	require.Equal(t, sum1, checksumIt([]byte("foo\n")), "foo with a newline should be written")
+	require.Equal(t, sum1, checksumIt(encodeWALTx([]byte("foo"))), "encoded foo should be written")
 
 	// 7. Invoke CloseWAL() and ensure it discards the
 	// WAL thus any other write won't go through.
@@ -474,10 +527,17 @@ func TestStorageTxsBytes(t *testing.T) {
 	storage.Flush()
 	assert.EqualValues(t, 0, storage.TxsBytes())
 
-	// 5. ErrStorageIsFull is returned when/if MaxTxsBytes limit is reached.
+	// 5. a tx is rejected when/if MaxTxsBytes limit is reached, and
+	// ErrStorageIsFull is returned if it can't fit at all.
 	err = storage.CheckTx([]byte{0x04, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04}, nil)
 	require.NoError(t, err)
-	err = storage.CheckTx([]byte{0x05}, nil)
+	err = storage.CheckTx([]byte{0x05}, func(res *asura.Response) {
+		assert.Equal(t, CodeTypeStorageIsFull, res.GetCheckTx().Code)
+		assert.Equal(t, CodespaceStorage, res.GetCheckTx().Codespace)
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, 10, storage.TxsBytes())
+	err = storage.CheckTx(make([]byte, 11), nil)
 	if assert.Error(t, err) {
 		assert.IsType(t, ErrStorageIsFull{}, err)
 	}
@@ -512,6 +572,135 @@ func TestStorageTxsBytes(t *testing.T) {
 	assert.EqualValues(t, 0, storage.TxsBytes())
 }
 
+// priorityApp accepts all the txs, with the first byte of a tx as its
+// priority.
+type priorityApp struct {
+	asura.BaseApplication
+}
+
+func (priorityApp) CheckTx(tx []byte) asura.ResponseCheckTx {
+	return asura.ResponseCheckTx{Code: asura.CodeTypeOK, Priority: int64(tx[0])}
+}
+
+func TestStoragePriority(t *testing.T) {
+	cc := proxy.NewLocalClientCreator(priorityApp{})
+	config := cfg.ResetTestRoot("storage_test")
+	config.Storage.Size = 3
+	config.Storage.MaxTxsBytes = 8
+	storage, cleanup := newStorageWithAppAndConfig(cc, config)
+	defer cleanup()
+
+	checkTx := func(tx types.Tx) (code uint32) {
+		err := storage.CheckTx(tx, func(res *asura.Response) {
+			code = res.GetCheckTx().Code
+		})
+		require.NoError(t, err)
+		return code
+	}
+
+	// txs are reaped by decreasing priority, and in order of arrival for
+	// the same priority
+	require.EqualValues(t, asura.CodeTypeOK, checkTx(types.Tx{1, 0}))
+	require.EqualValues(t, asura.CodeTypeOK, checkTx(types.Tx{3, 0}))
+	require.EqualValues(t, asura.CodeTypeOK, checkTx(types.Tx{1, 1}))
+	assert.Equal(t, types.Txs{{3, 0}, {1, 0}, {1, 1}}, storage.ReapMaxBytesMaxGas(-1, -1))
+	// each tx has 2 bytes + amino overhead = 4 bytes
+	assert.Equal(t, types.Txs{{3, 0}, {1, 0}}, storage.ReapMaxBytesMaxGas(8, -1))
+	assert.Equal(t, types.Txs{{3, 0}, {1, 0}, {1, 1}}, storage.ReapMaxTxs(-1))
+
+	// a full storage rejects the txs of lower or equal priority
+	assert.Equal(t, CodeTypeStorageIsFull, checkTx(types.Tx{0, 0}))
+	assert.Equal(t, CodeTypeStorageIsFull, checkTx(types.Tx{1, 2}))
+	assert.Equal(t, 3, storage.Size())
+
+	// and evicts the last txs of lowest priority for the others
+	require.EqualValues(t, asura.CodeTypeOK, checkTx(types.Tx{2, 0}))
+	assert.Equal(t, types.Txs{{3, 0}, {2, 0}, {1, 0}}, storage.ReapMaxBytesMaxGas(-1, -1))
+
+	// as many as needed to fit
+	require.EqualValues(t, asura.CodeTypeOK, checkTx(types.Tx{4, 0, 0, 0, 0, 0}))
+	assert.Equal(t, types.Txs{{4, 0, 0, 0, 0, 0}, {3, 0}}, storage.ReapMaxBytesMaxGas(-1, -1))
+	assert.EqualValues(t, 8, storage.TxsBytes())
+
+	// unless the txs of lower priority don't make enough room
+	assert.Equal(t, CodeTypeStorageIsFull, checkTx(types.Tx{3, 1}))
+	assert.Equal(t, types.Txs{{4, 0, 0, 0, 0, 0}, {3, 0}}, storage.ReapMaxBytesMaxGas(-1, -1))
+
+	// an evicted tx can be sent again
+	storage.Update(1, types.Txs{{4, 0, 0, 0, 0, 0}, {3, 0}}, nil, nil)
+	require.EqualValues(t, asura.CodeTypeOK, checkTx(types.Tx{2, 0}))
+	assert.Equal(t, types.Txs{{2, 0}}, storage.ReapMaxBytesMaxGas(-1, -1))
+}
+
+func TestStorageFullCheckTxsRate(t *testing.T) {
+	cc := proxy.NewLocalClientCreator(priorityApp{})
+	config := cfg.ResetTestRoot("storage_test")
+	config.Storage.Size = 1
+	config.Storage.FullMaxCheckTxsPerSec = 1
+	storage, cleanup := newStorageWithAppAndConfig(cc, config)
+	defer cleanup()
+
+	require.NoError(t, storage.CheckTx(types.Tx{1, 0}, nil))
+	require.NoError(t, storage.CheckTx(types.Tx{2, 0}, nil))
+	assert.Equal(t, types.Txs{{2, 0}}, storage.ReapMaxTxs(-1))
+
+	// a full storage only checks the txs at the rate of its limit
+	_, ok := storage.CheckTx(types.Tx{3, 0}, nil).(ErrStorageIsFull)
+	assert.True(t, ok)
+	assert.Equal(t, types.Txs{{2, 0}}, storage.ReapMaxTxs(-1))
+
+	// the rejected tx can be sent again
+	storage.fullCheckTxs.put(1)
+	require.NoError(t, storage.CheckTx(types.Tx{3, 0}, nil))
+	assert.Equal(t, types.Txs{{3, 0}}, storage.ReapMaxTxs(-1))
+}
+
+func TestStorageTTL(t *testing.T) {
+	app := kvstore.NewKVStoreApplication()
+	cc := proxy.NewLocalClientCreator(app)
+	config := cfg.ResetTestRoot("storage_test")
+	config.Storage.TTLNumBlocks = 2
+	storage, cleanup := newStorageWithAppAndConfig(cc, config)
+	defer cleanup()
+
+	eventBus := types.NewEventBus()
+	require.NoError(t, eventBus.Start())
+	defer eventBus.Stop()
+	WithEventBus(eventBus)(storage)
+	sub, err := eventBus.Subscribe(context.Background(), "test", types.EventQueryTxExpired)
+	require.NoError(t, err)
+
+	// txs expire after TTLNumBlocks blocks
+	require.NoError(t, storage.CheckTx(types.Tx{0x01}, nil))
+	storage.Update(1, nil, nil, nil)
+	require.NoError(t, storage.CheckTx(types.Tx{0x02}, nil))
+	storage.Update(2, nil, nil, nil)
+	assert.Equal(t, 2, storage.Size())
+	storage.Update(3, nil, nil, nil)
+	assert.Equal(t, types.Txs{{0x02}}, storage.ReapMaxTxs(-1))
+
+	select {
+	case msg := <-sub.Out():
+		edt := msg.Data().(types.EventDataTxExpired)
+		assert.Equal(t, types.Tx{0x01}, edt.Tx)
+		assert.EqualValues(t, 3, edt.Height)
+	case <-time.After(time.Second):
+		t.Fatal("did not receive the expired tx after 1 sec.")
+	}
+
+	// an expired tx can be sent again
+	require.NoError(t, storage.CheckTx(types.Tx{0x01}, nil))
+	assert.Equal(t, 2, storage.Size())
+
+	// and after TTLDuration
+	storage.config.TTLNumBlocks = 0
+	storage.config.TTLDuration = 100 * time.Millisecond
+	time.Sleep(200 * time.Millisecond)
+	require.NoError(t, storage.CheckTx(types.Tx{0x03}, nil))
+	storage.Update(4, nil, nil, nil)
+	assert.Equal(t, types.Txs{{0x03}}, storage.ReapMaxTxs(-1))
+}
+
 // This will non-deterministically catch some concurrency failures like
 // https://github.com/teragrid/dgrid/core/issues/3509
 // TODO: all of the tests should probably also run using the remote proxy app
@@ -0,0 +1,269 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/teragrid/dgrid/core/types"
+	auto "github.com/teragrid/dgrid/pkg/autofile"
+)
+
+// The storage WAL is a sequence of txs and markers, each one written as:
+// 4 bytes CRC sum + 4 bytes length + the raw tx, or the marker. The length
+// of a marker has the walMarkerFlag bit set. A marker is an 8 bytes height,
+// followed by the keys of the txs the height removed from the storage.
+
+const walMarkerFlag = 1 << 31
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// maxWALMarkerKeys is the maximum number of keys of a marker, so that it is
+// not larger than a tx. The keys of a height are split in several markers
+// if need be.
+var maxWALMarkerKeys = (maxTxSize - 8) / sha256.Size
+
+// walMarker ends a height in the WAL. The txs it removed from the storage
+// are not replayed.
+type walMarker struct {
+	height  int64
+	removed [][sha256.Size]byte
+}
+
+// encodeWALTx returns the WAL encoding of tx.
+func encodeWALTx(tx types.Tx) []byte {
+	return encodeWALEntry(tx, 0)
+}
+
+// encodeWALMarker returns the WAL encoding of m.
+func encodeWALMarker(m walMarker) []byte {
+	data := make([]byte, 8, 8+len(m.removed)*sha256.Size)
+	binary.BigEndian.PutUint64(data, uint64(m.height))
+	for _, key := range m.removed {
+		data = append(data, key[:]...)
+	}
+	return encodeWALEntry(data, walMarkerFlag)
+}
+
+func encodeWALEntry(data []byte, flags uint32) []byte {
+	msg := make([]byte, 8+len(data))
+	binary.BigEndian.PutUint32(msg[0:4], crc32.Checksum(data, crc32c))
+	binary.BigEndian.PutUint32(msg[4:8], uint32(len(data))|flags)
+	copy(msg[8:], data)
+	return msg
+}
+
+// encodeWALTxs returns the WAL encoding of txs.
+func encodeWALTxs(txs []types.Tx) []byte {
+	var buf bytes.Buffer
+	for _, tx := range txs {
+		buf.Write(encodeWALTx(tx))
+	}
+	return buf.Bytes()
+}
+
+// DataCorruptionError is returned for a tx of the WAL which can't be read:
+// it was torn by a crash, or altered.
+type DataCorruptionError struct {
+	cause error
+}
+
+func (e DataCorruptionError) Error() string {
+	return fmt.Sprintf("DataCorruptionError[%v]", e.cause)
+}
+
+// Cause returns the reason the tx can't be read.
+func (e DataCorruptionError) Cause() error {
+	return e.cause
+}
+
+// IsDataCorruptionError returns true if data has been corrupted inside WAL.
+func IsDataCorruptionError(err error) bool {
+	_, ok := err.(DataCorruptionError)
+	return ok
+}
+
+// walDecoder reads the txs and the markers written with encodeWALTx and
+// encodeWALMarker.
+type walDecoder struct {
+	rd io.Reader
+}
+
+func newWALDecoder(rd io.Reader) *walDecoder {
+	return &walDecoder{rd: rd}
+}
+
+// Decode reads the next tx or marker from its reader and returns it. It
+// returns io.EOF if there are no more.
+func (dec *walDecoder) Decode() (types.Tx, *walMarker, error) {
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(dec.rd, b); err == io.EOF {
+		return nil, nil, err
+	} else if err != nil {
+		return nil, nil, DataCorruptionError{fmt.Errorf("failed to read checksum: %v", err)}
+	}
+	crc := binary.BigEndian.Uint32(b)
+
+	if _, err := io.ReadFull(dec.rd, b); err != nil {
+		return nil, nil, DataCorruptionError{fmt.Errorf("failed to read length: %v", err)}
+	}
+	length := binary.BigEndian.Uint32(b)
+	isMarker := length&walMarkerFlag != 0
+	length &^= walMarkerFlag
+	if length > maxTxSize {
+		return nil, nil, DataCorruptionError{fmt.Errorf("length %d exceeded maximum possible value of %d bytes", length, maxTxSize)}
+	}
+
+	data := make([]byte, length)
+	if n, err := io.ReadFull(dec.rd, data); err != nil {
+		return nil, nil, DataCorruptionError{fmt.Errorf("failed to read tx: %v (read: %d, wanted: %d)", err, n, length)}
+	}
+
+	if actualCRC := crc32.Checksum(data, crc32c); actualCRC != crc {
+		return nil, nil, DataCorruptionError{fmt.Errorf("checksums do not match: read: %v, actual: %v", crc, actualCRC)}
+	}
+	if !isMarker {
+		return data, nil, nil
+	}
+
+	if length < 8 || (length-8)%sha256.Size != 0 {
+		return nil, nil, DataCorruptionError{fmt.Errorf("wrong marker length %d", length)}
+	}
+	m := &walMarker{height: int64(binary.BigEndian.Uint64(data[:8]))}
+	for i := 8; i < len(data); i += sha256.Size {
+		var key [sha256.Size]byte
+		copy(key[:], data[i:])
+		m.removed = append(m.removed, key)
+	}
+	return nil, m, nil
+}
+
+//--------------------------------------------------------------------------------
+
+// storageWAL is the WAL of the storage, in an autofile.Group: the txs are
+// written before they are checked, and a marker ends each height. The head
+// file is rotated when it exceeds its size limit, and the files before the
+// one of the oldest tx of the storage are removed at the end of each height,
+// so the WAL is only appended to.
+type storageWAL struct {
+	group *auto.Group
+}
+
+// openStorageWAL opens the WAL with the head file headPath.
+func openStorageWAL(headPath string) (*storageWAL, error) {
+	// the files are removed by the storage, see removeBefore
+	group, err := auto.OpenGroup(headPath, auto.GroupTotalSizeLimit(0))
+	if err != nil {
+		return nil, err
+	}
+	if err := group.Start(); err != nil {
+		return nil, err
+	}
+	return &storageWAL{group: group}, nil
+}
+
+// writeTx writes tx, and returns the index of the file it is written to, or
+// of an older one if the head is rotated meanwhile. tx is flushed to the
+// file, but not synced.
+func (wal *storageWAL) writeTx(tx types.Tx) (int, error) {
+	index := wal.group.MaxIndex()
+	if _, err := wal.group.Write(encodeWALTx(tx)); err != nil {
+		return index, err
+	}
+	return index, wal.group.Flush()
+}
+
+// writeMarker ends height, with the txs it removed from the storage, and
+// flushes the WAL.
+func (wal *storageWAL) writeMarker(height int64, removed []types.Tx) error {
+	m := walMarker{height: height}
+	for i, tx := range removed {
+		m.removed = append(m.removed, txKey(tx))
+		if len(m.removed) == maxWALMarkerKeys && i < len(removed)-1 {
+			if _, err := wal.group.Write(encodeWALMarker(m)); err != nil {
+				return err
+			}
+			m.removed = nil
+		}
+	}
+	if _, err := wal.group.Write(encodeWALMarker(m)); err != nil {
+		return err
+	}
+	return wal.group.FlushAndSync()
+}
+
+// headIndex returns the index of the head file.
+func (wal *storageWAL) headIndex() int {
+	return wal.group.MaxIndex()
+}
+
+// rotate starts a new head file, and returns its index.
+func (wal *storageWAL) rotate() int {
+	wal.group.RotateFile()
+	return wal.group.MaxIndex()
+}
+
+// removeBefore removes the files before index.
+func (wal *storageWAL) removeBefore(index int) error {
+	return wal.group.RemoveFilesBefore(index)
+}
+
+// pendingTxs returns the txs of the WAL which no marker removed, in the
+// order they were written. A torn or altered entry ends the WAL: the txs
+// read before are returned with the error.
+func (wal *storageWAL) pendingTxs() ([]types.Tx, error) {
+	if err := wal.group.FlushAndSync(); err != nil {
+		return nil, err
+	}
+	rd, err := wal.group.NewReader(wal.group.MinIndex())
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close() // nolint: errcheck
+
+	var keys [][sha256.Size]byte
+	pending := make(map[[sha256.Size]byte]types.Tx)
+	dec := newWALDecoder(rd)
+	for {
+		tx, m, err := dec.Decode()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return pendingInOrder(keys, pending), err
+		}
+		if m != nil {
+			for _, key := range m.removed {
+				delete(pending, key)
+			}
+			continue
+		}
+		key := txKey(tx)
+		if _, ok := pending[key]; !ok {
+			keys = append(keys, key)
+			pending[key] = tx
+		}
+	}
+	return pendingInOrder(keys, pending), nil
+}
+
+// pendingInOrder returns the txs of pending in the order of keys, once.
+func pendingInOrder(keys [][sha256.Size]byte, pending map[[sha256.Size]byte]types.Tx) []types.Tx {
+	txs := make([]types.Tx, 0, len(pending))
+	for _, key := range keys {
+		if tx, ok := pending[key]; ok {
+			txs = append(txs, tx)
+			delete(pending, key)
+		}
+	}
+	return txs
+}
+
+// close flushes and closes the WAL.
+func (wal *storageWAL) close() {
+	wal.group.Stop()
+	wal.group.Wait()
+	wal.group.Close()
+}
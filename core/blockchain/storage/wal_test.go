@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teragrid/dgrid/asura/example/kvstore"
+	asura "github.com/teragrid/dgrid/asura/types"
+	cfg "github.com/teragrid/dgrid/core/config"
+	"github.com/teragrid/dgrid/core/types"
+	"github.com/teragrid/dgrid/pkg/log"
+	"github.com/teragrid/dgrid/proxy"
+)
+
+func decodeWALTxs(t *testing.T, data []byte) []types.Tx {
+	var txs []types.Tx
+	dec := newWALDecoder(bytes.NewReader(data))
+	for {
+		tx, m, err := dec.Decode()
+		if err == io.EOF {
+			return txs
+		}
+		require.NoError(t, err)
+		require.Nil(t, m)
+		txs = append(txs, tx)
+	}
+}
+
+func TestWALDecoder(t *testing.T) {
+	txs := []types.Tx{[]byte("foo"), {}, []byte("bar\nbaz\n"), {0x00, 0x0a, 0xff}}
+	data := encodeWALTxs(txs)
+	assert.Equal(t, txs, decodeWALTxs(t, data))
+
+	// a torn tx
+	dec := newWALDecoder(bytes.NewReader(data[:len(data)-1]))
+	for range txs[:len(txs)-1] {
+		_, _, err := dec.Decode()
+		require.NoError(t, err)
+	}
+	_, _, err := dec.Decode()
+	assert.True(t, IsDataCorruptionError(err), "expected a DataCorruptionError, got %v", err)
+
+	// an altered tx
+	altered := append([]byte(nil), data...)
+	altered[10]++
+	_, _, err = newWALDecoder(bytes.NewReader(altered)).Decode()
+	assert.True(t, IsDataCorruptionError(err), "expected a DataCorruptionError, got %v", err)
+
+	// a marker
+	m := walMarker{height: 3, removed: [][sha256.Size]byte{txKey(txs[0]), txKey(txs[2])}}
+	tx, decoded, err := newWALDecoder(bytes.NewReader(encodeWALMarker(m))).Decode()
+	require.NoError(t, err)
+	assert.Nil(t, tx)
+	assert.Equal(t, &m, decoded)
+}
+
+func TestStorageReplayWAL(t *testing.T) {
+	rootDir, err := ioutil.TempDir("", "storage-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(rootDir)
+
+	wcfg := cfg.DefaultStorageConfig()
+	wcfg.RootDir = rootDir
+	wcfg.WalPath = "wal"
+	newStorage := func() *Storage {
+		appConnMem, _ := proxy.NewLocalClientCreator(kvstore.NewKVStoreApplication()).NewABCIClient()
+		require.NoError(t, appConnMem.Start())
+		storage := NewStorage(wcfg, appConnMem, 0)
+		storage.SetLogger(log.TestingLogger())
+		storage.InitWAL()
+		return storage
+	}
+
+	// the txs committed on Update are not pending anymore
+	storage := newStorage()
+	require.NoError(t, storage.CheckTx(types.Tx("foo"), nil))
+	require.NoError(t, storage.CheckTx(types.Tx("bar\n"), nil))
+	require.NoError(t, storage.Update(1, types.Txs{types.Tx("foo")}, nil, nil))
+	require.NoError(t, storage.CheckTx(types.Tx{0x00, 0x0a}, nil))
+	txs, err := storage.wal.pendingTxs()
+	require.NoError(t, err)
+	assert.Equal(t, []types.Tx{types.Tx("bar\n"), {0x00, 0x0a}}, txs)
+	storage.CloseWAL()
+
+	// the pending txs are added back to a new storage, and written to a new
+	// head file
+	storage = newStorage()
+	require.NoError(t, storage.ReplayWAL())
+	assert.Equal(t, types.Txs{types.Tx("bar\n"), {0x00, 0x0a}}, storage.ReapMaxTxs(-1))
+	assert.Equal(t, 1, storage.wal.group.MinIndex())
+	txs, err = storage.wal.pendingTxs()
+	require.NoError(t, err)
+	assert.Equal(t, []types.Tx{types.Tx("bar\n"), {0x00, 0x0a}}, txs)
+
+	// the files before the one of the oldest tx left are removed on Update
+	storage.wal.rotate()
+	require.NoError(t, storage.CheckTx(types.Tx("baz"), nil))
+	require.NoError(t, storage.Update(2, types.Txs{types.Tx("bar\n")}, nil, nil))
+	assert.Equal(t, 1, storage.wal.group.MinIndex())
+	require.NoError(t, storage.Update(3, types.Txs{{0x00, 0x0a}}, nil, nil))
+	assert.Equal(t, 2, storage.wal.group.MinIndex())
+	txs, err = storage.wal.pendingTxs()
+	require.NoError(t, err)
+	assert.Equal(t, []types.Tx{types.Tx("baz")}, txs)
+	storage.CloseWAL()
+}
+
+func TestStorageWALRemovedTxs(t *testing.T) {
+	rootDir, err := ioutil.TempDir("", "storage-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(rootDir)
+
+	wcfg := cfg.DefaultStorageConfig()
+	wcfg.RootDir = rootDir
+	wcfg.WalPath = "wal"
+	wcfg.Size = 2
+	wcfg.Recheck = true
+	appConnMem, _ := proxy.NewLocalClientCreator(priorityApp{}).NewABCIClient()
+	require.NoError(t, appConnMem.Start())
+	storage := NewStorage(wcfg, appConnMem, 0)
+	storage.SetLogger(log.TestingLogger())
+	storage.InitWAL()
+	defer storage.CloseWAL()
+
+	// the evicted txs are written to the next marker
+	require.NoError(t, storage.CheckTx(types.Tx{1, 0}, nil))
+	require.NoError(t, storage.CheckTx(types.Tx{1, 1}, nil))
+	require.NoError(t, storage.CheckTx(types.Tx{2, 0}, nil))
+	require.NoError(t, storage.Update(1, nil, nil, nil))
+	txs, err := storage.wal.pendingTxs()
+	require.NoError(t, err)
+	assert.Equal(t, []types.Tx{{1, 0}, {2, 0}}, txs)
+
+	// so are the txs invalidated by the recheck
+	postCheck := func(tx types.Tx, res *asura.ResponseCheckTx) error {
+		if tx[0] == 1 {
+			return errors.New("invalid")
+		}
+		return nil
+	}
+	require.NoError(t, storage.Update(2, nil, nil, postCheck))
+	assert.Equal(t, types.Txs{{2, 0}}, storage.ReapMaxTxs(-1))
+	require.NoError(t, storage.Update(3, nil, nil, nil))
+	txs, err = storage.wal.pendingTxs()
+	require.NoError(t, err)
+	assert.Equal(t, []types.Tx{{2, 0}}, txs)
+
+	// unless they were sent again meanwhile
+	require.NoError(t, storage.CheckTx(types.Tx{3, 0}, nil))
+	storage.walRemoveTx(types.Tx{3, 0})
+	require.NoError(t, storage.Update(4, nil, nil, nil))
+	txs, err = storage.wal.pendingTxs()
+	require.NoError(t, err)
+	assert.Equal(t, []types.Tx{{2, 0}, {3, 0}}, txs)
+}
@@ -0,0 +1,113 @@
+package checkpoint
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/teragrid/dgrid/core/types"
+)
+
+// Checkpoint is a header of a Regular league, with the commit of its
+// validators, anchored into the Base league. Once anchored, a header of the
+// league at the same height with another hash is a fork of the league.
+type Checkpoint struct {
+	Header types.Header  `json:"header"`
+	Commit *types.Commit `json:"commit"`
+	// Validators which signed the commit. Their hash is the ValidatorsHash
+	// of the header.
+	Validators *types.ValidatorSet `json:"validators"`
+}
+
+// NewCheckpoint returns the checkpoint of a header, committed by commit.
+func NewCheckpoint(header types.Header, commit *types.Commit, vals *types.ValidatorSet) *Checkpoint {
+	return &Checkpoint{
+		Header:     header,
+		Commit:     commit,
+		Validators: vals,
+	}
+}
+
+// LeagueID returns the league of the checkpoint.
+func (cp *Checkpoint) LeagueID() string {
+	return cp.Header.LeagueID
+}
+
+// Height returns the height of the checkpoint.
+func (cp *Checkpoint) Height() int64 {
+	return cp.Header.Height
+}
+
+// Hash returns the hash of the block anchored.
+func (cp *Checkpoint) Hash() []byte {
+	return cp.Header.Hash()
+}
+
+// ValidateBasic performs basic validation.
+func (cp *Checkpoint) ValidateBasic() error {
+	if cp.Header.LeagueID == "" {
+		return errors.New("Empty league ID")
+	}
+	if cp.Header.Height <= 0 {
+		return errors.New("Non-positive height")
+	}
+	if cp.Commit == nil {
+		return errors.New("Commit is missing")
+	}
+	if err := cp.Commit.ValidateBasic(); err != nil {
+		return fmt.Errorf("Wrong commit: %v", err)
+	}
+	if cp.Commit.Height() != cp.Header.Height {
+		return fmt.Errorf("Commit of height %d for a header of height %d", cp.Commit.Height(), cp.Header.Height)
+	}
+	if !bytes.Equal(cp.Commit.BlockID.Hash, cp.Hash()) {
+		return fmt.Errorf("Commit of block %X for header %X", cp.Commit.BlockID.Hash, cp.Hash())
+	}
+	if cp.Validators.IsNilOrEmpty() {
+		return errors.New("Validators are missing")
+	}
+	if !bytes.Equal(cp.Validators.Hash(), cp.Header.ValidatorsHash) {
+		return fmt.Errorf("Validators hash %X doesn't match the header's %X", cp.Validators.Hash(), cp.Header.ValidatorsHash)
+	}
+	return nil
+}
+
+// Verify checks the commit of the checkpoint against the validators trusted
+// in the league: validators holding more than 2/3 of the voting power of
+// trusted must have signed it. If the validators changed since, they must
+// also hold more than 2/3 of the voting power of the new validators.
+func (cp *Checkpoint) Verify(trusted *types.ValidatorSet) error {
+	if err := cp.ValidateBasic(); err != nil {
+		return err
+	}
+	if trusted.IsNilOrEmpty() {
+		return errors.New("No trusted validators")
+	}
+	if bytes.Equal(trusted.Hash(), cp.Validators.Hash()) {
+		return trusted.VerifyCommit(cp.LeagueID(), cp.Commit.BlockID, cp.Height(), cp.Commit)
+	}
+	return trusted.VerifyFutureCommit(cp.Validators, cp.LeagueID(), cp.Commit.BlockID, cp.Height(), cp.Commit)
+}
+
+// VerifyNext checks the commit of the checkpoint against trusted, the
+// latest checkpoint anchored for the league. The validators of the height
+// after trusted are committed by its header, so a checkpoint signed by more
+// than 2/3 of them is verified even if they differ from the validators of
+// trusted. Checkpoints are submitted at every change of the validators, so
+// the chain of trust follows the changes; otherwise Verify applies with the
+// validators of trusted.
+func (cp *Checkpoint) VerifyNext(trusted *Checkpoint) error {
+	if err := cp.ValidateBasic(); err != nil {
+		return err
+	}
+	if len(trusted.Header.NextValidatorsHash) > 0 &&
+		bytes.Equal(trusted.Header.NextValidatorsHash, cp.Header.ValidatorsHash) {
+		return cp.Validators.VerifyCommit(cp.LeagueID(), cp.Commit.BlockID, cp.Height(), cp.Commit)
+	}
+	return cp.Verify(trusted.Validators)
+}
+
+// String returns a string representation of the checkpoint.
+func (cp *Checkpoint) String() string {
+	return fmt.Sprintf("Checkpoint{%s #%d %X}", cp.LeagueID(), cp.Height(), cp.Hash())
+}
@@ -0,0 +1,149 @@
+package checkpoint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teragrid/dgrid/core/types"
+	"github.com/teragrid/dgrid/pkg/crypto/tmhash"
+)
+
+// makeValidators returns n validators, sorted as in their validator set.
+func makeValidators(n int) ([]*types.MockPV, *types.ValidatorSet) {
+	pvs := make([]*types.MockPV, n)
+	vals := make([]*types.Validator, n)
+	for i := range pvs {
+		pvs[i] = types.NewMockPV()
+		vals[i] = types.NewValidator(pvs[i].GetPubKey(), 10)
+	}
+	valSet := types.NewValidatorSet(vals)
+	sorted := make([]*types.MockPV, n)
+	for _, pv := range pvs {
+		idx, _ := valSet.GetByAddress(pv.GetPubKey().Address())
+		sorted[idx] = pv
+	}
+	return sorted, valSet
+}
+
+// makeCheckpoint returns the checkpoint of a header signed by pvs, the
+// validators of vals, which must be sorted as in vals.
+func makeCheckpoint(t *testing.T, leagueID string, height int64, appHash string,
+	pvs []*types.MockPV, vals *types.ValidatorSet) *Checkpoint {
+	return makeCheckpointNext(t, leagueID, height, appHash, pvs, vals, vals)
+}
+
+// makeCheckpointNext returns the checkpoint of a header after which the
+// validators change to nextVals.
+func makeCheckpointNext(t *testing.T, leagueID string, height int64, appHash string,
+	pvs []*types.MockPV, vals, nextVals *types.ValidatorSet) *Checkpoint {
+	header := types.Header{
+		LeagueID:           leagueID,
+		Height:             height,
+		AppHash:            []byte(appHash),
+		ValidatorsHash:     vals.Hash(),
+		NextValidatorsHash: nextVals.Hash(),
+	}
+	blockID := types.BlockID{
+		Hash:        header.Hash(),
+		PartsHeader: types.PartSetHeader{Total: 1, Hash: tmhash.Sum([]byte(appHash))},
+	}
+	signers := make([]types.Validator, len(pvs))
+	for i, pv := range pvs {
+		signers[i] = pv
+	}
+	voteSet := types.NewVoteSet(leagueID, height, 0, types.PrecommitType, vals)
+	commit, err := types.MakeCommit(blockID, height, 0, voteSet, signers)
+	require.NoError(t, err)
+	return NewCheckpoint(header, commit, vals)
+}
+
+func TestCheckpointVerify(t *testing.T) {
+	pvs, vals := makeValidators(4)
+	cp := makeCheckpoint(t, "regular", 100, "app", pvs, vals)
+	require.NoError(t, cp.ValidateBasic())
+	assert.NoError(t, cp.Verify(vals))
+	assert.Error(t, cp.Verify(nil))
+
+	// signed by other validators
+	_, others := makeValidators(4)
+	assert.Error(t, cp.Verify(others))
+
+	// the header doesn't match the commit, or the validators
+	forged := *cp
+	forged.Header.AppHash = []byte("forged")
+	assert.Error(t, forged.ValidateBasic())
+	forged = *cp
+	forged.Validators = others
+	assert.Error(t, forged.ValidateBasic())
+
+	// the validators changed, but 3 of the 4 trusted ones signed
+	newPV := types.NewMockPV()
+	newVals := vals.Copy()
+	require.NoError(t, newVals.UpdateWithChangeSet([]*types.Validator{
+		types.NewValidator(newPV.GetPubKey(), 10),
+	}))
+	newPVs := make([]*types.MockPV, newVals.Size())
+	for _, pv := range append(pvs, newPV) {
+		idx, _ := newVals.GetByAddress(pv.GetPubKey().Address())
+		newPVs[idx] = pv
+	}
+	assert.NoError(t, makeCheckpoint(t, "regular", 200, "app", newPVs, newVals).Verify(vals))
+	// but not by the validators of another league
+	assert.Error(t, makeCheckpoint(t, "regular", 200, "app", newPVs, newVals).Verify(others))
+}
+
+func TestCheckpointVerifyNext(t *testing.T) {
+	pvs, vals := makeValidators(4)
+	newPVs, newVals := makeValidators(4)
+
+	// the trusted header commits to the new validators, which replace all
+	// the trusted ones
+	trusted := makeCheckpointNext(t, "regular", 100, "app", pvs, vals, newVals)
+	cp := makeCheckpoint(t, "regular", 150, "app", newPVs, newVals)
+	assert.Error(t, cp.Verify(trusted.Validators))
+	assert.NoError(t, cp.VerifyNext(trusted))
+
+	// without the change, the trusted validators must sign
+	assert.Error(t, cp.VerifyNext(makeCheckpoint(t, "regular", 100, "app", pvs, vals)))
+	assert.NoError(t, makeCheckpoint(t, "regular", 150, "app", pvs, vals).VerifyNext(trusted))
+
+	// other validators than the committed ones
+	_, others := makeValidators(4)
+	forged := *cp
+	forged.Validators = others
+	assert.Error(t, forged.VerifyNext(trusted))
+}
+
+func TestCheckpointTx(t *testing.T) {
+	pvs, vals := makeValidators(1)
+	cp := makeCheckpoint(t, "regular", 100, "app", pvs, vals)
+
+	tx, err := EncodeTx(&AnchorTx{Checkpoint: *cp})
+	require.NoError(t, err)
+	assert.True(t, IsCheckpointTx(tx))
+	ctx, err := DecodeTx(tx)
+	require.NoError(t, err)
+	require.IsType(t, &AnchorTx{}, ctx)
+	decoded := ctx.(*AnchorTx).Checkpoint
+	assert.Equal(t, cp.Hash(), decoded.Hash())
+	assert.NoError(t, decoded.Verify(vals))
+
+	tx, err = EncodeTx(&RegistrationTx{Registration: *NewRegistration("regular", vals)})
+	require.NoError(t, err)
+	ctx, err = DecodeTx(tx)
+	require.NoError(t, err)
+	require.IsType(t, &RegistrationTx{}, ctx)
+	assert.Equal(t, vals.Hash(), ctx.(*RegistrationTx).Registration.Validators.Hash())
+
+	// invalid txs are rejected
+	tx, err = EncodeTx(&RegistrationTx{Registration: *NewRegistration("regular", nil)})
+	require.NoError(t, err)
+	_, err = DecodeTx(tx)
+	assert.Error(t, err)
+
+	_, err = DecodeTx(types.Tx("app tx"))
+	assert.Error(t, err)
+	assert.False(t, IsCheckpointTx(types.Tx("app tx")))
+}
@@ -0,0 +1,315 @@
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/teragrid/dgrid/core/baseleague"
+	"github.com/teragrid/dgrid/core/types"
+	cmn "github.com/teragrid/dgrid/pkg/common"
+)
+
+const subscriber = "CheckpointManager"
+
+// DefaultInterval is the number of heights of a Regular league between two
+// checkpoints.
+const DefaultInterval = 100
+
+// ValidatorsLoader returns the validators of a league at a height.
+type ValidatorsLoader func(height int64) (*types.ValidatorSet, error)
+
+// LeagueBlockStore loads the headers of a league and their commits, to
+// submit the checkpoints due while the manager was not running. It is
+// implemented by the block store.
+type LeagueBlockStore interface {
+	Height() int64
+	LoadBlockMeta(height int64) *types.BlockMeta
+	LoadBlockCommit(height int64) *types.Commit
+}
+
+// anchoredLeague is a Regular league run by the cell, whose headers are
+// submitted as checkpoints.
+type anchoredLeague struct {
+	eventBus          types.EventBusSubscriber
+	blockStore        LeagueBlockStore
+	genesisValidators *types.ValidatorSet
+	loadValidators    ValidatorsLoader
+}
+
+// Manager anchors the history of the Regular leagues into the Base league.
+// The cells of a Regular league register its genesis validators, then
+// submit a checkpoint of its header every interval heights, and at every
+// change of its validators so that the validators of each checkpoint verify
+// the next one, as transactions of the Base league. Every cell of the Base
+// league verifies the checkpoints of its blocks against the registered
+// validators of the Regular league, and indexes them in its Store: a header
+// of the league conflicting with an anchored checkpoint is a fork. The Manager
+// checks the blocks of the leagues it tracks against the checkpoints, and
+// reports the forks.
+type Manager struct {
+	cmn.BaseService
+	// submits the checkpoint transactions
+	baseleague.TxSubmitter
+
+	follower *baseleague.Follower
+	store    *Store
+
+	mtx      sync.Mutex
+	leagues  map[string]*anchoredLeague
+	interval int64
+}
+
+// NewManager returns a new Manager processing the checkpoint transactions
+// of the blocks published on the event bus of the Base league, and of the
+// blocks of baseBlockStore it didn't process yet.
+func NewManager(baseEventBus types.EventBusSubscriber, baseBlockStore baseleague.BlockStore, store *Store) *Manager {
+	m := &Manager{
+		store:    store,
+		leagues:  make(map[string]*anchoredLeague),
+		interval: DefaultInterval,
+	}
+	m.follower = baseleague.NewFollower(subscriber, baseEventBus, baseBlockStore,
+		store.LastHeight, m.processBaseBlock)
+	m.BaseService = *cmn.NewBaseService(nil, "CheckpointManager", m)
+	return m
+}
+
+// Store returns the store of the anchored checkpoints.
+func (m *Manager) Store() *Store {
+	return m.store
+}
+
+// SetInterval sets the number of heights between two checkpoints.
+func (m *Manager) SetInterval(interval int64) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.interval = interval
+}
+
+// OnStart implements cmn.Service by processing the blocks of the Base league.
+// The blocks committed since the last one processed, eg. while the cell was
+// down, are replayed from the block store first.
+func (m *Manager) OnStart() error {
+	m.follower.SetLogger(m.Logger)
+	return m.follower.Start()
+}
+
+// OnStop implements cmn.Service.
+func (m *Manager) OnStop() {
+	m.follower.Stop()
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for leagueID := range m.leagues {
+		m.untrackLeague(leagueID)
+	}
+}
+
+// TrackLeague starts submitting the checkpoints of a Regular league run by
+// the cell, and checking its blocks against the anchored checkpoints. The
+// league is registered with genesisValidators if it is not, and the
+// checkpoints due since the latest anchored one are submitted first.
+func (m *Manager) TrackLeague(leagueID string, eventBus types.EventBusSubscriber,
+	blockStore LeagueBlockStore, genesisValidators *types.ValidatorSet, loadValidators ValidatorsLoader) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if _, ok := m.leagues[leagueID]; ok {
+		return fmt.Errorf("League %s is already tracked", leagueID)
+	}
+	sub, err := eventBus.Subscribe(context.Background(), baseleague.LeagueSubscriber(subscriber, leagueID),
+		types.EventQueryNewBlock)
+	if err != nil {
+		return err
+	}
+	al := &anchoredLeague{
+		eventBus:          eventBus,
+		blockStore:        blockStore,
+		genesisValidators: genesisValidators,
+		loadValidators:    loadValidators,
+	}
+	m.leagues[leagueID] = al
+	go func() {
+		m.catchUpLeague(leagueID, al)
+		baseleague.BlockRoutine(sub, m.Quit(), m.Logger.With("league", leagueID), func(block *types.Block) {
+			m.applyLeagueBlock(leagueID, block)
+		})
+	}()
+	return nil
+}
+
+// UntrackLeague stops submitting the checkpoints of a league.
+func (m *Manager) UntrackLeague(leagueID string) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.untrackLeague(leagueID)
+}
+
+func (m *Manager) untrackLeague(leagueID string) {
+	al, ok := m.leagues[leagueID]
+	if !ok {
+		return
+	}
+	al.eventBus.UnsubscribeAll(context.Background(), baseleague.LeagueSubscriber(subscriber, leagueID))
+	delete(m.leagues, leagueID)
+}
+
+// CheckTx rejects the checkpoint transactions which would have no effect in
+// the Base league, see Store.CheckTx, and the registrations of the leagues
+// tracked with other validators than their genesis ones. The other
+// transactions are accepted.
+func (m *Manager) CheckTx(tx types.Tx) error {
+	if !IsCheckpointTx(tx) {
+		return nil
+	}
+	ctx, err := DecodeTx(tx)
+	if err != nil {
+		return err
+	}
+	if rtx, ok := ctx.(*RegistrationTx); ok {
+		reg := &rtx.Registration
+		m.mtx.Lock()
+		al, tracked := m.leagues[reg.LeagueID]
+		m.mtx.Unlock()
+		if tracked && !bytes.Equal(reg.Validators.Hash(), al.genesisValidators.Hash()) {
+			return fmt.Errorf("%v conflicts with the genesis validators of the league", reg)
+		}
+	}
+	return m.store.CheckTx(tx)
+}
+
+// processBaseBlock anchors the registrations and checkpoints of a block of
+// the Base league.
+func (m *Manager) processBaseBlock(block *types.Block) {
+	res := m.store.ApplyBlock(block)
+	for _, reg := range res.Registered {
+		m.Logger.Info("Registered league", "registration", reg, "baseHeight", block.Height)
+	}
+	for _, cp := range res.Anchored {
+		m.Logger.Info("Anchored checkpoint", "checkpoint", cp, "baseHeight", block.Height)
+	}
+	for _, cp := range res.Forks {
+		m.Logger.Error("League fork: checkpoint conflicts with the anchored one",
+			"checkpoint", cp, "baseHeight", block.Height)
+	}
+}
+
+// catchUpLeague registers a league if it is not, and submits its
+// checkpoints due after the latest anchored one, up to the last height
+// committed in its block store.
+func (m *Manager) catchUpLeague(leagueID string, al *anchoredLeague) {
+	m.registerLeague(leagueID, al)
+	from := int64(1)
+	if cp := m.store.LatestCheckpoint(leagueID); cp != nil {
+		from = cp.Height() + 1
+	}
+	// the commit of the last height is in the next block
+	for height := from; height < al.blockStore.Height(); height++ {
+		meta := al.blockStore.LoadBlockMeta(height)
+		if meta == nil {
+			m.Logger.Error("League block is missing", "league", leagueID, "height", height)
+			return
+		}
+		if !m.isDue(meta.Header) {
+			continue
+		}
+		if err := m.submitCheckpoint(al, meta.Header, al.blockStore.LoadBlockCommit(height)); err != nil {
+			m.Logger.Error("Error submitting checkpoint", "league", leagueID, "height", height, "err", err)
+			return
+		}
+	}
+}
+
+// applyLeagueBlock checks a block of a Regular league against the anchored
+// checkpoints, and submits the checkpoint of the previous height, committed
+// by the block, if it is due.
+func (m *Manager) applyLeagueBlock(leagueID string, block *types.Block) {
+	if _, err := m.store.CheckHeader(leagueID, block.Height, block.Hash()); err != nil {
+		m.Logger.Error("League fork: block conflicts with the anchored checkpoint",
+			"league", leagueID, "height", block.Height, "hash", block.Hash())
+	}
+
+	m.mtx.Lock()
+	al, ok := m.leagues[leagueID]
+	m.mtx.Unlock()
+	height := block.Height - 1
+	if !ok || height <= 0 {
+		return
+	}
+	if anchored, _ := m.store.CheckHeader(leagueID, height, block.LastBlockID.Hash); anchored {
+		return
+	}
+	meta := al.blockStore.LoadBlockMeta(height)
+	if meta == nil {
+		m.Logger.Error("League block is missing", "league", leagueID, "height", height)
+		return
+	}
+	if !m.isDue(meta.Header) {
+		return
+	}
+
+	m.registerLeague(leagueID, al)
+	if err := m.submitCheckpoint(al, meta.Header, block.LastCommit); err != nil {
+		m.Logger.Error("Error submitting checkpoint", "league", leagueID, "height", height, "err", err)
+	}
+}
+
+// isDue returns whether the checkpoint of a header is submitted: every
+// interval heights, and whenever the validators change at the next height,
+// so that the anchored checkpoints follow the changes of the validators.
+func (m *Manager) isDue(header types.Header) bool {
+	m.mtx.Lock()
+	interval := m.interval
+	m.mtx.Unlock()
+	if interval > 0 && header.Height%interval == 0 {
+		return true
+	}
+	return len(header.NextValidatorsHash) > 0 &&
+		!bytes.Equal(header.ValidatorsHash, header.NextValidatorsHash)
+}
+
+// registerLeague submits the registration of a league with its genesis
+// validators, unless the league is registered. The registration may still
+// be in the storage of the Base league, so the checkpoints are submitted
+// even if it fails.
+func (m *Manager) registerLeague(leagueID string, al *anchoredLeague) {
+	if m.store.Registration(leagueID) != nil {
+		return
+	}
+	reg := NewRegistration(leagueID, al.genesisValidators)
+	if err := m.submit(&RegistrationTx{Registration: *reg}); err != nil {
+		m.Logger.Error("Error submitting registration", "league", leagueID, "err", err)
+		return
+	}
+	m.Logger.Info("Submitted registration", "registration", reg)
+}
+
+// submitCheckpoint submits the checkpoint of a header of a league.
+func (m *Manager) submitCheckpoint(al *anchoredLeague, header types.Header, commit *types.Commit) error {
+	if commit == nil {
+		return fmt.Errorf("Commit of block %d is missing", header.Height)
+	}
+	height := header.Height
+	vals, err := al.loadValidators(height)
+	if err != nil {
+		return err
+	}
+	cp := NewCheckpoint(header, commit, vals)
+	if err := m.submit(&AnchorTx{Checkpoint: *cp}); err != nil {
+		return err
+	}
+	m.Logger.Info("Submitted checkpoint", "checkpoint", cp)
+	return nil
+}
+
+// submit validates and submits a checkpoint transaction.
+func (m *Manager) submit(ctx CheckpointTx) error {
+	if err := ctx.ValidateBasic(); err != nil {
+		return err
+	}
+	tx, err := EncodeTx(ctx)
+	if err != nil {
+		return err
+	}
+	return m.SubmitTx(tx)
+}
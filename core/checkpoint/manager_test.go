@@ -0,0 +1,219 @@
+package checkpoint
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teragrid/dgrid/core/types"
+	dbm "github.com/teragrid/dgrid/pkg/db"
+)
+
+// testBlockStore holds the blocks of the Base league by height.
+type testBlockStore map[int64]*types.Block
+
+func (store testBlockStore) Height() int64 {
+	return int64(len(store))
+}
+
+func (store testBlockStore) LoadBlock(height int64) *types.Block {
+	return store[height]
+}
+
+// testLeagueBlockStore holds the headers of a league, with their commits,
+// by height.
+type testLeagueBlockStore map[int64]*Checkpoint
+
+func (store testLeagueBlockStore) Height() int64 {
+	var height int64
+	for h := range store {
+		if h > height {
+			height = h
+		}
+	}
+	return height
+}
+
+func (store testLeagueBlockStore) LoadBlockMeta(height int64) *types.BlockMeta {
+	if cp, ok := store[height]; ok {
+		return &types.BlockMeta{Header: cp.Header}
+	}
+	return nil
+}
+
+func (store testLeagueBlockStore) LoadBlockCommit(height int64) *types.Commit {
+	if cp, ok := store[height]; ok {
+		return cp.Commit
+	}
+	return nil
+}
+
+func startEventBus(t *testing.T) *types.EventBus {
+	eventBus := types.NewEventBus()
+	require.NoError(t, eventBus.Start())
+	return eventBus
+}
+
+func publishBlock(t *testing.T, eventBus *types.EventBus, block *types.Block) {
+	require.NoError(t, eventBus.PublishEventNewBlock(types.EventDataNewBlock{Block: block}))
+}
+
+// leagueBlock returns the block of a league committing cp.
+func leagueBlock(cp *Checkpoint) *types.Block {
+	block := &types.Block{LastCommit: cp.Commit}
+	block.LeagueID = cp.LeagueID()
+	block.Height = cp.Height() + 1
+	block.LastBlockID = cp.Commit.BlockID
+	return block
+}
+
+// waitSubmitted returns the next transaction submitted, and its decoding.
+func waitSubmitted(t *testing.T, submitted <-chan types.Tx) (types.Tx, CheckpointTx) {
+	select {
+	case tx := <-submitted:
+		ctx, err := DecodeTx(tx)
+		require.NoError(t, err)
+		return tx, ctx
+	case <-time.After(5 * time.Second):
+		t.Fatal("Transaction was not submitted")
+	}
+	return nil, nil
+}
+
+func waitCheckpoint(t *testing.T, submitted <-chan types.Tx) *Checkpoint {
+	_, ctx := waitSubmitted(t, submitted)
+	require.IsType(t, &AnchorTx{}, ctx)
+	return &ctx.(*AnchorTx).Checkpoint
+}
+
+func waitAnchored(t *testing.T, anchored func() bool) {
+	for start := time.Now(); !anchored(); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("Transaction was not anchored")
+		}
+	}
+}
+
+func newTestManager(t *testing.T, baseBus *types.EventBus, baseBlockStore testBlockStore) (*Manager, chan types.Tx) {
+	m := NewManager(baseBus, baseBlockStore, NewStore(dbm.NewMemDB()))
+	m.SetInterval(10)
+	submitted := make(chan types.Tx, 10)
+	m.SetTxBroadcaster(func(tx types.Tx) error {
+		submitted <- tx
+		return nil
+	})
+	require.NoError(t, m.Start())
+	return m, submitted
+}
+
+func TestManagerRegistration(t *testing.T) {
+	_, vals := makeValidators(4)
+	_, others := makeValidators(4)
+	baseBus, leagueBus := startEventBus(t), startEventBus(t)
+	defer baseBus.Stop()
+	defer leagueBus.Stop()
+
+	m, submitted := newTestManager(t, baseBus, testBlockStore{})
+	defer m.Stop()
+
+	// a league which is not registered is registered with its genesis
+	// validators
+	require.NoError(t, m.TrackLeague("regular", leagueBus, testLeagueBlockStore{}, vals,
+		func(height int64) (*types.ValidatorSet, error) { return vals, nil }))
+	tx, ctx := waitSubmitted(t, submitted)
+	require.IsType(t, &RegistrationTx{}, ctx)
+	assert.Equal(t, vals.Hash(), ctx.(*RegistrationTx).Registration.Validators.Hash())
+
+	// other registrations of the league are rejected
+	assert.NoError(t, m.CheckTx(tx))
+	assert.Error(t, m.CheckTx(makeTx(t, registrationTx("regular", others))))
+	assert.NoError(t, m.CheckTx(makeTx(t, registrationTx("other", others))))
+	assert.NoError(t, m.CheckTx(types.Tx("app tx")))
+
+	block := makeBlock(t, 1)
+	block.Data.Txs = append(block.Data.Txs, tx)
+	publishBlock(t, baseBus, block)
+	waitAnchored(t, func() bool { return m.Store().Registration("regular") != nil })
+	assert.Error(t, m.CheckTx(tx))
+}
+
+func TestManagerAnchoring(t *testing.T) {
+	pvs, vals := makeValidators(4)
+	baseBus, leagueBus := startEventBus(t), startEventBus(t)
+	defer baseBus.Stop()
+	defer leagueBus.Stop()
+
+	m, submitted := newTestManager(t, baseBus, testBlockStore{1: makeBlock(t, 1, registrationTx("regular", vals))})
+	defer m.Stop()
+	waitAnchored(t, func() bool { return m.Store().Registration("regular") != nil })
+
+	cp9 := makeCheckpoint(t, "regular", 9, "app", pvs, vals)
+	cp10 := makeCheckpoint(t, "regular", 10, "app", pvs, vals)
+	blockStore := testLeagueBlockStore{9: cp9, 10: cp10}
+	loadValidators := func(height int64) (*types.ValidatorSet, error) { return vals, nil }
+	require.NoError(t, m.TrackLeague("regular", leagueBus, blockStore, vals, loadValidators))
+	assert.Error(t, m.TrackLeague("regular", leagueBus, blockStore, vals, loadValidators))
+
+	// only the heights multiple of the interval are submitted, once
+	// committed by the next block
+	publishBlock(t, leagueBus, leagueBlock(cp9))
+	publishBlock(t, leagueBus, leagueBlock(cp10))
+	cp := waitCheckpoint(t, submitted)
+	assert.EqualValues(t, 10, cp.Height())
+	assert.Equal(t, cp10.Hash(), cp.Hash())
+	assert.Empty(t, submitted)
+
+	block := makeBlock(t, 2, anchorTx(cp))
+	publishBlock(t, baseBus, block)
+	waitAnchored(t, func() bool { return m.Store().LatestCheckpoint("regular") != nil })
+
+	// an anchored checkpoint is not submitted again
+	publishBlock(t, leagueBus, leagueBlock(cp10))
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, submitted)
+}
+
+func TestManagerCatchUp(t *testing.T) {
+	pvs, vals := makeValidators(4)
+	newPVs, newVals := makeValidators(4)
+	baseBus, leagueBus := startEventBus(t), startEventBus(t)
+	defer baseBus.Stop()
+	defer leagueBus.Stop()
+
+	cp10 := makeCheckpoint(t, "regular", 10, "app", pvs, vals)
+	m, submitted := newTestManager(t, baseBus, testBlockStore{
+		1: makeBlock(t, 1, registrationTx("regular", vals), anchorTx(cp10)),
+	})
+	defer m.Stop()
+
+	// the Base blocks committed while the manager was down are replayed
+	waitAnchored(t, func() bool { return m.Store().LatestCheckpoint("regular") != nil })
+	assert.Equal(t, cp10.Hash(), m.Store().LatestCheckpoint("regular").Hash())
+
+	// the checkpoints due after the anchored one are submitted, including
+	// the one of the change of validators after height 13
+	blockStore := testLeagueBlockStore{}
+	for h := int64(11); h <= 21; h++ {
+		switch {
+		case h < 13:
+			blockStore[h] = makeCheckpoint(t, "regular", h, "app", pvs, vals)
+		case h == 13:
+			blockStore[h] = makeCheckpointNext(t, "regular", h, "app", pvs, vals, newVals)
+		default:
+			blockStore[h] = makeCheckpoint(t, "regular", h, "app", newPVs, newVals)
+		}
+	}
+	require.NoError(t, m.TrackLeague("regular", leagueBus, blockStore, vals,
+		func(height int64) (*types.ValidatorSet, error) {
+			if height <= 13 {
+				return vals, nil
+			}
+			return newVals, nil
+		}))
+	assert.EqualValues(t, 13, waitCheckpoint(t, submitted).Height())
+	assert.EqualValues(t, 20, waitCheckpoint(t, submitted).Height())
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, submitted)
+}
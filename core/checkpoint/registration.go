@@ -0,0 +1,43 @@
+package checkpoint
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/teragrid/dgrid/core/types"
+)
+
+// Registration registers a Regular league into the Base league with its
+// validators at genesis, which verify the first checkpoint of the league.
+// The first registration of a league anchored is final, so a cell running
+// the league registers it before submitting its checkpoints, and the cells
+// of the Base league running it reject conflicting registrations.
+type Registration struct {
+	LeagueID   string              `json:"league_id"`
+	Validators *types.ValidatorSet `json:"validators"`
+}
+
+// NewRegistration returns the registration of a league with its genesis
+// validators.
+func NewRegistration(leagueID string, vals *types.ValidatorSet) *Registration {
+	return &Registration{
+		LeagueID:   leagueID,
+		Validators: vals,
+	}
+}
+
+// ValidateBasic performs basic validation.
+func (reg *Registration) ValidateBasic() error {
+	if reg.LeagueID == "" {
+		return errors.New("Empty league ID")
+	}
+	if reg.Validators.IsNilOrEmpty() {
+		return errors.New("Validators are missing")
+	}
+	return nil
+}
+
+// String returns a string representation of the registration.
+func (reg *Registration) String() string {
+	return fmt.Sprintf("Registration{%s %X}", reg.LeagueID, reg.Validators.Hash())
+}
@@ -0,0 +1,347 @@
+package checkpoint
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/teragrid/dgrid/core/types"
+	dbm "github.com/teragrid/dgrid/pkg/db"
+)
+
+/*
+Store persists the registrations of the Regular leagues, the checkpoints
+anchored into the Base league, indexed by league and height, and the
+conflicting checkpoints proving a fork:
+
+	"G:<len>:<leagueID>"                   -> Registration
+	"C:<len>:<leagueID>:<height>"          -> checkpointRecord
+	"L:<len>:<leagueID>"                   -> height of the latest checkpoint
+	"F:<len>:<leagueID>:<height>:<hash>"   -> checkpointRecord of a fork
+	"lastHeight"                           -> last Base height processed
+
+Heights are zero-padded, so the checkpoints of a league are iterated in
+height order.
+*/
+type Store struct {
+	db dbm.DB
+}
+
+// ErrLeagueFork is returned for a header conflicting with a checkpoint.
+var ErrLeagueFork = errors.New("Header conflicts with the anchored checkpoint")
+
+var lastHeightKey = []byte("lastHeight")
+
+// checkpointRecord is a checkpoint in the store.
+type checkpointRecord struct {
+	Checkpoint Checkpoint `json:"checkpoint"`
+	// Height of the Base league at which the checkpoint was anchored
+	BaseHeight int64 `json:"base_height"`
+}
+
+// NewStore returns a new Store using the given db.
+func NewStore(db dbm.DB) *Store {
+	return &Store{db: db}
+}
+
+func keyRegistration(leagueID string) []byte {
+	return []byte(fmt.Sprintf("G:%d:%s", len(leagueID), leagueID))
+}
+
+func keyCheckpointPrefix(leagueID string) []byte {
+	return []byte(fmt.Sprintf("C:%d:%s:", len(leagueID), leagueID))
+}
+
+func keyCheckpoint(leagueID string, height int64) []byte {
+	return append(keyCheckpointPrefix(leagueID), []byte(fmt.Sprintf("%020d", height))...)
+}
+
+func keyLatest(leagueID string) []byte {
+	return []byte(fmt.Sprintf("L:%d:%s", len(leagueID), leagueID))
+}
+
+func keyForkPrefix(leagueID string) []byte {
+	return []byte(fmt.Sprintf("F:%d:%s:", len(leagueID), leagueID))
+}
+
+func keyFork(cp *Checkpoint) []byte {
+	return append(keyForkPrefix(cp.LeagueID()), []byte(fmt.Sprintf("%020d:%X", cp.Height(), cp.Hash()))...)
+}
+
+func (store *Store) loadRecord(key []byte) *checkpointRecord {
+	bz := store.db.Get(key)
+	if len(bz) == 0 {
+		return nil
+	}
+	rec := new(checkpointRecord)
+	if err := cdc.UnmarshalBinaryBare(bz, rec); err != nil {
+		panic(fmt.Sprintf("Error reading checkpoint %s: %v", key, err))
+	}
+	return rec
+}
+
+// Registration returns the registration of a league, or nil if the league
+// is not registered.
+func (store *Store) Registration(leagueID string) *Registration {
+	bz := store.db.Get(keyRegistration(leagueID))
+	if len(bz) == 0 {
+		return nil
+	}
+	reg := new(Registration)
+	if err := cdc.UnmarshalBinaryBare(bz, reg); err != nil {
+		panic(fmt.Sprintf("Error reading registration of league %s: %v", leagueID, err))
+	}
+	return reg
+}
+
+// GetCheckpoint returns the checkpoint of a league at a height, and the
+// height of the Base league it was anchored at, or nil if there is none.
+func (store *Store) GetCheckpoint(leagueID string, height int64) (cp *Checkpoint, baseHeight int64) {
+	rec := store.loadRecord(keyCheckpoint(leagueID, height))
+	if rec == nil {
+		return nil, 0
+	}
+	return &rec.Checkpoint, rec.BaseHeight
+}
+
+// LatestCheckpoint returns the checkpoint of a league with the greatest
+// height, or nil if there is none.
+func (store *Store) LatestCheckpoint(leagueID string) *Checkpoint {
+	bz := store.db.Get(keyLatest(leagueID))
+	if len(bz) == 0 {
+		return nil
+	}
+	var height int64
+	cdc.MustUnmarshalBinaryBare(bz, &height)
+	cp, _ := store.GetCheckpoint(leagueID, height)
+	return cp
+}
+
+// Checkpoints returns the checkpoints of a league, in height order.
+func (store *Store) Checkpoints(leagueID string) []*Checkpoint {
+	return store.iterate(keyCheckpointPrefix(leagueID))
+}
+
+// Forks returns the checkpoints of a league which were signed by its
+// validators but conflict with the anchored ones, in height order.
+func (store *Store) Forks(leagueID string) []*Checkpoint {
+	return store.iterate(keyForkPrefix(leagueID))
+}
+
+func (store *Store) iterate(prefix []byte) []*Checkpoint {
+	var cps []*Checkpoint
+	itr := dbm.IteratePrefix(store.db, prefix)
+	defer itr.Close()
+	for ; itr.Valid(); itr.Next() {
+		rec := new(checkpointRecord)
+		if err := cdc.UnmarshalBinaryBare(itr.Value(), rec); err != nil {
+			panic(fmt.Sprintf("Error reading checkpoint %s: %v", itr.Key(), err))
+		}
+		cps = append(cps, &rec.Checkpoint)
+	}
+	return cps
+}
+
+// CheckHeader compares the hash of a header of a league with the anchored
+// one. It returns whether the height is anchored, and ErrLeagueFork if the
+// hashes differ.
+func (store *Store) CheckHeader(leagueID string, height int64, hash []byte) (anchored bool, err error) {
+	cp, _ := store.GetCheckpoint(leagueID, height)
+	if cp == nil {
+		return false, nil
+	}
+	if !bytes.Equal(cp.Hash(), hash) {
+		return true, ErrLeagueFork
+	}
+	return true, nil
+}
+
+// LastHeight returns the last height of the Base league processed.
+func (store *Store) LastHeight() int64 {
+	bz := store.db.Get(lastHeightKey)
+	if len(bz) == 0 {
+		return 0
+	}
+	var height int64
+	cdc.MustUnmarshalBinaryBare(bz, &height)
+	return height
+}
+
+// storeView reads the store, and the records set by the block being
+// applied, so they are seen by the next txs of the block.
+type storeView struct {
+	store         *Store
+	registrations map[string]*Registration
+	checkpoints   map[string]*checkpointRecord
+	latest        map[string]int64
+}
+
+func (store *Store) newView() *storeView {
+	return &storeView{
+		store:         store,
+		registrations: make(map[string]*Registration),
+		checkpoints:   make(map[string]*checkpointRecord),
+		latest:        make(map[string]int64),
+	}
+}
+
+func (v *storeView) registration(leagueID string) *Registration {
+	if reg, ok := v.registrations[leagueID]; ok {
+		return reg
+	}
+	return v.store.Registration(leagueID)
+}
+
+func (v *storeView) checkpoint(leagueID string, height int64) *checkpointRecord {
+	key := keyCheckpoint(leagueID, height)
+	if rec, ok := v.checkpoints[string(key)]; ok {
+		return rec
+	}
+	return v.store.loadRecord(key)
+}
+
+func (v *storeView) latestCheckpoint(leagueID string) *checkpointRecord {
+	if height, ok := v.latest[leagueID]; ok {
+		return v.checkpoint(leagueID, height)
+	}
+	if cp := v.store.LatestCheckpoint(leagueID); cp != nil {
+		return v.checkpoint(leagueID, cp.Height())
+	}
+	return nil
+}
+
+// checkRegistration returns an error if the league of reg is registered.
+func (v *storeView) checkRegistration(reg *Registration) error {
+	if v.registration(reg.LeagueID) != nil {
+		return fmt.Errorf("League %s is registered already", reg.LeagueID)
+	}
+	return nil
+}
+
+// checkCheckpoint returns whether cp is anchored, as the next checkpoint of
+// its league, or recorded as a fork of the anchored checkpoint at its
+// height, or an error if it is neither.
+func (v *storeView) checkCheckpoint(cp *Checkpoint) (fork bool, err error) {
+	leagueID, height := cp.LeagueID(), cp.Height()
+	if existing := v.checkpoint(leagueID, height); existing != nil {
+		if bytes.Equal(existing.Checkpoint.Hash(), cp.Hash()) {
+			return false, fmt.Errorf("%v is anchored already", cp)
+		}
+		if err := cp.Verify(existing.Checkpoint.Validators); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	if rec := v.latestCheckpoint(leagueID); rec != nil {
+		if height < rec.Checkpoint.Height() {
+			return false, fmt.Errorf("%v is before the latest anchored checkpoint %v", cp, &rec.Checkpoint)
+		}
+		return false, cp.VerifyNext(&rec.Checkpoint)
+	}
+	reg := v.registration(leagueID)
+	if reg == nil {
+		return false, fmt.Errorf("League %s is not registered", leagueID)
+	}
+	return false, cp.Verify(reg.Validators)
+}
+
+// BlockResult lists the registrations and checkpoints anchored by a block of
+// the Base league, and the forks found.
+type BlockResult struct {
+	Registered []*Registration
+	Anchored   []*Checkpoint
+	Forks      []*Checkpoint
+}
+
+// ApplyBlock processes the checkpoint transactions of a block of the Base
+// league. The first registration of a league is recorded, the next ones are
+// ignored. A checkpoint is anchored if it is past the latest checkpoint of
+// its league and verified by the validators of that checkpoint or the next
+// validators its header commits to, or by the registered validators for the
+// first one. A checkpoint at an anchored height with another hash, verified
+// by the validators of the anchored one, is recorded as a fork. Checkpoints
+// before the latest one of their league which are not anchored are ignored,
+// since the validators trusted at their height are not known. Blocks must be
+// applied in order; a block already applied is ignored.
+func (store *Store) ApplyBlock(block *types.Block) BlockResult {
+	var res BlockResult
+	if block.Height <= store.LastHeight() {
+		return res
+	}
+
+	batch := store.db.NewBatch()
+	defer batch.Close()
+
+	view := store.newView()
+	for _, tx := range block.Data.Txs {
+		if !IsCheckpointTx(tx) {
+			continue
+		}
+		ctx, err := DecodeTx(tx)
+		if err != nil {
+			// invalid txs are rejected by CheckTx, an invalid one which
+			// made it into a block has no effect
+			continue
+		}
+
+		switch ctx := ctx.(type) {
+		case *RegistrationTx:
+			reg := &ctx.Registration
+			if view.checkRegistration(reg) != nil {
+				continue
+			}
+			view.registrations[reg.LeagueID] = reg
+			batch.Set(keyRegistration(reg.LeagueID), cdc.MustMarshalBinaryBare(reg))
+			res.Registered = append(res.Registered, reg)
+
+		case *AnchorTx:
+			cp := &ctx.Checkpoint
+			fork, err := view.checkCheckpoint(cp)
+			if err != nil {
+				continue
+			}
+			rec := &checkpointRecord{Checkpoint: *cp, BaseHeight: block.Height}
+			if fork {
+				batch.Set(keyFork(cp), cdc.MustMarshalBinaryBare(rec))
+				res.Forks = append(res.Forks, cp)
+				continue
+			}
+			key := keyCheckpoint(cp.LeagueID(), cp.Height())
+			view.checkpoints[string(key)] = rec
+			view.latest[cp.LeagueID()] = cp.Height()
+			batch.Set(key, cdc.MustMarshalBinaryBare(rec))
+			batch.Set(keyLatest(cp.LeagueID()), cdc.MustMarshalBinaryBare(cp.Height()))
+			res.Anchored = append(res.Anchored, cp)
+		}
+	}
+
+	batch.Set(lastHeightKey, cdc.MustMarshalBinaryBare(block.Height))
+	batch.WriteSync()
+	return res
+}
+
+// CheckTx checks a checkpoint transaction before it is added to the
+// storage of the Base league. The transactions which would have no effect
+// in the next block are rejected. A checkpoint past the latest anchored one
+// of its league may be verified by the checkpoints still in the storage, so
+// it is only checked to be signed by its own validators; ApplyBlock verifies
+// it.
+func (store *Store) CheckTx(tx types.Tx) error {
+	ctx, err := DecodeTx(tx)
+	if err != nil {
+		return err
+	}
+	view := store.newView()
+	switch ctx := ctx.(type) {
+	case *RegistrationTx:
+		return view.checkRegistration(&ctx.Registration)
+	case *AnchorTx:
+		cp := &ctx.Checkpoint
+		if rec := view.latestCheckpoint(cp.LeagueID()); rec != nil && cp.Height() <= rec.Checkpoint.Height() {
+			_, err := view.checkCheckpoint(cp)
+			return err
+		}
+		return cp.Validators.VerifyCommit(cp.LeagueID(), cp.Commit.BlockID, cp.Height(), cp.Commit)
+	}
+	return fmt.Errorf("Unknown checkpoint transaction %T", ctx)
+}
@@ -0,0 +1,201 @@
+package checkpoint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teragrid/dgrid/core/types"
+	dbm "github.com/teragrid/dgrid/pkg/db"
+)
+
+func makeTx(t *testing.T, ctx CheckpointTx) types.Tx {
+	tx, err := EncodeTx(ctx)
+	require.NoError(t, err)
+	return tx
+}
+
+func anchorTx(cp *Checkpoint) CheckpointTx {
+	return &AnchorTx{Checkpoint: *cp}
+}
+
+func registrationTx(leagueID string, vals *types.ValidatorSet) CheckpointTx {
+	return &RegistrationTx{Registration: *NewRegistration(leagueID, vals)}
+}
+
+func makeBlock(t *testing.T, height int64, ctxs ...CheckpointTx) *types.Block {
+	txs := types.Txs{types.Tx("app tx")}
+	for _, ctx := range ctxs {
+		txs = append(txs, makeTx(t, ctx))
+	}
+	block := &types.Block{}
+	block.Height = height
+	block.Data.Txs = txs
+	return block
+}
+
+// registeredStore returns a store in which league is registered with vals
+// at the Base height 1.
+func registeredStore(t *testing.T, leagueID string, vals *types.ValidatorSet) *Store {
+	store := NewStore(dbm.NewMemDB())
+	res := store.ApplyBlock(makeBlock(t, 1, registrationTx(leagueID, vals)))
+	require.Len(t, res.Registered, 1)
+	return store
+}
+
+func TestStoreRegistration(t *testing.T) {
+	pvs, vals := makeValidators(4)
+	_, others := makeValidators(4)
+	store := NewStore(dbm.NewMemDB())
+	cp100 := makeCheckpoint(t, "regular", 100, "app", pvs, vals)
+
+	// the checkpoints of a league which is not registered are ignored
+	res := store.ApplyBlock(makeBlock(t, 1, anchorTx(cp100)))
+	assert.Empty(t, res.Anchored)
+	assert.Nil(t, store.Registration("regular"))
+
+	// the first registration is final, even within a block
+	res = store.ApplyBlock(makeBlock(t, 2,
+		registrationTx("regular", vals),
+		registrationTx("regular", others),
+		anchorTx(cp100),
+	))
+	require.Len(t, res.Registered, 1)
+	require.Len(t, res.Anchored, 1)
+	assert.Equal(t, vals.Hash(), store.Registration("regular").Validators.Hash())
+	res = store.ApplyBlock(makeBlock(t, 3, registrationTx("regular", others)))
+	assert.Empty(t, res.Registered)
+	assert.Equal(t, vals.Hash(), store.Registration("regular").Validators.Hash())
+}
+
+func TestStoreCheckTx(t *testing.T) {
+	pvs, vals := makeValidators(4)
+	newPVs, newVals := makeValidators(4)
+	_, others := makeValidators(4)
+	store := registeredStore(t, "regular", vals)
+	cp100 := makeCheckpointNext(t, "regular", 100, "app", pvs, vals, newVals)
+	store.ApplyBlock(makeBlock(t, 2, anchorTx(cp100)))
+
+	assert.Error(t, store.CheckTx(types.Tx("app tx")))
+	assert.Error(t, store.CheckTx(append(append(types.Tx{}, TxPrefix...), "garbage"...)))
+
+	// registrations of a registered league
+	assert.Error(t, store.CheckTx(makeTx(t, registrationTx("regular", others))))
+	assert.NoError(t, store.CheckTx(makeTx(t, registrationTx("other", others))))
+
+	// anchored checkpoints, checkpoints before the latest one, and
+	// conflicting checkpoints which are not signed by the anchored validators
+	assert.Error(t, store.CheckTx(makeTx(t, anchorTx(cp100))))
+	assert.Error(t, store.CheckTx(makeTx(t, anchorTx(makeCheckpoint(t, "regular", 50, "app", pvs, vals)))))
+	assert.Error(t, store.CheckTx(makeTx(t, anchorTx(makeCheckpoint(t, "regular", 100, "fork", newPVs, newVals)))))
+	assert.NoError(t, store.CheckTx(makeTx(t, anchorTx(makeCheckpoint(t, "regular", 100, "fork", pvs, vals)))))
+
+	// the next checkpoints may be verified by the ones still in the storage,
+	// so they only need to be signed by their validators
+	assert.NoError(t, store.CheckTx(makeTx(t, anchorTx(makeCheckpoint(t, "regular", 200, "app", newPVs, newVals)))))
+	assert.NoError(t, store.CheckTx(makeTx(t, anchorTx(makeCheckpoint(t, "other", 100, "app", newPVs, newVals)))))
+	unsigned := makeCheckpoint(t, "regular", 200, "app", newPVs[:2], newVals)
+	assert.Error(t, store.CheckTx(makeTx(t, anchorTx(unsigned))))
+}
+
+func TestStoreAnchoring(t *testing.T) {
+	pvs, vals := makeValidators(4)
+	store := registeredStore(t, "regular", vals)
+
+	cp100 := makeCheckpoint(t, "regular", 100, "app", pvs, vals)
+	cp200 := makeCheckpoint(t, "regular", 200, "app", pvs, vals)
+	_, others := makeValidators(4)
+	_, strangers := makeValidators(1)
+
+	// checkpoints of an unknown league, or signed by strangers, are ignored,
+	// the others are anchored in order, even within a block
+	res := store.ApplyBlock(makeBlock(t, 2,
+		anchorTx(makeCheckpoint(t, "unknown", 100, "app", pvs, vals)),
+		anchorTx(makeCheckpoint(t, "regular", 50, "app", pvs[:1], strangers)),
+		anchorTx(cp100),
+		anchorTx(cp200),
+	))
+	require.Len(t, res.Anchored, 2)
+	assert.Empty(t, res.Forks)
+	assert.Equal(t, cp200.Hash(), store.LatestCheckpoint("regular").Hash())
+	assert.Len(t, store.Checkpoints("regular"), 2)
+	assert.Nil(t, store.LatestCheckpoint("unknown"))
+
+	cp, baseHeight := store.GetCheckpoint("regular", 100)
+	require.NotNil(t, cp)
+	assert.Equal(t, cp100.Hash(), cp.Hash())
+	assert.EqualValues(t, 2, baseHeight)
+
+	// resubmitted checkpoints, or checkpoints before the latest one, are ignored
+	res = store.ApplyBlock(makeBlock(t, 3,
+		anchorTx(cp100),
+		anchorTx(makeCheckpoint(t, "regular", 150, "app", pvs, vals)),
+	))
+	assert.Empty(t, res.Anchored)
+	assert.Empty(t, res.Forks)
+	cp, _ = store.GetCheckpoint("regular", 150)
+	assert.Nil(t, cp)
+
+	// a conflicting checkpoint is a fork if signed by the league validators
+	fork := makeCheckpoint(t, "regular", 200, "fork", pvs, vals)
+	res = store.ApplyBlock(makeBlock(t, 4,
+		anchorTx(makeCheckpoint(t, "regular", 200, "fork", pvs, others)),
+		anchorTx(fork),
+	))
+	assert.Empty(t, res.Anchored)
+	require.Len(t, res.Forks, 1)
+	assert.Equal(t, fork.Hash(), res.Forks[0].Hash())
+	assert.Len(t, store.Forks("regular"), 1)
+	assert.Equal(t, cp200.Hash(), store.LatestCheckpoint("regular").Hash())
+
+	ok, err := store.CheckHeader("regular", 200, cp200.Hash())
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	ok, err = store.CheckHeader("regular", 200, fork.Hash())
+	assert.True(t, ok)
+	assert.Equal(t, ErrLeagueFork, err)
+	ok, err = store.CheckHeader("regular", 300, fork.Hash())
+	assert.False(t, ok)
+	assert.NoError(t, err)
+
+	// a block is applied once
+	assert.EqualValues(t, 4, store.LastHeight())
+	res = store.ApplyBlock(makeBlock(t, 4, anchorTx(makeCheckpoint(t, "regular", 300, "app", pvs, vals))))
+	assert.Empty(t, res.Anchored)
+}
+
+func TestStoreValidatorChanges(t *testing.T) {
+	pvs, vals := makeValidators(4)
+	store := registeredStore(t, "regular", vals)
+
+	// validators which don't hold 2/3 of the trusted voting power
+	newPVs, newVals := makeValidators(4)
+	res := store.ApplyBlock(makeBlock(t, 2,
+		anchorTx(makeCheckpoint(t, "regular", 100, "app", newPVs, newVals))))
+	assert.Empty(t, res.Anchored)
+
+	// a checkpoint signed by the trusted validators and the new ones
+	// makes the new ones trusted
+	pv := types.NewMockPV()
+	mixedVals := vals.Copy()
+	require.NoError(t, mixedVals.UpdateWithChangeSet([]*types.Validator{types.NewValidator(pv.GetPubKey(), 10)}))
+	mixedPVs := make([]*types.MockPV, mixedVals.Size())
+	for _, p := range append(pvs, pv) {
+		idx, _ := mixedVals.GetByAddress(p.GetPubKey().Address())
+		mixedPVs[idx] = p
+	}
+	res = store.ApplyBlock(makeBlock(t, 3,
+		anchorTx(makeCheckpoint(t, "regular", 100, "app", mixedPVs, mixedVals))))
+	require.Len(t, res.Anchored, 1)
+	assert.Equal(t, mixedVals.Hash(), store.LatestCheckpoint("regular").Validators.Hash())
+
+	// the checkpoint of a change of the validators makes the next ones
+	// trusted, even if they replace all the trusted ones
+	res = store.ApplyBlock(makeBlock(t, 4,
+		anchorTx(makeCheckpointNext(t, "regular", 200, "app", mixedPVs, mixedVals, newVals)),
+		anchorTx(makeCheckpoint(t, "regular", 300, "app", newPVs, newVals)),
+	))
+	require.Len(t, res.Anchored, 2)
+	assert.Equal(t, newVals.Hash(), store.LatestCheckpoint("regular").Validators.Hash())
+}
@@ -0,0 +1,67 @@
+package checkpoint
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/teragrid/dgrid/core/types"
+)
+
+// TxPrefix starts the checkpoint transactions of the Base league, so they
+// can be told apart from the transactions of the application.
+var TxPrefix = []byte("dgrid/cp:")
+
+// CheckpointTx is a transaction of the Base league anchoring the history of
+// the Regular leagues.
+type CheckpointTx interface {
+	ValidateBasic() error
+}
+
+// RegistrationTx registers a Regular league.
+type RegistrationTx struct {
+	Registration Registration `json:"registration"`
+}
+
+// ValidateBasic performs basic validation.
+func (tx *RegistrationTx) ValidateBasic() error {
+	return tx.Registration.ValidateBasic()
+}
+
+// AnchorTx anchors a Checkpoint.
+type AnchorTx struct {
+	Checkpoint Checkpoint `json:"checkpoint"`
+}
+
+// ValidateBasic performs basic validation.
+func (tx *AnchorTx) ValidateBasic() error {
+	return tx.Checkpoint.ValidateBasic()
+}
+
+// EncodeTx returns the Base league transaction of a checkpoint tx.
+func EncodeTx(ctx CheckpointTx) (types.Tx, error) {
+	bz, err := cdc.MarshalBinaryBare(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, TxPrefix...), bz...), nil
+}
+
+// IsCheckpointTx returns true if tx is a checkpoint transaction.
+func IsCheckpointTx(tx types.Tx) bool {
+	return bytes.HasPrefix(tx, TxPrefix)
+}
+
+// DecodeTx decodes and validates a checkpoint transaction.
+func DecodeTx(tx types.Tx) (CheckpointTx, error) {
+	if !IsCheckpointTx(tx) {
+		return nil, errors.New("Not a checkpoint transaction")
+	}
+	var ctx CheckpointTx
+	if err := cdc.UnmarshalBinaryBare(tx[len(TxPrefix):], &ctx); err != nil {
+		return nil, err
+	}
+	if err := ctx.ValidateBasic(); err != nil {
+		return nil, err
+	}
+	return ctx, nil
+}
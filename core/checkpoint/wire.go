@@ -0,0 +1,20 @@
+package checkpoint
+
+import (
+	"github.com/teragrid/dgrid/core/types"
+	amino "github.com/teragrid/dgrid/third_party/amino"
+)
+
+var cdc = amino.NewCodec()
+
+func init() {
+	RegisterCheckpointTxs(cdc)
+	types.RegisterBlockAmino(cdc)
+}
+
+// RegisterCheckpointTxs registers the checkpoint transactions on the codec.
+func RegisterCheckpointTxs(cdc *amino.Codec) {
+	cdc.RegisterInterface((*CheckpointTx)(nil), nil)
+	cdc.RegisterConcrete(&RegistrationTx{}, "dgrid/cp/Registration", nil)
+	cdc.RegisterConcrete(&AnchorTx{}, "dgrid/cp/Anchor", nil)
+}
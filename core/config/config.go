@@ -75,15 +75,17 @@ func EnsureRoot(rootDir string, leagueID string) {
 	// Write default config file if missing.
 	// 1. The default config file for the Base
 	if !cmn.FileExists(configFilePath) {
-		writeDefaultConfigFile(configFilePath, leagueID)
+		writeDefaultConfigFile(configFilePath, BaseLeagueType, DefaultLeagueProtocol(BaseLeagueType))
 	}
 	// 2. The default config file for the first regular league
 }
 
 // writeDefaultConfigFile should probably be called by cmd/tgrid/commands/init.go
-// alongside the writing of the genesis.json and consensus.json
-func writeDefaultConfigFile(configFilePath string, leagueType LeagueType) {
+// alongside the writing of the genesis.json and consensus.json.
+// The league runs the given consensus protocol.
+func writeDefaultConfigFile(configFilePath string, leagueType LeagueType, protocol ConsensusProtocol) {
 	cfg = NewLeagueConfig(leagueType)
+	cfg.ConsensusProtocol = protocol.String()
 	configTemplate = LeagueConfigFileTemplate(leagueType)
 	WriteConfigFile(configTemplate, configFilePath, cfg)
 }
@@ -1,11 +1,14 @@
 package config
 
 import (
+	"encoding/hex"
 	"fmt"
 	"path/filepath"
 	"time"
 
 	"github.com/pkg/errors"
+
+	"github.com/teragrid/dgrid/core/types"
 )
 
 var (
@@ -42,6 +45,69 @@ func NewConsensusConfig(protocol ConsensusProtocol) (*Config, err) {
 	return nil, nil
 }
 
+// ConvertConsensusConfig returns the consensus config of the given protocol
+// keeping the settings of config the protocols have in common: the home
+// directory, the WAL and the timeouts.
+func ConvertConsensusConfig(config Config, protocol ConsensusProtocol) (Config, error) {
+	var bft *BFTConsensusConfig
+	switch c := config.(type) {
+	case *BFTConsensusConfig:
+		bft = c
+	case *FBAConsensusConfig:
+		bft = &BFTConsensusConfig{
+			RootDir:                     c.RootDir,
+			WalPath:                     c.WalPath,
+			walFile:                     c.walFile,
+			ValidatorKey:                defaultValidatorKey,
+			ValidatorState:              defaultValidatorState,
+			TimeoutPropose:              c.TimeoutPropose,
+			TimeoutProposeDelta:         c.TimeoutProposeDelta,
+			TimeoutPrevote:              c.TimeoutPrevote,
+			TimeoutPrevoteDelta:         c.TimeoutPrevoteDelta,
+			TimeoutPrecommit:            c.TimeoutPrecommit,
+			TimeoutPrecommitDelta:       c.TimeoutPrecommitDelta,
+			TimeoutCommit:               c.TimeoutCommit,
+			SkipTimeoutCommit:           c.SkipTimeoutCommit,
+			CreateEmptyBlocks:           c.CreateEmptyBlocks,
+			CreateEmptyBlocksInterval:   c.CreateEmptyBlocksInterval,
+			PeerGossipSleepDuration:     c.PeerGossipSleepDuration,
+			PeerQueryMaj23SleepDuration: c.PeerQueryMaj23SleepDuration,
+		}
+	default:
+		return nil, fmt.Errorf("Unknown consensus config %T", config)
+	}
+
+	switch protocol {
+	case BFTConsensusProtocol:
+		bftCopy := *bft
+		return &bftCopy, nil
+	case FBAConsensusProtocol:
+		if fba, ok := config.(*FBAConsensusConfig); ok {
+			fbaCopy := *fba
+			return &fbaCopy, nil
+		}
+		return &FBAConsensusConfig{
+			RootDir:                     bft.RootDir,
+			WalPath:                     bft.WalPath,
+			walFile:                     bft.walFile,
+			TimeoutPropose:              bft.TimeoutPropose,
+			TimeoutProposeDelta:         bft.TimeoutProposeDelta,
+			TimeoutPrevote:              bft.TimeoutPrevote,
+			TimeoutPrevoteDelta:         bft.TimeoutPrevoteDelta,
+			TimeoutPrecommit:            bft.TimeoutPrecommit,
+			TimeoutPrecommitDelta:       bft.TimeoutPrecommitDelta,
+			TimeoutCommit:               bft.TimeoutCommit,
+			SkipTimeoutCommit:           bft.SkipTimeoutCommit,
+			CreateEmptyBlocks:           bft.CreateEmptyBlocks,
+			CreateEmptyBlocksInterval:   bft.CreateEmptyBlocksInterval,
+			PeerGossipSleepDuration:     bft.PeerGossipSleepDuration,
+			PeerQueryMaj23SleepDuration: bft.PeerQueryMaj23SleepDuration,
+		}, nil
+	default:
+		return nil, fmt.Errorf("Unknown consensus protocol %v", protocol)
+	}
+}
+
 // -----------------------------------------------------------------------------
 // BFTConsensusConfig
 // -----------------------------------------------------------------------------
@@ -74,6 +140,47 @@ type BFTConsensusConfig struct {
 	// Reactor sleep duration parameters
 	PeerGossipSleepDuration     time.Duration `mapstructure:"peer_gossip_sleep_duration"`
 	PeerQueryMaj23SleepDuration time.Duration `mapstructure:"peer_query_maj23_sleep_duration"`
+
+	// Misbehaviors of this validator, to test the detection of byzantine
+	// validators on a testnet. Never set them on a production node
+	ByzantineBehaviors []string `mapstructure:"byzantine_behaviors"`
+}
+
+// Byzantine behaviors of a test validator, see ByzantineBehaviors.
+const (
+	// ByzantineDoublePrevote signs a second, conflicting prevote
+	ByzantineDoublePrevote = "double-prevote"
+	// ByzantineDoublePrecommit signs a second, conflicting precommit
+	ByzantineDoublePrecommit = "double-precommit"
+	// ByzantineConflictingProposals proposes a different block to each half of the peers
+	ByzantineConflictingProposals = "conflicting-proposals"
+	// ByzantineWithholdVotes signs votes but never sends them to the peers
+	ByzantineWithholdVotes = "withhold-votes"
+)
+
+// ByzantineBehaviors lists the known byzantine behaviors.
+var ByzantineBehaviors = []string{
+	ByzantineDoublePrevote,
+	ByzantineDoublePrecommit,
+	ByzantineConflictingProposals,
+	ByzantineWithholdVotes,
+}
+
+// ValidateByzantineBehaviors returns an error if one of the behaviors is unknown.
+func ValidateByzantineBehaviors(behaviors []string) error {
+	for _, behavior := range behaviors {
+		known := false
+		for _, b := range ByzantineBehaviors {
+			if behavior == b {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("Unknown byzantine behavior %q, must be one of %v", behavior, ByzantineBehaviors)
+		}
+	}
+	return nil
 }
 
 // Default returns the default config details of FBA protocol
@@ -131,6 +238,9 @@ func (cfg *BFTConsensusConfig) Validate() error {
 	if cfg.PeerQueryMaj23SleepDuration < 0 {
 		return errors.New("peer_query_maj23_sleep_duration can't be negative")
 	}
+	if err := ValidateByzantineBehaviors(cfg.ByzantineBehaviors); err != nil {
+		return errors.Wrap(err, "Error in byzantine_behaviors")
+	}
 	return nil
 }
 
@@ -224,6 +334,10 @@ type FBAConsensusConfig struct {
 	// Reactor sleep duration parameters
 	PeerGossipSleepDuration     time.Duration `mapstructure:"peer_gossip_sleep_duration"`
 	PeerQueryMaj23SleepDuration time.Duration `mapstructure:"peer_query_maj23_sleep_duration"`
+
+	// Quorum slices of this node. If nil, the quorum set declared
+	// in the genesis file (or +2/3 of the validators) is used
+	QuorumSet *QuorumSetConfig `mapstructure:"quorum_set"`
 }
 
 // Default returns the default config details of FBA protocol
@@ -279,6 +393,11 @@ func (cfg *FBAConsensusConfig) Validate() error {
 	if cfg.PeerQueryMaj23SleepDuration < 0 {
 		return errors.New("peer_query_maj23_sleep_duration can't be negative")
 	}
+	if cfg.QuorumSet != nil {
+		if err := cfg.QuorumSet.Validate(); err != nil {
+			return errors.Wrap(err, "Error in [quorum_set] section")
+		}
+	}
 	return nil
 }
 
@@ -325,3 +444,55 @@ func (cfg *FBAConsensusConfig) WalFile() string {
 func (cfg *FBAConsensusConfig) SetWalFile(walFile string) {
 	cfg.walFile = walFile
 }
+
+// -----------------------------------------------------------------------------
+// QuorumSetConfig
+// -----------------------------------------------------------------------------
+
+// QuorumSetConfig declares the quorum slices of a node of an FBA league:
+// any set of nodes satisfying Threshold of the Validators (hex addresses)
+// and InnerSets is a slice.
+type QuorumSetConfig struct {
+	Threshold  int               `mapstructure:"threshold"`
+	Validators []string          `mapstructure:"validators"`
+	InnerSets  []QuorumSetConfig `mapstructure:"inner_sets"`
+}
+
+// Validate performs basic validation of the quorum set and
+// returns an error if it is malformed.
+func (cfg *QuorumSetConfig) Validate() error {
+	_, err := cfg.QuorumSet()
+	return err
+}
+
+// QuorumSet converts the configuration to a QuorumSet, and returns an error
+// if it is malformed.
+func (cfg *QuorumSetConfig) QuorumSet() (*types.QuorumSet, error) {
+	qs, err := cfg.toQuorumSet()
+	if err != nil {
+		return nil, err
+	}
+	if err := qs.ValidateBasic(); err != nil {
+		return nil, err
+	}
+	return qs, nil
+}
+
+func (cfg *QuorumSetConfig) toQuorumSet() (*types.QuorumSet, error) {
+	qs := &types.QuorumSet{Threshold: cfg.Threshold}
+	for _, addr := range cfg.Validators {
+		bz, err := hex.DecodeString(addr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "validator address %q is not hex encoded", addr)
+		}
+		qs.Validators = append(qs.Validators, types.Address(bz))
+	}
+	for i := range cfg.InnerSets {
+		inner, err := cfg.InnerSets[i].toQuorumSet()
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error in inner_sets[%d]", i)
+		}
+		qs.InnerSets = append(qs.InnerSets, inner)
+	}
+	return qs, nil
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teragrid/dgrid/core/types"
+)
+
+func TestQuorumSetConfig(t *testing.T) {
+	valSet, _ := types.RandValidatorSet(3, 1)
+	addrs := types.NewMajorityQuorumSet(valSet).Validators
+	config := &QuorumSetConfig{
+		Threshold:  2,
+		Validators: []string{fmt.Sprintf("%X", []byte(addrs[0]))},
+		InnerSets: []QuorumSetConfig{{
+			Threshold:  1,
+			Validators: []string{fmt.Sprintf("%X", []byte(addrs[1])), fmt.Sprintf("%X", []byte(addrs[2]))},
+		}},
+	}
+	qs, err := config.QuorumSet()
+	require.NoError(t, err)
+	assert.NoError(t, config.Validate())
+	assert.Equal(t, 2, qs.Threshold)
+	assert.Equal(t, addrs[0], qs.Validators[0])
+	assert.Len(t, qs.Members(), 3)
+
+	config.InnerSets[0].Threshold = 3
+	assert.Error(t, config.Validate())
+
+	config.InnerSets[0].Threshold = 1
+	config.InnerSets[0].Validators[1] = config.Validators[0]
+	assert.Error(t, config.Validate(), "duplicate validator")
+
+	config.Validators = []string{"not hex"}
+	assert.Error(t, config.Validate())
+
+	config.Validators = []string{"0102"}
+	assert.Error(t, config.Validate(), "short address")
+}
@@ -41,6 +41,8 @@ var (
 	defaultConfigFile  = "config.toml"
 	defaultGenesisFile = "genesis.json"
 
+	defaultValidatorFailoverStateFile = "validator_failover_state.json"
+
 	// Set the default consensus protocol for the Base league
 	defaultConsensu4BaseLeague = BFTConsensusProtocol
 
@@ -294,6 +296,10 @@ type BaseConfig struct {
 	// Database directory
 	DBPath string `mapstructure:"db_dir"`
 
+	// Consensus protocol run by the league: bft | fba. Its settings are
+	// taken from the [bft_consensus] or [fba_consensus] section
+	ConsensusProtocol string `mapstructure:"consensus_protocol"`
+
 	// Output level for logging
 	LogLevel string `mapstructure:"log_level"`
 
@@ -304,12 +310,40 @@ type BaseConfig struct {
 	Genesis string `mapstructure:"genesis_file"`
 
 	// TCP or UNIX socket address for Dgrid to listen on for
-	// connections from an external Validator process
+	// connections from an external Validator process, or the address of an
+	// external Validator process serving the Signer gRPC service for Dgrid
+	// to dial, as grpc://ID@host:port, where ID is the ID of its node key.
+	// The gRPC connections are authenticated with the node keys of both ends
 	ValidatorListenAddr string `mapstructure:"validator_laddr"`
 
+	// TCP or UNIX socket addresses for Dgrid to listen on for connections
+	// from the external Validator processes of the co-signers of a
+	// threshold validator, or their gRPC addresses, see ValidatorListenAddr
+	ValidatorCosignerListenAddrs []string `mapstructure:"validator_cosigner_laddrs"`
+
+	// Number of co-signers of a threshold validator signing each vote
+	ValidatorThreshold int `mapstructure:"validator_threshold"`
+
+	// TCP or UNIX socket addresses for Dgrid to listen on for connections
+	// from the external Validator processes of the same key in high
+	// availability, which must share their sign state, or their gRPC
+	// addresses, see ValidatorListenAddr
+	ValidatorFailoverListenAddrs []string `mapstructure:"validator_failover_laddrs"`
+
+	// A JSON file containing the last sign state of the validator of
+	// validator_failover_laddrs, locked while it signs, so Dgrid never asks
+	// the next Validator process to sign a message conflicting with one
+	// signed before failing over
+	ValidatorFailoverState string `mapstructure:"validator_failover_state_file"`
+
 	// A JSON file containing the private key to use for p2p authenticated encryption
 	CellKey string `mapstructure:"node_key_file"`
 
+	// Source of the passphrase of the encrypted validator and node key
+	// files: "prompt" | "env:NAME" | "fd:N". New key files are encrypted
+	// if it is set
+	KeyPassphrase string `mapstructure:"key_passphrase"`
+
 	// Mechanism to connect to the Asura application: socket | grpc
 	Asura string `mapstructure:"asura"`
 
@@ -324,19 +358,20 @@ type BaseConfig struct {
 // Default returns a default base configuration for a Dgrid node
 func (cfg *BaseConfig) Default() *BaseConfig {
 	return &BaseConfig{
-		ConfigDir:         defaultConfigDir,
-		Genesis:           filepath.Join(defaultConfigDir, defaultGenesisFile),
-		CellKey:           filepath.Join(defaultConfigDir, defaultCellKey),
-		Hostname:          defaultHostname,
-		ProxyApp:          cfg.ProxyApp,
-		Asura:             "socket",
-		LogLevel:          DefaultPackageLogLevels(),
-		LogFormat:         LogFormatPlain,
-		ProfListenAddress: "",
-		FastSync:          true,
-		FilterPeers:       false,
-		DBBackend:         "leveldb",
-		DBPath:            "data",
+		ConfigDir:              defaultConfigDir,
+		Genesis:                filepath.Join(defaultConfigDir, defaultGenesisFile),
+		CellKey:                filepath.Join(defaultConfigDir, defaultCellKey),
+		ValidatorFailoverState: filepath.Join(defaultDataDir, defaultValidatorFailoverStateFile),
+		Hostname:               defaultHostname,
+		ProxyApp:               cfg.ProxyApp,
+		Asura:                  "socket",
+		LogLevel:               DefaultPackageLogLevels(),
+		LogFormat:              LogFormatPlain,
+		ProfListenAddress:      "",
+		FastSync:               true,
+		FilterPeers:            false,
+		DBBackend:              "leveldb",
+		DBPath:                 "data",
 	}
 }
 
@@ -348,6 +383,22 @@ func (cfg *BaseConfig) Validate() error {
 	default:
 		return errors.New("unknown log_format (must be 'plain' or 'json')")
 	}
+	if len(cfg.ValidatorFailoverListenAddrs) > 0 {
+		if cfg.ValidatorListenAddr != "" || len(cfg.ValidatorCosignerListenAddrs) > 0 {
+			return errors.New("validator_failover_laddrs can't be set with validator_laddr or validator_cosigner_laddrs")
+		}
+		if cfg.ValidatorFailoverState == "" {
+			return errors.New("validator_failover_state_file must be set with validator_failover_laddrs")
+		}
+	}
+	if len(cfg.ValidatorCosignerListenAddrs) > 0 {
+		if cfg.ValidatorListenAddr != "" {
+			return errors.New("validator_laddr and validator_cosigner_laddrs can't be both set")
+		}
+		if cfg.ValidatorThreshold <= 0 || cfg.ValidatorThreshold > len(cfg.ValidatorCosignerListenAddrs) {
+			return errors.New("validator_threshold must be between 1 and the number of validator_cosigner_laddrs")
+		}
+	}
 	return nil
 }
 
@@ -366,6 +417,12 @@ func (cfg BaseConfig) CellKeyFile() string {
 	return Rootify(cfg.CellKey, cfg.RootDir)
 }
 
+// ValidatorFailoverStateFile returns the full path to the
+// validator_failover_state.json file
+func (cfg BaseConfig) ValidatorFailoverStateFile() string {
+	return Rootify(cfg.ValidatorFailoverState, cfg.RootDir)
+}
+
 // DBDir returns the full path to the database directory
 func (cfg BaseConfig) DBDir() string {
 	return Rootify(cfg.DBPath, cfg.RootDir)
@@ -0,0 +1,299 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+
+	cmn "github.com/teragrid/dgrid/pkg/common"
+)
+
+// defaultLeagueRegistryFile is the file, in the root directory,
+// recording the leagues run by the node
+var defaultLeagueRegistryFile = "leagues.json"
+
+var (
+	// ErrLeagueExists is returned when creating a league which is
+	// already in the registry
+	ErrLeagueExists = errors.New("League already exists")
+	// ErrUnknownLeague is returned when a league is not in the registry
+	ErrUnknownLeague = errors.New("Unknown league")
+)
+
+// String returns the name of the league type
+func (t LeagueType) String() string {
+	switch t {
+	case BaseLeagueType:
+		return "base"
+	case RegularLeagueType:
+		return "regular"
+	default:
+		return fmt.Sprintf("LeagueType(%d)", int(t))
+	}
+}
+
+// ParseLeagueType returns the league type of a name, "base" or "regular"
+func ParseLeagueType(name string) (LeagueType, error) {
+	switch strings.ToLower(name) {
+	case "base":
+		return BaseLeagueType, nil
+	case "regular", "reg":
+		return RegularLeagueType, nil
+	default:
+		return 0, fmt.Errorf("Unknown league type %q (must be 'base' or 'regular')", name)
+	}
+}
+
+// String returns the name of the consensus protocol
+func (p ConsensusProtocol) String() string {
+	switch p {
+	case BFTConsensusProtocol:
+		return "bft"
+	case FBAConsensusProtocol:
+		return "fba"
+	default:
+		return fmt.Sprintf("ConsensusProtocol(%d)", int(p))
+	}
+}
+
+// ParseConsensusProtocol returns the consensus protocol of a name, "bft" or "fba"
+func ParseConsensusProtocol(name string) (ConsensusProtocol, error) {
+	switch strings.ToLower(name) {
+	case "bft":
+		return BFTConsensusProtocol, nil
+	case "fba":
+		return FBAConsensusProtocol, nil
+	default:
+		return 0, fmt.Errorf("Unknown consensus protocol %q (must be 'bft' or 'fba')", name)
+	}
+}
+
+// DefaultLeagueProtocol returns the consensus protocol run by default
+// by the leagues of a type
+func DefaultLeagueProtocol(leagueType LeagueType) ConsensusProtocol {
+	if leagueType == BaseLeagueType {
+		return defaultConsensu4BaseLeague
+	}
+	return defaultConsensu4RegLeague
+}
+
+// LeagueRecord is the entry of a league in the registry
+type LeagueRecord struct {
+	ID       string            `json:"id"`
+	Type     LeagueType        `json:"type"`
+	Protocol ConsensusProtocol `json:"protocol"`
+
+	// Directory holding the config and data directories of the league
+	HomeDir string `json:"home"`
+	// Path to the genesis file of the league
+	GenesisFile string `json:"genesis_file"`
+	// Path to the config file of the league
+	ConfigFile string `json:"config_file"`
+}
+
+// DataDir returns the data directory of the league
+func (rec *LeagueRecord) DataDir() string {
+	return filepath.Join(rec.HomeDir, defaultDataDir)
+}
+
+// LoadConfig loads the config file of the league, rooted at its home
+// directory and using its genesis file. The consensus settings are those of
+// the protocol of the config file, or of the registry if it has none.
+func (rec *LeagueRecord) LoadConfig() (*Config, error) {
+	v := viper.New()
+	v.SetConfigFile(rec.ConfigFile)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("Error reading config of league %s", rec.ID))
+	}
+	conf := DefaultConfig()
+	if err := v.Unmarshal(conf); err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("Error parsing config of league %s", rec.ID))
+	}
+
+	protocol := rec.Protocol
+	if conf.ConsensusProtocol != "" {
+		var err error
+		if protocol, err = ParseConsensusProtocol(conf.ConsensusProtocol); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("Error parsing config of league %s", rec.ID))
+		}
+	}
+	consensus, err := NewConsensusConfig(protocol)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.UnmarshalKey(consensusConfigSection(protocol), consensus); err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("Error parsing consensus config of league %s", rec.ID))
+	}
+	conf.ConsensusProtocol = protocol.String()
+	conf.Consensus = consensus
+	conf.SetRoot(rec.HomeDir)
+	conf.Genesis = rec.GenesisFile
+	return conf, nil
+}
+
+// consensusConfigSection returns the section of the config file holding
+// the settings of a consensus protocol
+func consensusConfigSection(protocol ConsensusProtocol) string {
+	if protocol == FBAConsensusProtocol {
+		return "fba_consensus"
+	}
+	return "bft_consensus"
+}
+
+// LeagueRegistry is the persistent list of the leagues run by a node.
+// Every league has its own config and data directories under
+// <root>/<leagueID>, and the registry is stored in <root>/leagues.json.
+type LeagueRegistry struct {
+	mtx     sync.RWMutex
+	rootDir string
+	leagues map[string]*LeagueRecord
+}
+
+// LeagueRegistryFile returns the path to the registry in a root directory
+func LeagueRegistryFile(rootDir string) string {
+	return filepath.Join(rootDir, defaultLeagueRegistryFile)
+}
+
+// LoadLeagueRegistry loads the registry of the root directory.
+// The registry is empty if the file doesn't exist yet.
+func LoadLeagueRegistry(rootDir string) (*LeagueRegistry, error) {
+	reg := &LeagueRegistry{
+		rootDir: rootDir,
+		leagues: make(map[string]*LeagueRecord),
+	}
+	file := LeagueRegistryFile(rootDir)
+	if !cmn.FileExists(file) {
+		return reg, nil
+	}
+	bz, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var records []*LeagueRecord
+	if err := json.Unmarshal(bz, &records); err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("Error reading league registry %s", file))
+	}
+	for _, rec := range records {
+		reg.leagues[rec.ID] = rec
+	}
+	return reg, nil
+}
+
+// CreateLeague lays out the config and data directories of a new league,
+// writes its default config file from the template of its type, and
+// records it in the registry. The genesis file is left to the caller.
+func (reg *LeagueRegistry) CreateLeague(leagueID string, leagueType LeagueType,
+	protocol ConsensusProtocol) (*LeagueRecord, error) {
+	if err := validateLeagueID(leagueID); err != nil {
+		return nil, err
+	}
+
+	reg.mtx.Lock()
+	defer reg.mtx.Unlock()
+	if _, ok := reg.leagues[leagueID]; ok {
+		return nil, ErrLeagueExists
+	}
+
+	homeDir := filepath.Join(reg.rootDir, leagueID)
+	rec := &LeagueRecord{
+		ID:          leagueID,
+		Type:        leagueType,
+		Protocol:    protocol,
+		HomeDir:     homeDir,
+		GenesisFile: filepath.Join(homeDir, defaultConfigDir, defaultGenesisFile),
+		ConfigFile:  filepath.Join(homeDir, defaultConfigDir, defaultConfigFile),
+	}
+	for _, dir := range []string{filepath.Dir(rec.ConfigFile), rec.DataDir()} {
+		if err := cmn.EnsureDir(dir, DefaultDirPerm); err != nil {
+			return nil, err
+		}
+	}
+	if !cmn.FileExists(rec.ConfigFile) {
+		writeDefaultConfigFile(rec.ConfigFile, leagueType, protocol)
+	}
+
+	reg.leagues[leagueID] = rec
+	if err := reg.save(); err != nil {
+		delete(reg.leagues, leagueID)
+		return nil, err
+	}
+	return rec, nil
+}
+
+// RemoveLeague removes a league from the registry. If purge is true,
+// the config and data directories of the league are deleted too.
+func (reg *LeagueRegistry) RemoveLeague(leagueID string, purge bool) error {
+	reg.mtx.Lock()
+	defer reg.mtx.Unlock()
+	rec, ok := reg.leagues[leagueID]
+	if !ok {
+		return ErrUnknownLeague
+	}
+
+	delete(reg.leagues, leagueID)
+	if err := reg.save(); err != nil {
+		reg.leagues[leagueID] = rec
+		return err
+	}
+	if purge {
+		return os.RemoveAll(rec.HomeDir)
+	}
+	return nil
+}
+
+// GetLeague returns the record of a league.
+func (reg *LeagueRegistry) GetLeague(leagueID string) (*LeagueRecord, bool) {
+	reg.mtx.RLock()
+	defer reg.mtx.RUnlock()
+	rec, ok := reg.leagues[leagueID]
+	return rec, ok
+}
+
+// Leagues returns the records of all the leagues, sorted by ID.
+func (reg *LeagueRegistry) Leagues() []*LeagueRecord {
+	reg.mtx.RLock()
+	defer reg.mtx.RUnlock()
+	return reg.sortedLeagues()
+}
+
+func (reg *LeagueRegistry) sortedLeagues() []*LeagueRecord {
+	records := make([]*LeagueRecord, 0, len(reg.leagues))
+	for _, rec := range reg.leagues {
+		records = append(records, rec)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+	return records
+}
+
+// save writes the registry atomically. The lock must be held.
+func (reg *LeagueRegistry) save() error {
+	if err := cmn.EnsureDir(reg.rootDir, DefaultDirPerm); err != nil {
+		return err
+	}
+	bz, err := json.MarshalIndent(reg.sortedLeagues(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return cmn.WriteFileAtomic(LeagueRegistryFile(reg.rootDir), bz, 0600)
+}
+
+// validateLeagueID checks a league ID can be used as a directory name
+func validateLeagueID(leagueID string) error {
+	switch {
+	case leagueID == "":
+		return errors.New("League ID can't be empty")
+	case leagueID == defaultConfigDir || leagueID == defaultDataDir:
+		return fmt.Errorf("League ID %q is reserved", leagueID)
+	case strings.ContainsAny(leagueID, `/\`) || leagueID == "." || leagueID == "..":
+		return fmt.Errorf("League ID %q is not a valid directory name", leagueID)
+	}
+	return nil
+}
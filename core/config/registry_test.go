@@ -0,0 +1,97 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cmn "github.com/teragrid/dgrid/pkg/common"
+)
+
+func TestLeagueRegistry(t *testing.T) {
+	rootDir, err := ioutil.TempDir("", "league-registry")
+	require.NoError(t, err)
+	defer os.RemoveAll(rootDir)
+
+	reg, err := LoadLeagueRegistry(rootDir)
+	require.NoError(t, err)
+	assert.Empty(t, reg.Leagues())
+
+	base, err := reg.CreateLeague("base", BaseLeagueType, DefaultLeagueProtocol(BaseLeagueType))
+	require.NoError(t, err)
+	_, err = reg.CreateLeague("regular", RegularLeagueType, FBAConsensusProtocol)
+	require.NoError(t, err)
+	_, err = reg.CreateLeague("base", RegularLeagueType, BFTConsensusProtocol)
+	assert.Equal(t, ErrLeagueExists, err)
+
+	// the directories of the league are laid out
+	assert.Equal(t, filepath.Join(rootDir, "base"), base.HomeDir)
+	assert.True(t, cmn.FileExists(base.ConfigFile))
+	_, err = os.Stat(base.DataDir())
+	assert.NoError(t, err)
+
+	// the config of the league is rooted at its home directory
+	conf, err := base.LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, base.HomeDir, conf.RootDir)
+	assert.Equal(t, base.GenesisFile, conf.Genesis)
+	assert.Equal(t, "bft", conf.ConsensusProtocol)
+	assert.IsType(t, &BFTConsensusConfig{}, conf.Consensus)
+
+	// the league runs the protocol it was created with
+	regular, ok := reg.GetLeague("regular")
+	require.True(t, ok)
+	conf, err = regular.LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "fba", conf.ConsensusProtocol)
+	assert.IsType(t, &FBAConsensusConfig{}, conf.Consensus)
+
+	// the registry is persisted
+	reg, err = LoadLeagueRegistry(rootDir)
+	require.NoError(t, err)
+	leagues := reg.Leagues()
+	require.Len(t, leagues, 2)
+	assert.Equal(t, "base", leagues[0].ID)
+	assert.Equal(t, LeagueType(BaseLeagueType), leagues[0].Type)
+	assert.Equal(t, "regular", leagues[1].ID)
+	assert.Equal(t, ConsensusProtocol(FBAConsensusProtocol), leagues[1].Protocol)
+
+	// removing a league keeps its directories unless purged
+	require.NoError(t, reg.RemoveLeague("base", false))
+	assert.True(t, cmn.FileExists(base.ConfigFile))
+	regular, ok = reg.GetLeague("regular")
+	require.True(t, ok)
+	require.NoError(t, reg.RemoveLeague("regular", true))
+	assert.False(t, cmn.FileExists(regular.ConfigFile))
+	assert.Equal(t, ErrUnknownLeague, reg.RemoveLeague("regular", false))
+
+	reg, err = LoadLeagueRegistry(rootDir)
+	require.NoError(t, err)
+	assert.Empty(t, reg.Leagues())
+}
+
+func TestLeagueRegistryInvalidID(t *testing.T) {
+	reg := &LeagueRegistry{rootDir: "/nonexistent", leagues: make(map[string]*LeagueRecord)}
+	for _, id := range []string{"", "..", "a/b", defaultConfigDir, defaultDataDir} {
+		_, err := reg.CreateLeague(id, RegularLeagueType, BFTConsensusProtocol)
+		assert.Error(t, err, "league ID %q", id)
+	}
+}
+
+func TestParseLeagueTypeAndProtocol(t *testing.T) {
+	lType, err := ParseLeagueType("Base")
+	require.NoError(t, err)
+	assert.Equal(t, LeagueType(BaseLeagueType), lType)
+	_, err = ParseLeagueType("other")
+	assert.Error(t, err)
+
+	protocol, err := ParseConsensusProtocol("fba")
+	require.NoError(t, err)
+	assert.Equal(t, "fba", protocol.String())
+	_, err = ParseConsensusProtocol("pow")
+	assert.Error(t, err)
+}
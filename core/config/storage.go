@@ -1,6 +1,9 @@
 package config
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 // LeagueStorageConfig
 
@@ -13,19 +16,54 @@ type LeagueStorageConfig struct {
 	Size        int    `mapstructure:"size"`
 	MaxTxsBytes int64  `mapstructure:"max_txs_bytes"`
 	CacheSize   int    `mapstructure:"cache_size"`
+
+	// AnnounceTxs makes the txs be gossiped by announcing their hashes to
+	// the peers which support it, which request the ones they don't have.
+	AnnounceTxs bool `mapstructure:"announce_txs"`
+
+	// TTLNumBlocks is the number of blocks after which a tx is dropped from
+	// the storage if it wasn't committed. 0 disables it.
+	TTLNumBlocks int64 `mapstructure:"ttl_num_blocks"`
+	// TTLDuration is the time after which a tx is dropped from the storage if
+	// it wasn't committed. 0 disables it.
+	TTLDuration time.Duration `mapstructure:"ttl_duration"`
+
+	// PeerMaxTxsPerSec and PeerMaxBytesPerSec limit the rate of the txs
+	// received from each peer. The txs over the limits are dropped without
+	// being checked. 0 disables them.
+	PeerMaxTxsPerSec   int `mapstructure:"peer_max_txs_per_sec"`
+	PeerMaxBytesPerSec int `mapstructure:"peer_max_bytes_per_sec"`
+	// PeerMaxInvalidTxsPerMin and PeerMaxDuplicateTxsPerMin are the numbers
+	// of txs rejected by the application, and of txs sent twice, a peer can
+	// send per minute before being disconnected. 0 disables them.
+	PeerMaxInvalidTxsPerMin   int `mapstructure:"peer_max_invalid_txs_per_min"`
+	PeerMaxDuplicateTxsPerMin int `mapstructure:"peer_max_duplicate_txs_per_min"`
+
+	// FullMaxCheckTxsPerSec limits the rate of the txs checked while the
+	// storage is full, as they only get in by evicting txs of lower priority.
+	// The txs over the limit are rejected without being checked. 0 disables it.
+	FullMaxCheckTxsPerSec int `mapstructure:"full_max_check_txs_per_sec"`
 }
 
 // Default returns a default configuration for Dgrid League Storage
 func (cfg *LeagueStorageConfig) Default() *Config {
 	return &LeagueStorageConfig{
-		Recheck:   true,
-		Broadcast: true,
-		WalPath:   "",
+		Recheck:     true,
+		Broadcast:   true,
+		AnnounceTxs: true,
+		WalPath:     "",
 		// Each signature verification takes .5ms, Size reduced until we implement
 		// Asura Recheck
 		Size:        5000,
 		MaxTxsBytes: 1024 * 1024 * 1024, // 1GB
 		CacheSize:   10000,
+
+		PeerMaxTxsPerSec:          1000,
+		PeerMaxBytesPerSec:        10 * 1024 * 1024, // 10MB
+		PeerMaxInvalidTxsPerMin:   1000,
+		PeerMaxDuplicateTxsPerMin: 1000,
+
+		FullMaxCheckTxsPerSec: 100,
 	}
 }
 
@@ -41,6 +79,27 @@ func (cfg *LeagueStorageConfig) Validate() error {
 	if cfg.CacheSize < 0 {
 		return errors.New("cache_size can't be negative")
 	}
+	if cfg.TTLNumBlocks < 0 {
+		return errors.New("ttl_num_blocks can't be negative")
+	}
+	if cfg.TTLDuration < 0 {
+		return errors.New("ttl_duration can't be negative")
+	}
+	if cfg.PeerMaxTxsPerSec < 0 {
+		return errors.New("peer_max_txs_per_sec can't be negative")
+	}
+	if cfg.PeerMaxBytesPerSec < 0 {
+		return errors.New("peer_max_bytes_per_sec can't be negative")
+	}
+	if cfg.PeerMaxInvalidTxsPerMin < 0 {
+		return errors.New("peer_max_invalid_txs_per_min can't be negative")
+	}
+	if cfg.PeerMaxDuplicateTxsPerMin < 0 {
+		return errors.New("peer_max_duplicate_txs_per_min can't be negative")
+	}
+	if cfg.FullMaxCheckTxsPerSec < 0 {
+		return errors.New("full_max_check_txs_per_sec can't be negative")
+	}
 	return nil
 }
 
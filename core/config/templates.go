@@ -45,6 +45,10 @@ hostname = "{{ .BaseLeagueConfig.Hostname }}"
 # and verifying their commits
 fast_sync = {{ .BaseLeagueConfig.FastSync }}
 
+# Consensus protocol of the league: bft | fba
+# Its settings are taken from the [bft_consensus] or [fba_consensus] section
+consensus_protocol = "{{ .BaseLeagueConfig.ConsensusProtocol }}"
+
 # Database backend: leveldb | memdb | cleveldb
 db_backend = "{{ .BaseLeagueConfig.DBBackend }}"
 
@@ -69,12 +73,44 @@ validator_key_file = "{{ js .BaseLeagueConfig.ValidatorKey }}"
 validator_state_file = "{{ js .BaseLeagueConfig.ValidatorState }}"
 
 # TCP or UNIX socket address for Dgrid to listen on for
-# connections from an external Validator process
+# connections from an external Validator process, or the address of an
+# external Validator process serving the Signer gRPC service for Dgrid to
+# dial, as grpc://ID@host:port, where ID is the ID of its node key.
+# The gRPC connections are authenticated with the node keys of both ends
 validator_laddr = "{{ .BaseLeagueConfig.ValidatorListenAddr }}"
 
+# TCP or UNIX socket addresses for Dgrid to listen on for connections from the
+# external Validator processes of the co-signers of a threshold validator,
+# whose votes are signed by validator_threshold of them, or their gRPC
+# addresses, see validator_laddr. Exclusive with validator_laddr
+validator_cosigner_laddrs = [{{ range .BaseLeagueConfig.ValidatorCosignerListenAddrs }}{{ printf "%q, " . }}{{end}}]
+validator_threshold = {{ .BaseLeagueConfig.ValidatorThreshold }}
+
+# TCP or UNIX socket addresses for Dgrid to listen on for connections from the
+# external Validator processes of the same key in high availability: Dgrid
+# signs with one of them, and fails over to another one. The Validator
+# processes must share their sign state, eg. in a locked file on a shared
+# file system, see the shared_state_dir of dgrid signer. gRPC addresses are
+# accepted, see validator_laddr.
+# Exclusive with validator_laddr and validator_cosigner_laddrs
+validator_failover_laddrs = [{{ range .BaseLeagueConfig.ValidatorFailoverListenAddrs }}{{ printf "%q, " . }}{{end}}]
+
+# Path to the JSON file containing the last sign state of the validator of
+# validator_failover_laddrs: Dgrid never asks the next Validator process to
+# sign a message conflicting with one signed before failing over
+validator_failover_state_file = "{{ js .BaseLeagueConfig.ValidatorFailoverState }}"
+
 # Path to the JSON file containing the private key to use for node authentication in the p2p protocol
 node_key_file = "{{ js .BaseLeagueConfig.NodeKey }}"
 
+# Source of the passphrase of the encrypted validator and node key files:
+#   "prompt" to prompt for it on the terminal
+#   "env:NAME" to read it from the environment variable NAME
+#   "fd:N" to read it from the file descriptor N
+# New key files are encrypted if it is set. See the encrypt_keys command to
+# encrypt the existing ones
+key_passphrase = "{{ .BaseLeagueConfig.KeyPassphrase }}"
+
 # Mechanism to connect to the Asura application: socket | grpc
 asura = "{{ .BaseLeagueConfig.Asura }}"
 
@@ -226,9 +262,20 @@ dial_timeout = "{{ .P2P.DialTimeout }}"
 
 recheck = {{ .LeagueStorage.Recheck }}
 broadcast = {{ .LeagueStorage.Broadcast }}
+
+# Gossip the transactions by announcing their hashes to the peers which
+# support it, which request the ones they don't have, instead of sending them
+# all the transactions.
+announce_txs = {{ .LeagueStorage.AnnounceTxs }}
+
+# Directory of the WAL of the pending transactions, which are checked again
+# when the node restarts. Empty disables it.
 wal_dir = "{{ js .LeagueStorage.WalPath }}"
 
 # Maximum number of transactions in the storage
+# When the storage is full, a new transaction evicts the transactions of lower
+# priority, as given by the application in the CheckTx response, or is
+# rejected if they are not enough to make room for it.
 size = {{ .LeagueStorage.Size }}
 
 # Limit the total size of all txs in the storage.
@@ -239,6 +286,30 @@ max_txs_bytes = {{ .LeagueStorage.MaxTxsBytes }}
 # Size of the cache (used to filter transactions we saw earlier) in transactions
 cache_size = {{ .LeagueStorage.CacheSize }}
 
+# Number of blocks after which a transaction which wasn't committed is dropped
+# from the storage. 0 disables it.
+ttl_num_blocks = {{ .LeagueStorage.TTLNumBlocks }}
+
+# Time after which a transaction which wasn't committed is dropped from the
+# storage. 0 disables it.
+ttl_duration = "{{ .LeagueStorage.TTLDuration }}"
+
+# Limits on the rate of the transactions received from each peer. The
+# transactions over the limits are dropped without being checked.
+# 0 disables them.
+peer_max_txs_per_sec = {{ .LeagueStorage.PeerMaxTxsPerSec }}
+peer_max_bytes_per_sec = {{ .LeagueStorage.PeerMaxBytesPerSec }}
+
+# Number of transactions rejected by the application, and of transactions sent
+# twice, a peer can send per minute before being disconnected. 0 disables them.
+peer_max_invalid_txs_per_min = {{ .LeagueStorage.PeerMaxInvalidTxsPerMin }}
+peer_max_duplicate_txs_per_min = {{ .LeagueStorage.PeerMaxDuplicateTxsPerMin }}
+
+# Limit on the rate of the transactions checked while the storage is full, as
+# they only get in by evicting transactions of lower priority. The transactions
+# over the limit are rejected without being checked. 0 disables it.
+full_max_check_txs_per_sec = {{ .LeagueStorage.FullMaxCheckTxsPerSec }}
+
 ##### FBA consensus configuration options #####
 [fba_consensus]
 
@@ -263,6 +334,19 @@ create_empty_blocks_interval = "{{ .FBAConsensusConfig.CreateEmptyBlocksInterval
 peer_gossip_sleep_duration = "{{ .FBAConsensusConfig.PeerGossipSleepDuration }}"
 peer_query_maj23_sleep_duration = "{{ .FBAConsensusConfig.PeerQueryMaj23SleepDuration }}"
 
+# Quorum slices of this node: any set of nodes satisfying threshold of the
+# validators (hex addresses) and inner sets is a slice. If unset, the quorum
+# set declared in the genesis file, or +2/3 of the validators, is used.
+# Use "dgrid check_quorum" to verify the quorums of the league intersect.
+#
+# [fba_consensus.quorum_set]
+# threshold = 2
+# validators = ["<ADDRESS_1>", "<ADDRESS_2>"]
+#
+# [[fba_consensus.quorum_set.inner_sets]]
+# threshold = 1
+# validators = ["<ADDRESS_3>", "<ADDRESS_4>"]
+
 ##### BFT consensus configuration options #####
 [bft_consensus]
 
@@ -287,6 +371,13 @@ create_empty_blocks_interval = "{{ .BFTConsensusConfig.CreateEmptyBlocksInterval
 peer_gossip_sleep_duration = "{{ .BFTConsensusConfig.PeerGossipSleepDuration }}"
 peer_query_maj23_sleep_duration = "{{ .BFTConsensusConfig.PeerQueryMaj23SleepDuration }}"
 
+# Misbehaviors of this validator, to test the detection of byzantine
+# validators on a testnet. Never set them on a production node.
+#
+# Options: "double-prevote", "double-precommit", "conflicting-proposals",
+# "withhold-votes"
+byzantine_behaviors = [{{ range .BFTConsensusConfig.ByzantineBehaviors }}{{ printf "%q, " . }}{{end}}]
+
 ##### transactions indexer configuration options #####
 [tx_index]
 
@@ -361,6 +452,10 @@ hostname = "{{ .BaseLeagueConfig.Hostname }}"
 # and verifying their commits
 fast_sync = {{ .BaseLeagueConfig.FastSync }}
 
+# Consensus protocol of the league: bft | fba
+# Its settings are taken from the [bft_consensus] or [fba_consensus] section
+consensus_protocol = "{{ .BaseLeagueConfig.ConsensusProtocol }}"
+
 # Database backend: leveldb | memdb | cleveldb
 db_backend = "{{ .BaseLeagueConfig.DBBackend }}"
 
@@ -385,12 +480,44 @@ validator_key_file = "{{ js .BaseLeagueConfig.ValidatorKey }}"
 validator_state_file = "{{ js .BaseLeagueConfig.ValidatorState }}"
 
 # TCP or UNIX socket address for Dgrid to listen on for
-# connections from an external Validator process
+# connections from an external Validator process, or the address of an
+# external Validator process serving the Signer gRPC service for Dgrid to
+# dial, as grpc://ID@host:port, where ID is the ID of its node key.
+# The gRPC connections are authenticated with the node keys of both ends
 validator_laddr = "{{ .BaseLeagueConfig.ValidatorListenAddr }}"
 
+# TCP or UNIX socket addresses for Dgrid to listen on for connections from the
+# external Validator processes of the co-signers of a threshold validator,
+# whose votes are signed by validator_threshold of them, or their gRPC
+# addresses, see validator_laddr. Exclusive with validator_laddr
+validator_cosigner_laddrs = [{{ range .BaseLeagueConfig.ValidatorCosignerListenAddrs }}{{ printf "%q, " . }}{{end}}]
+validator_threshold = {{ .BaseLeagueConfig.ValidatorThreshold }}
+
+# TCP or UNIX socket addresses for Dgrid to listen on for connections from the
+# external Validator processes of the same key in high availability: Dgrid
+# signs with one of them, and fails over to another one. The Validator
+# processes must share their sign state, eg. in a locked file on a shared
+# file system, see the shared_state_dir of dgrid signer. gRPC addresses are
+# accepted, see validator_laddr.
+# Exclusive with validator_laddr and validator_cosigner_laddrs
+validator_failover_laddrs = [{{ range .BaseLeagueConfig.ValidatorFailoverListenAddrs }}{{ printf "%q, " . }}{{end}}]
+
+# Path to the JSON file containing the last sign state of the validator of
+# validator_failover_laddrs: Dgrid never asks the next Validator process to
+# sign a message conflicting with one signed before failing over
+validator_failover_state_file = "{{ js .BaseLeagueConfig.ValidatorFailoverState }}"
+
 # Path to the JSON file containing the private key to use for node authentication in the p2p protocol
 node_key_file = "{{ js .BaseLeagueConfig.NodeKey }}"
 
+# Source of the passphrase of the encrypted validator and node key files:
+#   "prompt" to prompt for it on the terminal
+#   "env:NAME" to read it from the environment variable NAME
+#   "fd:N" to read it from the file descriptor N
+# New key files are encrypted if it is set. See the encrypt_keys command to
+# encrypt the existing ones
+key_passphrase = "{{ .BaseLeagueConfig.KeyPassphrase }}"
+
 # Mechanism to connect to the Asura application: socket | grpc
 asura = "{{ .BaseLeagueConfig.Asura }}"
 
@@ -561,6 +688,19 @@ create_empty_blocks_interval = "{{ .FBAConsensusConfig.CreateEmptyBlocksInterval
 peer_gossip_sleep_duration = "{{ .FBAConsensusConfig.PeerGossipSleepDuration }}"
 peer_query_maj23_sleep_duration = "{{ .FBAConsensusConfig.PeerQueryMaj23SleepDuration }}"
 
+# Quorum slices of this node: any set of nodes satisfying threshold of the
+# validators (hex addresses) and inner sets is a slice. If unset, the quorum
+# set declared in the genesis file, or +2/3 of the validators, is used.
+# Use "dgrid check_quorum" to verify the quorums of the league intersect.
+#
+# [fba_consensus.quorum_set]
+# threshold = 2
+# validators = ["<ADDRESS_1>", "<ADDRESS_2>"]
+#
+# [[fba_consensus.quorum_set.inner_sets]]
+# threshold = 1
+# validators = ["<ADDRESS_3>", "<ADDRESS_4>"]
+
 ##### BFT consensus configuration options #####
 [bft_consensus]
 
@@ -585,6 +725,13 @@ create_empty_blocks_interval = "{{ .BFTConsensusConfig.CreateEmptyBlocksInterval
 peer_gossip_sleep_duration = "{{ .BFTConsensusConfig.PeerGossipSleepDuration }}"
 peer_query_maj23_sleep_duration = "{{ .BFTConsensusConfig.PeerQueryMaj23SleepDuration }}"
 
+# Misbehaviors of this validator, to test the detection of byzantine
+# validators on a testnet. Never set them on a production node.
+#
+# Options: "double-prevote", "double-precommit", "conflicting-proposals",
+# "withhold-votes"
+byzantine_behaviors = [{{ range .BFTConsensusConfig.ByzantineBehaviors }}{{ printf "%q, " . }}{{end}}]
+
 ##### transactions indexer configuration options #####
 [tx_index]
 
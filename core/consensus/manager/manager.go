@@ -0,0 +1,1046 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+
+	cfg "github.com/teragrid/dgrid/core/config"
+	"github.com/teragrid/dgrid/core/consensus/protocols"
+	"github.com/teragrid/dgrid/core/types"
+	cmn "github.com/teragrid/dgrid/pkg/common"
+	dbm "github.com/teragrid/dgrid/pkg/db"
+	"github.com/teragrid/dgrid/pkg/log"
+	sm "github.com/teragrid/dgrid/state"
+)
+
+//-----------------------------------------------------------------------------
+// Errors
+
+var (
+	ErrLeagueExists        = errors.New("Error league already managed")
+	ErrUnknownLeague       = errors.New("Error unknown league")
+	ErrUnknownProtocol     = errors.New("Error unknown consensus protocol")
+	ErrLeagueNotConfigured = errors.New("Error league has no configuration")
+	ErrPipelinedFastSync   = errors.New("Error fast sync doesn't execute the blocks of leagues pipelining the execution")
+	ErrMinorityCommit      = errors.New("Error the last block wasn't committed by +2/3 of the voting power")
+	ErrHeightStarted       = errors.New("Error the engine may have started the height already")
+)
+
+//-----------------------------------------------------------------------------
+
+// TxNotifier notifies the engine when transactions are available.
+type TxNotifier interface {
+	TxsAvailable() <-chan struct{}
+}
+
+// EvidencePool collects the evidence detected by the engine.
+type EvidencePool interface {
+	AddEvidence(types.Evidence) error
+}
+
+// League holds everything the consensus engine of a league needs. Every
+// league has its own block store, WAL and validator signer, so the engines
+// of a cell never share any consensus state.
+type League struct {
+	ID       string
+	Protocol cfg.ConsensusProtocol
+	// *cfg.BFTConsensusConfig or *cfg.FBAConsensusConfig, depending on the
+	// protocol. If nil, it is taken from the league configuration stored
+	// with cfg.StoreLeagueConfig.
+	Config cfg.Config
+
+	State        sm.State
+	BlockExec    *sm.BlockExecutor
+	BlockStore   sm.BlockStore
+	TxNotifier   TxNotifier
+	EvidencePool EvidencePool
+	EventBus     *types.EventBus
+
+	// Validator signs the votes of this league. It may be nil if the
+	// cell only follows the league.
+	Validator types.Validator
+
+	// ConflictingSigner signs the conflicting votes and proposals of a
+	// byzantine validator, see cfg.BFTConsensusConfig.ByzantineBehaviors.
+	// It holds the key of Validator, without its double-sign protection.
+	ConflictingSigner types.Validator
+
+	// QuorumSet overrides the quorum slices of the local validator in
+	// FBA leagues.
+	QuorumSet *types.QuorumSet
+
+	// QuorumSets are the quorum slices the validators declared in the
+	// genesis file of FBA leagues, see types.GenesisDoc.QuorumSets.
+	QuorumSets map[string]*types.QuorumSet
+
+	// Metrics of the engine. If nil, the engine records no metrics.
+	Metrics *protocols.Metrics
+
+	// FastSync is true while the block store of the league catches up with
+	// the league. The manager doesn't start the engine until StartLeague is
+	// called, eg. by the reactor once it switches to consensus. Leagues
+	// pipelining the execution can't fast sync.
+	FastSync bool
+
+	// StateDB saves the pipelined states of the league if it pipelines the
+	// execution, so a restarted engine resumes the pipeline.
+	StateDB dbm.DB
+}
+
+// EngineFactory creates the engine of a league for a consensus protocol.
+type EngineFactory func(league *League) (protocols.ConsensusEngine, error)
+
+var (
+	protocolsMtx    sync.RWMutex
+	factories       = make(map[cfg.ConsensusProtocol]EngineFactory)
+	configProtocols = make(map[reflect.Type]cfg.ConsensusProtocol)
+)
+
+func init() {
+	RegisterProtocol(cfg.BFTConsensusProtocol, (*cfg.BFTConsensusConfig)(nil), newBFTEngine)
+	RegisterProtocol(cfg.FBAConsensusProtocol, (*cfg.FBAConsensusConfig)(nil), newFBAEngine)
+}
+
+// RegisterProtocol registers the factory of the engines of a consensus
+// protocol, and the type of its consensus config, so that cells can run
+// it without any other change. It replaces any factory previously
+// registered for the protocol.
+func RegisterProtocol(protocol cfg.ConsensusProtocol, config cfg.Config, factory EngineFactory) {
+	protocolsMtx.Lock()
+	defer protocolsMtx.Unlock()
+	factories[protocol] = factory
+	configProtocols[reflect.TypeOf(config)] = protocol
+}
+
+func engineFactory(protocol cfg.ConsensusProtocol) (EngineFactory, bool) {
+	protocolsMtx.RLock()
+	defer protocolsMtx.RUnlock()
+	factory, ok := factories[protocol]
+	return factory, ok
+}
+
+// ProtocolOf returns the consensus protocol configured by a consensus config.
+func ProtocolOf(config cfg.Config) (cfg.ConsensusProtocol, error) {
+	protocolsMtx.RLock()
+	defer protocolsMtx.RUnlock()
+	protocol, ok := configProtocols[reflect.TypeOf(config)]
+	if !ok {
+		return 0, ErrUnknownProtocol
+	}
+	return protocol, nil
+}
+
+// NewEngine creates the engine of a league according to its consensus
+// protocol. Unlike AddLeague, the engine isn't managed.
+func NewEngine(league *League) (protocols.ConsensusEngine, error) {
+	factory, ok := engineFactory(league.Protocol)
+	if !ok {
+		return nil, ErrUnknownProtocol
+	}
+	return factory(league)
+}
+
+func newBFTEngine(league *League) (protocols.ConsensusEngine, error) {
+	config, ok := league.Config.(*cfg.BFTConsensusConfig)
+	if !ok {
+		return nil, fmt.Errorf("Expected a BFT consensus config for league %s, got %T", league.ID, league.Config)
+	}
+	var options []protocols.BFTOption
+	if league.Metrics != nil {
+		options = append(options, protocols.BFTMetrics(league.Metrics))
+	}
+	if league.StateDB != nil {
+		options = append(options, protocols.BFTStateDB(league.StateDB))
+	}
+	if behaviors := config.ByzantineBehaviors; len(behaviors) > 0 {
+		if err := cfg.ValidateByzantineBehaviors(behaviors); err != nil {
+			return nil, err
+		}
+		signs := len(behaviors) > 1 || behaviors[0] != cfg.ByzantineWithholdVotes
+		if signs && league.ConflictingSigner == nil {
+			return nil, fmt.Errorf("Byzantine behaviors %v of league %s need a conflicting signer, only built with the byzantine tag", behaviors, league.ID)
+		}
+		options = append(options, protocols.BFTByzantine(behaviors, league.ConflictingSigner))
+	}
+	return protocols.NewBFTConsensus(
+		config,
+		league.State,
+		league.BlockExec,
+		league.BlockStore,
+		league.TxNotifier,
+		league.EvidencePool,
+		options...,
+	), nil
+}
+
+func newFBAEngine(league *League) (protocols.ConsensusEngine, error) {
+	config, ok := league.Config.(*cfg.FBAConsensusConfig)
+	if !ok {
+		return nil, fmt.Errorf("Expected an FBA consensus config for league %s, got %T", league.ID, league.Config)
+	}
+	quorumSet := league.QuorumSet
+	if quorumSet == nil && config.QuorumSet != nil {
+		qs, err := config.QuorumSet.QuorumSet()
+		if err != nil {
+			return nil, err
+		}
+		quorumSet = qs
+	}
+	var options []protocols.FBAOption
+	if league.Metrics != nil {
+		options = append(options, protocols.FBAMetrics(league.Metrics))
+	}
+	if league.QuorumSets != nil {
+		options = append(options, protocols.FBAGenesisQuorumSets(league.QuorumSets))
+	}
+	if quorumSet != nil {
+		options = append(options, protocols.FBAQuorumSet(quorumSet))
+	}
+	return protocols.NewFBAConsensus(
+		config,
+		league.State,
+		league.BlockExec,
+		league.BlockStore,
+		league.TxNotifier,
+		league.EvidencePool,
+		options...,
+	), nil
+}
+
+// LeagueConsensusConfig returns the consensus protocol and configuration of
+// a league from the league configurations stored in core/config.
+func LeagueConsensusConfig(leagueID string) (cfg.ConsensusProtocol, cfg.Config, error) {
+	switch config := cfg.GetLeagueConfig(leagueID).(type) {
+	case *cfg.BaseLeagueConfig:
+		return leagueConsensus(config.BaseConfig, cfg.BaseLeagueType, config.Consensus)
+	case *cfg.RegularLeagueConfig:
+		return leagueConsensus(config.BaseConfig, cfg.RegularLeagueType, config.Consensus)
+	case nil:
+		return 0, nil, ErrLeagueNotConfigured
+	default:
+		return 0, nil, fmt.Errorf("Unknown configuration %T for league %s", config, leagueID)
+	}
+}
+
+// leagueConsensus returns the consensus protocol of a league config and its
+// consensus config for that protocol. The protocol is the consensus_protocol
+// of the config, else the one of its consensus config, else the default of
+// the league type. The consensus config is converted if it belongs to
+// another protocol.
+func leagueConsensus(base cfg.BaseConfig, leagueType cfg.LeagueType, consensus cfg.Config) (cfg.ConsensusProtocol, cfg.Config, error) {
+	var protocol cfg.ConsensusProtocol
+	switch {
+	case base.ConsensusProtocol != "":
+		var err error
+		if protocol, err = cfg.ParseConsensusProtocol(base.ConsensusProtocol); err != nil {
+			return 0, nil, err
+		}
+	case !isNilConfig(consensus):
+		var err error
+		if protocol, err = ProtocolOf(consensus); err != nil {
+			return 0, nil, err
+		}
+	default:
+		protocol = cfg.DefaultLeagueProtocol(leagueType)
+	}
+
+	if isNilConfig(consensus) {
+		switch protocol {
+		case cfg.BFTConsensusProtocol:
+			return protocol, *cfg.DefaultConsensusConfig(&cfg.BFTConsensusConfig{}), nil
+		case cfg.FBAConsensusProtocol:
+			return protocol, *cfg.DefaultConsensusConfig(&cfg.FBAConsensusConfig{}), nil
+		}
+		return 0, nil, ErrUnknownProtocol
+	}
+	if configProtocol, err := ProtocolOf(consensus); err == nil && configProtocol == protocol {
+		return protocol, consensus, nil
+	}
+	config, err := cfg.ConvertConsensusConfig(consensus, protocol)
+	return protocol, config, err
+}
+
+// isNilConfig tells whether a config is nil or a typed nil consensus config.
+func isNilConfig(config cfg.Config) bool {
+	switch c := config.(type) {
+	case nil:
+		return true
+	case *cfg.BFTConsensusConfig:
+		return c == nil
+	case *cfg.FBAConsensusConfig:
+		return c == nil
+	}
+	return false
+}
+
+// WALProvider opens the write-ahead log of a league at the given path.
+type WALProvider func(leagueID, walFile string) (protocols.WAL, error)
+
+// walFiler is implemented by the consensus configs.
+type walFiler interface {
+	WalFile() string
+}
+
+//-----------------------------------------------------------------------------
+
+// leagueEngine is a managed league and its running engine.
+type leagueEngine struct {
+	league  League
+	engine  protocols.ConsensusEngine
+	walFile string
+	started bool // engine was started, so it must be recreated to restart
+
+	// reconfigurations to come, by height
+	pending []*reconfiguration
+	// heights the league must not start until released, sorted
+	holds  []int64
+	halted bool // engine halted at a held height
+}
+
+// isHeld returns true if the league is held at the given height.
+func (le *leagueEngine) isHeld(height int64) bool {
+	i := sort.Search(len(le.holds), func(i int) bool { return le.holds[i] >= height })
+	return i < len(le.holds) && le.holds[i] == height
+}
+
+// hold adds a held height, and returns false if it was held already.
+func (le *leagueEngine) hold(height int64) bool {
+	i := sort.Search(len(le.holds), func(i int) bool { return le.holds[i] >= height })
+	if i < len(le.holds) && le.holds[i] == height {
+		return false
+	}
+	le.holds = append(le.holds, 0)
+	copy(le.holds[i+1:], le.holds[i:])
+	le.holds[i] = height
+	return true
+}
+
+// release removes a held height, and returns false if it wasn't held.
+func (le *leagueEngine) release(height int64) bool {
+	i := sort.Search(len(le.holds), func(i int) bool { return le.holds[i] >= height })
+	if i == len(le.holds) || le.holds[i] != height {
+		return false
+	}
+	le.holds = append(le.holds[:i], le.holds[i+1:]...)
+	return true
+}
+
+// reconfiguration is a consensus config a league switches to at a height.
+type reconfiguration struct {
+	height      int64
+	protocol    cfg.ConsensusProtocol
+	config      cfg.Config
+	updateState func(*sm.State)
+}
+
+// EngineListener is called when the engine of a league is replaced.
+type EngineListener func(leagueID string, engine protocols.ConsensusEngine)
+
+// Manager runs the consensus engines of all the leagues a cell takes part
+// in, typically the Base league and one or more Regular leagues. Each league
+// runs the engine of its own consensus protocol, and the engines are started
+// and stopped independently.
+type Manager struct {
+	cmn.BaseService
+
+	mtx         sync.RWMutex
+	leagues     map[string]*leagueEngine
+	walProvider WALProvider
+	listeners   []EngineListener
+	store       *ReconfigurationStore
+}
+
+// ManagerOption sets an optional parameter on the Manager.
+type ManagerOption func(*Manager)
+
+// WithWALProvider sets how the write-ahead log of each league is opened.
+// It defaults to DefaultWALProvider; without any, engines run without a WAL.
+func WithWALProvider(walProvider WALProvider) ManagerOption {
+	return func(m *Manager) { m.walProvider = walProvider }
+}
+
+// WithEngineListener registers a listener for the engines replaced by a
+// reconfiguration, eg. the reactor of the league.
+func WithEngineListener(listener EngineListener) ManagerOption {
+	return func(m *Manager) { m.listeners = append(m.listeners, listener) }
+}
+
+// WithReconfigurationStore persists the reconfigurations of the leagues in
+// store. A league added to the Manager runs the config in effect at its
+// next height, and the reconfigurations to come are scheduled again.
+func WithReconfigurationStore(store *ReconfigurationStore) ManagerOption {
+	return func(m *Manager) { m.store = store }
+}
+
+// NewManager returns a new Manager without any league.
+func NewManager(options ...ManagerOption) *Manager {
+	m := &Manager{
+		leagues:     make(map[string]*leagueEngine),
+		walProvider: DefaultWALProvider,
+	}
+	m.BaseService = *cmn.NewBaseService(nil, "ConsensusManager", m)
+	for _, option := range options {
+		option(m)
+	}
+	return m
+}
+
+// SetLogger implements Service.
+func (m *Manager) SetLogger(l log.Logger) {
+	m.BaseService.Logger = l
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	for id, le := range m.leagues {
+		le.engine.SetLogger(l.With("league", id))
+	}
+}
+
+// AddLeague creates the engine of a league according to its consensus
+// protocol, or to the persisted reconfigurations of the league, see
+// WithReconfigurationStore. If the manager is running, the engine is
+// started as well.
+func (m *Manager) AddLeague(league League) (protocols.ConsensusEngine, error) {
+	if league.Config == nil {
+		protocol, config, err := LeagueConsensusConfig(league.ID)
+		if err != nil {
+			return nil, err
+		}
+		league.Protocol, league.Config = protocol, config
+	} else if protocol, err := ProtocolOf(league.Config); err == nil && protocol != league.Protocol {
+		// the consensus config of another protocol, e.g. the typed config
+		// of a league whose consensus_protocol selects the other engine
+		if _, ok := engineFactory(league.Protocol); !ok {
+			return nil, ErrUnknownProtocol
+		}
+		config, err := cfg.ConvertConsensusConfig(league.Config, league.Protocol)
+		if err != nil {
+			return nil, err
+		}
+		league.Config = config
+	}
+
+	if league.FastSync && league.State.ConsensusParams.Execution.Pipelined {
+		return nil, ErrPipelinedFastSync
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if _, ok := m.leagues[league.ID]; ok {
+		return nil, ErrLeagueExists
+	}
+
+	le := &leagueEngine{league: league, walFile: walFileOf(league.Config)}
+	if err := m.loadReconfigurations(le); err != nil {
+		return nil, err
+	}
+	if err := m.checkWAL(league.ID, le.league.Config); err != nil {
+		return nil, err
+	}
+	if err := m.createEngine(le); err != nil {
+		return nil, err
+	}
+	m.leagues[league.ID] = le
+
+	if m.IsRunning() && !league.FastSync {
+		if err := m.startEngine(le); err != nil {
+			delete(m.leagues, league.ID)
+			return nil, err
+		}
+	}
+	return le.engine, nil
+}
+
+// RemoveLeague stops the engine of a league and forgets about it.
+func (m *Manager) RemoveLeague(leagueID string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	le, ok := m.leagues[leagueID]
+	if !ok {
+		return ErrUnknownLeague
+	}
+	delete(m.leagues, leagueID)
+	return m.stopEngine(le)
+}
+
+// StartLeague starts the engine of a league, which is done fast syncing if it
+// was. An engine which was stopped is recreated from its last state.
+func (m *Manager) StartLeague(leagueID string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	le, ok := m.leagues[leagueID]
+	if !ok {
+		return ErrUnknownLeague
+	}
+	le.league.FastSync = false
+	if le.engine.IsRunning() {
+		return nil
+	}
+	if le.started {
+		le.league.State = le.engine.GetState()
+		if err := m.createEngine(le); err != nil {
+			return err
+		}
+	}
+	return m.startEngine(le)
+}
+
+// StopLeague stops the engine of a league, leaving the other leagues running.
+func (m *Manager) StopLeague(leagueID string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	le, ok := m.leagues[leagueID]
+	if !ok {
+		return ErrUnknownLeague
+	}
+	return m.stopEngine(le)
+}
+
+// ReconfigureLeague replaces the engine of a league by one running the given
+// consensus config, possibly of another protocol, from the last state of the
+// current engine. updateState, if not nil, may change that state first, eg.
+// its consensus params. The new engine is started if the old one was running.
+// The new engine needs the last block committed by +2/3 of the voting power,
+// the reconfiguration fails with ErrMinorityCommit otherwise.
+func (m *Manager) ReconfigureLeague(leagueID string, config cfg.Config, updateState func(*sm.State)) error {
+	protocol, err := ProtocolOf(config)
+	if err != nil {
+		return err
+	}
+
+	m.mtx.Lock()
+	le, ok := m.leagues[leagueID]
+	if !ok {
+		m.mtx.Unlock()
+		return ErrUnknownLeague
+	}
+	r := &reconfiguration{
+		height:      le.engine.GetState().LastBlockHeight + 1,
+		protocol:    protocol,
+		config:      config,
+		updateState: updateState,
+	}
+	if err := m.checkWAL(leagueID, config); err != nil {
+		m.mtx.Unlock()
+		return err
+	}
+	if err := checkLastCommit(le.league.BlockStore, le.engine.GetState()); err != nil {
+		m.mtx.Unlock()
+		return err
+	}
+	if err := m.saveReconfiguration(leagueID, r); err != nil {
+		m.mtx.Unlock()
+		return err
+	}
+	err = m.reconfigure(le, r)
+	engine := le.engine
+	m.mtx.Unlock()
+	if err != nil {
+		return err
+	}
+
+	m.notifyListeners(leagueID, engine)
+	return nil
+}
+
+// ReconfigureLeagueAt schedules the reconfiguration of a league at a height,
+// see ReconfigureLeague. The current engine commits the blocks up to
+// height-1, then halts, and the new engine starts the height from the
+// state of the last commit of the current one. Both engines share the block
+// store and the WAL of the league, so a league migrates between BFT and FBA
+// without losing its history.
+//
+// A reconfiguration at a height the engine may have started already is
+// applied at once. A reconfiguration replaces any other one at the same
+// height. The reconfigurations are persisted, see WithReconfigurationStore.
+func (m *Manager) ReconfigureLeagueAt(leagueID string, height int64, config cfg.Config,
+	updateState func(*sm.State)) error {
+	protocol, err := ProtocolOf(config)
+	if err != nil {
+		return err
+	}
+	r := &reconfiguration{
+		height:      height,
+		protocol:    protocol,
+		config:      config,
+		updateState: updateState,
+	}
+
+	m.mtx.Lock()
+	le, ok := m.leagues[leagueID]
+	if !ok {
+		m.mtx.Unlock()
+		return ErrUnknownLeague
+	}
+	if err := m.checkWAL(leagueID, config); err != nil {
+		m.mtx.Unlock()
+		return err
+	}
+
+	nextHeight := le.engine.GetState().LastBlockHeight + 1
+	// a held engine halted at the next height runs it once released
+	scheduled := height > nextHeight || (height == nextHeight && (!le.engine.IsRunning() || le.halted))
+	if !scheduled && r.height < nextHeight {
+		// in effect from the next height on
+		r.height = nextHeight
+	}
+	if !scheduled {
+		if err := checkLastCommit(le.league.BlockStore, le.engine.GetState()); err != nil {
+			m.mtx.Unlock()
+			return err
+		}
+	}
+	if err := m.saveReconfiguration(leagueID, r); err != nil {
+		m.mtx.Unlock()
+		return err
+	}
+
+	if scheduled {
+		m.schedule(le, r)
+		var err error
+		if le.pending[0] == r && !le.halted {
+			err = m.setHaltHeight(le)
+		}
+		m.mtx.Unlock()
+		if err == nil {
+			m.Logger.Info("Scheduled consensus reconfiguration", "league", leagueID,
+				"protocol", protocol, "height", height)
+		}
+		return err
+	}
+
+	m.Logger.Info("Reconfiguration height reached, reconfiguring now", "league", leagueID,
+		"height", height, "nextHeight", nextHeight)
+	// it replaces the reconfigurations due
+	for len(le.pending) > 0 && le.pending[0].height <= r.height {
+		le.pending = le.pending[1:]
+	}
+	err = m.reconfigure(le, r)
+	engine := le.engine
+	m.mtx.Unlock()
+	if err != nil {
+		return err
+	}
+	m.notifyListeners(leagueID, engine)
+	return nil
+}
+
+// schedule inserts a reconfiguration in the pending ones of a league.
+func (m *Manager) schedule(le *leagueEngine, r *reconfiguration) {
+	i := sort.Search(len(le.pending), func(i int) bool { return le.pending[i].height >= r.height })
+	if i < len(le.pending) && le.pending[i].height == r.height {
+		le.pending[i] = r
+		return
+	}
+	le.pending = append(le.pending, nil)
+	copy(le.pending[i+1:], le.pending[i:])
+	le.pending[i] = r
+}
+
+// HoldLeagueAt makes the engine of a league halt before the given height
+// until the height is released by ReleaseLeagueAt, eg. while the Base league
+// didn't decide yet whether the league is reconfigured at that height. It
+// fails with ErrHeightStarted if the engine may have started the height.
+func (m *Manager) HoldLeagueAt(leagueID string, height int64) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	le, ok := m.leagues[leagueID]
+	if !ok {
+		return ErrUnknownLeague
+	}
+	nextHeight := le.engine.GetState().LastBlockHeight + 1
+	if height < nextHeight || (height == nextHeight && le.engine.IsRunning() && !le.halted) {
+		return ErrHeightStarted
+	}
+	if !le.hold(height) || le.halted {
+		return nil
+	}
+	m.Logger.Info("Holding league", "league", leagueID, "height", height)
+	return m.setHaltHeight(le)
+}
+
+// ReleaseLeagueAt releases a height held by HoldLeagueAt. An engine halted
+// at that height is replaced by one running the reconfigurations due at the
+// height, if any, or the current config of the league.
+func (m *Manager) ReleaseLeagueAt(leagueID string, height int64) error {
+	m.mtx.Lock()
+	le, ok := m.leagues[leagueID]
+	if !ok {
+		m.mtx.Unlock()
+		return ErrUnknownLeague
+	}
+	if !le.release(height) {
+		m.mtx.Unlock()
+		return nil
+	}
+	m.Logger.Info("Releasing league", "league", leagueID, "height", height)
+	if !le.halted {
+		err := m.setHaltHeight(le)
+		m.mtx.Unlock()
+		return err
+	}
+	nextHeight := le.engine.GetState().LastBlockHeight + 1
+	if le.isHeld(nextHeight) {
+		m.mtx.Unlock()
+		return nil
+	}
+	err := m.reconfigureDue(le, nextHeight)
+	engine := le.engine
+	m.mtx.Unlock()
+	if err != nil {
+		return err
+	}
+	m.notifyListeners(leagueID, engine)
+	return nil
+}
+
+// setHaltHeight makes the engine of a league halt at the height of the
+// first pending reconfiguration or held height, or right away if it is
+// overdue.
+func (m *Manager) setHaltHeight(le *leagueEngine) error {
+	var height int64
+	if len(le.pending) > 0 {
+		height = le.pending[0].height
+	}
+	if len(le.holds) > 0 && (height == 0 || le.holds[0] < height) {
+		height = le.holds[0]
+	}
+	if height == 0 {
+		return le.engine.SetHaltHeight(0, nil)
+	}
+	leagueID, engine := le.league.ID, le.engine
+	if nextHeight := engine.GetState().LastBlockHeight + 1; height < nextHeight {
+		height = nextHeight
+	}
+	return engine.SetHaltHeight(height, func(state sm.State) {
+		m.onHalt(leagueID, engine, state)
+	})
+}
+
+// onHalt reconfigures a league once its engine halted, and arms the next
+// pending reconfiguration on the new engine. An engine halted at a held
+// height is left halted until the height is released.
+func (m *Manager) onHalt(leagueID string, engine protocols.ConsensusEngine, state sm.State) {
+	m.mtx.Lock()
+	le, ok := m.leagues[leagueID]
+	if !ok || le.engine != engine {
+		// the league was removed or reconfigured meanwhile
+		m.mtx.Unlock()
+		return
+	}
+
+	nextHeight := state.LastBlockHeight + 1
+	if le.isHeld(nextHeight) {
+		le.halted = true
+		m.mtx.Unlock()
+		m.Logger.Info("League is held", "league", leagueID, "height", nextHeight)
+		return
+	}
+	err := m.reconfigureDue(le, nextHeight)
+	newEngine := le.engine
+	m.mtx.Unlock()
+	if err != nil {
+		m.Logger.Error("Error reconfiguring league", "league", leagueID, "height", nextHeight, "err", err)
+		return
+	}
+	m.notifyListeners(leagueID, newEngine)
+}
+
+// reconfigureDue replaces the halted engine of a league by one running the
+// reconfigurations due at the next height, the last one winning, or the
+// current config if none is due. m.mtx must be held.
+func (m *Manager) reconfigureDue(le *leagueEngine, nextHeight int64) error {
+	var due []*reconfiguration
+	for len(le.pending) > 0 && le.pending[0].height <= nextHeight {
+		due = append(due, le.pending[0])
+		le.pending = le.pending[1:]
+	}
+	if len(due) == 0 {
+		return m.restartEngine(le)
+	}
+	r := *due[len(due)-1]
+	r.updateState = func(state *sm.State) {
+		for _, d := range due {
+			if d.updateState != nil {
+				d.updateState(state)
+			}
+		}
+	}
+	return m.reconfigure(le, &r)
+}
+
+// restartEngine replaces the halted engine of a league by a new one with
+// the same config. m.mtx must be held.
+func (m *Manager) restartEngine(le *leagueEngine) error {
+	running := le.engine.IsRunning()
+	if err := m.stopEngine(le); err != nil {
+		return err
+	}
+	if running {
+		le.engine.Wait()
+	}
+	le.league.State = le.engine.GetState()
+	if err := m.createEngine(le); err != nil {
+		return err
+	}
+	m.Logger.Info("Restarted consensus engine", "league", le.league.ID,
+		"height", le.league.State.LastBlockHeight+1)
+	if running {
+		return m.startEngine(le)
+	}
+	return nil
+}
+
+// reconfigure replaces the engine of a league. m.mtx must be held.
+func (m *Manager) reconfigure(le *leagueEngine, r *reconfiguration) error {
+	leagueID := le.league.ID
+	if err := m.checkWAL(leagueID, r.config); err != nil {
+		return err
+	}
+	// the new engine takes the commit of the last block as its last commit
+	if err := checkLastCommit(le.league.BlockStore, le.engine.GetState()); err != nil {
+		return err
+	}
+
+	running := le.engine.IsRunning()
+	if err := m.stopEngine(le); err != nil {
+		return err
+	}
+	if running {
+		// the WAL is released once the engine is done
+		le.engine.Wait()
+	}
+
+	le.league.State = le.engine.GetState()
+	if r.updateState != nil {
+		r.updateState(&le.league.State)
+	}
+	le.league.Protocol, le.league.Config = r.protocol, r.config
+	le.walFile = walFileOf(r.config)
+	if err := m.createEngine(le); err != nil {
+		return err
+	}
+	if m.store != nil {
+		// the reconfiguration is the one in effect from now on
+		m.store.prune(leagueID, r.height)
+	}
+	m.Logger.Info("Reconfigured consensus engine", "league", leagueID, "protocol", r.protocol,
+		"height", le.league.State.LastBlockHeight+1)
+
+	if running {
+		return m.startEngine(le)
+	}
+	return nil
+}
+
+// checkLastCommit checks that the last block of a state was committed by
+// +2/3 of the voting power of its validators. The BFT engine can't take
+// over a league from a commit of a smaller FBA quorum.
+func checkLastCommit(blockStore sm.BlockStore, state sm.State) error {
+	if blockStore == nil || state.LastBlockHeight == 0 {
+		return nil
+	}
+	commit := blockStore.LoadSeenCommit(state.LastBlockHeight)
+	if commit == nil {
+		return fmt.Errorf("Error no commit for the block %d of league %s", state.LastBlockHeight, state.LeagueID)
+	}
+	precommits := types.NewVoteSet(state.LeagueID, state.LastBlockHeight, commit.Round(), types.PrecommitType, state.LastValidators)
+	for _, precommit := range commit.Precommits {
+		if precommit == nil {
+			continue
+		}
+		if _, err := precommits.AddVote(commit.ToVote(precommit)); err != nil {
+			return err
+		}
+	}
+	if !precommits.HasTwoThirdsMajority() {
+		return ErrMinorityCommit
+	}
+	return nil
+}
+
+// saveReconfiguration persists a reconfiguration of a league, if the
+// reconfigurations are persisted.
+func (m *Manager) saveReconfiguration(leagueID string, r *reconfiguration) error {
+	if m.store == nil {
+		return nil
+	}
+	return m.store.save(leagueID, r)
+}
+
+// loadReconfigurations restores the persisted reconfigurations of a league:
+// the last one up to its next height is in effect, and the later ones are
+// scheduled. m.mtx must be held.
+func (m *Manager) loadReconfigurations(le *leagueEngine) error {
+	if m.store == nil {
+		return nil
+	}
+	rs, err := m.store.load(le.league.ID)
+	if err != nil {
+		return err
+	}
+	nextHeight := le.league.State.LastBlockHeight + 1
+	for _, r := range rs {
+		if r.height > nextHeight {
+			m.schedule(le, r)
+			continue
+		}
+		if r.height == nextHeight {
+			// the reconfigured engine takes over from the last commit
+			if err := checkLastCommit(le.league.BlockStore, le.league.State); err != nil {
+				return err
+			}
+		}
+		le.league.Protocol, le.league.Config = r.protocol, r.config
+		le.walFile = walFileOf(r.config)
+	}
+	return nil
+}
+
+// checkWAL returns an error if config uses the WAL of another league.
+// m.mtx must be held.
+func (m *Manager) checkWAL(leagueID string, config cfg.Config) error {
+	walFile := walFileOf(config)
+	if walFile == "" {
+		return nil
+	}
+	for id, le := range m.leagues {
+		if id != leagueID && le.walFile == walFile {
+			return fmt.Errorf("League %s uses the WAL %s of league %s", leagueID, walFile, id)
+		}
+	}
+	return nil
+}
+
+// walFileOf returns the WAL file of a consensus config, if any.
+func walFileOf(config cfg.Config) string {
+	if filer, ok := config.(walFiler); ok {
+		return filepath.Clean(filer.WalFile())
+	}
+	return ""
+}
+
+func (m *Manager) notifyListeners(leagueID string, engine protocols.ConsensusEngine) {
+	for _, listener := range m.listeners {
+		listener(leagueID, engine)
+	}
+}
+
+// Engine returns the engine of a league.
+func (m *Manager) Engine(leagueID string) (protocols.ConsensusEngine, bool) {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	le, ok := m.leagues[leagueID]
+	if !ok {
+		return nil, false
+	}
+	return le.engine, true
+}
+
+// Leagues returns the IDs of the managed leagues, sorted.
+func (m *Manager) Leagues() []string {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	ids := make([]string, 0, len(m.leagues))
+	for id := range m.leagues {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// OnStart implements cmn.Service.
+// It starts the engines of all the leagues which aren't fast syncing.
+func (m *Manager) OnStart() error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for _, id := range m.sortedIDs() {
+		le := m.leagues[id]
+		if le.league.FastSync {
+			m.Logger.Info("Waiting for fast sync to start consensus engine", "league", id)
+			continue
+		}
+		if err := m.startEngine(le); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OnStop implements cmn.Service.
+// It stops the engines of all the leagues.
+func (m *Manager) OnStop() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for _, id := range m.sortedIDs() {
+		if err := m.stopEngine(m.leagues[id]); err != nil {
+			m.Logger.Error("Error stopping consensus engine", "league", id, "err", err)
+		}
+	}
+}
+
+func (m *Manager) sortedIDs() []string {
+	ids := make([]string, 0, len(m.leagues))
+	for id := range m.leagues {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (m *Manager) createEngine(le *leagueEngine) error {
+	league := &le.league
+	engine, err := NewEngine(league)
+	if err != nil {
+		return err
+	}
+
+	engine.SetLogger(m.Logger.With("league", league.ID))
+	if league.EventBus != nil {
+		engine.SetEventBus(league.EventBus)
+	}
+	if league.Validator != nil {
+		engine.SetValidator(league.Validator)
+	}
+	if m.walProvider != nil && le.walFile != "" {
+		wal, err := m.walProvider(league.ID, le.walFile)
+		if err != nil {
+			return err
+		}
+		if l, ok := wal.(interface{ SetLogger(log.Logger) }); ok {
+			l.SetLogger(m.Logger.With("league", league.ID, "module", "wal"))
+		}
+		engine.SetWAL(wal)
+	}
+
+	le.engine = engine
+	le.started = false
+	le.halted = false
+	return m.setHaltHeight(le)
+}
+
+func (m *Manager) startEngine(le *leagueEngine) error {
+	if le.engine.IsRunning() {
+		return nil
+	}
+	if err := le.engine.Start(); err != nil {
+		return err
+	}
+	le.started = true
+	m.Logger.Info("Started consensus engine", "league", le.league.ID, "engine", le.engine)
+	return nil
+}
+
+func (m *Manager) stopEngine(le *leagueEngine) error {
+	if !le.engine.IsRunning() {
+		return nil
+	}
+	if err := le.engine.Stop(); err != nil {
+		return err
+	}
+	m.Logger.Info("Stopped consensus engine", "league", le.league.ID)
+	return nil
+}
@@ -0,0 +1,474 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teragrid/dgrid/core/blockchain/p2p/conn"
+	cfg "github.com/teragrid/dgrid/core/config"
+	"github.com/teragrid/dgrid/core/consensus/protocols"
+	"github.com/teragrid/dgrid/core/types"
+	ttime "github.com/teragrid/dgrid/core/types/time"
+	cmn "github.com/teragrid/dgrid/pkg/common"
+	dbm "github.com/teragrid/dgrid/pkg/db"
+	sm "github.com/teragrid/dgrid/state"
+)
+
+const testProtocol = cfg.ConsensusProtocol(100)
+
+type testEngine struct {
+	cmn.BaseService
+	league *League
+	wal    protocols.WAL
+	val    types.Validator
+
+	haltHeight int64
+	onHalt     func(state sm.State)
+}
+
+// testConfig is the consensus config of testProtocol.
+type testConfig struct {
+	cfg.BFTConsensusConfig
+}
+
+func newTestEngine(league *League) (protocols.ConsensusEngine, error) {
+	e := &testEngine{league: league}
+	e.BaseService = *cmn.NewBaseService(nil, "testEngine", e)
+	return e, nil
+}
+
+func (e *testEngine) Wait()                                      {}
+func (e *testEngine) Protocol() cfg.ConsensusProtocol            { return testProtocol }
+func (e *testEngine) SetEventBus(b *types.EventBus)              {}
+func (e *testEngine) SetWAL(wal protocols.WAL)                   { e.wal = wal }
+func (e *testEngine) SetValidator(val types.Validator)           { e.val = val }
+func (e *testEngine) GetChannels() []*conn.ChannelDescriptor     { return nil }
+func (e *testEngine) GetState() sm.State                         { return e.league.State }
+func (e *testEngine) GetLastHeight() int64                       { return 0 }
+func (e *testEngine) GetValidators() (int64, []*types.Validator) { return 0, nil }
+func (e *testEngine) GetRoundStateJSON() ([]byte, error)         { return nil, nil }
+func (e *testEngine) GetRoundStateSimpleJSON() ([]byte, error)   { return nil, nil }
+func (e *testEngine) LoadCommit(height int64) *types.Commit      { return nil }
+func (e *testEngine) SwitchToState(state sm.State)               {}
+func (e *testEngine) ReplayMessage(msg protocols.WALMessage) error {
+	return nil
+}
+func (e *testEngine) ReceiveMessage(msg protocols.ConsensusMessage, peerID types.P2PID) error {
+	return nil
+}
+func (e *testEngine) AddBroadcastListener(listenerID string, cb func(*protocols.Broadcast)) error {
+	return nil
+}
+func (e *testEngine) RemoveBroadcastListener(listenerID string) {}
+func (e *testEngine) SetHaltHeight(height int64, onHalt func(state sm.State)) error {
+	e.haltHeight, e.onHalt = height, onHalt
+	return nil
+}
+
+func init() {
+	RegisterProtocol(testProtocol, (*testConfig)(nil), newTestEngine)
+	// the test engines don't write their WAL
+	DefaultWALProvider = nil
+}
+
+// commitStore is a block store holding the seen commit of the last block.
+type commitStore struct {
+	sm.BlockStore
+	commit *types.Commit
+}
+
+func (s *commitStore) LoadSeenCommit(height int64) *types.Commit { return s.commit }
+
+// signedCommit returns the commit of a block precommitted by some validators.
+func signedCommit(t *testing.T, state sm.State, privVals []types.Validator, signers int) *types.Commit {
+	blockID := types.BlockID{Hash: []byte("block")}
+	precommits := make([]*types.CommitSig, len(privVals))
+	for i, pv := range privVals[:signers] {
+		vote := &types.Vote{
+			ValidatorAddress: pv.GetPubKey().Address(),
+			ValidatorIndex:   i,
+			Height:           state.LastBlockHeight,
+			Type:             types.PrecommitType,
+			BlockID:          blockID,
+			Timestamp:        ttime.Now(),
+		}
+		require.NoError(t, pv.SignVote(state.LeagueID, vote))
+		precommits[i] = vote.CommitSig()
+	}
+	return types.NewCommit(blockID, precommits)
+}
+
+func testLeague(id string) League {
+	config := &testConfig{cfg.BFTConsensusConfig{WalPath: id + "/cs.wal/wal", RootDir: "/tmp"}}
+	return League{ID: id, Protocol: testProtocol, Config: config, Validator: types.NewMockPV()}
+}
+
+func TestManagerIndependentLeagues(t *testing.T) {
+	m := NewManager()
+	base, err := m.AddLeague(testLeague("base"))
+	require.NoError(t, err)
+	regular, err := m.AddLeague(testLeague("regular"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"base", "regular"}, m.Leagues())
+	assert.NotNil(t, base.(*testEngine).val)
+
+	_, err = m.AddLeague(testLeague("base"))
+	assert.Equal(t, ErrLeagueExists, err)
+
+	require.NoError(t, m.Start())
+	assert.True(t, base.IsRunning())
+	assert.True(t, regular.IsRunning())
+
+	// stopping a league leaves the others running
+	require.NoError(t, m.StopLeague("base"))
+	assert.False(t, base.IsRunning())
+	assert.True(t, regular.IsRunning())
+
+	// a stopped engine is recreated on restart
+	require.NoError(t, m.StartLeague("base"))
+	restarted, ok := m.Engine("base")
+	require.True(t, ok)
+	assert.True(t, restarted.IsRunning())
+	assert.False(t, restarted == base)
+
+	require.NoError(t, m.RemoveLeague("regular"))
+	assert.False(t, regular.IsRunning())
+	assert.Equal(t, ErrUnknownLeague, m.StopLeague("regular"))
+
+	require.NoError(t, m.Stop())
+	assert.False(t, restarted.IsRunning())
+}
+
+func TestManagerFastSyncLeague(t *testing.T) {
+	m := NewManager()
+	syncing := testLeague("syncing")
+	syncing.FastSync = true
+	engine, err := m.AddLeague(syncing)
+	require.NoError(t, err)
+	other, err := m.AddLeague(testLeague("other"))
+	require.NoError(t, err)
+
+	// the engine of a fast syncing league waits for StartLeague
+	require.NoError(t, m.Start())
+	assert.False(t, engine.IsRunning())
+	assert.True(t, other.IsRunning())
+
+	require.NoError(t, m.StartLeague("syncing"))
+	assert.True(t, engine.IsRunning())
+
+	require.NoError(t, m.Stop())
+	assert.False(t, engine.IsRunning())
+}
+
+func TestManagerSeparateWALs(t *testing.T) {
+	walFiles := make(map[string]string)
+	m := NewManager(WithWALProvider(func(leagueID, walFile string) (protocols.WAL, error) {
+		walFiles[leagueID] = walFile
+		return nil, nil
+	}))
+	_, err := m.AddLeague(testLeague("base"))
+	require.NoError(t, err)
+	_, err = m.AddLeague(testLeague("regular"))
+	require.NoError(t, err)
+	assert.Equal(t, "/tmp/base/cs.wal/wal", walFiles["base"])
+	assert.Equal(t, "/tmp/regular/cs.wal/wal", walFiles["regular"])
+
+	// two leagues can't share a WAL
+	shared := testLeague("shared")
+	shared.Config = &testConfig{cfg.BFTConsensusConfig{WalPath: "base/cs.wal/wal", RootDir: "/tmp"}}
+	_, err = m.AddLeague(shared)
+	assert.Error(t, err)
+}
+
+func TestManagerUnknownProtocol(t *testing.T) {
+	m := NewManager()
+	league := testLeague("unknown")
+	league.Protocol = cfg.ConsensusProtocol(-1)
+	_, err := m.AddLeague(league)
+	assert.Equal(t, ErrUnknownProtocol, err)
+}
+
+func TestProtocolOf(t *testing.T) {
+	protocol, err := ProtocolOf(&cfg.BFTConsensusConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, cfg.ConsensusProtocol(cfg.BFTConsensusProtocol), protocol)
+
+	protocol, err = ProtocolOf(&cfg.FBAConsensusConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, cfg.ConsensusProtocol(cfg.FBAConsensusProtocol), protocol)
+
+	protocol, err = ProtocolOf(&testConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, testProtocol, protocol)
+
+	_, err = ProtocolOf(nil)
+	assert.Equal(t, ErrUnknownProtocol, err)
+}
+
+func TestLeagueConsensus(t *testing.T) {
+	// the consensus_protocol of the config wins over its typed consensus config
+	bft := &cfg.BFTConsensusConfig{WalPath: "regular/cs.wal/wal", RootDir: "/tmp"}
+	bft.TimeoutCommit = 42
+	protocol, config, err := leagueConsensus(cfg.BaseConfig{ConsensusProtocol: "fba"}, cfg.RegularLeagueType, bft)
+	require.NoError(t, err)
+	assert.Equal(t, cfg.ConsensusProtocol(cfg.FBAConsensusProtocol), protocol)
+	require.IsType(t, &cfg.FBAConsensusConfig{}, config)
+	assert.Equal(t, bft.TimeoutCommit, config.(*cfg.FBAConsensusConfig).TimeoutCommit)
+	assert.Equal(t, bft.WalPath, config.(*cfg.FBAConsensusConfig).WalPath)
+
+	// else the protocol is the one of the consensus config
+	fba := &cfg.FBAConsensusConfig{}
+	protocol, config, err = leagueConsensus(cfg.BaseConfig{}, cfg.BaseLeagueType, fba)
+	require.NoError(t, err)
+	assert.Equal(t, cfg.ConsensusProtocol(cfg.FBAConsensusProtocol), protocol)
+	assert.True(t, config == fba)
+
+	// else the default protocol of the league type
+	protocol, config, err = leagueConsensus(cfg.BaseConfig{}, cfg.RegularLeagueType, (*cfg.BFTConsensusConfig)(nil))
+	require.NoError(t, err)
+	assert.Equal(t, cfg.DefaultLeagueProtocol(cfg.RegularLeagueType), protocol)
+	pr, err := ProtocolOf(config)
+	require.NoError(t, err)
+	assert.Equal(t, protocol, pr)
+
+	_, _, err = leagueConsensus(cfg.BaseConfig{ConsensusProtocol: "pbft"}, cfg.RegularLeagueType, bft)
+	assert.Error(t, err)
+}
+
+func TestManagerRegularLeagueFBA(t *testing.T) {
+	var created *League
+	RegisterProtocol(cfg.FBAConsensusProtocol, (*cfg.FBAConsensusConfig)(nil), func(league *League) (protocols.ConsensusEngine, error) {
+		created = league
+		return newTestEngine(league)
+	})
+	defer RegisterProtocol(cfg.FBAConsensusProtocol, (*cfg.FBAConsensusConfig)(nil), newFBAEngine)
+
+	// a Regular league created with --protocol fba keeps its typed BFT
+	// consensus config, and still runs the FBA engine
+	bft := &cfg.BFTConsensusConfig{WalPath: "regular/cs.wal/wal", RootDir: "/tmp"}
+	protocol, err := cfg.ParseConsensusProtocol("fba")
+	require.NoError(t, err)
+	m := NewManager()
+	_, err = m.AddLeague(League{ID: "regular", Protocol: protocol, Config: bft, Validator: types.NewMockPV()})
+	require.NoError(t, err)
+	require.NotNil(t, created)
+	assert.Equal(t, cfg.ConsensusProtocol(cfg.FBAConsensusProtocol), created.Protocol)
+	require.IsType(t, &cfg.FBAConsensusConfig{}, created.Config)
+	assert.Equal(t, "/tmp/regular/cs.wal/wal", created.Config.(*cfg.FBAConsensusConfig).WalFile())
+}
+
+func TestManagerReconfigureLeague(t *testing.T) {
+	m := NewManager()
+	old, err := m.AddLeague(testLeague("regular"))
+	require.NoError(t, err)
+	require.NoError(t, m.Start())
+	defer m.Stop()
+
+	config := &testConfig{cfg.BFTConsensusConfig{WalPath: "regular/cs.wal/wal", RootDir: "/tmp"}}
+	config.TimeoutCommit = 42
+	updated := false
+	err = m.ReconfigureLeague("regular", config, func(state *sm.State) { updated = true })
+	require.NoError(t, err)
+	assert.True(t, updated)
+
+	engine, ok := m.Engine("regular")
+	require.True(t, ok)
+	assert.False(t, engine == old)
+	assert.False(t, old.IsRunning())
+	assert.True(t, engine.IsRunning())
+	assert.True(t, engine.(*testEngine).league.Config == config)
+
+	assert.Equal(t, ErrUnknownLeague, m.ReconfigureLeague("unknown", config, nil))
+	_, err = m.AddLeague(testLeague("other"))
+	require.NoError(t, err)
+	shared := &testConfig{cfg.BFTConsensusConfig{WalPath: "other/cs.wal/wal", RootDir: "/tmp"}}
+	assert.Error(t, m.ReconfigureLeague("regular", shared, nil))
+}
+
+func TestManagerReconfigureLeagueAt(t *testing.T) {
+	var switched []protocols.ConsensusEngine
+	m := NewManager(WithEngineListener(func(leagueID string, engine protocols.ConsensusEngine) {
+		assert.Equal(t, "regular", leagueID)
+		switched = append(switched, engine)
+	}))
+	league := testLeague("regular")
+	league.State.LastBlockHeight = 9
+	old, err := m.AddLeague(league)
+	require.NoError(t, err)
+	require.NoError(t, m.Start())
+	defer m.Stop()
+
+	config := &testConfig{cfg.BFTConsensusConfig{WalPath: "regular/cs.wal/wal", RootDir: "/tmp"}}
+	config.TimeoutCommit = 42
+	updated := false
+	err = m.ReconfigureLeagueAt("regular", 20, config, func(state *sm.State) { updated = true })
+	require.NoError(t, err)
+
+	// the engine runs until it halts before the reconfiguration height
+	engine, _ := m.Engine("regular")
+	assert.True(t, engine == old)
+	assert.EqualValues(t, 20, old.(*testEngine).haltHeight)
+	assert.False(t, updated)
+	assert.Empty(t, switched)
+
+	// the new engine starts from the last state of the old one
+	old.(*testEngine).league.State.LastBlockHeight = 19
+	old.(*testEngine).onHalt(old.GetState())
+	engine, _ = m.Engine("regular")
+	assert.False(t, engine == old)
+	assert.False(t, old.IsRunning())
+	assert.True(t, engine.IsRunning())
+	assert.True(t, updated)
+	assert.True(t, engine.(*testEngine).league.Config == config)
+	assert.EqualValues(t, 19, engine.GetState().LastBlockHeight)
+	assert.Zero(t, engine.(*testEngine).haltHeight)
+	assert.Equal(t, []protocols.ConsensusEngine{engine}, switched)
+
+	// a halted engine which was replaced meanwhile is ignored
+	old.(*testEngine).onHalt(old.GetState())
+	assert.Len(t, switched, 1)
+
+	// a height the engine may have started is reconfigured at once
+	require.NoError(t, m.ReconfigureLeagueAt("regular", 20, testLeague("regular").Config, nil))
+	assert.Len(t, switched, 2)
+	assert.Equal(t, ErrUnknownLeague, m.ReconfigureLeagueAt("unknown", 30, config, nil))
+}
+
+func TestManagerHoldLeague(t *testing.T) {
+	var switched []protocols.ConsensusEngine
+	m := NewManager(WithEngineListener(func(leagueID string, engine protocols.ConsensusEngine) {
+		switched = append(switched, engine)
+	}))
+	league := testLeague("regular")
+	league.State.LastBlockHeight = 9
+	old, err := m.AddLeague(league)
+	require.NoError(t, err)
+	require.NoError(t, m.Start())
+	defer m.Stop()
+
+	// the heights the engine may have started can't be held
+	assert.Equal(t, ErrHeightStarted, m.HoldLeagueAt("regular", 10))
+	assert.Equal(t, ErrUnknownLeague, m.HoldLeagueAt("unknown", 20))
+	require.NoError(t, m.HoldLeagueAt("regular", 30))
+	require.NoError(t, m.HoldLeagueAt("regular", 20))
+	assert.EqualValues(t, 20, old.(*testEngine).haltHeight)
+
+	// the engine stays halted at a held height
+	old.(*testEngine).league.State.LastBlockHeight = 19
+	old.(*testEngine).onHalt(old.GetState())
+	engine, _ := m.Engine("regular")
+	assert.True(t, engine == old)
+	assert.Empty(t, switched)
+
+	// and runs the reconfiguration scheduled meanwhile once released
+	config := &testConfig{cfg.BFTConsensusConfig{WalPath: "regular/cs.wal/wal", RootDir: "/tmp"}}
+	config.TimeoutCommit = 42
+	require.NoError(t, m.ReconfigureLeagueAt("regular", 20, config, nil))
+	engine, _ = m.Engine("regular")
+	assert.True(t, engine == old)
+	require.NoError(t, m.ReleaseLeagueAt("regular", 20))
+	engine, _ = m.Engine("regular")
+	assert.False(t, engine == old)
+	assert.True(t, engine.IsRunning())
+	assert.True(t, engine.(*testEngine).league.Config == config)
+	assert.EqualValues(t, 30, engine.(*testEngine).haltHeight)
+	assert.Len(t, switched, 1)
+
+	// a released engine without any reconfiguration due restarts as is
+	old = engine
+	old.(*testEngine).league.State.LastBlockHeight = 29
+	old.(*testEngine).onHalt(old.GetState())
+	require.NoError(t, m.ReleaseLeagueAt("regular", 30))
+	engine, _ = m.Engine("regular")
+	assert.False(t, engine == old)
+	assert.True(t, engine.(*testEngine).league.Config == config)
+	assert.EqualValues(t, 29, engine.GetState().LastBlockHeight)
+	assert.Zero(t, engine.(*testEngine).haltHeight)
+	assert.Len(t, switched, 2)
+
+	// releasing a height which isn't held does nothing
+	require.NoError(t, m.ReleaseLeagueAt("regular", 40))
+	assert.Len(t, switched, 2)
+}
+
+func TestManagerFBAToBFTMinorityCommit(t *testing.T) {
+	RegisterProtocol(cfg.FBAConsensusProtocol, (*cfg.FBAConsensusConfig)(nil), newTestEngine)
+	RegisterProtocol(cfg.BFTConsensusProtocol, (*cfg.BFTConsensusConfig)(nil), newTestEngine)
+	defer RegisterProtocol(cfg.FBAConsensusProtocol, (*cfg.FBAConsensusConfig)(nil), newFBAEngine)
+	defer RegisterProtocol(cfg.BFTConsensusProtocol, (*cfg.BFTConsensusConfig)(nil), newBFTEngine)
+
+	vals, privVals := types.RandValidatorSet(4, 10)
+	state := sm.State{LeagueID: "regular", LastBlockHeight: 9, Validators: vals, LastValidators: vals}
+	// the last block is committed by an FBA quorum of 2 validators
+	blockStore := &commitStore{commit: signedCommit(t, state, privVals, 2)}
+	league := League{
+		ID:         "regular",
+		Protocol:   cfg.FBAConsensusProtocol,
+		Config:     &cfg.FBAConsensusConfig{WalPath: "regular/cs.wal/wal", RootDir: "/tmp"},
+		State:      state,
+		BlockStore: blockStore,
+		Validator:  types.NewMockPV(),
+	}
+	store := NewReconfigurationStore(dbm.NewMemDB())
+	m := NewManager(WithReconfigurationStore(store))
+	old, err := m.AddLeague(league)
+	require.NoError(t, err)
+	require.NoError(t, m.Start())
+	defer m.Stop()
+
+	// the BFT engine can't take over from the minority commit
+	bft := &cfg.BFTConsensusConfig{WalPath: "regular/cs.wal/wal", RootDir: "/tmp"}
+	assert.Equal(t, ErrMinorityCommit, m.ReconfigureLeague("regular", bft, nil))
+	assert.Equal(t, ErrMinorityCommit, m.ReconfigureLeagueAt("regular", 10, bft, nil))
+	engine, _ := m.Engine("regular")
+	assert.True(t, engine == old)
+	assert.True(t, old.IsRunning())
+	rs, err := store.load("regular")
+	require.NoError(t, err)
+	assert.Empty(t, rs)
+
+	// nor once the FBA engine halts at the migration height
+	require.NoError(t, m.ReconfigureLeagueAt("regular", 11, bft, nil))
+	old.(*testEngine).league.State.LastBlockHeight = 10
+	blockStore.commit = signedCommit(t, old.GetState(), privVals, 2)
+	old.(*testEngine).onHalt(old.GetState())
+	engine, _ = m.Engine("regular")
+	assert.True(t, engine == old)
+
+	// +2/3 of the voting power hand over the league
+	blockStore.commit = signedCommit(t, old.GetState(), privVals, 3)
+	require.NoError(t, m.ReconfigureLeague("regular", bft, nil))
+	engine, _ = m.Engine("regular")
+	assert.False(t, engine == old)
+	assert.True(t, engine.(*testEngine).league.Config == bft)
+}
+
+func TestManagerPersistedReconfigurations(t *testing.T) {
+	store := NewReconfigurationStore(dbm.NewMemDB())
+	m := NewManager(WithReconfigurationStore(store))
+	league := testLeague("regular")
+	league.State.LastBlockHeight = 9
+	_, err := m.AddLeague(league)
+	require.NoError(t, err)
+
+	config := &testConfig{cfg.BFTConsensusConfig{WalPath: "regular/cs.wal/wal", RootDir: "/tmp"}}
+	config.TimeoutCommit = 42
+	require.NoError(t, m.ReconfigureLeagueAt("regular", 20, config, nil))
+
+	// a restarted cell schedules the reconfiguration again
+	m = NewManager(WithReconfigurationStore(store))
+	engine, err := m.AddLeague(league)
+	require.NoError(t, err)
+	assert.EqualValues(t, 20, engine.(*testEngine).haltHeight)
+	assert.NotEqual(t, config.TimeoutCommit, engine.(*testEngine).league.Config.(*testConfig).TimeoutCommit)
+
+	// and runs the reconfigured engine once the height is reached
+	m = NewManager(WithReconfigurationStore(store))
+	league.State.LastBlockHeight = 25
+	engine, err = m.AddLeague(league)
+	require.NoError(t, err)
+	assert.Zero(t, engine.(*testEngine).haltHeight)
+	assert.Equal(t, config.TimeoutCommit, engine.(*testEngine).league.Config.(*testConfig).TimeoutCommit)
+}
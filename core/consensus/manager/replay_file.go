@@ -0,0 +1,116 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+
+	bc "github.com/teragrid/dgrid/core/blockchain"
+	cfg "github.com/teragrid/dgrid/core/config"
+	cs "github.com/teragrid/dgrid/core/consensus"
+	"github.com/teragrid/dgrid/core/consensus/protocols"
+	"github.com/teragrid/dgrid/core/types"
+	dbm "github.com/teragrid/dgrid/pkg/db"
+	"github.com/teragrid/dgrid/pkg/log"
+	"github.com/teragrid/dgrid/proxy"
+	sm "github.com/teragrid/dgrid/state"
+)
+
+// ErrNoWALProvider is returned when no WALProvider is available
+// to open the WAL of a league.
+var ErrNoWALProvider = errors.New("Error no WAL provider")
+
+// DefaultWALProvider opens the WAL of the engines of a Manager created
+// without WithWALProvider, and the WAL replayed by RunReplayFile. It opens
+// a protocols.BaseWAL, shared by the engines of every protocol.
+var DefaultWALProvider WALProvider = func(leagueID, walFile string) (protocols.WAL, error) {
+	return protocols.NewWAL(walFile)
+}
+
+// RunReplayFile replays the WAL of the consensus engine configured by
+// csConfig, whatever its protocol, against the stored state of the cell.
+func RunReplayFile(config cfg.BaseConfig, csConfig cfg.Config, console bool) error {
+	protocol, err := ProtocolOf(csConfig)
+	if err != nil {
+		return err
+	}
+	filer, ok := csConfig.(walFiler)
+	if !ok {
+		return fmt.Errorf("Consensus config %T has no WAL", csConfig)
+	}
+	if DefaultWALProvider == nil {
+		return ErrNoWALProvider
+	}
+
+	league, err := newLeagueForReplay(config, protocol, csConfig)
+	if err != nil {
+		return err
+	}
+	engine, err := NewEngine(league)
+	if err != nil {
+		return err
+	}
+	engine.SetEventBus(league.EventBus)
+
+	wal, err := DefaultWALProvider(league.ID, filer.WalFile())
+	if err != nil {
+		return err
+	}
+	if err := wal.Start(); err != nil {
+		return err
+	}
+	defer wal.Stop()
+
+	return protocols.RunReplayFile(engine, wal, console)
+}
+
+// newLeagueForReplay loads the state of the cell and syncs the application
+// with it, so the WAL can be replayed on top of it.
+func newLeagueForReplay(config cfg.BaseConfig, protocol cfg.ConsensusProtocol, csConfig cfg.Config) (*League, error) {
+	dbType := dbm.DBBackendType(config.DBBackend)
+	blockStoreDB := dbm.NewDB("blockstore", dbType, config.DBDir())
+	blockStore := bc.NewBlockStore(blockStoreDB)
+
+	stateDB := dbm.NewDB("state", dbType, config.DBDir())
+	genDoc, err := sm.MakeGenesisDocFromFile(config.GenesisFile())
+	if err != nil {
+		return nil, err
+	}
+	state, err := sm.MakeGenesisState(genDoc)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create proxyAppConn connection (consensus, storage, query)
+	clientCreator := proxy.DefaultClientCreator(config.ProxyApp, config.Asura, config.DBDir())
+	proxyApp := proxy.NewAppConns(clientCreator)
+	if err := proxyApp.Start(); err != nil {
+		return nil, fmt.Errorf("Error starting proxy app conns: %v", err)
+	}
+
+	eventBus := types.NewEventBus()
+	if err := eventBus.Start(); err != nil {
+		return nil, fmt.Errorf("Failed to start event bus: %v", err)
+	}
+
+	handshaker := cs.NewHandshaker(stateDB, state, blockStore, genDoc)
+	handshaker.SetEventBus(eventBus)
+	if err := handshaker.Handshake(proxyApp); err != nil {
+		return nil, fmt.Errorf("Error on handshake: %v", err)
+	}
+	state = sm.LoadState(stateDB)
+
+	storage, evpool := sm.MockMempool{}, sm.MockEvidencePool{}
+	blockExec := sm.NewBlockExecutor(stateDB, log.NewNopLogger(), proxyApp.Consensus(), storage, evpool)
+
+	return &League{
+		ID:           genDoc.LeagueID,
+		Protocol:     protocol,
+		Config:       csConfig,
+		State:        state.Copy(),
+		BlockExec:    blockExec,
+		BlockStore:   blockStore,
+		TxNotifier:   storage,
+		EvidencePool: evpool,
+		EventBus:     eventBus,
+	}, nil
+}
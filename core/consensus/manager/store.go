@@ -0,0 +1,124 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	cfg "github.com/teragrid/dgrid/core/config"
+	dbm "github.com/teragrid/dgrid/pkg/db"
+)
+
+/*
+ReconfigurationStore persists the consensus reconfigurations of the leagues,
+so that a restarted cell runs the config in effect at its height, and the
+reconfigurations still to come:
+
+	"R:<len>:<leagueID>:<height>" -> reconfigurationRecord
+
+Heights are zero-padded, so the reconfigurations of a league are iterated
+in height order.
+*/
+type ReconfigurationStore struct {
+	db dbm.DB
+}
+
+// reconfigurationRecord is a reconfiguration in the store. The state
+// updates of a reconfiguration are not persisted; the callers scheduling
+// some schedule them again when the cell restarts.
+type reconfigurationRecord struct {
+	Height   int64                 `json:"height"`
+	Protocol cfg.ConsensusProtocol `json:"protocol"`
+	Config   json.RawMessage       `json:"config"`
+}
+
+// NewReconfigurationStore returns a new ReconfigurationStore using the given db.
+func NewReconfigurationStore(db dbm.DB) *ReconfigurationStore {
+	return &ReconfigurationStore{db: db}
+}
+
+func keyReconfigurationPrefix(leagueID string) []byte {
+	return []byte(fmt.Sprintf("R:%d:%s:", len(leagueID), leagueID))
+}
+
+func keyReconfiguration(leagueID string, height int64) []byte {
+	return append(keyReconfigurationPrefix(leagueID), []byte(fmt.Sprintf("%020d", height))...)
+}
+
+// save persists a reconfiguration of a league, replacing any other one at
+// the same height.
+func (store *ReconfigurationStore) save(leagueID string, r *reconfiguration) error {
+	config, err := json.Marshal(r.config)
+	if err != nil {
+		return err
+	}
+	bz, err := json.Marshal(&reconfigurationRecord{
+		Height:   r.height,
+		Protocol: r.protocol,
+		Config:   config,
+	})
+	if err != nil {
+		return err
+	}
+	store.db.SetSync(keyReconfiguration(leagueID, r.height), bz)
+	return nil
+}
+
+// load returns the reconfigurations of a league, in height order.
+func (store *ReconfigurationStore) load(leagueID string) ([]*reconfiguration, error) {
+	var rs []*reconfiguration
+	itr := dbm.IteratePrefix(store.db, keyReconfigurationPrefix(leagueID))
+	defer itr.Close()
+	for ; itr.Valid(); itr.Next() {
+		var rec reconfigurationRecord
+		if err := json.Unmarshal(itr.Value(), &rec); err != nil {
+			return nil, fmt.Errorf("Error reading reconfiguration of league %s: %v", leagueID, err)
+		}
+		config, err := newConfig(rec.Protocol)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(rec.Config, config); err != nil {
+			return nil, fmt.Errorf("Error reading reconfiguration of league %s: %v", leagueID, err)
+		}
+		rs = append(rs, &reconfiguration{
+			height:   rec.Height,
+			protocol: rec.Protocol,
+			config:   config,
+		})
+	}
+	return rs, nil
+}
+
+// prune deletes the reconfigurations of a league before height.
+func (store *ReconfigurationStore) prune(leagueID string, height int64) {
+	var keys [][]byte
+	itr := dbm.IteratePrefix(store.db, keyReconfigurationPrefix(leagueID))
+	for ; itr.Valid(); itr.Next() {
+		var rec reconfigurationRecord
+		if err := json.Unmarshal(itr.Value(), &rec); err != nil || rec.Height >= height {
+			break
+		}
+		keys = append(keys, itr.Key())
+	}
+	itr.Close()
+
+	batch := store.db.NewBatch()
+	defer batch.Close()
+	for _, key := range keys {
+		batch.Delete(key)
+	}
+	batch.WriteSync()
+}
+
+// newConfig returns an empty consensus config of a protocol.
+func newConfig(protocol cfg.ConsensusProtocol) (cfg.Config, error) {
+	protocolsMtx.RLock()
+	defer protocolsMtx.RUnlock()
+	for typ, p := range configProtocols {
+		if p == protocol && typ.Kind() == reflect.Ptr {
+			return reflect.New(typ.Elem()).Interface().(cfg.Config), nil
+		}
+	}
+	return nil, ErrUnknownProtocol
+}
@@ -0,0 +1,1918 @@
+package protocols
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/teragrid/dgrid/core/blockchain/p2p/conn"
+	cfg "github.com/teragrid/dgrid/core/config"
+	"github.com/teragrid/dgrid/core/types"
+	ttime "github.com/teragrid/dgrid/core/types/time"
+	cmn "github.com/teragrid/dgrid/pkg/common"
+	dbm "github.com/teragrid/dgrid/pkg/db"
+	tevents "github.com/teragrid/dgrid/pkg/events"
+	"github.com/teragrid/dgrid/pkg/fail"
+	"github.com/teragrid/dgrid/pkg/log"
+	sm "github.com/teragrid/dgrid/state"
+)
+
+//-----------------------------------------------------------------------------
+// Errors
+
+var (
+	ErrInvalidProposalSignature = errors.New("Error invalid proposal signature")
+	ErrInvalidProposalPOLRound  = errors.New("Error invalid proposal POL round")
+	ErrAddingVote               = errors.New("Error adding vote")
+	ErrVoteHeightMismatch       = errors.New("Error vote height mismatch")
+	ErrNotValidator             = errors.New("Error this node is not a validator")
+)
+
+//-----------------------------------------------------------------------------
+
+var (
+	msgQueueSize = 1000
+)
+
+// interface to the storage
+type txNotifier interface {
+	TxsAvailable() <-chan struct{}
+}
+
+// interface to the evidence pool
+type evidencePool interface {
+	AddEvidence(types.Evidence) error
+}
+
+// BFTConsensus handles execution of the round-based BFT consensus algorithm
+// for a single league. It processes votes and proposals, and upon reaching
+// agreement, commits blocks to the chain and executes them against the
+// application.
+//
+// Each height is decided in one or more rounds. A round consists of a
+// propose step, a prevote step and a precommit step, each bounded by the
+// timeouts of the BFTConsensusConfig. A validator locks on a block once it
+// has seen a polka (+2/3 prevotes) for it, and only unlocks when it sees a
+// polka for something else at a later round (the POL rules).
+//
+// The internal state machine receives input from peers, the internal
+// validator, and from a timer.
+type BFTConsensus struct {
+	cmn.BaseService
+
+	// config details
+	config    *cfg.BFTConsensusConfig
+	validator types.Validator // for signing votes
+
+	// store blocks and commits
+	blockStore sm.BlockStore
+
+	// create and execute blocks
+	blockExec *sm.BlockExecutor
+	pipeline  *executionPipeline // nil unless the league pipelines the execution
+	stateDB   dbm.DB             // saves the pipelined states
+
+	// notify us if txs are available
+	txNotifier txNotifier
+
+	// add evidence to the pool
+	// when it's detected
+	evpool evidencePool
+
+	// internal state
+	mtx sync.RWMutex
+	RoundState
+	state sm.State // State until height-1.
+
+	// state changes may be triggered by: msgs from peers,
+	// msgs from ourself, or by timeouts
+	peerMsgQueue     chan msgInfo
+	internalMsgQueue chan msgInfo
+	timeoutTicker    TimeoutTicker
+
+	// we use eventBus to trigger msg broadcasts in the reactor,
+	// and to notify external subscribers, eg. through a websocket
+	eventBus *types.EventBus
+
+	// a Write-Ahead Log ensures we can recover from any kind of crash
+	// and helps us avoid signing conflicting votes
+	wal          WAL
+	replayMode   bool // so we don't log signing errors during replay
+	doWALCatchup bool // determines if we even try to do the catchup
+
+	// stops the engine when the league migrates to another engine
+	halter heightHalter
+
+	metrics *Metrics
+
+	// for tests where we want to limit the number of transitions the state makes
+	nSteps int
+
+	// some functions can be overwritten for testing
+	decideProposal func(height int64, round int)
+	doPrevote      func(height int64, round int)
+	setProposal    func(proposal *types.Proposal) error
+
+	// misbehaviors of the validator on a testnet, nil if it is honest
+	byzantine *byzantine
+
+	// closed when we finish shutting down
+	done chan struct{}
+
+	// synchronous pubsub between the engine and its reactor.
+	// state only emits EventNewRoundStep, EventVote and EventValidBlock
+	evsw tevents.EventSwitch
+}
+
+// BFTOption sets an optional parameter on the BFTConsensus.
+type BFTOption func(*BFTConsensus)
+
+// BFTMetrics sets the metrics.
+func BFTMetrics(metrics *Metrics) BFTOption {
+	return func(cs *BFTConsensus) { cs.metrics = metrics }
+}
+
+// BFTStateDB sets the state db of the league, where the pipelined states
+// are saved if the league pipelines the execution. Without it, a restarted
+// engine can't resume the pipeline.
+func BFTStateDB(stateDB dbm.DB) BFTOption {
+	return func(cs *BFTConsensus) { cs.stateDB = stateDB }
+}
+
+// NewBFTConsensus returns a new BFTConsensus.
+func NewBFTConsensus(
+	config *cfg.BFTConsensusConfig,
+	state sm.State,
+	blockExec *sm.BlockExecutor,
+	blockStore sm.BlockStore,
+	txNotifier txNotifier,
+	evpool evidencePool,
+	options ...BFTOption,
+) *BFTConsensus {
+	cs := &BFTConsensus{
+		config:           config,
+		blockExec:        blockExec,
+		blockStore:       blockStore,
+		txNotifier:       txNotifier,
+		peerMsgQueue:     make(chan msgInfo, msgQueueSize),
+		internalMsgQueue: make(chan msgInfo, msgQueueSize),
+		timeoutTicker:    NewTimeoutTicker(),
+		done:             make(chan struct{}),
+		doWALCatchup:     true,
+		wal:              nilWAL{},
+		evpool:           evpool,
+		evsw:             tevents.NewEventSwitch(),
+		metrics:          NopMetrics(),
+	}
+	// set function defaults (may be overwritten before calling Start)
+	cs.decideProposal = cs.defaultDecideProposal
+	cs.doPrevote = cs.defaultDoPrevote
+	cs.setProposal = cs.defaultSetProposal
+	for _, option := range options {
+		option(cs)
+	}
+	if state.ConsensusParams.Execution.Pipelined {
+		cs.pipeline = newExecutionPipeline(blockExec, cs.stateDB)
+		var err error
+		if state, err = cs.pipeline.restore(state); err != nil {
+			cmn.PanicSanity(fmt.Sprintf("Failed to resume the pipelined execution: %v", err))
+		}
+	}
+
+	cs.updateToState(state)
+
+	// Don't call scheduleRound0 yet.
+	// We do that upon Start().
+	cs.reconstructLastCommit(state)
+	cs.BaseService = *cmn.NewBaseService(nil, "BFTConsensus", cs)
+	return cs
+}
+
+//----------------------------------------
+// Public interface
+
+// SetLogger implements Service.
+func (cs *BFTConsensus) SetLogger(l log.Logger) {
+	cs.BaseService.Logger = l
+	cs.timeoutTicker.SetLogger(l)
+}
+
+// SetEventBus sets event bus.
+func (cs *BFTConsensus) SetEventBus(b *types.EventBus) {
+	cs.eventBus = b
+	cs.blockExec.SetEventBus(b)
+}
+
+// SetWAL sets the write-ahead log used by the engine. It must be called
+// before Start.
+func (cs *BFTConsensus) SetWAL(wal WAL) {
+	cs.wal = wal
+}
+
+// String returns a string.
+func (cs *BFTConsensus) String() string {
+	// better not to access shared variables
+	return fmt.Sprintf("BFTConsensus") //(H:%v R:%v S:%v", cs.Height, cs.Round, cs.Step)
+}
+
+// GetState returns a copy of the chain state.
+func (cs *BFTConsensus) GetState() sm.State {
+	cs.mtx.RLock()
+	defer cs.mtx.RUnlock()
+	return cs.state.Copy()
+}
+
+// GetLastHeight returns the last height committed.
+// If there were no blocks, returns 0.
+func (cs *BFTConsensus) GetLastHeight() int64 {
+	cs.mtx.RLock()
+	defer cs.mtx.RUnlock()
+	return cs.RoundState.Height - 1
+}
+
+// GetRoundState returns a shallow copy of the internal consensus state.
+func (cs *BFTConsensus) GetRoundState() *RoundState {
+	cs.mtx.RLock()
+	rs := cs.RoundState // copy
+	cs.mtx.RUnlock()
+	return &rs
+}
+
+// GetRoundStateJSON returns a json of RoundState, marshalled using go-amino.
+func (cs *BFTConsensus) GetRoundStateJSON() ([]byte, error) {
+	cs.mtx.RLock()
+	defer cs.mtx.RUnlock()
+	return cdc.MarshalJSON(cs.RoundState)
+}
+
+// GetRoundStateSimpleJSON returns a json of RoundStateSimple, marshalled using go-amino.
+func (cs *BFTConsensus) GetRoundStateSimpleJSON() ([]byte, error) {
+	cs.mtx.RLock()
+	defer cs.mtx.RUnlock()
+	return cdc.MarshalJSON(cs.RoundState.RoundStateSimple())
+}
+
+// GetValidators returns a copy of the current validators.
+func (cs *BFTConsensus) GetValidators() (int64, []*types.Validator) {
+	cs.mtx.RLock()
+	defer cs.mtx.RUnlock()
+	return cs.state.LastBlockHeight, cs.state.Validators.Copy().Validators
+}
+
+// SetValidator sets the local validator.
+func (cs *BFTConsensus) SetValidator(val types.Validator) {
+	cs.mtx.Lock()
+	defer cs.mtx.Unlock()
+	cs.validator = val
+}
+
+// SetTimeoutTicker sets the local timer. It may be useful to overwrite for testing.
+func (cs *BFTConsensus) SetTimeoutTicker(timeoutTicker TimeoutTicker) {
+	cs.mtx.Lock()
+	defer cs.mtx.Unlock()
+	cs.timeoutTicker = timeoutTicker
+}
+
+// LoadCommit loads the commit for a given height.
+func (cs *BFTConsensus) LoadCommit(height int64) *types.Commit {
+	cs.mtx.RLock()
+	defer cs.mtx.RUnlock()
+	if height == cs.blockStore.Height() {
+		return cs.blockStore.LoadSeenCommit(height)
+	}
+	return cs.blockStore.LoadBlockCommit(height)
+}
+
+// OnStart implements cmn.Service.
+// It loads the latest state via the WAL, and starts the timeout and receive routines.
+func (cs *BFTConsensus) OnStart() error {
+	if err := cs.evsw.Start(); err != nil {
+		return err
+	}
+
+	if err := cs.wal.Start(); err != nil {
+		return err
+	}
+
+	// we need the timeoutRoutine for replay so
+	// we don't block on the tick chan.
+	// NOTE: we will get a build up of garbage go routines
+	// firing on the tockChan until the receiveRoutine is started
+	// to deal with them (by that point, at most one will be valid)
+	if err := cs.timeoutTicker.Start(); err != nil {
+		return err
+	}
+
+	// resume the round in flight before a crash
+	if _, ok := cs.wal.(nilWAL); !ok && cs.doWALCatchup {
+		if err := catchupReplay(cs.wal, cs.Height, cs.ReplayMessage); err != nil {
+			cs.Logger.Error("Error on catchup replay. Proceeding to start the engine anyway", "err", err)
+		}
+	}
+
+	// now start the receiveRoutine
+	go cs.receiveRoutine(0)
+
+	// schedule the first round, unless another engine takes over the league!
+	// use GetRoundState so we don't race the receiveRoutine for access
+	cs.mtx.Lock()
+	halted := cs.halter.maybeHalt(cs.state)
+	cs.mtx.Unlock()
+	if !halted {
+		cs.scheduleRound0(cs.GetRoundState())
+	}
+
+	return nil
+}
+
+// timeoutRoutine: receive requests for timeouts on tickChan and fire timeouts on tockChan
+// receiveRoutine: serializes processing of proposoals, block parts, votes; coordinates state transitions
+func (cs *BFTConsensus) startRoutines(maxSteps int) {
+	err := cs.timeoutTicker.Start()
+	if err != nil {
+		cs.Logger.Error("Error starting timeout ticker", "err", err)
+		return
+	}
+	go cs.receiveRoutine(maxSteps)
+}
+
+// OnStop implements cmn.Service.
+func (cs *BFTConsensus) OnStop() {
+	cs.evsw.Stop()
+	cs.timeoutTicker.Stop()
+	// WAL is stopped in receiveRoutine.
+}
+
+// Wait waits for the the main routine to return.
+// NOTE: be sure to Stop() the event switch and drain
+// any event channels or this may deadlock
+func (cs *BFTConsensus) Wait() {
+	<-cs.done
+}
+
+// AddVote inputs a vote.
+func (cs *BFTConsensus) AddVote(vote *types.Vote, peerID types.P2PID) (added bool, err error) {
+	if peerID == "" {
+		cs.internalMsgQueue <- msgInfo{&VoteMessage{vote}, ""}
+	} else {
+		cs.peerMsgQueue <- msgInfo{&VoteMessage{vote}, peerID}
+	}
+
+	// TODO: wait for event?!
+	return false, nil
+}
+
+// SetProposal inputs a proposal.
+func (cs *BFTConsensus) SetProposal(proposal *types.Proposal, peerID types.P2PID) error {
+
+	if peerID == "" {
+		cs.internalMsgQueue <- msgInfo{&ProposalMessage{proposal}, ""}
+	} else {
+		cs.peerMsgQueue <- msgInfo{&ProposalMessage{proposal}, peerID}
+	}
+
+	// TODO: wait for event?!
+	return nil
+}
+
+// AddProposalBlockPart inputs a part of the proposal block.
+func (cs *BFTConsensus) AddProposalBlockPart(height int64, round int, part *types.Part, peerID types.P2PID) error {
+
+	if peerID == "" {
+		cs.internalMsgQueue <- msgInfo{&BlockPartMessage{height, round, part}, ""}
+	} else {
+		cs.peerMsgQueue <- msgInfo{&BlockPartMessage{height, round, part}, peerID}
+	}
+
+	// TODO: wait for event?!
+	return nil
+}
+
+// SetProposalAndBlock inputs the proposal and all block parts.
+func (cs *BFTConsensus) SetProposalAndBlock(proposal *types.Proposal, block *types.Block, parts *types.PartSet, peerID types.P2PID) error {
+	if err := cs.SetProposal(proposal, peerID); err != nil {
+		return err
+	}
+	for i := 0; i < parts.Total(); i++ {
+		part := parts.GetPart(i)
+		if err := cs.AddProposalBlockPart(proposal.Height, proposal.Round, part, peerID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Protocol implements ConsensusEngine.
+func (cs *BFTConsensus) Protocol() cfg.ConsensusProtocol {
+	return cfg.BFTConsensusProtocol
+}
+
+// GetChannels implements ConsensusEngine.
+func (cs *BFTConsensus) GetChannels() []*conn.ChannelDescriptor {
+	return channelDescriptors(StateChannel, DataChannel, VoteChannel, VoteSetBitsChannel)
+}
+
+// ReceiveMessage implements ConsensusEngine.
+func (cs *BFTConsensus) ReceiveMessage(msg ConsensusMessage, peerID types.P2PID) error {
+	if !isBFTMessage(msg) {
+		return ErrUnexpectedMessage
+	}
+	cs.peerMsgQueue <- msgInfo{msg, peerID}
+	return nil
+}
+
+// isBFTMessage returns true if msg is used by the BFT protocol.
+func isBFTMessage(msg ConsensusMessage) bool {
+	switch msg.(type) {
+	case *ProposalMessage, *BlockPartMessage, *VoteMessage:
+		return true
+	default:
+		return false
+	}
+}
+
+// AddBroadcastListener implements ConsensusEngine.
+func (cs *BFTConsensus) AddBroadcastListener(listenerID string, cb func(*Broadcast)) error {
+	return addBroadcastListener(cs.evsw, listenerID, cb)
+}
+
+// RemoveBroadcastListener implements ConsensusEngine.
+func (cs *BFTConsensus) RemoveBroadcastListener(listenerID string) {
+	cs.evsw.RemoveListener(listenerID)
+}
+
+// LoadBlockMeta implements GossipEngine.
+func (cs *BFTConsensus) LoadBlockMeta(height int64) *types.BlockMeta {
+	return cs.blockStore.LoadBlockMeta(height)
+}
+
+// LoadBlockPart implements GossipEngine.
+func (cs *BFTConsensus) LoadBlockPart(height int64, index int) *types.Part {
+	return cs.blockStore.LoadBlockPart(height, index)
+}
+
+// SetPeerMaj23 implements GossipEngine.
+func (cs *BFTConsensus) SetPeerMaj23(height int64, round int, voteType types.SignedMsgType,
+	peerID types.P2PID, blockID types.BlockID) error {
+	cs.mtx.Lock()
+	defer cs.mtx.Unlock()
+	if height != cs.Height {
+		return nil
+	}
+	return cs.Votes.SetPeerMaj23(round, voteType, peerID, blockID)
+}
+
+// VoteBitArray implements GossipEngine.
+func (cs *BFTConsensus) VoteBitArray(height int64, round int, voteType types.SignedMsgType,
+	blockID types.BlockID) *cmn.BitArray {
+	cs.mtx.RLock()
+	defer cs.mtx.RUnlock()
+	if height != cs.Height {
+		return nil
+	}
+	switch voteType {
+	case types.PrevoteType:
+		return cs.Votes.Prevotes(round).BitArrayByBlockID(blockID)
+	case types.PrecommitType:
+		return cs.Votes.Precommits(round).BitArrayByBlockID(blockID)
+	default:
+		return nil
+	}
+}
+
+// PeerGossipSleepDurations implements GossipEngine.
+func (cs *BFTConsensus) PeerGossipSleepDurations() (gossip, queryMaj23 time.Duration) {
+	return cs.config.PeerGossipSleepDuration, cs.config.PeerQueryMaj23SleepDuration
+}
+
+// AddRoundStateListener implements GossipEngine.
+func (cs *BFTConsensus) AddRoundStateListener(listenerID string, listener RoundStateListener) error {
+	if listener.OnNewRoundStep != nil {
+		err := cs.evsw.AddListenerForEvent(listenerID, types.EventNewRoundStep, func(data tevents.EventData) {
+			listener.OnNewRoundStep(data.(*RoundState))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if listener.OnValidBlock != nil {
+		err := cs.evsw.AddListenerForEvent(listenerID, types.EventValidBlock, func(data tevents.EventData) {
+			listener.OnValidBlock(data.(*RoundState))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if listener.OnVote != nil {
+		return cs.evsw.AddListenerForEvent(listenerID, types.EventVote, func(data tevents.EventData) {
+			listener.OnVote(data.(*types.Vote))
+		})
+	}
+	return nil
+}
+
+// SwitchToState implements ConsensusEngine.
+func (cs *BFTConsensus) SwitchToState(state sm.State) {
+	cs.mtx.Lock()
+	defer cs.mtx.Unlock()
+	cs.reconstructLastCommit(state)
+	cs.updateToState(state)
+}
+
+// ReplayMessage implements ConsensusEngine.
+// The message is applied synchronously, as if the receive routine got it.
+func (cs *BFTConsensus) ReplayMessage(msg WALMessage) error {
+	cs.replayMode = true
+	defer func() { cs.replayMode = false }()
+
+	switch m := msg.(type) {
+	case types.EventDataRoundState, EndHeightMessage:
+		// markers, nothing to apply
+	case msgInfo:
+		if isBFTMessage(m.Msg) {
+			cs.handleMsg(m)
+		}
+	case timeoutInfo:
+		cs.handleTimeout(m, *cs.GetRoundState())
+	default:
+		return fmt.Errorf("Unknown WAL message type %v", reflect.TypeOf(msg))
+	}
+	return nil
+}
+
+// SetHaltHeight implements ConsensusEngine.
+func (cs *BFTConsensus) SetHaltHeight(height int64, onHalt func(state sm.State)) error {
+	cs.mtx.Lock()
+	defer cs.mtx.Unlock()
+	return cs.halter.setHaltHeight(height, onHalt, cs.Height, cs.IsRunning())
+}
+
+//------------------------------------------------------------
+// internal functions for managing the state
+
+func (cs *BFTConsensus) updateHeight(height int64) {
+	cs.Height = height
+}
+
+func (cs *BFTConsensus) updateRoundStep(round int, step RoundStepType) {
+	cs.Round = round
+	cs.Step = step
+}
+
+// enterNewRound(height, 0) at cs.StartTime.
+func (cs *BFTConsensus) scheduleRound0(rs *RoundState) {
+	sleepDuration := rs.StartTime.Sub(ttime.Now())
+	cs.scheduleTimeout(sleepDuration, rs.Height, 0, RoundStepNewHeight)
+}
+
+// Attempt to schedule a timeout (by sending timeoutInfo on the tickChan)
+func (cs *BFTConsensus) scheduleTimeout(duration time.Duration, height int64, round int, step RoundStepType) {
+	cs.timeoutTicker.ScheduleTimeout(timeoutInfo{duration, height, round, step})
+}
+
+// send a msg into the receiveRoutine regarding our own proposal, block part, or vote
+func (cs *BFTConsensus) sendInternalMessage(mi msgInfo) {
+	select {
+	case cs.internalMsgQueue <- mi:
+	default:
+		// NOTE: using the go-routine means our votes can
+		// be processed out of order.
+		// TODO: use CList here for strict determinism and
+		// attempt push to internalMsgQueue in receiveRoutine
+		cs.Logger.Info("Internal msg queue is full. Using a go-routine")
+		go func() { cs.internalMsgQueue <- mi }()
+	}
+}
+
+// Reconstruct LastCommit from SeenCommit, which we saved along with the block,
+// (which happens even before saving the state)
+func (cs *BFTConsensus) reconstructLastCommit(state sm.State) {
+	if state.LastBlockHeight == 0 {
+		return
+	}
+	seenCommit := cs.blockStore.LoadSeenCommit(state.LastBlockHeight)
+	lastPrecommits := types.NewVoteSet(state.LeagueID, state.LastBlockHeight, seenCommit.Round(), types.PrecommitType, state.LastValidators)
+	for _, precommit := range seenCommit.Precommits {
+		if precommit == nil {
+			continue
+		}
+		added, err := lastPrecommits.AddVote(seenCommit.ToVote(precommit))
+		if !added || err != nil {
+			cmn.PanicCrisis(fmt.Sprintf("Failed to reconstruct LastCommit: %v", err))
+		}
+	}
+	if !lastPrecommits.HasTwoThirdsMajority() {
+		cmn.PanicSanity("Failed to reconstruct LastCommit: Does not have +2/3 maj")
+	}
+	cs.LastCommit = lastPrecommits
+}
+
+// Updates BFTConsensus and increments height to match that of state.
+// The round becomes 0 and cs.Step becomes RoundStepNewHeight.
+func (cs *BFTConsensus) updateToState(state sm.State) {
+	if cs.CommitRound > -1 && 0 < cs.Height && cs.Height != state.LastBlockHeight {
+		cmn.PanicSanity(fmt.Sprintf("updateToState() expected state height of %v but found %v",
+			cs.Height, state.LastBlockHeight))
+	}
+	if !cs.state.IsEmpty() && cs.state.LastBlockHeight+1 != cs.Height {
+		// This might happen when someone else is mutating cs.state.
+		// Someone forgot to pass in state.Copy() somewhere?!
+		cmn.PanicSanity(fmt.Sprintf("Inconsistent cs.state.LastBlockHeight+1 %v vs cs.Height %v",
+			cs.state.LastBlockHeight+1, cs.Height))
+	}
+
+	// If state isn't further out than cs.state, just ignore.
+	// This happens when SwitchToConsensus() is called in the reactor.
+	// We don't want to reset e.g. the Votes, but we still want to
+	// signal the new round step, because other services (eg. txNotifier)
+	// depend on having an up-to-date peer state!
+	if !cs.state.IsEmpty() && (state.LastBlockHeight <= cs.state.LastBlockHeight) {
+		cs.Logger.Info("Ignoring updateToState()", "newHeight", state.LastBlockHeight+1, "oldHeight", cs.state.LastBlockHeight+1)
+		cs.newStep()
+		return
+	}
+
+	// Reset fields based on state.
+	validators := state.Validators
+	lastPrecommits := (*types.VoteSet)(nil)
+	if cs.CommitRound > -1 && cs.Votes != nil {
+		if !cs.Votes.Precommits(cs.CommitRound).HasTwoThirdsMajority() {
+			cmn.PanicSanity("updateToState(state) called but last Precommit round didn't have +2/3")
+		}
+		lastPrecommits = cs.Votes.Precommits(cs.CommitRound)
+	}
+
+	// Next desired block height
+	height := state.LastBlockHeight + 1
+
+	// RoundState fields
+	cs.updateHeight(height)
+	cs.updateRoundStep(0, RoundStepNewHeight)
+	if cs.CommitTime.IsZero() {
+		// "Now" makes it easier to sync up dev nodes.
+		// We add timeoutCommit to allow transactions
+		// to be gathered for the first block.
+		// And alternative solution that relies on clocks:
+		//  cs.StartTime = state.LastBlockTime.Add(timeoutCommit)
+		cs.StartTime = cs.config.Commit(ttime.Now())
+	} else {
+		cs.StartTime = cs.config.Commit(cs.CommitTime)
+	}
+
+	cs.Validators = validators
+	cs.Proposal = nil
+	cs.ProposalBlock = nil
+	cs.ProposalBlockParts = nil
+	cs.LockedRound = -1
+	cs.LockedBlock = nil
+	cs.LockedBlockParts = nil
+	cs.ValidRound = -1
+	cs.ValidBlock = nil
+	cs.ValidBlockParts = nil
+	cs.Votes = NewHeightVoteSet(state.LeagueID, height, validators)
+	cs.CommitRound = -1
+	cs.LastCommit = lastPrecommits
+	cs.LastValidators = state.LastValidators
+	cs.TriggeredTimeoutPrecommit = false
+
+	cs.state = state
+	cs.Proposer = cs.roundProposer(validators, 0)
+
+	// Finally, broadcast RoundState
+	cs.newStep()
+}
+
+func (cs *BFTConsensus) newStep() {
+	rs := cs.RoundStateEvent()
+	cs.wal.Write(rs)
+	cs.nSteps++
+	// newStep is called by updateToState in NewBFTConsensus before the eventBus is set!
+	if cs.eventBus != nil {
+		cs.eventBus.PublishEventNewRoundStep(rs)
+		cs.evsw.FireEvent(types.EventNewRoundStep, &cs.RoundState)
+	}
+}
+
+//-----------------------------------------
+// the main go routines
+
+// receiveRoutine handles messages which may cause state transitions.
+// it's argument (n) is the number of messages to process before exiting - use 0 to run forever
+// It keeps the RoundState and is the only thing that updates it.
+// Updates (state transitions) happen on timeouts, complete proposals, and 2/3 majorities.
+// BFTConsensus must be locked before any internal state is updated.
+func (cs *BFTConsensus) receiveRoutine(maxSteps int) {
+	onExit := func(cs *BFTConsensus) {
+		// NOTE: the internalMsgQueue may have signed messages from our
+		// validator on it, which is not yet in the WAL.
+		// Since the engine may only sign one message per step,
+		// only ever dropping them is safe; see WAL recovery.
+		cs.wal.Stop()
+		cs.wal.Wait()
+		if cs.pipeline != nil {
+			cs.pipeline.wait()
+		}
+
+		close(cs.done)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			cs.Logger.Error("CONSENSUS FAILURE!!!", "err", r, "stack", string(debug.Stack()))
+			// stop gracefully
+			//
+			// NOTE: We most probably shouldn't be running any further when there is
+			// some unexpected panic. Some unknown error happened, and so we don't
+			// know if that will result in the validator signing an invalid thing. It
+			// might be worthwhile to explore a mechanism for manual resuming via
+			// some console or secure RPC system, but for now, halting the chain upon
+			// unexpected consensus bugs sounds like the better option.
+			onExit(cs)
+		}
+	}()
+
+	for {
+		if maxSteps > 0 {
+			if cs.nSteps >= maxSteps {
+				cs.Logger.Info("reached max steps. exiting receive routine")
+				cs.nSteps = 0
+				return
+			}
+		}
+		rs := cs.RoundState
+		var mi msgInfo
+
+		select {
+		case <-cs.txNotifier.TxsAvailable():
+			cs.handleTxsAvailable()
+		case mi = <-cs.peerMsgQueue:
+			cs.wal.Write(mi)
+			// handles proposals, block parts, votes
+			// may generate internal events (votes, complete proposals, 2/3 majorities)
+			cs.handleMsg(mi)
+		case mi = <-cs.internalMsgQueue:
+			cs.wal.WriteSync(mi) // NOTE: fsync
+			// handles proposals, block parts, votes
+			cs.handleMsg(mi)
+		case ti := <-cs.timeoutTicker.Chan(): // tockChan:
+			cs.wal.Write(ti)
+			// if the timeout is relevant to the rs
+			// go to the next step
+			cs.handleTimeout(ti, rs)
+		case <-cs.Quit():
+			onExit(cs)
+			return
+		}
+	}
+}
+
+// state transitions on complete-proposal, 2/3-any, 2/3-one
+func (cs *BFTConsensus) handleMsg(mi msgInfo) {
+	cs.mtx.Lock()
+	defer cs.mtx.Unlock()
+	if cs.halter.halted {
+		return
+	}
+
+	var (
+		added bool
+		err   error
+	)
+	msg, peerID := mi.Msg, mi.PeerID
+	switch msg := msg.(type) {
+	case *ProposalMessage:
+		// will not cause transition.
+		// once proposal is set, we can receive block parts
+		proposal := cs.Proposal
+		err = cs.setProposal(msg.Proposal)
+		added = cs.Proposal != proposal
+	case *BlockPartMessage:
+		// if the proposal is complete, we'll enterPrevote or tryFinalizeCommit
+		added, err = cs.addProposalBlockPart(msg, peerID)
+		if err != nil && msg.Round != cs.Round {
+			cs.Logger.Debug("Received block part from wrong round", "height", cs.Height, "csRound", cs.Round, "blockRound", msg.Round)
+			err = nil
+		}
+	case *VoteMessage:
+		// attempt to add the vote and dupeout the validator if its a duplicate signature
+		// if the vote gives us a 2/3-any or 2/3-one, we transition
+		added, err = cs.tryAddVote(msg.Vote, peerID)
+		if err == ErrAddingVote {
+			// TODO: punish peer
+			// We probably don't want to stop the peer here. The vote does not
+			// necessarily comes from a malicious peer but can be just broadcasted by
+			// a typical peer.
+		}
+	default:
+		cs.Logger.Error("Unknown msg type", "type", reflect.TypeOf(msg))
+		return
+	}
+
+	// relay what we didn't know yet, including our own messages
+	if added && !cs.replayMode {
+		if peerID == "" && cs.byzantine != nil {
+			cs.broadcastOwn(msg)
+		} else {
+			fireBroadcast(cs.evsw, msg)
+		}
+	}
+
+	if err != nil {
+		cs.Logger.Error("Error with msg", "height", cs.Height, "round", cs.Round, "peer", peerID, "err", err, "msg", msg)
+	}
+}
+
+func (cs *BFTConsensus) handleTimeout(ti timeoutInfo, rs RoundState) {
+	cs.Logger.Debug("Received tock", "timeout", ti.Duration, "height", ti.Height, "round", ti.Round, "step", ti.Step)
+
+	// timeouts must be for current height, round, step
+	if ti.Height != rs.Height || ti.Round < rs.Round || (ti.Round == rs.Round && ti.Step < rs.Step) {
+		cs.Logger.Debug("Ignoring tock because we're ahead", "height", rs.Height, "round", rs.Round, "step", rs.Step)
+		return
+	}
+
+	// the timeout will now cause a state transition
+	cs.mtx.Lock()
+	defer cs.mtx.Unlock()
+	if cs.halter.halted {
+		return
+	}
+
+	switch ti.Step {
+	case RoundStepNewHeight:
+		// NewRound event fired from enterNewRound.
+		// XXX: should we fire timeout here (for timeout commit)?
+		cs.enterNewRound(ti.Height, 0)
+	case RoundStepNewRound:
+		cs.enterPropose(ti.Height, 0)
+	case RoundStepPropose:
+		cs.eventBus.PublishEventTimeoutPropose(cs.RoundStateEvent())
+		cs.enterPrevote(ti.Height, ti.Round)
+	case RoundStepPrevoteWait:
+		cs.eventBus.PublishEventTimeoutWait(cs.RoundStateEvent())
+		cs.enterPrecommit(ti.Height, ti.Round)
+	case RoundStepPrecommitWait:
+		cs.eventBus.PublishEventTimeoutWait(cs.RoundStateEvent())
+		cs.enterPrecommit(ti.Height, ti.Round)
+		cs.enterNewRound(ti.Height, ti.Round+1)
+	default:
+		panic(fmt.Sprintf("Invalid timeout step: %v", ti.Step))
+	}
+
+}
+
+func (cs *BFTConsensus) handleTxsAvailable() {
+	cs.mtx.Lock()
+	defer cs.mtx.Unlock()
+	if cs.halter.halted {
+		return
+	}
+	// we only need to do this for round 0
+	cs.enterNewRound(cs.Height, 0)
+	cs.enterPropose(cs.Height, 0)
+}
+
+//-----------------------------------------------------------------------------
+// State functions
+// Used internally by handleTimeout and handleMsg to make state transitions
+
+// Enter: `timeoutNewHeight` by startTime (commitTime+timeoutCommit),
+//
+//	or, if SkipTimeoutCommit==true, after receiving all precommits from (height,round-1)
+//
+// Enter: `timeoutPrecommits` after any +2/3 precommits from (height,round-1)
+// Enter: +2/3 prevotes any or +2/3 precommits for block or any from (height, round)
+// NOTE: cs.StartTime was already set for height.
+func (cs *BFTConsensus) enterNewRound(height int64, round int) {
+	logger := cs.Logger.With("height", height, "round", round)
+
+	if cs.Height != height || round < cs.Round || (cs.Round == round && cs.Step != RoundStepNewHeight) {
+		logger.Debug(fmt.Sprintf("enterNewRound(%v/%v): Invalid args. Current step: %v/%v/%v", height, round, cs.Height, cs.Round, cs.Step))
+		return
+	}
+
+	if now := ttime.Now(); cs.StartTime.After(now) {
+		logger.Info("Need to set a buffer and log message here for sanity.", "startTime", cs.StartTime, "now", now)
+	}
+
+	logger.Info(fmt.Sprintf("enterNewRound(%v/%v). Current: %v/%v/%v", height, round, cs.Height, cs.Round, cs.Step))
+
+	// Increment validators if necessary
+	validators := cs.Validators
+	if cs.Round < round {
+		validators = validators.Copy()
+		validators.IncrementProposerPriority(round - cs.Round)
+	}
+
+	// Setup new round
+	// we don't fire newStep for this step,
+	// but we fire an event, so update the round step first
+	cs.updateRoundStep(round, RoundStepNewRound)
+	cs.Validators = validators
+	cs.Proposer = cs.roundProposer(validators, round)
+	if round == 0 {
+		// We've already reset these upon new height,
+		// and meanwhile we might have received a proposal
+		// for round 0.
+	} else {
+		logger.Info("Resetting Proposal info")
+		cs.Proposal = nil
+		cs.ProposalBlock = nil
+		cs.ProposalBlockParts = nil
+	}
+	cs.Votes.SetRound(round + 1) // also track next round (round+1) to allow round-skipping
+	cs.TriggeredTimeoutPrecommit = false
+
+	cs.eventBus.PublishEventNewRound(cs.NewRoundEvent())
+
+	// Wait for txs to be available in the storage
+	// before we enterPropose in round 0. If the last block changed the app hash,
+	// we may need an empty "proof" block, and enterPropose immediately.
+	waitForTxs := cs.config.WaitForTxs() && round == 0 && !cs.needProofBlock(height)
+	if waitForTxs {
+		if cs.config.CreateEmptyBlocksInterval > 0 {
+			cs.scheduleTimeout(cs.config.CreateEmptyBlocksInterval, height, round,
+				RoundStepNewRound)
+		}
+	} else {
+		cs.enterPropose(height, round)
+	}
+}
+
+// needProofBlock returns true on the first height (so the genesis app hash is signed right away)
+// and where the last block (height-1) caused the app hash to change
+func (cs *BFTConsensus) needProofBlock(height int64) bool {
+	if height == 1 {
+		return true
+	}
+
+	lastBlockMeta := cs.blockStore.LoadBlockMeta(height - 1)
+	return !bytes.Equal(cs.state.AppHash, lastBlockMeta.Header.AppHash)
+}
+
+// Enter (CreateEmptyBlocks): from enterNewRound(height,round)
+// Enter (CreateEmptyBlocks, CreateEmptyBlocksInterval > 0 ): after enterNewRound(height,round), after timeout of CreateEmptyBlocksInterval
+// Enter (!CreateEmptyBlocks) : after enterNewRound(height,round), once txs are in the storage
+func (cs *BFTConsensus) enterPropose(height int64, round int) {
+	logger := cs.Logger.With("height", height, "round", round)
+
+	if cs.Height != height || round < cs.Round || (cs.Round == round && RoundStepPropose <= cs.Step) {
+		logger.Debug(fmt.Sprintf("enterPropose(%v/%v): Invalid args. Current step: %v/%v/%v", height, round, cs.Height, cs.Round, cs.Step))
+		return
+	}
+	logger.Info(fmt.Sprintf("enterPropose(%v/%v). Current: %v/%v/%v", height, round, cs.Height, cs.Round, cs.Step))
+
+	defer func() {
+		// Done enterPropose:
+		cs.updateRoundStep(round, RoundStepPropose)
+		cs.newStep()
+
+		// If we have the whole proposal + POL, then goto Prevote now.
+		// else, we'll enterPrevote when the rest of the proposal is received (in AddProposalBlockPart),
+		// or else after timeoutPropose
+		if cs.isProposalComplete() {
+			cs.enterPrevote(height, cs.Round)
+		}
+	}()
+
+	// If we don't get the proposal and all block parts quick enough, enterPrevote
+	cs.scheduleTimeout(cs.config.Propose(round), height, round, RoundStepPropose)
+
+	// Nothing more to do if we're not a validator
+	address := cs.validatorAddress()
+	if address == nil {
+		logger.Debug("This node is not a validator", "vals", cs.Validators)
+		return
+	}
+	logger.Debug("This node is a validator")
+
+	if cs.isProposer(address) {
+		logger.Info("enterPropose: Our turn to propose", "proposer", cs.Proposer.Address, "validator", cs.validator)
+		cs.decideProposal(height, round)
+	} else {
+		logger.Info("enterPropose: Not our turn to propose", "proposer", cs.Proposer.Address, "validator", cs.validator)
+	}
+}
+
+// validatorAddress returns the address of the local validator if it is in
+// the validator set, nil otherwise.
+func (cs *BFTConsensus) validatorAddress() types.Address {
+	if cs.validator == nil {
+		return nil
+	}
+	addr := cs.validator.GetPubKey().Address()
+	if !cs.Validators.HasAddress(addr) {
+		return nil
+	}
+	return addr
+}
+
+func (cs *BFTConsensus) isProposer(address []byte) bool {
+	return bytes.Equal(cs.Proposer.Address, address)
+}
+
+// roundProposer returns the proposer of a round of the current height,
+// selected as the consensus params say. The validators must have been
+// incremented up to the round. The random selection is seeded by the
+// LastCommitHash of the last block of the state, see types.ProposerSeed.
+func (cs *BFTConsensus) roundProposer(validators *types.ValidatorSet, round int) *types.Validator {
+	if cs.state.ConsensusParams.Proposer.Random() {
+		return validators.RandomProposer(cs.lastCommitHash(), cs.Height, round)
+	}
+	return validators.GetProposer()
+}
+
+// lastCommitHash returns the LastCommitHash of the last block of the state,
+// empty at the first height.
+func (cs *BFTConsensus) lastCommitHash() []byte {
+	if cs.state.LastBlockHeight == 0 {
+		return nil
+	}
+	blockMeta := cs.blockStore.LoadBlockMeta(cs.state.LastBlockHeight)
+	if blockMeta == nil {
+		cmn.PanicSanity(fmt.Sprintf("Failed to load the last block %v to select the proposer", cs.state.LastBlockHeight))
+	}
+	return blockMeta.Header.LastCommitHash
+}
+
+func (cs *BFTConsensus) defaultDecideProposal(height int64, round int) {
+	if cs.validatorAddress() == nil {
+		return
+	}
+	var block *types.Block
+	var blockParts *types.PartSet
+
+	// Decide on block
+	if cs.ValidBlock != nil {
+		// If there is valid block, choose that.
+		block, blockParts = cs.ValidBlock, cs.ValidBlockParts
+	} else {
+		// Create a new proposal block from state/txs from the storage.
+		block, blockParts = cs.createProposalBlock()
+		if block == nil { // on error
+			return
+		}
+	}
+
+	// Flush the WAL. Otherwise, we may not recompute the same proposal to sign, and the validator will refuse to sign anything.
+	cs.wal.FlushAndSync()
+
+	// Make proposal
+	propBlockId := types.BlockID{Hash: block.Hash(), PartsHeader: blockParts.Header()}
+	proposal := types.NewProposal(height, round, cs.ValidRound, propBlockId)
+	if err := cs.validator.SignProposal(cs.state.LeagueID, proposal); err == nil {
+
+		// send proposal and block parts on internal msg queue
+		cs.sendInternalMessage(msgInfo{&ProposalMessage{proposal}, ""})
+		for i := 0; i < blockParts.Total(); i++ {
+			part := blockParts.GetPart(i)
+			cs.sendInternalMessage(msgInfo{&BlockPartMessage{cs.Height, cs.Round, part}, ""})
+		}
+		cs.Logger.Info("Signed proposal", "height", height, "round", round, "proposal", proposal)
+		cs.Logger.Debug(fmt.Sprintf("Signed proposal block: %v", block))
+	} else if !cs.replayMode {
+		cs.Logger.Error("enterPropose: Error signing proposal", "height", height, "round", round, "err", err)
+	}
+}
+
+// Returns true if the proposal block is complete &&
+// (if POLRound was proposed, we have +2/3 prevotes from there).
+func (cs *BFTConsensus) isProposalComplete() bool {
+	if cs.Proposal == nil || cs.ProposalBlock == nil {
+		return false
+	}
+	// we have the proposal. if there's a POLRound,
+	// make sure we have the prevotes from it too
+	if cs.Proposal.POLRound < 0 {
+		return true
+	}
+	// if this is false the proposer is lying or we haven't received the POL yet
+	return cs.Votes.Prevotes(cs.Proposal.POLRound).HasTwoThirdsMajority()
+
+}
+
+// Create the next block to propose and return it.
+// We really only need to return the parts, but the block
+// is returned for convenience so we can log the proposal block.
+// Returns nil block upon error.
+// NOTE: keep it side-effect free for clarity.
+func (cs *BFTConsensus) createProposalBlock() (block *types.Block, blockParts *types.PartSet) {
+	var commit *types.Commit
+	switch {
+	case cs.Height == 1:
+		// We're creating a proposal for the first block.
+		// The commit is empty, but not nil.
+		commit = types.NewCommit(types.BlockID{}, nil)
+	case cs.LastCommit.HasTwoThirdsMajority():
+		// Make the commit from LastCommit
+		commit = cs.LastCommit.MakeCommit()
+	default:
+		// This shouldn't happen.
+		cs.Logger.Error("enterPropose: Cannot propose anything: No commit for the previous block.")
+		return
+	}
+
+	proposerAddr := cs.validatorAddress()
+	if proposerAddr == nil {
+		cs.Logger.Error("enterPropose: Cannot propose anything: This node is not a validator.")
+		return
+	}
+	block, blockParts = cs.blockExec.CreateProposalBlock(cs.Height, cs.state, commit, proposerAddr)
+	if cs.pipeline != nil && block != nil {
+		// the txs of the block in execution are still in the storage
+		block, blockParts = withoutTxs(block, blockParts, cs.pipeline.pendingTxs())
+	}
+	return block, blockParts
+}
+
+// Enter: `timeoutPropose` after entering Propose.
+// Enter: proposal block and POL is ready.
+// Enter: any +2/3 prevotes for future round.
+// Prevote for LockedBlock if we're locked, or ProposalBlock if valid.
+// Otherwise vote nil.
+func (cs *BFTConsensus) enterPrevote(height int64, round int) {
+	if cs.Height != height || round < cs.Round || (cs.Round == round && RoundStepPrevote <= cs.Step) {
+		cs.Logger.Debug(fmt.Sprintf("enterPrevote(%v/%v): Invalid args. Current step: %v/%v/%v", height, round, cs.Height, cs.Round, cs.Step))
+		return
+	}
+
+	defer func() {
+		// Done enterPrevote:
+		cs.updateRoundStep(round, RoundStepPrevote)
+		cs.newStep()
+	}()
+
+	cs.Logger.Info(fmt.Sprintf("enterPrevote(%v/%v). Current: %v/%v/%v", height, round, cs.Height, cs.Round, cs.Step))
+
+	// Sign and broadcast vote as necessary
+	cs.doPrevote(height, round)
+
+	// Once `addVote` hits any +2/3 prevotes, we will go to PrevoteWait
+	// (so we have more time to try and collect +2/3 prevotes for a single block)
+}
+
+func (cs *BFTConsensus) defaultDoPrevote(height int64, round int) {
+	logger := cs.Logger.With("height", height, "round", round)
+
+	// If a block is locked, prevote that.
+	if cs.LockedBlock != nil {
+		logger.Info("enterPrevote: Block was locked")
+		cs.signAddVote(types.PrevoteType, cs.LockedBlock.Hash(), cs.LockedBlockParts.Header())
+		return
+	}
+
+	// If ProposalBlock is nil, prevote nil.
+	if cs.ProposalBlock == nil {
+		logger.Info("enterPrevote: ProposalBlock is nil")
+		cs.signAddVote(types.PrevoteType, nil, types.PartSetHeader{})
+		return
+	}
+
+	// Validate proposal block
+	err := cs.blockExec.ValidateBlock(cs.state, cs.ProposalBlock)
+	if err != nil {
+		// ProposalBlock is invalid, prevote nil.
+		logger.Error("enterPrevote: ProposalBlock is invalid", "err", err)
+		cs.signAddVote(types.PrevoteType, nil, types.PartSetHeader{})
+		return
+	}
+
+	// Prevote cs.ProposalBlock
+	// NOTE: the proposal signature is validated when it is received,
+	// and the proposal block parts are validated as they are received (against the merkle hash in the proposal)
+	logger.Info("enterPrevote: ProposalBlock is valid")
+	cs.signAddVote(types.PrevoteType, cs.ProposalBlock.Hash(), cs.ProposalBlockParts.Header())
+}
+
+// Enter: any +2/3 prevotes at next round.
+func (cs *BFTConsensus) enterPrevoteWait(height int64, round int) {
+	logger := cs.Logger.With("height", height, "round", round)
+
+	if cs.Height != height || round < cs.Round || (cs.Round == round && RoundStepPrevoteWait <= cs.Step) {
+		logger.Debug(fmt.Sprintf("enterPrevoteWait(%v/%v): Invalid args. Current step: %v/%v/%v", height, round, cs.Height, cs.Round, cs.Step))
+		return
+	}
+	if !cs.Votes.Prevotes(round).HasTwoThirdsAny() {
+		cmn.PanicSanity(fmt.Sprintf("enterPrevoteWait(%v/%v), but Prevotes does not have any +2/3 votes", height, round))
+	}
+	logger.Info(fmt.Sprintf("enterPrevoteWait(%v/%v). Current: %v/%v/%v", height, round, cs.Height, cs.Round, cs.Step))
+
+	defer func() {
+		// Done enterPrevoteWait:
+		cs.updateRoundStep(round, RoundStepPrevoteWait)
+		cs.newStep()
+	}()
+
+	// Wait for some more prevotes; enterPrecommit
+	cs.scheduleTimeout(cs.config.Prevote(round), height, round, RoundStepPrevoteWait)
+}
+
+// Enter: `timeoutPrevote` after any +2/3 prevotes.
+// Enter: `timeoutPrecommit` after any +2/3 precommits.
+// Enter: +2/3 precomits for block or nil.
+// Lock & precommit the ProposalBlock if we have enough prevotes for it (a POL in this round)
+// else, unlock an existing lock and precommit nil if +2/3 of prevotes were nil,
+// else, precommit nil otherwise.
+func (cs *BFTConsensus) enterPrecommit(height int64, round int) {
+	logger := cs.Logger.With("height", height, "round", round)
+
+	if cs.Height != height || round < cs.Round || (cs.Round == round && RoundStepPrecommit <= cs.Step) {
+		logger.Debug(fmt.Sprintf("enterPrecommit(%v/%v): Invalid args. Current step: %v/%v/%v", height, round, cs.Height, cs.Round, cs.Step))
+		return
+	}
+
+	logger.Info(fmt.Sprintf("enterPrecommit(%v/%v). Current: %v/%v/%v", height, round, cs.Height, cs.Round, cs.Step))
+
+	defer func() {
+		// Done enterPrecommit:
+		cs.updateRoundStep(round, RoundStepPrecommit)
+		cs.newStep()
+	}()
+
+	// check for a polka
+	blockID, ok := cs.Votes.Prevotes(round).TwoThirdsMajority()
+
+	// If we don't have a polka, we must precommit nil.
+	if !ok {
+		if cs.LockedBlock != nil {
+			logger.Info("enterPrecommit: No +2/3 prevotes during enterPrecommit while we're locked. Precommitting nil")
+		} else {
+			logger.Info("enterPrecommit: No +2/3 prevotes during enterPrecommit. Precommitting nil.")
+		}
+		cs.signAddVote(types.PrecommitType, nil, types.PartSetHeader{})
+		return
+	}
+
+	// At this point +2/3 prevoted for a particular block or nil.
+	cs.eventBus.PublishEventPolka(cs.RoundStateEvent())
+
+	// the latest POLRound should be this round.
+	polRound, _ := cs.Votes.POLInfo()
+	if polRound < round {
+		cmn.PanicSanity(fmt.Sprintf("This POLRound should be %v but got %v", round, polRound))
+	}
+
+	// +2/3 prevoted nil. Unlock and precommit nil.
+	if len(blockID.Hash) == 0 {
+		if cs.LockedBlock == nil {
+			logger.Info("enterPrecommit: +2/3 prevoted for nil.")
+		} else {
+			logger.Info("enterPrecommit: +2/3 prevoted for nil. Unlocking")
+			cs.LockedRound = -1
+			cs.LockedBlock = nil
+			cs.LockedBlockParts = nil
+			cs.eventBus.PublishEventUnlock(cs.RoundStateEvent())
+		}
+		cs.signAddVote(types.PrecommitType, nil, types.PartSetHeader{})
+		return
+	}
+
+	// At this point, +2/3 prevoted for a particular block.
+
+	// If we're already locked on that block, precommit it, and update the LockedRound
+	if cs.LockedBlock.HashesTo(blockID.Hash) {
+		logger.Info("enterPrecommit: +2/3 prevoted locked block. Relocking")
+		cs.LockedRound = round
+		cs.eventBus.PublishEventRelock(cs.RoundStateEvent())
+		cs.signAddVote(types.PrecommitType, blockID.Hash, blockID.PartsHeader)
+		return
+	}
+
+	// If +2/3 prevoted for proposal block, stage and precommit it
+	if cs.ProposalBlock.HashesTo(blockID.Hash) {
+		logger.Info("enterPrecommit: +2/3 prevoted proposal block. Locking", "hash", blockID.Hash)
+		// Validate the block.
+		if err := cs.blockExec.ValidateBlock(cs.state, cs.ProposalBlock); err != nil {
+			cmn.PanicConsensus(fmt.Sprintf("enterPrecommit: +2/3 prevoted for an invalid block: %v", err))
+		}
+		cs.LockedRound = round
+		cs.LockedBlock = cs.ProposalBlock
+		cs.LockedBlockParts = cs.ProposalBlockParts
+		cs.eventBus.PublishEventLock(cs.RoundStateEvent())
+		cs.signAddVote(types.PrecommitType, blockID.Hash, blockID.PartsHeader)
+		return
+	}
+
+	// There was a polka in this round for a block we don't have.
+	// Fetch that block, unlock, and precommit nil.
+	// The +2/3 prevotes for this round is the POL for our unlock.
+	// TODO: In the future save the POL prevotes for justification.
+	cs.LockedRound = -1
+	cs.LockedBlock = nil
+	cs.LockedBlockParts = nil
+	if !cs.ProposalBlockParts.HasHeader(blockID.PartsHeader) {
+		cs.ProposalBlock = nil
+		cs.ProposalBlockParts = types.NewPartSetFromHeader(blockID.PartsHeader)
+	}
+	cs.eventBus.PublishEventUnlock(cs.RoundStateEvent())
+	cs.signAddVote(types.PrecommitType, nil, types.PartSetHeader{})
+}
+
+// Enter: any +2/3 precommits for next round.
+func (cs *BFTConsensus) enterPrecommitWait(height int64, round int) {
+	logger := cs.Logger.With("height", height, "round", round)
+
+	if cs.Height != height || round < cs.Round || (cs.Round == round && cs.TriggeredTimeoutPrecommit) {
+		logger.Debug(
+			fmt.Sprintf(
+				"enterPrecommitWait(%v/%v): Invalid args. "+
+					"Current state is Height/Round: %v/%v/, TriggeredTimeoutPrecommit:%v",
+				height, round, cs.Height, cs.Round, cs.TriggeredTimeoutPrecommit))
+		return
+	}
+	if !cs.Votes.Precommits(round).HasTwoThirdsAny() {
+		cmn.PanicSanity(fmt.Sprintf("enterPrecommitWait(%v/%v), but Precommits does not have any +2/3 votes", height, round))
+	}
+	logger.Info(fmt.Sprintf("enterPrecommitWait(%v/%v). Current: %v/%v/%v", height, round, cs.Height, cs.Round, cs.Step))
+
+	defer func() {
+		// Done enterPrecommitWait:
+		cs.TriggeredTimeoutPrecommit = true
+		cs.newStep()
+	}()
+
+	// Wait for some more precommits; enterNewRound
+	cs.scheduleTimeout(cs.config.Precommit(round), height, round, RoundStepPrecommitWait)
+
+}
+
+// Enter: +2/3 precommits for block
+func (cs *BFTConsensus) enterCommit(height int64, commitRound int) {
+	logger := cs.Logger.With("height", height, "commitRound", commitRound)
+
+	if cs.Height != height || RoundStepCommit <= cs.Step {
+		logger.Debug(fmt.Sprintf("enterCommit(%v/%v): Invalid args. Current step: %v/%v/%v", height, commitRound, cs.Height, cs.Round, cs.Step))
+		return
+	}
+	logger.Info(fmt.Sprintf("enterCommit(%v/%v). Current: %v/%v/%v", height, commitRound, cs.Height, cs.Round, cs.Step))
+
+	defer func() {
+		// Done enterCommit:
+		// keep cs.Round the same, commitRound points to the right Precommits set.
+		cs.updateRoundStep(cs.Round, RoundStepCommit)
+		cs.CommitRound = commitRound
+		cs.CommitTime = ttime.Now()
+		cs.newStep()
+
+		// Maybe finalize immediately.
+		cs.tryFinalizeCommit(height)
+	}()
+
+	blockID, ok := cs.Votes.Precommits(commitRound).TwoThirdsMajority()
+	if !ok {
+		cmn.PanicSanity("RunActionCommit() expects +2/3 precommits")
+	}
+
+	// The Locked* fields no longer matter.
+	// Move them over to ProposalBlock if they match the commit hash,
+	// otherwise they'll be cleared in updateToState.
+	if cs.LockedBlock.HashesTo(blockID.Hash) {
+		logger.Info("Commit is for locked block. Set ProposalBlock=LockedBlock", "blockHash", blockID.Hash)
+		cs.ProposalBlock = cs.LockedBlock
+		cs.ProposalBlockParts = cs.LockedBlockParts
+	}
+
+	// If we don't have the block being committed, set up to get it.
+	if !cs.ProposalBlock.HashesTo(blockID.Hash) {
+		if !cs.ProposalBlockParts.HasHeader(blockID.PartsHeader) {
+			logger.Info("Commit is for a block we don't know about. Set ProposalBlock=nil", "proposal", cs.ProposalBlock.Hash(), "commit", blockID.Hash)
+			// We're getting the wrong block.
+			// Set up ProposalBlockParts and keep waiting.
+			cs.ProposalBlock = nil
+			cs.ProposalBlockParts = types.NewPartSetFromHeader(blockID.PartsHeader)
+			cs.eventBus.PublishEventValidBlock(cs.RoundStateEvent())
+			cs.evsw.FireEvent(types.EventValidBlock, &cs.RoundState)
+		}
+		// else {
+		// We just need to keep waiting.
+		// }
+	}
+}
+
+// If we have the block AND +2/3 commits for it, finalize.
+func (cs *BFTConsensus) tryFinalizeCommit(height int64) {
+	logger := cs.Logger.With("height", height)
+
+	if cs.Height != height {
+		cmn.PanicSanity(fmt.Sprintf("tryFinalizeCommit() cs.Height: %v vs height: %v", cs.Height, height))
+	}
+
+	blockID, ok := cs.Votes.Precommits(cs.CommitRound).TwoThirdsMajority()
+	if !ok || len(blockID.Hash) == 0 {
+		logger.Error("Attempt to finalize failed. There was no +2/3 majority, or +2/3 was for <nil>.")
+		return
+	}
+	if !cs.ProposalBlock.HashesTo(blockID.Hash) {
+		// TODO: this happens every time if we're not a validator (ugly logs)
+		// TODO: ^^ wait, why does it matter that we're a validator?
+		logger.Info("Attempt to finalize failed. We don't have the commit block.", "proposal-block", cs.ProposalBlock.Hash(), "commit-block", blockID.Hash)
+		return
+	}
+
+	//	go
+	cs.finalizeCommit(height)
+}
+
+// Increment height and goto RoundStepNewHeight
+func (cs *BFTConsensus) finalizeCommit(height int64) {
+	if cs.Height != height || cs.Step != RoundStepCommit {
+		cs.Logger.Debug(fmt.Sprintf("finalizeCommit(%v): Invalid args. Current step: %v/%v/%v", height, cs.Height, cs.Round, cs.Step))
+		return
+	}
+
+	blockID, ok := cs.Votes.Precommits(cs.CommitRound).TwoThirdsMajority()
+	block, blockParts := cs.ProposalBlock, cs.ProposalBlockParts
+
+	if !ok {
+		cmn.PanicSanity(fmt.Sprintf("Cannot finalizeCommit, commit does not have two thirds majority"))
+	}
+	if !blockParts.HasHeader(blockID.PartsHeader) {
+		cmn.PanicSanity(fmt.Sprintf("Expected ProposalBlockParts header to be commit header"))
+	}
+	if !block.HashesTo(blockID.Hash) {
+		cmn.PanicSanity(fmt.Sprintf("Cannot finalizeCommit, ProposalBlock does not hash to commit hash"))
+	}
+	if err := cs.blockExec.ValidateBlock(cs.state, block); err != nil {
+		cmn.PanicConsensus(fmt.Sprintf("+2/3 committed an invalid block: %v", err))
+	}
+
+	cs.Logger.Info(fmt.Sprintf("Finalizing commit of block with %d txs", block.NumTxs),
+		"height", block.Height, "hash", block.Hash(), "root", block.AppHash)
+	cs.Logger.Info(fmt.Sprintf("%v", block))
+
+	fail.Fail() // XXX
+
+	// A league pipelining the execution saves the state of the next height
+	// before the block, so a restarted cell finds the state of the last
+	// block saved. The block is executed once saved.
+	var pipelinedState sm.State
+	if cs.pipeline != nil {
+		var err error
+		if pipelinedState, err = cs.pipeline.commit(cs.state.Copy(), blockID, block); err != nil {
+			cs.Logger.Error("Error on ApplyBlock. Did the application crash? Please restart dgrid", "err", err)
+			if err := cmn.Kill(); err != nil {
+				cs.Logger.Error("Failed to kill this process - please do so manually", "err", err)
+			}
+			return
+		}
+	}
+
+	fail.Fail() // XXX
+
+	// Save to blockStore.
+	if cs.blockStore.Height() < block.Height {
+		// NOTE: the seenCommit is local justification to commit this block,
+		// but may differ from the LastCommit included in the next block
+		precommits := cs.Votes.Precommits(cs.CommitRound)
+		seenCommit := precommits.MakeCommit()
+		cs.blockStore.SaveBlock(block, blockParts, seenCommit)
+	} else {
+		// Happens during replay if we already saved the block but didn't commit
+		cs.Logger.Info("Calling finalizeCommit on already stored block", "height", block.Height)
+	}
+
+	fail.Fail() // XXX
+
+	// Write EndHeightMessage{} for this height, implying that the blockstore
+	// has saved the block.
+	//
+	// If we crash before writing this EndHeightMessage{}, we will recover by
+	// running ApplyBlock during the Handshake when we restart.  If we
+	// didn't save the block to the blockstore before writing
+	// EndHeightMessage{}, we'd have to change WAL replay -- currently it
+	// complains about replaying for heights where an #ENDHEIGHT entry already
+	// exists.
+	//
+	// Either way, the BFTConsensus should not be resumed until we
+	// successfully call ApplyBlock (ie. later here, or in Handshake after
+	// restart).
+	cs.wal.WriteSync(EndHeightMessage{height}) // NOTE: fsync
+
+	fail.Fail() // XXX
+
+	// Create a copy of the state for staging and an event cache for txs.
+	stateCopy := cs.state.Copy()
+
+	// Execute and commit the block, update and save the state, and update the storage.
+	// NOTE The block.AppHash wont reflect these txs until the next block,
+	// or the one after if the execution is pipelined.
+	var err error
+	if cs.pipeline != nil {
+		cs.pipeline.execute()
+		stateCopy = pipelinedState
+	} else {
+		stateCopy, err = cs.blockExec.ApplyBlock(stateCopy, blockID, block)
+	}
+	if err != nil {
+		cs.Logger.Error("Error on ApplyBlock. Did the application crash? Please restart dgrid", "err", err)
+		err := cmn.Kill()
+		if err != nil {
+			cs.Logger.Error("Failed to kill this process - please do so manually", "err", err)
+		}
+		return
+	}
+
+	fail.Fail() // XXX
+
+	cs.metrics.recordBlock(cs.state, block, cs.CommitRound)
+
+	// NewHeightStep!
+	cs.updateToState(stateCopy)
+
+	fail.Fail() // XXX
+
+	// Another engine takes over the league from the next height, once the
+	// block is executed.
+	if cs.pipeline != nil && cs.halter.halts(cs.state) {
+		if cs.state, err = cs.pipeline.drain(cs.state); err != nil {
+			cs.Logger.Error("Error on ApplyBlock. Did the application crash? Please restart dgrid", "err", err)
+			return
+		}
+	}
+	if cs.halter.maybeHalt(cs.state) {
+		return
+	}
+
+	// cs.StartTime is already set.
+	// Schedule Round0 to start soon.
+	cs.scheduleRound0(&cs.RoundState)
+
+	// By here,
+	// * cs.Height has been increment to height+1
+	// * cs.Step is now RoundStepNewHeight
+	// * cs.StartTime is set to when we will start round0.
+}
+
+//-----------------------------------------------------------------------------
+
+func (cs *BFTConsensus) defaultSetProposal(proposal *types.Proposal) error {
+	// Already have one
+	// TODO: possibly catch double proposals
+	if cs.Proposal != nil {
+		return nil
+	}
+
+	// Does not apply
+	if proposal.Height != cs.Height || proposal.Round != cs.Round {
+		return nil
+	}
+
+	// Verify POLRound, which must be -1 or in range [0, proposal.Round).
+	if proposal.POLRound < -1 ||
+		(proposal.POLRound >= 0 && proposal.POLRound >= proposal.Round) {
+		return ErrInvalidProposalPOLRound
+	}
+
+	// Verify signature
+	if !cs.Proposer.PubKey.VerifyBytes(proposal.SignBytes(cs.state.LeagueID), proposal.Signature) {
+		return ErrInvalidProposalSignature
+	}
+
+	cs.Proposal = proposal
+	// We don't update cs.ProposalBlockParts if it is already set.
+	// This happens if we're already in cstypes.RoundStepCommit or if there is a valid block in the current round.
+	// TODO: We can check if Proposal is for a different block as this is a sign of misbehavior!
+	if cs.ProposalBlockParts == nil {
+		cs.ProposalBlockParts = types.NewPartSetFromHeader(proposal.BlockID.PartsHeader)
+	}
+	cs.Logger.Info("Received proposal", "proposal", proposal)
+	return nil
+}
+
+// NOTE: block is not necessarily valid.
+// Asynchronously triggers either enterPrevote (before we timeout of propose) or tryFinalizeCommit, once we have the full block.
+func (cs *BFTConsensus) addProposalBlockPart(msg *BlockPartMessage, peerID types.P2PID) (added bool, err error) {
+	height, round, part := msg.Height, msg.Round, msg.Part
+
+	// Blocks might be reused, so round mismatch is OK
+	if cs.Height != height {
+		cs.Logger.Debug("Received block part from wrong height", "height", height, "round", round)
+		return false, nil
+	}
+
+	// We're not expecting a block part.
+	if cs.ProposalBlockParts == nil {
+		// NOTE: this can happen when we've gone to a higher round and
+		// then receive parts from the previous round - not necessarily a bad peer.
+		cs.Logger.Info("Received a block part when we're not expecting any",
+			"height", height, "round", round, "index", part.Index, "peer", peerID)
+		return false, nil
+	}
+
+	added, err = cs.ProposalBlockParts.AddPart(part)
+	if err != nil {
+		return added, err
+	}
+	if added && cs.ProposalBlockParts.IsComplete() {
+		// Added and completed!
+		_, err = cdc.UnmarshalBinaryReader(
+			cs.ProposalBlockParts.GetReader(),
+			&cs.ProposalBlock,
+			cs.state.ConsensusParams.Block.MaxBytes,
+		)
+		if err != nil {
+			return added, err
+		}
+		// NOTE: it's possible to receive complete proposal blocks for future rounds without having the proposal
+		cs.Logger.Info("Received complete proposal block", "height", cs.ProposalBlock.Height, "hash", cs.ProposalBlock.Hash())
+		cs.eventBus.PublishEventCompleteProposal(cs.CompleteProposalEvent())
+
+		// Update Valid* if we can.
+		prevotes := cs.Votes.Prevotes(cs.Round)
+		blockID, hasTwoThirds := prevotes.TwoThirdsMajority()
+		if hasTwoThirds && !blockID.IsZero() && (cs.ValidRound < cs.Round) {
+			if cs.ProposalBlock.HashesTo(blockID.Hash) {
+				cs.Logger.Info("Updating valid block to new proposal block",
+					"valid-round", cs.Round, "valid-block-hash", cs.ProposalBlock.Hash())
+				cs.ValidRound = cs.Round
+				cs.ValidBlock = cs.ProposalBlock
+				cs.ValidBlockParts = cs.ProposalBlockParts
+			}
+			// TODO: In case there is +2/3 majority in Prevotes set for some
+			// block and cs.ProposalBlock contains different block, either
+			// proposer is faulty or voting power of faulty processes is more
+			// than 1/3. We should trigger in the future accountability
+			// procedure at this point.
+		}
+
+		if cs.Step <= RoundStepPropose && cs.isProposalComplete() {
+			// Move onto the next step
+			cs.enterPrevote(height, cs.Round)
+			if hasTwoThirds { // this is optimisation as this will be triggered when prevote is added
+				cs.enterPrecommit(height, cs.Round)
+			}
+		} else if cs.Step == RoundStepCommit {
+			// If we're waiting on the proposal block...
+			cs.tryFinalizeCommit(height)
+		}
+		return added, nil
+	}
+	return added, nil
+}
+
+// Attempt to add the vote. if its a duplicate signature, dupeout the validator
+func (cs *BFTConsensus) tryAddVote(vote *types.Vote, peerID types.P2PID) (bool, error) {
+	added, err := cs.addVote(vote, peerID)
+	if err != nil {
+		// If the vote height is off, we'll just ignore it,
+		// But if it's a conflicting sig, add it to the cs.evpool.
+		// If it's otherwise invalid, punish peer.
+		if err == ErrVoteHeightMismatch {
+			return added, err
+		} else if voteErr, ok := err.(*types.ErrVoteConflictingVotes); ok {
+			if addr := cs.validatorAddress(); addr != nil && bytes.Equal(vote.ValidatorAddress, addr) {
+				cs.Logger.Error("Found conflicting vote from ourselves. Did you unsafe_reset a validator?", "height", vote.Height, "round", vote.Round, "type", vote.Type)
+				return added, err
+			}
+			cs.evpool.AddEvidence(voteErr.DuplicateVoteEvidence)
+			return added, err
+		} else {
+			// Either
+			// 1) bad peer OR
+			// 2) not a bad peer? this can also err sometimes with "Unexpected step" OR
+			// 3) tmkms use with multiple validators connecting to a single tmkms instance
+			// 		(https://github.com/tendermint/tendermint/issues/3839).
+			cs.Logger.Info("Error attempting to add vote", "err", err)
+			return added, ErrAddingVote
+		}
+	}
+	return added, nil
+}
+
+//-----------------------------------------------------------------------------
+
+func (cs *BFTConsensus) addVote(vote *types.Vote, peerID types.P2PID) (added bool, err error) {
+	cs.Logger.Debug("addVote", "voteHeight", vote.Height, "voteType", vote.Type, "valIndex", vote.ValidatorIndex, "csHeight", cs.Height)
+
+	// A precommit for the previous height?
+	// These come in while we wait timeoutCommit
+	if vote.Height+1 == cs.Height {
+		if !(cs.Step == RoundStepNewHeight && vote.Type == types.PrecommitType) {
+			// TODO: give the reason ..
+			// fmt.Errorf("tryAddVote: Wrong height, not a LastCommit straggler commit.")
+			return added, ErrVoteHeightMismatch
+		}
+		added, err = cs.LastCommit.AddVote(vote)
+		if !added {
+			return added, err
+		}
+
+		cs.Logger.Info(fmt.Sprintf("Added to lastPrecommits: %v", cs.LastCommit.StringShort()))
+		cs.eventBus.PublishEventVote(types.EventDataVote{Vote: vote})
+		cs.evsw.FireEvent(types.EventVote, vote)
+
+		// if we can skip timeoutCommit and have all the votes now,
+		if cs.config.SkipTimeoutCommit && cs.LastCommit.HasAll() {
+			// go straight to new round (skip timeout commit)
+			// cs.scheduleTimeout(time.Duration(0), cs.Height, 0, RoundStepNewHeight)
+			cs.enterNewRound(cs.Height, 0)
+		}
+
+		return
+	}
+
+	// Height mismatch is ignored.
+	// Not necessarily a bad peer, but not favourable behaviour.
+	if vote.Height != cs.Height {
+		err = ErrVoteHeightMismatch
+		cs.Logger.Info("Vote ignored and not added", "voteHeight", vote.Height, "csHeight", cs.Height, "peerID", peerID)
+		return
+	}
+
+	height := cs.Height
+	added, err = cs.Votes.AddVote(vote, peerID)
+	if !added {
+		// Either duplicate, or error upon cs.Votes.AddByIndex()
+		return
+	}
+
+	cs.eventBus.PublishEventVote(types.EventDataVote{Vote: vote})
+	cs.evsw.FireEvent(types.EventVote, vote)
+
+	switch vote.Type {
+	case types.PrevoteType:
+		prevotes := cs.Votes.Prevotes(vote.Round)
+		cs.Logger.Info("Added to prevote", "vote", vote, "prevotes", prevotes.StringShort())
+
+		// If +2/3 prevotes for a block or nil for *any* round:
+		if blockID, ok := prevotes.TwoThirdsMajority(); ok {
+
+			// There was a polka!
+			// If we're locked but this is a recent polka, unlock.
+			// If it matches our ProposalBlock, update the ValidBlock
+
+			// Unlock if `cs.LockedRound < vote.Round <= cs.Round`
+			// NOTE: If vote.Round > cs.Round, we'll deal with it when we get to vote.Round
+			if (cs.LockedBlock != nil) &&
+				(cs.LockedRound < vote.Round) &&
+				(vote.Round <= cs.Round) &&
+				!cs.LockedBlock.HashesTo(blockID.Hash) {
+
+				cs.Logger.Info("Unlocking because of POL.", "lockedRound", cs.LockedRound, "POLRound", vote.Round)
+				cs.LockedRound = -1
+				cs.LockedBlock = nil
+				cs.LockedBlockParts = nil
+				cs.eventBus.PublishEventUnlock(cs.RoundStateEvent())
+			}
+
+			// Update Valid* if we can.
+			// NOTE: our proposal block may be nil or not what received a polka..
+			if len(blockID.Hash) != 0 && (cs.ValidRound < vote.Round) && (vote.Round == cs.Round) {
+
+				if cs.ProposalBlock.HashesTo(blockID.Hash) {
+					cs.Logger.Info(
+						"Updating ValidBlock because of POL.", "validRound", cs.ValidRound, "POLRound", vote.Round)
+					cs.ValidRound = vote.Round
+					cs.ValidBlock = cs.ProposalBlock
+					cs.ValidBlockParts = cs.ProposalBlockParts
+				} else {
+					cs.Logger.Info(
+						"Valid block we don't know about. Set ProposalBlock=nil",
+						"proposal", cs.ProposalBlock.Hash(), "blockId", blockID.Hash)
+					// We're getting the wrong block.
+					cs.ProposalBlock = nil
+				}
+				if !cs.ProposalBlockParts.HasHeader(blockID.PartsHeader) {
+					cs.ProposalBlockParts = types.NewPartSetFromHeader(blockID.PartsHeader)
+				}
+				cs.evsw.FireEvent(types.EventValidBlock, &cs.RoundState)
+				cs.eventBus.PublishEventValidBlock(cs.RoundStateEvent())
+			}
+		}
+
+		// If +2/3 prevotes for *anything* for future round:
+		switch {
+		case cs.Round < vote.Round && prevotes.HasTwoThirdsAny():
+			// Round-skip if there is any 2/3+ of votes ahead of us
+			cs.enterNewRound(height, vote.Round)
+		case cs.Round == vote.Round && RoundStepPrevote <= cs.Step: // current round
+			blockID, ok := prevotes.TwoThirdsMajority()
+			if ok && (cs.isProposalComplete() || len(blockID.Hash) == 0) {
+				cs.enterPrecommit(height, vote.Round)
+			} else if prevotes.HasTwoThirdsAny() {
+				cs.enterPrevoteWait(height, vote.Round)
+			}
+		case cs.Proposal != nil && 0 <= cs.Proposal.POLRound && cs.Proposal.POLRound == vote.Round:
+			// If the proposal is now complete, enter prevote of cs.Round.
+			if cs.isProposalComplete() {
+				cs.enterPrevote(height, cs.Round)
+			}
+		}
+
+	case types.PrecommitType:
+		precommits := cs.Votes.Precommits(vote.Round)
+		cs.Logger.Info("Added to precommit", "vote", vote, "precommits", precommits.StringShort())
+
+		blockID, ok := precommits.TwoThirdsMajority()
+		if ok {
+			// Executed as TwoThirdsMajority could be from a higher round
+			cs.enterNewRound(height, vote.Round)
+			cs.enterPrecommit(height, vote.Round)
+			if len(blockID.Hash) != 0 {
+				cs.enterCommit(height, vote.Round)
+				if cs.config.SkipTimeoutCommit && precommits.HasAll() {
+					cs.enterNewRound(cs.Height, 0)
+				}
+			} else {
+				cs.enterPrecommitWait(height, vote.Round)
+			}
+		} else if cs.Round <= vote.Round && precommits.HasTwoThirdsAny() {
+			cs.enterNewRound(height, vote.Round)
+			cs.enterPrecommitWait(height, vote.Round)
+		}
+
+	default:
+		panic(fmt.Sprintf("Unexpected vote type %X", vote.Type)) // go-amino should prevent this.
+	}
+
+	return
+}
+
+func (cs *BFTConsensus) signVote(type_ types.SignedMsgType, hash []byte, header types.PartSetHeader) (*types.Vote, error) {
+	// Flush the WAL. Otherwise, we may not recompute the same vote to sign, and the validator will refuse to sign anything.
+	cs.wal.FlushAndSync()
+
+	addr := cs.validatorAddress()
+	if addr == nil {
+		return nil, ErrNotValidator
+	}
+	valIndex, _ := cs.Validators.GetByAddress(addr)
+
+	vote := &types.Vote{
+		ValidatorAddress: addr,
+		ValidatorIndex:   valIndex,
+		Height:           cs.Height,
+		Round:            cs.Round,
+		Timestamp:        cs.voteTime(),
+		Type:             type_,
+		BlockID:          types.BlockID{Hash: hash, PartsHeader: header},
+	}
+	err := cs.validator.SignVote(cs.state.LeagueID, vote)
+	return vote, err
+}
+
+func (cs *BFTConsensus) voteTime() time.Time {
+	now := ttime.Now()
+	minVoteTime := now
+	// TODO: We should remove next line in case we don't vote for v in case cs.ProposalBlock == nil,
+	// even if cs.LockedBlock != nil. See https://github.com/tendermint/spec.
+	timeIotaMs := time.Duration(cs.state.ConsensusParams.Block.TimeIotaMs) * time.Millisecond
+	if cs.LockedBlock != nil {
+		// See the BFT time spec https://tendermint.com/docs/spec/consensus/bft-time.html
+		minVoteTime = cs.LockedBlock.Time.Add(timeIotaMs)
+	} else if cs.ProposalBlock != nil {
+		minVoteTime = cs.ProposalBlock.Time.Add(timeIotaMs)
+	}
+
+	if now.After(minVoteTime) {
+		return now
+	}
+	return minVoteTime
+}
+
+// sign the vote and publish on internalMsgQueue
+func (cs *BFTConsensus) signAddVote(type_ types.SignedMsgType, hash []byte, header types.PartSetHeader) *types.Vote {
+	// if we don't have a key or we're not in the validator set, do nothing
+	if cs.validatorAddress() == nil {
+		return nil
+	}
+	vote, err := cs.signVote(type_, hash, header)
+	if err == nil {
+		cs.sendInternalMessage(msgInfo{&VoteMessage{vote}, ""})
+		cs.Logger.Info("Signed and pushed vote", "height", cs.Height, "round", cs.Round, "vote", vote, "err", err)
+		if cs.byzantine != nil && !cs.replayMode {
+			cs.signConflictingVote(vote)
+		}
+		return vote
+	}
+	//if !cs.replayMode {
+	cs.Logger.Error("Error signing vote", "height", cs.Height, "round", cs.Round, "vote", vote, "err", err)
+	//}
+	return nil
+}
+
+//---------------------------------------------------------
+
+// CompareHRS compares two height/round/step triples.
+func CompareHRS(h1 int64, r1 int, s1 RoundStepType, h2 int64, r2 int, s2 RoundStepType) int {
+	if h1 < h2 {
+		return -1
+	} else if h1 > h2 {
+		return 1
+	}
+	if r1 < r2 {
+		return -1
+	} else if r1 > r2 {
+		return 1
+	}
+	if s1 < s2 {
+		return -1
+	} else if s1 > s2 {
+		return 1
+	}
+	return 0
+}
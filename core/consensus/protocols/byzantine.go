@@ -0,0 +1,141 @@
+package protocols
+
+import (
+	"fmt"
+
+	cfg "github.com/teragrid/dgrid/core/config"
+	"github.com/teragrid/dgrid/core/types"
+	cmn "github.com/teragrid/dgrid/pkg/common"
+	"github.com/teragrid/dgrid/pkg/crypto/tmhash"
+)
+
+// byzantine makes a BFTConsensus misbehave, to test the detection of
+// byzantine validators on a testnet. The validator of the engine signs what
+// an honest validator would, and its messages go to one half of the peers.
+// The conflicting messages are signed by signer, which holds the same key,
+// and go to the other half. The peers relay what they didn't know yet, so
+// the whole network ends up with the evidence of the misbehavior.
+type byzantine struct {
+	behaviors map[string]bool
+	signer    types.Validator
+}
+
+// BFTByzantine makes the engine misbehave in the given ways, see
+// cfg.ByzantineBehaviors. signer signs the conflicting votes and proposals:
+// it must hold the key of the validator of the engine and sign anything it
+// is given. It may be nil if the engine only withholds its votes.
+func BFTByzantine(behaviors []string, signer types.Validator) BFTOption {
+	return func(cs *BFTConsensus) {
+		b := &byzantine{behaviors: make(map[string]bool), signer: signer}
+		for _, behavior := range behaviors {
+			b.behaviors[behavior] = true
+		}
+		cs.byzantine = b
+		if b.behaviors[cfg.ByzantineConflictingProposals] {
+			cs.decideProposal = cs.byzantineDecideProposal
+		}
+	}
+}
+
+// byzantineHalf splits the peers in two halves, based on the hash of their ID.
+func byzantineHalf(peerID types.P2PID) byte {
+	return tmhash.Sum([]byte(peerID))[0] % 2
+}
+
+// honestPeers selects the peers getting the messages of the validator.
+func honestPeers(peerID types.P2PID) bool {
+	return byzantineHalf(peerID) == 0
+}
+
+// conflictingPeers selects the peers getting the conflicting messages.
+func conflictingPeers(peerID types.P2PID) bool {
+	return byzantineHalf(peerID) == 1
+}
+
+// doubleSigns returns true if a conflicting vote of the given type must be signed.
+func (b *byzantine) doubleSigns(type_ types.SignedMsgType) bool {
+	switch type_ {
+	case types.PrevoteType:
+		return b.behaviors[cfg.ByzantineDoublePrevote]
+	case types.PrecommitType:
+		return b.behaviors[cfg.ByzantineDoublePrecommit]
+	default:
+		return false
+	}
+}
+
+// broadcastOwn sends a message of the validator of the engine to the peers.
+func (cs *BFTConsensus) broadcastOwn(msg ConsensusMessage) {
+	b := cs.byzantine
+	switch msg := msg.(type) {
+	case *VoteMessage:
+		if b.behaviors[cfg.ByzantineWithholdVotes] {
+			return
+		}
+		if b.doubleSigns(msg.Vote.Type) {
+			fireBroadcastTo(cs.evsw, msg, honestPeers)
+			return
+		}
+	case *ProposalMessage, *BlockPartMessage:
+		if b.behaviors[cfg.ByzantineConflictingProposals] {
+			fireBroadcastTo(cs.evsw, msg, honestPeers)
+			return
+		}
+	}
+	fireBroadcast(cs.evsw, msg)
+}
+
+// signConflictingVote signs a vote conflicting with the given vote of the
+// validator and sends it to the other half of the peers: a vote for nil if
+// the vote is for a block, and for the proposal block, or an unknown one,
+// otherwise.
+func (cs *BFTConsensus) signConflictingVote(vote *types.Vote) {
+	if !cs.byzantine.doubleSigns(vote.Type) {
+		return
+	}
+	conflicting := vote.Copy()
+	conflicting.Signature = nil
+	switch {
+	case len(vote.BlockID.Hash) > 0:
+		conflicting.BlockID = types.BlockID{}
+	case cs.ProposalBlock != nil:
+		conflicting.BlockID = types.BlockID{Hash: cs.ProposalBlock.Hash(), PartsHeader: cs.ProposalBlockParts.Header()}
+	default:
+		conflicting.BlockID = types.BlockID{
+			Hash:        cmn.RandBytes(tmhash.Size),
+			PartsHeader: types.PartSetHeader{Total: 1, Hash: cmn.RandBytes(tmhash.Size)},
+		}
+	}
+	if err := cs.byzantine.signer.SignVote(cs.state.LeagueID, conflicting); err != nil {
+		cs.Logger.Error("Error signing conflicting vote", "height", cs.Height, "round", cs.Round, "vote", conflicting, "err", err)
+		return
+	}
+	fireBroadcastTo(cs.evsw, &VoteMessage{conflicting}, conflictingPeers)
+	cs.Logger.Info("Signed conflicting vote", "height", cs.Height, "round", cs.Round, "vote", conflicting)
+}
+
+// byzantineDecideProposal proposes a block to one half of the peers, and
+// another one to the other half.
+func (cs *BFTConsensus) byzantineDecideProposal(height int64, round int) {
+	cs.defaultDecideProposal(height, round)
+
+	block, _ := cs.createProposalBlock()
+	if block == nil { // on error
+		return
+	}
+	// the same block with one more tx
+	txs := append(append(types.Txs{}, block.Data.Txs...), types.Tx(fmt.Sprintf("byzantine/%d/%d", height, round)))
+	conflicting, blockParts := blockWithTxs(block, txs)
+
+	propBlockID := types.BlockID{Hash: conflicting.Hash(), PartsHeader: blockParts.Header()}
+	proposal := types.NewProposal(height, round, cs.ValidRound, propBlockID)
+	if err := cs.byzantine.signer.SignProposal(cs.state.LeagueID, proposal); err != nil {
+		cs.Logger.Error("Error signing conflicting proposal", "height", height, "round", round, "err", err)
+		return
+	}
+	fireBroadcastTo(cs.evsw, &ProposalMessage{proposal}, conflictingPeers)
+	for i := 0; i < blockParts.Total(); i++ {
+		fireBroadcastTo(cs.evsw, &BlockPartMessage{height, round, blockParts.GetPart(i)}, conflictingPeers)
+	}
+	cs.Logger.Info("Signed conflicting proposal", "height", height, "round", round, "proposal", proposal)
+}
@@ -0,0 +1,255 @@
+package protocols
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/teragrid/dgrid/core/blockchain/p2p/conn"
+	cfg "github.com/teragrid/dgrid/core/config"
+	"github.com/teragrid/dgrid/core/types"
+	cmn "github.com/teragrid/dgrid/pkg/common"
+	tevents "github.com/teragrid/dgrid/pkg/events"
+	sm "github.com/teragrid/dgrid/state"
+)
+
+// Channels used by the consensus engines to talk to their peers.
+const (
+	StateChannel       = byte(0x20) // SCP statements and the round states of the peers
+	DataChannel        = byte(0x21) // proposals and block parts
+	VoteChannel        = byte(0x22) // votes
+	VoteSetBitsChannel = byte(0x23) // votes the peers have for a +2/3 majority
+)
+
+// EventBroadcast is fired on the event switch of an engine, with a
+// *Broadcast, for every message which must be sent to the peers.
+const EventBroadcast = "Broadcast"
+
+// ErrUnexpectedMessage is returned when an engine receives a message
+// its protocol doesn't use.
+var ErrUnexpectedMessage = errors.New("Error unexpected consensus message")
+
+// Broadcast is a message to send to all the peers on a channel, or to
+// the peers Peers selects if it is set.
+type Broadcast struct {
+	ChannelID byte
+	Msg       ConsensusMessage
+	Peers     func(peerID types.P2PID) bool
+}
+
+// ConsensusEngine is implemented by the engine of every consensus protocol,
+// so the cell, the reactors and the RPC don't depend on a given protocol.
+type ConsensusEngine interface {
+	cmn.Service
+	// Wait waits for the main routine to return after Stop.
+	Wait()
+
+	// Protocol returns the consensus protocol run by the engine.
+	Protocol() cfg.ConsensusProtocol
+
+	// SetEventBus sets the bus the engine publishes its events on.
+	SetEventBus(b *types.EventBus)
+	// SetValidator sets the local validator signing for the engine.
+	SetValidator(val types.Validator)
+	// SetWAL sets the write-ahead log. It must be called before Start.
+	SetWAL(wal WAL)
+
+	// GetChannels returns the p2p channels used by the engine.
+	GetChannels() []*conn.ChannelDescriptor
+	// ReceiveMessage queues a message received from a peer.
+	ReceiveMessage(msg ConsensusMessage, peerID types.P2PID) error
+	// AddBroadcastListener registers a callback for the messages the
+	// engine wants to send to its peers.
+	AddBroadcastListener(listenerID string, cb func(*Broadcast)) error
+	// RemoveBroadcastListener removes the callbacks of the listener.
+	RemoveBroadcastListener(listenerID string)
+
+	// GetState returns a copy of the chain state.
+	GetState() sm.State
+	// GetLastHeight returns the last height committed.
+	GetLastHeight() int64
+	// GetValidators returns the current validators.
+	GetValidators() (int64, []*types.Validator)
+	// GetRoundStateJSON returns the internal state of the engine as json.
+	GetRoundStateJSON() ([]byte, error)
+	// GetRoundStateSimpleJSON returns a summary of the internal state as json.
+	GetRoundStateSimpleJSON() ([]byte, error)
+	// LoadCommit returns the commit of a height.
+	LoadCommit(height int64) *types.Commit
+
+	// SwitchToState updates the engine to a state synced by other means,
+	// eg. fast sync. It must be called before Start.
+	SwitchToState(state sm.State)
+	// ReplayMessage applies a message read from the WAL to the engine.
+	// Messages of other protocols, written before the league migrated to
+	// the protocol of the engine, are skipped.
+	ReplayMessage(msg WALMessage) error
+
+	// SetHaltHeight makes the engine stop before it starts the given height,
+	// and call onHalt with the state committed up to the previous height.
+	// The engine keeps running but ignores any further input, so that
+	// another engine can take over the league. A zero height cancels it.
+	SetHaltHeight(height int64, onHalt func(state sm.State)) error
+}
+
+// GossipEngine is implemented by the engines whose proposals, block parts
+// and votes are gossiped to each peer according to the round state it
+// announced, see PeerState, rather than relayed once as they are broadcast.
+// A peer which missed a message, or which is catching up a height, gets it
+// from the gossip routines of the reactor.
+type GossipEngine interface {
+	ConsensusEngine
+
+	// GetRoundState returns a shallow copy of the round state.
+	GetRoundState() *RoundState
+	// LoadBlockMeta returns the meta of a committed block.
+	LoadBlockMeta(height int64) *types.BlockMeta
+	// LoadBlockPart returns a part of a committed block.
+	LoadBlockPart(height int64, index int) *types.Part
+	// SetPeerMaj23 records that a peer claims +2/3 votes of a type for
+	// blockID. Claims for another height than the current one are ignored.
+	SetPeerMaj23(height int64, round int, voteType types.SignedMsgType, peerID types.P2PID, blockID types.BlockID) error
+	// VoteBitArray returns the votes of a type the engine has for blockID,
+	// or nil if height isn't the current one.
+	VoteBitArray(height int64, round int, voteType types.SignedMsgType, blockID types.BlockID) *cmn.BitArray
+	// PeerGossipSleepDurations returns how long the gossip routines sleep
+	// when they have nothing to send, and between two queries of the
+	// +2/3 majorities of a peer.
+	PeerGossipSleepDurations() (gossip, queryMaj23 time.Duration)
+	// AddRoundStateListener registers the callbacks of a listener for the
+	// changes of the round state. They are removed with
+	// RemoveBroadcastListener.
+	AddRoundStateListener(listenerID string, listener RoundStateListener) error
+}
+
+// RoundStateListener holds the callbacks for the changes of the round state
+// of a GossipEngine. They are called synchronously, with the engine locked.
+type RoundStateListener struct {
+	// OnNewRoundStep is called on every height/round/step transition.
+	OnNewRoundStep func(rs *RoundState)
+	// OnValidBlock is called when a block gets +2/3 prevotes.
+	OnValidBlock func(rs *RoundState)
+	// OnVote is called for every vote added.
+	OnVote func(vote *types.Vote)
+}
+
+var (
+	_ ConsensusEngine = (*BFTConsensus)(nil)
+	_ ConsensusEngine = (*FBAConsensus)(nil)
+	_ GossipEngine    = (*BFTConsensus)(nil)
+)
+
+// AllChannels returns the descriptors of the channels of every protocol.
+// A league may migrate to another protocol, so its reactor registers them all.
+func AllChannels() []*conn.ChannelDescriptor {
+	return channelDescriptors(StateChannel, DataChannel, VoteChannel, VoteSetBitsChannel)
+}
+
+// channelDescriptors returns the descriptors of the given channels.
+func channelDescriptors(chIDs ...byte) []*conn.ChannelDescriptor {
+	descriptors := make([]*conn.ChannelDescriptor, 0, len(chIDs))
+	for _, chID := range chIDs {
+		desc := &conn.ChannelDescriptor{
+			ID:                  chID,
+			SendQueueCapacity:   100,
+			RecvMessageCapacity: maxMsgSize,
+		}
+		switch chID {
+		case StateChannel:
+			desc.Priority = 5
+			desc.RecvBufferCapacity = 100 * 100
+		case DataChannel:
+			// once we gossip the whole block there's nothing left to send until next height or round
+			desc.Priority = 10
+			desc.SendQueueCapacity = 100
+			desc.RecvBufferCapacity = 50 * 4096
+		case VoteChannel:
+			desc.Priority = 5
+			desc.RecvBufferCapacity = 100 * 100
+		case VoteSetBitsChannel:
+			desc.Priority = 1
+			desc.SendQueueCapacity = 2
+			desc.RecvBufferCapacity = 1024
+		}
+		descriptors = append(descriptors, desc)
+	}
+	return descriptors
+}
+
+// messageChannel returns the channel a message is sent on.
+func messageChannel(msg ConsensusMessage) byte {
+	switch msg.(type) {
+	case *ProposalMessage, *BlockPartMessage, *ProposalPOLMessage:
+		return DataChannel
+	case *VoteMessage:
+		return VoteChannel
+	case *VoteSetBitsMessage:
+		return VoteSetBitsChannel
+	default:
+		return StateChannel
+	}
+}
+
+// fireBroadcast asks the listeners of evsw to send msg to the peers.
+func fireBroadcast(evsw tevents.EventSwitch, msg ConsensusMessage) {
+	evsw.FireEvent(EventBroadcast, &Broadcast{ChannelID: messageChannel(msg), Msg: msg})
+}
+
+// fireBroadcastTo asks the listeners of evsw to send msg to the peers
+// selected by peers.
+func fireBroadcastTo(evsw tevents.EventSwitch, msg ConsensusMessage, peers func(peerID types.P2PID) bool) {
+	evsw.FireEvent(EventBroadcast, &Broadcast{ChannelID: messageChannel(msg), Msg: msg, Peers: peers})
+}
+
+// addBroadcastListener registers cb for the broadcasts fired on evsw.
+func addBroadcastListener(evsw tevents.EventSwitch, listenerID string, cb func(*Broadcast)) error {
+	return evsw.AddListenerForEvent(listenerID, EventBroadcast, func(data tevents.EventData) {
+		cb(data.(*Broadcast))
+	})
+}
+
+// heightHalter stops an engine before a given height, so that another
+// engine can take over the league from there. It is protected by the
+// mutex of the engine.
+type heightHalter struct {
+	haltHeight int64 // 0 if the engine never halts
+	onHalt     func(state sm.State)
+	halted     bool
+}
+
+// setHaltHeight sets the halt height of an engine at the given height.
+// The current height can only be halted if the engine isn't running yet.
+func (h *heightHalter) setHaltHeight(height int64, onHalt func(state sm.State), current int64, running bool) error {
+	if h.halted {
+		return fmt.Errorf("Engine already halted at height %d", h.haltHeight)
+	}
+	if height != 0 && (height < current || (height == current && running)) {
+		return fmt.Errorf("Cannot halt at height %d, the engine already started height %d", height, current)
+	}
+	h.haltHeight, h.onHalt = height, onHalt
+	if height == 0 {
+		h.onHalt = nil
+	}
+	return nil
+}
+
+// halts returns true if the engine halts before the height following state.
+func (h *heightHalter) halts(state sm.State) bool {
+	return h.halted || (h.haltHeight != 0 && state.LastBlockHeight+1 >= h.haltHeight)
+}
+
+// maybeHalt halts the engine if the height following state is the halt
+// height, and returns whether the engine is halted.
+func (h *heightHalter) maybeHalt(state sm.State) bool {
+	if h.halted {
+		return true
+	}
+	if !h.halts(state) {
+		return false
+	}
+	h.halted = true
+	if h.onHalt != nil {
+		go h.onHalt(state.Copy())
+	}
+	return true
+}
@@ -0,0 +1,62 @@
+package protocols
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	sm "github.com/teragrid/dgrid/state"
+)
+
+func TestMessageChannel(t *testing.T) {
+	assert.Equal(t, DataChannel, messageChannel(&ProposalMessage{}))
+	assert.Equal(t, DataChannel, messageChannel(&BlockPartMessage{}))
+	assert.Equal(t, VoteChannel, messageChannel(&VoteMessage{}))
+	assert.Equal(t, StateChannel, messageChannel(&SCPStatementMessage{}))
+	assert.Equal(t, StateChannel, messageChannel(&NewRoundStepMessage{}))
+	assert.Equal(t, StateChannel, messageChannel(&HasVoteMessage{}))
+	assert.Equal(t, DataChannel, messageChannel(&ProposalPOLMessage{}))
+	assert.Equal(t, VoteSetBitsChannel, messageChannel(&VoteSetBitsMessage{}))
+}
+
+func TestEngineChannels(t *testing.T) {
+	channelIDs := func(engine ConsensusEngine) []byte {
+		var ids []byte
+		for _, desc := range engine.GetChannels() {
+			ids = append(ids, desc.ID)
+		}
+		return ids
+	}
+	assert.Equal(t, []byte{StateChannel, DataChannel, VoteChannel, VoteSetBitsChannel}, channelIDs(&BFTConsensus{}))
+	assert.Equal(t, []byte{StateChannel, DataChannel, VoteChannel}, channelIDs(&FBAConsensus{}))
+}
+
+func TestHeightHalter(t *testing.T) {
+	var h heightHalter
+	halted := make(chan sm.State, 1)
+	onHalt := func(state sm.State) { halted <- state }
+
+	// a running engine can't halt at the height it started
+	assert.Error(t, h.setHaltHeight(10, onHalt, 10, true))
+	assert.Error(t, h.setHaltHeight(9, onHalt, 10, false))
+	require.NoError(t, h.setHaltHeight(12, onHalt, 10, true))
+
+	state := sm.State{LastBlockHeight: 10}
+	assert.False(t, h.maybeHalt(state))
+	state.LastBlockHeight = 11
+	assert.True(t, h.maybeHalt(state))
+	assert.EqualValues(t, 11, (<-halted).LastBlockHeight)
+
+	// once halted, the engine stays halted
+	assert.True(t, h.maybeHalt(state))
+	assert.Error(t, h.setHaltHeight(20, onHalt, 12, true))
+	assert.Empty(t, halted)
+}
+
+func TestMessageProtocols(t *testing.T) {
+	assert.True(t, isBFTMessage(&VoteMessage{}))
+	assert.False(t, isBFTMessage(&SCPStatementMessage{}))
+	assert.True(t, isFBAMessage(&SCPStatementMessage{}))
+	assert.True(t, isFBAMessage(&BlockPartMessage{}))
+}
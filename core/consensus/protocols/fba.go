@@ -0,0 +1,2105 @@
+package protocols
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/teragrid/dgrid/core/blockchain/p2p/conn"
+	cfg "github.com/teragrid/dgrid/core/config"
+	"github.com/teragrid/dgrid/core/types"
+	ttime "github.com/teragrid/dgrid/core/types/time"
+	cmn "github.com/teragrid/dgrid/pkg/common"
+	"github.com/teragrid/dgrid/pkg/crypto/tmhash"
+	tevents "github.com/teragrid/dgrid/pkg/events"
+	"github.com/teragrid/dgrid/pkg/fail"
+	"github.com/teragrid/dgrid/pkg/log"
+	sm "github.com/teragrid/dgrid/state"
+)
+
+//-----------------------------------------------------------------------------
+// Errors
+
+var (
+	ErrStatementHeightMismatch   = errors.New("Error statement height mismatch")
+	ErrStatementUnknownValidator = errors.New("Error statement from unknown validator")
+	ErrUnexpectedProposal        = errors.New("Error unexpected proposal")
+)
+
+// EventSCPStatement is fired on the event switch of the FBAConsensus
+// every time the local validator emits a new statement.
+const EventSCPStatement = "SCPStatement"
+
+// maxBallotCounter stands for the infinite counter of the ballots which
+// are implicitly voted or accepted by CONFIRM and EXTERNALIZE statements.
+const maxBallotCounter = math.MaxInt32
+
+// statementFilter selects the statements voting or accepting something.
+type statementFilter func(stmt *types.SCPStatement) bool
+
+//-----------------------------------------------------------------------------
+
+// FBAConsensus handles execution of the Federated Byzantine Agreement
+// consensus algorithm for a single league, following the Stellar Consensus
+// Protocol (SCP).
+//
+// Each height is a slot, decided in two stages. The nomination protocol
+// selects candidate blocks: round leaders propose blocks, and validators
+// echo the leaders' votes until some blocks are confirmed nominated. The
+// ballot protocol then agrees on one of the candidates through the
+// PREPARE, CONFIRM and EXTERNALIZE statements. All decisions rely on
+// federated voting over the quorum slices of the validators: a statement
+// is accepted once a quorum voted for it or a v-blocking set accepted it,
+// and confirmed once a quorum accepted it.
+//
+// Once a value is externalized, every validator precommits it, and so does
+// a validator learning the value from the precommits of a quorum or of a
+// v-blocking set. The block is only committed once +2/3 of the voting power
+// precommitted it, so that it carries a regular commit and can be verified
+// like any block of a BFT league.
+type FBAConsensus struct {
+	cmn.BaseService
+
+	// config details
+	config    *cfg.FBAConsensusConfig
+	validator types.Validator // for signing statements, proposals and precommits
+
+	// store blocks and commits
+	blockStore sm.BlockStore
+
+	// create and execute blocks
+	blockExec *sm.BlockExecutor
+
+	// notify us if txs are available
+	txNotifier txNotifier
+
+	// add evidence to the pool
+	// when it's detected
+	evpool evidencePool
+
+	// configured quorum slices of the local validator, which override
+	// those of quorumSets if not nil
+	quorumSet *types.QuorumSet
+	// quorum slices of the validators declared in the genesis file, keyed
+	// by string(address)
+	quorumSets map[string]*types.QuorumSet
+
+	// internal state
+	mtx sync.RWMutex
+	SlotState
+	state sm.State // State until height-1.
+
+	ballotTimerCounter int             // ballot counter the ballot timer was armed for
+	proposal           *types.Proposal // our own proposal for this height
+	txsAvailable       bool
+	waitingForTxs      bool
+
+	// state changes may be triggered by: msgs from peers,
+	// msgs from ourself, or by timeouts
+	peerMsgQueue     chan msgInfo
+	internalMsgQueue chan msgInfo
+	nominationTicker TimeoutTicker
+	ballotTicker     TimeoutTicker
+
+	// we use eventBus to trigger msg broadcasts in the reactor,
+	// and to notify external subscribers, eg. through a websocket
+	eventBus *types.EventBus
+
+	// a Write-Ahead Log ensures we can recover from any kind of crash
+	wal          WAL
+	replayMode   bool // so we don't log signing errors during replay
+	doWALCatchup bool // determines if we even try to do the catchup
+
+	// stops the engine when the league migrates to another engine
+	halter heightHalter
+
+	metrics *Metrics
+
+	// closed when we finish shutting down
+	done chan struct{}
+
+	// synchronous pubsub between the engine and its reactor.
+	// state only emits EventNewRoundStep, EventVote and EventSCPStatement
+	evsw tevents.EventSwitch
+}
+
+// FBAOption sets an optional parameter on the FBAConsensus.
+type FBAOption func(*FBAConsensus)
+
+// FBAQuorumSet sets the quorum slices of the local validator.
+func FBAQuorumSet(quorumSet *types.QuorumSet) FBAOption {
+	return func(cs *FBAConsensus) {
+		cs.quorumSet = quorumSet
+		cs.QuorumSet = cs.localQuorumSet(cs.Validators)
+	}
+}
+
+// FBAGenesisQuorumSets sets the quorum slices the validators declared in the
+// genesis file, keyed by string(address). They are the slices of the local
+// validator unless FBAQuorumSet is set, and those of the validators whose
+// statements we didn't receive.
+func FBAGenesisQuorumSets(quorumSets map[string]*types.QuorumSet) FBAOption {
+	return func(cs *FBAConsensus) {
+		cs.quorumSets = quorumSets
+		cs.QuorumSet = cs.localQuorumSet(cs.Validators)
+	}
+}
+
+// FBAMetrics sets the metrics.
+func FBAMetrics(metrics *Metrics) FBAOption {
+	return func(cs *FBAConsensus) { cs.metrics = metrics }
+}
+
+// NewFBAConsensus returns a new FBAConsensus.
+func NewFBAConsensus(
+	config *cfg.FBAConsensusConfig,
+	state sm.State,
+	blockExec *sm.BlockExecutor,
+	blockStore sm.BlockStore,
+	txNotifier txNotifier,
+	evpool evidencePool,
+	options ...FBAOption,
+) *FBAConsensus {
+	cs := &FBAConsensus{
+		config:           config,
+		blockExec:        blockExec,
+		blockStore:       blockStore,
+		txNotifier:       txNotifier,
+		peerMsgQueue:     make(chan msgInfo, msgQueueSize),
+		internalMsgQueue: make(chan msgInfo, msgQueueSize),
+		nominationTicker: NewTimeoutTicker(),
+		ballotTicker:     NewTimeoutTicker(),
+		done:             make(chan struct{}),
+		wal:              nilWAL{},
+		doWALCatchup:     true,
+		evpool:           evpool,
+		evsw:             tevents.NewEventSwitch(),
+		metrics:          NopMetrics(),
+	}
+
+	cs.updateToState(state)
+
+	// Don't call scheduleSlot0 yet.
+	// We do that upon Start().
+	cs.reconstructLastCommit(state)
+	cs.BaseService = *cmn.NewBaseService(nil, "FBAConsensus", cs)
+	for _, option := range options {
+		option(cs)
+	}
+	return cs
+}
+
+//----------------------------------------
+// Public interface
+
+// SetLogger implements Service.
+func (cs *FBAConsensus) SetLogger(l log.Logger) {
+	cs.BaseService.Logger = l
+	cs.nominationTicker.SetLogger(l)
+	cs.ballotTicker.SetLogger(l)
+}
+
+// SetEventBus sets event bus.
+func (cs *FBAConsensus) SetEventBus(b *types.EventBus) {
+	cs.eventBus = b
+	cs.blockExec.SetEventBus(b)
+}
+
+// SetWAL sets the write-ahead log used by the engine. It must be called
+// before Start.
+func (cs *FBAConsensus) SetWAL(wal WAL) {
+	cs.wal = wal
+}
+
+// String returns a string.
+func (cs *FBAConsensus) String() string {
+	// better not to access shared variables
+	return fmt.Sprintf("FBAConsensus")
+}
+
+// GetState returns a copy of the chain state.
+func (cs *FBAConsensus) GetState() sm.State {
+	cs.mtx.RLock()
+	defer cs.mtx.RUnlock()
+	return cs.state.Copy()
+}
+
+// GetLastHeight returns the last height committed.
+// If there were no blocks, returns 0.
+func (cs *FBAConsensus) GetLastHeight() int64 {
+	cs.mtx.RLock()
+	defer cs.mtx.RUnlock()
+	return cs.SlotState.Height - 1
+}
+
+// GetSlotState returns a shallow copy of the internal consensus state.
+func (cs *FBAConsensus) GetSlotState() *SlotState {
+	cs.mtx.RLock()
+	ss := cs.SlotState // copy
+	cs.mtx.RUnlock()
+	return &ss
+}
+
+// GetRoundStateJSON returns a json of SlotState, marshalled using go-amino.
+func (cs *FBAConsensus) GetRoundStateJSON() ([]byte, error) {
+	cs.mtx.RLock()
+	defer cs.mtx.RUnlock()
+	return cdc.MarshalJSON(cs.SlotState)
+}
+
+// GetRoundStateSimpleJSON returns a json of SlotStateSimple, marshalled using go-amino.
+func (cs *FBAConsensus) GetRoundStateSimpleJSON() ([]byte, error) {
+	cs.mtx.RLock()
+	defer cs.mtx.RUnlock()
+	return cdc.MarshalJSON(cs.SlotState.SlotStateSimple())
+}
+
+// GetValidators returns a copy of the current validators.
+func (cs *FBAConsensus) GetValidators() (int64, []*types.Validator) {
+	cs.mtx.RLock()
+	defer cs.mtx.RUnlock()
+	return cs.state.LastBlockHeight, cs.state.Validators.Copy().Validators
+}
+
+// SetValidator sets the local validator.
+// It must implement types.StatementSigner to take part in the consensus,
+// otherwise the engine only follows the league.
+func (cs *FBAConsensus) SetValidator(val types.Validator) {
+	cs.mtx.Lock()
+	defer cs.mtx.Unlock()
+	cs.validator = val
+	cs.QuorumSet = cs.localQuorumSet(cs.Validators)
+}
+
+// LoadCommit loads the commit for a given height.
+func (cs *FBAConsensus) LoadCommit(height int64) *types.Commit {
+	cs.mtx.RLock()
+	defer cs.mtx.RUnlock()
+	if height == cs.blockStore.Height() {
+		return cs.blockStore.LoadSeenCommit(height)
+	}
+	return cs.blockStore.LoadBlockCommit(height)
+}
+
+// OnStart implements cmn.Service.
+// It starts the timeout and receive routines.
+func (cs *FBAConsensus) OnStart() error {
+	if err := cs.evsw.Start(); err != nil {
+		return err
+	}
+
+	if err := cs.wal.Start(); err != nil {
+		return err
+	}
+
+	if err := cs.nominationTicker.Start(); err != nil {
+		return err
+	}
+	if err := cs.ballotTicker.Start(); err != nil {
+		return err
+	}
+
+	// resume the slot in flight before a crash
+	if _, ok := cs.wal.(nilWAL); !ok && cs.doWALCatchup {
+		if err := catchupReplay(cs.wal, cs.Height, cs.ReplayMessage); err != nil {
+			cs.Logger.Error("Error on catchup replay. Proceeding to start the engine anyway", "err", err)
+		}
+	}
+
+	// now start the receiveRoutine
+	go cs.receiveRoutine()
+
+	// schedule the first slot, unless another engine takes over the league!
+	// use GetSlotState so we don't race the receiveRoutine for access
+	cs.mtx.Lock()
+	halted := cs.halter.maybeHalt(cs.state)
+	cs.mtx.Unlock()
+	if !halted {
+		cs.scheduleSlot0(cs.GetSlotState())
+	}
+
+	return nil
+}
+
+// OnStop implements cmn.Service.
+func (cs *FBAConsensus) OnStop() {
+	cs.evsw.Stop()
+	cs.nominationTicker.Stop()
+	cs.ballotTicker.Stop()
+	// WAL is stopped in receiveRoutine.
+}
+
+// Wait waits for the the main routine to return.
+func (cs *FBAConsensus) Wait() {
+	<-cs.done
+}
+
+// AddStatement inputs a nomination or ballot statement.
+func (cs *FBAConsensus) AddStatement(stmt *types.SCPStatement, peerID types.P2PID) error {
+	if peerID == "" {
+		cs.internalMsgQueue <- msgInfo{&SCPStatementMessage{stmt}, ""}
+	} else {
+		cs.peerMsgQueue <- msgInfo{&SCPStatementMessage{stmt}, peerID}
+	}
+	return nil
+}
+
+// AddVote inputs a precommit certifying an externalized value.
+func (cs *FBAConsensus) AddVote(vote *types.Vote, peerID types.P2PID) (added bool, err error) {
+	if peerID == "" {
+		cs.internalMsgQueue <- msgInfo{&VoteMessage{vote}, ""}
+	} else {
+		cs.peerMsgQueue <- msgInfo{&VoteMessage{vote}, peerID}
+	}
+	return false, nil
+}
+
+// SetProposal inputs a proposal of a round leader.
+func (cs *FBAConsensus) SetProposal(proposal *types.Proposal, peerID types.P2PID) error {
+	if peerID == "" {
+		cs.internalMsgQueue <- msgInfo{&ProposalMessage{proposal}, ""}
+	} else {
+		cs.peerMsgQueue <- msgInfo{&ProposalMessage{proposal}, peerID}
+	}
+	return nil
+}
+
+// AddProposalBlockPart inputs a part of a proposed block.
+func (cs *FBAConsensus) AddProposalBlockPart(height int64, round int, part *types.Part, peerID types.P2PID) error {
+	if peerID == "" {
+		cs.internalMsgQueue <- msgInfo{&BlockPartMessage{height, round, part}, ""}
+	} else {
+		cs.peerMsgQueue <- msgInfo{&BlockPartMessage{height, round, part}, peerID}
+	}
+	return nil
+}
+
+// Protocol implements ConsensusEngine.
+func (cs *FBAConsensus) Protocol() cfg.ConsensusProtocol {
+	return cfg.FBAConsensusProtocol
+}
+
+// GetChannels implements ConsensusEngine.
+func (cs *FBAConsensus) GetChannels() []*conn.ChannelDescriptor {
+	return channelDescriptors(StateChannel, DataChannel, VoteChannel)
+}
+
+// ReceiveMessage implements ConsensusEngine.
+func (cs *FBAConsensus) ReceiveMessage(msg ConsensusMessage, peerID types.P2PID) error {
+	if !isFBAMessage(msg) {
+		return ErrUnexpectedMessage
+	}
+	cs.peerMsgQueue <- msgInfo{msg, peerID}
+	return nil
+}
+
+// isFBAMessage returns true if msg is used by the FBA protocol.
+func isFBAMessage(msg ConsensusMessage) bool {
+	switch msg.(type) {
+	case *SCPStatementMessage, *ProposalMessage, *BlockPartMessage, *VoteMessage:
+		return true
+	default:
+		return false
+	}
+}
+
+// AddBroadcastListener implements ConsensusEngine.
+func (cs *FBAConsensus) AddBroadcastListener(listenerID string, cb func(*Broadcast)) error {
+	return addBroadcastListener(cs.evsw, listenerID, cb)
+}
+
+// RemoveBroadcastListener implements ConsensusEngine.
+func (cs *FBAConsensus) RemoveBroadcastListener(listenerID string) {
+	cs.evsw.RemoveListener(listenerID)
+}
+
+// SwitchToState implements ConsensusEngine.
+func (cs *FBAConsensus) SwitchToState(state sm.State) {
+	cs.mtx.Lock()
+	defer cs.mtx.Unlock()
+	cs.reconstructLastCommit(state)
+	cs.updateToState(state)
+}
+
+// ReplayMessage implements ConsensusEngine.
+// The message is applied synchronously, as if the receive routine got it.
+func (cs *FBAConsensus) ReplayMessage(msg WALMessage) error {
+	cs.replayMode = true
+	defer func() { cs.replayMode = false }()
+
+	switch m := msg.(type) {
+	case types.EventDataRoundState, EndHeightMessage:
+		// markers, nothing to apply
+	case msgInfo:
+		if isFBAMessage(m.Msg) {
+			cs.handleMsg(m)
+		}
+	case timeoutInfo:
+		cs.handleTimeout(m, *cs.GetSlotState())
+	default:
+		return fmt.Errorf("Unknown WAL message type %v", reflect.TypeOf(msg))
+	}
+	return nil
+}
+
+// SetHaltHeight implements ConsensusEngine.
+func (cs *FBAConsensus) SetHaltHeight(height int64, onHalt func(state sm.State)) error {
+	cs.mtx.Lock()
+	defer cs.mtx.Unlock()
+	return cs.halter.setHaltHeight(height, onHalt, cs.Height, cs.IsRunning())
+}
+
+//------------------------------------------------------------
+// internal functions for managing the state
+
+// start nominating for the slot at cs.StartTime.
+func (cs *FBAConsensus) scheduleSlot0(ss *SlotState) {
+	sleepDuration := ss.StartTime.Sub(ttime.Now())
+	cs.nominationTicker.ScheduleTimeout(timeoutInfo{sleepDuration, ss.Height, 0, RoundStepNewHeight})
+}
+
+// send a msg into the receiveRoutine regarding our own proposal, block part, or vote
+func (cs *FBAConsensus) sendInternalMessage(mi msgInfo) {
+	select {
+	case cs.internalMsgQueue <- mi:
+	default:
+		cs.Logger.Info("Internal msg queue is full. Using a go-routine")
+		go func() { cs.internalMsgQueue <- mi }()
+	}
+}
+
+// Reconstruct LastCommit from SeenCommit, which we saved along with the block,
+// (which happens even before saving the state)
+func (cs *FBAConsensus) reconstructLastCommit(state sm.State) {
+	if state.LastBlockHeight == 0 {
+		return
+	}
+	seenCommit := cs.blockStore.LoadSeenCommit(state.LastBlockHeight)
+	lastPrecommits := types.NewVoteSet(state.LeagueID, state.LastBlockHeight, seenCommit.Round(), types.PrecommitType, state.LastValidators)
+	for _, precommit := range seenCommit.Precommits {
+		if precommit == nil {
+			continue
+		}
+		added, err := lastPrecommits.AddVote(seenCommit.ToVote(precommit))
+		if !added || err != nil {
+			cmn.PanicCrisis(fmt.Sprintf("Failed to reconstruct LastCommit: %v", err))
+		}
+	}
+	if !lastPrecommits.HasTwoThirdsMajority() {
+		cmn.PanicSanity("Failed to reconstruct LastCommit: Does not have +2/3 maj")
+	}
+	cs.LastCommit = lastPrecommits
+}
+
+// localQuorumSet returns the quorum slices of the local validator: the
+// configured ones, or else those declared in the genesis file. A node which
+// isn't a genesis validator, eg. one only following the league, relies on
+// +2/3 of the validators.
+func (cs *FBAConsensus) localQuorumSet(validators *types.ValidatorSet) *types.QuorumSet {
+	if cs.quorumSet != nil {
+		return cs.quorumSet
+	}
+	if cs.validator != nil {
+		addr := cs.validator.GetPubKey().Address()
+		if qs := cs.quorumSets[string(addr)]; qs != nil {
+			return qs
+		}
+	}
+	return types.NewMajorityQuorumSet(validators)
+}
+
+// nodeQuorumSet returns the quorum slices of a node, keyed by
+// string(address): those of its statement, or else those it declared in
+// the genesis file. It returns nil if they are unknown.
+func (cs *FBAConsensus) nodeQuorumSet(addr string, statements map[string]*types.SCPStatement) *types.QuorumSet {
+	if stmt := statements[addr]; stmt != nil {
+		return stmt.QuorumSet
+	}
+	return cs.quorumSets[addr]
+}
+
+// Updates FBAConsensus and increments height to match that of state.
+// The phase becomes SlotPhaseNewHeight.
+func (cs *FBAConsensus) updateToState(state sm.State) {
+	if cs.Phase >= SlotPhaseExternalize && 0 < cs.Height && cs.Height != state.LastBlockHeight {
+		cmn.PanicSanity(fmt.Sprintf("updateToState() expected state height of %v but found %v",
+			cs.Height, state.LastBlockHeight))
+	}
+
+	// If state isn't further out than cs.state, just ignore.
+	if !cs.state.IsEmpty() && (state.LastBlockHeight <= cs.state.LastBlockHeight) {
+		cs.Logger.Info("Ignoring updateToState()", "newHeight", state.LastBlockHeight+1, "oldHeight", cs.state.LastBlockHeight+1)
+		cs.newStep()
+		return
+	}
+
+	validators := state.Validators
+	lastPrecommits := (*types.VoteSet)(nil)
+	if cs.Phase >= SlotPhaseExternalize && cs.Precommits != nil {
+		if !cs.Precommits.HasTwoThirdsMajority() {
+			cmn.PanicSanity("updateToState(state) called but last Precommit round didn't have +2/3")
+		}
+		lastPrecommits = cs.Precommits
+	}
+
+	// Next desired block height
+	height := state.LastBlockHeight + 1
+
+	cs.Height = height
+	cs.Phase = SlotPhaseNewHeight
+	switch {
+	case cs.CommitTime.IsZero():
+		// "Now" makes it easier to sync up dev nodes.
+		cs.StartTime = cs.config.Commit(ttime.Now())
+	case cs.config.SkipTimeoutCommit && lastPrecommits != nil && lastPrecommits.HasAll():
+		// we have all the precommits, no need to wait for stragglers
+		cs.StartTime = cs.CommitTime
+	default:
+		cs.StartTime = cs.config.Commit(cs.CommitTime)
+	}
+
+	cs.Validators = validators
+	cs.QuorumSet = cs.localQuorumSet(validators)
+	cs.NominationRound = 0
+	cs.RoundLeaders = nil
+	cs.Votes = nil
+	cs.Accepted = nil
+	cs.Candidates = nil
+	cs.Ballot = nil
+	cs.Prepared = nil
+	cs.PreparedPrime = nil
+	cs.HighBallot = nil
+	cs.CommitBallot = nil
+	cs.ProposalBlocks = make(map[string]*types.Block)
+	cs.ProposalBlockParts = make(map[string]*types.PartSet)
+	cs.Proposers = make(map[string]string)
+	cs.LatestNominations = make(map[string]*types.SCPStatement)
+	cs.LatestBallots = make(map[string]*types.SCPStatement)
+	cs.Precommits = types.NewVoteSet(state.LeagueID, height, 0, types.PrecommitType, validators)
+	cs.LastCommit = lastPrecommits
+	cs.LastValidators = state.LastValidators
+
+	cs.ballotTimerCounter = 0
+	cs.proposal = nil
+	cs.txsAvailable = false
+	cs.waitingForTxs = false
+
+	cs.state = state
+
+	// Finally, broadcast SlotState
+	cs.newStep()
+}
+
+func (cs *FBAConsensus) newStep() {
+	ss := cs.SlotStateEvent()
+	cs.wal.Write(ss)
+	// newStep is called by updateToState in NewFBAConsensus before the eventBus is set!
+	if cs.eventBus != nil {
+		cs.eventBus.PublishEventNewRoundStep(ss)
+		cs.evsw.FireEvent(types.EventNewRoundStep, &cs.SlotState)
+	}
+}
+
+//-----------------------------------------
+// the main go routines
+
+// receiveRoutine handles messages which may cause state transitions.
+// It keeps the SlotState and is the only thing that updates it.
+func (cs *FBAConsensus) receiveRoutine() {
+	onExit := func(cs *FBAConsensus) {
+		cs.wal.Stop()
+		cs.wal.Wait()
+
+		close(cs.done)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			cs.Logger.Error("CONSENSUS FAILURE!!!", "err", r, "stack", string(debug.Stack()))
+			// stop gracefully, see BFTConsensus.receiveRoutine
+			onExit(cs)
+		}
+	}()
+
+	for {
+		ss := cs.SlotState
+		var mi msgInfo
+
+		select {
+		case <-cs.txNotifier.TxsAvailable():
+			cs.handleTxsAvailable()
+		case mi = <-cs.peerMsgQueue:
+			cs.wal.Write(mi)
+			cs.handleMsg(mi)
+		case mi = <-cs.internalMsgQueue:
+			cs.wal.WriteSync(mi) // NOTE: fsync
+			cs.handleMsg(mi)
+		case ti := <-cs.nominationTicker.Chan():
+			cs.wal.Write(ti)
+			cs.handleTimeout(ti, ss)
+		case ti := <-cs.ballotTicker.Chan():
+			cs.wal.Write(ti)
+			cs.handleTimeout(ti, ss)
+		case <-cs.Quit():
+			onExit(cs)
+			return
+		}
+	}
+}
+
+func (cs *FBAConsensus) handleMsg(mi msgInfo) {
+	cs.mtx.Lock()
+	defer cs.mtx.Unlock()
+	if cs.halter.halted {
+		return
+	}
+
+	var (
+		added bool
+		err   error
+	)
+	msg, peerID := mi.Msg, mi.PeerID
+	switch msg := msg.(type) {
+	case *SCPStatementMessage:
+		err = cs.addStatement(msg.Statement, peerID)
+		added = err == nil && cs.isLatestStatement(msg.Statement)
+	case *ProposalMessage:
+		_, known := cs.ProposalBlockParts[msg.Proposal.BlockID.Key()]
+		err = cs.setProposal(msg.Proposal)
+		_, added = cs.ProposalBlockParts[msg.Proposal.BlockID.Key()]
+		added = added && !known
+	case *BlockPartMessage:
+		added, err = cs.addProposalBlockPart(msg, peerID)
+	case *VoteMessage:
+		added, err = cs.tryAddVote(msg.Vote, peerID)
+	default:
+		cs.Logger.Error("Unknown msg type", "type", reflect.TypeOf(msg))
+		return
+	}
+
+	// relay what we didn't know yet. Our own proposal is known
+	// before it goes through the queue, so always send it.
+	if (added || (peerID == "" && err == nil)) && !cs.replayMode {
+		fireBroadcast(cs.evsw, msg)
+	}
+
+	if err != nil {
+		cs.Logger.Error("Error with msg", "height", cs.Height, "phase", cs.Phase, "peer", peerID, "err", err, "msg", msg)
+	}
+}
+
+// isLatestStatement returns true if stmt is the latest statement
+// recorded for its validator.
+func (cs *FBAConsensus) isLatestStatement(stmt *types.SCPStatement) bool {
+	key := string(stmt.ValidatorAddress)
+	if stmt.Type == types.SCPNominateType {
+		return cs.LatestNominations[key] == stmt
+	}
+	return cs.LatestBallots[key] == stmt
+}
+
+func (cs *FBAConsensus) handleTimeout(ti timeoutInfo, ss SlotState) {
+	cs.Logger.Debug("Received tock", "timeout", ti.Duration, "height", ti.Height, "round", ti.Round, "step", ti.Step)
+
+	// timeouts must be for the current height
+	if ti.Height != ss.Height {
+		cs.Logger.Debug("Ignoring tock because we're ahead", "height", ss.Height, "phase", ss.Phase)
+		return
+	}
+
+	cs.mtx.Lock()
+	defer cs.mtx.Unlock()
+	if cs.halter.halted {
+		return
+	}
+
+	switch ti.Step {
+	case RoundStepNewHeight:
+		cs.startNomination(ti.Height)
+	case RoundStepNewRound:
+		// CreateEmptyBlocksInterval elapsed without txs
+		cs.nominate(ti.Height, 1)
+	case RoundStepPropose:
+		cs.nominate(ti.Height, ti.Round+1)
+	case RoundStepPrecommitWait:
+		cs.abandonBallot(ti.Height, ti.Round)
+	default:
+		panic(fmt.Sprintf("Invalid timeout step: %v", ti.Step))
+	}
+}
+
+func (cs *FBAConsensus) handleTxsAvailable() {
+	cs.mtx.Lock()
+	defer cs.mtx.Unlock()
+	if cs.halter.halted {
+		return
+	}
+	cs.txsAvailable = true
+	if cs.waitingForTxs {
+		cs.nominate(cs.Height, 1)
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Nomination protocol
+
+// Enter: `timeoutNewHeight` by startTime (commitTime+timeoutCommit)
+func (cs *FBAConsensus) startNomination(height int64) {
+	if cs.Height != height || cs.Phase != SlotPhaseNewHeight {
+		return
+	}
+
+	// Wait for txs to be available in the storage before we nominate, unless
+	// the last block changed the app hash and we need a "proof" block.
+	if cs.config.WaitForTxs() && !cs.txsAvailable && !cs.needProofBlock(height) {
+		cs.waitingForTxs = true
+		if cs.config.CreateEmptyBlocksInterval > 0 {
+			cs.nominationTicker.ScheduleTimeout(timeoutInfo{cs.config.CreateEmptyBlocksInterval, height, 0, RoundStepNewRound})
+		}
+		return
+	}
+	cs.nominate(height, 1)
+}
+
+// needProofBlock returns true on the first height (so the genesis app hash is signed right away)
+// and where the last block (height-1) caused the app hash to change
+func (cs *FBAConsensus) needProofBlock(height int64) bool {
+	if height == 1 {
+		return true
+	}
+
+	lastBlockMeta := cs.blockStore.LoadBlockMeta(height - 1)
+	return !bytes.Equal(cs.state.AppHash, lastBlockMeta.Header.AppHash)
+}
+
+// Enter: nomination round `round-1` timed out, or the slot started.
+// Nomination stops once some candidates are confirmed.
+func (cs *FBAConsensus) nominate(height int64, round int) {
+	logger := cs.Logger.With("height", height, "round", round)
+
+	if cs.Height != height || round <= cs.NominationRound || len(cs.Candidates) > 0 ||
+		cs.Phase >= SlotPhaseExternalize {
+		logger.Debug(fmt.Sprintf("nominate(%v/%v): Invalid args. Current: %v/%v/%v", height, round, cs.Height, cs.NominationRound, cs.Phase))
+		return
+	}
+	logger.Info(fmt.Sprintf("nominate(%v/%v). Current: %v/%v/%v", height, round, cs.Height, cs.NominationRound, cs.Phase))
+
+	cs.NominationRound = round
+	cs.waitingForTxs = false
+	if cs.Phase < SlotPhaseNominate {
+		cs.Phase = SlotPhaseNominate
+	}
+	cs.updateRoundLeaders(round)
+	defer cs.newStep()
+
+	// If no candidate is confirmed in time, move on to the next round with one more leader
+	cs.nominationTicker.ScheduleTimeout(timeoutInfo{cs.config.Propose(round), height, round, RoundStepPropose})
+
+	updated := false
+	if addr := cs.validatorAddress(); addr != nil && cs.isRoundLeader(addr) && cs.proposal == nil {
+		logger.Info("nominate: We are a round leader")
+		updated = cs.decideProposal(height)
+	}
+	if cs.adoptLeaderVotes() {
+		updated = true
+	}
+	if updated {
+		cs.emitNomination()
+		cs.advanceSlot()
+	}
+}
+
+// updateRoundLeaders adds the member of our quorum slices with the highest
+// priority for the round to the leaders. Leaders accumulate over rounds.
+func (cs *FBAConsensus) updateRoundLeaders(round int) {
+	var (
+		leader      types.Address
+		maxPriority []byte
+	)
+	members := cs.QuorumSet.Members()
+	if addr := cs.validatorAddress(); addr != nil {
+		members = append(members, addr)
+	}
+	for _, addr := range members {
+		priority := cs.nodePriority(round, addr)
+		if maxPriority == nil || bytes.Compare(priority, maxPriority) > 0 {
+			leader, maxPriority = addr, priority
+		}
+	}
+	if leader != nil && !cs.isRoundLeader(leader) {
+		cs.RoundLeaders = append(cs.RoundLeaders, leader)
+	}
+}
+
+// nodePriority returns the priority of a node as a round leader, derived
+// from the height, the round and the last block.
+func (cs *FBAConsensus) nodePriority(round int, addr types.Address) []byte {
+	bz := make([]byte, 16, 16+len(cs.state.LastBlockID.Hash)+len(addr))
+	binary.BigEndian.PutUint64(bz[:8], uint64(cs.Height))
+	binary.BigEndian.PutUint64(bz[8:], uint64(round))
+	bz = append(bz, cs.state.LastBlockID.Hash...)
+	bz = append(bz, addr...)
+	return tmhash.Sum(bz)
+}
+
+func (cs *FBAConsensus) isRoundLeader(addr types.Address) bool {
+	for _, leader := range cs.RoundLeaders {
+		if bytes.Equal(leader, addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// validatorAddress returns the address of the local validator if it takes
+// part in the consensus, nil otherwise.
+func (cs *FBAConsensus) validatorAddress() types.Address {
+	if cs.validator == nil {
+		return nil
+	}
+	if _, ok := cs.validator.(types.StatementSigner); !ok {
+		return nil
+	}
+	addr := cs.validator.GetPubKey().Address()
+	if !cs.Validators.HasAddress(addr) {
+		return nil
+	}
+	return addr
+}
+
+// decideProposal creates, signs and broadcasts our block for the height, and
+// votes to nominate it. A single proposal is made per height, at round 0.
+func (cs *FBAConsensus) decideProposal(height int64) bool {
+	block, blockParts := cs.createProposalBlock()
+	if block == nil { // on error
+		return false
+	}
+
+	// Flush the WAL. Otherwise, we may not recompute the same proposal to sign, and the validator will refuse to sign anything.
+	cs.wal.FlushAndSync()
+
+	propBlockID := types.BlockID{Hash: block.Hash(), PartsHeader: blockParts.Header()}
+	proposal := types.NewProposal(height, 0, -1, propBlockID)
+	if err := cs.validator.SignProposal(cs.state.LeagueID, proposal); err != nil {
+		if !cs.replayMode {
+			cs.Logger.Error("nominate: Error signing proposal", "height", height, "err", err)
+		}
+		return false
+	}
+	cs.proposal = proposal
+
+	// send proposal and block parts on internal msg queue
+	cs.sendInternalMessage(msgInfo{&ProposalMessage{proposal}, ""})
+	for i := 0; i < blockParts.Total(); i++ {
+		part := blockParts.GetPart(i)
+		cs.sendInternalMessage(msgInfo{&BlockPartMessage{height, 0, part}, ""})
+	}
+	cs.Logger.Info("Signed proposal", "height", height, "proposal", proposal)
+
+	// our own block is valid by construction
+	cs.ProposalBlocks[propBlockID.Key()] = block
+	cs.ProposalBlockParts[propBlockID.Key()] = blockParts
+	cs.Votes = appendBlockID(cs.Votes, propBlockID)
+	return true
+}
+
+// Create the next block to propose and return it.
+// Returns nil block upon error.
+func (cs *FBAConsensus) createProposalBlock() (block *types.Block, blockParts *types.PartSet) {
+	var commit *types.Commit
+	switch {
+	case cs.Height == 1:
+		// We're creating a proposal for the first block.
+		// The commit is empty, but not nil.
+		commit = types.NewCommit(types.BlockID{}, nil)
+	case cs.LastCommit != nil:
+		// Make the commit from LastCommit
+		commit = cs.LastCommit.MakeCommit()
+	default:
+		// This shouldn't happen.
+		cs.Logger.Error("nominate: Cannot propose anything: No commit for the previous block.")
+		return
+	}
+
+	proposerAddr := cs.validatorAddress()
+	if proposerAddr == nil {
+		cs.Logger.Error("nominate: Cannot propose anything: Not a validator.")
+		return
+	}
+	return cs.blockExec.CreateProposalBlock(cs.Height, cs.state, commit, proposerAddr)
+}
+
+// adoptLeaderVotes echoes the nomination votes of the round leaders for the
+// blocks we have validated. Returns true if our votes changed.
+func (cs *FBAConsensus) adoptLeaderVotes() bool {
+	if len(cs.Candidates) > 0 || cs.validatorAddress() == nil {
+		return false
+	}
+	updated := false
+	for _, leader := range cs.RoundLeaders {
+		stmt := cs.LatestNominations[string(leader)]
+		if stmt == nil {
+			continue
+		}
+		for _, blockID := range append(stmt.Votes, stmt.Accepted...) {
+			if containsBlockID(cs.Votes, blockID) || cs.ProposalBlocks[blockID.Key()] == nil {
+				continue
+			}
+			cs.Votes = appendBlockID(cs.Votes, blockID)
+			updated = true
+		}
+	}
+	return updated
+}
+
+// advanceNomination runs federated voting on the nominated values.
+// Returns true if our nomination statement changed.
+func (cs *FBAConsensus) advanceNomination() bool {
+	updated := false
+
+	// accept nominate(x)
+	for _, stmt := range cs.LatestNominations {
+		for _, blockID := range append(stmt.Votes, stmt.Accepted...) {
+			if containsBlockID(cs.Accepted, blockID) {
+				continue
+			}
+			if cs.federatedAccept(votesNominate(blockID), acceptsNominate(blockID), cs.LatestNominations) {
+				cs.Logger.Info("Accepted nomination", "height", cs.Height, "value", blockID)
+				cs.Accepted = appendBlockID(cs.Accepted, blockID)
+				cs.Votes = appendBlockID(cs.Votes, blockID)
+				updated = true
+			}
+		}
+	}
+
+	// confirm nominate(x)
+	for _, blockID := range cs.Accepted {
+		if containsBlockID(cs.Candidates, blockID) {
+			continue
+		}
+		if cs.federatedRatify(acceptsNominate(blockID), cs.LatestNominations) {
+			cs.Logger.Info("Confirmed nomination", "height", cs.Height, "value", blockID)
+			cs.Candidates = appendBlockID(cs.Candidates, blockID)
+			updated = true
+		}
+	}
+	return updated
+}
+
+// compositeValue combines the candidates into the value to ballot on. Blocks
+// can't be merged, so the candidate with the highest hash is chosen.
+func (cs *FBAConsensus) compositeValue() (composite types.BlockID, ok bool) {
+	for _, blockID := range cs.Candidates {
+		if !ok || bytes.Compare(blockID.Hash, composite.Hash) > 0 {
+			composite, ok = blockID, true
+		}
+	}
+	return
+}
+
+//-----------------------------------------------------------------------------
+// Ballot protocol
+
+// advanceBallot runs the steps of the ballot protocol until none applies.
+// Returns true if our ballot statement changed.
+func (cs *FBAConsensus) advanceBallot() bool {
+	if cs.Phase >= SlotPhaseExternalize {
+		return false
+	}
+	updated := false
+	if cs.Ballot == nil {
+		if composite, ok := cs.compositeValue(); ok {
+			cs.setBallot(&types.SCPBallot{Counter: 1, Value: composite})
+			updated = true
+		}
+	}
+	for {
+		stepped := cs.attemptAcceptPrepared() ||
+			cs.attemptConfirmPrepared() ||
+			cs.attemptAcceptCommit() ||
+			cs.attemptConfirmCommit() ||
+			cs.attemptBump()
+		if !stepped {
+			return updated
+		}
+		updated = true
+	}
+}
+
+func (cs *FBAConsensus) setBallot(ballot *types.SCPBallot) {
+	cs.Ballot = ballot
+	if cs.Phase < SlotPhasePrepare {
+		cs.Phase = SlotPhasePrepare
+	}
+}
+
+// prepareCandidates returns the ballots referenced by the ballot statements,
+// highest first.
+func (cs *FBAConsensus) prepareCandidates() []*types.SCPBallot {
+	var ballots []*types.SCPBallot
+	add := func(ballot *types.SCPBallot) {
+		if ballot == nil || ballot.Counter == 0 {
+			return
+		}
+		for _, b := range ballots {
+			if b.Compare(ballot) == 0 {
+				return
+			}
+		}
+		ballots = append(ballots, ballot)
+	}
+	for _, stmt := range cs.LatestBallots {
+		switch stmt.Type {
+		case types.SCPPrepareType:
+			add(stmt.Ballot)
+			add(stmt.Prepared)
+			add(stmt.PreparedPrime)
+		case types.SCPConfirmType:
+			// a CONFIRM prepares every ballot compatible with b,
+			// the counters it mentions are enough to catch up
+			add(stmt.Ballot)
+			add(&types.SCPBallot{Counter: stmt.NPrepared, Value: stmt.Ballot.Value})
+			add(&types.SCPBallot{Counter: stmt.NHigh, Value: stmt.Ballot.Value})
+		case types.SCPExternalizeType:
+			add(stmt.Ballot)
+			add(&types.SCPBallot{Counter: stmt.NHigh, Value: stmt.Ballot.Value})
+		}
+	}
+	sort.Slice(ballots, func(i, j int) bool { return ballots[i].Compare(ballots[j]) > 0 })
+	return ballots
+}
+
+// attemptAcceptPrepared raises p (and p') to the highest ballot accepted as prepared.
+func (cs *FBAConsensus) attemptAcceptPrepared() bool {
+	if cs.Phase != SlotPhasePrepare && cs.Phase != SlotPhaseConfirm {
+		return false
+	}
+	for _, ballot := range cs.prepareCandidates() {
+		if cs.Phase == SlotPhaseConfirm {
+			// only raise p, which must stay compatible with h
+			if !cs.Prepared.LessAndCompatible(ballot) || !cs.HighBallot.Compatible(ballot) {
+				continue
+			}
+		}
+		// skip ballots that wouldn't change p or p'
+		if cs.PreparedPrime != nil && ballot.Compare(cs.PreparedPrime) <= 0 {
+			continue
+		}
+		if cs.Prepared != nil && ballot.LessAndCompatible(cs.Prepared) {
+			continue
+		}
+		if cs.federatedAccept(votesPrepare(ballot), acceptsPrepare(ballot), cs.LatestBallots) {
+			return cs.setAcceptPrepared(ballot)
+		}
+	}
+	return false
+}
+
+func (cs *FBAConsensus) setAcceptPrepared(ballot *types.SCPBallot) bool {
+	cs.Logger.Info("Accepted prepared", "height", cs.Height, "ballot", ballot)
+	switch {
+	case cs.Prepared == nil:
+		cs.Prepared = ballot.Copy()
+	case cs.Prepared.Compare(ballot) < 0:
+		if !cs.Prepared.Compatible(ballot) {
+			cs.PreparedPrime = cs.Prepared
+		}
+		cs.Prepared = ballot.Copy()
+	case !cs.Prepared.Compatible(ballot):
+		cs.PreparedPrime = ballot.Copy()
+	default:
+		return false
+	}
+
+	// a prepared ballot aborts the commit votes for incompatible lower ballots
+	if cs.CommitBallot != nil && cs.HighBallot != nil && cs.Phase == SlotPhasePrepare {
+		if cs.HighBallot.LessAndIncompatible(cs.Prepared) ||
+			(cs.PreparedPrime != nil && cs.HighBallot.LessAndIncompatible(cs.PreparedPrime)) {
+			cs.CommitBallot = nil
+		}
+	}
+	return true
+}
+
+// attemptConfirmPrepared raises h to the highest ballot confirmed as
+// prepared, and starts voting to commit it if possible.
+func (cs *FBAConsensus) attemptConfirmPrepared() bool {
+	if cs.Phase != SlotPhasePrepare || cs.Prepared == nil {
+		return false
+	}
+	candidates := cs.prepareCandidates()
+	for i, ballot := range candidates {
+		if cs.HighBallot != nil && ballot.Compare(cs.HighBallot) <= 0 {
+			return false
+		}
+		if !cs.federatedRatify(acceptsPrepare(ballot), cs.LatestBallots) {
+			continue
+		}
+		cs.Logger.Info("Confirmed prepared", "height", cs.Height, "ballot", ballot)
+		newH := ballot.Copy()
+		cs.HighBallot = newH
+		if cs.Ballot == nil || cs.Ballot.Compare(newH) < 0 {
+			cs.setBallot(newH.Copy())
+		}
+
+		// vote to commit the lowest confirmed prepared ballot compatible with h,
+		// unless it was aborted by p or p'
+		if cs.CommitBallot == nil && cs.Ballot.LessAndCompatible(newH) &&
+			!newH.LessAndIncompatible(cs.Prepared) &&
+			!(cs.PreparedPrime != nil && newH.LessAndIncompatible(cs.PreparedPrime)) {
+			newC := newH.Copy()
+			for _, lower := range candidates[i+1:] {
+				if !lower.Compatible(newH) || lower.Compare(cs.Ballot) < 0 {
+					continue
+				}
+				if !cs.federatedRatify(acceptsPrepare(lower), cs.LatestBallots) {
+					break
+				}
+				newC = lower.Copy()
+			}
+			cs.CommitBallot = newC
+		}
+		return true
+	}
+	return false
+}
+
+// commitBoundaries returns the counters bounding the commit ranges of the
+// statements compatible with value, highest first.
+func (cs *FBAConsensus) commitBoundaries(value types.BlockID) []int {
+	var counters []int
+	add := func(n int) {
+		if n == 0 {
+			return
+		}
+		for _, c := range counters {
+			if c == n {
+				return
+			}
+		}
+		counters = append(counters, n)
+	}
+	for _, stmt := range cs.LatestBallots {
+		if !stmt.Ballot.Value.Equals(value) {
+			continue
+		}
+		switch stmt.Type {
+		case types.SCPPrepareType:
+			if stmt.NCommit != 0 {
+				add(stmt.NCommit)
+				add(stmt.NHigh)
+			}
+		case types.SCPConfirmType:
+			add(stmt.NCommit)
+			add(stmt.NHigh)
+		case types.SCPExternalizeType:
+			add(stmt.Ballot.Counter)
+			add(stmt.NHigh)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(counters)))
+	return counters
+}
+
+// findCommitRange returns the highest contiguous range of counters
+// satisfying pred.
+func findCommitRange(counters []int, pred func(n int) bool) (low, high int, ok bool) {
+	for _, n := range counters {
+		if pred(n) {
+			if !ok {
+				high, ok = n, true
+			}
+			low = n
+		} else if ok {
+			break
+		}
+	}
+	return
+}
+
+// attemptAcceptCommit moves to the CONFIRM phase once we accept commit(b).
+func (cs *FBAConsensus) attemptAcceptCommit() bool {
+	if cs.Phase != SlotPhasePrepare && cs.Phase != SlotPhaseConfirm {
+		return false
+	}
+	var values []types.BlockID
+	if cs.Phase == SlotPhaseConfirm {
+		values = []types.BlockID{cs.HighBallot.Value}
+	} else {
+		for _, stmt := range cs.LatestBallots {
+			values = appendBlockID(values, stmt.Ballot.Value)
+		}
+	}
+	for _, value := range values {
+		low, high, ok := findCommitRange(cs.commitBoundaries(value), func(n int) bool {
+			return cs.federatedAccept(votesCommit(value, n), acceptsCommit(value, n), cs.LatestBallots)
+		})
+		if !ok {
+			continue
+		}
+		if cs.Phase == SlotPhaseConfirm && high <= cs.HighBallot.Counter && low >= cs.CommitBallot.Counter {
+			continue
+		}
+		cs.Logger.Info("Accepted commit", "height", cs.Height, "value", value, "low", low, "high", high)
+		cs.Phase = SlotPhaseConfirm
+		cs.CommitBallot = &types.SCPBallot{Counter: low, Value: value}
+		cs.HighBallot = &types.SCPBallot{Counter: high, Value: value}
+		if cs.Ballot == nil || !cs.Ballot.Compatible(cs.HighBallot) || cs.Ballot.Counter < high {
+			cs.Ballot = cs.HighBallot.Copy()
+		}
+		if cs.Prepared == nil || cs.Prepared.Compare(cs.HighBallot) < 0 {
+			cs.Prepared = cs.HighBallot.Copy()
+		}
+		cs.PreparedPrime = nil
+		return true
+	}
+	return false
+}
+
+// attemptConfirmCommit externalizes the value once we confirm commit(b).
+func (cs *FBAConsensus) attemptConfirmCommit() bool {
+	if cs.Phase != SlotPhaseConfirm {
+		return false
+	}
+	value := cs.HighBallot.Value
+	low, high, ok := findCommitRange(cs.commitBoundaries(value), func(n int) bool {
+		return cs.federatedRatify(acceptsCommit(value, n), cs.LatestBallots)
+	})
+	if !ok {
+		return false
+	}
+	cs.CommitBallot = &types.SCPBallot{Counter: low, Value: value}
+	cs.HighBallot = &types.SCPBallot{Counter: high, Value: value}
+	cs.externalize()
+	return true
+}
+
+// attemptBump jumps to a higher ballot counter if a v-blocking set of
+// validators is already ahead of us.
+func (cs *FBAConsensus) attemptBump() bool {
+	if cs.Phase != SlotPhasePrepare && cs.Phase != SlotPhaseConfirm && cs.Phase != SlotPhaseNominate {
+		return false
+	}
+	current := cs.ballotCounter()
+	aheadOf := func(n int) map[string]bool {
+		return cs.nodesMatching(func(stmt *types.SCPStatement) bool {
+			return statementCounter(stmt) > n
+		}, cs.LatestBallots)
+	}
+	if !cs.QuorumSet.IsVBlocking(aheadOf(current)) {
+		return false
+	}
+	var counters []int
+	for _, stmt := range cs.LatestBallots {
+		if n := statementCounter(stmt); n > current && n < maxBallotCounter {
+			counters = append(counters, n)
+		}
+	}
+	sort.Ints(counters)
+	for _, n := range counters {
+		if !cs.QuorumSet.IsVBlocking(aheadOf(n)) {
+			return cs.bumpBallotCounter(n)
+		}
+	}
+	return false
+}
+
+// bumpBallotCounter moves to a ballot with the given counter, for the value
+// of h if set, our current ballot or the composite value otherwise.
+func (cs *FBAConsensus) bumpBallotCounter(counter int) bool {
+	var value types.BlockID
+	switch {
+	case cs.HighBallot != nil:
+		value = cs.HighBallot.Value
+	case cs.Ballot != nil:
+		value = cs.Ballot.Value
+	default:
+		composite, ok := cs.compositeValue()
+		if !ok {
+			// adopt the value of the highest ballot we know of
+			candidates := cs.prepareCandidates()
+			if len(candidates) == 0 {
+				return false
+			}
+			composite = candidates[0].Value
+		}
+		value = composite
+	}
+	cs.Logger.Info("Bumping ballot", "height", cs.Height, "counter", counter, "value", value)
+	cs.setBallot(&types.SCPBallot{Counter: counter, Value: value})
+	return true
+}
+
+// Enter: `timeoutBallot` after a quorum reached our ballot counter.
+func (cs *FBAConsensus) abandonBallot(height int64, counter int) {
+	if cs.Height != height || cs.Ballot == nil || cs.Ballot.Counter != counter ||
+		(cs.Phase != SlotPhasePrepare && cs.Phase != SlotPhaseConfirm) {
+		return
+	}
+	cs.Logger.Info(fmt.Sprintf("abandonBallot(%v/%v). Current: %v/%v", height, counter, cs.Height, cs.Phase))
+	if cs.bumpBallotCounter(counter + 1) {
+		cs.emitBallot()
+		cs.advanceSlot()
+		cs.newStep()
+	}
+}
+
+// checkBallotTimer arms the ballot timer once a quorum reached our counter.
+func (cs *FBAConsensus) checkBallotTimer() {
+	if cs.Ballot == nil || cs.ballotTimerCounter >= cs.Ballot.Counter ||
+		(cs.Phase != SlotPhasePrepare && cs.Phase != SlotPhaseConfirm) {
+		return
+	}
+	counter := cs.Ballot.Counter
+	if !cs.isQuorum(func(stmt *types.SCPStatement) bool { return statementCounter(stmt) >= counter }, cs.LatestBallots) {
+		return
+	}
+	cs.ballotTimerCounter = counter
+	cs.ballotTicker.ScheduleTimeout(timeoutInfo{cs.config.Precommit(counter), cs.Height, counter, RoundStepPrecommitWait})
+}
+
+func (cs *FBAConsensus) ballotCounter() int {
+	if cs.Ballot == nil {
+		return 0
+	}
+	return cs.Ballot.Counter
+}
+
+// statementCounter returns the ballot counter of a ballot statement.
+func statementCounter(stmt *types.SCPStatement) int {
+	if stmt.Type == types.SCPExternalizeType {
+		return maxBallotCounter
+	}
+	return stmt.Ballot.Counter
+}
+
+//-----------------------------------------------------------------------------
+// Federated voting
+
+// federatedAccept returns true if a v-blocking set accepted the statement,
+// or a quorum voted for or accepted it.
+func (cs *FBAConsensus) federatedAccept(voted, accepted statementFilter, statements map[string]*types.SCPStatement) bool {
+	if cs.QuorumSet.IsVBlocking(cs.nodesMatching(accepted, statements)) {
+		return true
+	}
+	return cs.isQuorum(func(stmt *types.SCPStatement) bool {
+		return voted(stmt) || accepted(stmt)
+	}, statements)
+}
+
+// federatedRatify returns true if a quorum accepted the statement.
+func (cs *FBAConsensus) federatedRatify(accepted statementFilter, statements map[string]*types.SCPStatement) bool {
+	return cs.isQuorum(accepted, statements)
+}
+
+// isQuorum returns true if the nodes whose statement matches the filter
+// contain a quorum including one of our slices.
+func (cs *FBAConsensus) isQuorum(filter statementFilter, statements map[string]*types.SCPStatement) bool {
+	return cs.containsQuorum(cs.nodesMatching(filter, statements), statements)
+}
+
+// containsQuorum returns true if nodes contain a quorum including one of our
+// slices, the slices of the nodes being those of their statements, see
+// nodeQuorumSet. It removes the nodes outside of the quorum.
+func (cs *FBAConsensus) containsQuorum(nodes map[string]bool, statements map[string]*types.SCPStatement) bool {
+	// remove the nodes whose slices aren't satisfied, until a fixpoint is reached
+	for {
+		removed := false
+		for addr := range nodes {
+			if !cs.nodeQuorumSet(addr, statements).IsSliceSatisfied(nodes) {
+				delete(nodes, addr)
+				removed = true
+			}
+		}
+		if !removed {
+			break
+		}
+	}
+	return cs.QuorumSet.IsSliceSatisfied(nodes)
+}
+
+func (cs *FBAConsensus) nodesMatching(filter statementFilter, statements map[string]*types.SCPStatement) map[string]bool {
+	nodes := make(map[string]bool)
+	for addr, stmt := range statements {
+		if filter(stmt) {
+			nodes[addr] = true
+		}
+	}
+	return nodes
+}
+
+func votesNominate(blockID types.BlockID) statementFilter {
+	return func(stmt *types.SCPStatement) bool {
+		return containsBlockID(stmt.Votes, blockID) || containsBlockID(stmt.Accepted, blockID)
+	}
+}
+
+func acceptsNominate(blockID types.BlockID) statementFilter {
+	return func(stmt *types.SCPStatement) bool {
+		return containsBlockID(stmt.Accepted, blockID)
+	}
+}
+
+func votesPrepare(ballot *types.SCPBallot) statementFilter {
+	return func(stmt *types.SCPStatement) bool {
+		switch stmt.Type {
+		case types.SCPPrepareType:
+			return ballot.LessAndCompatible(stmt.Ballot)
+		case types.SCPConfirmType, types.SCPExternalizeType:
+			return ballot.Compatible(stmt.Ballot)
+		}
+		return false
+	}
+}
+
+func acceptsPrepare(ballot *types.SCPBallot) statementFilter {
+	return func(stmt *types.SCPStatement) bool {
+		switch stmt.Type {
+		case types.SCPPrepareType:
+			return (stmt.Prepared != nil && ballot.LessAndCompatible(stmt.Prepared)) ||
+				(stmt.PreparedPrime != nil && ballot.LessAndCompatible(stmt.PreparedPrime))
+		case types.SCPConfirmType:
+			return ballot.Compatible(stmt.Ballot) && ballot.Counter <= stmt.NPrepared
+		case types.SCPExternalizeType:
+			return ballot.Compatible(stmt.Ballot)
+		}
+		return false
+	}
+}
+
+func votesCommit(value types.BlockID, n int) statementFilter {
+	return func(stmt *types.SCPStatement) bool {
+		if !stmt.Ballot.Value.Equals(value) {
+			return false
+		}
+		switch stmt.Type {
+		case types.SCPPrepareType:
+			return stmt.NCommit != 0 && stmt.NCommit <= n && n <= stmt.NHigh
+		case types.SCPConfirmType:
+			return stmt.NCommit <= n
+		case types.SCPExternalizeType:
+			return stmt.Ballot.Counter <= n
+		}
+		return false
+	}
+}
+
+func acceptsCommit(value types.BlockID, n int) statementFilter {
+	return func(stmt *types.SCPStatement) bool {
+		if !stmt.Ballot.Value.Equals(value) {
+			return false
+		}
+		switch stmt.Type {
+		case types.SCPConfirmType:
+			return stmt.NCommit <= n && n <= stmt.NHigh
+		case types.SCPExternalizeType:
+			return stmt.Ballot.Counter <= n
+		}
+		return false
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Statements
+
+// advanceSlot runs the nomination and ballot protocols until neither makes
+// progress, emitting our updated statements on the way.
+func (cs *FBAConsensus) advanceSlot() {
+	for {
+		nominationUpdated := cs.advanceNomination()
+		if nominationUpdated {
+			cs.emitNomination()
+		}
+		ballotUpdated := cs.advanceBallot()
+		if ballotUpdated {
+			cs.emitBallot()
+		}
+		if !nominationUpdated && !ballotUpdated {
+			break
+		}
+	}
+	cs.checkBallotTimer()
+	if cs.Phase == SlotPhaseExternalize {
+		cs.tryFinalizeCommit(cs.Height)
+	}
+}
+
+func (cs *FBAConsensus) addStatement(stmt *types.SCPStatement, peerID types.P2PID) error {
+	if stmt.Height < cs.Height {
+		// stragglers of a past height
+		return nil
+	}
+	if stmt.Height != cs.Height {
+		return ErrStatementHeightMismatch
+	}
+	_, val := cs.Validators.GetByAddress(stmt.ValidatorAddress)
+	if val == nil {
+		return ErrStatementUnknownValidator
+	}
+	if err := stmt.Verify(cs.state.LeagueID, val.PubKey); err != nil {
+		return err
+	}
+
+	key := string(stmt.ValidatorAddress)
+	if stmt.Type == types.SCPNominateType {
+		if !isNewerNomination(cs.LatestNominations[key], stmt) {
+			return nil
+		}
+		cs.LatestNominations[key] = stmt
+	} else {
+		if !isNewerBallotStatement(cs.LatestBallots[key], stmt) {
+			return nil
+		}
+		cs.LatestBallots[key] = stmt
+	}
+	cs.Logger.Debug("Added statement", "stmt", stmt, "peer", peerID)
+
+	if stmt.Type == types.SCPNominateType && cs.isRoundLeader(stmt.ValidatorAddress) && cs.adoptLeaderVotes() {
+		cs.emitNomination()
+	}
+	phase := cs.Phase
+	cs.advanceSlot()
+	if cs.Phase != phase {
+		cs.newStep()
+	}
+	return nil
+}
+
+// isNewerNomination returns true if stmt adds votes or accepted values to old.
+func isNewerNomination(old, stmt *types.SCPStatement) bool {
+	if old == nil {
+		return true
+	}
+	if len(stmt.Votes) < len(old.Votes) || len(stmt.Accepted) < len(old.Accepted) {
+		return false
+	}
+	for _, blockID := range old.Votes {
+		if !containsBlockID(stmt.Votes, blockID) {
+			return false
+		}
+	}
+	for _, blockID := range old.Accepted {
+		if !containsBlockID(stmt.Accepted, blockID) {
+			return false
+		}
+	}
+	return len(stmt.Votes) > len(old.Votes) || len(stmt.Accepted) > len(old.Accepted)
+}
+
+// isNewerBallotStatement returns true if stmt supersedes old.
+// Statements are ordered by type (PREPARE < CONFIRM < EXTERNALIZE), then by
+// their ballots and counters.
+func isNewerBallotStatement(old, stmt *types.SCPStatement) bool {
+	if old == nil {
+		return true
+	}
+	if old.Type != stmt.Type {
+		return old.Type < stmt.Type
+	}
+	if c := old.Ballot.Compare(stmt.Ballot); c != 0 {
+		return c < 0
+	}
+	switch stmt.Type {
+	case types.SCPPrepareType:
+		if c := compareOptionalBallots(old.Prepared, stmt.Prepared); c != 0 {
+			return c < 0
+		}
+		if c := compareOptionalBallots(old.PreparedPrime, stmt.PreparedPrime); c != 0 {
+			return c < 0
+		}
+		return old.NHigh < stmt.NHigh
+	case types.SCPConfirmType:
+		if old.NPrepared != stmt.NPrepared {
+			return old.NPrepared < stmt.NPrepared
+		}
+		return old.NHigh < stmt.NHigh
+	}
+	// an EXTERNALIZE is final
+	return false
+}
+
+func compareOptionalBallots(a, b *types.SCPBallot) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	return a.Compare(b)
+}
+
+// emitNomination signs and records our nomination statement.
+func (cs *FBAConsensus) emitNomination() {
+	cs.emitStatement(&types.SCPStatement{
+		Type:     types.SCPNominateType,
+		Votes:    append([]types.BlockID(nil), cs.Votes...),
+		Accepted: append([]types.BlockID(nil), cs.Accepted...),
+	})
+}
+
+// emitBallot signs and records our ballot statement for the current phase.
+func (cs *FBAConsensus) emitBallot() {
+	if cs.Ballot == nil {
+		return
+	}
+	stmt := &types.SCPStatement{}
+	switch cs.Phase {
+	case SlotPhasePrepare:
+		stmt.Type = types.SCPPrepareType
+		stmt.Ballot = cs.Ballot.Copy()
+		stmt.Prepared = cs.Prepared.Copy()
+		stmt.PreparedPrime = cs.PreparedPrime.Copy()
+		if cs.HighBallot != nil {
+			stmt.NHigh = cs.HighBallot.Counter
+		}
+		if cs.CommitBallot != nil {
+			stmt.NCommit = cs.CommitBallot.Counter
+		}
+	case SlotPhaseConfirm:
+		stmt.Type = types.SCPConfirmType
+		stmt.Ballot = cs.Ballot.Copy()
+		stmt.NPrepared = cs.Prepared.Counter
+		stmt.NCommit = cs.CommitBallot.Counter
+		stmt.NHigh = cs.HighBallot.Counter
+	case SlotPhaseExternalize, SlotPhaseCommit:
+		stmt.Type = types.SCPExternalizeType
+		stmt.Ballot = cs.CommitBallot.Copy()
+		stmt.NHigh = cs.HighBallot.Counter
+	default:
+		return
+	}
+	cs.emitStatement(stmt)
+}
+
+func (cs *FBAConsensus) emitStatement(stmt *types.SCPStatement) {
+	addr := cs.validatorAddress()
+	if addr == nil {
+		return
+	}
+	stmt.Height = cs.Height
+	stmt.ValidatorAddress = addr
+	stmt.QuorumSet = cs.QuorumSet
+	if err := cs.validator.(types.StatementSigner).SignStatement(cs.state.LeagueID, stmt); err != nil {
+		if !cs.replayMode {
+			cs.Logger.Error("Error signing statement", "height", cs.Height, "stmt", stmt, "err", err)
+		}
+		return
+	}
+
+	// record it right away, our statement counts in our own quorums
+	if stmt.Type == types.SCPNominateType {
+		cs.LatestNominations[string(addr)] = stmt
+	} else {
+		cs.LatestBallots[string(addr)] = stmt
+	}
+	cs.wal.WriteSync(msgInfo{&SCPStatementMessage{stmt}, ""}) // NOTE: fsync
+	cs.evsw.FireEvent(EventSCPStatement, stmt)
+	if !cs.replayMode {
+		fireBroadcast(cs.evsw, &SCPStatementMessage{stmt})
+	}
+	cs.Logger.Info("Signed and emitted statement", "height", cs.Height, "stmt", stmt)
+}
+
+//-----------------------------------------------------------------------------
+// Proposals
+
+func (cs *FBAConsensus) setProposal(proposal *types.Proposal) error {
+	if proposal.Height != cs.Height || cs.Phase >= SlotPhaseCommit {
+		return nil
+	}
+	if proposal.Round != 0 || proposal.POLRound != -1 {
+		return ErrUnexpectedProposal
+	}
+	key := proposal.BlockID.Key()
+	if cs.ProposalBlockParts[key] != nil {
+		return nil
+	}
+	signBytes := proposal.SignBytes(cs.state.LeagueID)
+	var proposer types.Address
+	cs.Validators.Iterate(func(index int, val *types.Validator) bool {
+		if val.PubKey.VerifyBytes(signBytes, proposal.Signature) {
+			proposer = val.Address
+			return true
+		}
+		return false
+	})
+	if proposer == nil {
+		return ErrInvalidProposalSignature
+	}
+	// only the validators may propose, and at most one block each, as
+	// there is a single proposal round per height
+	if _, ok := cs.Proposers[string(proposer)]; ok {
+		return ErrUnexpectedProposal
+	}
+
+	cs.Proposers[string(proposer)] = key
+	cs.ProposalBlockParts[key] = types.NewPartSetFromHeader(proposal.BlockID.PartsHeader)
+	cs.Logger.Info("Received proposal", "proposal", proposal)
+	return nil
+}
+
+// addProposalBlockPart adds the part to the proposal it belongs to. Once the
+// block is complete and valid, it can be voted for.
+func (cs *FBAConsensus) addProposalBlockPart(msg *BlockPartMessage, peerID types.P2PID) (added bool, err error) {
+	height, part := msg.Height, msg.Part
+
+	if cs.Height != height {
+		cs.Logger.Debug("Received block part from wrong height", "height", height)
+		return false, nil
+	}
+
+	for key, parts := range cs.ProposalBlockParts {
+		if parts.IsComplete() {
+			continue
+		}
+		added, err = parts.AddPart(part)
+		if err != nil || !added {
+			// it belongs to another proposal
+			continue
+		}
+		if parts.IsComplete() {
+			return true, cs.completeProposalBlock(key, parts)
+		}
+		return true, nil
+	}
+	cs.Logger.Debug("Received a block part we're not expecting", "height", height, "index", part.Index, "peer", peerID)
+	return false, nil
+}
+
+func (cs *FBAConsensus) completeProposalBlock(key string, parts *types.PartSet) error {
+	var block *types.Block
+	_, err := cdc.UnmarshalBinaryReader(parts.GetReader(), &block, cs.state.ConsensusParams.Block.MaxBytes)
+	if err != nil {
+		delete(cs.ProposalBlockParts, key)
+		return err
+	}
+	if err := cs.blockExec.ValidateBlock(cs.state, block); err != nil {
+		delete(cs.ProposalBlockParts, key)
+		return fmt.Errorf("Invalid proposal block: %v", err)
+	}
+	cs.ProposalBlocks[key] = block
+	blockID := types.BlockID{Hash: block.Hash(), PartsHeader: parts.Header()}
+	cs.Logger.Info("Received complete proposal block", "height", block.Height, "hash", block.Hash())
+	if cs.eventBus != nil {
+		cs.eventBus.PublishEventCompleteProposal(types.EventDataCompleteProposal{
+			Height:  cs.Height,
+			Round:   cs.NominationRound,
+			Step:    cs.Phase.String(),
+			BlockID: blockID,
+		})
+	}
+
+	if cs.adoptLeaderVotes() {
+		cs.emitNomination()
+		cs.advanceSlot()
+	}
+	if cs.Phase >= SlotPhaseExternalize {
+		cs.tryFinalizeCommit(cs.Height)
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// Commit
+
+// externalize records the decided value and precommits it.
+func (cs *FBAConsensus) externalize() {
+	value := cs.CommitBallot.Value
+	cs.Logger.Info("Externalized value", "height", cs.Height, "commit", cs.CommitBallot, "high", cs.HighBallot)
+	cs.Phase = SlotPhaseExternalize
+	cs.CommitTime = ttime.Now()
+
+	// set up to receive the block if we don't have it
+	if cs.ProposalBlockParts[value.Key()] == nil {
+		cs.ProposalBlockParts[value.Key()] = types.NewPartSetFromHeader(value.PartsHeader)
+	}
+	cs.signAddPrecommit(value)
+}
+
+func (cs *FBAConsensus) signAddPrecommit(blockID types.BlockID) {
+	addr := cs.validatorAddress()
+	if addr == nil {
+		return
+	}
+	// Flush the WAL. Otherwise, we may not recompute the same vote to sign, and the validator will refuse to sign anything.
+	cs.wal.FlushAndSync()
+
+	valIndex, _ := cs.Validators.GetByAddress(addr)
+	vote := &types.Vote{
+		ValidatorAddress: addr,
+		ValidatorIndex:   valIndex,
+		Height:           cs.Height,
+		Round:            0,
+		Timestamp:        cs.voteTime(blockID),
+		Type:             types.PrecommitType,
+		BlockID:          blockID,
+	}
+	if err := cs.validator.SignVote(cs.state.LeagueID, vote); err != nil {
+		cs.Logger.Error("Error signing precommit", "height", cs.Height, "vote", vote, "err", err)
+		return
+	}
+	cs.sendInternalMessage(msgInfo{&VoteMessage{vote}, ""})
+	cs.Logger.Info("Signed and pushed precommit", "height", cs.Height, "vote", vote)
+}
+
+func (cs *FBAConsensus) voteTime(blockID types.BlockID) time.Time {
+	now := ttime.Now()
+	block := cs.ProposalBlocks[blockID.Key()]
+	if block == nil {
+		return now
+	}
+	// See the BFT time spec https://tendermint.com/docs/spec/consensus/bft-time.html
+	timeIotaMs := time.Duration(cs.state.ConsensusParams.Block.TimeIotaMs) * time.Millisecond
+	minVoteTime := block.Time.Add(timeIotaMs)
+	if now.After(minVoteTime) {
+		return now
+	}
+	return minVoteTime
+}
+
+// Attempt to add the precommit. If it's a duplicate signature, dupeout the validator.
+func (cs *FBAConsensus) tryAddVote(vote *types.Vote, peerID types.P2PID) (bool, error) {
+	added, err := cs.addVote(vote, peerID)
+	if err != nil {
+		if err == ErrVoteHeightMismatch {
+			return added, err
+		} else if voteErr, ok := err.(*types.ErrVoteConflictingVotes); ok {
+			if addr := cs.validatorAddress(); addr != nil && bytes.Equal(vote.ValidatorAddress, addr) {
+				cs.Logger.Error("Found conflicting vote from ourselves. Did you unsafe_reset a validator?", "height", vote.Height, "type", vote.Type)
+				return added, err
+			}
+			cs.evpool.AddEvidence(voteErr.DuplicateVoteEvidence)
+			return added, err
+		}
+		cs.Logger.Info("Error attempting to add vote", "err", err)
+		return added, ErrAddingVote
+	}
+	return added, nil
+}
+
+func (cs *FBAConsensus) addVote(vote *types.Vote, peerID types.P2PID) (added bool, err error) {
+	cs.Logger.Debug("addVote", "voteHeight", vote.Height, "voteType", vote.Type, "valIndex", vote.ValidatorIndex, "csHeight", cs.Height)
+
+	if vote.Type != types.PrecommitType || vote.Round != 0 {
+		return false, ErrAddingVote
+	}
+
+	// A precommit for the previous height?
+	// These come in while we wait timeoutCommit
+	if vote.Height+1 == cs.Height {
+		if cs.Phase != SlotPhaseNewHeight || cs.LastCommit == nil {
+			return added, ErrVoteHeightMismatch
+		}
+		added, err = cs.LastCommit.AddVote(vote)
+		if !added {
+			return added, err
+		}
+		cs.Logger.Info(fmt.Sprintf("Added to lastPrecommits: %v", cs.LastCommit.StringShort()))
+		cs.eventBus.PublishEventVote(types.EventDataVote{Vote: vote})
+		cs.evsw.FireEvent(types.EventVote, vote)
+		return
+	}
+
+	if vote.Height != cs.Height {
+		cs.Logger.Info("Vote ignored and not added", "voteHeight", vote.Height, "csHeight", cs.Height, "peerID", peerID)
+		return false, ErrVoteHeightMismatch
+	}
+
+	added, err = cs.Precommits.AddVote(vote)
+	if !added {
+		return
+	}
+	cs.Logger.Info("Added to precommit", "vote", vote, "precommits", cs.Precommits.StringShort())
+	cs.eventBus.PublishEventVote(types.EventDataVote{Vote: vote})
+	cs.evsw.FireEvent(types.EventVote, vote)
+
+	cs.tryFinalizeCommit(cs.Height)
+	return
+}
+
+// If we have the block AND +2/3 precommits for it, finalize.
+//
+// The precommits are only signed for externalized values, so the precommits
+// of a quorum, or of a v-blocking set, certify a value which a lagging
+// validator precommits too, until +2/3 of the voting power did.
+func (cs *FBAConsensus) tryFinalizeCommit(height int64) {
+	logger := cs.Logger.With("height", height)
+
+	if cs.Height != height {
+		cmn.PanicSanity(fmt.Sprintf("tryFinalizeCommit() cs.Height: %v vs height: %v", cs.Height, height))
+	}
+
+	if cs.Phase < SlotPhaseExternalize {
+		if blockID, ok := cs.certifiedValue(); ok {
+			cs.adoptValue(blockID)
+		}
+	}
+
+	blockID, ok := cs.Precommits.TwoThirdsMajority()
+	if !ok || len(blockID.Hash) == 0 {
+		logger.Debug("Attempt to finalize failed. There was no +2/3 majority, or +2/3 was for <nil>.")
+		return
+	}
+	if cs.Phase == SlotPhaseCommit {
+		return
+	}
+	block := cs.ProposalBlocks[blockID.Key()]
+	if block == nil {
+		logger.Info("Attempt to finalize failed. We don't have the commit block.", "commit-block", blockID.Hash)
+		if cs.ProposalBlockParts[blockID.Key()] == nil {
+			cs.ProposalBlockParts[blockID.Key()] = types.NewPartSetFromHeader(blockID.PartsHeader)
+		}
+		return
+	}
+
+	cs.finalizeCommit(height, blockID, block)
+}
+
+// certifiedValue returns the value precommitted by a quorum, or by a set of
+// nodes blocking each of our slices, which contains a correct node if we are
+// intact. The quorums are evaluated with the slices of the nodes' latest
+// ballot statements, or of the genesis file.
+func (cs *FBAConsensus) certifiedValue() (types.BlockID, bool) {
+	blockIDs := make(map[string]types.BlockID)
+	precommitters := make(map[string]map[string]bool)
+	for i := 0; i < cs.Validators.Size(); i++ {
+		precommit := cs.Precommits.GetByIndex(i)
+		if precommit == nil || len(precommit.BlockID.Hash) == 0 {
+			continue
+		}
+		key := precommit.BlockID.Key()
+		if precommitters[key] == nil {
+			blockIDs[key] = precommit.BlockID
+			precommitters[key] = make(map[string]bool)
+		}
+		precommitters[key][string(precommit.ValidatorAddress)] = true
+	}
+	for key, nodes := range precommitters {
+		if cs.QuorumSet.IsVBlocking(nodes) || cs.containsQuorum(nodes, cs.LatestBallots) {
+			return blockIDs[key], true
+		}
+	}
+	return types.BlockID{}, false
+}
+
+// adoptValue precommits a value certified by the precommits of others, if
+// we didn't precommit yet.
+func (cs *FBAConsensus) adoptValue(blockID types.BlockID) {
+	addr := cs.validatorAddress()
+	if addr == nil || cs.Precommits.GetByAddress(addr) != nil {
+		return
+	}
+	cs.Logger.Info("Precommitting a value certified by the precommits of a quorum before externalizing it",
+		"height", cs.Height, "value", blockID)
+	if cs.ProposalBlockParts[blockID.Key()] == nil {
+		cs.ProposalBlockParts[blockID.Key()] = types.NewPartSetFromHeader(blockID.PartsHeader)
+	}
+	cs.signAddPrecommit(blockID)
+}
+
+// Increment height and goto SlotPhaseNewHeight
+func (cs *FBAConsensus) finalizeCommit(height int64, blockID types.BlockID, block *types.Block) {
+	blockParts := cs.ProposalBlockParts[blockID.Key()]
+	if !blockParts.HasHeader(blockID.PartsHeader) {
+		cmn.PanicSanity(fmt.Sprintf("Expected ProposalBlockParts header to be commit header"))
+	}
+	if !block.HashesTo(blockID.Hash) {
+		cmn.PanicSanity(fmt.Sprintf("Cannot finalizeCommit, ProposalBlock does not hash to commit hash"))
+	}
+	if err := cs.blockExec.ValidateBlock(cs.state, block); err != nil {
+		cmn.PanicConsensus(fmt.Sprintf("A quorum committed an invalid block: %v", err))
+	}
+
+	cs.Phase = SlotPhaseCommit
+	if cs.CommitTime.IsZero() || cs.CommitBallot == nil {
+		cs.CommitTime = ttime.Now()
+	}
+	cs.newStep()
+
+	cs.Logger.Info(fmt.Sprintf("Finalizing commit of block with %d txs", block.NumTxs),
+		"height", block.Height, "hash", block.Hash(), "root", block.AppHash)
+	cs.Logger.Info(fmt.Sprintf("%v", block))
+
+	fail.Fail() // XXX
+
+	// Save to blockStore.
+	if cs.blockStore.Height() < block.Height {
+		seenCommit := cs.Precommits.MakeCommit()
+		cs.blockStore.SaveBlock(block, blockParts, seenCommit)
+	} else {
+		// Happens during replay if we already saved the block but didn't commit
+		cs.Logger.Info("Calling finalizeCommit on already stored block", "height", block.Height)
+	}
+
+	fail.Fail() // XXX
+
+	// Write EndHeightMessage{} for this height, implying that the blockstore
+	// has saved the block. See BFTConsensus.finalizeCommit.
+	cs.wal.WriteSync(EndHeightMessage{height}) // NOTE: fsync
+
+	fail.Fail() // XXX
+
+	// Create a copy of the state for staging and an event cache for txs.
+	stateCopy := cs.state.Copy()
+
+	// Execute and commit the block, update and save the state, and update the storage.
+	var err error
+	stateCopy, err = cs.blockExec.ApplyBlock(stateCopy, blockID, block)
+	if err != nil {
+		cs.Logger.Error("Error on ApplyBlock. Did the application crash? Please restart dgrid", "err", err)
+		err := cmn.Kill()
+		if err != nil {
+			cs.Logger.Error("Failed to kill this process - please do so manually", "err", err)
+		}
+		return
+	}
+
+	fail.Fail() // XXX
+
+	rounds := 0
+	if cs.CommitBallot != nil {
+		rounds = cs.CommitBallot.Counter
+	}
+	cs.metrics.recordBlock(cs.state, block, rounds)
+
+	// NewHeightStep!
+	cs.updateToState(stateCopy)
+
+	fail.Fail() // XXX
+
+	// Another engine takes over the league from the next height.
+	if cs.halter.maybeHalt(cs.state) {
+		return
+	}
+
+	// cs.StartTime is already set.
+	// Schedule the next slot to start soon.
+	cs.scheduleSlot0(&cs.SlotState)
+}
+
+//-----------------------------------------------------------------------------
+
+func containsBlockID(blockIDs []types.BlockID, blockID types.BlockID) bool {
+	for _, id := range blockIDs {
+		if id.Equals(blockID) {
+			return true
+		}
+	}
+	return false
+}
+
+func appendBlockID(blockIDs []types.BlockID, blockID types.BlockID) []types.BlockID {
+	if containsBlockID(blockIDs, blockID) {
+		return blockIDs
+	}
+	return append(blockIDs, blockID)
+}
@@ -0,0 +1,253 @@
+package protocols
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teragrid/dgrid/core/types"
+	"github.com/teragrid/dgrid/pkg/log"
+	sm "github.com/teragrid/dgrid/state"
+)
+
+func testBlockID(hash string) types.BlockID {
+	return types.BlockID{Hash: []byte(hash), PartsHeader: types.PartSetHeader{Total: 1, Hash: []byte(hash)}}
+}
+
+// newTestFBA returns an engine over n validators sharing a +2/3 quorum set,
+// only suitable to exercise federated voting.
+func newTestFBA(n int) (*FBAConsensus, []types.Address) {
+	valSet, _ := types.RandValidatorSet(n, 1)
+	qs := types.NewMajorityQuorumSet(valSet)
+	cs := &FBAConsensus{}
+	cs.Validators = valSet
+	cs.QuorumSet = qs
+	return cs, qs.Validators
+}
+
+func nominations(qs *types.QuorumSet, votes map[string][]types.BlockID, accepted map[string][]types.BlockID) map[string]*types.SCPStatement {
+	statements := make(map[string]*types.SCPStatement)
+	for _, addr := range qs.Validators {
+		key := string(addr)
+		if votes[key] == nil && accepted[key] == nil {
+			continue
+		}
+		statements[key] = &types.SCPStatement{
+			Type:             types.SCPNominateType,
+			ValidatorAddress: addr,
+			QuorumSet:        qs,
+			Votes:            votes[key],
+			Accepted:         accepted[key],
+		}
+	}
+	return statements
+}
+
+func TestFBAFederatedAccept(t *testing.T) {
+	cs, addrs := newTestFBA(4)
+	x := testBlockID("x")
+
+	// 2 votes out of 4: neither a quorum nor v-blocking accepted
+	votes := map[string][]types.BlockID{
+		string(addrs[0]): {x},
+		string(addrs[1]): {x},
+	}
+	statements := nominations(cs.QuorumSet, votes, nil)
+	assert.False(t, cs.federatedAccept(votesNominate(x), acceptsNominate(x), statements))
+
+	// a quorum voted
+	votes[string(addrs[2])] = []types.BlockID{x}
+	statements = nominations(cs.QuorumSet, votes, nil)
+	assert.True(t, cs.federatedAccept(votesNominate(x), acceptsNominate(x), statements))
+	assert.False(t, cs.federatedRatify(acceptsNominate(x), statements))
+
+	// a v-blocking set accepted
+	accepted := map[string][]types.BlockID{
+		string(addrs[2]): {x},
+		string(addrs[3]): {x},
+	}
+	statements = nominations(cs.QuorumSet, nil, accepted)
+	assert.True(t, cs.federatedAccept(votesNominate(x), acceptsNominate(x), statements))
+	assert.False(t, cs.federatedRatify(acceptsNominate(x), statements))
+
+	// a quorum accepted
+	accepted[string(addrs[0])] = []types.BlockID{x}
+	statements = nominations(cs.QuorumSet, nil, accepted)
+	assert.True(t, cs.federatedRatify(acceptsNominate(x), statements))
+}
+
+func TestFBAQuorumIgnoresUnsatisfiedNodes(t *testing.T) {
+	cs, addrs := newTestFBA(4)
+	x := testBlockID("x")
+
+	accepted := map[string][]types.BlockID{
+		string(addrs[0]): {x},
+		string(addrs[1]): {x},
+		string(addrs[2]): {x},
+	}
+	statements := nominations(cs.QuorumSet, nil, accepted)
+	// addrs[2] only trusts addrs[3], so its slice isn't satisfied
+	statements[string(addrs[2])].QuorumSet = &types.QuorumSet{Threshold: 1, Validators: []types.Address{addrs[3]}}
+	assert.False(t, cs.federatedRatify(acceptsNominate(x), statements))
+}
+
+func TestFBABallotStatementOrder(t *testing.T) {
+	x, y := testBlockID("x"), testBlockID("y")
+	prepare := &types.SCPStatement{Type: types.SCPPrepareType, Ballot: &types.SCPBallot{Counter: 1, Value: x}}
+	assert.True(t, isNewerBallotStatement(nil, prepare))
+
+	higher := &types.SCPStatement{Type: types.SCPPrepareType, Ballot: &types.SCPBallot{Counter: 2, Value: y}}
+	assert.True(t, isNewerBallotStatement(prepare, higher))
+	assert.False(t, isNewerBallotStatement(higher, prepare))
+
+	prepared := &types.SCPStatement{
+		Type:     types.SCPPrepareType,
+		Ballot:   &types.SCPBallot{Counter: 2, Value: y},
+		Prepared: &types.SCPBallot{Counter: 1, Value: y},
+	}
+	assert.True(t, isNewerBallotStatement(higher, prepared))
+
+	confirm := &types.SCPStatement{Type: types.SCPConfirmType, Ballot: &types.SCPBallot{Counter: 1, Value: x}}
+	assert.True(t, isNewerBallotStatement(prepared, confirm))
+
+	externalize := &types.SCPStatement{Type: types.SCPExternalizeType, Ballot: &types.SCPBallot{Counter: 1, Value: x}}
+	assert.True(t, isNewerBallotStatement(confirm, externalize))
+	assert.False(t, isNewerBallotStatement(externalize, externalize))
+}
+
+func TestFBAFindCommitRange(t *testing.T) {
+	counters := []int{5, 4, 3, 2, 1}
+	low, high, ok := findCommitRange(counters, func(n int) bool { return n >= 2 && n <= 4 })
+	assert.True(t, ok)
+	assert.Equal(t, 2, low)
+	assert.Equal(t, 4, high)
+
+	_, _, ok = findCommitRange(counters, func(n int) bool { return false })
+	assert.False(t, ok)
+}
+
+// newTestFBASlot returns an engine at height 1 over n validators sharing a
+// +2/3 quorum set, along with their signers, suitable to exercise the
+// proposals and precommits of a slot.
+func newTestFBASlot(n int) (*FBAConsensus, []types.Validator) {
+	valSet, pvs := types.RandValidatorSet(n, 1)
+	cs := &FBAConsensus{}
+	cs.BaseService.Logger = log.NewNopLogger()
+	cs.state = sm.State{LeagueID: "test_league"}
+	cs.Height = 1
+	cs.Validators = valSet
+	cs.QuorumSet = types.NewMajorityQuorumSet(valSet)
+	cs.ProposalBlockParts = make(map[string]*types.PartSet)
+	cs.Proposers = make(map[string]string)
+	cs.LatestBallots = make(map[string]*types.SCPStatement)
+	cs.Precommits = types.NewVoteSet(cs.state.LeagueID, 1, 0, types.PrecommitType, valSet)
+	return cs, pvs
+}
+
+func TestFBAOneProposalPerValidator(t *testing.T) {
+	cs, pvs := newTestFBASlot(4)
+	propose := func(pv types.Validator, blockID types.BlockID) error {
+		proposal := types.NewProposal(1, 0, -1, blockID)
+		require.NoError(t, pv.SignProposal(cs.state.LeagueID, proposal))
+		return cs.setProposal(proposal)
+	}
+
+	assert.NoError(t, propose(pvs[0], testBlockID("x")))
+	// the same proposal again is ignored
+	assert.NoError(t, propose(pvs[0], testBlockID("x")))
+	// a second block of the same validator is rejected
+	assert.Equal(t, ErrUnexpectedProposal, propose(pvs[0], testBlockID("y")))
+	assert.Len(t, cs.ProposalBlockParts, 1)
+
+	// while every other validator can still propose
+	assert.NoError(t, propose(pvs[1], testBlockID("y")))
+	assert.NoError(t, propose(pvs[2], testBlockID("z")))
+	assert.Len(t, cs.ProposalBlockParts, 3)
+
+	// and anyone else can't
+	outsider := types.NewMockPV()
+	assert.Equal(t, ErrInvalidProposalSignature, propose(outsider, testBlockID("w")))
+}
+
+func TestFBACertifiedValue(t *testing.T) {
+	cs, pvs := newTestFBASlot(4)
+	addrs := cs.QuorumSet.Validators
+	x := testBlockID("x")
+	precommit := func(i int) {
+		vote := &types.Vote{
+			ValidatorAddress: addrs[i],
+			ValidatorIndex:   i,
+			Height:           1,
+			Round:            0,
+			Type:             types.PrecommitType,
+			BlockID:          x,
+		}
+		require.NoError(t, pvs[i].SignVote(cs.state.LeagueID, vote))
+		added, err := cs.Precommits.AddVote(vote)
+		require.True(t, added)
+		require.NoError(t, err)
+	}
+
+	// our slices trust any single validator, which is neither v-blocking
+	// nor a quorum unless its own slice is satisfied
+	cs.QuorumSet = &types.QuorumSet{Threshold: 1, Validators: addrs}
+	precommit(0)
+	_, ok := cs.certifiedValue()
+	assert.False(t, ok, "no statement for the precommitter")
+
+	alone := &types.QuorumSet{Threshold: 1, Validators: []types.Address{addrs[0]}}
+	cs.quorumSets = map[string]*types.QuorumSet{string(addrs[0]): alone}
+	_, ok = cs.certifiedValue()
+	assert.True(t, ok, "the genesis slice of the precommitter is satisfied")
+
+	// the slices of the statements override those of the genesis file
+	cs.LatestBallots[string(addrs[0])] = &types.SCPStatement{
+		ValidatorAddress: addrs[0],
+		QuorumSet:        &types.QuorumSet{Threshold: 2, Validators: []types.Address{addrs[0], addrs[1]}},
+	}
+	_, ok = cs.certifiedValue()
+	assert.False(t, ok, "the slice of the precommitter isn't satisfied")
+
+	cs.LatestBallots[string(addrs[0])].QuorumSet = alone
+	blockID, ok := cs.certifiedValue()
+	assert.True(t, ok, "a quorum precommitted")
+	assert.Equal(t, x, blockID)
+
+	// with +2/3 slices, 2 precommits out of 4 are v-blocking even without
+	// any statement
+	cs.QuorumSet = types.NewMajorityQuorumSet(cs.Validators)
+	cs.LatestBallots = make(map[string]*types.SCPStatement)
+	cs.quorumSets = nil
+	_, ok = cs.certifiedValue()
+	assert.False(t, ok)
+	precommit(1)
+	blockID, ok = cs.certifiedValue()
+	assert.True(t, ok, "a v-blocking set precommitted")
+	assert.Equal(t, x, blockID)
+
+	// but the block is only committed with +2/3 of the voting power
+	_, ok = cs.Precommits.TwoThirdsMajority()
+	assert.False(t, ok)
+	precommit(2)
+	blockID, ok = cs.Precommits.TwoThirdsMajority()
+	assert.True(t, ok)
+	assert.Equal(t, x, blockID)
+}
+
+func TestFBALocalQuorumSet(t *testing.T) {
+	cs, pvs := newTestFBASlot(4)
+	addrs := cs.QuorumSet.Validators
+	declared := &types.QuorumSet{Threshold: 2, Validators: addrs[:2]}
+	cs.quorumSets = map[string]*types.QuorumSet{string(addrs[0]): declared}
+
+	// a node which isn't a genesis validator relies on +2/3 of the validators
+	assert.True(t, types.NewMajorityQuorumSet(cs.Validators).Equals(cs.localQuorumSet(cs.Validators)))
+
+	cs.validator = pvs[0]
+	assert.Equal(t, declared, cs.localQuorumSet(cs.Validators))
+
+	configured := &types.QuorumSet{Threshold: 1, Validators: addrs[:1]}
+	cs.quorumSet = configured
+	assert.Equal(t, configured, cs.localQuorumSet(cs.Validators))
+}
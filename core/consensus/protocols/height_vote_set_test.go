@@ -0,0 +1,85 @@
+package protocols
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/teragrid/dgrid/core/types"
+	ttime "github.com/teragrid/dgrid/core/types/time"
+)
+
+const testLeagueID = "test_league_id"
+
+func TestPeerCatchupRounds(t *testing.T) {
+	valSet, privVals := types.RandValidatorSet(10, 1)
+
+	hvs := NewHeightVoteSet(testLeagueID, 1, valSet)
+
+	vote999_0 := makeVoteHR(t, 1, 999, privVals, 0)
+	added, err := hvs.AddVote(vote999_0, "peer1")
+	if !added || err != nil {
+		t.Error("Expected to successfully add vote from peer", added, err)
+	}
+
+	vote1000_0 := makeVoteHR(t, 1, 1000, privVals, 0)
+	added, err = hvs.AddVote(vote1000_0, "peer1")
+	if !added || err != nil {
+		t.Error("Expected to successfully add vote from peer", added, err)
+	}
+
+	vote1001_0 := makeVoteHR(t, 1, 1001, privVals, 0)
+	added, err = hvs.AddVote(vote1001_0, "peer1")
+	if err != GotVoteFromUnwantedRoundError {
+		t.Errorf("Expected GotVoteFromUnwantedRoundError, but got %v", err)
+	}
+	if added {
+		t.Error("Expected to *not* add vote from peer, too many catchup rounds.")
+	}
+
+	added, err = hvs.AddVote(vote1001_0, "peer2")
+	if !added || err != nil {
+		t.Error("Expected to successfully add vote from another peer")
+	}
+}
+
+func TestHeightVoteSetRound(t *testing.T) {
+	valSet, _ := types.RandValidatorSet(4, 1)
+
+	hvs := NewHeightVoteSet(testLeagueID, 1, valSet)
+	hvs.SetRound(3)
+	if hvs.Round() != 3 {
+		t.Errorf("Expected round 3, got %v", hvs.Round())
+	}
+	for r := 0; r <= 3; r++ {
+		if hvs.Prevotes(r) == nil || hvs.Precommits(r) == nil {
+			t.Errorf("Expected vote sets for round %v", r)
+		}
+	}
+	if hvs.Prevotes(4) != nil {
+		t.Error("Expected no vote set for untracked round 4")
+	}
+	polRound, _ := hvs.POLInfo()
+	if polRound != -1 {
+		t.Errorf("Expected no POL round, got %v", polRound)
+	}
+}
+
+func makeVoteHR(t *testing.T, height int64, round int, privVals []types.Validator, valIndex int) *types.Vote {
+	privVal := privVals[valIndex]
+	addr := privVal.GetPubKey().Address()
+	vote := &types.Vote{
+		ValidatorAddress: addr,
+		ValidatorIndex:   valIndex,
+		Height:           height,
+		Round:            round,
+		Timestamp:        ttime.Now(),
+		Type:             types.PrecommitType,
+		BlockID:          types.BlockID{Hash: []byte("fakehash"), PartsHeader: types.PartSetHeader{}},
+	}
+	leagueID := testLeagueID
+	err := privVal.SignVote(leagueID, vote)
+	if err != nil {
+		panic(fmt.Sprintf("Error signing vote: %v", err))
+	}
+	return vote
+}
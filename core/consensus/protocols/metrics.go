@@ -0,0 +1,131 @@
+package protocols
+
+import (
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+	"github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/teragrid/dgrid/core/types"
+	sm "github.com/teragrid/dgrid/state"
+)
+
+const (
+	// MetricsSubsystem is a subsystem shared by all metrics exposed by this
+	// package.
+	MetricsSubsystem = "consensus"
+)
+
+// Metrics contains metrics exposed by this package.
+type Metrics struct {
+	// Height of the chain.
+	Height metrics.Gauge
+
+	// Number of rounds, or ballots in FBA leagues, before the commit.
+	Rounds metrics.Gauge
+
+	// Number of validators.
+	Validators metrics.Gauge
+	// Total power of all validators.
+	ValidatorsPower metrics.Gauge
+	// Number of validators who did not sign.
+	MissingValidators metrics.Gauge
+	// Total power of the missing validators.
+	MissingValidatorsPower metrics.Gauge
+
+	// Time between this and the last block.
+	BlockIntervalSeconds metrics.Gauge
+
+	// Number of transactions.
+	NumTxs metrics.Gauge
+	// Size of the block.
+	BlockSizeBytes metrics.Gauge
+	// Total number of transactions.
+	TotalTxs metrics.Gauge
+}
+
+// PrometheusMetrics returns Metrics build using Prometheus client library.
+// Optionally, labels can be provided along with their values ("foo",
+// "fooValue").
+func PrometheusMetrics(namespace string, labelsAndValues ...string) *Metrics {
+	labels := []string{}
+	for i := 0; i < len(labelsAndValues); i += 2 {
+		labels = append(labels, labelsAndValues[i])
+	}
+	gauge := func(name, help string) metrics.Gauge {
+		return prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: MetricsSubsystem,
+			Name:      name,
+			Help:      help,
+		}, labels).With(labelsAndValues...)
+	}
+	return &Metrics{
+		Height: gauge("height", "Height of the chain."),
+		Rounds: gauge("rounds", "Number of rounds, or ballots in FBA leagues, before the commit."),
+
+		Validators:             gauge("validators", "Number of validators."),
+		ValidatorsPower:        gauge("validators_power", "Total power of all validators."),
+		MissingValidators:      gauge("missing_validators", "Number of validators who did not sign."),
+		MissingValidatorsPower: gauge("missing_validators_power", "Total power of the missing validators."),
+
+		BlockIntervalSeconds: gauge("block_interval_seconds", "Time between this and the last block."),
+
+		NumTxs:         gauge("num_txs", "Number of transactions."),
+		BlockSizeBytes: gauge("block_size_bytes", "Size of the block."),
+		TotalTxs:       gauge("total_txs", "Total number of transactions."),
+	}
+}
+
+// NopMetrics returns no-op Metrics.
+func NopMetrics() *Metrics {
+	return &Metrics{
+		Height: discard.NewGauge(),
+		Rounds: discard.NewGauge(),
+
+		Validators:             discard.NewGauge(),
+		ValidatorsPower:        discard.NewGauge(),
+		MissingValidators:      discard.NewGauge(),
+		MissingValidatorsPower: discard.NewGauge(),
+
+		BlockIntervalSeconds: discard.NewGauge(),
+
+		NumTxs:         discard.NewGauge(),
+		BlockSizeBytes: discard.NewGauge(),
+		TotalTxs:       discard.NewGauge(),
+	}
+}
+
+// recordBlock records the metrics of a block committed after rounds rounds,
+// with state the state the block was committed on.
+func (m *Metrics) recordBlock(state sm.State, block *types.Block, rounds int) {
+	m.Rounds.Set(float64(rounds))
+	m.Validators.Set(float64(state.Validators.Size()))
+	m.ValidatorsPower.Set(float64(state.Validators.TotalVotingPower()))
+
+	// the validators who did not sign the last commit
+	missingValidators := 0
+	missingValidatorsPower := int64(0)
+	if block.LastCommit != nil && state.LastValidators != nil {
+		for i, precommit := range block.LastCommit.Precommits {
+			if precommit != nil {
+				continue
+			}
+			if _, val := state.LastValidators.GetByIndex(i); val != nil {
+				missingValidators++
+				missingValidatorsPower += val.VotingPower
+			}
+		}
+	}
+	m.MissingValidators.Set(float64(missingValidators))
+	m.MissingValidatorsPower.Set(float64(missingValidatorsPower))
+
+	if block.Height > 1 && !state.LastBlockTime.IsZero() {
+		m.BlockIntervalSeconds.Set(block.Time.Sub(state.LastBlockTime).Seconds())
+	}
+
+	m.NumTxs.Set(float64(block.NumTxs))
+	m.BlockSizeBytes.Set(float64(block.Size()))
+	m.TotalTxs.Set(float64(block.TotalTxs))
+	m.Height.Set(float64(block.Height))
+}
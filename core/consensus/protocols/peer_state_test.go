@@ -0,0 +1,44 @@
+package protocols
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/teragrid/dgrid/core/types"
+)
+
+func TestPeerStateApplyNewRoundStep(t *testing.T) {
+	ps := NewPeerState(nil)
+	ps.ApplyNewRoundStepMessage(&NewRoundStepMessage{Height: 5, Round: 0, Step: RoundStepPropose, LastCommitRound: 1})
+	ps.EnsureVoteBitArrays(5, 4)
+	ps.EnsureVoteBitArrays(4, 4)
+
+	prs := ps.GetRoundState()
+	assert.Equal(t, int64(5), prs.Height)
+	assert.Equal(t, 1, prs.LastCommitRound)
+
+	// the votes the peer has are not sent again
+	ps.SetHasVote(&types.Vote{Height: 5, Round: 0, Type: types.PrevoteType, ValidatorIndex: 2})
+	assert.True(t, ps.GetRoundState().Prevotes.GetIndex(2))
+	assert.False(t, ps.GetRoundState().Prevotes.GetIndex(1))
+
+	// a new round clears them
+	ps.ApplyNewRoundStepMessage(&NewRoundStepMessage{Height: 5, Round: 1, Step: RoundStepPropose, LastCommitRound: 1})
+	assert.Nil(t, ps.GetRoundState().Prevotes)
+
+	// older steps are ignored
+	ps.ApplyNewRoundStepMessage(&NewRoundStepMessage{Height: 5, Round: 0, Step: RoundStepPrecommit})
+	assert.Equal(t, 1, ps.GetRoundState().Round)
+}
+
+func TestPeerStateApplyHeight(t *testing.T) {
+	ps := NewPeerState(nil)
+	ps.ApplyHeight(3)
+	assert.Equal(t, int64(3), ps.GetHeight())
+
+	// the height of a peer never decreases
+	ps.ApplyHeight(2)
+	assert.Equal(t, int64(3), ps.GetHeight())
+	assert.Equal(t, -1, ps.GetRoundState().Round)
+}
@@ -0,0 +1,290 @@
+package protocols
+
+import (
+	"fmt"
+
+	"github.com/teragrid/dgrid/core/types"
+	dbm "github.com/teragrid/dgrid/pkg/db"
+	sm "github.com/teragrid/dgrid/state"
+)
+
+// executionPipeline executes the blocks committed by a BFTConsensus in the
+// background, when the league pipelines the execution (see
+// types.ExecutionParams), so the next height is proposed and voted while
+// the application executes the previous block. At most one block is in
+// execution: the execution of a block must end before the next one is
+// committed.
+//
+// The state of the next height is derived from the header of the committed
+// block, and from the state resulting from the execution of the block
+// before, see nextPipelinedState. The state resulting from the execution
+// of a block is saved by the block executor, and the pipelined states in
+// the state db, see pipelineRecord, so a restarted engine resumes the
+// pipeline with restore.
+type executionPipeline struct {
+	blockExec *sm.BlockExecutor
+	db        dbm.DB       // nil if the pipelined states aren't saved
+	pending   *pipelineJob // block in execution, nil if none
+}
+
+// pipelineJob is the execution of a committed block.
+type pipelineJob struct {
+	state   sm.State // the state the block was validated against
+	blockID types.BlockID
+	block   *types.Block // nil once restored, as executed
+
+	done   chan struct{} // closed once executed
+	result sm.State
+	err    error
+}
+
+/*
+pipelineRecord is the pipelined state of a height, saved in the state db
+of the league before its block is saved:
+
+	"pipeline:<height>" -> pipelineRecord
+
+The records of the last two heights are kept: the block of the last one may
+not be saved if the cell stopped in between.
+*/
+type pipelineRecord struct {
+	// State the block of the height was validated against.
+	State sm.State `json:"state"`
+	// Next is the state of the height after, see nextPipelinedState.
+	Next sm.State `json:"next"`
+}
+
+func keyPipelineRecord(height int64) []byte {
+	return []byte(fmt.Sprintf("pipeline:%v", height))
+}
+
+func loadPipelineRecord(db dbm.DB, height int64) *pipelineRecord {
+	bz := db.Get(keyPipelineRecord(height))
+	if len(bz) == 0 {
+		return nil
+	}
+	rec := new(pipelineRecord)
+	if err := cdc.UnmarshalBinaryBare(bz, rec); err != nil {
+		panic(fmt.Sprintf("Error reading pipelined state of height %d: %v", height, err))
+	}
+	return rec
+}
+
+func newExecutionPipeline(blockExec *sm.BlockExecutor, db dbm.DB) *executionPipeline {
+	return &executionPipeline{blockExec: blockExec, db: db}
+}
+
+// restore resumes the pipeline of a restarted engine. state is the state
+// saved by the block executor, resulting from the execution of the last
+// block. It returns the pipelined state of the next height.
+func (p *executionPipeline) restore(state sm.State) (sm.State, error) {
+	if p.db == nil || state.LastBlockHeight == 0 {
+		return state, nil
+	}
+	rec := loadPipelineRecord(p.db, state.LastBlockHeight)
+	if rec == nil {
+		return state, fmt.Errorf("Pipelined state of height %d not found", state.LastBlockHeight)
+	}
+	// the last block is executed, its updates apply to the next height
+	done := make(chan struct{})
+	close(done)
+	p.pending = &pipelineJob{state: rec.State, blockID: state.LastBlockID, done: done, result: state}
+	return rec.Next, nil
+}
+
+// commit saves the state of the next height of a committed block,
+// validated against state, once the block in execution, if any, is
+// executed. The block is executed by execute, once saved. It returns the
+// state of the next height.
+func (p *executionPipeline) commit(state sm.State, blockID types.BlockID, block *types.Block) (sm.State, error) {
+	// the state before the first execution is the one of the genesis,
+	// or the one saved by the executor
+	executedFrom, executed := state, state
+	if prev := p.wait(); prev != nil {
+		if prev.err != nil {
+			return state, prev.err
+		}
+		executedFrom, executed = prev.state, prev.result
+	}
+	next, err := nextPipelinedState(state, executedFrom, executed, blockID, block)
+	if err != nil {
+		return state, err
+	}
+
+	if p.db != nil {
+		batch := p.db.NewBatch()
+		defer batch.Close()
+		batch.Set(keyPipelineRecord(block.Height), cdc.MustMarshalBinaryBare(&pipelineRecord{State: state, Next: next}))
+		batch.Delete(keyPipelineRecord(block.Height - 2))
+		batch.WriteSync()
+	}
+	p.pending = &pipelineJob{state: state.Copy(), blockID: blockID, block: block, done: make(chan struct{})}
+	return next, nil
+}
+
+// execute starts the execution of the block committed last.
+func (p *executionPipeline) execute() {
+	job := p.pending
+	go func() {
+		job.result, job.err = p.blockExec.ApplyBlock(job.state.Copy(), job.blockID, job.block)
+		close(job.done)
+	}()
+}
+
+// ReplayPipelinedBlock executes the last block of a league pipelining the
+// execution if the cell stopped before its execution ended, and returns the
+// resulting state, or state if there is none. The block is validated
+// against its pipelined state, which the handshake doesn't know, so
+// ReplayPipelinedBlock must be called before the handshake, with state the
+// state saved by the block executor.
+func ReplayPipelinedBlock(stateDB dbm.DB, state sm.State, blockStore sm.BlockStore,
+	blockExec *sm.BlockExecutor) (sm.State, error) {
+	height := state.LastBlockHeight + 1
+	if !state.ConsensusParams.Execution.Pipelined || blockStore.Height() != height {
+		return state, nil
+	}
+	rec := loadPipelineRecord(stateDB, height)
+	if rec == nil {
+		return state, fmt.Errorf("Pipelined state of height %d not found", height)
+	}
+	meta := blockStore.LoadBlockMeta(height)
+	block := blockStore.LoadBlock(height)
+	if meta == nil || block == nil {
+		return state, fmt.Errorf("Block %d not found", height)
+	}
+	return blockExec.ApplyBlock(rec.State, meta.BlockID, block)
+}
+
+// wait waits for the end of the execution of the block in execution, and
+// returns it, or nil if no block is in execution.
+func (p *executionPipeline) wait() *pipelineJob {
+	job := p.pending
+	if job == nil {
+		return nil
+	}
+	<-job.done
+	p.pending = nil
+	return job
+}
+
+// drain waits for the end of the execution of the block in execution, and
+// returns next, the state of the following height, as if the execution
+// weren't pipelined: with the AppHash, the LastResultsHash and the updates
+// of the executed block. The engine of another protocol, or one which
+// doesn't pipeline, can take over the league from there.
+func (p *executionPipeline) drain(next sm.State) (sm.State, error) {
+	job := p.wait()
+	if job == nil {
+		return next, nil
+	}
+	if job.err != nil {
+		return next, job.err
+	}
+	next = next.Copy()
+	height := job.state.LastBlockHeight + 1 // of the executed block
+	if changes := validatorChanges(job.state.NextValidators, job.result.NextValidators); len(changes) > 0 {
+		if err := next.NextValidators.UpdateWithChangeSet(changes); err != nil {
+			return next, err
+		}
+		next.LastHeightValidatorsChanged = height + 2
+	}
+	if !job.result.ConsensusParams.Equals(&job.state.ConsensusParams) {
+		next.ConsensusParams = job.result.ConsensusParams
+		next.LastHeightConsensusParamsChanged = height + 1
+	}
+	next.AppHash = job.result.AppHash
+	next.LastResultsHash = job.result.LastResultsHash
+	return next, nil
+}
+
+// pendingTxs returns the txs of the block in execution, which are still in
+// the storage until the block is executed.
+func (p *executionPipeline) pendingTxs() types.Txs {
+	if p.pending == nil || p.pending.block == nil {
+		return nil
+	}
+	return p.pending.block.Txs
+}
+
+// nextPipelinedState returns the state of the height following block,
+// validated against state, without executing it. executed is the state
+// resulting from the execution of the block before, which was validated
+// against executedFrom. The AppHash and LastResultsHash are those of
+// executed, so they lag a height behind the ones of a league which doesn't
+// pipeline. The validator updates of the block before, ie. the difference
+// between the next validators of executedFrom and executed, are applied to
+// the next validators, so they take effect a height later than without
+// pipelining. Likewise for the updates of the consensus params.
+func nextPipelinedState(state, executedFrom, executed sm.State, blockID types.BlockID, block *types.Block) (sm.State, error) {
+	next := state.Copy()
+	next.LastBlockHeight = block.Height
+	next.LastBlockID = blockID
+	next.LastBlockTime = block.Time
+	next.LastBlockTotalTx = state.LastBlockTotalTx + block.NumTxs
+
+	nextValidators := state.NextValidators.Copy()
+	if changes := validatorChanges(executedFrom.NextValidators, executed.NextValidators); len(changes) > 0 {
+		if err := nextValidators.UpdateWithChangeSet(changes); err != nil {
+			return state, err
+		}
+		next.LastHeightValidatorsChanged = block.Height + 2
+	}
+	nextValidators.IncrementProposerPriority(1)
+	next.LastValidators = state.Validators.Copy()
+	next.Validators = state.NextValidators.Copy()
+	next.NextValidators = nextValidators
+
+	if !executed.ConsensusParams.Equals(&executedFrom.ConsensusParams) {
+		next.ConsensusParams = executed.ConsensusParams
+		next.LastHeightConsensusParamsChanged = block.Height + 1
+	}
+	next.AppHash = executed.AppHash
+	next.LastResultsHash = executed.LastResultsHash
+	return next, nil
+}
+
+// validatorChanges returns the changes turning the validator set from into
+// to: the validators added or with a new voting power, and the ones removed
+// with a voting power of 0.
+func validatorChanges(from, to *types.ValidatorSet) []*types.Validator {
+	var changes []*types.Validator
+	for _, val := range to.Validators {
+		if _, old := from.GetByAddress(val.Address); old == nil || old.VotingPower != val.VotingPower {
+			changes = append(changes, val.Copy())
+		}
+	}
+	for _, val := range from.Validators {
+		if !to.HasAddress(val.Address) {
+			changes = append(changes, types.NewValidator(val.PubKey, 0))
+		}
+	}
+	return changes
+}
+
+// withoutTxs returns block without the given txs, and its parts.
+func withoutTxs(block *types.Block, blockParts *types.PartSet, txs types.Txs) (*types.Block, *types.PartSet) {
+	if len(txs) == 0 {
+		return block, blockParts
+	}
+	kept := make(types.Txs, 0, len(block.Txs))
+	for _, tx := range block.Txs {
+		if txs.Index(tx) < 0 {
+			kept = append(kept, tx)
+		}
+	}
+	if len(kept) == len(block.Txs) {
+		return block, blockParts
+	}
+	return blockWithTxs(block, kept)
+}
+
+// blockWithTxs returns a copy of block with other txs, and its parts.
+func blockWithTxs(block *types.Block, txs types.Txs) (*types.Block, *types.PartSet) {
+	b := types.MakeBlock(block.Height, txs, block.LastCommit, block.Evidence.Evidence)
+	header := block.Header
+	header.TotalTxs += b.NumTxs - block.NumTxs
+	header.NumTxs = b.NumTxs
+	header.DataHash = b.DataHash
+	b.Header = header
+	return b, b.MakePartSet(types.BlockPartSizeBytes)
+}
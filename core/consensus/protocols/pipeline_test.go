@@ -0,0 +1,140 @@
+package protocols
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teragrid/dgrid/core/types"
+	dbm "github.com/teragrid/dgrid/pkg/db"
+	sm "github.com/teragrid/dgrid/state"
+)
+
+func TestValidatorChanges(t *testing.T) {
+	from, _ := types.RandValidatorSet(3, 10)
+	assert.Empty(t, validatorChanges(from, from.Copy()))
+
+	added, _ := types.RandValidator(false, 5)
+	to := from.Copy()
+	require.NoError(t, to.UpdateWithChangeSet([]*types.Validator{
+		added,
+		types.NewValidator(from.Validators[0].PubKey, 0),
+		types.NewValidator(from.Validators[1].PubKey, 20),
+	}))
+
+	// the changes turn from into to
+	changes := validatorChanges(from, to)
+	assert.Len(t, changes, 3)
+	updated := from.Copy()
+	require.NoError(t, updated.UpdateWithChangeSet(changes))
+	assert.Equal(t, to.Hash(), updated.Hash())
+}
+
+func TestNextPipelinedState(t *testing.T) {
+	vals, _ := types.RandValidatorSet(2, 10)
+	state := sm.State{
+		LeagueID:         "pipeline",
+		LastBlockHeight:  4,
+		LastBlockTotalTx: 7,
+		LastValidators:   vals.Copy(),
+		Validators:       vals.Copy(),
+		NextValidators:   vals.Copy(),
+		ConsensusParams:  *types.DefaultConsensusParams(),
+		AppHash:          []byte("app hash 2"),
+	}
+
+	// the block before added a validator, and changed the params
+	executedFrom := state.Copy()
+	executed := state.Copy()
+	added, _ := types.RandValidator(false, 5)
+	require.NoError(t, executed.NextValidators.UpdateWithChangeSet([]*types.Validator{added}))
+	executed.ConsensusParams.Block.MaxGas++
+	executed.AppHash = []byte("app hash 3")
+	executed.LastResultsHash = []byte("results hash 3")
+
+	block := types.MakeBlock(5, types.Txs{types.Tx("a"), types.Tx("b")}, nil, nil)
+	blockID := types.BlockID{Hash: block.Hash()}
+	next, err := nextPipelinedState(state, executedFrom, executed, blockID, block)
+	require.NoError(t, err)
+
+	assert.EqualValues(t, 5, next.LastBlockHeight)
+	assert.Equal(t, blockID, next.LastBlockID)
+	assert.EqualValues(t, 9, next.LastBlockTotalTx)
+
+	// the hashes and the updates lag a height behind
+	assert.Equal(t, []byte("app hash 3"), next.AppHash)
+	assert.Equal(t, []byte("results hash 3"), next.LastResultsHash)
+	assert.Equal(t, vals.Size(), next.Validators.Size())
+	assert.Equal(t, vals.Size()+1, next.NextValidators.Size())
+	assert.EqualValues(t, 7, next.LastHeightValidatorsChanged)
+	assert.Equal(t, executed.ConsensusParams, next.ConsensusParams)
+	assert.EqualValues(t, 6, next.LastHeightConsensusParamsChanged)
+
+	// state itself is unchanged
+	assert.EqualValues(t, 4, state.LastBlockHeight)
+	assert.Equal(t, vals.Size(), state.NextValidators.Size())
+}
+
+func TestExecutionPipelineRestore(t *testing.T) {
+	vals, _ := types.RandValidatorSet(2, 10)
+	state := sm.State{
+		LeagueID:        "pipeline",
+		LastBlockHeight: 4,
+		LastValidators:  vals.Copy(),
+		Validators:      vals.Copy(),
+		NextValidators:  vals.Copy(),
+		ConsensusParams: *types.DefaultConsensusParams(),
+		AppHash:         []byte("app hash 3"),
+	}
+	db := dbm.NewMemDB()
+	block := types.MakeBlock(5, types.Txs{types.Tx("a")}, nil, nil)
+	blockID := types.BlockID{Hash: block.Hash()}
+	next, err := newExecutionPipeline(nil, db).commit(state, blockID, block)
+	require.NoError(t, err)
+
+	// the cell restarts once the block is executed, which added a validator
+	executed := state.Copy()
+	executed.LastBlockHeight = 5
+	executed.LastBlockID = blockID
+	added, _ := types.RandValidator(false, 5)
+	require.NoError(t, executed.NextValidators.UpdateWithChangeSet([]*types.Validator{added}))
+	executed.AppHash = []byte("app hash 5")
+
+	p := newExecutionPipeline(nil, db)
+	restored, err := p.restore(executed)
+	require.NoError(t, err)
+	assert.Equal(t, next.LastBlockID, restored.LastBlockID)
+	assert.Equal(t, next.AppHash, restored.AppHash)
+	assert.Nil(t, p.pendingTxs())
+
+	// the updates of the executed block apply from the next one
+	block = types.MakeBlock(6, nil, nil, nil)
+	next, err = p.commit(restored, types.BlockID{Hash: block.Hash()}, block)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("app hash 5"), next.AppHash)
+	assert.Equal(t, vals.Size()+1, next.NextValidators.Size())
+
+	// the pipelined state of the last block is missing
+	executed.LastBlockHeight = 8
+	_, err = newExecutionPipeline(nil, db).restore(executed)
+	assert.Error(t, err)
+}
+
+func TestWithoutTxs(t *testing.T) {
+	txs := types.Txs{types.Tx("a"), types.Tx("b"), types.Tx("c")}
+	block := types.MakeBlock(3, txs, nil, nil)
+	block.TotalTxs = 10
+	parts := block.MakePartSet(types.BlockPartSizeBytes)
+
+	same, sameParts := withoutTxs(block, parts, types.Txs{types.Tx("d")})
+	assert.True(t, same == block)
+	assert.True(t, sameParts == parts)
+
+	filtered, filteredParts := withoutTxs(block, parts, types.Txs{types.Tx("b")})
+	assert.Equal(t, types.Txs{types.Tx("a"), types.Tx("c")}, filtered.Txs)
+	assert.EqualValues(t, 2, filtered.NumTxs)
+	assert.EqualValues(t, 9, filtered.TotalTxs)
+	assert.Equal(t, filtered.Data.Hash(), filtered.DataHash)
+	assert.True(t, filteredParts.HasHeader(filtered.MakePartSet(types.BlockPartSizeBytes).Header()))
+}
@@ -0,0 +1,801 @@
+package protocols
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/teragrid/dgrid/core/blockchain/p2p"
+	"github.com/teragrid/dgrid/core/blockchain/p2p/conn"
+	"github.com/teragrid/dgrid/core/types"
+	cmn "github.com/teragrid/dgrid/pkg/common"
+	"github.com/teragrid/dgrid/pkg/log"
+	sm "github.com/teragrid/dgrid/state"
+)
+
+const (
+	reactorListenerID = "ConsensusReactor"
+
+	// how long the gossip routines of a peer wait for the engine to be a
+	// GossipEngine, eg. once the league migrated to BFT
+	gossipEngineSleepDuration = 1 * time.Second
+)
+
+// ConsensusReactor connects a ConsensusEngine of any protocol to the p2p
+// switch. Messages received from peers are handed to the engine. The
+// reactor keeps the PeerState of every peer, and for a GossipEngine, runs
+// routines gossiping the proposal, the block parts and the votes each peer
+// is missing, which lets the peers which missed a message or lag behind
+// catch up. The messages the other engines broadcast are relayed to all
+// the peers; engines only broadcast what they didn't know yet, so each
+// message crosses every connection at most once per direction.
+//
+// The engine may be replaced with SwitchEngine when the league migrates to
+// another protocol, so the reactor uses the channels of every protocol.
+type ConsensusReactor struct {
+	p2p.BaseReactor // BaseService + p2p.Switch
+
+	mtx      sync.RWMutex
+	engine   ConsensusEngine
+	fastSync bool
+	eventBus *types.EventBus
+
+	// starts the engine, if it isn't started by the reactor itself
+	startEngineFn func() error
+}
+
+// ReactorOption sets an optional parameter on the ConsensusReactor.
+type ReactorOption func(*ConsensusReactor)
+
+// ReactorEngineStarter makes the reactor start its engine with start, eg.
+// through the consensus manager running the engine, once it doesn't fast
+// sync. The engine is then stopped by whoever runs it, not by the reactor.
+func ReactorEngineStarter(start func() error) ReactorOption {
+	return func(conR *ConsensusReactor) { conR.startEngineFn = start }
+}
+
+// NewConsensusReactor returns a new ConsensusReactor for the given engine.
+func NewConsensusReactor(engine ConsensusEngine, fastSync bool, options ...ReactorOption) *ConsensusReactor {
+	conR := &ConsensusReactor{
+		engine:   engine,
+		fastSync: fastSync,
+	}
+	conR.BaseReactor = *p2p.NewBaseReactor("ConsensusReactor", conR)
+	for _, option := range options {
+		option(conR)
+	}
+	return conR
+}
+
+// SetLogger implements Service.
+func (conR *ConsensusReactor) SetLogger(l log.Logger) {
+	conR.BaseReactor.SetLogger(l)
+	conR.Engine().SetLogger(l)
+}
+
+// OnStart implements BaseService by starting the engine,
+// unless we are fast syncing.
+func (conR *ConsensusReactor) OnStart() error {
+	engine := conR.Engine()
+	conR.Logger.Info("ConsensusReactor ", "fastSync", conR.FastSync(), "protocol", engine.Protocol())
+
+	if err := conR.listen(engine); err != nil {
+		return err
+	}
+	if !conR.FastSync() {
+		if err := conR.startEngine(engine); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OnStop implements BaseService by stopping the engine, unless it is run
+// by someone else, see ReactorEngineStarter.
+func (conR *ConsensusReactor) OnStop() {
+	engine := conR.Engine()
+	engine.RemoveBroadcastListener(reactorListenerID)
+	if conR.startEngineFn == nil && engine.IsRunning() {
+		engine.Stop()
+		engine.Wait()
+	}
+}
+
+// startEngine starts the engine, unless it is running already.
+func (conR *ConsensusReactor) startEngine(engine ConsensusEngine) error {
+	if conR.startEngineFn != nil {
+		return conR.startEngineFn()
+	}
+	if engine.IsRunning() {
+		return nil
+	}
+	return engine.Start()
+}
+
+// SwitchToConsensus switches from fast_sync mode to consensus mode.
+// It resets the engine to the synced state and starts it.
+func (conR *ConsensusReactor) SwitchToConsensus(state sm.State, blocksSynced int) {
+	conR.Logger.Info("SwitchToConsensus", "height", state.LastBlockHeight+1, "blocksSynced", blocksSynced)
+	engine := conR.Engine()
+	engine.SwitchToState(state)
+
+	conR.mtx.Lock()
+	conR.fastSync = false
+	conR.mtx.Unlock()
+
+	if err := conR.startEngine(engine); err != nil {
+		panic(fmt.Sprintf("Failed to start consensus engine: %v", err))
+	}
+	// let the peers know we're done fast syncing
+	if ge, ok := engine.(GossipEngine); ok {
+		conR.broadcastNewRoundStepMessage(ge.GetRoundState())
+	}
+}
+
+// SwitchEngine replaces the engine of the reactor, eg. once the league
+// migrated to another consensus protocol. The old engine must be halted or
+// stopped. The new one is started, unless it is running already or the
+// reactor is fast syncing.
+func (conR *ConsensusReactor) SwitchEngine(engine ConsensusEngine) error {
+	conR.mtx.Lock()
+	old := conR.engine
+	conR.engine = engine
+	fastSync, eventBus := conR.fastSync, conR.eventBus
+	conR.mtx.Unlock()
+
+	old.RemoveBroadcastListener(reactorListenerID)
+	engine.SetLogger(conR.Logger)
+	if eventBus != nil {
+		engine.SetEventBus(eventBus)
+	}
+	conR.Logger.Info("Switched consensus engine", "from", old.Protocol(), "to", engine.Protocol())
+
+	if !conR.IsRunning() {
+		return nil
+	}
+	if err := conR.listen(engine); err != nil {
+		return err
+	}
+	if !fastSync {
+		return conR.startEngine(engine)
+	}
+	return nil
+}
+
+// GetChannels implements Reactor.
+func (conR *ConsensusReactor) GetChannels() []*conn.ChannelDescriptor {
+	return AllChannels()
+}
+
+// AddPeer implements Reactor.
+// It sets the PeerState of the peer and starts its gossip routines.
+func (conR *ConsensusReactor) AddPeer(peer p2p.Peer) {
+	if !conR.IsRunning() {
+		return
+	}
+
+	peerState := NewPeerState(peer).SetLogger(conR.Logger.With("peer", peer))
+	peer.Set(types.PeerStateKey, peerState)
+
+	// Begin routines for this peer.
+	go conR.gossipDataRoutine(peer, peerState)
+	go conR.gossipVotesRoutine(peer, peerState)
+	go conR.queryMaj23Routine(peer, peerState)
+
+	// Send our state to peer.
+	// If we're fast_syncing, broadcast a RoundStepMessage later upon SwitchToConsensus().
+	if !conR.FastSync() {
+		conR.sendNewRoundStepMessage(peer)
+	}
+}
+
+// Receive implements Reactor.
+// The messages about the round state of the peer update its PeerState.
+// NOTE: We process the other messages even when we're fast_syncing, as the
+// engine drops the ones which are not for its height.
+func (conR *ConsensusReactor) Receive(chID byte, src p2p.Peer, msgBytes []byte) {
+	if !conR.IsRunning() {
+		conR.Logger.Debug("Receive", "src", src, "chId", chID, "bytes", msgBytes)
+		return
+	}
+
+	msg, err := decodeMsg(msgBytes)
+	if err != nil {
+		conR.Logger.Error("Error decoding message", "src", src, "chId", chID, "msg", msg, "err", err, "bytes", msgBytes)
+		conR.Switch.StopPeerForError(src, err)
+		return
+	}
+	if err = msg.ValidateBasic(); err != nil {
+		conR.Logger.Error("Peer sent us invalid msg", "peer", src, "msg", msg, "err", err)
+		conR.Switch.StopPeerForError(src, err)
+		return
+	}
+	if messageChannel(msg) != chID {
+		conR.Logger.Error("Peer sent us a message on the wrong channel", "peer", src, "chId", chID, "msg", msg)
+		conR.Switch.StopPeerForError(src, ErrUnexpectedMessage)
+		return
+	}
+	conR.Logger.Debug("Receive", "src", src, "chId", chID, "msg", msg)
+
+	ps, ok := src.Get(types.PeerStateKey).(*PeerState)
+	if !ok {
+		conR.Logger.Error("Peer has no state", "peer", src)
+		return
+	}
+
+	switch msg := msg.(type) {
+	case *NewRoundStepMessage:
+		ps.ApplyNewRoundStepMessage(msg)
+		return
+	case *NewValidBlockMessage:
+		ps.ApplyNewValidBlockMessage(msg)
+		return
+	case *HasVoteMessage:
+		ps.ApplyHasVoteMessage(msg)
+		return
+	case *VoteSetMaj23Message:
+		conR.receiveVoteSetMaj23(msg, src)
+		return
+	}
+
+	if conR.FastSync() {
+		return
+	}
+	engine := conR.Engine()
+
+	switch msg := msg.(type) {
+	case *ProposalPOLMessage:
+		ps.ApplyProposalPOLMessage(msg)
+		return
+	case *VoteSetBitsMessage:
+		var ourVotes *cmn.BitArray
+		if ge, ok := engine.(GossipEngine); ok {
+			ourVotes = ge.VoteBitArray(msg.Height, msg.Round, msg.Type, msg.BlockID)
+		}
+		ps.ApplyVoteSetBitsMessage(msg, ourVotes)
+		return
+	case *ProposalMessage:
+		ps.SetHasProposal(msg.Proposal)
+	case *BlockPartMessage:
+		ps.SetHasProposalBlockPart(msg.Height, msg.Round, msg.Part.Index)
+		ps.RecordBlockPart()
+	case *VoteMessage:
+		if ge, ok := engine.(GossipEngine); ok {
+			rs := ge.GetRoundState()
+			ps.EnsureVoteBitArrays(rs.Height, rs.Validators.Size())
+			ps.EnsureVoteBitArrays(rs.Height-1, rs.LastCommit.Size())
+		}
+		ps.SetHasVote(msg.Vote)
+		ps.RecordVote()
+	case *SCPStatementMessage:
+		// peers only relay the statements of their height
+		ps.ApplyHeight(msg.Statement.Height)
+	}
+
+	err = engine.ReceiveMessage(msg, types.P2PID(src.ID()))
+	if err == ErrUnexpectedMessage {
+		// the peer may run another protocol until the league migrates
+		conR.Logger.Debug("Dropping message of another protocol", "peer", src, "msg", msg)
+	} else if err != nil {
+		conR.Logger.Error("Peer sent us a message the engine can't handle", "peer", src, "msg", msg, "err", err)
+		conR.Switch.StopPeerForError(src, err)
+	}
+}
+
+// receiveVoteSetMaj23 records the +2/3 majority a peer claims, and responds
+// with the votes we have for it.
+func (conR *ConsensusReactor) receiveVoteSetMaj23(msg *VoteSetMaj23Message, src p2p.Peer) {
+	engine, ok := conR.Engine().(GossipEngine)
+	if !ok {
+		return
+	}
+	err := engine.SetPeerMaj23(msg.Height, msg.Round, msg.Type, types.P2PID(src.ID()), msg.BlockID)
+	if err != nil {
+		conR.Switch.StopPeerForError(src, err)
+		return
+	}
+	// Respond with a VoteSetBitsMessage showing which votes we have.
+	// (and consequently shows which we don't have)
+	ourVotes := engine.VoteBitArray(msg.Height, msg.Round, msg.Type, msg.BlockID)
+	if ourVotes == nil {
+		return
+	}
+	src.TrySend(VoteSetBitsChannel, cdc.MustMarshalBinaryBare(&VoteSetBitsMessage{
+		Height:  msg.Height,
+		Round:   msg.Round,
+		Type:    msg.Type,
+		BlockID: msg.BlockID,
+		Votes:   ourVotes,
+	}))
+}
+
+// SetEventBus sets the event bus of the engine.
+func (conR *ConsensusReactor) SetEventBus(b *types.EventBus) {
+	conR.mtx.Lock()
+	conR.eventBus = b
+	engine := conR.engine
+	conR.mtx.Unlock()
+	engine.SetEventBus(b)
+}
+
+// FastSync returns whether the consensus reactor is in fast-sync mode.
+func (conR *ConsensusReactor) FastSync() bool {
+	conR.mtx.RLock()
+	defer conR.mtx.RUnlock()
+	return conR.fastSync
+}
+
+// Engine returns the consensus engine of the reactor.
+func (conR *ConsensusReactor) Engine() ConsensusEngine {
+	conR.mtx.RLock()
+	defer conR.mtx.RUnlock()
+	return conR.engine
+}
+
+// listen registers the reactor for the broadcasts of engine, and for the
+// changes of its round state if it is a GossipEngine.
+func (conR *ConsensusReactor) listen(engine ConsensusEngine) error {
+	ge, gossip := engine.(GossipEngine)
+	err := engine.AddBroadcastListener(reactorListenerID, func(b *Broadcast) {
+		// the gossip routines send the messages of a GossipEngine to the
+		// peers missing them, unless they are for some peers only
+		if gossip && b.Peers == nil {
+			return
+		}
+		conR.broadcast(b)
+	})
+	if err != nil || !gossip {
+		return err
+	}
+	return ge.AddRoundStateListener(reactorListenerID, RoundStateListener{
+		OnNewRoundStep: conR.broadcastNewRoundStepMessage,
+		OnValidBlock:   conR.broadcastNewValidBlockMessage,
+		OnVote:         conR.broadcastHasVoteMessage,
+	})
+}
+
+// broadcast relays a message of the engine to all the peers, or to the
+// ones it selects.
+func (conR *ConsensusReactor) broadcast(b *Broadcast) {
+	if conR.Switch == nil {
+		return
+	}
+	msgBytes := cdc.MustMarshalBinaryBare(b.Msg)
+	if b.Peers == nil {
+		conR.Switch.Broadcast(b.ChannelID, msgBytes)
+		return
+	}
+	for _, peer := range conR.Switch.Peers().List() {
+		if b.Peers(types.P2PID(peer.ID())) {
+			go peer.Send(b.ChannelID, msgBytes)
+		}
+	}
+}
+
+func (conR *ConsensusReactor) broadcastNewRoundStepMessage(rs *RoundState) {
+	if conR.Switch == nil {
+		return
+	}
+	nrsMsg := makeRoundStepMessage(rs)
+	conR.Switch.Broadcast(StateChannel, cdc.MustMarshalBinaryBare(nrsMsg))
+}
+
+func (conR *ConsensusReactor) broadcastNewValidBlockMessage(rs *RoundState) {
+	if conR.Switch == nil {
+		return
+	}
+	csMsg := &NewValidBlockMessage{
+		Height:           rs.Height,
+		Round:            rs.Round,
+		BlockPartsHeader: rs.ProposalBlockParts.Header(),
+		BlockParts:       rs.ProposalBlockParts.BitArray(),
+		IsCommit:         rs.Step == RoundStepCommit,
+	}
+	conR.Switch.Broadcast(StateChannel, cdc.MustMarshalBinaryBare(csMsg))
+}
+
+// Broadcasts HasVoteMessage to peers that care.
+func (conR *ConsensusReactor) broadcastHasVoteMessage(vote *types.Vote) {
+	if conR.Switch == nil {
+		return
+	}
+	msg := &HasVoteMessage{
+		Height: vote.Height,
+		Round:  vote.Round,
+		Type:   vote.Type,
+		Index:  vote.ValidatorIndex,
+	}
+	conR.Switch.Broadcast(StateChannel, cdc.MustMarshalBinaryBare(msg))
+}
+
+func makeRoundStepMessage(rs *RoundState) (nrsMsg *NewRoundStepMessage) {
+	nrsMsg = &NewRoundStepMessage{
+		Height:                rs.Height,
+		Round:                 rs.Round,
+		Step:                  rs.Step,
+		SecondsSinceStartTime: int(time.Since(rs.StartTime).Seconds()),
+		LastCommitRound:       rs.LastCommit.Round(),
+	}
+	return
+}
+
+func (conR *ConsensusReactor) sendNewRoundStepMessage(peer p2p.Peer) {
+	engine, ok := conR.Engine().(GossipEngine)
+	if !ok {
+		return
+	}
+	nrsMsg := makeRoundStepMessage(engine.GetRoundState())
+	peer.Send(StateChannel, cdc.MustMarshalBinaryBare(nrsMsg))
+}
+
+// gossipEngine returns the engine if it is a GossipEngine, or sleeps and
+// returns false.
+func (conR *ConsensusReactor) gossipEngine() (GossipEngine, bool) {
+	engine, ok := conR.Engine().(GossipEngine)
+	if !ok || conR.FastSync() {
+		time.Sleep(gossipEngineSleepDuration)
+		return nil, false
+	}
+	return engine, true
+}
+
+func (conR *ConsensusReactor) gossipDataRoutine(peer p2p.Peer, ps *PeerState) {
+	logger := conR.Logger.With("peer", peer)
+
+OUTER_LOOP:
+	for {
+		// Manage disconnects from self or peer.
+		if !peer.IsRunning() || !conR.IsRunning() {
+			logger.Info("Stopping gossipDataRoutine for peer")
+			return
+		}
+		engine, ok := conR.gossipEngine()
+		if !ok {
+			continue OUTER_LOOP
+		}
+		gossipSleep, _ := engine.PeerGossipSleepDurations()
+		rs := engine.GetRoundState()
+		prs := ps.GetRoundState()
+
+		// Send proposal Block parts?
+		if rs.ProposalBlockParts.HasHeader(prs.ProposalBlockPartsHeader) {
+			if index, ok := rs.ProposalBlockParts.BitArray().Sub(prs.ProposalBlockParts.Copy()).PickRandom(); ok {
+				part := rs.ProposalBlockParts.GetPart(index)
+				msg := &BlockPartMessage{
+					Height: rs.Height, // This tells peer that this part applies to us.
+					Round:  rs.Round,  // This tells peer that this part applies to us.
+					Part:   part,
+				}
+				logger.Debug("Sending block part", "height", prs.Height, "round", prs.Round)
+				if peer.Send(DataChannel, cdc.MustMarshalBinaryBare(msg)) {
+					ps.SetHasProposalBlockPart(prs.Height, prs.Round, index)
+				}
+				continue OUTER_LOOP
+			}
+		}
+
+		// If the peer is on a previous height, help catch up.
+		if (0 < prs.Height) && (prs.Height < rs.Height) {
+			heightLogger := logger.With("height", prs.Height)
+
+			// if we never received the commit message from the peer, the block parts wont be initialized
+			if prs.ProposalBlockParts == nil {
+				blockMeta := engine.LoadBlockMeta(prs.Height)
+				if blockMeta == nil {
+					heightLogger.Error("Failed to load block meta", "ourHeight", rs.Height)
+					time.Sleep(gossipSleep)
+					continue OUTER_LOOP
+				}
+				ps.InitProposalBlockParts(blockMeta.BlockID.PartsHeader)
+				// continue the loop since prs is a copy and not effected by this initialization
+				continue OUTER_LOOP
+			}
+			conR.gossipDataForCatchup(heightLogger, engine, rs, prs, ps, peer)
+			continue OUTER_LOOP
+		}
+
+		// If height and round don't match, sleep.
+		if (rs.Height != prs.Height) || (rs.Round != prs.Round) {
+			time.Sleep(gossipSleep)
+			continue OUTER_LOOP
+		}
+
+		// By here, height and round match.
+		// Proposal block parts were already matched and sent if any were wanted.
+		// (These can match on hash so the round doesn't matter)
+		// Now consider sending other things, like the Proposal itself.
+
+		// Send Proposal && ProposalPOL BitArray?
+		if rs.Proposal != nil && !prs.Proposal {
+			// Proposal: share the proposal metadata with peer.
+			{
+				msg := &ProposalMessage{Proposal: rs.Proposal}
+				logger.Debug("Sending proposal", "height", prs.Height, "round", prs.Round)
+				if peer.Send(DataChannel, cdc.MustMarshalBinaryBare(msg)) {
+					// NOTE: A peer might have received different proposal msg so this Proposal msg will be rejected!
+					ps.SetHasProposal(rs.Proposal)
+				}
+			}
+			// ProposalPOL: lets peer know which POL votes we have so far.
+			// Peer must receive ProposalMessage first.
+			// rs.Proposal was validated, so rs.Proposal.POLRound <= rs.Round,
+			// so we definitely have rs.Votes.Prevotes(rs.Proposal.POLRound).
+			if 0 <= rs.Proposal.POLRound {
+				msg := &ProposalPOLMessage{
+					Height:           rs.Height,
+					ProposalPOLRound: rs.Proposal.POLRound,
+					ProposalPOL:      rs.Votes.Prevotes(rs.Proposal.POLRound).BitArray(),
+				}
+				logger.Debug("Sending POL", "height", prs.Height, "round", prs.Round)
+				peer.Send(DataChannel, cdc.MustMarshalBinaryBare(msg))
+			}
+			continue OUTER_LOOP
+		}
+
+		// Nothing to do. Sleep.
+		time.Sleep(gossipSleep)
+		continue OUTER_LOOP
+	}
+}
+
+func (conR *ConsensusReactor) gossipDataForCatchup(logger log.Logger, engine GossipEngine, rs *RoundState,
+	prs *PeerRoundState, ps *PeerState, peer p2p.Peer) {
+	gossipSleep, _ := engine.PeerGossipSleepDurations()
+
+	if index, ok := prs.ProposalBlockParts.Not().PickRandom(); ok {
+		// Ensure that the peer's PartSetHeader is correct
+		blockMeta := engine.LoadBlockMeta(prs.Height)
+		if blockMeta == nil {
+			logger.Error("Failed to load block meta", "ourHeight", rs.Height)
+			time.Sleep(gossipSleep)
+			return
+		} else if !blockMeta.BlockID.PartsHeader.Equals(prs.ProposalBlockPartsHeader) {
+			logger.Info("Peer ProposalBlockPartsHeader mismatch, sleeping",
+				"blockPartsHeader", blockMeta.BlockID.PartsHeader, "peerBlockPartsHeader", prs.ProposalBlockPartsHeader)
+			time.Sleep(gossipSleep)
+			return
+		}
+		// Load the part
+		part := engine.LoadBlockPart(prs.Height, index)
+		if part == nil {
+			logger.Error("Could not load part", "index", index,
+				"blockPartsHeader", blockMeta.BlockID.PartsHeader, "peerBlockPartsHeader", prs.ProposalBlockPartsHeader)
+			time.Sleep(gossipSleep)
+			return
+		}
+		// Send the part
+		msg := &BlockPartMessage{
+			Height: prs.Height, // Not our height, so it doesn't matter.
+			Round:  prs.Round,  // Not our height, so it doesn't matter.
+			Part:   part,
+		}
+		logger.Debug("Sending block part for catchup", "round", prs.Round, "index", index)
+		if peer.Send(DataChannel, cdc.MustMarshalBinaryBare(msg)) {
+			ps.SetHasProposalBlockPart(prs.Height, prs.Round, index)
+		} else {
+			logger.Debug("Sending block part for catchup failed")
+		}
+		return
+	}
+	time.Sleep(gossipSleep)
+}
+
+func (conR *ConsensusReactor) gossipVotesRoutine(peer p2p.Peer, ps *PeerState) {
+	logger := conR.Logger.With("peer", peer)
+
+	// Simple hack to throttle logs upon sleep.
+	var sleeping = 0
+
+OUTER_LOOP:
+	for {
+		// Manage disconnects from self or peer.
+		if !peer.IsRunning() || !conR.IsRunning() {
+			logger.Info("Stopping gossipVotesRoutine for peer")
+			return
+		}
+		engine, ok := conR.gossipEngine()
+		if !ok {
+			continue OUTER_LOOP
+		}
+		gossipSleep, _ := engine.PeerGossipSleepDurations()
+		rs := engine.GetRoundState()
+		prs := ps.GetRoundState()
+
+		switch sleeping {
+		case 1: // First sleep
+			sleeping = 2
+		case 2: // No more sleep
+			sleeping = 0
+		}
+
+		// If height matches, then send LastCommit, Prevotes, Precommits.
+		if rs.Height == prs.Height {
+			heightLogger := logger.With("height", prs.Height)
+			if conR.gossipVotesForHeight(heightLogger, rs, prs, ps) {
+				continue OUTER_LOOP
+			}
+		}
+
+		// Special catchup logic.
+		// If peer is lagging by height 1, send LastCommit.
+		if prs.Height != 0 && rs.Height == prs.Height+1 {
+			if ps.PickSendVote(rs.LastCommit) {
+				logger.Debug("Picked rs.LastCommit to send", "height", prs.Height)
+				continue OUTER_LOOP
+			}
+		}
+
+		// Catchup logic
+		// If peer is lagging by more than 1, send Commit.
+		if prs.Height != 0 && rs.Height >= prs.Height+2 {
+			// Load the block commit for prs.Height,
+			// which contains precommit signatures for prs.Height.
+			if commit := engine.LoadCommit(prs.Height); commit != nil && ps.PickSendVote(commit) {
+				logger.Debug("Picked Catchup commit to send", "height", prs.Height)
+				continue OUTER_LOOP
+			}
+		}
+
+		if sleeping == 0 {
+			// We sent nothing. Sleep...
+			sleeping = 1
+			logger.Debug("No votes to send, sleeping", "rs.Height", rs.Height, "prs.Height", prs.Height,
+				"localPV", rs.Votes.Prevotes(rs.Round).BitArray(), "peerPV", prs.Prevotes,
+				"localPC", rs.Votes.Precommits(rs.Round).BitArray(), "peerPC", prs.Precommits)
+		} else if sleeping == 2 {
+			// Continued sleep...
+			sleeping = 1
+		}
+
+		time.Sleep(gossipSleep)
+		continue OUTER_LOOP
+	}
+}
+
+func (conR *ConsensusReactor) gossipVotesForHeight(logger log.Logger, rs *RoundState, prs *PeerRoundState,
+	ps *PeerState) bool {
+
+	// If there are lastCommits to send...
+	if prs.Step == RoundStepNewHeight {
+		if ps.PickSendVote(rs.LastCommit) {
+			logger.Debug("Picked rs.LastCommit to send")
+			return true
+		}
+	}
+	// If there are POL prevotes to send...
+	if prs.Step <= RoundStepPropose && prs.Round != -1 && prs.Round <= rs.Round && prs.ProposalPOLRound != -1 {
+		if polPrevotes := rs.Votes.Prevotes(prs.ProposalPOLRound); polPrevotes != nil {
+			if ps.PickSendVote(polPrevotes) {
+				logger.Debug("Picked rs.Prevotes(prs.ProposalPOLRound) to send",
+					"round", prs.ProposalPOLRound)
+				return true
+			}
+		}
+	}
+	// If there are prevotes to send...
+	if prs.Step <= RoundStepPrevoteWait && prs.Round != -1 && prs.Round <= rs.Round {
+		if ps.PickSendVote(rs.Votes.Prevotes(prs.Round)) {
+			logger.Debug("Picked rs.Prevotes(prs.Round) to send", "round", prs.Round)
+			return true
+		}
+	}
+	// If there are precommits to send...
+	if prs.Step <= RoundStepPrecommitWait && prs.Round != -1 && prs.Round <= rs.Round {
+		if ps.PickSendVote(rs.Votes.Precommits(prs.Round)) {
+			logger.Debug("Picked rs.Precommits(prs.Round) to send", "round", prs.Round)
+			return true
+		}
+	}
+	// If there are prevotes to send...Needed because of validBlock mechanism
+	if prs.Round != -1 && prs.Round <= rs.Round {
+		if ps.PickSendVote(rs.Votes.Prevotes(prs.Round)) {
+			logger.Debug("Picked rs.Prevotes(prs.Round) to send", "round", prs.Round)
+			return true
+		}
+	}
+	// If there are POLPrevotes to send...
+	if prs.ProposalPOLRound != -1 {
+		if polPrevotes := rs.Votes.Prevotes(prs.ProposalPOLRound); polPrevotes != nil {
+			if ps.PickSendVote(polPrevotes) {
+				logger.Debug("Picked rs.Prevotes(prs.ProposalPOLRound) to send",
+					"round", prs.ProposalPOLRound)
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// NOTE: `queryMaj23Routine` has a simple crude design since it only comes
+// into play for liveness when there's a signature DDoS attack happening.
+func (conR *ConsensusReactor) queryMaj23Routine(peer p2p.Peer, ps *PeerState) {
+	logger := conR.Logger.With("peer", peer)
+
+OUTER_LOOP:
+	for {
+		// Manage disconnects from self or peer.
+		if !peer.IsRunning() || !conR.IsRunning() {
+			logger.Info("Stopping queryMaj23Routine for peer")
+			return
+		}
+		engine, ok := conR.gossipEngine()
+		if !ok {
+			continue OUTER_LOOP
+		}
+		_, querySleep := engine.PeerGossipSleepDurations()
+
+		// Maybe send Height/Round/Prevotes
+		{
+			rs := engine.GetRoundState()
+			prs := ps.GetRoundState()
+			if rs.Height == prs.Height {
+				if queryMaj23(peer, prs.Height, prs.Round, types.PrevoteType, rs.Votes.Prevotes(prs.Round)) {
+					time.Sleep(querySleep)
+				}
+			}
+		}
+
+		// Maybe send Height/Round/Precommits
+		{
+			rs := engine.GetRoundState()
+			prs := ps.GetRoundState()
+			if rs.Height == prs.Height {
+				if queryMaj23(peer, prs.Height, prs.Round, types.PrecommitType, rs.Votes.Precommits(prs.Round)) {
+					time.Sleep(querySleep)
+				}
+			}
+		}
+
+		// Maybe send Height/Round/ProposalPOL
+		{
+			rs := engine.GetRoundState()
+			prs := ps.GetRoundState()
+			if rs.Height == prs.Height && prs.ProposalPOLRound >= 0 {
+				if queryMaj23(peer, prs.Height, prs.ProposalPOLRound, types.PrevoteType,
+					rs.Votes.Prevotes(prs.ProposalPOLRound)) {
+					time.Sleep(querySleep)
+				}
+			}
+		}
+
+		// Little point sending LastCommitRound/LastCommit,
+		// These are fleeting and non-blocking.
+
+		// Maybe send Height/CatchupCommitRound/CatchupCommit.
+		{
+			prs := ps.GetRoundState()
+			if prs.CatchupCommitRound != -1 && 0 < prs.Height && prs.Height <= engine.GetLastHeight() {
+				if commit := engine.LoadCommit(prs.Height); commit != nil {
+					peer.TrySend(StateChannel, cdc.MustMarshalBinaryBare(&VoteSetMaj23Message{
+						Height:  prs.Height,
+						Round:   commit.Round(),
+						Type:    types.PrecommitType,
+						BlockID: commit.BlockID,
+					}))
+					time.Sleep(querySleep)
+				}
+			}
+		}
+
+		time.Sleep(querySleep)
+
+		continue OUTER_LOOP
+	}
+}
+
+// queryMaj23 tells the peer about the +2/3 majority of votes, if any, and
+// returns whether there is one.
+func queryMaj23(peer p2p.Peer, height int64, round int, voteType types.SignedMsgType, votes *types.VoteSet) bool {
+	maj23, ok := votes.TwoThirdsMajority()
+	if !ok {
+		return false
+	}
+	peer.TrySend(StateChannel, cdc.MustMarshalBinaryBare(&VoteSetMaj23Message{
+		Height:  height,
+		Round:   round,
+		Type:    voteType,
+		BlockID: maj23,
+	}))
+	return true
+}
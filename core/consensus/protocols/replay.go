@@ -0,0 +1,44 @@
+package protocols
+
+import (
+	"fmt"
+	"io"
+)
+
+// catchupReplay replays the messages of the WAL written after the end of
+// the height before the given one, so an engine restarting after a crash
+// resumes the height in flight in the round and step it was in, with the
+// messages it received and the votes it signed. The validator signs the
+// same votes again, and refuses to sign conflicting ones.
+func catchupReplay(wal WAL, height int64, replay func(msg WALMessage) error) error {
+	// the messages of a committed height must not be replayed
+	dec, found, err := wal.SearchForEndHeight(height)
+	if err != nil {
+		return err
+	}
+	if found {
+		dec.Close()
+		return fmt.Errorf("WAL should not contain EndHeightMessage %d", height)
+	}
+
+	dec, found, err = wal.SearchForEndHeight(height - 1)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("Cannot replay height %d. WAL does not contain EndHeightMessage for %d", height, height-1)
+	}
+	defer dec.Close()
+
+	for {
+		msg, err := dec.Decode()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := replay(msg.Msg); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package protocols
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrWALNotStarted is returned when the WAL has no marker
+// for the beginning of the chain.
+var ErrWALNotStarted = errors.New("Error WAL has no EndHeightMessage for height 0")
+
+// RunReplayFile replays the messages of a WAL against an engine of any
+// protocol, from the beginning of the WAL. The engine must not be started.
+//
+// In console mode, messages are replayed on demand. The console reads
+// commands from stdin:
+//
+//	next [N]  replay the next N messages (default: 1)
+//	rs        print the internal state of the engine
+//	rs short  print a summary of the internal state of the engine
+//	n         print the number of messages replayed so far
+//	quit      exit
+func RunReplayFile(engine ConsensusEngine, wal WAL, console bool) error {
+	dec, found, err := wal.SearchForEndHeight(0)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrWALNotStarted
+	}
+	defer dec.Close()
+
+	pb := &playback{engine: engine, dec: dec}
+	if console {
+		return pb.replayConsoleLoop(os.Stdin, os.Stdout)
+	}
+	for {
+		if err := pb.replayNext(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// playback replays the messages of a WAL one by one.
+type playback struct {
+	engine ConsensusEngine
+	dec    WALDecoder
+	count  int // how many messages were replayed
+}
+
+func (pb *playback) replayNext() error {
+	msg, err := pb.dec.Decode()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d: %v\n", pb.count, msg)
+	if err := pb.engine.ReplayMessage(msg.Msg); err != nil {
+		return err
+	}
+	pb.count++
+	return nil
+}
+
+func (pb *playback) replayConsoleLoop(in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, "> ")
+		if !scanner.Scan() {
+			return scanner.Err()
+		}
+		tokens := strings.Fields(scanner.Text())
+		if len(tokens) == 0 {
+			continue
+		}
+
+		switch tokens[0] {
+		case "next":
+			n := 1
+			if len(tokens) > 1 {
+				i, err := strconv.Atoi(tokens[1])
+				if err != nil || i < 1 {
+					fmt.Fprintln(out, "next takes a positive integer argument")
+					continue
+				}
+				n = i
+			}
+			for ; n > 0; n-- {
+				if err := pb.replayNext(); err == io.EOF {
+					fmt.Fprintln(out, "Reached the end of the WAL")
+					break
+				} else if err != nil {
+					return err
+				}
+			}
+		case "rs":
+			var (
+				bz  []byte
+				err error
+			)
+			if len(tokens) > 1 && tokens[1] == "short" {
+				bz, err = pb.engine.GetRoundStateSimpleJSON()
+			} else {
+				bz, err = pb.engine.GetRoundStateJSON()
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(out, string(bz))
+		case "n":
+			fmt.Fprintln(out, pb.count)
+		case "quit":
+			return nil
+		default:
+			fmt.Fprintf(out, "Unknown command %q\n", tokens[0])
+		}
+	}
+}
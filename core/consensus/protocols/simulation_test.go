@@ -0,0 +1,299 @@
+package protocols
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cfg "github.com/teragrid/dgrid/core/config"
+	"github.com/teragrid/dgrid/core/types"
+)
+
+var simProtocols = map[string]cfg.ConsensusProtocol{
+	"BFT": cfg.BFTConsensusProtocol,
+	"FBA": cfg.FBAConsensusProtocol,
+}
+
+func TestSimulationNoFaults(t *testing.T) {
+	for name, protocol := range simProtocols {
+		t.Run(name, func(t *testing.T) {
+			runSimulation(t, simConfig{
+				Protocol:   protocol,
+				Validators: 4,
+				Faults:     simFaults{MinDelay: 10 * time.Millisecond, MaxDelay: 100 * time.Millisecond},
+				Heights:    5,
+				MaxTime:    time.Minute,
+			})
+		})
+	}
+}
+
+func TestSimulationLossyNetwork(t *testing.T) {
+	for name, protocol := range simProtocols {
+		t.Run(name, func(t *testing.T) {
+			runSimulation(t, simConfig{
+				Protocol:   protocol,
+				Validators: 4,
+				Faults: simFaults{
+					DropRate: 0.05,
+					MinDelay: 10 * time.Millisecond,
+					MaxDelay: 2 * time.Second,
+					Reorder:  true,
+				},
+				Heights: 3,
+				MaxTime: 10 * time.Minute,
+			})
+		})
+	}
+}
+
+func TestSimulationPartition(t *testing.T) {
+	for name, protocol := range simProtocols {
+		t.Run(name, func(t *testing.T) {
+			// no quorum on either side until the partition heals
+			runSimulation(t, simConfig{
+				Protocol:   protocol,
+				Validators: 4,
+				Faults: simFaults{
+					MinDelay:   10 * time.Millisecond,
+					MaxDelay:   100 * time.Millisecond,
+					Partitions: []simPartition{{From: 0, To: 30 * time.Second, Groups: [][]int{{0, 1}, {2, 3}}}},
+				},
+				Heights: 3,
+				MaxTime: 5 * time.Minute,
+			})
+		})
+	}
+}
+
+func TestSimulationCrash(t *testing.T) {
+	for name, protocol := range simProtocols {
+		t.Run(name, func(t *testing.T) {
+			runSimulation(t, simConfig{
+				Protocol:   protocol,
+				Validators: 4,
+				Faults: simFaults{
+					MinDelay: 10 * time.Millisecond,
+					MaxDelay: 100 * time.Millisecond,
+					Crashes:  map[int]time.Duration{0: 2 * time.Second},
+				},
+				Heights: 4,
+				MaxTime: 5 * time.Minute,
+			})
+		})
+	}
+}
+
+func TestSimulationEquivocation(t *testing.T) {
+	config := simConfig{
+		Protocol:   cfg.BFTConsensusProtocol,
+		Validators: 4,
+		Faults: simFaults{
+			MinDelay:     10 * time.Millisecond,
+			MaxDelay:     100 * time.Millisecond,
+			Equivocators: []int{0},
+		},
+		Heights: 3,
+		MaxTime: 5 * time.Minute,
+	}
+	runSimulation(t, config)
+
+	// the honest nodes receiving both votes report the equivocator
+	sim, err := simulate(config, 1)
+	require.NoError(t, err)
+	reported := false
+	for _, n := range sim.nodes[1:] {
+		for _, ev := range n.evpool.evidence {
+			dve, ok := ev.(*types.DuplicateVoteEvidence)
+			require.True(t, ok)
+			assert.Equal(t, sim.nodes[0].pv.GetPubKey(), dve.PubKey)
+			reported = true
+		}
+	}
+	assert.True(t, reported, "No evidence of the equivocation")
+}
+
+func TestSimulationObservers(t *testing.T) {
+	for name, protocol := range simProtocols {
+		t.Run(name, func(t *testing.T) {
+			config := simConfig{
+				Protocol:   protocol,
+				Validators: 4,
+				Observers:  2,
+				Faults:     simFaults{MinDelay: 10 * time.Millisecond, MaxDelay: 100 * time.Millisecond},
+				Heights:    3,
+				MaxTime:    5 * time.Minute,
+			}
+			if protocol == cfg.BFTConsensusProtocol {
+				// the observer 5 gets the conflicting votes too
+				config.Faults.Equivocators = []int{0}
+			}
+			sim, err := simulate(config, 1)
+			require.NoError(t, err)
+			for _, n := range sim.nodes[4:] {
+				assert.True(t, n.committed >= config.Heights, "observer %d at height %d", n.index, n.committed)
+			}
+			if protocol == cfg.BFTConsensusProtocol {
+				assert.NotEmpty(t, sim.nodes[5].evpool.evidence, "No evidence of the equivocation")
+			}
+		})
+	}
+}
+
+func TestBFTNotValidator(t *testing.T) {
+	sim, err := newSimulator(simConfig{Protocol: cfg.BFTConsensusProtocol, Validators: 1, Observers: 1}, 1)
+	require.NoError(t, err)
+	defer sim.stop()
+
+	cs := sim.nodes[1].engine.(*BFTConsensus)
+	assert.Nil(t, cs.validatorAddress())
+	_, err = cs.signVote(types.PrevoteType, nil, types.PartSetHeader{})
+	assert.Equal(t, ErrNotValidator, err)
+	assert.Nil(t, cs.signAddVote(types.PrevoteType, nil, types.PartSetHeader{}))
+	block, _ := cs.createProposalBlock()
+	assert.Nil(t, block)
+
+	// the observer goes through the first round without signing anything
+	sim.handle(sim.nodes[1], func() {
+		cs.enterNewRound(1, 0)
+		cs.enterPrevote(1, 0)
+		cs.enterPrecommit(1, 0)
+	})
+	assert.EqualValues(t, RoundStepPrecommit, cs.GetRoundState().Step)
+	for _, ev := range sim.events {
+		assert.False(t, ev.from == sim.nodes[1], "the observer sent a message")
+	}
+}
+
+func TestSimulationByzantine(t *testing.T) {
+	for _, behavior := range cfg.ByzantineBehaviors {
+		t.Run(behavior, func(t *testing.T) {
+			config := simConfig{
+				Protocol:   cfg.BFTConsensusProtocol,
+				Validators: 4,
+				Faults: simFaults{
+					MinDelay:  10 * time.Millisecond,
+					MaxDelay:  100 * time.Millisecond,
+					Byzantine: map[int][]string{0: {behavior}},
+				},
+				Heights: 3,
+				MaxTime: 5 * time.Minute,
+			}
+			runSimulation(t, config)
+
+			if behavior != cfg.ByzantineDoublePrevote && behavior != cfg.ByzantineDoublePrecommit {
+				return
+			}
+			// the conflicting votes reach the peers of both halves
+			sim, err := simulate(config, 1)
+			require.NoError(t, err)
+			reported := false
+			for _, n := range sim.nodes[1:] {
+				for _, ev := range n.evpool.evidence {
+					dve, ok := ev.(*types.DuplicateVoteEvidence)
+					require.True(t, ok)
+					assert.Equal(t, sim.nodes[0].pv.GetPubKey(), dve.PubKey)
+					reported = true
+				}
+			}
+			assert.True(t, reported, "No evidence of the double vote")
+		})
+	}
+}
+
+func TestByzantinePeerHalves(t *testing.T) {
+	// both halves get some of the peers of the simulations
+	var halves [2]int
+	for i := 1; i < 4; i++ {
+		halves[byzantineHalf(types.P2PID(fmt.Sprintf("node%d", i)))]++
+	}
+	assert.NotZero(t, halves[0])
+	assert.NotZero(t, halves[1])
+	for _, id := range []types.P2PID{"node1", "node2"} {
+		assert.NotEqual(t, honestPeers(id), conflictingPeers(id))
+	}
+}
+
+func TestSimulationRandomProposer(t *testing.T) {
+	params := types.DefaultConsensusParams()
+	params.Proposer.Selection = types.ProposerSelectionRandom
+	config := simConfig{
+		Protocol:   cfg.BFTConsensusProtocol,
+		Validators: 4,
+		Faults: simFaults{
+			DropRate: 0.05,
+			MinDelay: 10 * time.Millisecond,
+			MaxDelay: 100 * time.Millisecond,
+		},
+		ConsensusParams: params,
+		Heights:         5,
+		MaxTime:         5 * time.Minute,
+	}
+	runSimulation(t, config)
+
+	// the proposers of the committed blocks are verified from the headers
+	sim, err := simulate(config, 1)
+	require.NoError(t, err)
+	n := sim.nodes[0]
+	vals := n.engine.GetState().Validators
+	var lastCommitHash []byte
+	for height := int64(1); height < n.blockStore.Height(); height++ {
+		header := n.blockStore.LoadBlockMeta(height).Header
+		commit := n.blockStore.LoadBlockCommit(height)
+		assert.NoError(t, vals.VerifyRandomProposer(lastCommitHash, height, commit.Round(), header.ProposerAddress))
+		lastCommitHash = header.LastCommitHash
+	}
+}
+
+func TestSimulationPipelined(t *testing.T) {
+	params := types.DefaultConsensusParams()
+	params.Execution.Pipelined = true
+	config := simConfig{
+		Protocol:   cfg.BFTConsensusProtocol,
+		Validators: 4,
+		Faults: simFaults{
+			DropRate: 0.05,
+			MinDelay: 10 * time.Millisecond,
+			MaxDelay: 100 * time.Millisecond,
+		},
+		ConsensusParams: params,
+		Heights:         5,
+		MaxTime:         5 * time.Minute,
+	}
+	runSimulation(t, config)
+
+	// the headers stay consistent while the execution lags behind
+	sim, err := simulate(config, 1)
+	require.NoError(t, err)
+	n := sim.nodes[0]
+	for height := int64(3); height <= n.blockStore.Height(); height++ {
+		header := n.blockStore.LoadBlockMeta(height).Header
+		prev := n.blockStore.LoadBlockMeta(height - 1).Header
+		assert.EqualValues(t, prev.TotalTxs+header.NumTxs, header.TotalTxs)
+		assert.NotEmpty(t, header.AppHash, "height %d", height)
+	}
+}
+
+func TestSimulationDeterminism(t *testing.T) {
+	config := simConfig{
+		Protocol:   cfg.BFTConsensusProtocol,
+		Validators: 4,
+		Faults: simFaults{
+			DropRate: 0.05,
+			MinDelay: 10 * time.Millisecond,
+			MaxDelay: time.Second,
+			Reorder:  true,
+		},
+		Heights: 3,
+		MaxTime: 5 * time.Minute,
+	}
+	sim1, err := simulate(config, 42)
+	require.NoError(t, err)
+	sim2, err := simulate(config, 42)
+	require.NoError(t, err)
+	assert.Equal(t, sim1.trace, sim2.trace)
+	assert.NotEmpty(t, sim1.trace)
+}
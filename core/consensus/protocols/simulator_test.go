@@ -0,0 +1,642 @@
+package protocols
+
+import (
+	"bytes"
+	"container/heap"
+	"flag"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/teragrid/dgrid/asura/example/kvstore"
+	bc "github.com/teragrid/dgrid/core/blockchain"
+	cfg "github.com/teragrid/dgrid/core/config"
+	"github.com/teragrid/dgrid/core/types"
+	ttime "github.com/teragrid/dgrid/core/types/time"
+	"github.com/teragrid/dgrid/pkg/crypto/ed25519"
+	"github.com/teragrid/dgrid/pkg/crypto/tmhash"
+	dbm "github.com/teragrid/dgrid/pkg/db"
+	"github.com/teragrid/dgrid/pkg/log"
+	"github.com/teragrid/dgrid/proxy"
+	sm "github.com/teragrid/dgrid/state"
+)
+
+/*
+The simulator runs the validators of a league in a single goroutine, on a
+virtual clock. The engines don't run their receive routines: every input,
+a message from a peer or a timeout, is an event of a queue ordered by
+virtual time, and the simulator hands the events to the engines one at a
+time, as their receive routines would. The messages are those the engines
+broadcast: there is no reactor gossiping the votes and block parts again,
+so a lost message stays lost. The delivery delays, the losses and
+the order of the messages are drawn from a seeded source, so a simulation
+only depends on its seed, and a failing seed can be replayed with
+
+	go test ./core/consensus/protocols -run TestSimulation -sim.seed=<seed>
+*/
+
+var (
+	simSeed = flag.Int64("sim.seed", 0, "seed of the consensus simulations, 0 to run sim.runs seeds")
+	simRuns = flag.Int("sim.runs", 5, "number of seeds each consensus simulation is run with")
+)
+
+const simLeagueID = "sim-league"
+
+// simEpoch is the genesis time of the simulated leagues.
+var simEpoch = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// simPartition splits the network from From to To, measured from the
+// genesis: the messages between nodes of different groups are lost. The
+// nodes missing from the groups form a group of their own.
+type simPartition struct {
+	From, To time.Duration
+	Groups   [][]int
+}
+
+// simFaults are the faults injected in a simulation.
+type simFaults struct {
+	DropRate           float64       // probability a message is lost
+	MinDelay, MaxDelay time.Duration // delivery delay of a message, drawn uniformly
+	Reorder            bool          // messages may overtake each other, FIFO links otherwise
+
+	Partitions []simPartition
+	// nodes signing a conflicting vote or proposal for each one they sign,
+	// and sending it to half of their peers
+	Equivocators []int
+	// nodes running the byzantine test mode of the engine, with the
+	// given behaviors
+	Byzantine map[int][]string
+	// nodes crashing for good, at the given time after the genesis
+	Crashes map[int]time.Duration
+}
+
+// simConfig configures a simulation.
+type simConfig struct {
+	Protocol   cfg.ConsensusProtocol
+	Validators int
+	// full nodes without a validator key, following the league
+	Observers int
+	Faults    simFaults
+	// consensus params of the genesis, the default ones if nil
+	ConsensusParams *types.ConsensusParams
+	// liveness: a +2/3 majority of the nodes must commit Heights blocks
+	// within MaxTime of virtual time
+	Heights int64
+	MaxTime time.Duration
+}
+
+// runSimulation runs a simulation with sim.runs seeds, or the one given
+// with -sim.seed, and reports the seeds violating safety or liveness.
+func runSimulation(t *testing.T, config simConfig) {
+	seeds := []int64{*simSeed}
+	if *simSeed == 0 {
+		seeds = seeds[:0]
+		for i := 1; i <= *simRuns; i++ {
+			seeds = append(seeds, int64(i))
+		}
+	}
+	for _, seed := range seeds {
+		if _, err := simulate(config, seed); err != nil {
+			t.Errorf("Seed %d: %v (replay with -sim.seed=%d)", seed, err, seed)
+		}
+	}
+}
+
+// simulate runs a simulation with a seed, and returns its trace.
+func simulate(config simConfig, seed int64) (*simulator, error) {
+	sim, err := newSimulator(config, seed)
+	if err != nil {
+		return nil, err
+	}
+	defer sim.stop()
+	return sim, sim.run()
+}
+
+//-----------------------------------------------------------------------------
+
+// simulator is a deterministic, in-memory network of validators.
+type simulator struct {
+	config simConfig
+	rng    *rand.Rand
+	now    time.Time
+	events simEventQueue
+	seq    int64
+
+	nodes        []*simNode
+	lastDelivery map[[2]int]time.Time // last delivery on each link, for FIFO links
+
+	commits map[int64]types.BlockID // blocks committed, by height
+	trace   []string                // commits of every node, in order
+	err     error                   // safety violation
+
+	restoreClock func()
+}
+
+func newSimulator(config simConfig, seed int64) (*simulator, error) {
+	sim := &simulator{
+		config:       config,
+		rng:          rand.New(rand.NewSource(seed)),
+		now:          simEpoch,
+		lastDelivery: make(map[[2]int]time.Time),
+		commits:      make(map[int64]types.BlockID),
+	}
+	sim.restoreClock = ttime.SetClock(func() time.Time { return sim.now })
+
+	pvs := make([]*types.MockPV, config.Validators)
+	genDoc := &types.GenesisDoc{GenesisTime: simEpoch, LeagueID: simLeagueID, ConsensusParams: config.ConsensusParams}
+	for i := range pvs {
+		privKey := ed25519.GenPrivKeyFromSecret([]byte(fmt.Sprintf("sim-validator-%d", i)))
+		pvs[i] = types.NewMockPVWithParams(privKey, false, false)
+		genDoc.Validators = append(genDoc.Validators, types.GenesisValidator{
+			Address: privKey.PubKey().Address(),
+			PubKey:  privKey.PubKey(),
+			Power:   10,
+		})
+	}
+	state, err := sm.MakeGenesisState(genDoc)
+	if err != nil {
+		sim.restoreClock()
+		return nil, err
+	}
+
+	for i := 0; i < config.Observers; i++ {
+		pvs = append(pvs, nil)
+	}
+	for i, pv := range pvs {
+		n, err := sim.newNode(i, pv, state.Copy())
+		if err != nil {
+			sim.stop()
+			return nil, err
+		}
+		sim.nodes = append(sim.nodes, n)
+	}
+	for _, i := range config.Faults.Equivocators {
+		sim.nodes[i].equivocator = true
+	}
+	return sim, nil
+}
+
+// stop releases the nodes and restores the wall clock.
+func (sim *simulator) stop() {
+	for _, n := range sim.nodes {
+		n.proxyApp.Stop()
+		n.eventBus.Stop()
+	}
+	sim.restoreClock()
+}
+
+// run runs the simulation until a +2/3 majority of the nodes committed
+// the target height. It returns an error if safety or liveness is violated.
+func (sim *simulator) run() error {
+	for _, n := range sim.nodes {
+		if at, ok := sim.config.Faults.Crashes[n.index]; ok {
+			sim.push(&simEvent{at: simEpoch.Add(at), node: n, crash: true})
+		}
+	}
+	for _, n := range sim.nodes {
+		sim.handle(n, n.scheduleFirst)
+	}
+
+	deadline := simEpoch.Add(sim.config.MaxTime)
+	for sim.err == nil && !sim.live() {
+		if sim.events.Len() == 0 {
+			return fmt.Errorf("Liveness violated: no more events at %v, heights %v",
+				sim.now.Sub(simEpoch), sim.heights())
+		}
+		ev := heap.Pop(&sim.events).(*simEvent)
+		if ev.at.After(deadline) {
+			return fmt.Errorf("Liveness violated: height %d not committed by +2/3 of the nodes after %v, heights %v",
+				sim.config.Heights, sim.config.MaxTime, sim.heights())
+		}
+		sim.now = ev.at
+		sim.process(ev)
+	}
+	return sim.err
+}
+
+// live returns true once a +2/3 majority of the nodes committed the target height.
+func (sim *simulator) live() bool {
+	committed := 0
+	for _, n := range sim.nodes {
+		if n.committed >= sim.config.Heights {
+			committed++
+		}
+	}
+	return committed*3 > len(sim.nodes)*2
+}
+
+func (sim *simulator) heights() []int64 {
+	heights := make([]int64, len(sim.nodes))
+	for i, n := range sim.nodes {
+		heights[i] = n.committed
+	}
+	return heights
+}
+
+func (sim *simulator) process(ev *simEvent) {
+	n := ev.node
+	if n.crashed {
+		return
+	}
+	switch {
+	case ev.crash:
+		n.crashed = true
+	case ev.ticker != nil:
+		// the ticker only fires its latest timeout
+		if ev.gen == ev.ticker.gen {
+			sim.handle(n, func() { n.handleTimeout(ev.ti) })
+		}
+	default:
+		msg, err := decodeMsg(ev.msg)
+		if err == nil {
+			err = msg.ValidateBasic()
+		}
+		if err != nil {
+			sim.err = fmt.Errorf("Invalid message from node %d: %v", ev.from.index, err)
+			return
+		}
+		sim.handle(n, func() { n.handleMsg(msgInfo{msg, ev.from.peerID()}) })
+	}
+}
+
+// handle runs fn on the engine of a node, then processes the messages of
+// its own validator, as the receive routine would, sends the messages it
+// broadcast and checks the blocks it committed.
+func (sim *simulator) handle(n *simNode, fn func()) {
+	fn()
+	queue := n.internalMsgQueue()
+	for {
+		select {
+		case mi := <-queue:
+			n.handleMsg(mi)
+			continue
+		default:
+		}
+		break
+	}
+
+	outbox := n.outbox
+	n.outbox = nil
+	for _, b := range outbox {
+		var conflicting ConsensusMessage
+		if n.equivocator {
+			conflicting = sim.equivocate(n, b.Msg)
+		}
+		for _, peer := range sim.nodes {
+			if peer == n {
+				continue
+			}
+			// the peers getting the conflicting message get the original
+			// too, as the reactors of the honest peers would relay it
+			if conflicting != nil && peer.index%2 == 1 {
+				sim.send(n, peer, conflicting)
+			}
+			// likewise for the peers a message is not sent to, one hop later
+			if b.Peers != nil && !b.Peers(peer.peerID()) {
+				sim.sendAfter(n, peer, b.Msg, sim.config.Faults.MaxDelay)
+				continue
+			}
+			sim.send(n, peer, b.Msg)
+		}
+	}
+
+	sim.checkCommits(n)
+}
+
+// send schedules the delivery of a message, unless it is lost.
+func (sim *simulator) send(from, to *simNode, msg ConsensusMessage) {
+	sim.sendAfter(from, to, msg, 0)
+}
+
+// sendAfter schedules the delivery of a message with an extra delay,
+// unless it is lost.
+func (sim *simulator) sendAfter(from, to *simNode, msg ConsensusMessage, extra time.Duration) {
+	faults := sim.config.Faults
+	if sim.rng.Float64() < faults.DropRate || sim.partitioned(from.index, to.index) {
+		return
+	}
+	delay := extra + faults.MinDelay
+	if spread := faults.MaxDelay - faults.MinDelay; spread > 0 {
+		delay += time.Duration(sim.rng.Int63n(int64(spread)))
+	}
+	at := sim.now.Add(delay)
+	if !faults.Reorder {
+		link := [2]int{from.index, to.index}
+		if last := sim.lastDelivery[link]; at.Before(last) {
+			at = last
+		}
+		sim.lastDelivery[link] = at
+	}
+	sim.push(&simEvent{at: at, node: to, from: from, msg: cdc.MustMarshalBinaryBare(msg)})
+}
+
+// partitioned returns true if nodes i and j can't talk to each other now.
+func (sim *simulator) partitioned(i, j int) bool {
+	elapsed := sim.now.Sub(simEpoch)
+	for _, p := range sim.config.Faults.Partitions {
+		if elapsed >= p.From && elapsed < p.To && p.groupOf(i) != p.groupOf(j) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p simPartition) groupOf(i int) int {
+	for g, group := range p.Groups {
+		for _, j := range group {
+			if i == j {
+				return g
+			}
+		}
+	}
+	return -1
+}
+
+// equivocate returns a message conflicting with a vote or a proposal
+// signed by the node, or nil for any other message.
+func (sim *simulator) equivocate(n *simNode, msg ConsensusMessage) ConsensusMessage {
+	pubKey := n.pv.GetPubKey()
+	switch msg := msg.(type) {
+	case *VoteMessage:
+		if !bytes.Equal(msg.Vote.ValidatorAddress, pubKey.Address()) {
+			return nil
+		}
+		vote := msg.Vote.Copy()
+		if vote.BlockID.IsZero() {
+			vote.BlockID = sim.randBlockID()
+		} else {
+			vote.BlockID = types.BlockID{}
+		}
+		if err := n.pv.SignVote(simLeagueID, vote); err != nil {
+			return nil
+		}
+		return &VoteMessage{vote}
+	case *ProposalMessage:
+		if !pubKey.VerifyBytes(msg.Proposal.SignBytes(simLeagueID), msg.Proposal.Signature) {
+			return nil
+		}
+		proposal := *msg.Proposal
+		proposal.BlockID = sim.randBlockID()
+		if err := n.pv.SignProposal(simLeagueID, &proposal); err != nil {
+			return nil
+		}
+		return &ProposalMessage{&proposal}
+	}
+	return nil
+}
+
+func (sim *simulator) randBlockID() types.BlockID {
+	hash, partsHash := make([]byte, tmhash.Size), make([]byte, tmhash.Size)
+	sim.rng.Read(hash)
+	sim.rng.Read(partsHash)
+	return types.BlockID{Hash: hash, PartsHeader: types.PartSetHeader{Total: 1, Hash: partsHash}}
+}
+
+// checkCommits checks the blocks committed by a node against the blocks
+// committed by the others at the same heights.
+func (sim *simulator) checkCommits(n *simNode) {
+	for height := n.committed + 1; height <= n.blockStore.Height(); height++ {
+		blockID := n.blockStore.LoadBlockMeta(height).BlockID
+		if committed, ok := sim.commits[height]; !ok {
+			sim.commits[height] = blockID
+		} else if !committed.Equals(blockID) && sim.err == nil {
+			sim.err = fmt.Errorf("Safety violated: node %d committed %v at height %d, another node committed %v",
+				n.index, blockID, height, committed)
+		}
+		sim.trace = append(sim.trace, fmt.Sprintf("%v node%d %d %X",
+			sim.now.Sub(simEpoch), n.index, height, blockID.Hash))
+		n.committed = height
+	}
+}
+
+func (sim *simulator) push(ev *simEvent) {
+	sim.seq++
+	ev.seq = sim.seq
+	heap.Push(&sim.events, ev)
+}
+
+//-----------------------------------------------------------------------------
+
+// simNode is a validator of the simulated league, or an observer.
+type simNode struct {
+	index      int
+	pv         *types.MockPV // nil for an observer
+	engine     ConsensusEngine
+	blockStore sm.BlockStore
+	proxyApp   proxy.AppConns
+	eventBus   *types.EventBus
+	evpool     *simEvidencePool
+
+	outbox      []*Broadcast
+	committed   int64
+	crashed     bool
+	equivocator bool
+}
+
+func (sim *simulator) newNode(index int, pv *types.MockPV, state sm.State) (*simNode, error) {
+	n := &simNode{
+		index:      index,
+		pv:         pv,
+		blockStore: bc.NewBlockStore(dbm.NewMemDB()),
+		proxyApp:   proxy.NewAppConns(proxy.NewLocalClientCreator(kvstore.NewKVStoreApplication())),
+		eventBus:   types.NewEventBus(),
+		evpool:     &simEvidencePool{},
+	}
+	if err := n.proxyApp.Start(); err != nil {
+		return nil, err
+	}
+	if err := n.eventBus.Start(); err != nil {
+		return nil, err
+	}
+
+	stateDB := dbm.NewMemDB()
+	sm.SaveState(stateDB, state)
+	blockExec := sm.NewBlockExecutor(stateDB, log.NewNopLogger(), n.proxyApp.Consensus(),
+		sm.MockMempool{}, sm.MockEvidencePool{})
+
+	switch sim.config.Protocol {
+	case cfg.BFTConsensusProtocol:
+		var options []BFTOption
+		if behaviors, ok := sim.config.Faults.Byzantine[index]; ok {
+			options = append(options, BFTByzantine(behaviors, pv))
+		}
+		cs := NewBFTConsensus(simBFTConfig(), state, blockExec, n.blockStore, sm.MockMempool{}, n.evpool, options...)
+		cs.SetTimeoutTicker(&simTicker{sim: sim, node: n})
+		if err := cs.evsw.Start(); err != nil {
+			return nil, err
+		}
+		n.engine = cs
+	case cfg.FBAConsensusProtocol:
+		cs := NewFBAConsensus(simFBAConfig(), state, blockExec, n.blockStore, sm.MockMempool{}, n.evpool)
+		cs.nominationTicker = &simTicker{sim: sim, node: n}
+		cs.ballotTicker = &simTicker{sim: sim, node: n}
+		if err := cs.evsw.Start(); err != nil {
+			return nil, err
+		}
+		n.engine = cs
+	default:
+		return nil, fmt.Errorf("Unknown consensus protocol %v", sim.config.Protocol)
+	}
+
+	n.engine.SetLogger(log.NewNopLogger())
+	n.engine.SetEventBus(n.eventBus)
+	if pv != nil {
+		n.engine.SetValidator(pv)
+	}
+	err := n.engine.AddBroadcastListener("simulator", func(b *Broadcast) {
+		n.outbox = append(n.outbox, b)
+	})
+	return n, err
+}
+
+// peerID is the ID of the node for its peers.
+func (n *simNode) peerID() types.P2PID {
+	return types.P2PID(fmt.Sprintf("node%d", n.index))
+}
+
+// scheduleFirst schedules the first round or slot, as OnStart does.
+func (n *simNode) scheduleFirst() {
+	switch cs := n.engine.(type) {
+	case *BFTConsensus:
+		cs.scheduleRound0(cs.GetRoundState())
+	case *FBAConsensus:
+		cs.scheduleSlot0(cs.GetSlotState())
+	}
+}
+
+func (n *simNode) handleMsg(mi msgInfo) {
+	switch cs := n.engine.(type) {
+	case *BFTConsensus:
+		cs.handleMsg(mi)
+	case *FBAConsensus:
+		cs.handleMsg(mi)
+	}
+}
+
+func (n *simNode) handleTimeout(ti timeoutInfo) {
+	switch cs := n.engine.(type) {
+	case *BFTConsensus:
+		cs.handleTimeout(ti, *cs.GetRoundState())
+	case *FBAConsensus:
+		cs.handleTimeout(ti, *cs.GetSlotState())
+	}
+}
+
+func (n *simNode) internalMsgQueue() chan msgInfo {
+	switch cs := n.engine.(type) {
+	case *BFTConsensus:
+		return cs.internalMsgQueue
+	case *FBAConsensus:
+		return cs.internalMsgQueue
+	}
+	return nil
+}
+
+func simBFTConfig() *cfg.BFTConsensusConfig {
+	return &cfg.BFTConsensusConfig{
+		TimeoutPropose:        3 * time.Second,
+		TimeoutProposeDelta:   500 * time.Millisecond,
+		TimeoutPrevote:        time.Second,
+		TimeoutPrevoteDelta:   500 * time.Millisecond,
+		TimeoutPrecommit:      time.Second,
+		TimeoutPrecommitDelta: 500 * time.Millisecond,
+		TimeoutCommit:         time.Second,
+		CreateEmptyBlocks:     true,
+	}
+}
+
+func simFBAConfig() *cfg.FBAConsensusConfig {
+	return &cfg.FBAConsensusConfig{
+		TimeoutPropose:        3 * time.Second,
+		TimeoutProposeDelta:   500 * time.Millisecond,
+		TimeoutPrevote:        time.Second,
+		TimeoutPrevoteDelta:   500 * time.Millisecond,
+		TimeoutPrecommit:      time.Second,
+		TimeoutPrecommitDelta: 500 * time.Millisecond,
+		TimeoutCommit:         time.Second,
+		CreateEmptyBlocks:     true,
+	}
+}
+
+// simTicker is a TimeoutTicker on the virtual clock. Like the timeoutTicker,
+// it only fires the latest timeout scheduled, and ignores the timeouts for
+// earlier heights, rounds and steps.
+type simTicker struct {
+	sim  *simulator
+	node *simNode
+	ti   timeoutInfo // latest timeout scheduled
+	gen  int         // incremented for every timeout scheduled
+}
+
+func (t *simTicker) Start() error             { return nil }
+func (t *simTicker) Stop() error              { return nil }
+func (t *simTicker) Chan() <-chan timeoutInfo { return nil }
+func (t *simTicker) SetLogger(log.Logger)     {}
+func (t *simTicker) ScheduleTimeout(ti timeoutInfo) {
+	if ti.Height < t.ti.Height {
+		return
+	} else if ti.Height == t.ti.Height {
+		if ti.Round < t.ti.Round {
+			return
+		} else if ti.Round == t.ti.Round && t.ti.Step > 0 && ti.Step <= t.ti.Step {
+			return
+		}
+	}
+	t.ti = ti
+	t.gen++
+	delay := ti.Duration
+	if delay < 0 {
+		delay = 0
+	}
+	t.sim.push(&simEvent{at: t.sim.now.Add(delay), node: t.node, ticker: t, gen: t.gen, ti: ti})
+}
+
+// simEvidencePool records the evidence detected by a node.
+type simEvidencePool struct {
+	evidence []types.Evidence
+}
+
+func (p *simEvidencePool) AddEvidence(ev types.Evidence) error {
+	p.evidence = append(p.evidence, ev)
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+
+// simEvent is the delivery of a message, a timeout or a crash.
+type simEvent struct {
+	at   time.Time
+	seq  int64 // orders the events of the same time
+	node *simNode
+
+	// delivery of a message
+	from *simNode
+	msg  []byte
+
+	// timeout
+	ticker *simTicker
+	gen    int
+	ti     timeoutInfo
+
+	crash bool
+}
+
+// simEventQueue is a heap of events ordered by time, then scheduling order.
+type simEventQueue []*simEvent
+
+func (q simEventQueue) Len() int { return len(q) }
+func (q simEventQueue) Less(i, j int) bool {
+	if !q[i].at.Equal(q[j].at) {
+		return q[i].at.Before(q[j].at)
+	}
+	return q[i].seq < q[j].seq
+}
+func (q simEventQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *simEventQueue) Push(x interface{}) { *q = append(*q, x.(*simEvent)) }
+func (q *simEventQueue) Pop() interface{} {
+	old := *q
+	ev := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return ev
+}
@@ -0,0 +1,181 @@
+package protocols
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/teragrid/dgrid/core/types"
+)
+
+//-----------------------------------------------------------------------------
+// SlotPhaseType enum type
+
+// SlotPhaseType enumerates the phases of a slot (height) in the FBA engine.
+type SlotPhaseType uint8 // These must be numeric, ordered.
+
+// SlotPhaseType
+const (
+	SlotPhaseNewHeight   = SlotPhaseType(0x01) // Wait til CommitTime + timeoutCommit
+	SlotPhaseNominate    = SlotPhaseType(0x02) // Nominating candidate values
+	SlotPhasePrepare     = SlotPhaseType(0x03) // Ballot protocol, trying to prepare a ballot
+	SlotPhaseConfirm     = SlotPhaseType(0x04) // Ballot protocol, accepted a commit
+	SlotPhaseExternalize = SlotPhaseType(0x05) // Confirmed a commit, value is decided
+	SlotPhaseCommit      = SlotPhaseType(0x06) // Saving and applying the decided block
+
+	// NOTE: Update IsValid method if you change this!
+)
+
+// IsValid returns true if the phase is valid, false if unknown/undefined.
+func (sp SlotPhaseType) IsValid() bool {
+	return uint8(sp) >= 0x01 && uint8(sp) <= 0x06
+}
+
+// String returns a string
+func (sp SlotPhaseType) String() string {
+	switch sp {
+	case SlotPhaseNewHeight:
+		return "SlotPhaseNewHeight"
+	case SlotPhaseNominate:
+		return "SlotPhaseNominate"
+	case SlotPhasePrepare:
+		return "SlotPhasePrepare"
+	case SlotPhaseConfirm:
+		return "SlotPhaseConfirm"
+	case SlotPhaseExternalize:
+		return "SlotPhaseExternalize"
+	case SlotPhaseCommit:
+		return "SlotPhaseCommit"
+	default:
+		return "SlotPhaseUnknown" // Cannot panic.
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+// SlotState defines the internal state of the FBA engine for a single height.
+// NOTE: Not thread safe. Should only be manipulated by functions downstream
+// of the FBAConsensus receiveRoutine
+type SlotState struct {
+	Height     int64               `json:"height"` // Height (slot) we are working on
+	Phase      SlotPhaseType       `json:"phase"`
+	StartTime  time.Time           `json:"start_time"`
+	CommitTime time.Time           `json:"commit_time"`
+	Validators *types.ValidatorSet `json:"validators"`
+	QuorumSet  *types.QuorumSet    `json:"quorum_set"` // our own quorum slices
+
+	// Nomination protocol
+	NominationRound int             `json:"nomination_round"`
+	RoundLeaders    []types.Address `json:"round_leaders"`
+	Votes           []types.BlockID `json:"votes"`      // values we voted to nominate
+	Accepted        []types.BlockID `json:"accepted"`   // values we accepted as nominated
+	Candidates      []types.BlockID `json:"candidates"` // values confirmed nominated
+
+	// Ballot protocol
+	Ballot        *types.SCPBallot `json:"ballot"`         // b
+	Prepared      *types.SCPBallot `json:"prepared"`       // p
+	PreparedPrime *types.SCPBallot `json:"prepared_prime"` // p'
+	HighBallot    *types.SCPBallot `json:"high_ballot"`    // h
+	CommitBallot  *types.SCPBallot `json:"commit_ballot"`  // c
+
+	// Blocks proposed for this height, keyed by BlockID.Key()
+	ProposalBlocks     map[string]*types.Block   `json:"-"`
+	ProposalBlockParts map[string]*types.PartSet `json:"-"`
+	// BlockID.Key() of the proposal of each validator, keyed by address
+	Proposers map[string]string `json:"-"`
+
+	// Latest statements received from each validator, keyed by address
+	LatestNominations map[string]*types.SCPStatement `json:"latest_nominations"`
+	LatestBallots     map[string]*types.SCPStatement `json:"latest_ballots"`
+
+	// Precommits certifying the externalized value,
+	// so the committed block can be verified like any other
+	Precommits     *types.VoteSet      `json:"precommits"`
+	LastCommit     *types.VoteSet      `json:"last_commit"` // Last precommits at Height-1
+	LastValidators *types.ValidatorSet `json:"last_validators"`
+}
+
+// SlotStateSimple is a compressed version of the SlotState for use in RPC
+type SlotStateSimple struct {
+	HeightPhase     string          `json:"height/phase"`
+	StartTime       time.Time       `json:"start_time"`
+	NominationRound int             `json:"nomination_round"`
+	Candidates      int             `json:"candidates"`
+	Ballot          string          `json:"ballot"`
+	HighBallot      string          `json:"high_ballot"`
+	CommitBallot    string          `json:"commit_ballot"`
+	Statements      int             `json:"statements"`
+	Precommits      json.RawMessage `json:"precommits"`
+}
+
+// SlotStateSimple compresses the SlotState to a SlotStateSimple
+func (ss *SlotState) SlotStateSimple() SlotStateSimple {
+	precommits, err := json.Marshal(ss.Precommits.BitArrayString())
+	if err != nil {
+		panic(err)
+	}
+	return SlotStateSimple{
+		HeightPhase:     fmt.Sprintf("%d/%d", ss.Height, ss.Phase),
+		StartTime:       ss.StartTime,
+		NominationRound: ss.NominationRound,
+		Candidates:      len(ss.Candidates),
+		Ballot:          ss.Ballot.String(),
+		HighBallot:      ss.HighBallot.String(),
+		CommitBallot:    ss.CommitBallot.String(),
+		Statements:      len(ss.LatestNominations) + len(ss.LatestBallots),
+		Precommits:      precommits,
+	}
+}
+
+// SlotStateEvent returns the H/R/S of the SlotState as an event. The round is
+// the counter of the current ballot, or of the nomination round if there is
+// no ballot yet.
+func (ss *SlotState) SlotStateEvent() types.EventDataRoundState {
+	round := ss.NominationRound
+	if ss.Ballot != nil {
+		round = ss.Ballot.Counter
+	}
+	return types.EventDataRoundState{
+		Height: ss.Height,
+		Round:  round,
+		Step:   ss.Phase.String(),
+	}
+}
+
+// String returns a string
+func (ss *SlotState) String() string {
+	return ss.StringIndented("")
+}
+
+// StringIndented returns a string
+func (ss *SlotState) StringIndented(indent string) string {
+	return fmt.Sprintf(`SlotState{
+%s  H:%v P:%v
+%s  StartTime:     %v
+%s  CommitTime:    %v
+%s  Validators:    %v
+%s  QuorumSet:     %v
+%s  Nomination:    R:%v V:%v A:%v C:%v
+%s  Ballot:        b:%v p:%v p':%v h:%v c:%v
+%s  Statements:    N:%v B:%v
+%s  Precommits:    %v
+%s  LastCommit:    %v
+%s}`,
+		indent, ss.Height, ss.Phase,
+		indent, ss.StartTime,
+		indent, ss.CommitTime,
+		indent, ss.Validators.StringIndented(indent+"  "),
+		indent, ss.QuorumSet,
+		indent, ss.NominationRound, len(ss.Votes), len(ss.Accepted), len(ss.Candidates),
+		indent, ss.Ballot, ss.Prepared, ss.PreparedPrime, ss.HighBallot, ss.CommitBallot,
+		indent, len(ss.LatestNominations), len(ss.LatestBallots),
+		indent, ss.Precommits.StringShort(),
+		indent, ss.LastCommit.StringShort(),
+		indent)
+}
+
+// StringShort returns a string
+func (ss *SlotState) StringShort() string {
+	return fmt.Sprintf(`SlotState{H:%v P:%v b:%v ST:%v}`,
+		ss.Height, ss.Phase, ss.Ballot, ss.StartTime)
+}
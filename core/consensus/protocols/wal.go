@@ -0,0 +1,457 @@
+package protocols
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/teragrid/dgrid/core/types"
+	ttime "github.com/teragrid/dgrid/core/types/time"
+	auto "github.com/teragrid/dgrid/pkg/autofile"
+	cmn "github.com/teragrid/dgrid/pkg/common"
+	"github.com/teragrid/dgrid/pkg/log"
+	amino "github.com/teragrid/dgrid/third_party/amino"
+)
+
+const (
+	// maxMsgSizeBytes is the max size of a message in the WAL: a consensus
+	// message and the fields wrapping it
+	maxMsgSizeBytes = maxMsgSize + 24
+
+	// how often the WAL should be sync'd during period sync'ing
+	walDefaultFlushInterval = 2 * time.Second
+)
+
+//--------------------------------------------------------
+// types and functions for savings consensus messages
+
+// TimedWALMessage wraps WALMessage and adds Time for debugging purposes.
+type TimedWALMessage struct {
+	Time time.Time  `json:"time"`
+	Msg  WALMessage `json:"msg"`
+}
+
+// EndHeightMessage marks the end of the given height inside WAL.
+// @internal used by scripts/wal2json util.
+type EndHeightMessage struct {
+	Height int64 `json:"height"`
+}
+
+// WALMessage is a message stored in the consensus write-ahead log.
+type WALMessage interface{}
+
+// RegisterWALMessages registers the messages which may be written to the WAL.
+func RegisterWALMessages(cdc *amino.Codec) {
+	cdc.RegisterInterface((*WALMessage)(nil), nil)
+	cdc.RegisterConcrete(types.EventDataRoundState{}, "teragrid/wal/EventDataRoundState", nil)
+	cdc.RegisterConcrete(msgInfo{}, "teragrid/wal/MsgInfo", nil)
+	cdc.RegisterConcrete(timeoutInfo{}, "teragrid/wal/TimeoutInfo", nil)
+	cdc.RegisterConcrete(EndHeightMessage{}, "teragrid/wal/EndHeightMessage", nil)
+}
+
+//--------------------------------------------------------
+// Simple write-ahead logger
+
+// WAL is an interface for any write-ahead logger.
+type WAL interface {
+	Write(WALMessage)
+	WriteSync(WALMessage)
+	FlushAndSync() error
+
+	// SearchForEndHeight returns a decoder positioned right after the
+	// EndHeightMessage of the given height, if there is one.
+	SearchForEndHeight(height int64) (dec WALDecoder, found bool, err error)
+
+	Start() error
+	Stop() error
+	Wait()
+}
+
+// WALDecoder reads the messages of a WAL in order.
+type WALDecoder interface {
+	// Decode returns the next message, or io.EOF after the last one.
+	Decode() (*TimedWALMessage, error)
+	Close() error
+}
+
+type nilWAL struct{}
+
+func (nilWAL) Write(m WALMessage)     {}
+func (nilWAL) WriteSync(m WALMessage) {}
+func (nilWAL) FlushAndSync() error    { return nil }
+func (nilWAL) Start() error           { return nil }
+func (nilWAL) Stop() error            { return nil }
+func (nilWAL) Wait()                  {}
+func (nilWAL) SearchForEndHeight(height int64) (dec WALDecoder, found bool, err error) {
+	return nil, false, nil
+}
+
+//--------------------------------------------------------
+
+// BaseWAL is a WAL on an autofile.Group. Each message is written with its
+// checksum and length:
+//
+//	4 bytes CRC32-C | 4 bytes length | amino-encoded TimedWALMessage
+//
+// and every height committed ends with an EndHeightMessage, so the messages
+// of the height in flight are found after the last one. A BaseWAL started
+// on a head file torn by a crash repairs it first, see RepairWAL.
+type BaseWAL struct {
+	cmn.BaseService
+
+	group *auto.Group
+	enc   *WALEncoder
+
+	flushTicker   *time.Ticker
+	flushInterval time.Duration
+}
+
+var _ WAL = (*BaseWAL)(nil)
+
+// NewWAL returns a new WAL writing to walFile, rotated to walFile.000,
+// walFile.001, ... as set by groupOptions.
+func NewWAL(walFile string, groupOptions ...func(*auto.Group)) (*BaseWAL, error) {
+	if err := cmn.EnsureDir(filepath.Dir(walFile), 0700); err != nil {
+		return nil, errors.Wrap(err, "failed to ensure WAL directory is in place")
+	}
+
+	group, err := auto.OpenGroup(walFile, groupOptions...)
+	if err != nil {
+		return nil, err
+	}
+	wal := &BaseWAL{
+		group:         group,
+		enc:           NewWALEncoder(group),
+		flushInterval: walDefaultFlushInterval,
+	}
+	wal.BaseService = *cmn.NewBaseService(nil, "baseWAL", wal)
+	return wal, nil
+}
+
+// SetFlushInterval allows us to override the periodic flush interval for the WAL.
+func (wal *BaseWAL) SetFlushInterval(i time.Duration) {
+	wal.flushInterval = i
+}
+
+// Group returns the group of files of the WAL.
+func (wal *BaseWAL) Group() *auto.Group {
+	return wal.group
+}
+
+// SetLogger implements cmn.Service.
+func (wal *BaseWAL) SetLogger(l log.Logger) {
+	wal.BaseService.Logger = l
+	wal.group.SetLogger(l)
+}
+
+// OnStart implements cmn.Service. It repairs the tail of the head file, and
+// marks the beginning of a new WAL with the EndHeightMessage of height 0.
+func (wal *BaseWAL) OnStart() error {
+	repaired, err := RepairWAL(wal.group.Head.Path)
+	if err != nil {
+		return err
+	}
+	if repaired {
+		wal.Logger.Error("WAL was torn by a crash, its tail was dropped",
+			"wal", wal.group.Head.Path, "backup", corruptedWALFile(wal.group.Head.Path))
+	}
+
+	size, err := wal.group.Head.Size()
+	if err != nil {
+		return err
+	} else if size == 0 {
+		wal.WriteSync(EndHeightMessage{0})
+	}
+	if err := wal.group.Start(); err != nil {
+		return err
+	}
+	wal.flushTicker = time.NewTicker(wal.flushInterval)
+	go wal.processFlushTicks()
+	return nil
+}
+
+func (wal *BaseWAL) processFlushTicks() {
+	for {
+		select {
+		case <-wal.flushTicker.C:
+			if err := wal.FlushAndSync(); err != nil {
+				wal.Logger.Error("Periodic WAL flush failed", "err", err)
+			}
+		case <-wal.Quit():
+			return
+		}
+	}
+}
+
+// FlushAndSync flushes and fsync's the underlying group's data to disk.
+// See auto#FlushAndSync
+func (wal *BaseWAL) FlushAndSync() error {
+	return wal.group.FlushAndSync()
+}
+
+// OnStop implements cmn.Service.
+// Stop the underlying autofile group.
+// Use Wait() to ensure it's finished shutting down
+// before cleaning up files.
+func (wal *BaseWAL) OnStop() {
+	wal.flushTicker.Stop()
+	wal.FlushAndSync()
+	wal.group.Stop()
+	wal.group.Close()
+}
+
+// Wait for the underlying autofile group to finish shutting down
+// so it's safe to cleanup files.
+func (wal *BaseWAL) Wait() {
+	wal.group.Wait()
+}
+
+// Write is called in newStep and for each receive on the
+// peerMsgQueue and the timeoutTicker.
+// NOTE: does not call fsync()
+func (wal *BaseWAL) Write(msg WALMessage) {
+	if wal == nil {
+		return
+	}
+
+	if err := wal.enc.Encode(&TimedWALMessage{ttime.Now(), msg}); err != nil {
+		panic(fmt.Sprintf("Error writing msg to consensus wal: %v \n\nMessage: %v", err, msg))
+	}
+}
+
+// WriteSync is called when we receive a msg from ourselves
+// so that we write to disk before sending signed messages.
+// NOTE: calls fsync()
+func (wal *BaseWAL) WriteSync(msg WALMessage) {
+	if wal == nil {
+		return
+	}
+
+	wal.Write(msg)
+	if err := wal.FlushAndSync(); err != nil {
+		panic(fmt.Sprintf("Error flushing consensus wal buf to file. Error: %v \n", err))
+	}
+}
+
+// SearchForEndHeight searches for the EndHeightMessage with the given
+// height, from the newest file of the group to the oldest. A corrupted
+// message ends the search in its file.
+func (wal *BaseWAL) SearchForEndHeight(height int64) (dec WALDecoder, found bool, err error) {
+	lastHeightFound := int64(-1)
+	min, max := wal.group.MinIndex(), wal.group.MaxIndex()
+	wal.Logger.Info("Searching for height", "height", height, "min", min, "max", max)
+	for index := max; index >= min; index-- {
+		gr, err := wal.group.NewReader(index)
+		if err != nil {
+			return nil, false, err
+		}
+
+		dec := NewWALDecoder(gr)
+		for {
+			msg, err := dec.Decode()
+			if err == io.EOF || IsDataCorruptionError(err) {
+				break
+			} else if err != nil {
+				gr.Close()
+				return nil, false, err
+			}
+
+			if m, ok := msg.Msg.(EndHeightMessage); ok {
+				lastHeightFound = m.Height
+				if m.Height == height {
+					wal.Logger.Info("Found", "height", height, "index", index)
+					return dec, true, nil
+				}
+			}
+		}
+		gr.Close()
+
+		// no need to look for height in older files if we've seen h < height
+		if lastHeightFound >= 0 && lastHeightFound < height {
+			break
+		}
+	}
+	return nil, false, nil
+}
+
+//--------------------------------------------------------
+
+// A WALEncoder writes custom-encoded WAL messages to an output stream.
+//
+// Format: 4 bytes CRC sum + 4 bytes length + arbitrary-length value (go-amino encoded)
+type WALEncoder struct {
+	wr io.Writer
+}
+
+// NewWALEncoder returns a new encoder that writes to wr.
+func NewWALEncoder(wr io.Writer) *WALEncoder {
+	return &WALEncoder{wr}
+}
+
+// Encode writes the custom encoding of v to the stream.
+func (enc *WALEncoder) Encode(v *TimedWALMessage) error {
+	data := cdc.MustMarshalBinaryBare(v)
+
+	crc := crc32.Checksum(data, crc32c)
+	length := uint32(len(data))
+	if length > maxMsgSizeBytes {
+		return fmt.Errorf("Msg is too big: %d bytes, max: %d bytes", length, maxMsgSizeBytes)
+	}
+	totalLength := 8 + int(length)
+
+	msg := make([]byte, totalLength)
+	binary.BigEndian.PutUint32(msg[0:4], crc)
+	binary.BigEndian.PutUint32(msg[4:8], length)
+	copy(msg[8:], data)
+
+	_, err := enc.wr.Write(msg)
+	return err
+}
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// DataCorruptionError is returned for a message of the WAL which can't be
+// read: it was torn by a crash, or altered.
+type DataCorruptionError struct {
+	cause error
+}
+
+func (e DataCorruptionError) Error() string {
+	return fmt.Sprintf("DataCorruptionError[%v]", e.cause)
+}
+
+// Cause returns the reason the message can't be read.
+func (e DataCorruptionError) Cause() error {
+	return e.cause
+}
+
+// IsDataCorruptionError returns true if data has been corrupted inside WAL.
+func IsDataCorruptionError(err error) bool {
+	_, ok := err.(DataCorruptionError)
+	return ok
+}
+
+// walDecoder reads the custom-encoded messages written by a WALEncoder.
+type walDecoder struct {
+	rd     io.Reader
+	offset int64 // of the next message
+}
+
+// NewWALDecoder returns a new decoder that reads from rd. Closing it
+// closes rd, if it is an io.Closer.
+func NewWALDecoder(rd io.Reader) WALDecoder {
+	return &walDecoder{rd: rd}
+}
+
+// Decode reads the next custom-encoded value from its reader and returns it.
+func (dec *walDecoder) Decode() (*TimedWALMessage, error) {
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(dec.rd, b); err == io.EOF {
+		return nil, err
+	} else if err != nil {
+		return nil, DataCorruptionError{fmt.Errorf("failed to read checksum: %v", err)}
+	}
+	crc := binary.BigEndian.Uint32(b)
+
+	if _, err := io.ReadFull(dec.rd, b); err != nil {
+		return nil, DataCorruptionError{fmt.Errorf("failed to read length: %v", err)}
+	}
+	length := binary.BigEndian.Uint32(b)
+	if length > maxMsgSizeBytes {
+		return nil, DataCorruptionError{fmt.Errorf("length %d exceeded maximum possible value of %d bytes", length, maxMsgSizeBytes)}
+	}
+
+	data := make([]byte, length)
+	if n, err := io.ReadFull(dec.rd, data); err != nil {
+		return nil, DataCorruptionError{fmt.Errorf("failed to read data: %v (read: %d, wanted: %d)", err, n, length)}
+	}
+
+	// check checksum before decoding data
+	if actualCRC := crc32.Checksum(data, crc32c); actualCRC != crc {
+		return nil, DataCorruptionError{fmt.Errorf("checksums do not match: read: %v, actual: %v", crc, actualCRC)}
+	}
+
+	res := new(TimedWALMessage)
+	if err := cdc.UnmarshalBinaryBare(data, res); err != nil {
+		return nil, DataCorruptionError{fmt.Errorf("failed to decode data: %v", err)}
+	}
+	dec.offset += 8 + int64(length)
+	return res, nil
+}
+
+// Close implements WALDecoder.
+func (dec *walDecoder) Close() error {
+	if closer, ok := dec.rd.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+//--------------------------------------------------------
+
+func corruptedWALFile(walFile string) string {
+	return walFile + ".CORRUPTED"
+}
+
+// RepairWAL drops the tail of a WAL file following its last valid message,
+// left by a crash in the middle of a write. The original file is copied to
+// <walFile>.CORRUPTED first. It returns whether the file was repaired. The
+// WAL must not be in use.
+func RepairWAL(walFile string) (repaired bool, err error) {
+	f, err := os.Open(walFile)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	dec := &walDecoder{rd: bufio.NewReader(f)}
+	for {
+		_, err := dec.Decode()
+		if err == io.EOF {
+			return false, nil
+		} else if !IsDataCorruptionError(err) && err != nil {
+			return false, err
+		} else if err != nil {
+			break
+		}
+	}
+
+	if err := copyFile(walFile, corruptedWALFile(walFile)); err != nil {
+		return false, err
+	}
+	if err := os.Truncate(walFile, dec.offset); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// copyFile copies the file src to dst, replacing it.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
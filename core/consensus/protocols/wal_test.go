@@ -0,0 +1,158 @@
+package protocols
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teragrid/dgrid/core/types"
+	ttime "github.com/teragrid/dgrid/core/types/time"
+)
+
+func TestWALEncoderDecoder(t *testing.T) {
+	now := ttime.Now()
+	msgs := []TimedWALMessage{
+		{Time: now, Msg: EndHeightMessage{0}},
+		{Time: now, Msg: timeoutInfo{Duration: time.Second, Height: 1, Round: 1, Step: RoundStepPropose}},
+	}
+
+	b := new(bytes.Buffer)
+	for _, msg := range msgs {
+		b.Reset()
+		enc := NewWALEncoder(b)
+		require.NoError(t, enc.Encode(&msg))
+
+		dec := NewWALDecoder(b)
+		decoded, err := dec.Decode()
+		require.NoError(t, err)
+		assert.Equal(t, msg.Time.UTC(), decoded.Time)
+		assert.Equal(t, msg.Msg, decoded.Msg)
+	}
+}
+
+func TestWALDecoderCorruption(t *testing.T) {
+	b := new(bytes.Buffer)
+	require.NoError(t, NewWALEncoder(b).Encode(&TimedWALMessage{Time: ttime.Now(), Msg: EndHeightMessage{1}}))
+	bz := b.Bytes()
+
+	// a flipped bit fails the checksum
+	corrupted := append([]byte{}, bz...)
+	corrupted[len(corrupted)-1] ^= 0x01
+	_, err := NewWALDecoder(bytes.NewReader(corrupted)).Decode()
+	assert.True(t, IsDataCorruptionError(err), err)
+
+	// a torn message
+	_, err = NewWALDecoder(bytes.NewReader(bz[:len(bz)-2])).Decode()
+	assert.True(t, IsDataCorruptionError(err), err)
+
+	// the end of the WAL is not a corruption
+	_, err = NewWALDecoder(bytes.NewReader(nil)).Decode()
+	assert.Equal(t, io.EOF, err)
+}
+
+func newTestWAL(t *testing.T) (wal *BaseWAL, walFile string, cleanup func()) {
+	dir, err := ioutil.TempDir("", "wal")
+	require.NoError(t, err)
+	walFile = filepath.Join(dir, "wal")
+	wal, err = NewWAL(walFile)
+	require.NoError(t, err)
+	require.NoError(t, wal.Start())
+	return wal, walFile, func() {
+		wal.Stop()
+		wal.Wait()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestWALSearchForEndHeight(t *testing.T) {
+	wal, _, cleanup := newTestWAL(t)
+	defer cleanup()
+
+	for height := int64(1); height <= 3; height++ {
+		wal.Write(timeoutInfo{Height: height, Step: RoundStepPropose})
+		wal.WriteSync(EndHeightMessage{height})
+	}
+	wal.Write(timeoutInfo{Height: 4, Step: RoundStepPrevote})
+	require.NoError(t, wal.FlushAndSync())
+
+	dec, found, err := wal.SearchForEndHeight(2)
+	require.NoError(t, err)
+	require.True(t, found)
+	msg, err := dec.Decode()
+	require.NoError(t, err)
+	assert.Equal(t, timeoutInfo{Height: 3, Step: RoundStepPropose}, msg.Msg)
+	dec.Close()
+
+	_, found, err = wal.SearchForEndHeight(4)
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	// the messages of the height in flight are replayed
+	var replayed []WALMessage
+	require.NoError(t, catchupReplay(wal, 4, func(msg WALMessage) error {
+		replayed = append(replayed, msg)
+		return nil
+	}))
+	assert.Equal(t, []WALMessage{timeoutInfo{Height: 4, Step: RoundStepPrevote}}, replayed)
+	// but not those of a committed height
+	assert.Error(t, catchupReplay(wal, 3, func(msg WALMessage) error { return nil }))
+}
+
+func TestWALRepair(t *testing.T) {
+	wal, walFile, cleanup := newTestWAL(t)
+	defer cleanup()
+
+	wal.WriteSync(EndHeightMessage{1})
+	wal.WriteSync(timeoutInfo{Height: 2, Step: RoundStepPropose})
+	info, err := os.Stat(walFile)
+	require.NoError(t, err)
+	intact := info.Size()
+
+	repaired, err := RepairWAL(walFile)
+	require.NoError(t, err)
+	assert.False(t, repaired)
+
+	// a crash in the middle of a write
+	wal.Stop()
+	wal.Wait()
+	f, err := os.OpenFile(walFile, os.O_WRONLY|os.O_APPEND, 0600)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0x01, 0x02, 0x03, 0x04, 0x00})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	// the WAL repairs itself on start
+	wal, err = NewWAL(walFile)
+	require.NoError(t, err)
+	require.NoError(t, wal.Start())
+	defer wal.Stop()
+	info, err = os.Stat(walFile)
+	require.NoError(t, err)
+	assert.Equal(t, intact, info.Size())
+	_, err = os.Stat(walFile + ".CORRUPTED")
+	assert.NoError(t, err)
+
+	var replayed []WALMessage
+	require.NoError(t, catchupReplay(wal, 2, func(msg WALMessage) error {
+		replayed = append(replayed, msg)
+		return nil
+	}))
+	assert.Equal(t, []WALMessage{timeoutInfo{Height: 2, Step: RoundStepPropose}}, replayed)
+}
+
+func TestWALMsgInfo(t *testing.T) {
+	b := new(bytes.Buffer)
+	vote := &types.Vote{Height: 1, Type: types.PrevoteType}
+	msg := &TimedWALMessage{Time: ttime.Now(), Msg: msgInfo{Msg: &VoteMessage{vote}}}
+	require.NoError(t, NewWALEncoder(b).Encode(msg))
+	decoded, err := NewWALDecoder(b).Decode()
+	require.NoError(t, err)
+	assert.IsType(t, msgInfo{}, decoded.Msg)
+}
@@ -0,0 +1,14 @@
+package protocols
+
+import (
+	"github.com/teragrid/dgrid/core/types"
+	amino "github.com/teragrid/dgrid/third_party/amino"
+)
+
+var cdc = amino.NewCodec()
+
+func init() {
+	RegisterConsensusMessages(cdc)
+	RegisterWALMessages(cdc)
+	types.RegisterBlockAmino(cdc)
+}
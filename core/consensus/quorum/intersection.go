@@ -0,0 +1,146 @@
+package quorum
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/teragrid/dgrid/core/types"
+)
+
+// MaxCheckedValidators is the maximum number of validators CheckIntersection
+// accepts, as the search is exponential in the size of the network.
+const MaxCheckedValidators = 20
+
+var (
+	// ErrNoQuorum is returned when the quorum sets admit no quorum at all,
+	// so the league could never make progress.
+	ErrNoQuorum = errors.New("No quorum exists among the validators")
+	// ErrTooManyValidators is returned when the network is too large
+	// to be checked exhaustively.
+	ErrTooManyValidators = fmt.Errorf("Quorum intersection can be checked for at most %d validators", MaxCheckedValidators)
+)
+
+// Result is the outcome of CheckIntersection. If the quorums don't
+// intersect, QuorumA and QuorumB are two disjoint quorums.
+type Result struct {
+	Intersecting bool
+	Validators   int // number of validators belonging to some quorum
+	QuorumA      []types.Address
+	QuorumB      []types.Address
+}
+
+// String returns a string representation of the Result.
+func (r *Result) String() string {
+	if r.Intersecting {
+		return fmt.Sprintf("All quorums of the %d validators intersect", r.Validators)
+	}
+	return fmt.Sprintf("Found disjoint quorums %v and %v", r.QuorumA, r.QuorumB)
+}
+
+// CheckIntersection checks every two quorums of the network defined by the
+// given quorum sets, keyed by string(address), share at least one validator.
+// Without quorum intersection, the league can fork.
+//
+// Two disjoint quorums exist if and only if there is a partition of the
+// validators in two sets S and V\S which both contain a quorum, so the check
+// enumerates the partitions and computes the greatest quorum of each side.
+func CheckIntersection(qsets map[string]*types.QuorumSet) (*Result, error) {
+	all := make(map[string]bool, len(qsets))
+	for key := range qsets {
+		all[key] = true
+	}
+	// nodes outside of the greatest quorum can't belong to any quorum
+	members := maxQuorum(qsets, all)
+	if len(members) == 0 {
+		return nil, ErrNoQuorum
+	}
+	if len(members) > MaxCheckedValidators {
+		return nil, ErrTooManyValidators
+	}
+
+	nodes := make([]string, 0, len(members))
+	for key := range members {
+		nodes = append(nodes, key)
+	}
+	sort.Strings(nodes)
+
+	// The first node is fixed on one side: a counter-example with the
+	// first node in neither quorum is found when it is put with either.
+	n := len(nodes)
+	for mask := uint64(0); mask < uint64(1)<<uint(n-1); mask++ {
+		side, rest := map[string]bool{nodes[0]: true}, make(map[string]bool)
+		for i := 1; i < n; i++ {
+			if mask&(uint64(1)<<uint(i-1)) != 0 {
+				side[nodes[i]] = true
+			} else {
+				rest[nodes[i]] = true
+			}
+		}
+		quorumA := maxQuorum(qsets, side)
+		if len(quorumA) == 0 {
+			continue
+		}
+		quorumB := maxQuorum(qsets, rest)
+		if len(quorumB) == 0 {
+			continue
+		}
+		return &Result{
+			Validators: n,
+			QuorumA:    sortedAddresses(quorumA),
+			QuorumB:    sortedAddresses(quorumB),
+		}, nil
+	}
+	return &Result{Intersecting: true, Validators: n}, nil
+}
+
+// IsQuorum returns true if every node of the given set has one of its
+// quorum slices within the set.
+func IsQuorum(qsets map[string]*types.QuorumSet, nodes map[string]bool) bool {
+	if len(nodes) == 0 {
+		return false
+	}
+	for key := range nodes {
+		qs := qsets[key]
+		if qs == nil || !qs.IsSliceSatisfied(nodes) {
+			return false
+		}
+	}
+	return true
+}
+
+// maxQuorum returns the greatest quorum contained in nodes, which is empty
+// if there is none. Nodes whose slices aren't satisfied are removed until a
+// fixpoint is reached.
+func maxQuorum(qsets map[string]*types.QuorumSet, nodes map[string]bool) map[string]bool {
+	quorum := make(map[string]bool, len(nodes))
+	for key := range nodes {
+		quorum[key] = true
+	}
+	for {
+		removed := false
+		for key := range quorum {
+			qs := qsets[key]
+			if qs == nil || !qs.IsSliceSatisfied(quorum) {
+				delete(quorum, key)
+				removed = true
+			}
+		}
+		if !removed {
+			return quorum
+		}
+	}
+}
+
+func sortedAddresses(nodes map[string]bool) []types.Address {
+	keys := make([]string, 0, len(nodes))
+	for key := range nodes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	addrs := make([]types.Address, len(keys))
+	for i, key := range keys {
+		addrs[i] = types.Address(key)
+	}
+	return addrs
+}
@@ -0,0 +1,87 @@
+package quorum
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teragrid/dgrid/core/types"
+)
+
+func randAddresses(n int) []types.Address {
+	valSet, _ := types.RandValidatorSet(n, 1)
+	return types.NewMajorityQuorumSet(valSet).Validators
+}
+
+func TestCheckIntersectionMajority(t *testing.T) {
+	addrs := randAddresses(4)
+	qs := &types.QuorumSet{Threshold: 3, Validators: addrs}
+	qsets := make(map[string]*types.QuorumSet)
+	for _, addr := range addrs {
+		qsets[string(addr)] = qs
+	}
+
+	res, err := CheckIntersection(qsets)
+	require.NoError(t, err)
+	assert.True(t, res.Intersecting)
+	assert.Equal(t, 4, res.Validators)
+}
+
+func TestCheckIntersectionDisjoint(t *testing.T) {
+	addrs := randAddresses(4)
+	// {0, 1} and {2, 3} only trust each other
+	left := &types.QuorumSet{Threshold: 2, Validators: addrs[:2]}
+	right := &types.QuorumSet{Threshold: 2, Validators: addrs[2:]}
+	qsets := map[string]*types.QuorumSet{
+		string(addrs[0]): left,
+		string(addrs[1]): left,
+		string(addrs[2]): right,
+		string(addrs[3]): right,
+	}
+
+	res, err := CheckIntersection(qsets)
+	require.NoError(t, err)
+	assert.False(t, res.Intersecting)
+	require.Len(t, res.QuorumA, 2)
+	require.Len(t, res.QuorumB, 2)
+
+	quorumA, quorumB := make(map[string]bool), make(map[string]bool)
+	for _, addr := range res.QuorumA {
+		quorumA[string(addr)] = true
+	}
+	for _, addr := range res.QuorumB {
+		assert.False(t, quorumA[string(addr)], "quorums must be disjoint")
+		quorumB[string(addr)] = true
+	}
+	assert.True(t, IsQuorum(qsets, quorumA))
+	assert.True(t, IsQuorum(qsets, quorumB))
+}
+
+func TestCheckIntersectionThresholdTooLow(t *testing.T) {
+	addrs := randAddresses(4)
+	// half of the validators is enough, so two halves are disjoint quorums
+	qs := &types.QuorumSet{Threshold: 2, Validators: addrs}
+	qsets := make(map[string]*types.QuorumSet)
+	for _, addr := range addrs {
+		qsets[string(addr)] = qs
+	}
+
+	res, err := CheckIntersection(qsets)
+	require.NoError(t, err)
+	assert.False(t, res.Intersecting)
+}
+
+func TestCheckIntersectionNoQuorum(t *testing.T) {
+	addrs := randAddresses(2)
+	unknown := randAddresses(1)
+	// everyone trusts a validator which isn't part of the network
+	qs := &types.QuorumSet{Threshold: 1, Validators: unknown}
+	qsets := map[string]*types.QuorumSet{
+		string(addrs[0]): qs,
+		string(addrs[1]): qs,
+	}
+
+	_, err := CheckIntersection(qsets)
+	assert.Equal(t, ErrNoQuorum, err)
+}
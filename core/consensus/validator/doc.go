@@ -16,6 +16,26 @@ SignerValidatorEndpoint takes a listener, which determines the type of connectio
 SignerServiceEndpoint
 
 SignerServiceEndpoint is a simple wrapper around a net.Conn. It's used by both IPCVal and TCPVal.
+It signs with SignerKeys, the keys of the validators of one or more leagues,
+selected by the league and the validator address of each request.
+
+SignerGRPCClient and SignerGRPCServer
+
+SignerGRPCClient and SignerGRPCServer speak the same protocol over gRPC, see the Signer service in proto/signer.proto,
+for KMS processes speaking gRPC. Unlike over a socket, SignerGRPCClient dials the SignerGRPCServer of the external process.
+The connections are SecretConnections authenticated with the node keys of both ends, see NewSecretConnCredentials.
+
+ThresholdSigner
+
+ThresholdSigner signs with k of n co-signers, usually SignerValidatorEndpoints of signers on other machines.
+Its key, the key of the validator, is a k of n multisig.PubKeyMultisigThreshold of the keys of the co-signers,
+so a vote is only valid with the signatures of k co-signers. Each co-signer keeps its own sign state.
+
+FailoverSigner
+
+FailoverSigner signs with one of several signers of the same key in high availability, usually SignerValidatorEndpoints,
+and fails over to another one when it can't be reached. The signers must share their last sign state
+in a SignStateStore, eg. a FileSignStateStore on a shared file system, so they never sign conflicting messages.
 
 */
 package validator
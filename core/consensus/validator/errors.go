@@ -10,6 +10,18 @@ var (
 	ErrConnTimeout        = fmt.Errorf("remote signer timed out")
 )
 
+// gRPC errors.
+var (
+	ErrUnauthorizedNodeKey = fmt.Errorf("unauthorized node key")
+)
+
+// Threshold signer errors.
+var (
+	ErrInvalidCosignature = fmt.Errorf("invalid co-signer signature")
+	ErrUnknownCosigner    = fmt.Errorf("co-signer key is not a key of the threshold validator")
+	ErrCosignerMissing    = fmt.Errorf("co-signer not connected")
+)
+
 // RemoteSignerError allows (remote) validators to include meaningful error descriptions in their reply.
 type RemoteSignerError struct {
 	// TODO(ismail): create an enum of known errors
@@ -0,0 +1,336 @@
+package validator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/teragrid/dgrid/core/types"
+	cmn "github.com/teragrid/dgrid/pkg/common"
+	"github.com/teragrid/dgrid/pkg/crypto"
+	"github.com/teragrid/dgrid/pkg/log"
+)
+
+// FailoverEndpoint is a signer of a FailoverSigner, like a
+// SignerValidatorEndpoint. It is started by the FailoverSigner.
+type FailoverEndpoint interface {
+	types.Validator
+	cmn.Service
+
+	Ping() error
+}
+
+// FailoverSignerOption sets an optional parameter on the FailoverSigner.
+type FailoverSignerOption func(*FailoverSigner)
+
+// FailoverSignerSetHeartbeat sets the period on which the FailoverSigner
+// checks the health of its endpoints.
+func FailoverSignerSetHeartbeat(period time.Duration) FailoverSignerOption {
+	return func(fs *FailoverSigner) { fs.heartbeatPeriod = period }
+}
+
+// FailoverSignerSetSignStateStore makes the FailoverSigner check each
+// message against the last sign state of store before an endpoint signs
+// it, and save it to store, so it never asks the next endpoint to sign a
+// message conflicting with one the former endpoint signed.
+func FailoverSignerSetSignStateStore(store SignStateStore) FailoverSignerOption {
+	return func(fs *FailoverSigner) { fs.stateStore = store }
+}
+
+// FailoverSigner implements Validator with several signers of the same key
+// in high availability, usually the SignerValidatorEndpoints of remote
+// signers. It signs with one of them, the active one, and fails over to
+// another healthy one when the active one can't be reached. The health of
+// the endpoints is checked with a PingRequest on each heartbeat.
+//
+// A signer may have signed a message it couldn't send back before the
+// validator failed over, so the signers must share their last sign state,
+// see SignStateStore: the next signer returns the same signature, or
+// refuses a conflicting message. A signer refusing to sign isn't failed
+// over, since the others would refuse as well. The FailoverSigner keeps
+// its own sign state as well, see FailoverSignerSetSignStateStore.
+type FailoverSigner struct {
+	cmn.BaseService
+
+	endpoints       []FailoverEndpoint
+	heartbeatPeriod time.Duration
+	stateStore      SignStateStore // nil if only the endpoints check the messages
+
+	mtx     sync.Mutex
+	pubKey  crypto.PubKey
+	active  int
+	started []bool
+	healthy []bool
+
+	quit chan struct{}
+}
+
+// Check that FailoverSigner implements Validator.
+var _ types.Validator = (*FailoverSigner)(nil)
+
+// NewFailoverSigner returns a FailoverSigner signing with endpoints.
+func NewFailoverSigner(logger log.Logger, endpoints []FailoverEndpoint) *FailoverSigner {
+	fs := &FailoverSigner{
+		endpoints:       endpoints,
+		heartbeatPeriod: heartbeatPeriod,
+		started:         make([]bool, len(endpoints)),
+		healthy:         make([]bool, len(endpoints)),
+	}
+	fs.BaseService = *cmn.NewBaseService(logger, "FailoverSigner", fs)
+	return fs
+}
+
+// OnStart implements cmn.Service. It starts the endpoints, and returns once
+// one of them is started. The others are started in the background.
+func (fs *FailoverSigner) OnStart() error {
+	if len(fs.endpoints) == 0 {
+		return errors.New("no signer endpoint")
+	}
+	fs.quit = make(chan struct{})
+
+	type result struct {
+		index int
+		err   error
+	}
+	results := make(chan result, len(fs.endpoints))
+	for i, endpoint := range fs.endpoints {
+		go func(i int, endpoint FailoverEndpoint) {
+			results <- result{i, endpoint.Start()}
+		}(i, endpoint)
+	}
+
+	var errs []string
+	for range fs.endpoints {
+		res := <-results
+		if res.err == nil {
+			res.err = fs.setStarted(res.index)
+		}
+		if res.err != nil {
+			fs.Logger.Error("Failed to start signer endpoint", "index", res.index, "err", res.err)
+			errs = append(errs, res.err.Error())
+			continue
+		}
+
+		// the other endpoints go on starting in the background
+		go func(remaining int) {
+			for ; remaining > 0; remaining-- {
+				res := <-results
+				if res.err == nil {
+					res.err = fs.setStarted(res.index)
+				}
+				if res.err != nil {
+					fs.Logger.Error("Failed to start signer endpoint", "index", res.index, "err", res.err)
+				}
+			}
+		}(len(fs.endpoints) - len(errs) - 1)
+		go fs.healthRoutine()
+		return nil
+	}
+	return fmt.Errorf("no signer endpoint started: %v", errs)
+}
+
+// OnStop implements cmn.Service.
+func (fs *FailoverSigner) OnStop() {
+	if fs.quit != nil {
+		close(fs.quit)
+	}
+	for _, endpoint := range fs.endpoints {
+		if endpoint.IsRunning() {
+			if err := endpoint.Stop(); err != nil {
+				fs.Logger.Error("OnStop", "err", err)
+			}
+		}
+	}
+}
+
+// setStarted marks an endpoint started, and active if it is the first one.
+// It returns an error if the key of the endpoint isn't the one of the
+// others.
+func (fs *FailoverSigner) setStarted(index int) error {
+	pubKey := fs.endpoints[index].GetPubKey()
+	fs.mtx.Lock()
+	defer fs.mtx.Unlock()
+	if fs.pubKey == nil {
+		fs.pubKey = pubKey
+		fs.active = index
+	} else if !fs.pubKey.Equals(pubKey) {
+		return fmt.Errorf("signer endpoint %d has the key %v, not %v", index, pubKey.Address(), fs.pubKey.Address())
+	}
+	fs.started[index] = true
+	fs.healthy[index] = true
+	return nil
+}
+
+// healthRoutine pings the started endpoints on each heartbeat.
+func (fs *FailoverSigner) healthRoutine() {
+	ticker := time.NewTicker(fs.heartbeatPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for i, endpoint := range fs.endpoints {
+				fs.mtx.Lock()
+				started := fs.started[i]
+				fs.mtx.Unlock()
+				if !started {
+					continue
+				}
+				err := endpoint.Ping()
+				fs.setHealthy(i, err == nil)
+				if err != nil {
+					fs.Logger.Error("Ping", "index", i, "err", err)
+				}
+			}
+		case <-fs.quit:
+			return
+		}
+	}
+}
+
+func (fs *FailoverSigner) setHealthy(index int, healthy bool) {
+	fs.mtx.Lock()
+	defer fs.mtx.Unlock()
+	if fs.healthy[index] != healthy {
+		fs.Logger.Info("Signer endpoint health changed", "index", index, "healthy", healthy)
+	}
+	fs.healthy[index] = healthy
+}
+
+// GetPubKey implements Validator.
+func (fs *FailoverSigner) GetPubKey() crypto.PubKey {
+	fs.mtx.Lock()
+	defer fs.mtx.Unlock()
+	return fs.pubKey
+}
+
+// SignVote implements Validator.
+func (fs *FailoverSigner) SignVote(chainID string, vote *types.Vote) error {
+	sign := func(endpoint FailoverEndpoint) error {
+		return endpoint.SignVote(chainID, vote)
+	}
+	if fs.stateStore == nil {
+		return fs.sign(sign)
+	}
+	height, round, step := vote.Height, vote.Round, voteToStep(vote)
+	return fs.withSignState(chainID, height, round, step, func(lss *FilePVLeagueSignState, sameHRS bool) ([]byte, []byte, error) {
+		// The vote was signed already: use the last signature, like FilePV.
+		if sameHRS {
+			signBytes := vote.SignBytes(chainID)
+			if bytes.Equal(signBytes, lss.SignBytes) {
+				vote.Signature = lss.Signature
+			} else if timestamp, ok := checkVotesOnlyDifferByTimestamp(lss.SignBytes, signBytes); ok {
+				vote.Timestamp = timestamp
+				vote.Signature = lss.Signature
+			} else {
+				return nil, nil, fmt.Errorf("conflicting data")
+			}
+			return nil, nil, nil
+		}
+		if err := fs.sign(sign); err != nil {
+			return nil, nil, err
+		}
+		return vote.SignBytes(chainID), vote.Signature, nil
+	})
+}
+
+// SignProposal implements Validator.
+func (fs *FailoverSigner) SignProposal(chainID string, proposal *types.Proposal) error {
+	sign := func(endpoint FailoverEndpoint) error {
+		return endpoint.SignProposal(chainID, proposal)
+	}
+	if fs.stateStore == nil {
+		return fs.sign(sign)
+	}
+	height, round, step := proposal.Height, proposal.Round, stepPropose
+	return fs.withSignState(chainID, height, round, step, func(lss *FilePVLeagueSignState, sameHRS bool) ([]byte, []byte, error) {
+		// The proposal was signed already: use the last signature, like FilePV.
+		if sameHRS {
+			signBytes := proposal.SignBytes(chainID)
+			if bytes.Equal(signBytes, lss.SignBytes) {
+				proposal.Signature = lss.Signature
+			} else if timestamp, ok := checkProposalsOnlyDifferByTimestamp(lss.SignBytes, signBytes); ok {
+				proposal.Timestamp = timestamp
+				proposal.Signature = lss.Signature
+			} else {
+				return nil, nil, fmt.Errorf("conflicting data")
+			}
+			return nil, nil, nil
+		}
+		if err := fs.sign(sign); err != nil {
+			return nil, nil, err
+		}
+		return proposal.SignBytes(chainID), proposal.Signature, nil
+	})
+}
+
+// withSignState calls sign with the sign state of a league in the store,
+// which is locked meanwhile, and whether the message at height, round and
+// step was signed already. sign returns the sign bytes and the signature
+// of the message it signed, if any, which are saved to the store.
+func (fs *FailoverSigner) withSignState(leagueID string, height int64, round int, step int8,
+	sign func(lss *FilePVLeagueSignState, sameHRS bool) ([]byte, []byte, error)) error {
+
+	return fs.stateStore.Update(func(state *FilePVLastSignState) error {
+		lss := state.League(leagueID)
+		if lss == nil {
+			lss = &FilePVLeagueSignState{LeagueID: leagueID}
+		}
+		sameHRS, err := lss.CheckHRS(height, round, step)
+		if err != nil {
+			return err
+		}
+		signBytes, sig, err := sign(lss, sameHRS)
+		if err != nil || signBytes == nil {
+			return err
+		}
+		state.setLeague(FilePVLeagueSignState{
+			LeagueID:  leagueID,
+			Height:    height,
+			Round:     round,
+			Step:      step,
+			Signature: sig,
+			SignBytes: signBytes,
+		})
+		return nil
+	})
+}
+
+// sign signs with the active endpoint, or else with the next healthy one,
+// which becomes the active one. Only one endpoint signs at a time.
+func (fs *FailoverSigner) sign(sign func(endpoint FailoverEndpoint) error) error {
+	fs.mtx.Lock()
+	defer fs.mtx.Unlock()
+
+	var errs []string
+	for i := range fs.endpoints {
+		index := (fs.active + i) % len(fs.endpoints)
+		if !fs.started[index] || (i > 0 && !fs.healthy[index]) {
+			continue
+		}
+		err := sign(fs.endpoints[index])
+		if err == nil {
+			if index != fs.active {
+				fs.Logger.Info("Failed over to signer endpoint", "from", fs.active, "to", index)
+				fs.active = index
+			}
+			return nil
+		}
+		if _, ok := err.(*RemoteSignerError); ok {
+			// the signer refused to sign
+			return err
+		}
+		fs.Logger.Error("Signer endpoint failed", "index", index, "err", err)
+		fs.healthy[index] = false
+		errs = append(errs, fmt.Sprintf("endpoint %d: %v", index, err))
+	}
+	return fmt.Errorf("no signer endpoint signed: %v", errs)
+}
+
+// String returns a string representation of the FailoverSigner.
+func (fs *FailoverSigner) String() string {
+	fs.mtx.Lock()
+	defer fs.mtx.Unlock()
+	return fmt.Sprintf("FailoverSigner{active: %d, healthy: %v}", fs.active, fs.healthy)
+}
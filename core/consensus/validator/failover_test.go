@@ -0,0 +1,212 @@
+package validator
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teragrid/dgrid/core/types"
+	cmn "github.com/teragrid/dgrid/pkg/common"
+	"github.com/teragrid/dgrid/pkg/crypto"
+	"github.com/teragrid/dgrid/pkg/log"
+)
+
+// testFailoverEndpoint is a FailoverEndpoint which can be made unreachable.
+type testFailoverEndpoint struct {
+	cmn.BaseService
+
+	privVal types.Validator
+
+	mtx  sync.Mutex
+	down bool
+}
+
+func newTestFailoverEndpoint(privVal types.Validator) *testFailoverEndpoint {
+	e := &testFailoverEndpoint{privVal: privVal}
+	e.BaseService = *cmn.NewBaseService(log.TestingLogger(), "testFailoverEndpoint", e)
+	return e
+}
+
+func (e *testFailoverEndpoint) setDown(down bool) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	e.down = down
+}
+
+func (e *testFailoverEndpoint) check() error {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	if e.down {
+		return ErrConnTimeout
+	}
+	return nil
+}
+
+func (e *testFailoverEndpoint) GetPubKey() crypto.PubKey {
+	return e.privVal.GetPubKey()
+}
+
+func (e *testFailoverEndpoint) SignVote(chainID string, vote *types.Vote) error {
+	if err := e.check(); err != nil {
+		return err
+	}
+	if err := e.privVal.SignVote(chainID, vote); err != nil {
+		return &RemoteSignerError{0, err.Error()}
+	}
+	return nil
+}
+
+func (e *testFailoverEndpoint) SignProposal(chainID string, proposal *types.Proposal) error {
+	if err := e.check(); err != nil {
+		return err
+	}
+	if err := e.privVal.SignProposal(chainID, proposal); err != nil {
+		return &RemoteSignerError{0, err.Error()}
+	}
+	return nil
+}
+
+func (e *testFailoverEndpoint) Ping() error {
+	return e.check()
+}
+
+func TestFailoverSigner(t *testing.T) {
+	tempStateFile, err := ioutil.TempFile("", "validator_state_")
+	require.Nil(t, err)
+	require.Nil(t, os.Remove(tempStateFile.Name()))
+	pvs := testSharedFilePVs(t, 2, tempStateFile.Name())
+	endpoints := []*testFailoverEndpoint{
+		newTestFailoverEndpoint(pvs[0]),
+		newTestFailoverEndpoint(pvs[1]),
+	}
+
+	fs := NewFailoverSigner(log.TestingLogger(), []FailoverEndpoint{endpoints[0], endpoints[1]})
+	FailoverSignerSetHeartbeat(testTimeoutHeartbeat)(fs)
+	require.NoError(t, fs.Start())
+	defer fs.Stop()
+	testWaitFailoverStarted(t, fs)
+	assert.Equal(t, pvs[0].GetPubKey(), fs.GetPubKey())
+
+	block1 := types.BlockID{Hash: []byte{1, 2, 3}}
+	block2 := types.BlockID{Hash: []byte{3, 2, 1}}
+	voteType := byte(types.PrevoteType)
+
+	vote := newVote(pvs[0].Key.Address, 0, 10, 1, voteType, block1)
+	require.NoError(t, fs.SignVote("mychainid", vote))
+	active := fs.active
+
+	// the active signer signed a vote, but the validator didn't get it:
+	// the next signer returns the same signature, and refuses a
+	// conflicting vote
+	signed := newVote(pvs[0].Key.Address, 0, 11, 1, voteType, block1)
+	require.NoError(t, pvs[active].SignVote("mychainid", signed.Copy()))
+	endpoints[active].setDown(true)
+
+	conflicting := newVote(pvs[0].Key.Address, 0, 11, 1, voteType, block2)
+	err = fs.SignVote("mychainid", conflicting)
+	require.Error(t, err)
+	assert.Nil(t, conflicting.Signature)
+
+	require.NoError(t, fs.SignVote("mychainid", signed))
+	assert.NoError(t, signed.Verify("mychainid", fs.GetPubKey()))
+	assert.Equal(t, 1-active, fs.active)
+
+	// no signer left
+	endpoints[1-active].setDown(true)
+	next := newVote(pvs[0].Key.Address, 0, 12, 1, voteType, block1)
+	assert.Error(t, fs.SignVote("mychainid", next))
+
+	// the signer is back
+	endpoints[active].setDown(false)
+	time.Sleep(testTimeoutHeartbeat * 3)
+	require.NoError(t, fs.SignVote("mychainid", next))
+	assert.Equal(t, active, fs.active)
+}
+
+func TestFailoverSignerSignStateStore(t *testing.T) {
+	tempStateFile, err := ioutil.TempFile("", "validator_failover_state_")
+	require.Nil(t, err)
+	require.Nil(t, os.Remove(tempStateFile.Name()))
+
+	// the endpoints don't share their sign state
+	pv := types.NewMockPV()
+	endpoints := []*testFailoverEndpoint{
+		newTestFailoverEndpoint(pv),
+		newTestFailoverEndpoint(pv),
+	}
+	fs := NewFailoverSigner(log.TestingLogger(), []FailoverEndpoint{endpoints[0], endpoints[1]})
+	FailoverSignerSetHeartbeat(testTimeoutHeartbeat)(fs)
+	FailoverSignerSetSignStateStore(NewFileSignStateStore(tempStateFile.Name()))(fs)
+	require.NoError(t, fs.Start())
+	defer fs.Stop()
+	testWaitFailoverStarted(t, fs)
+
+	block1 := types.BlockID{Hash: []byte{1, 2, 3}}
+	block2 := types.BlockID{Hash: []byte{3, 2, 1}}
+	voteType := byte(types.PrevoteType)
+
+	vote := newVote(pv.GetPubKey().Address(), 0, 10, 1, voteType, block1)
+	require.NoError(t, fs.SignVote("mychainid", vote))
+	endpoints[fs.active].setDown(true)
+
+	// the next endpoint is never asked to sign a conflicting vote
+	conflicting := newVote(pv.GetPubKey().Address(), 0, 10, 1, voteType, block2)
+	assert.Error(t, fs.SignVote("mychainid", conflicting))
+	assert.Nil(t, conflicting.Signature)
+	regression := newVote(pv.GetPubKey().Address(), 0, 9, 1, voteType, block2)
+	assert.Error(t, fs.SignVote("mychainid", regression))
+
+	same := newVote(pv.GetPubKey().Address(), 0, 10, 1, voteType, block1)
+	require.NoError(t, fs.SignVote("mychainid", same))
+	assert.Equal(t, vote.Signature, same.Signature)
+	assert.Equal(t, vote.Timestamp, same.Timestamp)
+
+	next := newVote(pv.GetPubKey().Address(), 0, 11, 1, voteType, block2)
+	require.NoError(t, fs.SignVote("mychainid", next))
+	assert.NoError(t, next.Verify("mychainid", fs.GetPubKey()))
+}
+
+func TestFailoverSignerKeys(t *testing.T) {
+	pv := types.NewMockPV()
+	fs := NewFailoverSigner(log.TestingLogger(), []FailoverEndpoint{
+		newTestFailoverEndpoint(pv),
+		newTestFailoverEndpoint(types.NewMockPV()),
+		newTestFailoverEndpoint(pv),
+	})
+	require.NoError(t, fs.Start())
+	defer fs.Stop()
+	time.Sleep(testTimeoutHeartbeat)
+
+	// an endpoint with another key is never used
+	pubKey := fs.GetPubKey()
+	for i := range fs.endpoints {
+		fs.mtx.Lock()
+		started := fs.started[i]
+		fs.mtx.Unlock()
+		if started {
+			assert.Equal(t, pubKey, fs.endpoints[i].GetPubKey())
+		}
+	}
+}
+
+// testWaitFailoverStarted waits for the start of all the endpoints of fs.
+func testWaitFailoverStarted(t *testing.T, fs *FailoverSigner) {
+	for i := 0; i < 100; i++ {
+		fs.mtx.Lock()
+		started := true
+		for _, s := range fs.started {
+			started = started && s
+		}
+		fs.mtx.Unlock()
+		if started {
+			return
+		}
+		time.Sleep(testTimeoutHeartbeat)
+	}
+	t.Fatal("the endpoints didn't start")
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/teragrid/dgrid/core/types"
@@ -12,6 +14,7 @@ import (
 	cmn "github.com/teragrid/dgrid/pkg/common"
 	"github.com/teragrid/dgrid/pkg/crypto"
 	"github.com/teragrid/dgrid/pkg/crypto/ed25519"
+	"github.com/teragrid/dgrid/pkg/crypto/keyfile"
 )
 
 // TODO: type ?
@@ -42,10 +45,12 @@ type FilePVKey struct {
 	PubKey  crypto.PubKey  `json:"pub_key"`
 	PrivKey crypto.PrivKey `json:"priv_key"`
 
-	filePath string
+	filePath   string
+	passphrase keyfile.Passphrase // nil if the key file isn't encrypted
 }
 
-// Save persists the FilePVKey to its filePath.
+// Save persists the FilePVKey to its filePath, encrypted if it has a
+// passphrase.
 func (pvKey FilePVKey) Save() {
 	outFile := pvKey.filePath
 	if outFile == "" {
@@ -56,7 +61,7 @@ func (pvKey FilePVKey) Save() {
 	if err != nil {
 		panic(err)
 	}
-	err = cmn.WriteFileAtomic(outFile, jsonBytes, 0600)
+	err = keyfile.WriteFile(outFile, jsonBytes, pvKey.passphrase)
 	if err != nil {
 		panic(err)
 	}
@@ -65,25 +70,25 @@ func (pvKey FilePVKey) Save() {
 
 //-------------------------------------------------------------------------------
 
-// FilePVLastSignState stores the mutable part of Validator.
-type FilePVLastSignState struct {
+// FilePVLeagueSignState is the last height/round/step (HRS) signed by a
+// FilePV in a league, with the signature.
+type FilePVLeagueSignState struct {
+	LeagueID  string       `json:"league_id"`
 	Height    int64        `json:"height"`
 	Round     int          `json:"round"`
 	Step      int8         `json:"step"`
 	Signature []byte       `json:"signature,omitempty"`
 	SignBytes cmn.HexBytes `json:"signbytes,omitempty"`
-
-	filePath string
 }
 
 // CheckHRS checks the given height, round, step (HRS) against that of the
-// FilePVLastSignState. It returns an error if the arguments constitute a regression,
+// FilePVLeagueSignState. It returns an error if the arguments constitute a regression,
 // or if they match but the SignBytes are empty.
 // The returned boolean indicates whether the last Signature should be reused -
 // it returns true if the HRS matches the arguments and the SignBytes are not empty (indicating
 // we have already signed for this HRS, and can reuse the existing signature).
 // It panics if the HRS matches the arguments, there's a SignBytes, but no Signature.
-func (lss *FilePVLastSignState) CheckHRS(height int64, round int, step int8) (bool, error) {
+func (lss *FilePVLeagueSignState) CheckHRS(height int64, round int, step int8) (bool, error) {
 
 	if lss.Height > height {
 		return false, fmt.Errorf("height regression. Got %v, last height %v", height, lss.Height)
@@ -111,6 +116,52 @@ func (lss *FilePVLastSignState) CheckHRS(height int64, round int, step int8) (bo
 	return false, nil
 }
 
+// FilePVLastSignState stores the mutable part of Validator: the last
+// signature of the validator in each league it signs for. A validator
+// never signs conflicting messages within a league, while the leagues
+// sharing its key progress independently.
+type FilePVLastSignState struct {
+	Leagues []*FilePVLeagueSignState `json:"leagues,omitempty"`
+
+	filePath string
+}
+
+// League returns the sign state of a league, or nil if the validator never
+// signed for it.
+func (lss *FilePVLastSignState) League(leagueID string) *FilePVLeagueSignState {
+	for _, league := range lss.Leagues {
+		if league.LeagueID == leagueID {
+			return league
+		}
+	}
+	return nil
+}
+
+// AssignLeague assigns the sign state of a state file saved before the sign
+// states were kept by league to the league the validator signed for, and
+// persists it. It does nothing if there's no such sign state.
+func (lss *FilePVLastSignState) AssignLeague(leagueID string) error {
+	legacy := lss.League("")
+	if legacy == nil || leagueID == "" {
+		return nil
+	}
+	if lss.League(leagueID) != nil {
+		return fmt.Errorf("league %v already has a sign state", leagueID)
+	}
+	legacy.LeagueID = leagueID
+	lss.Save()
+	return nil
+}
+
+// setLeague sets the sign state of a league.
+func (lss *FilePVLastSignState) setLeague(state FilePVLeagueSignState) {
+	if league := lss.League(state.LeagueID); league != nil {
+		*league = state
+		return
+	}
+	lss.Leagues = append(lss.Leagues, &state)
+}
+
 // Save persists the FilePvLastSignState to its filePath.
 func (lss *FilePVLastSignState) Save() {
 	outFile := lss.filePath
@@ -134,9 +185,13 @@ func (lss *FilePVLastSignState) Save() {
 // NOTE: the directories containing pv.Key.filePath and pv.LastSignState.filePath must already exist.
 // It includes the LastSignature and LastSignBytes so we don't lose the signature
 // if the process crashes after signing but before the resulting consensus message is processed.
+// The sign state is kept by league: the engines of several leagues can share a FilePV.
 type FilePV struct {
 	Key           FilePVKey
 	LastSignState FilePVLastSignState
+
+	mtx        sync.Mutex     // guards LastSignState
+	stateStore SignStateStore // nil if the sign state is only the one of LastSignState
 }
 
 // GenFilePV generates a new validator with randomly generated private key
@@ -152,7 +207,6 @@ func GenFilePV(keyFilePath, stateFilePath string) *FilePV {
 			filePath: keyFilePath,
 		},
 		LastSignState: FilePVLastSignState{
-			Step:     stepNone,
 			filePath: stateFilePath,
 		},
 	}
@@ -162,20 +216,27 @@ func GenFilePV(keyFilePath, stateFilePath string) *FilePV {
 // signing prevention by persisting data to the stateFilePath.  If either file path
 // does not exist, the program will exit.
 func LoadFilePV(keyFilePath, stateFilePath string) *FilePV {
-	return loadFilePV(keyFilePath, stateFilePath, true)
+	return loadFilePV(keyFilePath, stateFilePath, true, nil)
+}
+
+// LoadFilePVWithPassphrase loads a FilePV like LoadFilePV, decrypting the
+// key file with passphrase if it is encrypted. The key file is encrypted
+// with passphrase when saved again.
+func LoadFilePVWithPassphrase(keyFilePath, stateFilePath string, passphrase keyfile.Passphrase) *FilePV {
+	return loadFilePV(keyFilePath, stateFilePath, true, passphrase)
 }
 
 // LoadFilePVEmptyState loads a FilePV from the given keyFilePath, with an empty LastSignState.
 // If the keyFilePath does not exist, the program will exit.
 func LoadFilePVEmptyState(keyFilePath, stateFilePath string) *FilePV {
-	return loadFilePV(keyFilePath, stateFilePath, false)
+	return loadFilePV(keyFilePath, stateFilePath, false, nil)
 }
 
 // If loadState is true, we load from the stateFilePath. Otherwise, we use an empty LastSignState.
-func loadFilePV(keyFilePath, stateFilePath string, loadState bool) *FilePV {
-	keyJSONBytes, err := ioutil.ReadFile(keyFilePath)
+func loadFilePV(keyFilePath, stateFilePath string, loadState bool, passphrase keyfile.Passphrase) *FilePV {
+	keyJSONBytes, err := keyfile.ReadFile(keyFilePath, passphrase)
 	if err != nil {
-		cmn.Exit(err.Error())
+		cmn.Exit(fmt.Sprintf("Error reading Validator key from %v: %v\n", keyFilePath, err))
 	}
 	pvKey := FilePVKey{}
 	err = cdc.UnmarshalJSON(keyJSONBytes, &pvKey)
@@ -187,6 +248,7 @@ func loadFilePV(keyFilePath, stateFilePath string, loadState bool) *FilePV {
 	pvKey.PubKey = pvKey.PrivKey.PubKey()
 	pvKey.Address = pvKey.PubKey.Address()
 	pvKey.filePath = keyFilePath
+	pvKey.passphrase = passphrase
 
 	pvState := FilePVLastSignState{}
 	if loadState {
@@ -198,6 +260,19 @@ func loadFilePV(keyFilePath, stateFilePath string, loadState bool) *FilePV {
 		if err != nil {
 			cmn.Exit(fmt.Sprintf("Error reading Validator state from %v: %v\n", stateFilePath, err))
 		}
+		if len(pvState.Leagues) == 0 {
+			// the state file was saved before the sign states were kept by
+			// league: it holds the state of the league the validator signed
+			// for, see AssignLeague
+			legacyState := FilePVLeagueSignState{}
+			err = cdc.UnmarshalJSON(stateJSONBytes, &legacyState)
+			if err != nil {
+				cmn.Exit(fmt.Sprintf("Error reading Validator state from %v: %v\n", stateFilePath, err))
+			}
+			if legacyState.Height != 0 || legacyState.Step != stepNone {
+				pvState.Leagues = []*FilePVLeagueSignState{&legacyState}
+			}
+		}
 	}
 
 	pvState.filePath = stateFilePath
@@ -221,6 +296,21 @@ func LoadOrGenFilePV(keyFilePath, stateFilePath string) *FilePV {
 	return pv
 }
 
+// LoadOrGenFilePVWithPassphrase loads a FilePV like
+// LoadFilePVWithPassphrase, or else generates a new one and saves it to the
+// filePaths, with the key file encrypted with passphrase.
+func LoadOrGenFilePVWithPassphrase(keyFilePath, stateFilePath string, passphrase keyfile.Passphrase) *FilePV {
+	var pv *FilePV
+	if cmn.FileExists(keyFilePath) {
+		pv = LoadFilePVWithPassphrase(keyFilePath, stateFilePath, passphrase)
+	} else {
+		pv = GenFilePV(keyFilePath, stateFilePath)
+		pv.Key.passphrase = passphrase
+		pv.Save()
+	}
+	return pv
+}
+
 // GetAddress returns the address of the validator.
 // Implements Validator.
 func (pv *FilePV) GetAddress() types.Address {
@@ -236,7 +326,12 @@ func (pv *FilePV) GetPubKey() crypto.PubKey {
 // SignVote signs a canonical representation of the vote, along with the
 // chainID. Implements Validator.
 func (pv *FilePV) SignVote(chainID string, vote *types.Vote) error {
-	if err := pv.signVote(chainID, vote); err != nil {
+	pv.mtx.Lock()
+	defer pv.mtx.Unlock()
+	err := pv.withSignState(func() error {
+		return pv.signVote(chainID, vote)
+	})
+	if err != nil {
 		return fmt.Errorf("error signing vote: %v", err)
 	}
 	return nil
@@ -245,33 +340,92 @@ func (pv *FilePV) SignVote(chainID string, vote *types.Vote) error {
 // SignProposal signs a canonical representation of the proposal, along with
 // the chainID. Implements Validator.
 func (pv *FilePV) SignProposal(chainID string, proposal *types.Proposal) error {
-	if err := pv.signProposal(chainID, proposal); err != nil {
+	pv.mtx.Lock()
+	defer pv.mtx.Unlock()
+	err := pv.withSignState(func() error {
+		return pv.signProposal(chainID, proposal)
+	})
+	if err != nil {
 		return fmt.Errorf("error signing proposal: %v", err)
 	}
 	return nil
 }
 
+// SignStatement signs a statement of the federated (SCP) consensus.
+// Statements are not subject to the height/round/step checks of votes and
+// proposals, since SCP legitimately emits many statements for a height.
+// Implements types.StatementSigner.
+func (pv *FilePV) SignStatement(chainID string, stmt *types.SCPStatement) error {
+	sig, err := pv.Key.PrivKey.Sign(stmt.SignBytes(chainID))
+	if err != nil {
+		return fmt.Errorf("error signing statement: %v", err)
+	}
+	stmt.Signature = sig
+	return nil
+}
+
+// SignConfigVote signs the approval of a proposal to update the
+// configuration of a league. Implements types.ConfigVoteSigner.
+func (pv *FilePV) SignConfigVote(chainID string, vote *types.ConfigVote) error {
+	sig, err := pv.Key.PrivKey.Sign(vote.SignBytes(chainID))
+	if err != nil {
+		return fmt.Errorf("error signing config vote: %v", err)
+	}
+	vote.Signature = sig
+	return nil
+}
+
 // Save persists the FilePV to disk.
 func (pv *FilePV) Save() {
+	pv.mtx.Lock()
+	defer pv.mtx.Unlock()
 	pv.Key.Save()
 	pv.LastSignState.Save()
 }
 
-// Reset resets all fields in the FilePV.
+// AssignLeague assigns the sign state saved before the sign states were
+// kept by league to leagueID, see FilePVLastSignState.AssignLeague.
+func (pv *FilePV) AssignLeague(leagueID string) error {
+	pv.mtx.Lock()
+	defer pv.mtx.Unlock()
+	return pv.LastSignState.AssignLeague(leagueID)
+}
+
+// SetSignStateStore makes the FilePV sign against the last sign state of
+// store, shared with the other signers of its key, rather than only its
+// own. The sign state of the FilePV is saved to store from then on.
+func (pv *FilePV) SetSignStateStore(store SignStateStore) {
+	pv.mtx.Lock()
+	defer pv.mtx.Unlock()
+	pv.stateStore = store
+}
+
+// EncryptKey saves the key file of the FilePV encrypted with passphrase.
+func (pv *FilePV) EncryptKey(passphrase keyfile.Passphrase) {
+	pv.mtx.Lock()
+	defer pv.mtx.Unlock()
+	pv.Key.passphrase = passphrase
+	pv.Key.Save()
+}
+
+// Reset resets the sign states of all the leagues in the FilePV.
 // NOTE: Unsafe!
 func (pv *FilePV) Reset() {
-	var sig []byte
-	pv.LastSignState.Height = 0
-	pv.LastSignState.Round = 0
-	pv.LastSignState.Step = 0
-	pv.LastSignState.Signature = sig
-	pv.LastSignState.SignBytes = nil
+	pv.mtx.Lock()
+	pv.LastSignState.Leagues = nil
+	pv.mtx.Unlock()
 	pv.Save()
 }
 
 // String returns a string representation of the FilePV.
 func (pv *FilePV) String() string {
-	return fmt.Sprintf("Validator{%v LH:%v, LR:%v, LS:%v}", pv.GetAddress(), pv.LastSignState.Height, pv.LastSignState.Round, pv.LastSignState.Step)
+	pv.mtx.Lock()
+	defer pv.mtx.Unlock()
+	leagues := make([]string, len(pv.LastSignState.Leagues))
+	for i, lss := range pv.LastSignState.Leagues {
+		leagues[i] = fmt.Sprintf("%v LH:%v, LR:%v, LS:%v", lss.LeagueID, lss.Height, lss.Round, lss.Step)
+	}
+	return fmt.Sprintf("Validator{%v [%v]}", pv.GetAddress(), strings.Join(leagues, "; "))
 }
 
 //------------------------------------------------------------------------------------
@@ -282,7 +436,10 @@ func (pv *FilePV) String() string {
 func (pv *FilePV) signVote(chainID string, vote *types.Vote) error {
 	height, round, step := vote.Height, vote.Round, voteToStep(vote)
 
-	lss := pv.LastSignState
+	lss, err := pv.leagueSignState(chainID)
+	if err != nil {
+		return err
+	}
 
 	sameHRS, err := lss.CheckHRS(height, round, step)
 	if err != nil {
@@ -313,7 +470,7 @@ func (pv *FilePV) signVote(chainID string, vote *types.Vote) error {
 	if err != nil {
 		return err
 	}
-	pv.saveSigned(height, round, step, signBytes, sig)
+	pv.saveSigned(chainID, height, round, step, signBytes, sig)
 	vote.Signature = sig
 	return nil
 }
@@ -324,7 +481,10 @@ func (pv *FilePV) signVote(chainID string, vote *types.Vote) error {
 func (pv *FilePV) signProposal(chainID string, proposal *types.Proposal) error {
 	height, round, step := proposal.Height, proposal.Round, stepPropose
 
-	lss := pv.LastSignState
+	lss, err := pv.leagueSignState(chainID)
+	if err != nil {
+		return err
+	}
 
 	sameHRS, err := lss.CheckHRS(height, round, step)
 	if err != nil {
@@ -355,21 +515,56 @@ func (pv *FilePV) signProposal(chainID string, proposal *types.Proposal) error {
 	if err != nil {
 		return err
 	}
-	pv.saveSigned(height, round, step, signBytes, sig)
+	pv.saveSigned(chainID, height, round, step, signBytes, sig)
 	proposal.Signature = sig
 	return nil
 }
 
-// Persist height/round/step and signature
-func (pv *FilePV) saveSigned(height int64, round int, step int8,
+// withSignState calls sign with the last sign state of the store of the
+// FilePV, if any, which is locked meanwhile. The resulting sign state is
+// saved to the store.
+func (pv *FilePV) withSignState(sign func() error) error {
+	if pv.stateStore == nil {
+		return sign()
+	}
+	return pv.stateStore.Update(func(lss *FilePVLastSignState) error {
+		pv.LastSignState.Leagues = lss.Leagues
+		if err := sign(); err != nil {
+			return err
+		}
+		lss.Leagues = pv.LastSignState.Leagues
+		return nil
+	})
+}
+
+// leagueSignState returns the sign state of a league to sign for. It
+// refuses to sign while the league of the sign state of an older state file
+// is unknown, since it may be this one.
+func (pv *FilePV) leagueSignState(leagueID string) (*FilePVLeagueSignState, error) {
+	if lss := pv.LastSignState.League(leagueID); lss != nil {
+		return lss, nil
+	}
+	if pv.LastSignState.League("") != nil {
+		return nil, errors.New("the league of the last sign state is unknown, it must be assigned first")
+	}
+	return &FilePVLeagueSignState{LeagueID: leagueID}, nil
+}
+
+// Persist height/round/step and signature of the league
+func (pv *FilePV) saveSigned(leagueID string, height int64, round int, step int8,
 	signBytes []byte, sig []byte) {
 
-	pv.LastSignState.Height = height
-	pv.LastSignState.Round = round
-	pv.LastSignState.Step = step
-	pv.LastSignState.Signature = sig
-	pv.LastSignState.SignBytes = signBytes
-	pv.LastSignState.Save()
+	pv.LastSignState.setLeague(FilePVLeagueSignState{
+		LeagueID:  leagueID,
+		Height:    height,
+		Round:     round,
+		Step:      step,
+		Signature: sig,
+		SignBytes: signBytes,
+	})
+	if pv.stateStore == nil {
+		pv.LastSignState.Save()
+	}
 }
 
 //-----------------------------------------------------------------------------------------
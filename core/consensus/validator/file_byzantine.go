@@ -0,0 +1,17 @@
+//go:build byzantine
+// +build byzantine
+
+package validator
+
+import (
+	"github.com/teragrid/dgrid/core/types"
+)
+
+// ConflictingSigner returns a validator signing with the key of the FilePV
+// whatever it is given, without checking nor updating the last sign state.
+// It is only built with the byzantine tag, so that a production binary
+// can't double sign.
+// NOTE: Unsafe! Only meant for the byzantine behaviors of a test validator.
+func (pv *FilePV) ConflictingSigner() types.Validator {
+	return types.NewMockPVWithParams(pv.Key.PrivKey, false, false)
+}
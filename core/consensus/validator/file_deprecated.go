@@ -56,13 +56,17 @@ func (oldFilePV *OldFilePV) Upgrade(keyFilePath, stateFilePath string) *FilePV {
 		filePath: keyFilePath,
 	}
 
+	// the league of the old sign state is unknown until it's assigned, see
+	// FilePVLastSignState.AssignLeague
 	pvState := FilePVLastSignState{
-		Height:    oldFilePV.LastHeight,
-		Round:     oldFilePV.LastRound,
-		Step:      oldFilePV.LastStep,
-		Signature: oldFilePV.LastSignature,
-		SignBytes: oldFilePV.LastSignBytes,
-		filePath:  stateFilePath,
+		Leagues: []*FilePVLeagueSignState{{
+			Height:    oldFilePV.LastHeight,
+			Round:     oldFilePV.LastRound,
+			Step:      oldFilePV.LastStep,
+			Signature: oldFilePV.LastSignature,
+			SignBytes: oldFilePV.LastSignBytes,
+		}},
+		filePath: stateFilePath,
 	}
 
 	// Save the new PV files
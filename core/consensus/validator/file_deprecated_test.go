@@ -59,11 +59,14 @@ func assertEqualPV(t *testing.T, oldPV *validator.OldFilePV, newPV *validator.Fi
 	assert.Equal(t, oldPV.PubKey, newPV.GetPubKey())
 	assert.Equal(t, oldPV.PrivKey, newPV.Key.PrivKey)
 
-	assert.Equal(t, oldPV.LastHeight, newPV.LastSignState.Height)
-	assert.Equal(t, oldPV.LastRound, newPV.LastSignState.Round)
-	assert.Equal(t, oldPV.LastSignature, newPV.LastSignState.Signature)
-	assert.Equal(t, oldPV.LastSignBytes, newPV.LastSignState.SignBytes)
-	assert.Equal(t, oldPV.LastStep, newPV.LastSignState.Step)
+	// the league of the old sign state is unknown
+	lss := newPV.LastSignState.League("")
+	require.NotNil(t, lss)
+	assert.Equal(t, oldPV.LastHeight, lss.Height)
+	assert.Equal(t, oldPV.LastRound, lss.Round)
+	assert.Equal(t, oldPV.LastSignature, lss.Signature)
+	assert.Equal(t, oldPV.LastSignBytes, lss.SignBytes)
+	assert.Equal(t, oldPV.LastStep, lss.Step)
 }
 
 func initTmpOldFile(t *testing.T) string {
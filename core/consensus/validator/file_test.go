@@ -11,6 +11,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/teragrid/dgrid/pkg/crypto/ed25519"
+	"github.com/teragrid/dgrid/pkg/crypto/keyfile"
 	"github.com/teragrid/dgrid/core/types"
 	tmtime "github.com/teragrid/dgrid/core/types/time"
 )
@@ -26,13 +27,14 @@ func TestGenLoadValidator(t *testing.T) {
 	privVal := GenFilePV(tempKeyFile.Name(), tempStateFile.Name())
 
 	height := int64(100)
-	privVal.LastSignState.Height = height
+	privVal.LastSignState.setLeague(FilePVLeagueSignState{LeagueID: "mychainid", Height: height})
 	privVal.Save()
 	addr := privVal.GetAddress()
 
 	privVal = LoadFilePV(tempKeyFile.Name(), tempStateFile.Name())
 	assert.Equal(addr, privVal.GetAddress(), "expected validator addr to be the same")
-	assert.Equal(height, privVal.LastSignState.Height, "expected validator.LastHeight to have been saved")
+	require.NotNil(t, privVal.LastSignState.League("mychainid"))
+	assert.Equal(height, privVal.LastSignState.League("mychainid").Height, "expected validator.LastHeight to have been saved")
 }
 
 func TestResetValidator(t *testing.T) {
@@ -86,14 +88,61 @@ func TestLoadOrGenValidator(t *testing.T) {
 	assert.Equal(addr, privVal.GetAddress(), "expected validator addr to be the same")
 }
 
+func TestEncryptedValidatorKey(t *testing.T) {
+	tempKeyFile, err := ioutil.TempFile("", "validator_key_")
+	require.Nil(t, err)
+	tempStateFile, err := ioutil.TempFile("", "validator_state_")
+	require.Nil(t, err)
+	tempKeyFilePath := tempKeyFile.Name()
+	require.Nil(t, os.Remove(tempKeyFilePath))
+
+	passphrase := keyfile.StaticPassphrase([]byte("passphrase"))
+	privVal := LoadOrGenFilePVWithPassphrase(tempKeyFilePath, tempStateFile.Name(), passphrase)
+	data, err := ioutil.ReadFile(tempKeyFilePath)
+	require.Nil(t, err)
+	assert.True(t, keyfile.IsEncrypted(data))
+
+	loaded := LoadFilePVWithPassphrase(tempKeyFilePath, tempStateFile.Name(), passphrase)
+	assert.Equal(t, privVal.Key.PrivKey, loaded.Key.PrivKey)
+
+	// saving again keeps the key file encrypted
+	loaded.Reset()
+	data, err = ioutil.ReadFile(tempKeyFilePath)
+	require.Nil(t, err)
+	assert.True(t, keyfile.IsEncrypted(data))
+
+	// a plaintext key file can be encrypted
+	plainKeyFile, err := ioutil.TempFile("", "validator_key_")
+	require.Nil(t, err)
+	plain := GenFilePV(plainKeyFile.Name(), tempStateFile.Name())
+	plain.Save()
+	plain.EncryptKey(passphrase)
+	data, err = ioutil.ReadFile(plainKeyFile.Name())
+	require.Nil(t, err)
+	assert.True(t, keyfile.IsEncrypted(data))
+	loaded = LoadFilePVWithPassphrase(plainKeyFile.Name(), tempStateFile.Name(), passphrase)
+	assert.Equal(t, plain.Key.PrivKey, loaded.Key.PrivKey)
+}
+
 func TestUnmarshalValidatorState(t *testing.T) {
 	assert, require := assert.New(t), require.New(t)
 
 	// create some fixed values
 	serialized := `{
-		"height": "1",
-		"round": "1",
-		"step": 1
+		"leagues": [
+			{
+				"league_id": "base",
+				"height": "1",
+				"round": "1",
+				"step": 1
+			},
+			{
+				"league_id": "regular",
+				"height": "20",
+				"round": "0",
+				"step": 3
+			}
+		]
 	}`
 
 	val := FilePVLastSignState{}
@@ -101,9 +150,13 @@ func TestUnmarshalValidatorState(t *testing.T) {
 	require.Nil(err, "%+v", err)
 
 	// make sure the values match
-	assert.EqualValues(val.Height, 1)
-	assert.EqualValues(val.Round, 1)
-	assert.EqualValues(val.Step, 1)
+	require.NotNil(val.League("base"))
+	assert.EqualValues(val.League("base").Height, 1)
+	assert.EqualValues(val.League("base").Round, 1)
+	assert.EqualValues(val.League("base").Step, 1)
+	require.NotNil(val.League("regular"))
+	assert.EqualValues(val.League("regular").Height, 20)
+	assert.Nil(val.League("other"))
 
 	// export it and make sure it is the same
 	out, err := cdc.MarshalJSON(val)
@@ -111,6 +164,43 @@ func TestUnmarshalValidatorState(t *testing.T) {
 	assert.JSONEq(serialized, string(out))
 }
 
+func TestLoadLegacyValidatorState(t *testing.T) {
+	tempKeyFile, err := ioutil.TempFile("", "validator_key_")
+	require.Nil(t, err)
+	tempStateFile, err := ioutil.TempFile("", "validator_state_")
+	require.Nil(t, err)
+	GenFilePV(tempKeyFile.Name(), tempStateFile.Name()).Save()
+
+	// a state file saved before the sign states were kept by league
+	legacy := `{
+		"height": "10",
+		"round": "1",
+		"step": 2
+	}`
+	require.NoError(t, ioutil.WriteFile(tempStateFile.Name(), []byte(legacy), 0600))
+
+	privVal := LoadFilePV(tempKeyFile.Name(), tempStateFile.Name())
+	require.NotNil(t, privVal.LastSignState.League(""))
+	assert.Nil(t, privVal.LastSignState.League("base"))
+
+	// no league is signed for until the old state is assigned
+	blockID := types.BlockID{[]byte{1, 2, 3}, types.PartSetHeader{}}
+	vote := newVote(privVal.Key.Address, 0, 9, 0, byte(types.PrevoteType), blockID)
+	assert.Error(t, privVal.SignVote("regular", vote))
+
+	// once assigned, the old state protects its league only
+	require.NoError(t, privVal.AssignLeague("base"))
+	assert.Nil(t, privVal.LastSignState.League(""))
+	assert.Error(t, privVal.SignVote("base", vote), "expected error on height regression")
+	assert.NoError(t, privVal.SignVote("regular", vote))
+
+	privVal = LoadFilePV(tempKeyFile.Name(), tempStateFile.Name())
+	require.NotNil(t, privVal.LastSignState.League("base"))
+	assert.EqualValues(t, 10, privVal.LastSignState.League("base").Height)
+	assert.EqualValues(t, 9, privVal.LastSignState.League("regular").Height)
+	assert.NoError(t, privVal.AssignLeague("base"), "expected nothing left to assign")
+}
+
 func TestUnmarshalValidatorKey(t *testing.T) {
 	assert, require := assert.New(t), require.New(t)
 
@@ -241,6 +331,38 @@ func TestSignProposal(t *testing.T) {
 	assert.Equal(sig, proposal.Signature)
 }
 
+func TestSignVoteLeagues(t *testing.T) {
+	tempKeyFile, err := ioutil.TempFile("", "validator_key_")
+	require.Nil(t, err)
+	tempStateFile, err := ioutil.TempFile("", "validator_state_")
+	require.Nil(t, err)
+
+	privVal := GenFilePV(tempKeyFile.Name(), tempStateFile.Name())
+
+	block1 := types.BlockID{[]byte{1, 2, 3}, types.PartSetHeader{}}
+	block2 := types.BlockID{[]byte{3, 2, 1}, types.PartSetHeader{}}
+	voteType := byte(types.PrecommitType)
+
+	// the leagues sharing the key progress independently
+	require.NoError(t, privVal.SignVote("base", newVote(privVal.Key.Address, 0, 100, 0, voteType, block1)))
+	require.NoError(t, privVal.SignVote("regular", newVote(privVal.Key.Address, 0, 5, 0, voteType, block2)))
+	require.NoError(t, privVal.SignProposal("other", newProposal(1, 0, block1)))
+
+	// but never sign conflicting messages within a league
+	assert.Error(t, privVal.SignVote("base", newVote(privVal.Key.Address, 0, 100, 0, voteType, block2)))
+	assert.Error(t, privVal.SignVote("base", newVote(privVal.Key.Address, 0, 5, 0, voteType, block2)))
+	assert.Error(t, privVal.SignVote("regular", newVote(privVal.Key.Address, 0, 5, 0, voteType, block1)))
+	assert.NoError(t, privVal.SignVote("regular", newVote(privVal.Key.Address, 0, 6, 0, voteType, block1)))
+
+	// the sign states are persisted by league
+	privVal = LoadFilePV(tempKeyFile.Name(), tempStateFile.Name())
+	assert.Len(t, privVal.LastSignState.Leagues, 3)
+	assert.EqualValues(t, 100, privVal.LastSignState.League("base").Height)
+	assert.EqualValues(t, 6, privVal.LastSignState.League("regular").Height)
+	assert.Equal(t, stepPropose, privVal.LastSignState.League("other").Step)
+	assert.Error(t, privVal.SignVote("base", newVote(privVal.Key.Address, 0, 100, 0, voteType, block2)))
+}
+
 func TestDifferByTimestamp(t *testing.T) {
 	tempKeyFile, err := ioutil.TempFile("", "validator_key_")
 	require.Nil(t, err)
@@ -0,0 +1,19 @@
+//go:build windows
+// +build windows
+
+package validator
+
+import (
+	"errors"
+	"os"
+)
+
+var errFileLockUnsupported = errors.New("file locks are not supported on windows")
+
+func lockFileExclusive(f *os.File) error {
+	return errFileLockUnsupported
+}
+
+func unlockFile(f *os.File) error {
+	return errFileLockUnsupported
+}
@@ -21,8 +21,13 @@ func RegisterRemoteSignerMsg(cdc *amino.Codec) {
 	cdc.RegisterConcrete(&PingResponse{}, "teragrid/remotesigner/PingResponse", nil)
 }
 
-// PubKeyRequest requests the consensus public key from the remote signer.
-type PubKeyRequest struct{}
+// PubKeyRequest requests the consensus public key of a validator of a league
+// from the remote signer. The address selects the key of a league with
+// several keys, see SignerKeys.
+type PubKeyRequest struct {
+	LeagueID string
+	Address  types.Address
+}
 
 // PubKeyResponse is a PrivValidatorSocket message containing the public key.
 type PubKeyResponse struct {
@@ -30,9 +35,12 @@ type PubKeyResponse struct {
 	Error  *RemoteSignerError
 }
 
-// SignVoteRequest is a PrivValidatorSocket message containing a vote.
+// SignVoteRequest is a PrivValidatorSocket message containing a vote, and
+// the league it is signed for. The ValidatorAddress of the vote selects the
+// key signing it.
 type SignVoteRequest struct {
-	Vote *types.Vote
+	Vote     *types.Vote
+	LeagueID string
 }
 
 // SignedVoteResponse is a PrivValidatorSocket message containing a signed vote along with a potenial error message.
@@ -41,9 +49,12 @@ type SignedVoteResponse struct {
 	Error *RemoteSignerError
 }
 
-// SignProposalRequest is a PrivValidatorSocket message containing a Proposal.
+// SignProposalRequest is a PrivValidatorSocket message containing a Proposal,
+// the league it is signed for and the address of the key signing it.
 type SignProposalRequest struct {
-	Proposal *types.Proposal
+	Proposal         *types.Proposal
+	LeagueID         string
+	ValidatorAddress types.Address
 }
 
 // SignedProposalResponse is a PrivValidatorSocket message containing a proposal response
@@ -0,0 +1,68 @@
+package validator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	cmn "github.com/teragrid/dgrid/pkg/common"
+)
+
+// SignStateStore stores the last sign state of a FilePV outside of it, so
+// the signers of a key in high availability share it: each signs under the
+// lock of the store, against the last sign state saved by any of them, so
+// they never sign conflicting messages, even when the validator fails over
+// from a signer which signed but didn't answer in time. See FailoverSigner.
+type SignStateStore interface {
+	// Update locks the store against the other signers, loads the last
+	// sign state, and calls update with it. The sign state is saved if
+	// update returns no error, then the store is unlocked.
+	Update(update func(lss *FilePVLastSignState) error) error
+}
+
+// FileSignStateStore is a SignStateStore in a file locked with an advisory
+// lock, eg. on a file system shared by the signers. The lock is the one of
+// a separate lock file, since the state file is replaced on each save.
+type FileSignStateStore struct {
+	filePath string
+}
+
+var _ SignStateStore = (*FileSignStateStore)(nil)
+
+// NewFileSignStateStore returns a FileSignStateStore in filePath, locked
+// with filePath.lock.
+func NewFileSignStateStore(filePath string) *FileSignStateStore {
+	return &FileSignStateStore{filePath: filePath}
+}
+
+// Update implements SignStateStore.
+func (s *FileSignStateStore) Update(update func(lss *FilePVLastSignState) error) error {
+	lockFile, err := os.OpenFile(s.filePath+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer lockFile.Close() // nolint: errcheck
+	if err := lockFileExclusive(lockFile); err != nil {
+		return fmt.Errorf("error locking %v: %v", lockFile.Name(), err)
+	}
+	defer unlockFile(lockFile) // nolint: errcheck
+
+	lss := FilePVLastSignState{}
+	if cmn.FileExists(s.filePath) {
+		jsonBytes, err := ioutil.ReadFile(s.filePath)
+		if err != nil {
+			return err
+		}
+		if err := cdc.UnmarshalJSON(jsonBytes, &lss); err != nil {
+			return fmt.Errorf("error reading Validator state from %v: %v", s.filePath, err)
+		}
+	}
+	if err := update(&lss); err != nil {
+		return err
+	}
+	jsonBytes, err := cdc.MarshalJSONIndent(&lss, "", "  ")
+	if err != nil {
+		return err
+	}
+	return cmn.WriteFileAtomic(s.filePath, jsonBytes, 0600)
+}
@@ -0,0 +1,85 @@
+package validator
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teragrid/dgrid/core/types"
+)
+
+// testSharedFilePVs returns n FilePVs with the same key, sharing the sign
+// state store of the file filePath.
+func testSharedFilePVs(t *testing.T, n int, filePath string) []*FilePV {
+	tempKeyFile, err := ioutil.TempFile("", "validator_key_")
+	require.Nil(t, err)
+	store := NewFileSignStateStore(filePath)
+
+	pvs := make([]*FilePV, n)
+	for i := range pvs {
+		if i == 0 {
+			pvs[i] = GenFilePV(tempKeyFile.Name(), "")
+		} else {
+			pvs[i] = &FilePV{Key: pvs[0].Key}
+		}
+		pvs[i].SetSignStateStore(store)
+	}
+	return pvs
+}
+
+func TestFileSignStateStore(t *testing.T) {
+	tempStateFile, err := ioutil.TempFile("", "validator_state_")
+	require.Nil(t, err)
+	require.Nil(t, os.Remove(tempStateFile.Name()))
+	pvs := testSharedFilePVs(t, 2, tempStateFile.Name())
+
+	block1 := types.BlockID{Hash: []byte{1, 2, 3}}
+	block2 := types.BlockID{Hash: []byte{3, 2, 1}}
+	height, round := int64(10), 1
+	voteType := byte(types.PrevoteType)
+
+	vote := newVote(pvs[0].Key.Address, 0, height, round, voteType, block1)
+	require.NoError(t, pvs[0].SignVote("mychainid", vote))
+
+	// the other signer of the key returns the same signature, and refuses
+	// to sign a conflicting vote
+	same := newVote(pvs[0].Key.Address, 0, height, round, voteType, block1)
+	same.Timestamp = vote.Timestamp
+	require.NoError(t, pvs[1].SignVote("mychainid", same))
+	assert.Equal(t, vote.Signature, same.Signature)
+
+	conflicting := newVote(pvs[0].Key.Address, 0, height, round, voteType, block2)
+	assert.Error(t, pvs[1].SignVote("mychainid", conflicting))
+	regression := newVote(pvs[0].Key.Address, 0, height-1, round, voteType, block2)
+	assert.Error(t, pvs[1].SignVote("mychainid", regression))
+
+	// the signers sign conflicting votes for the following heights
+	// concurrently, but only one signs each of them
+	var (
+		wg     sync.WaitGroup
+		mtx    sync.Mutex
+		signed = make(map[int64]int)
+	)
+	for i, pv := range pvs {
+		wg.Add(1)
+		go func(i int, pv *FilePV) {
+			defer wg.Done()
+			for h := height + 1; h <= height+20; h++ {
+				v := newVote(pv.Key.Address, 0, h, round, voteType, types.BlockID{Hash: []byte{byte(h), byte(i)}})
+				if pv.SignVote("mychainid", v) == nil {
+					mtx.Lock()
+					signed[h]++
+					mtx.Unlock()
+				}
+			}
+		}(i, pv)
+	}
+	wg.Wait()
+	for h, n := range signed {
+		assert.Equal(t, 1, n, "height %d signed %d times", h, n)
+	}
+}
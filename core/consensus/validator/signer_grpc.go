@@ -0,0 +1,221 @@
+package validator
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/credentials"
+
+	asura "github.com/teragrid/dgrid/asura/types"
+	"github.com/teragrid/dgrid/core/blockchain/p2p"
+	p2pconn "github.com/teragrid/dgrid/core/blockchain/p2p/conn"
+	protosigner "github.com/teragrid/dgrid/core/consensus/validator/proto"
+	"github.com/teragrid/dgrid/core/types"
+	"github.com/teragrid/dgrid/pkg/crypto"
+)
+
+//------------------------------------------------------------------
+// Transport credentials
+
+// SecretConnAuthType is the gRPC auth type of SecretConnCredentials.
+const SecretConnAuthType = "secret_connection"
+
+// SecretConnAuthInfo is the gRPC auth info of a connection authenticated by
+// SecretConnCredentials.
+type SecretConnAuthInfo struct {
+	// ID is the node ID of the remote end.
+	ID p2p.ID
+}
+
+// AuthType implements credentials.AuthInfo.
+func (SecretConnAuthInfo) AuthType() string {
+	return SecretConnAuthType
+}
+
+type secretConnCredentials struct {
+	privKey       crypto.PrivKey
+	authorizedIDs []p2p.ID
+}
+
+// NewSecretConnCredentials returns the gRPC transport credentials of the
+// connections between a validator and its remote signer: they are
+// SecretConnections authenticated with the node key privKey, and the node
+// key of the remote end must be the one of authorizedIDs, so both ends
+// authenticate each other.
+func NewSecretConnCredentials(privKey crypto.PrivKey, authorizedIDs []p2p.ID) credentials.TransportCredentials {
+	return &secretConnCredentials{
+		privKey:       privKey,
+		authorizedIDs: authorizedIDs,
+	}
+}
+
+// ClientHandshake implements credentials.TransportCredentials.
+func (sc *secretConnCredentials) ClientHandshake(
+	ctx context.Context,
+	authority string,
+	rawConn net.Conn,
+) (net.Conn, credentials.AuthInfo, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := rawConn.SetDeadline(deadline); err != nil {
+			return nil, nil, err
+		}
+		defer rawConn.SetDeadline(time.Time{}) // nolint: errcheck
+	}
+	return sc.handshake(rawConn)
+}
+
+// ServerHandshake implements credentials.TransportCredentials.
+func (sc *secretConnCredentials) ServerHandshake(rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return sc.handshake(rawConn)
+}
+
+func (sc *secretConnCredentials) handshake(rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	conn, err := p2pconn.MakeSecretConnection(rawConn, sc.privKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	id := p2p.PubKeyToID(conn.RemotePubKey())
+	for _, authorizedID := range sc.authorizedIDs {
+		if id == authorizedID {
+			return conn, SecretConnAuthInfo{ID: id}, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("%v: %v", ErrUnauthorizedNodeKey, id)
+}
+
+// Info implements credentials.TransportCredentials.
+func (sc *secretConnCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{SecurityProtocol: SecretConnAuthType}
+}
+
+// Clone implements credentials.TransportCredentials.
+func (sc *secretConnCredentials) Clone() credentials.TransportCredentials {
+	return NewSecretConnCredentials(sc.privKey, append([]p2p.ID(nil), sc.authorizedIDs...))
+}
+
+// OverrideServerName implements credentials.TransportCredentials. The
+// server is authenticated by its node key, not its name.
+func (sc *secretConnCredentials) OverrideServerName(string) error {
+	return nil
+}
+
+//------------------------------------------------------------------
+// Messages
+
+func pubKeyToProto(pubKey crypto.PubKey) *protosigner.PubKey {
+	pk := types.TM2PB.PubKey(pubKey)
+	return &protosigner.PubKey{Type: pk.Type, Data: pk.Data}
+}
+
+func pubKeyFromProto(pb *protosigner.PubKey) (crypto.PubKey, error) {
+	if pb == nil {
+		return nil, ErrUnexpectedResponse
+	}
+	return types.PB2TM.PubKey(asura.PubKey{Type: pb.Type, Data: pb.Data})
+}
+
+func blockIDToProto(blockID types.BlockID) *protosigner.BlockID {
+	return &protosigner.BlockID{
+		Hash: blockID.Hash,
+		PartsHeader: &protosigner.PartSetHeader{
+			Total: int64(blockID.PartsHeader.Total),
+			Hash:  blockID.PartsHeader.Hash,
+		},
+	}
+}
+
+func blockIDFromProto(pb *protosigner.BlockID) types.BlockID {
+	return types.BlockID{
+		Hash: pb.GetHash(),
+		PartsHeader: types.PartSetHeader{
+			Total: int(pb.GetPartsHeader().GetTotal()),
+			Hash:  pb.GetPartsHeader().GetHash(),
+		},
+	}
+}
+
+func voteToProto(vote *types.Vote) (*protosigner.Vote, error) {
+	timestamp, err := ptypes.TimestampProto(vote.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+	return &protosigner.Vote{
+		Type:             uint32(vote.Type),
+		Height:           vote.Height,
+		Round:            int64(vote.Round),
+		BlockId:          blockIDToProto(vote.BlockID),
+		Timestamp:        timestamp,
+		ValidatorAddress: vote.ValidatorAddress,
+		ValidatorIndex:   int64(vote.ValidatorIndex),
+		Signature:        vote.Signature,
+	}, nil
+}
+
+func voteFromProto(pb *protosigner.Vote) (*types.Vote, error) {
+	if pb == nil {
+		return nil, ErrUnexpectedResponse
+	}
+	timestamp, err := ptypes.Timestamp(pb.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+	return &types.Vote{
+		Type:             types.SignedMsgType(pb.Type),
+		Height:           pb.Height,
+		Round:            int(pb.Round),
+		BlockID:          blockIDFromProto(pb.BlockId),
+		Timestamp:        timestamp,
+		ValidatorAddress: pb.ValidatorAddress,
+		ValidatorIndex:   int(pb.ValidatorIndex),
+		Signature:        pb.Signature,
+	}, nil
+}
+
+func proposalToProto(proposal *types.Proposal) (*protosigner.Proposal, error) {
+	timestamp, err := ptypes.TimestampProto(proposal.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+	return &protosigner.Proposal{
+		Type:      uint32(proposal.Type),
+		Height:    proposal.Height,
+		Round:     int64(proposal.Round),
+		PolRound:  int64(proposal.POLRound),
+		BlockId:   blockIDToProto(proposal.BlockID),
+		Timestamp: timestamp,
+		Signature: proposal.Signature,
+	}, nil
+}
+
+func proposalFromProto(pb *protosigner.Proposal) (*types.Proposal, error) {
+	if pb == nil {
+		return nil, ErrUnexpectedResponse
+	}
+	timestamp, err := ptypes.Timestamp(pb.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+	return &types.Proposal{
+		Type:      types.SignedMsgType(pb.Type),
+		Height:    pb.Height,
+		Round:     int(pb.Round),
+		POLRound:  int(pb.PolRound),
+		BlockID:   blockIDFromProto(pb.BlockId),
+		Timestamp: timestamp,
+		Signature: pb.Signature,
+	}, nil
+}
+
+func remoteSignerErrorToProto(err *RemoteSignerError) *protosigner.RemoteSignerError {
+	if err == nil {
+		return nil
+	}
+	return &protosigner.RemoteSignerError{Code: int32(err.Code), Description: err.Description}
+}
+
+func remoteSignerErrorFromProto(pb *protosigner.RemoteSignerError) *RemoteSignerError {
+	return &RemoteSignerError{Code: int(pb.Code), Description: pb.Description}
+}
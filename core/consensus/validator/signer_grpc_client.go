@@ -0,0 +1,212 @@
+package validator
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+
+	protosigner "github.com/teragrid/dgrid/core/consensus/validator/proto"
+	"github.com/teragrid/dgrid/core/types"
+	cmn "github.com/teragrid/dgrid/pkg/common"
+	"github.com/teragrid/dgrid/pkg/crypto"
+	"github.com/teragrid/dgrid/pkg/log"
+)
+
+// SignerGRPCClientOption sets an optional parameter on the SignerGRPCClient.
+type SignerGRPCClientOption func(*SignerGRPCClient)
+
+// SignerGRPCClientTimeoutDial sets the timeout for connecting to the signer.
+func SignerGRPCClientTimeoutDial(timeout time.Duration) SignerGRPCClientOption {
+	return func(sc *SignerGRPCClient) { sc.timeoutDial = timeout }
+}
+
+// SignerGRPCClientTimeoutReadWrite sets the timeout of the requests to the
+// signer.
+func SignerGRPCClientTimeoutReadWrite(timeout time.Duration) SignerGRPCClientOption {
+	return func(sc *SignerGRPCClient) { sc.timeoutReadWrite = timeout }
+}
+
+// SignerGRPCClientSetLeague sets the league of the validator, and its
+// address, which selects its key on a signer hosting several keys for the
+// league. See SignerKeys.
+func SignerGRPCClientSetLeague(leagueID string, address types.Address) SignerGRPCClientOption {
+	return func(sc *SignerGRPCClient) {
+		sc.leagueID = leagueID
+		sc.address = address
+	}
+}
+
+// SignerGRPCClient implements Validator.
+// It dials an external process serving the Signer gRPC service, like
+// SignerGRPCServer, and requests signatures from it. It is the gRPC
+// counterpart of SignerValidatorEndpoint: the connection is authenticated
+// by the node keys of both ends, see NewSecretConnCredentials, and it is
+// re-established by gRPC if it fails.
+type SignerGRPCClient struct {
+	cmn.BaseService
+
+	addr  string
+	creds credentials.TransportCredentials
+
+	// the validator whose key the signer signs with
+	leagueID string
+	address  types.Address
+
+	timeoutDial      time.Duration
+	timeoutReadWrite time.Duration
+
+	conn   *grpc.ClientConn
+	client protosigner.SignerClient
+
+	// memoized
+	consensusPubKey crypto.PubKey
+}
+
+// Check that SignerGRPCClient implements Validator.
+var _ types.Validator = (*SignerGRPCClient)(nil)
+
+// NewSignerGRPCClient returns an instance of SignerGRPCClient dialing the
+// signer at addr, a host and port, with creds.
+func NewSignerGRPCClient(logger log.Logger, addr string, creds credentials.TransportCredentials) *SignerGRPCClient {
+	sc := &SignerGRPCClient{
+		addr:             addr,
+		creds:            creds,
+		timeoutDial:      time.Second * defaultTimeoutAcceptSeconds,
+		timeoutReadWrite: time.Second * defaultTimeoutReadWriteSeconds,
+	}
+
+	sc.BaseService = *cmn.NewBaseService(logger, "SignerGRPCClient", sc)
+
+	return sc
+}
+
+// OnStart implements cmn.Service. It connects to the signer, and retrieves
+// the public key of the validator.
+func (sc *SignerGRPCClient) OnStart() error {
+	ctx, cancel := context.WithTimeout(context.Background(), sc.timeoutDial)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, sc.addr, grpc.WithTransportCredentials(sc.creds), grpc.WithBlock())
+	if err != nil {
+		sc.Logger.Error("OnStart", "err", err)
+		return grpcError(err)
+	}
+
+	sc.conn = conn
+	sc.client = protosigner.NewSignerClient(conn)
+	pubKey, err := sc.getPubKey()
+	if err != nil {
+		sc.Logger.Error("OnStart", "err", err)
+		if err := conn.Close(); err != nil {
+			sc.Logger.Error("error closing connection", "err", err)
+		}
+		return cmn.ErrorWrap(err, "error while retrieving public key for remote signer")
+	}
+	sc.consensusPubKey = pubKey
+
+	return nil
+}
+
+// OnStop implements cmn.Service.
+func (sc *SignerGRPCClient) OnStop() {
+	if err := sc.conn.Close(); err != nil {
+		sc.Logger.Error("OnStop", "err", err)
+	}
+}
+
+// GetPubKey implements Validator.
+func (sc *SignerGRPCClient) GetPubKey() crypto.PubKey {
+	return sc.consensusPubKey
+}
+
+func (sc *SignerGRPCClient) getPubKey() (crypto.PubKey, error) {
+	ctx, cancel := sc.requestContext()
+	defer cancel()
+	res, err := sc.client.PubKey(ctx, &protosigner.PubKeyRequest{LeagueId: sc.leagueID, Address: sc.address})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	if res.Error != nil {
+		return nil, errors.Wrap(remoteSignerErrorFromProto(res.Error), "failed to get private validator's public key")
+	}
+	return pubKeyFromProto(res.PubKey)
+}
+
+// SignVote implements Validator.
+func (sc *SignerGRPCClient) SignVote(chainID string, vote *types.Vote) error {
+	pbVote, err := voteToProto(vote)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := sc.requestContext()
+	defer cancel()
+	res, err := sc.client.SignVote(ctx, &protosigner.SignVoteRequest{Vote: pbVote, LeagueId: chainID})
+	if err != nil {
+		return grpcError(err)
+	}
+	if res.Error != nil {
+		return remoteSignerErrorFromProto(res.Error)
+	}
+	signed, err := voteFromProto(res.Vote)
+	if err != nil {
+		return err
+	}
+	*vote = *signed
+
+	return nil
+}
+
+// SignProposal implements Validator.
+func (sc *SignerGRPCClient) SignProposal(chainID string, proposal *types.Proposal) error {
+	pbProposal, err := proposalToProto(proposal)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := sc.requestContext()
+	defer cancel()
+	res, err := sc.client.SignProposal(ctx, &protosigner.SignProposalRequest{
+		Proposal:         pbProposal,
+		LeagueId:         chainID,
+		ValidatorAddress: sc.consensusPubKey.Address(),
+	})
+	if err != nil {
+		return grpcError(err)
+	}
+	if res.Error != nil {
+		return remoteSignerErrorFromProto(res.Error)
+	}
+	signed, err := proposalFromProto(res.Proposal)
+	if err != nil {
+		return err
+	}
+	*proposal = *signed
+
+	return nil
+}
+
+// Ping is used to check connection health.
+func (sc *SignerGRPCClient) Ping() error {
+	ctx, cancel := sc.requestContext()
+	defer cancel()
+	_, err := sc.client.Ping(ctx, &protosigner.PingRequest{})
+	return grpcError(err)
+}
+
+func (sc *SignerGRPCClient) requestContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), sc.timeoutReadWrite)
+}
+
+// grpcError returns ErrConnTimeout for the timeouts of gRPC, so they are
+// reported like the ones of the socket protocol. See IsConnTimeout.
+func grpcError(err error) error {
+	if err == context.DeadlineExceeded || status.Code(err) == codes.DeadlineExceeded {
+		return cmn.ErrorWrap(ErrConnTimeout, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,183 @@
+package validator
+
+import (
+	"net"
+	"sync"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	protosigner "github.com/teragrid/dgrid/core/consensus/validator/proto"
+	"github.com/teragrid/dgrid/core/types"
+	cmn "github.com/teragrid/dgrid/pkg/common"
+	"github.com/teragrid/dgrid/pkg/log"
+)
+
+// SignerGRPCServer serves the Signer gRPC service on its listener, and
+// responds to the signature requests of the validators dialing it, like
+// SignerGRPCClient, using its keys. It is the gRPC counterpart of
+// SignerServiceEndpoint: a request is signed by the key of the league and
+// validator it names, or of chainID if it names no league, and the
+// connections are authenticated by the node keys of both ends, see
+// NewSecretConnCredentials.
+type SignerGRPCServer struct {
+	cmn.BaseService
+
+	chainID string
+	keys    *SignerKeys
+
+	listener net.Listener
+	creds    credentials.TransportCredentials
+	server   *grpc.Server
+
+	// requests are handled one at a time, like over a socket connection
+	mtx sync.Mutex
+}
+
+// NewSignerGRPCServer returns a SignerGRPCServer that will serve the
+// signature requests on the listener using the given privVal.
+func NewSignerGRPCServer(
+	logger log.Logger,
+	chainID string,
+	privVal types.Validator,
+	listener net.Listener,
+	creds credentials.TransportCredentials,
+) *SignerGRPCServer {
+	keys := NewSignerKeys()
+	keys.leagues[chainID] = []types.Validator{privVal}
+	return newSignerGRPCServer(logger, chainID, keys, listener, creds)
+}
+
+// NewMultiSignerGRPCServer returns a SignerGRPCServer that will serve the
+// signature requests of the validators of several leagues on the listener,
+// using the given keys.
+func NewMultiSignerGRPCServer(
+	logger log.Logger,
+	keys *SignerKeys,
+	listener net.Listener,
+	creds credentials.TransportCredentials,
+) *SignerGRPCServer {
+	return newSignerGRPCServer(logger, "", keys, listener, creds)
+}
+
+func newSignerGRPCServer(
+	logger log.Logger,
+	chainID string,
+	keys *SignerKeys,
+	listener net.Listener,
+	creds credentials.TransportCredentials,
+) *SignerGRPCServer {
+	ss := &SignerGRPCServer{
+		chainID:  chainID,
+		keys:     keys,
+		listener: listener,
+		creds:    creds,
+	}
+
+	ss.BaseService = *cmn.NewBaseService(logger, "SignerGRPCServer", ss)
+	return ss
+}
+
+// OnStart implements cmn.Service.
+func (ss *SignerGRPCServer) OnStart() error {
+	if err := ss.keys.assignLeague(ss.chainID); err != nil {
+		ss.Logger.Error("OnStart", "err", err)
+		return err
+	}
+
+	ss.server = grpc.NewServer(grpc.Creds(ss.creds))
+	protosigner.RegisterSignerServer(ss.server, grpcSignerService{ss})
+	go func() {
+		if err := ss.server.Serve(ss.listener); err != nil {
+			ss.Logger.Error("Serve", "err", err)
+		}
+	}()
+
+	return nil
+}
+
+// OnStop implements cmn.Service.
+func (ss *SignerGRPCServer) OnStop() {
+	ss.server.Stop()
+}
+
+// handleRequest handles a request converted from the Signer service.
+func (ss *SignerGRPCServer) handleRequest(req RemoteSignerMsg) RemoteSignerMsg {
+	ss.mtx.Lock()
+	defer ss.mtx.Unlock()
+
+	res, err := handleRequest(req, ss.chainID, ss.keys)
+	if err != nil {
+		// only log the error; we'll reply with an error in res
+		ss.Logger.Error("handleRequest", "err", err)
+	}
+	return res
+}
+
+// grpcSignerService implements the Signer service of a SignerGRPCServer.
+type grpcSignerService struct {
+	ss *SignerGRPCServer
+}
+
+var _ protosigner.SignerServer = grpcSignerService{}
+
+func (s grpcSignerService) PubKey(
+	ctx context.Context,
+	req *protosigner.PubKeyRequest,
+) (*protosigner.PubKeyResponse, error) {
+	res := s.ss.handleRequest(&PubKeyRequest{LeagueID: req.LeagueId, Address: req.Address}).(*PubKeyResponse)
+	if res.Error != nil {
+		return &protosigner.PubKeyResponse{Error: remoteSignerErrorToProto(res.Error)}, nil
+	}
+	return &protosigner.PubKeyResponse{PubKey: pubKeyToProto(res.PubKey)}, nil
+}
+
+func (s grpcSignerService) SignVote(
+	ctx context.Context,
+	req *protosigner.SignVoteRequest,
+) (*protosigner.SignedVoteResponse, error) {
+	vote, err := voteFromProto(req.Vote)
+	if err != nil {
+		return &protosigner.SignedVoteResponse{Error: &protosigner.RemoteSignerError{Description: err.Error()}}, nil
+	}
+	res := s.ss.handleRequest(&SignVoteRequest{Vote: vote, LeagueID: req.LeagueId}).(*SignedVoteResponse)
+	if res.Error != nil {
+		return &protosigner.SignedVoteResponse{Error: remoteSignerErrorToProto(res.Error)}, nil
+	}
+	pbVote, err := voteToProto(res.Vote)
+	if err != nil {
+		return nil, err
+	}
+	return &protosigner.SignedVoteResponse{Vote: pbVote}, nil
+}
+
+func (s grpcSignerService) SignProposal(
+	ctx context.Context,
+	req *protosigner.SignProposalRequest,
+) (*protosigner.SignedProposalResponse, error) {
+	proposal, err := proposalFromProto(req.Proposal)
+	if err != nil {
+		return &protosigner.SignedProposalResponse{Error: &protosigner.RemoteSignerError{Description: err.Error()}}, nil
+	}
+	res := s.ss.handleRequest(&SignProposalRequest{
+		Proposal:         proposal,
+		LeagueID:         req.LeagueId,
+		ValidatorAddress: req.ValidatorAddress,
+	}).(*SignedProposalResponse)
+	if res.Error != nil {
+		return &protosigner.SignedProposalResponse{Error: remoteSignerErrorToProto(res.Error)}, nil
+	}
+	pbProposal, err := proposalToProto(res.Proposal)
+	if err != nil {
+		return nil, err
+	}
+	return &protosigner.SignedProposalResponse{Proposal: pbProposal}, nil
+}
+
+func (s grpcSignerService) Ping(
+	ctx context.Context,
+	req *protosigner.PingRequest,
+) (*protosigner.PingResponse, error) {
+	return &protosigner.PingResponse{}, nil
+}
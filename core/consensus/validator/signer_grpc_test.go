@@ -0,0 +1,108 @@
+package validator
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teragrid/dgrid/core/blockchain/p2p"
+	"github.com/teragrid/dgrid/core/types"
+	cmn "github.com/teragrid/dgrid/pkg/common"
+	"github.com/teragrid/dgrid/pkg/crypto/ed25519"
+	"github.com/teragrid/dgrid/pkg/log"
+)
+
+func TestSignerGRPCUnauthorized(t *testing.T) {
+	var (
+		logger       = log.TestingLogger()
+		chainID      = cmn.RandStr(12)
+		signerKey    = ed25519.GenPrivKey()
+		validatorKey = ed25519.GenPrivKey()
+		otherKey     = ed25519.GenPrivKey()
+	)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	serviceEndpoint := NewSignerGRPCServer(logger, chainID, types.NewMockPV(), ln,
+		NewSecretConnCredentials(signerKey, []p2p.ID{p2p.PubKeyToID(validatorKey.PubKey())}))
+	require.NoError(t, serviceEndpoint.Start())
+	defer serviceEndpoint.Stop()
+
+	for _, creds := range []struct {
+		privKey  ed25519.PrivKeyEd25519
+		signerID p2p.ID
+	}{
+		// the signer doesn't authorize the validator
+		{otherKey, p2p.PubKeyToID(signerKey.PubKey())},
+		// the validator doesn't authorize the signer
+		{validatorKey, p2p.PubKeyToID(otherKey.PubKey())},
+	} {
+		validatorEndpoint := NewSignerGRPCClient(logger, ln.Addr().String(),
+			NewSecretConnCredentials(creds.privKey, []p2p.ID{creds.signerID}))
+		SignerGRPCClientTimeoutDial(testTimeoutReadWrite)(validatorEndpoint)
+		assert.Error(t, validatorEndpoint.Start())
+		assert.False(t, validatorEndpoint.IsRunning())
+	}
+}
+
+func TestSignerGRPCMessages(t *testing.T) {
+	blockID := types.BlockID{
+		Hash:        []byte{1, 2, 3},
+		PartsHeader: types.PartSetHeader{Total: 2, Hash: []byte{3, 2, 1}},
+	}
+
+	vote := newVote(ed25519.GenPrivKey().PubKey().Address(), 3, 10, 1, byte(types.PrecommitType), blockID)
+	vote.Signature = []byte("signature")
+	pbVote, err := voteToProto(vote)
+	require.NoError(t, err)
+	decodedVote, err := voteFromProto(pbVote)
+	require.NoError(t, err)
+	assert.Equal(t, vote.SignBytes("mychainid"), decodedVote.SignBytes("mychainid"))
+	assert.Equal(t, vote.ValidatorIndex, decodedVote.ValidatorIndex)
+	assert.Equal(t, vote.Signature, decodedVote.Signature)
+
+	proposal := newProposal(10, 1, blockID)
+	proposal.POLRound = -1
+	pbProposal, err := proposalToProto(proposal)
+	require.NoError(t, err)
+	decodedProposal, err := proposalFromProto(pbProposal)
+	require.NoError(t, err)
+	assert.Equal(t, proposal.SignBytes("mychainid"), decodedProposal.SignBytes("mychainid"))
+
+	pubKey := ed25519.GenPrivKey().PubKey()
+	decodedPubKey, err := pubKeyFromProto(pubKeyToProto(pubKey))
+	require.NoError(t, err)
+	assert.Equal(t, pubKey, decodedPubKey)
+}
+
+// testSetupGRPCSigner sets up a SignerGRPCServer and a SignerGRPCClient
+// authenticating each other.
+func testSetupGRPCSigner(
+	t *testing.T,
+	chainID string,
+	keys *SignerKeys,
+	leagueID string,
+	address types.Address,
+) (remoteValidator, cmn.Service) {
+	var (
+		logger       = log.TestingLogger()
+		signerKey    = ed25519.GenPrivKey()
+		validatorKey = ed25519.GenPrivKey()
+	)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	serviceEndpoint := newSignerGRPCServer(logger, chainID, keys, ln,
+		NewSecretConnCredentials(signerKey, []p2p.ID{p2p.PubKeyToID(validatorKey.PubKey())}))
+	require.NoError(t, serviceEndpoint.Start())
+
+	validatorEndpoint := NewSignerGRPCClient(logger, ln.Addr().String(),
+		NewSecretConnCredentials(validatorKey, []p2p.ID{p2p.PubKeyToID(signerKey.PubKey())}))
+	SignerGRPCClientTimeoutReadWrite(time.Second)(validatorEndpoint)
+	SignerGRPCClientSetLeague(leagueID, address)(validatorEndpoint)
+	require.NoError(t, validatorEndpoint.Start())
+
+	return validatorEndpoint, serviceEndpoint
+}
@@ -0,0 +1,81 @@
+package validator
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/teragrid/dgrid/core/types"
+)
+
+// SignerKeys are the validator keys hosted by a signer process, by league.
+// A league can have several keys, eg. the ones of several validators of the
+// league, and a key can sign for several leagues, given it keeps its sign
+// state by league, like FilePV.
+type SignerKeys struct {
+	mtx     sync.RWMutex
+	leagues map[string][]types.Validator
+}
+
+// NewSignerKeys returns an empty set of keys.
+func NewSignerKeys() *SignerKeys {
+	return &SignerKeys{leagues: make(map[string][]types.Validator)}
+}
+
+// Add adds the key of a validator of a league. It returns an error if the
+// league already has a key with the same address.
+func (sk *SignerKeys) Add(leagueID string, privVal types.Validator) error {
+	sk.mtx.Lock()
+	defer sk.mtx.Unlock()
+	address := privVal.GetPubKey().Address()
+	for _, val := range sk.leagues[leagueID] {
+		if bytes.Equal(val.GetPubKey().Address(), address) {
+			return fmt.Errorf("league %v already has the key of %X", leagueID, address)
+		}
+	}
+	sk.leagues[leagueID] = append(sk.leagues[leagueID], privVal)
+	return nil
+}
+
+// Validator returns the key of a league with the given address. An empty
+// address selects the key of a league with a single key.
+func (sk *SignerKeys) Validator(leagueID string, address types.Address) (types.Validator, error) {
+	sk.mtx.RLock()
+	defer sk.mtx.RUnlock()
+	vals := sk.leagues[leagueID]
+	if len(vals) == 0 {
+		return nil, fmt.Errorf("no key for league %v", leagueID)
+	}
+	if len(address) == 0 {
+		if len(vals) > 1 {
+			return nil, fmt.Errorf("league %v has %d keys, the validator address is needed", leagueID, len(vals))
+		}
+		return vals[0], nil
+	}
+	for _, val := range vals {
+		if bytes.Equal(val.GetPubKey().Address(), address) {
+			return val, nil
+		}
+	}
+	return nil, fmt.Errorf("no key for validator %X of league %v", address, leagueID)
+}
+
+// assignLeague assigns the sign state saved by the keys of chainID before
+// the sign states were kept by league to chainID.
+func (sk *SignerKeys) assignLeague(chainID string) error {
+	for _, privVal := range sk.Validators(chainID) {
+		if pv, ok := privVal.(interface{ AssignLeague(leagueID string) error }); ok {
+			if err := pv.AssignLeague(chainID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Validators returns the keys of a league.
+func (sk *SignerKeys) Validators(leagueID string) []types.Validator {
+	sk.mtx.RLock()
+	defer sk.mtx.RUnlock()
+	return append([]types.Validator(nil), sk.leagues[leagueID]...)
+}
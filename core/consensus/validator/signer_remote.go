@@ -24,11 +24,13 @@ type SignerRemote struct {
 // Check that SignerRemote implements Validator.
 var _ types.Validator = (*SignerRemote)(nil)
 
-// NewSignerRemote returns an instance of SignerRemote.
-func NewSignerRemote(conn net.Conn) (*SignerRemote, error) {
+// NewSignerRemote returns an instance of SignerRemote, signing with the key
+// of a validator of a league. The address selects the key of a league with
+// several keys on the signer, and may be empty otherwise.
+func NewSignerRemote(conn net.Conn, leagueID string, address types.Address) (*SignerRemote, error) {
 
 	// retrieve and memoize the consensus public key once.
-	pubKey, err := getPubKey(conn)
+	pubKey, err := getPubKey(conn, leagueID, address)
 	if err != nil {
 		return nil, cmn.ErrorWrap(err, "error while retrieving public key for remote signer")
 	}
@@ -49,8 +51,8 @@ func (sc *SignerRemote) GetPubKey() crypto.PubKey {
 }
 
 // not thread-safe (only called on startup).
-func getPubKey(conn net.Conn) (crypto.PubKey, error) {
-	err := writeMsg(conn, &PubKeyRequest{})
+func getPubKey(conn net.Conn, leagueID string, address types.Address) (crypto.PubKey, error) {
+	err := writeMsg(conn, &PubKeyRequest{LeagueID: leagueID, Address: address})
 	if err != nil {
 		return nil, err
 	}
@@ -74,7 +76,7 @@ func getPubKey(conn net.Conn) (crypto.PubKey, error) {
 
 // SignVote implements Validator.
 func (sc *SignerRemote) SignVote(chainID string, vote *types.Vote) error {
-	err := writeMsg(sc.conn, &SignVoteRequest{Vote: vote})
+	err := writeMsg(sc.conn, &SignVoteRequest{Vote: vote, LeagueID: chainID})
 	if err != nil {
 		return err
 	}
@@ -98,7 +100,11 @@ func (sc *SignerRemote) SignVote(chainID string, vote *types.Vote) error {
 
 // SignProposal implements Validator.
 func (sc *SignerRemote) SignProposal(chainID string, proposal *types.Proposal) error {
-	err := writeMsg(sc.conn, &SignProposalRequest{Proposal: proposal})
+	err := writeMsg(sc.conn, &SignProposalRequest{
+		Proposal:         proposal,
+		LeagueID:         chainID,
+		ValidatorAddress: sc.consensusPubKey.Address(),
+	})
 	if err != nil {
 		return err
 	}
@@ -155,18 +161,29 @@ func writeMsg(w io.Writer, msg interface{}) (err error) {
 	return
 }
 
-func handleRequest(req RemoteSignerMsg, chainID string, privVal types.Validator) (RemoteSignerMsg, error) {
+// handleRequest handles a request with the key of the league and validator
+// it names. The league is chainID for a request which names none.
+func handleRequest(req RemoteSignerMsg, chainID string, keys *SignerKeys) (RemoteSignerMsg, error) {
 	var res RemoteSignerMsg
 	var err error
 
 	switch r := req.(type) {
 	case *PubKeyRequest:
-		var p crypto.PubKey
-		p = privVal.GetPubKey()
-		res = &PubKeyResponse{p, nil}
+		var privVal types.Validator
+		privVal, err = keys.Validator(requestLeagueID(r.LeagueID, chainID), r.Address)
+		if err != nil {
+			res = &PubKeyResponse{nil, &RemoteSignerError{0, err.Error()}}
+		} else {
+			res = &PubKeyResponse{privVal.GetPubKey(), nil}
+		}
 
 	case *SignVoteRequest:
-		err = privVal.SignVote(chainID, r.Vote)
+		leagueID := requestLeagueID(r.LeagueID, chainID)
+		var privVal types.Validator
+		privVal, err = keys.Validator(leagueID, r.Vote.ValidatorAddress)
+		if err == nil {
+			err = privVal.SignVote(leagueID, r.Vote)
+		}
 		if err != nil {
 			res = &SignedVoteResponse{nil, &RemoteSignerError{0, err.Error()}}
 		} else {
@@ -174,7 +191,12 @@ func handleRequest(req RemoteSignerMsg, chainID string, privVal types.Validator)
 		}
 
 	case *SignProposalRequest:
-		err = privVal.SignProposal(chainID, r.Proposal)
+		leagueID := requestLeagueID(r.LeagueID, chainID)
+		var privVal types.Validator
+		privVal, err = keys.Validator(leagueID, r.ValidatorAddress)
+		if err == nil {
+			err = privVal.SignProposal(leagueID, r.Proposal)
+		}
 		if err != nil {
 			res = &SignedProposalResponse{nil, &RemoteSignerError{0, err.Error()}}
 		} else {
@@ -190,3 +212,12 @@ func handleRequest(req RemoteSignerMsg, chainID string, privVal types.Validator)
 
 	return res, err
 }
+
+// requestLeagueID returns the league a request is signed for: the one of
+// the request, or chainID for a validator which doesn't send it.
+func requestLeagueID(leagueID, chainID string) string {
+	if leagueID == "" {
+		return chainID
+	}
+	return leagueID
+}
@@ -25,14 +25,17 @@ func SignerServiceEndpointConnRetries(retries int) SignerServiceEndpointOption {
 }
 
 // SignerServiceEndpoint dials using its dialer and responds to any
-// signature requests using its privVal.
+// signature requests using its keys. A request is signed by the key of the
+// league and validator it names, or of chainID if it names no league, so one
+// signer process serves the validators of several leagues over one
+// connection.
 type SignerServiceEndpoint struct {
 	cmn.BaseService
 
 	chainID          string
 	timeoutReadWrite time.Duration
 	connRetries      int
-	privVal          types.Validator
+	keys             *SignerKeys
 
 	dialer SocketDialer
 	conn   net.Conn
@@ -46,12 +49,34 @@ func NewSignerServiceEndpoint(
 	chainID string,
 	privVal types.Validator,
 	dialer SocketDialer,
+) *SignerServiceEndpoint {
+	keys := NewSignerKeys()
+	keys.leagues[chainID] = []types.Validator{privVal}
+	return newSignerServiceEndpoint(logger, chainID, keys, dialer)
+}
+
+// NewMultiSignerServiceEndpoint returns a SignerServiceEndpoint that will dial
+// using the given dialer and respond to the signature requests of the
+// validators of several leagues over the connection, using the given keys.
+func NewMultiSignerServiceEndpoint(
+	logger log.Logger,
+	keys *SignerKeys,
+	dialer SocketDialer,
+) *SignerServiceEndpoint {
+	return newSignerServiceEndpoint(logger, "", keys, dialer)
+}
+
+func newSignerServiceEndpoint(
+	logger log.Logger,
+	chainID string,
+	keys *SignerKeys,
+	dialer SocketDialer,
 ) *SignerServiceEndpoint {
 	se := &SignerServiceEndpoint{
 		chainID:          chainID,
 		timeoutReadWrite: time.Second * defaultTimeoutReadWriteSeconds,
 		connRetries:      defaultMaxDialRetries,
-		privVal:          privVal,
+		keys:             keys,
 		dialer:           dialer,
 	}
 
@@ -61,6 +86,11 @@ func NewSignerServiceEndpoint(
 
 // OnStart implements cmn.Service.
 func (se *SignerServiceEndpoint) OnStart() error {
+	if err := se.keys.assignLeague(se.chainID); err != nil {
+		se.Logger.Error("OnStart", "err", err)
+		return err
+	}
+
 	conn, err := se.connect()
 	if err != nil {
 		se.Logger.Error("OnStart", "err", err)
@@ -123,7 +153,7 @@ func (se *SignerServiceEndpoint) handleConnection(conn net.Conn) {
 			return
 		}
 
-		res, err := handleRequest(req, se.chainID, se.privVal)
+		res, err := handleRequest(req, se.chainID, se.keys)
 
 		if err != nil {
 			// only log the error; we'll reply with an error in res
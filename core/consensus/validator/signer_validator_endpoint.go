@@ -30,6 +30,16 @@ func SignerValidatorEndpointSetHeartbeat(period time.Duration) SignerValidatorEn
 	return func(sc *SignerValidatorEndpoint) { sc.heartbeatPeriod = period }
 }
 
+// SignerValidatorEndpointSetLeague sets the league of the validator, and its
+// address, which selects its key on a signer hosting several keys for the
+// league. See SignerKeys.
+func SignerValidatorEndpointSetLeague(leagueID string, address types.Address) SignerValidatorEndpointOption {
+	return func(sc *SignerValidatorEndpoint) {
+		sc.leagueID = leagueID
+		sc.address = address
+	}
+}
+
 // SocketVal implements Validator.
 // It listens for an external process to dial in and uses
 // the socket to request signatures.
@@ -38,6 +48,10 @@ type SignerValidatorEndpoint struct {
 
 	listener net.Listener
 
+	// the validator whose key the signer signs with
+	leagueID string
+	address  types.Address
+
 	// ping
 	cancelPingCh    chan struct{}
 	pingTicker      *time.Ticker
@@ -205,7 +219,7 @@ func (ve *SignerValidatorEndpoint) reset() (closed bool, err error) {
 		return true, nil
 	}
 
-	ve.signer, err = NewSignerRemote(conn)
+	ve.signer, err = NewSignerRemote(conn, ve.leagueID, ve.address)
 	if err != nil {
 		// failed to fetch the pubkey. close out the connection.
 		if tmpErr := conn.Close(); tmpErr != nil {
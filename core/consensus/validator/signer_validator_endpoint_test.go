@@ -2,6 +2,7 @@ package validator
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net"
 	"testing"
 	"time"
@@ -31,6 +32,46 @@ type socketTestCase struct {
 	dialer SocketDialer
 }
 
+// remoteValidator is the validator end of a remote signer transport.
+type remoteValidator interface {
+	types.Validator
+	cmn.Service
+}
+
+// signerTestCase sets up the validator and signer ends of a remote signer
+// transport, with the keys of the signer. The validator end signs with the
+// key of address of leagueID, if set.
+type signerTestCase struct {
+	setup func(
+		t *testing.T,
+		chainID string,
+		keys *SignerKeys,
+		leagueID string,
+		address types.Address,
+	) (remoteValidator, cmn.Service)
+}
+
+// signerTestCases are the remote signer transports, which all pass the
+// same conformance tests.
+func signerTestCases(t *testing.T) []signerTestCase {
+	var cases []signerTestCase
+	for _, tc := range socketTestCases(t) {
+		tc := tc
+		cases = append(cases, signerTestCase{
+			setup: func(
+				t *testing.T,
+				chainID string,
+				keys *SignerKeys,
+				leagueID string,
+				address types.Address,
+			) (remoteValidator, cmn.Service) {
+				return testSetupSocketSigner(t, chainID, keys, leagueID, address, tc.addr, tc.dialer)
+			},
+		})
+	}
+	return append(cases, signerTestCase{setup: testSetupGRPCSigner})
+}
+
 func socketTestCases(t *testing.T) []socketTestCase {
 	tcpAddr := fmt.Sprintf("tcp://%s", testFreeTCPAddr(t))
 	unixFilePath, err := testUnixAddr()
@@ -49,18 +90,19 @@ func socketTestCases(t *testing.T) []socketTestCase {
 }
 
 func TestSocketPVAddress(t *testing.T) {
-	for _, tc := range socketTestCases(t) {
+	for _, tc := range signerTestCases(t) {
 		// Execute the test within a closure to ensure the deferred statements
 		// are called between each for loop iteration, for isolated test cases.
 		func() {
 			var (
 				chainID                            = cmn.RandStr(12)
-				validatorEndpoint, serviceEndpoint = testSetupSocketPair(t, chainID, types.NewMockPV(), tc.addr, tc.dialer)
+				privVal                            = types.NewMockPV()
+				validatorEndpoint, serviceEndpoint = tc.setup(t, chainID, testSignerKeys(t, chainID, privVal), "", nil)
 			)
 			defer validatorEndpoint.Stop()
 			defer serviceEndpoint.Stop()
 
-			serviceAddr := serviceEndpoint.privVal.GetPubKey().Address()
+			serviceAddr := privVal.GetPubKey().Address()
 			validatorAddr := validatorEndpoint.GetPubKey().Address()
 
 			assert.Equal(t, serviceAddr, validatorAddr)
@@ -69,22 +111,18 @@ func TestSocketPVAddress(t *testing.T) {
 }
 
 func TestSocketPVPubKey(t *testing.T) {
-	for _, tc := range socketTestCases(t) {
+	for _, tc := range signerTestCases(t) {
 		func() {
 			var (
 				chainID                            = cmn.RandStr(12)
-				validatorEndpoint, serviceEndpoint = testSetupSocketPair(
-					t,
-					chainID,
-					types.NewMockPV(),
-					tc.addr,
-					tc.dialer)
+				privVal                            = types.NewMockPV()
+				validatorEndpoint, serviceEndpoint = tc.setup(t, chainID, testSignerKeys(t, chainID, privVal), "", nil)
 			)
 			defer validatorEndpoint.Stop()
 			defer serviceEndpoint.Stop()
 
 			clientKey := validatorEndpoint.GetPubKey()
-			privvalPubKey := serviceEndpoint.privVal.GetPubKey()
+			privvalPubKey := privVal.GetPubKey()
 
 			assert.Equal(t, privvalPubKey, clientKey)
 		}()
@@ -92,16 +130,12 @@ func TestSocketPVPubKey(t *testing.T) {
 }
 
 func TestSocketPVProposal(t *testing.T) {
-	for _, tc := range socketTestCases(t) {
+	for _, tc := range signerTestCases(t) {
 		func() {
 			var (
 				chainID                            = cmn.RandStr(12)
-				validatorEndpoint, serviceEndpoint = testSetupSocketPair(
-					t,
-					chainID,
-					types.NewMockPV(),
-					tc.addr,
-					tc.dialer)
+				privVal                            = types.NewMockPV()
+				validatorEndpoint, serviceEndpoint = tc.setup(t, chainID, testSignerKeys(t, chainID, privVal), "", nil)
 
 				ts             = time.Now()
 				privProposal   = &types.Proposal{Timestamp: ts}
@@ -110,7 +144,7 @@ func TestSocketPVProposal(t *testing.T) {
 			defer validatorEndpoint.Stop()
 			defer serviceEndpoint.Stop()
 
-			require.NoError(t, serviceEndpoint.privVal.SignProposal(chainID, privProposal))
+			require.NoError(t, privVal.SignProposal(chainID, privProposal))
 			require.NoError(t, validatorEndpoint.SignProposal(chainID, clientProposal))
 
 			assert.Equal(t, privProposal.Signature, clientProposal.Signature)
@@ -119,16 +153,12 @@ func TestSocketPVProposal(t *testing.T) {
 }
 
 func TestSocketPVVote(t *testing.T) {
-	for _, tc := range socketTestCases(t) {
+	for _, tc := range signerTestCases(t) {
 		func() {
 			var (
 				chainID                            = cmn.RandStr(12)
-				validatorEndpoint, serviceEndpoint = testSetupSocketPair(
-					t,
-					chainID,
-					types.NewMockPV(),
-					tc.addr,
-					tc.dialer)
+				privVal                            = types.NewMockPV()
+				validatorEndpoint, serviceEndpoint = tc.setup(t, chainID, testSignerKeys(t, chainID, privVal), "", nil)
 
 				ts    = time.Now()
 				vType = types.PrecommitType
@@ -138,13 +168,94 @@ func TestSocketPVVote(t *testing.T) {
 			defer validatorEndpoint.Stop()
 			defer serviceEndpoint.Stop()
 
-			require.NoError(t, serviceEndpoint.privVal.SignVote(chainID, want))
+			require.NoError(t, privVal.SignVote(chainID, want))
 			require.NoError(t, validatorEndpoint.SignVote(chainID, have))
 			assert.Equal(t, want.Signature, have.Signature)
 		}()
 	}
 }
 
+func TestSocketPVVoteLeagues(t *testing.T) {
+	for _, tc := range signerTestCases(t) {
+		func() {
+			tempKeyFile, err := ioutil.TempFile("", "validator_key_")
+			require.NoError(t, err)
+			tempStateFile, err := ioutil.TempFile("", "validator_state_")
+			require.NoError(t, err)
+
+			var (
+				filePV                             = GenFilePV(tempKeyFile.Name(), tempStateFile.Name())
+				keys                               = testSignerKeys(t, "base", filePV)
+				validatorEndpoint, serviceEndpoint = tc.setup(t, "base", keys, "", nil)
+
+				block1 = types.BlockID{Hash: []byte{1, 2, 3}}
+				block2 = types.BlockID{Hash: []byte{3, 2, 1}}
+			)
+			defer validatorEndpoint.Stop()
+			defer serviceEndpoint.Stop()
+			require.NoError(t, keys.Add("regular", filePV))
+
+			// the votes are signed for the league of the request
+			vote := newVote(filePV.GetAddress(), 0, 10, 0, byte(types.PrecommitType), block1)
+			require.NoError(t, validatorEndpoint.SignVote("regular", vote))
+			assert.True(t, filePV.GetPubKey().VerifyBytes(vote.SignBytes("regular"), vote.Signature))
+
+			// the leagues progress independently, without conflicts within one
+			require.NoError(t, validatorEndpoint.SignVote("base", newVote(filePV.GetAddress(), 0, 2, 0, byte(types.PrecommitType), block2)))
+			assert.Error(t, validatorEndpoint.SignVote("regular", newVote(filePV.GetAddress(), 0, 10, 0, byte(types.PrecommitType), block2)))
+			assert.Error(t, validatorEndpoint.SignProposal("base", newProposal(1, 0, block1)))
+			assert.NoError(t, validatorEndpoint.SignProposal("regular", newProposal(11, 0, block1)))
+		}()
+	}
+}
+
+func TestSocketPVMultipleKeys(t *testing.T) {
+	for _, tc := range signerTestCases(t) {
+		func() {
+			var (
+				base1, base2, regular = types.NewMockPV(), types.NewMockPV(), types.NewMockPV()
+				keys                  = NewSignerKeys()
+			)
+			require.NoError(t, keys.Add("base", base1))
+			require.NoError(t, keys.Add("base", base2))
+			require.NoError(t, keys.Add("regular", regular))
+			require.NoError(t, keys.Add("regular", base1))
+			assert.Error(t, keys.Add("regular", base1))
+
+			validatorEndpoint, serviceEndpoint := tc.setup(t, "", keys, "base", base2.GetPubKey().Address())
+			defer validatorEndpoint.Stop()
+			defer serviceEndpoint.Stop()
+
+			// the public key is the one of the validator of the league
+			assert.Equal(t, base2.GetPubKey(), validatorEndpoint.GetPubKey())
+
+			// the requests are routed by league and validator address
+			proposal := &types.Proposal{Height: 1, Timestamp: time.Now()}
+			require.NoError(t, validatorEndpoint.SignProposal("base", proposal))
+			assert.True(t, base2.GetPubKey().VerifyBytes(proposal.SignBytes("base"), proposal.Signature))
+
+			for _, c := range []struct {
+				leagueID string
+				privVal  types.Validator
+			}{
+				{"base", base1},
+				{"regular", regular},
+				{"regular", base1},
+			} {
+				vote := &types.Vote{ValidatorAddress: c.privVal.GetPubKey().Address(), Type: types.PrecommitType, Timestamp: time.Now()}
+				require.NoError(t, validatorEndpoint.SignVote(c.leagueID, vote))
+				assert.True(t, c.privVal.GetPubKey().VerifyBytes(vote.SignBytes(c.leagueID), vote.Signature))
+			}
+
+			// an unknown league or key is refused
+			vote := &types.Vote{ValidatorAddress: base2.GetPubKey().Address(), Type: types.PrecommitType, Timestamp: time.Now()}
+			assert.Error(t, validatorEndpoint.SignVote("regular", vote))
+			assert.Error(t, validatorEndpoint.SignVote("other", vote))
+			assert.Error(t, validatorEndpoint.SignVote("base", &types.Vote{Type: types.PrecommitType, Timestamp: time.Now()}))
+		}()
+	}
+}
+
 func TestSocketPVVoteResetDeadline(t *testing.T) {
 	for _, tc := range socketTestCases(t) {
 		func() {
@@ -167,14 +278,14 @@ func TestSocketPVVoteResetDeadline(t *testing.T) {
 
 			time.Sleep(testTimeoutReadWrite2o3)
 
-			require.NoError(t, serviceEndpoint.privVal.SignVote(chainID, want))
+			require.NoError(t, testEndpointValidator(t, serviceEndpoint, chainID).SignVote(chainID, want))
 			require.NoError(t, validatorEndpoint.SignVote(chainID, have))
 			assert.Equal(t, want.Signature, have.Signature)
 
 			// This would exceed the deadline if it was not extended by the previous message
 			time.Sleep(testTimeoutReadWrite2o3)
 
-			require.NoError(t, serviceEndpoint.privVal.SignVote(chainID, want))
+			require.NoError(t, testEndpointValidator(t, serviceEndpoint, chainID).SignVote(chainID, want))
 			require.NoError(t, validatorEndpoint.SignVote(chainID, have))
 			assert.Equal(t, want.Signature, have.Signature)
 		}()
@@ -203,7 +314,7 @@ func TestSocketPVVoteKeepalive(t *testing.T) {
 
 			time.Sleep(testTimeoutReadWrite * 2)
 
-			require.NoError(t, serviceEndpoint.privVal.SignVote(chainID, want))
+			require.NoError(t, testEndpointValidator(t, serviceEndpoint, chainID).SignVote(chainID, want))
 			require.NoError(t, validatorEndpoint.SignVote(chainID, have))
 			assert.Equal(t, want.Signature, have.Signature)
 		}()
@@ -243,16 +354,12 @@ func TestSocketPVDeadline(t *testing.T) {
 }
 
 func TestRemoteSignVoteErrors(t *testing.T) {
-	for _, tc := range socketTestCases(t) {
+	for _, tc := range signerTestCases(t) {
 		func() {
 			var (
 				chainID                            = cmn.RandStr(12)
-				validatorEndpoint, serviceEndpoint = testSetupSocketPair(
-					t,
-					chainID,
-					types.NewErroringMockPV(),
-					tc.addr,
-					tc.dialer)
+				privVal                            = types.NewErroringMockPV()
+				validatorEndpoint, serviceEndpoint = tc.setup(t, chainID, testSignerKeys(t, chainID, privVal), "", nil)
 
 				ts    = time.Now()
 				vType = types.PrecommitType
@@ -264,7 +371,7 @@ func TestRemoteSignVoteErrors(t *testing.T) {
 			err := validatorEndpoint.SignVote("", vote)
 			require.Equal(t, err.(*RemoteSignerError).Description, types.ErroringMockPVErr.Error())
 
-			err = serviceEndpoint.privVal.SignVote(chainID, vote)
+			err = privVal.SignVote(chainID, vote)
 			require.Error(t, err)
 			err = validatorEndpoint.SignVote(chainID, vote)
 			require.Error(t, err)
@@ -273,16 +380,12 @@ func TestRemoteSignVoteErrors(t *testing.T) {
 }
 
 func TestRemoteSignProposalErrors(t *testing.T) {
-	for _, tc := range socketTestCases(t) {
+	for _, tc := range signerTestCases(t) {
 		func() {
 			var (
 				chainID                            = cmn.RandStr(12)
-				validatorEndpoint, serviceEndpoint = testSetupSocketPair(
-					t,
-					chainID,
-					types.NewErroringMockPV(),
-					tc.addr,
-					tc.dialer)
+				privVal                            = types.NewErroringMockPV()
+				validatorEndpoint, serviceEndpoint = tc.setup(t, chainID, testSignerKeys(t, chainID, privVal), "", nil)
 
 				ts       = time.Now()
 				proposal = &types.Proposal{Timestamp: ts}
@@ -293,7 +396,7 @@ func TestRemoteSignProposalErrors(t *testing.T) {
 			err := validatorEndpoint.SignProposal("", proposal)
 			require.Equal(t, err.(*RemoteSignerError).Description, types.ErroringMockPVErr.Error())
 
-			err = serviceEndpoint.privVal.SignProposal(chainID, proposal)
+			err = privVal.SignProposal(chainID, proposal)
 			require.Error(t, err)
 
 			err = validatorEndpoint.SignProposal(chainID, proposal)
@@ -448,15 +551,26 @@ func testSetupSocketPair(
 	privValidator types.Validator,
 	addr string,
 	socketDialer SocketDialer,
+) (*SignerValidatorEndpoint, *SignerServiceEndpoint) {
+	return testSetupSocketSigner(t, chainID, testSignerKeys(t, chainID, privValidator), "", nil, addr, socketDialer)
+}
+
+func testSetupSocketSigner(
+	t *testing.T,
+	chainID string,
+	keys *SignerKeys,
+	leagueID string,
+	address types.Address,
+	addr string,
+	socketDialer SocketDialer,
 ) (*SignerValidatorEndpoint, *SignerServiceEndpoint) {
 	var (
 		logger          = log.TestingLogger()
-		privVal         = privValidator
 		readyc          = make(chan struct{})
-		serviceEndpoint = NewSignerServiceEndpoint(
+		serviceEndpoint = newSignerServiceEndpoint(
 			logger,
 			chainID,
-			privVal,
+			keys,
 			socketDialer,
 		)
 
@@ -465,6 +579,9 @@ func testSetupSocketPair(
 	)
 
 	SignerValidatorEndpointSetHeartbeat(testTimeoutHeartbeat)(validatorEndpoint)
+	if leagueID != "" {
+		SignerValidatorEndpointSetLeague(leagueID, address)(validatorEndpoint)
+	}
 	SignerServiceEndpointTimeoutReadWrite(testTimeoutReadWrite)(serviceEndpoint)
 	SignerServiceEndpointConnRetries(1e6)(serviceEndpoint)
 
@@ -478,6 +595,20 @@ func testSetupSocketPair(
 	return validatorEndpoint, serviceEndpoint
 }
 
+// testSignerKeys returns the keys of a signer with the key privVal for
+// chainID.
+func testSignerKeys(t *testing.T, chainID string, privVal types.Validator) *SignerKeys {
+	keys := NewSignerKeys()
+	require.NoError(t, keys.Add(chainID, privVal))
+	return keys
+}
+
+func testEndpointValidator(t *testing.T, se *SignerServiceEndpoint, chainID string) types.Validator {
+	privVal, err := se.keys.Validator(chainID, nil)
+	require.NoError(t, err)
+	return privVal
+}
+
 func testReadWriteResponse(t *testing.T, resp RemoteSignerMsg, rsConn net.Conn) {
 	_, err := readMsg(rsConn)
 	require.NoError(t, err)
@@ -0,0 +1,206 @@
+package validator
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/teragrid/dgrid/core/types"
+	"github.com/teragrid/dgrid/pkg/crypto"
+	"github.com/teragrid/dgrid/pkg/crypto/multisig"
+)
+
+// ThresholdPubKey returns the key of a threshold validator whose votes are
+// signed by threshold of the co-signers with the given keys. The keys are
+// sorted by address, so the key doesn't depend on their order.
+func ThresholdPubKey(threshold int, pubKeys []crypto.PubKey) (multisig.PubKeyMultisigThreshold, error) {
+	if threshold <= 0 {
+		return multisig.PubKeyMultisigThreshold{}, fmt.Errorf("Invalid threshold %d", threshold)
+	}
+	sorted := append([]crypto.PubKey(nil), pubKeys...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].Address(), sorted[j].Address()) < 0
+	})
+	for i := 1; i < len(sorted); i++ {
+		if bytes.Equal(sorted[i-1].Address(), sorted[i].Address()) {
+			return multisig.PubKeyMultisigThreshold{}, fmt.Errorf("Duplicate co-signer key %v", sorted[i].Address())
+		}
+	}
+	pubKey := multisig.PubKeyMultisigThreshold{K: uint(threshold), PubKeys: sorted}
+	if err := types.ValidateThresholdPubKey(pubKey); err != nil {
+		return multisig.PubKeyMultisigThreshold{}, err
+	}
+	return pubKey, nil
+}
+
+// ThresholdSigner implements Validator for a threshold validator: its key is
+// a k of n multisig.PubKeyMultisigThreshold, and it signs with k of its n
+// co-signers, usually SignerValidatorEndpoints of remote signers on other
+// machines. Each co-signer keeps its own sign state, so it refuses to double
+// sign on its own, and a vote can't be double signed unless k co-signers
+// are compromised.
+type ThresholdSigner struct {
+	pubKey multisig.PubKeyMultisigThreshold
+
+	mtx       sync.RWMutex
+	cosigners []types.Validator // in the order of the keys of pubKey, nil if missing
+}
+
+// Check that ThresholdSigner implements Validator.
+var _ types.Validator = (*ThresholdSigner)(nil)
+
+// NewThresholdSigner returns a ThresholdSigner signing with threshold of the
+// given co-signers. Its key is ThresholdPubKey of the keys of the
+// co-signers.
+func NewThresholdSigner(threshold int, cosigners []types.Validator) (*ThresholdSigner, error) {
+	pubKeys := make([]crypto.PubKey, len(cosigners))
+	for i, cosigner := range cosigners {
+		pubKeys[i] = cosigner.GetPubKey()
+	}
+	pubKey, err := ThresholdPubKey(threshold, pubKeys)
+	if err != nil {
+		return nil, err
+	}
+	return NewThresholdSignerWithKey(pubKey, cosigners)
+}
+
+// NewThresholdSignerWithKey returns a ThresholdSigner of the key pubKey,
+// signing with some of its co-signers. The missing ones can be added with
+// AddCosigner, when they connect.
+func NewThresholdSignerWithKey(pubKey multisig.PubKeyMultisigThreshold, cosigners []types.Validator) (*ThresholdSigner, error) {
+	ts := &ThresholdSigner{
+		pubKey:    pubKey,
+		cosigners: make([]types.Validator, len(pubKey.PubKeys)),
+	}
+	for _, cosigner := range cosigners {
+		if err := ts.AddCosigner(cosigner); err != nil {
+			return nil, err
+		}
+	}
+	return ts, nil
+}
+
+// AddCosigner adds a co-signer, whose key must be one of the keys of the
+// threshold validator.
+func (ts *ThresholdSigner) AddCosigner(cosigner types.Validator) error {
+	ts.mtx.Lock()
+	defer ts.mtx.Unlock()
+	for i, key := range ts.pubKey.PubKeys {
+		if cosigner.GetPubKey().Equals(key) {
+			ts.cosigners[i] = cosigner
+			return nil
+		}
+	}
+	return ErrUnknownCosigner
+}
+
+// GetPubKey implements Validator.
+func (ts *ThresholdSigner) GetPubKey() crypto.PubKey {
+	return ts.pubKey
+}
+
+// SignVote implements Validator. Each co-signer signs a copy of the vote,
+// with the address of its own key.
+func (ts *ThresholdSigner) SignVote(chainID string, vote *types.Vote) error {
+	sig, timestamp, err := ts.sign(func(cosigner types.Validator) cosignature {
+		v := vote.Copy()
+		v.ValidatorAddress = cosigner.GetPubKey().Address()
+		if err := cosigner.SignVote(chainID, v); err != nil {
+			return cosignature{err: err}
+		}
+		return cosignature{v.SignBytes(chainID), v.Signature, v.Timestamp, nil}
+	})
+	if err != nil {
+		return fmt.Errorf("Error signing vote: %v", err)
+	}
+	vote.Signature = sig
+	vote.Timestamp = timestamp
+	return nil
+}
+
+// SignProposal implements Validator.
+func (ts *ThresholdSigner) SignProposal(chainID string, proposal *types.Proposal) error {
+	sig, timestamp, err := ts.sign(func(cosigner types.Validator) cosignature {
+		p := *proposal
+		if err := cosigner.SignProposal(chainID, &p); err != nil {
+			return cosignature{err: err}
+		}
+		return cosignature{p.SignBytes(chainID), p.Signature, p.Timestamp, nil}
+	})
+	if err != nil {
+		return fmt.Errorf("Error signing proposal: %v", err)
+	}
+	proposal.Signature = sig
+	proposal.Timestamp = timestamp
+	return nil
+}
+
+// String returns a string representation of the ThresholdSigner.
+func (ts *ThresholdSigner) String() string {
+	return fmt.Sprintf("ThresholdSigner{%v %d/%d}", ts.pubKey.Address(), ts.pubKey.K, len(ts.cosigners))
+}
+
+// cosignature is the signature of a vote or a proposal by a co-signer, with
+// the sign bytes and the timestamp it signed.
+type cosignature struct {
+	signBytes []byte
+	signature []byte
+	timestamp time.Time
+	err       error
+}
+
+// sign asks all the co-signers to sign concurrently, and returns the
+// multisig.Multisignature of the first k valid signatures of the same sign
+// bytes, with their timestamp. A co-signer re-signing a vote it already
+// signed returns its former signature, whose timestamp may differ, so only
+// the signatures of the same sign bytes are combined. The co-signers which
+// didn't answer yet go on signing in the background, and the missing ones
+// are skipped.
+func (ts *ThresholdSigner) sign(cosign func(cosigner types.Validator) cosignature) ([]byte, time.Time, error) {
+	type result struct {
+		index int
+		cosignature
+	}
+	ts.mtx.RLock()
+	cosigners := append([]types.Validator(nil), ts.cosigners...)
+	ts.mtx.RUnlock()
+
+	results := make(chan result, len(cosigners))
+	for i, cosigner := range cosigners {
+		if cosigner == nil {
+			results <- result{i, cosignature{err: ErrCosignerMissing}}
+			continue
+		}
+		go func(i int, cosigner types.Validator) {
+			results <- result{i, cosign(cosigner)}
+		}(i, cosigner)
+	}
+
+	msigs := make(map[string]*multisig.Multisignature)
+	counts := make(map[string]int)
+	var errs []string
+	for range cosigners {
+		res := <-results
+		if res.err == nil && !ts.pubKey.PubKeys[res.index].VerifyBytes(res.signBytes, res.signature) {
+			res.err = ErrInvalidCosignature
+		}
+		if res.err != nil {
+			errs = append(errs, fmt.Sprintf("co-signer %d: %v", res.index, res.err))
+			continue
+		}
+		key := string(res.signBytes)
+		if msigs[key] == nil {
+			msigs[key] = multisig.NewMultisig(len(cosigners))
+		}
+		msigs[key].AddSignature(res.signature, res.index)
+		counts[key]++
+		if counts[key] == int(ts.pubKey.K) {
+			return msigs[key].Marshal(), res.timestamp, nil
+		}
+	}
+	return nil, time.Time{}, fmt.Errorf("less than %d of %d co-signers signed: %v",
+		ts.pubKey.K, len(cosigners), strings.Join(errs, "; "))
+}
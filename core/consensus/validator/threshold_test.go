@@ -0,0 +1,110 @@
+package validator
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teragrid/dgrid/core/types"
+	"github.com/teragrid/dgrid/pkg/crypto"
+	"github.com/teragrid/dgrid/pkg/crypto/ed25519"
+)
+
+func TestThresholdPubKey(t *testing.T) {
+	pubKeys := []crypto.PubKey{
+		ed25519.GenPrivKey().PubKey(),
+		ed25519.GenPrivKey().PubKey(),
+		ed25519.GenPrivKey().PubKey(),
+	}
+	pubKey, err := ThresholdPubKey(2, pubKeys)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, pubKey.K)
+
+	// the order of the keys doesn't matter
+	reversed, err := ThresholdPubKey(2, []crypto.PubKey{pubKeys[2], pubKeys[1], pubKeys[0]})
+	require.NoError(t, err)
+	assert.Equal(t, pubKey.Address(), reversed.Address())
+
+	_, err = ThresholdPubKey(0, pubKeys)
+	assert.Error(t, err)
+	_, err = ThresholdPubKey(4, pubKeys)
+	assert.Error(t, err)
+	_, err = ThresholdPubKey(2, append(pubKeys, pubKeys[0]))
+	assert.Error(t, err)
+}
+
+func TestThresholdSignerSignVote(t *testing.T) {
+	cosigners := []types.Validator{types.NewMockPV(), types.NewErroringMockPV(), types.NewMockPV()}
+	ts, err := NewThresholdSigner(2, cosigners)
+	require.NoError(t, err)
+
+	block := types.BlockID{Hash: []byte{1, 2, 3}}
+	vote := newVote(ts.GetPubKey().Address(), 0, 10, 1, byte(types.PrecommitType), block)
+	require.NoError(t, ts.SignVote("mychainid", vote))
+	assert.Equal(t, ts.GetPubKey().Address(), vote.ValidatorAddress)
+	assert.NoError(t, vote.Verify("mychainid", ts.GetPubKey()))
+	assert.True(t, len(vote.Signature) <= types.MaxThresholdSignatureSize)
+
+	// a single co-signer doesn't make a valid vote
+	single, err := NewThresholdSigner(2, []types.Validator{cosigners[0], cosigners[1]})
+	require.NoError(t, err)
+	assert.Error(t, single.SignVote("mychainid", vote.Copy()))
+}
+
+func TestThresholdSignerSignProposal(t *testing.T) {
+	ts, err := NewThresholdSigner(3, []types.Validator{types.NewMockPV(), types.NewMockPV(), types.NewMockPV()})
+	require.NoError(t, err)
+
+	proposal := newProposal(10, 1, types.BlockID{Hash: []byte{1, 2, 3}})
+	require.NoError(t, ts.SignProposal("mychainid", proposal))
+	assert.True(t, ts.GetPubKey().VerifyBytes(proposal.SignBytes("mychainid"), proposal.Signature))
+}
+
+func TestThresholdSignerDoubleSign(t *testing.T) {
+	cosigners := make([]types.Validator, 3)
+	for i := range cosigners {
+		tempKeyFile, err := ioutil.TempFile("", "validator_key_")
+		require.Nil(t, err)
+		tempStateFile, err := ioutil.TempFile("", "validator_state_")
+		require.Nil(t, err)
+		cosigners[i] = GenFilePV(tempKeyFile.Name(), tempStateFile.Name())
+	}
+	ts, err := NewThresholdSigner(2, cosigners)
+	require.NoError(t, err)
+
+	block1 := types.BlockID{Hash: []byte{1, 2, 3}}
+	block2 := types.BlockID{Hash: []byte{3, 2, 1}}
+	vote := newVote(ts.GetPubKey().Address(), 0, 10, 1, byte(types.PrevoteType), block1)
+	require.NoError(t, ts.SignVote("mychainid", vote))
+
+	// each co-signer refuses to sign a conflicting vote
+	conflicting := newVote(ts.GetPubKey().Address(), 0, 10, 1, byte(types.PrevoteType), block2)
+	assert.Error(t, ts.SignVote("mychainid", conflicting))
+
+	// signing the same vote again is fine
+	require.NoError(t, ts.SignVote("mychainid", vote))
+	assert.NoError(t, vote.Verify("mychainid", ts.GetPubKey()))
+}
+
+func TestThresholdSignerWithKey(t *testing.T) {
+	cosigners := []types.Validator{types.NewMockPV(), types.NewMockPV(), types.NewMockPV()}
+	pubKey, err := ThresholdPubKey(2, []crypto.PubKey{
+		cosigners[0].GetPubKey(), cosigners[1].GetPubKey(), cosigners[2].GetPubKey()})
+	require.NoError(t, err)
+
+	// a single co-signer connected doesn't make a valid vote
+	ts, err := NewThresholdSignerWithKey(pubKey, cosigners[:1])
+	require.NoError(t, err)
+	assert.Equal(t, pubKey, ts.GetPubKey())
+	block := types.BlockID{Hash: []byte{1, 2, 3}}
+	vote := newVote(pubKey.Address(), 0, 10, 1, byte(types.PrecommitType), block)
+	assert.Error(t, ts.SignVote("mychainid", vote))
+
+	// it signs once the threshold is connected
+	require.NoError(t, ts.AddCosigner(cosigners[2]))
+	require.NoError(t, ts.SignVote("mychainid", vote))
+	assert.NoError(t, vote.Verify("mychainid", pubKey))
+
+	assert.Equal(t, ErrUnknownCosigner, ts.AddCosigner(types.NewMockPV()))
+}
@@ -0,0 +1,488 @@
+package governance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/teragrid/dgrid/core/baseleague"
+	cfg "github.com/teragrid/dgrid/core/config"
+	"github.com/teragrid/dgrid/core/types"
+	cmn "github.com/teragrid/dgrid/pkg/common"
+	sm "github.com/teragrid/dgrid/state"
+)
+
+const subscriber = "GovernanceManager"
+
+// DefaultActivationDelay is the number of heights of the league between the
+// submission of a proposal by UpdateLeagueConfig and its activation. It
+// leaves time for the validators of the Base league to approve it before
+// the league reaches the activation height.
+const DefaultActivationDelay = 100
+
+var (
+	// ErrUntrackedLeague is returned for a league the manager doesn't track
+	ErrUntrackedLeague = errors.New("League is not tracked by the governance manager")
+	// ErrNotConfigVoteSigner is returned when the validator can't sign config votes
+	ErrNotConfigVoteSigner = errors.New("Validator can't sign config votes")
+)
+
+// Reconfigurer switches the engine of a league to a new consensus config
+// from a height, at once if the height may have started already, and holds
+// a league before a height until it is released. It is implemented by the
+// consensus manager.
+type Reconfigurer interface {
+	ReconfigureLeagueAt(leagueID string, height int64, config cfg.Config, updateState func(*sm.State)) error
+	HoldLeagueAt(leagueID string, height int64) error
+	ReleaseLeagueAt(leagueID string, height int64) error
+}
+
+// ValidatorsLoader returns the validators of the Base league at a height.
+type ValidatorsLoader func(height int64) (*types.ValidatorSet, error)
+
+// governedLeague is a league whose configuration is governed.
+type governedLeague struct {
+	config   cfg.Config // consensus config before any update
+	height   int64      // last height committed
+	eventBus types.EventBusSubscriber
+}
+
+// Manager implements cfg.Manager by governing the configuration of the
+// leagues through the Base league. A proposal to update the configuration
+// of a league is a transaction of the Base league; once validators holding
+// more than 2/3 of the voting power of the Base league approved it, every
+// cell schedules the update with its Reconfigurer, and the engine of the
+// league switches to the new config at the proposed height. This is how a
+// league migrates from BFT to FBA, or back, without a new genesis.
+//
+// Whether an update is approved only depends on the blocks of the Base
+// league, as the votes must be included by the deadline of the proposal.
+// Until then, the cells hold the league before the activation height, so
+// every cell runs the height with the same config whatever its progress.
+// Proposals must reach the Base league before the league reaches their
+// activation height, see DefaultActivationDelay; a cell which committed the
+// height already can't hold it and reports it.
+type Manager struct {
+	cmn.BaseService
+	// submits the governance transactions
+	baseleague.TxSubmitter
+
+	baseLeagueID   string
+	follower       *baseleague.Follower
+	store          *ProposalStore
+	loadValidators ValidatorsLoader
+	reconfigurer   Reconfigurer
+
+	mtx       sync.Mutex
+	leagues   map[string]*governedLeague
+	validator types.Validator
+}
+
+// NewManager returns a new Manager processing the governance transactions
+// of the blocks published on the event bus of the Base league, and of the
+// blocks of baseBlockStore it didn't process yet.
+func NewManager(baseLeagueID string, baseEventBus types.EventBusSubscriber, baseBlockStore baseleague.BlockStore,
+	store *ProposalStore, loadValidators ValidatorsLoader, reconfigurer Reconfigurer) *Manager {
+	m := &Manager{
+		baseLeagueID:   baseLeagueID,
+		store:          store,
+		loadValidators: loadValidators,
+		reconfigurer:   reconfigurer,
+		leagues:        make(map[string]*governedLeague),
+	}
+	m.follower = baseleague.NewFollower(subscriber, baseEventBus, baseBlockStore,
+		store.LastHeight, m.processBaseBlock)
+	m.BaseService = *cmn.NewBaseService(nil, "GovernanceManager", m)
+	return m
+}
+
+var _ cfg.Manager = (*Manager)(nil)
+
+// SetValidator sets the validator of the Base league approving proposals.
+func (m *Manager) SetValidator(val types.Validator) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.validator = val
+}
+
+// OnStart implements cmn.Service by processing the blocks of the Base league.
+// The blocks committed since the last one processed, eg. while the cell was
+// down, are replayed from the block store first.
+func (m *Manager) OnStart() error {
+	m.follower.SetLogger(m.Logger)
+	return m.follower.Start()
+}
+
+// OnStop implements cmn.Service.
+func (m *Manager) OnStop() {
+	m.follower.Stop()
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for leagueID := range m.leagues {
+		m.untrackLeague(leagueID)
+	}
+}
+
+// TrackLeague starts governing the configuration of a league, whose last
+// committed height is height. config is the consensus config of the league
+// before any update. The approved updates are scheduled with the
+// Reconfigurer, which must run the league already. TrackLeague returns the
+// config in effect at the next height.
+func (m *Manager) TrackLeague(leagueID string, config cfg.Config, height int64,
+	eventBus types.EventBusSubscriber) (cfg.Config, error) {
+	gl := &governedLeague{
+		config:   config,
+		height:   height,
+		eventBus: eventBus,
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if _, ok := m.leagues[leagueID]; ok {
+		return nil, fmt.Errorf("League %s is already tracked", leagueID)
+	}
+	// the league waits for the pending proposals
+	for _, p := range m.store.PendingProposals(leagueID) {
+		m.hold(leagueID, p.Height)
+	}
+	// the updates activated up to the next height are in effect at once
+	if len(m.store.ApprovedProposals(leagueID, height+1)) > 0 {
+		if err := m.schedule(leagueID, gl, height+1); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.scheduleFrom(leagueID, gl, height+2); err != nil {
+		return nil, err
+	}
+
+	sub, err := eventBus.Subscribe(context.Background(), baseleague.LeagueSubscriber(subscriber, leagueID),
+		types.EventQueryNewBlock)
+	if err != nil {
+		return nil, err
+	}
+	m.leagues[leagueID] = gl
+	go baseleague.BlockRoutine(sub, m.Quit(), m.Logger.With("league", leagueID), func(block *types.Block) {
+		m.applyLeagueBlock(leagueID, block)
+	})
+	return m.configAt(leagueID, gl, height+1), nil
+}
+
+// UntrackLeague stops governing the configuration of a league, which isn't
+// held for the pending proposals any longer.
+func (m *Manager) UntrackLeague(leagueID string) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if _, ok := m.leagues[leagueID]; !ok {
+		return
+	}
+	for _, p := range m.store.PendingProposals(leagueID) {
+		m.release(leagueID, p.Height)
+	}
+	m.untrackLeague(leagueID)
+}
+
+func (m *Manager) untrackLeague(leagueID string) {
+	gl, ok := m.leagues[leagueID]
+	if !ok {
+		return
+	}
+	gl.eventBus.UnsubscribeAll(context.Background(), baseleague.LeagueSubscriber(subscriber, leagueID))
+	delete(m.leagues, leagueID)
+}
+
+// processBaseBlock processes the governance transactions of a block of the
+// Base league, and schedules the updates it approved.
+func (m *Manager) processBaseBlock(block *types.Block) {
+	vals, err := m.loadValidators(block.Height)
+	if err != nil {
+		m.Logger.Error("Error loading the validators of the Base league", "height", block.Height, "err", err)
+		return
+	}
+	res := m.store.ApplyBlock(m.baseLeagueID, block, vals)
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for _, p := range res.Submitted {
+		m.Logger.Info("Submitted league config update", "proposal", p, "baseHeight", block.Height)
+		if _, ok := m.leagues[p.LeagueID]; ok {
+			m.hold(p.LeagueID, p.Height)
+		}
+	}
+	for _, p := range res.Approved {
+		m.Logger.Info("Approved league config update", "proposal", p, "baseHeight", block.Height)
+		gl, ok := m.leagues[p.LeagueID]
+		if !ok {
+			continue
+		}
+		// the configs scheduled for the heights after the update change too
+		if err := m.scheduleFrom(p.LeagueID, gl, p.Height); err != nil {
+			m.Logger.Error("Error scheduling league config update", "proposal", p, "err", err)
+		}
+		m.releaseDecided(p)
+	}
+	for _, p := range res.Expired {
+		m.Logger.Info("League config update expired", "proposal", p, "baseHeight", block.Height)
+		if _, ok := m.leagues[p.LeagueID]; ok {
+			m.releaseDecided(p)
+		}
+	}
+}
+
+// hold holds a league before the activation height of a pending proposal.
+// m.mtx must be held.
+func (m *Manager) hold(leagueID string, height int64) {
+	if err := m.reconfigurer.HoldLeagueAt(leagueID, height); err != nil {
+		m.Logger.Error("Error holding league before the activation height of a proposal",
+			"league", leagueID, "height", height, "err", err)
+	}
+}
+
+// release releases a league held at a height. m.mtx must be held.
+func (m *Manager) release(leagueID string, height int64) {
+	if err := m.reconfigurer.ReleaseLeagueAt(leagueID, height); err != nil {
+		m.Logger.Error("Error releasing league at the activation height of a proposal",
+			"league", leagueID, "height", height, "err", err)
+	}
+}
+
+// releaseDecided releases the league of a decided proposal, unless another
+// pending proposal activates at the same height. m.mtx must be held.
+func (m *Manager) releaseDecided(p *ConfigProposal) {
+	for _, other := range m.store.PendingProposals(p.LeagueID) {
+		if other.Height == p.Height {
+			return
+		}
+	}
+	m.release(p.LeagueID, p.Height)
+}
+
+// applyLeagueBlock records the last height committed by a league.
+func (m *Manager) applyLeagueBlock(leagueID string, block *types.Block) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	gl, ok := m.leagues[leagueID]
+	if !ok || block.Height <= gl.height {
+		return
+	}
+	gl.height = block.Height
+}
+
+// configAt returns the consensus config of a league at a height, with the
+// updates approved up to that height applied in activation order.
+func (m *Manager) configAt(leagueID string, gl *governedLeague, height int64) cfg.Config {
+	config := gl.config
+	for _, p := range m.store.ApprovedProposals(leagueID, height) {
+		newConfig, err := p.Update.Apply(config)
+		if err != nil {
+			m.Logger.Error("Error applying league config update", "proposal", p, "err", err)
+			continue
+		}
+		config = newConfig
+	}
+	return config
+}
+
+// schedule asks the reconfigurer to run a league with its config at the
+// given height from that height on, and to apply the consensus params of
+// the updates activated at that height.
+func (m *Manager) schedule(leagueID string, gl *governedLeague, height int64) error {
+	var updates []*ConfigUpdate
+	for _, p := range m.store.ApprovedProposals(leagueID, height) {
+		if p.Height == height {
+			updates = append(updates, &p.Update)
+		}
+	}
+
+	updateState := func(state *sm.State) {
+		for _, u := range updates {
+			state.ConsensusParams = u.ApplyParams(state.ConsensusParams)
+		}
+	}
+	config := m.configAt(leagueID, gl, height)
+	if err := m.reconfigurer.ReconfigureLeagueAt(leagueID, height, config, updateState); err != nil {
+		return err
+	}
+	m.Logger.Info("Scheduled league config updates", "league", leagueID,
+		"height", height, "updates", len(updates))
+	return nil
+}
+
+// scheduleFrom schedules the updates of a league activated from the given
+// height on.
+func (m *Manager) scheduleFrom(leagueID string, gl *governedLeague, from int64) error {
+	var heights []int64
+	seen := make(map[int64]bool)
+	addHeight := func(height int64) {
+		if height >= from && !seen[height] {
+			seen[height] = true
+			heights = append(heights, height)
+		}
+	}
+	for _, p := range m.store.ApprovedProposals(leagueID, math.MaxInt64) {
+		addHeight(p.Height)
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+
+	for _, height := range heights {
+		if err := m.schedule(leagueID, gl, height); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetLeagueConfig implements cfg.Manager. It returns the consensus config
+// in effect in a league, or nil if the league is not tracked.
+func (m *Manager) GetLeagueConfig(leagueID string) cfg.Config {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	gl, ok := m.leagues[leagueID]
+	if !ok {
+		return nil
+	}
+	return m.configAt(leagueID, gl, gl.height+1)
+}
+
+// UpdateLeagueConfig implements cfg.Manager. It proposes to update the
+// timeouts and protocol of a league to those of config, DefaultActivationDelay
+// heights from now, and returns config. The update is effective once approved
+// by the validators of the Base league and the activation height is reached.
+func (m *Manager) UpdateLeagueConfig(leagueID string, config *cfg.Config) (*cfg.Config, error) {
+	m.mtx.Lock()
+	gl, ok := m.leagues[leagueID]
+	if !ok {
+		m.mtx.Unlock()
+		return nil, ErrUntrackedLeague
+	}
+	height := gl.height + DefaultActivationDelay
+	m.mtx.Unlock()
+
+	if _, err := m.ProposeConfig(leagueID, height, *config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// ProposeConfig submits a proposal to switch a league to the timeouts and
+// protocol of config from the given height, eg. to migrate it from BFT to
+// FBA, and returns the ID of the proposal.
+func (m *Manager) ProposeConfig(leagueID string, height int64, config cfg.Config) ([]byte, error) {
+	m.mtx.Lock()
+	gl, ok := m.leagues[leagueID]
+	if !ok {
+		m.mtx.Unlock()
+		return nil, ErrUntrackedLeague
+	}
+	update, err := diffConfigs(m.configAt(leagueID, gl, height), config)
+	m.mtx.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return m.ProposeConfigUpdate(leagueID, height, update)
+}
+
+// ProposeConfigUpdate submits a proposal to update the configuration of a
+// league from the given height, and returns the ID of the proposal. The
+// proposal must be approved within ApprovalPeriod heights of the Base league.
+func (m *Manager) ProposeConfigUpdate(leagueID string, height int64, update ConfigUpdate) ([]byte, error) {
+	m.mtx.Lock()
+	if gl, ok := m.leagues[leagueID]; ok && height <= gl.height+1 {
+		m.mtx.Unlock()
+		return nil, fmt.Errorf("Activation height %d is not after the next height %d of league %s",
+			height, gl.height+1, leagueID)
+	}
+	m.mtx.Unlock()
+
+	proposal := ConfigProposal{
+		LeagueID: leagueID,
+		Height:   height,
+		// the proposal is included after the last Base height processed
+		Deadline: m.store.LastHeight() + ApprovalPeriod,
+		Update:   update,
+		Nonce:    cmn.RandUint64(),
+	}
+	if err := proposal.ValidateBasic(); err != nil {
+		return nil, err
+	}
+	if err := m.submit(&ConfigProposalTx{Proposal: proposal}); err != nil {
+		return nil, err
+	}
+	m.Logger.Info("Proposed league config update", "proposal", &proposal)
+	return proposal.ID(), nil
+}
+
+// ApproveProposal submits the approval of a proposal by the validator.
+func (m *Manager) ApproveProposal(proposalID []byte) error {
+	m.mtx.Lock()
+	signer, ok := m.validator.(types.ConfigVoteSigner)
+	var address types.Address
+	if ok {
+		address = m.validator.GetPubKey().Address()
+	}
+	m.mtx.Unlock()
+	if !ok {
+		return ErrNotConfigVoteSigner
+	}
+
+	vote := &types.ConfigVote{
+		ProposalID:       proposalID,
+		ValidatorAddress: address,
+	}
+	if err := signer.SignConfigVote(m.baseLeagueID, vote); err != nil {
+		return err
+	}
+	return m.submit(&ConfigVoteTx{Vote: vote})
+}
+
+func (m *Manager) submit(gtx GovernanceTx) error {
+	tx, err := EncodeTx(gtx)
+	if err != nil {
+		return err
+	}
+	return m.SubmitTx(tx)
+}
+
+// diffConfigs returns the update turning the consensus config from into to.
+func diffConfigs(from, to cfg.Config) (ConfigUpdate, error) {
+	var update ConfigUpdate
+	fromProtocol, err := protocolOf(from)
+	if err != nil {
+		return update, err
+	}
+	toProtocol, err := protocolOf(to)
+	if err != nil {
+		return update, err
+	}
+	if fromProtocol != toProtocol {
+		update.Protocol = &toProtocol
+	}
+
+	fromTimeouts, toTimeouts := timeoutsOf(from), timeoutsOf(to)
+	for i, dst := range []**time.Duration{
+		&update.TimeoutPropose, &update.TimeoutPrevote, &update.TimeoutPrecommit, &update.TimeoutCommit,
+	} {
+		if fromTimeouts[i] != toTimeouts[i] {
+			timeout := toTimeouts[i]
+			*dst = &timeout
+		}
+	}
+	if update.IsEmpty() {
+		return update, errors.New("The league config is unchanged")
+	}
+	return update, nil
+}
+
+// timeoutsOf returns the propose, prevote, precommit and commit timeouts.
+func timeoutsOf(config cfg.Config) [4]time.Duration {
+	switch c := config.(type) {
+	case *cfg.BFTConsensusConfig:
+		return [4]time.Duration{c.TimeoutPropose, c.TimeoutPrevote, c.TimeoutPrecommit, c.TimeoutCommit}
+	case *cfg.FBAConsensusConfig:
+		return [4]time.Duration{c.TimeoutPropose, c.TimeoutPrevote, c.TimeoutPrecommit, c.TimeoutCommit}
+	}
+	return [4]time.Duration{}
+}
@@ -0,0 +1,252 @@
+package governance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cfg "github.com/teragrid/dgrid/core/config"
+	"github.com/teragrid/dgrid/core/types"
+	dbm "github.com/teragrid/dgrid/pkg/db"
+	sm "github.com/teragrid/dgrid/state"
+)
+
+type reconfiguration struct {
+	leagueID string
+	height   int64
+	config   cfg.Config
+	state    sm.State
+}
+
+// holding is a league held, or released, at a height.
+type holding struct {
+	leagueID string
+	height   int64
+	held     bool
+}
+
+type testReconfigurer struct {
+	reconfigured chan reconfiguration
+	holdings     chan holding
+}
+
+func newTestReconfigurer() *testReconfigurer {
+	return &testReconfigurer{
+		reconfigured: make(chan reconfiguration, 1),
+		holdings:     make(chan holding, 4),
+	}
+}
+
+func (r *testReconfigurer) ReconfigureLeagueAt(leagueID string, height int64, config cfg.Config,
+	updateState func(*sm.State)) error {
+	state := sm.State{ConsensusParams: *types.DefaultConsensusParams()}
+	updateState(&state)
+	r.reconfigured <- reconfiguration{leagueID, height, config, state}
+	return nil
+}
+
+func (r *testReconfigurer) HoldLeagueAt(leagueID string, height int64) error {
+	r.holdings <- holding{leagueID, height, true}
+	return nil
+}
+
+func (r *testReconfigurer) ReleaseLeagueAt(leagueID string, height int64) error {
+	r.holdings <- holding{leagueID, height, false}
+	return nil
+}
+
+func receiveReconfiguration(t *testing.T, reconfigurer *testReconfigurer) reconfiguration {
+	select {
+	case r := <-reconfigurer.reconfigured:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("League was not reconfigured")
+	}
+	return reconfiguration{}
+}
+
+func receiveHolding(t *testing.T, reconfigurer *testReconfigurer) holding {
+	select {
+	case h := <-reconfigurer.holdings:
+		return h
+	case <-time.After(5 * time.Second):
+		t.Fatal("League was not held nor released")
+	}
+	return holding{}
+}
+
+// testBlockStore holds the blocks of the Base league by height.
+type testBlockStore map[int64]*types.Block
+
+func (store testBlockStore) Height() int64 {
+	return int64(len(store))
+}
+
+func (store testBlockStore) LoadBlock(height int64) *types.Block {
+	return store[height]
+}
+
+func startEventBus(t *testing.T) *types.EventBus {
+	eventBus := types.NewEventBus()
+	require.NoError(t, eventBus.Start())
+	return eventBus
+}
+
+func publishBlock(t *testing.T, eventBus *types.EventBus, block *types.Block) {
+	require.NoError(t, eventBus.PublishEventNewBlock(types.EventDataNewBlock{Block: block}))
+}
+
+func TestManagerActivation(t *testing.T) {
+	pvs, vals := makeValidators(1)
+	baseBus, leagueBus := startEventBus(t), startEventBus(t)
+	defer baseBus.Stop()
+	defer leagueBus.Stop()
+
+	reconfigurer := newTestReconfigurer()
+	m := NewManager("base", baseBus, testBlockStore{}, NewProposalStore(dbm.NewMemDB()),
+		func(height int64) (*types.ValidatorSet, error) { return vals, nil }, reconfigurer)
+	m.SetValidator(pvs[0])
+	var submitted []types.Tx
+	m.SetTxBroadcaster(func(tx types.Tx) error {
+		submitted = append(submitted, tx)
+		return nil
+	})
+	require.NoError(t, m.Start())
+	defer m.Stop()
+
+	config := &cfg.BFTConsensusConfig{TimeoutCommit: time.Second}
+	current, err := m.TrackLeague("regular", config, 10, leagueBus)
+	require.NoError(t, err)
+	assert.True(t, current == config)
+
+	// proposals must leave time for the approval
+	update := ConfigUpdate{TimeoutCommit: durationPtr(3 * time.Second), BlockMaxBytes: int64Ptr(1024)}
+	_, err = m.ProposeConfigUpdate("regular", 11, update)
+	assert.Error(t, err)
+
+	id, err := m.ProposeConfigUpdate("regular", 12, update)
+	require.NoError(t, err)
+	require.NoError(t, m.ApproveProposal(id))
+	require.Len(t, submitted, 2)
+
+	block := &types.Block{}
+	block.Height = 1
+	block.Data.Txs = submitted
+	publishBlock(t, baseBus, block)
+
+	// the league is held until the update is approved, then the update is
+	// scheduled at its activation height
+	assert.Equal(t, holding{"regular", 12, true}, receiveHolding(t, reconfigurer))
+	r := receiveReconfiguration(t, reconfigurer)
+	assert.Equal(t, holding{"regular", 12, false}, receiveHolding(t, reconfigurer))
+	assert.Equal(t, "regular", r.leagueID)
+	assert.EqualValues(t, 12, r.height)
+	assert.Equal(t, 3*time.Second, r.config.(*cfg.BFTConsensusConfig).TimeoutCommit)
+	assert.EqualValues(t, 1024, r.state.ConsensusParams.Block.MaxBytes)
+	assert.Equal(t, time.Second, m.GetLeagueConfig("regular").(*cfg.BFTConsensusConfig).TimeoutCommit)
+	assert.Nil(t, m.GetLeagueConfig("other"))
+
+	// the update is scheduled again when tracking the league again
+	m.UntrackLeague("regular")
+	current, err = m.TrackLeague("regular", config, 10, leagueBus)
+	require.NoError(t, err)
+	assert.True(t, current == config)
+	assert.EqualValues(t, 12, receiveReconfiguration(t, reconfigurer).height)
+
+	// and in effect from the activation height
+	m.UntrackLeague("regular")
+	current, err = m.TrackLeague("regular", config, 11, leagueBus)
+	require.NoError(t, err)
+	assert.Equal(t, 3*time.Second, current.(*cfg.BFTConsensusConfig).TimeoutCommit)
+	assert.EqualValues(t, 12, receiveReconfiguration(t, reconfigurer).height)
+	assert.Empty(t, reconfigurer.reconfigured)
+	assert.Empty(t, reconfigurer.holdings)
+}
+
+func TestManagerDeadline(t *testing.T) {
+	pvs, vals := makeValidators(1)
+	baseBus, leagueBus := startEventBus(t), startEventBus(t)
+	defer baseBus.Stop()
+	defer leagueBus.Stop()
+
+	reconfigurer := newTestReconfigurer()
+	m := NewManager("base", baseBus, testBlockStore{}, NewProposalStore(dbm.NewMemDB()),
+		func(height int64) (*types.ValidatorSet, error) { return vals, nil }, reconfigurer)
+	require.NoError(t, m.Start())
+	defer m.Stop()
+
+	config := &cfg.BFTConsensusConfig{TimeoutCommit: time.Second}
+	_, err := m.TrackLeague("regular", config, 10, leagueBus)
+	require.NoError(t, err)
+
+	protocol := cfg.ConsensusProtocol(cfg.FBAConsensusProtocol)
+	proposal := ConfigProposal{LeagueID: "regular", Height: 12, Deadline: 2, Update: ConfigUpdate{Protocol: &protocol}}
+	publishBlock(t, baseBus, makeBlock(t, 1, &ConfigProposalTx{Proposal: proposal}))
+
+	// whatever its progress, the league waits for the decision of the Base
+	// league before the activation height
+	assert.Equal(t, holding{"regular", 12, true}, receiveHolding(t, reconfigurer))
+	m.UntrackLeague("regular")
+	assert.Equal(t, holding{"regular", 12, false}, receiveHolding(t, reconfigurer))
+	_, err = m.TrackLeague("regular", config, 11, leagueBus)
+	require.NoError(t, err)
+	assert.Equal(t, holding{"regular", 12, true}, receiveHolding(t, reconfigurer))
+
+	// which is known by every cell alike once the deadline passed
+	publishBlock(t, baseBus, makeBlock(t, 2))
+	assert.Equal(t, holding{"regular", 12, false}, receiveHolding(t, reconfigurer))
+	publishBlock(t, baseBus, makeBlock(t, 3, voteTx(t, pvs[0], "base", proposal.ID())))
+	waitBaseHeight(t, m, 3)
+	_, _, approved := m.store.GetProposal(proposal.ID())
+	assert.False(t, approved)
+	assert.Empty(t, reconfigurer.reconfigured)
+	assert.Empty(t, reconfigurer.holdings)
+	assert.True(t, m.GetLeagueConfig("regular") == config)
+}
+
+func TestManagerReplay(t *testing.T) {
+	pvs, vals := makeValidators(1)
+	baseBus, leagueBus := startEventBus(t), startEventBus(t)
+	defer baseBus.Stop()
+	defer leagueBus.Stop()
+
+	update := ConfigUpdate{TimeoutCommit: durationPtr(3 * time.Second)}
+	proposal := ConfigProposal{LeagueID: "regular", Height: 50, Deadline: 10, Update: update}
+	blockStore := testBlockStore{
+		1: makeBlock(t, 1, &ConfigProposalTx{Proposal: proposal}),
+		2: makeBlock(t, 2, voteTx(t, pvs[0], "base", proposal.ID())),
+	}
+
+	reconfigurer := newTestReconfigurer()
+	m := NewManager("base", baseBus, blockStore, NewProposalStore(dbm.NewMemDB()),
+		func(height int64) (*types.ValidatorSet, error) { return vals, nil }, reconfigurer)
+	config := &cfg.BFTConsensusConfig{TimeoutCommit: time.Second}
+	_, err := m.TrackLeague("regular", config, 10, leagueBus)
+	require.NoError(t, err)
+
+	// the blocks committed while the manager was not running are replayed
+	require.NoError(t, m.Start())
+	defer m.Stop()
+	r := receiveReconfiguration(t, reconfigurer)
+	assert.EqualValues(t, 50, r.height)
+	assert.Equal(t, 3*time.Second, r.config.(*cfg.BFTConsensusConfig).TimeoutCommit)
+
+	// and the blocks missed are loaded before a new one
+	blockStore[3] = makeBlock(t, 3)
+	blockStore[4] = makeBlock(t, 4)
+	publishBlock(t, baseBus, blockStore[4])
+	waitBaseHeight(t, m, 4)
+}
+
+// waitBaseHeight waits for the manager to process the Base league up to height.
+func waitBaseHeight(t *testing.T, m *Manager, height int64) {
+	for i := 0; i < 50; i++ {
+		if m.store.LastHeight() >= height {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("Base league height %d was not processed", height)
+}
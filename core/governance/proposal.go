@@ -0,0 +1,163 @@
+package governance
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	cfg "github.com/teragrid/dgrid/core/config"
+	"github.com/teragrid/dgrid/core/types"
+	"github.com/teragrid/dgrid/pkg/crypto/tmhash"
+)
+
+// ConfigUpdate is the change of the configuration of a league carried by a
+// proposal. Only the non-nil fields are changed.
+type ConfigUpdate struct {
+	Protocol *cfg.ConsensusProtocol `json:"protocol,omitempty"`
+
+	TimeoutPropose   *time.Duration `json:"timeout_propose,omitempty"`
+	TimeoutPrevote   *time.Duration `json:"timeout_prevote,omitempty"`
+	TimeoutPrecommit *time.Duration `json:"timeout_precommit,omitempty"`
+	TimeoutCommit    *time.Duration `json:"timeout_commit,omitempty"`
+
+	BlockMaxBytes *int64 `json:"block_max_bytes,omitempty"`
+	BlockMaxGas   *int64 `json:"block_max_gas,omitempty"`
+}
+
+// IsEmpty returns true if the update changes nothing.
+func (u *ConfigUpdate) IsEmpty() bool {
+	return u.Protocol == nil &&
+		u.TimeoutPropose == nil && u.TimeoutPrevote == nil &&
+		u.TimeoutPrecommit == nil && u.TimeoutCommit == nil &&
+		u.BlockMaxBytes == nil && u.BlockMaxGas == nil
+}
+
+// ValidateBasic performs basic validation.
+func (u *ConfigUpdate) ValidateBasic() error {
+	if u.IsEmpty() {
+		return errors.New("Empty config update")
+	}
+	if u.Protocol != nil {
+		switch *u.Protocol {
+		case cfg.BFTConsensusProtocol, cfg.FBAConsensusProtocol:
+		default:
+			return fmt.Errorf("Unknown consensus protocol %v", *u.Protocol)
+		}
+	}
+	for _, timeout := range []*time.Duration{u.TimeoutPropose, u.TimeoutPrevote, u.TimeoutPrecommit, u.TimeoutCommit} {
+		if timeout != nil && *timeout < 0 {
+			return errors.New("Negative timeout")
+		}
+	}
+	if u.BlockMaxBytes != nil && (*u.BlockMaxBytes <= 0 || *u.BlockMaxBytes > types.MaxBlockSizeBytes) {
+		return fmt.Errorf("BlockMaxBytes must be in (0, %d]", types.MaxBlockSizeBytes)
+	}
+	if u.BlockMaxGas != nil && *u.BlockMaxGas < -1 {
+		return errors.New("BlockMaxGas must be greater or equal to -1")
+	}
+	return nil
+}
+
+// Apply returns a copy of the consensus config of a league with the update
+// applied. If the protocol changes, the settings the protocols have in
+// common are carried over to the config of the new protocol.
+func (u *ConfigUpdate) Apply(config cfg.Config) (cfg.Config, error) {
+	protocol, err := protocolOf(config)
+	if err != nil {
+		return nil, err
+	}
+	if u.Protocol != nil {
+		protocol = *u.Protocol
+	}
+	// the conversion returns a copy even if the protocol is unchanged
+	newConfig, err := cfg.ConvertConsensusConfig(config, protocol)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c := newConfig.(type) {
+	case *cfg.BFTConsensusConfig:
+		u.applyTimeouts(&c.TimeoutPropose, &c.TimeoutPrevote, &c.TimeoutPrecommit, &c.TimeoutCommit)
+	case *cfg.FBAConsensusConfig:
+		u.applyTimeouts(&c.TimeoutPropose, &c.TimeoutPrevote, &c.TimeoutPrecommit, &c.TimeoutCommit)
+	}
+	return newConfig, nil
+}
+
+func (u *ConfigUpdate) applyTimeouts(propose, prevote, precommit, commit *time.Duration) {
+	for _, t := range []struct{ dst, src *time.Duration }{
+		{propose, u.TimeoutPropose},
+		{prevote, u.TimeoutPrevote},
+		{precommit, u.TimeoutPrecommit},
+		{commit, u.TimeoutCommit},
+	} {
+		if t.src != nil {
+			*t.dst = *t.src
+		}
+	}
+}
+
+// ApplyParams returns the consensus params with the update applied.
+func (u *ConfigUpdate) ApplyParams(params types.ConsensusParams) types.ConsensusParams {
+	if u.BlockMaxBytes != nil {
+		params.Block.MaxBytes = *u.BlockMaxBytes
+	}
+	if u.BlockMaxGas != nil {
+		params.Block.MaxGas = *u.BlockMaxGas
+	}
+	return params
+}
+
+// ChangesParams returns true if the update changes the consensus params.
+func (u *ConfigUpdate) ChangesParams() bool {
+	return u.BlockMaxBytes != nil || u.BlockMaxGas != nil
+}
+
+// ConfigProposal proposes to update the configuration of a league, from
+// the given height of that league. It must be approved by the validators
+// of the Base league by the deadline, a height of the Base league.
+type ConfigProposal struct {
+	LeagueID string       `json:"league_id"`
+	Height   int64        `json:"height"`
+	Deadline int64        `json:"deadline"`
+	Update   ConfigUpdate `json:"update"`
+	// Proposals are otherwise identical when the same update is proposed
+	// twice for the same height
+	Nonce uint64 `json:"nonce"`
+}
+
+// ID returns the hash identifying the proposal.
+func (p *ConfigProposal) ID() []byte {
+	return tmhash.Sum(cdc.MustMarshalBinaryBare(p))
+}
+
+// ValidateBasic performs basic validation.
+func (p *ConfigProposal) ValidateBasic() error {
+	if p.LeagueID == "" {
+		return errors.New("Empty LeagueID")
+	}
+	if p.Height <= 0 {
+		return errors.New("Non-positive Height")
+	}
+	if p.Deadline <= 0 {
+		return errors.New("Non-positive Deadline")
+	}
+	return p.Update.ValidateBasic()
+}
+
+// String returns a string representation of the ConfigProposal.
+func (p *ConfigProposal) String() string {
+	return fmt.Sprintf("ConfigProposal{%X %v@%d by %d}", p.ID()[:6], p.LeagueID, p.Height, p.Deadline)
+}
+
+// protocolOf returns the protocol of a consensus config.
+func protocolOf(config cfg.Config) (cfg.ConsensusProtocol, error) {
+	switch config.(type) {
+	case *cfg.BFTConsensusConfig:
+		return cfg.BFTConsensusProtocol, nil
+	case *cfg.FBAConsensusConfig:
+		return cfg.FBAConsensusProtocol, nil
+	default:
+		return 0, fmt.Errorf("Unknown consensus config %T", config)
+	}
+}
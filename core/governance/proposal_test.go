@@ -0,0 +1,106 @@
+package governance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cfg "github.com/teragrid/dgrid/core/config"
+	"github.com/teragrid/dgrid/core/types"
+)
+
+func durationPtr(d time.Duration) *time.Duration { return &d }
+func int64Ptr(i int64) *int64                    { return &i }
+
+func TestConfigUpdateApply(t *testing.T) {
+	config := &cfg.BFTConsensusConfig{
+		WalPath:        "data/cs.wal/wal",
+		TimeoutPropose: time.Second,
+		TimeoutCommit:  time.Second,
+	}
+	update := ConfigUpdate{TimeoutCommit: durationPtr(5 * time.Second)}
+	newConfig, err := update.Apply(config)
+	require.NoError(t, err)
+	bft, ok := newConfig.(*cfg.BFTConsensusConfig)
+	require.True(t, ok)
+	assert.Equal(t, 5*time.Second, bft.TimeoutCommit)
+	assert.Equal(t, time.Second, bft.TimeoutPropose)
+	// the config of the league is left untouched
+	assert.Equal(t, time.Second, config.TimeoutCommit)
+
+	fba := cfg.ConsensusProtocol(cfg.FBAConsensusProtocol)
+	update = ConfigUpdate{Protocol: &fba}
+	newConfig, err = update.Apply(config)
+	require.NoError(t, err)
+	fbaConfig, ok := newConfig.(*cfg.FBAConsensusConfig)
+	require.True(t, ok)
+	assert.Equal(t, config.WalPath, fbaConfig.WalPath)
+	assert.Equal(t, config.TimeoutPropose, fbaConfig.TimeoutPropose)
+}
+
+func TestConfigUpdateApplyParams(t *testing.T) {
+	params := *types.DefaultConsensusParams()
+	update := ConfigUpdate{BlockMaxBytes: int64Ptr(1024)}
+	assert.True(t, update.ChangesParams())
+	newParams := update.ApplyParams(params)
+	assert.EqualValues(t, 1024, newParams.Block.MaxBytes)
+	assert.Equal(t, params.Block.MaxGas, newParams.Block.MaxGas)
+}
+
+func TestConfigProposalValidateBasic(t *testing.T) {
+	valid := ConfigProposal{LeagueID: "regular", Height: 10, Deadline: 5, Update: ConfigUpdate{TimeoutCommit: durationPtr(time.Second)}}
+	require.NoError(t, valid.ValidateBasic())
+
+	unknown := cfg.ConsensusProtocol(-1)
+	testCases := []struct {
+		name     string
+		malleate func(*ConfigProposal)
+	}{
+		{"no league", func(p *ConfigProposal) { p.LeagueID = "" }},
+		{"no height", func(p *ConfigProposal) { p.Height = 0 }},
+		{"no deadline", func(p *ConfigProposal) { p.Deadline = 0 }},
+		{"empty update", func(p *ConfigProposal) { p.Update = ConfigUpdate{} }},
+		{"negative timeout", func(p *ConfigProposal) { p.Update.TimeoutCommit = durationPtr(-time.Second) }},
+		{"unknown protocol", func(p *ConfigProposal) { p.Update.Protocol = &unknown }},
+		{"big blocks", func(p *ConfigProposal) { p.Update.BlockMaxBytes = int64Ptr(types.MaxBlockSizeBytes + 1) }},
+		{"invalid gas", func(p *ConfigProposal) { p.Update.BlockMaxGas = int64Ptr(-2) }},
+	}
+	for _, tc := range testCases {
+		p := valid
+		tc.malleate(&p)
+		assert.Error(t, p.ValidateBasic(), tc.name)
+	}
+}
+
+func TestGovernanceTxEncoding(t *testing.T) {
+	proposal := ConfigProposal{LeagueID: "regular", Height: 10, Deadline: 5, Update: ConfigUpdate{TimeoutCommit: durationPtr(time.Second)}}
+	tx, err := EncodeTx(&ConfigProposalTx{Proposal: proposal})
+	require.NoError(t, err)
+	assert.True(t, IsGovernanceTx(tx))
+
+	gtx, err := DecodeTx(tx)
+	require.NoError(t, err)
+	decoded, ok := gtx.(*ConfigProposalTx)
+	require.True(t, ok)
+	assert.Equal(t, proposal.ID(), decoded.Proposal.ID())
+
+	_, err = DecodeTx(types.Tx("key=value"))
+	assert.Error(t, err)
+}
+
+func TestDiffConfigs(t *testing.T) {
+	from := &cfg.BFTConsensusConfig{TimeoutCommit: time.Second}
+	to := &cfg.FBAConsensusConfig{TimeoutCommit: 2 * time.Second}
+	update, err := diffConfigs(from, to)
+	require.NoError(t, err)
+	require.NotNil(t, update.Protocol)
+	assert.Equal(t, cfg.ConsensusProtocol(cfg.FBAConsensusProtocol), *update.Protocol)
+	require.NotNil(t, update.TimeoutCommit)
+	assert.Equal(t, 2*time.Second, *update.TimeoutCommit)
+	assert.Nil(t, update.TimeoutPropose)
+
+	_, err = diffConfigs(from, from)
+	assert.Error(t, err)
+}
@@ -0,0 +1,285 @@
+package governance
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/teragrid/dgrid/core/types"
+	dbm "github.com/teragrid/dgrid/pkg/db"
+)
+
+/*
+ProposalStore persists the proposals submitted to the Base league, the
+validators which approved them, and the indexes of the pending proposals
+and of the approved updates:
+
+	"P:<id>"                                          -> proposalRecord
+	"U:<len>:<leagueID>:<id>"                         -> id
+	"A:<len>:<leagueID>:<height>:<approvedAt>:<id>"   -> id
+	"lastHeight"                                      -> last Base height processed
+
+Heights are zero-padded, so the approved updates of a league are iterated
+in activation order, ties broken by the order of approval.
+*/
+type ProposalStore struct {
+	db dbm.DB
+}
+
+var lastHeightKey = []byte("lastHeight")
+
+// ApprovalPeriod is the maximum number of heights of the Base league
+// between the submission of a proposal and its deadline. The league of the
+// proposal is held at the activation height until the proposal is approved
+// or its deadline passed, so the period bounds the delay a proposal causes.
+const ApprovalPeriod = 50
+
+// proposalRecord is the state of a proposal in the store.
+type proposalRecord struct {
+	Proposal ConfigProposal  `json:"proposal"`
+	Voters   []types.Address `json:"voters"`
+	// Height of the Base league at which the proposal was approved,
+	// 0 while it is pending
+	ApprovedAt int64 `json:"approved_at"`
+}
+
+func (rec *proposalRecord) hasVoted(addr types.Address) bool {
+	for _, voter := range rec.Voters {
+		if bytes.Equal(voter, addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewProposalStore returns a new ProposalStore using the given db.
+func NewProposalStore(db dbm.DB) *ProposalStore {
+	return &ProposalStore{db: db}
+}
+
+func keyProposal(id []byte) []byte {
+	return []byte(fmt.Sprintf("P:%X", id))
+}
+
+func keyPendingPrefix(leagueID string) []byte {
+	return []byte(fmt.Sprintf("U:%d:%s:", len(leagueID), leagueID))
+}
+
+func keyPending(p *ConfigProposal) []byte {
+	return append(keyPendingPrefix(p.LeagueID), []byte(fmt.Sprintf("%X", p.ID()))...)
+}
+
+func keyApprovedPrefix(leagueID string) []byte {
+	return []byte(fmt.Sprintf("A:%d:%s:", len(leagueID), leagueID))
+}
+
+func keyApproved(p *ConfigProposal, approvedAt int64) []byte {
+	return append(keyApprovedPrefix(p.LeagueID),
+		[]byte(fmt.Sprintf("%020d:%020d:%X", p.Height, approvedAt, p.ID()))...)
+}
+
+// loadProposal returns the record of a proposal, or nil if it is unknown.
+func (store *ProposalStore) loadProposal(id []byte) *proposalRecord {
+	bz := store.db.Get(keyProposal(id))
+	if len(bz) == 0 {
+		return nil
+	}
+	rec := new(proposalRecord)
+	if err := cdc.UnmarshalBinaryBare(bz, rec); err != nil {
+		panic(fmt.Sprintf("Error reading proposal %X: %v", id, err))
+	}
+	return rec
+}
+
+// saveProposal saves the record of a proposal, and indexes it as pending
+// or approved.
+func (store *ProposalStore) saveProposal(batch dbm.Batch, rec *proposalRecord) {
+	batch.Set(keyProposal(rec.Proposal.ID()), cdc.MustMarshalBinaryBare(rec))
+	if rec.ApprovedAt > 0 {
+		batch.Delete(keyPending(&rec.Proposal))
+		batch.Set(keyApproved(&rec.Proposal, rec.ApprovedAt), rec.Proposal.ID())
+	} else {
+		batch.Set(keyPending(&rec.Proposal), rec.Proposal.ID())
+	}
+}
+
+// GetProposal returns a proposal, whether it is approved and by whom.
+func (store *ProposalStore) GetProposal(id []byte) (p *ConfigProposal, voters []types.Address, approved bool) {
+	rec := store.loadProposal(id)
+	if rec == nil {
+		return nil, nil, false
+	}
+	return &rec.Proposal, rec.Voters, rec.ApprovedAt > 0
+}
+
+// ApprovedProposals returns the approved proposals of a league with an
+// activation height not greater than maxHeight, in activation order.
+func (store *ProposalStore) ApprovedProposals(leagueID string, maxHeight int64) []*ConfigProposal {
+	var proposals []*ConfigProposal
+	itr := dbm.IteratePrefix(store.db, keyApprovedPrefix(leagueID))
+	defer itr.Close()
+	for ; itr.Valid(); itr.Next() {
+		rec := store.loadProposal(itr.Value())
+		if rec == nil {
+			panic(fmt.Sprintf("Approved proposal %X is missing", itr.Value()))
+		}
+		if rec.Proposal.Height > maxHeight {
+			break
+		}
+		proposals = append(proposals, &rec.Proposal)
+	}
+	return proposals
+}
+
+// PendingProposals returns the proposals of a league which were neither
+// approved nor expired yet.
+func (store *ProposalStore) PendingProposals(leagueID string) []*ConfigProposal {
+	var proposals []*ConfigProposal
+	itr := dbm.IteratePrefix(store.db, keyPendingPrefix(leagueID))
+	defer itr.Close()
+	for ; itr.Valid(); itr.Next() {
+		rec := store.loadProposal(itr.Value())
+		if rec == nil {
+			panic(fmt.Sprintf("Pending proposal %X is missing", itr.Value()))
+		}
+		proposals = append(proposals, &rec.Proposal)
+	}
+	return proposals
+}
+
+// LastHeight returns the last height of the Base league processed.
+func (store *ProposalStore) LastHeight() int64 {
+	bz := store.db.Get(lastHeightKey)
+	if len(bz) == 0 {
+		return 0
+	}
+	var height int64
+	cdc.MustUnmarshalBinaryBare(bz, &height)
+	return height
+}
+
+func (store *ProposalStore) setLastHeight(batch dbm.Batch, height int64) {
+	batch.Set(lastHeightKey, cdc.MustMarshalBinaryBare(height))
+}
+
+// BlockResult lists the proposals whose state changed in a block of the
+// Base league.
+type BlockResult struct {
+	Submitted []*ConfigProposal
+	Approved  []*ConfigProposal
+	// proposals whose deadline passed without approval
+	Expired []*ConfigProposal
+}
+
+// ApplyBlock processes the governance transactions of a block of the Base
+// league: proposals are recorded, and the votes of the validators are
+// tallied until the proposal gathers more than 2/3 of the voting power.
+// A proposal whose deadline is over or more than ApprovalPeriod heights
+// away is ignored, and so are the votes after the deadline, so whether a
+// proposal is approved only depends on the blocks of the Base league.
+// Blocks must be applied in order; a block already applied is ignored.
+func (store *ProposalStore) ApplyBlock(baseLeagueID string, block *types.Block,
+	vals *types.ValidatorSet) BlockResult {
+	var res BlockResult
+	if block.Height <= store.LastHeight() {
+		return res
+	}
+
+	batch := store.db.NewBatch()
+	defer batch.Close()
+
+	// records modified by the block, so several votes of the block add up
+	modified := make(map[string]*proposalRecord)
+	load := func(id []byte) *proposalRecord {
+		if rec, ok := modified[string(id)]; ok {
+			return rec
+		}
+		return store.loadProposal(id)
+	}
+
+	for _, tx := range block.Data.Txs {
+		if !IsGovernanceTx(tx) {
+			continue
+		}
+		gtx, err := DecodeTx(tx)
+		if err != nil {
+			// the application is responsible for rejecting invalid txs,
+			// an invalid one which made it into a block has no effect
+			continue
+		}
+
+		switch gtx := gtx.(type) {
+		case *ConfigProposalTx:
+			p := gtx.Proposal
+			if p.Deadline < block.Height || p.Deadline > block.Height+ApprovalPeriod {
+				continue
+			}
+			id := p.ID()
+			if load(id) == nil {
+				rec := &proposalRecord{Proposal: p}
+				modified[string(id)] = rec
+				store.saveProposal(batch, rec)
+				res.Submitted = append(res.Submitted, &rec.Proposal)
+			}
+		case *ConfigVoteTx:
+			vote := gtx.Vote
+			rec := load(vote.ProposalID)
+			if rec == nil || rec.ApprovedAt > 0 || rec.hasVoted(vote.ValidatorAddress) {
+				continue
+			}
+			if block.Height > rec.Proposal.Deadline {
+				continue
+			}
+			_, val := vals.GetByAddress(vote.ValidatorAddress)
+			if val == nil || vote.Verify(baseLeagueID, val.PubKey) != nil {
+				continue
+			}
+			rec.Voters = append(rec.Voters, vote.ValidatorAddress)
+			if votingPower(rec.Voters, vals)*3 > vals.TotalVotingPower()*2 {
+				rec.ApprovedAt = block.Height
+				res.Approved = append(res.Approved, &rec.Proposal)
+			}
+			modified[string(vote.ProposalID)] = rec
+			store.saveProposal(batch, rec)
+		}
+	}
+
+	res.Expired = store.expireProposals(batch, block.Height, modified)
+
+	store.setLastHeight(batch, block.Height)
+	batch.WriteSync()
+	return res
+}
+
+// expireProposals removes from the pending proposals those whose deadline
+// is over at the given height, and returns them. modified holds the records
+// modified by the block, which aren't written yet.
+func (store *ProposalStore) expireProposals(batch dbm.Batch, height int64,
+	modified map[string]*proposalRecord) []*ConfigProposal {
+	var ids []string
+	itr := dbm.IteratePrefix(store.db, []byte("U:"))
+	for ; itr.Valid(); itr.Next() {
+		if _, ok := modified[string(itr.Value())]; !ok {
+			ids = append(ids, string(itr.Value()))
+		}
+	}
+	itr.Close()
+	for id := range modified {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var expired []*ConfigProposal
+	for _, id := range ids {
+		rec, ok := modified[id]
+		if !ok {
+			rec = store.loadProposal([]byte(id))
+		}
+		if rec == nil || rec.ApprovedAt > 0 || rec.Proposal.Deadline > height {
+			continue
+		}
+		batch.Delete(keyPending(&rec.Proposal))
+		expired = append(expired, &rec.Proposal)
+	}
+	return expired
+}
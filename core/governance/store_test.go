@@ -0,0 +1,146 @@
+package governance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teragrid/dgrid/core/types"
+	dbm "github.com/teragrid/dgrid/pkg/db"
+)
+
+func makeValidators(n int) ([]*types.MockPV, *types.ValidatorSet) {
+	pvs := make([]*types.MockPV, n)
+	vals := make([]*types.Validator, n)
+	for i := range pvs {
+		pvs[i] = types.NewMockPV()
+		vals[i] = types.NewValidator(pvs[i].GetPubKey(), 10)
+	}
+	return pvs, types.NewValidatorSet(vals)
+}
+
+func makeBlock(t *testing.T, height int64, gtxs ...GovernanceTx) *types.Block {
+	txs := types.Txs{types.Tx("app tx")}
+	for _, gtx := range gtxs {
+		tx, err := EncodeTx(gtx)
+		require.NoError(t, err)
+		txs = append(txs, tx)
+	}
+	block := &types.Block{}
+	block.Height = height
+	block.Data.Txs = txs
+	return block
+}
+
+func voteTx(t *testing.T, pv *types.MockPV, leagueID string, proposalID []byte) *ConfigVoteTx {
+	vote := &types.ConfigVote{
+		ProposalID:       proposalID,
+		ValidatorAddress: pv.GetPubKey().Address(),
+	}
+	require.NoError(t, pv.SignConfigVote(leagueID, vote))
+	return &ConfigVoteTx{Vote: vote}
+}
+
+func TestProposalStoreApproval(t *testing.T) {
+	pvs, vals := makeValidators(4)
+	store := NewProposalStore(dbm.NewMemDB())
+
+	proposal := ConfigProposal{LeagueID: "regular", Height: 50, Deadline: 10, Update: ConfigUpdate{TimeoutCommit: durationPtr(time.Second)}}
+	id := proposal.ID()
+
+	res := store.ApplyBlock("base", makeBlock(t, 1, &ConfigProposalTx{Proposal: proposal}), vals)
+	require.Len(t, res.Submitted, 1)
+	assert.Equal(t, id, res.Submitted[0].ID())
+	assert.Empty(t, res.Approved)
+	assert.Len(t, store.PendingProposals("regular"), 1)
+	p, voters, ok := store.GetProposal(id)
+	require.NotNil(t, p)
+	assert.Empty(t, voters)
+	assert.False(t, ok)
+
+	// votes signed for another league, twice by the same validator,
+	// or by a stranger don't count
+	res = store.ApplyBlock("base", makeBlock(t, 2,
+		voteTx(t, pvs[0], "base", id),
+		voteTx(t, pvs[0], "base", id),
+		voteTx(t, pvs[1], "regular", id),
+		voteTx(t, types.NewMockPV(), "base", id),
+	), vals)
+	assert.Empty(t, res.Approved)
+	_, voters, _ = store.GetProposal(id)
+	assert.Len(t, voters, 1)
+
+	// half of the voting power is not enough
+	res = store.ApplyBlock("base", makeBlock(t, 3, voteTx(t, pvs[1], "base", id)), vals)
+	assert.Empty(t, res.Approved)
+	assert.Empty(t, store.ApprovedProposals("regular", 100))
+
+	res = store.ApplyBlock("base", makeBlock(t, 4, voteTx(t, pvs[2], "base", id)), vals)
+	require.Len(t, res.Approved, 1)
+	assert.Equal(t, id, res.Approved[0].ID())
+	assert.Empty(t, store.PendingProposals("regular"))
+	assert.Empty(t, store.ApprovedProposals("regular", 49))
+	assert.Len(t, store.ApprovedProposals("regular", 50), 1)
+	assert.Empty(t, store.ApprovedProposals("other", 100))
+
+	// a block is applied once
+	assert.EqualValues(t, 4, store.LastHeight())
+	assert.Empty(t, store.ApplyBlock("base", makeBlock(t, 4, voteTx(t, pvs[3], "base", id)), vals).Approved)
+	_, voters, _ = store.GetProposal(id)
+	assert.Len(t, voters, 3)
+}
+
+func TestProposalStoreActivationOrder(t *testing.T) {
+	pvs, vals := makeValidators(1)
+	store := NewProposalStore(dbm.NewMemDB())
+
+	late := ConfigProposal{LeagueID: "regular", Height: 200, Deadline: 1, Update: ConfigUpdate{TimeoutCommit: durationPtr(2 * time.Second)}}
+	early := ConfigProposal{LeagueID: "regular", Height: 100, Deadline: 1, Update: ConfigUpdate{TimeoutCommit: durationPtr(time.Second)}}
+	store.ApplyBlock("base", makeBlock(t, 1,
+		&ConfigProposalTx{Proposal: late},
+		&ConfigProposalTx{Proposal: early},
+		voteTx(t, pvs[0], "base", late.ID()),
+		voteTx(t, pvs[0], "base", early.ID()),
+	), vals)
+
+	proposals := store.ApprovedProposals("regular", 1000)
+	require.Len(t, proposals, 2)
+	assert.Equal(t, early.ID(), proposals[0].ID())
+	assert.Equal(t, late.ID(), proposals[1].ID())
+}
+
+func TestProposalStoreDeadline(t *testing.T) {
+	pvs, vals := makeValidators(1)
+	store := NewProposalStore(dbm.NewMemDB())
+
+	update := ConfigUpdate{TimeoutCommit: durationPtr(time.Second)}
+	proposal := ConfigProposal{LeagueID: "regular", Height: 500, Deadline: 3, Update: update}
+	// the proposals whose deadline is over or too far away are ignored
+	overdue := ConfigProposal{LeagueID: "regular", Height: 500, Deadline: 1, Update: update}
+	distant := ConfigProposal{LeagueID: "regular", Height: 500, Deadline: 3 + ApprovalPeriod, Update: update}
+	res := store.ApplyBlock("base", makeBlock(t, 2,
+		&ConfigProposalTx{Proposal: proposal},
+		&ConfigProposalTx{Proposal: overdue},
+		&ConfigProposalTx{Proposal: distant},
+	), vals)
+	require.Len(t, res.Submitted, 1)
+	assert.Equal(t, proposal.ID(), res.Submitted[0].ID())
+	p, _, _ := store.GetProposal(distant.ID())
+	assert.Nil(t, p)
+
+	// the proposal expires at its deadline
+	res = store.ApplyBlock("base", makeBlock(t, 3), vals)
+	require.Len(t, res.Expired, 1)
+	assert.Equal(t, proposal.ID(), res.Expired[0].ID())
+	assert.Empty(t, store.PendingProposals("regular"))
+
+	// and the votes coming later are ignored
+	res = store.ApplyBlock("base", makeBlock(t, 4, voteTx(t, pvs[0], "base", proposal.ID())), vals)
+	assert.Empty(t, res.Approved)
+	assert.Empty(t, res.Expired)
+	_, voters, ok := store.GetProposal(proposal.ID())
+	assert.Empty(t, voters)
+	assert.False(t, ok)
+}
@@ -0,0 +1,69 @@
+package governance
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/teragrid/dgrid/core/types"
+)
+
+// TxPrefix starts the governance transactions of the Base league, so they
+// can be told apart from the transactions of the application.
+var TxPrefix = []byte("dgrid/gov:")
+
+// GovernanceTx is a transaction of the Base league governing the leagues.
+type GovernanceTx interface {
+	ValidateBasic() error
+}
+
+// ConfigProposalTx submits a ConfigProposal.
+type ConfigProposalTx struct {
+	Proposal ConfigProposal `json:"proposal"`
+}
+
+// ValidateBasic performs basic validation.
+func (tx *ConfigProposalTx) ValidateBasic() error {
+	return tx.Proposal.ValidateBasic()
+}
+
+// ConfigVoteTx approves a ConfigProposal.
+type ConfigVoteTx struct {
+	Vote *types.ConfigVote `json:"vote"`
+}
+
+// ValidateBasic performs basic validation.
+func (tx *ConfigVoteTx) ValidateBasic() error {
+	if tx.Vote == nil {
+		return errors.New("Vote is missing")
+	}
+	return tx.Vote.ValidateBasic()
+}
+
+// EncodeTx returns the Base league transaction of a governance tx.
+func EncodeTx(gtx GovernanceTx) (types.Tx, error) {
+	bz, err := cdc.MarshalBinaryBare(gtx)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, TxPrefix...), bz...), nil
+}
+
+// IsGovernanceTx returns true if tx is a governance transaction.
+func IsGovernanceTx(tx types.Tx) bool {
+	return bytes.HasPrefix(tx, TxPrefix)
+}
+
+// DecodeTx decodes and validates a governance transaction.
+func DecodeTx(tx types.Tx) (GovernanceTx, error) {
+	if !IsGovernanceTx(tx) {
+		return nil, errors.New("Not a governance transaction")
+	}
+	var gtx GovernanceTx
+	if err := cdc.UnmarshalBinaryBare(tx[len(TxPrefix):], &gtx); err != nil {
+		return nil, err
+	}
+	if err := gtx.ValidateBasic(); err != nil {
+		return nil, err
+	}
+	return gtx, nil
+}
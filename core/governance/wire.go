@@ -0,0 +1,20 @@
+package governance
+
+import (
+	"github.com/teragrid/dgrid/core/types"
+	amino "github.com/teragrid/dgrid/third_party/amino"
+)
+
+var cdc = amino.NewCodec()
+
+func init() {
+	RegisterGovernanceTxs(cdc)
+	types.RegisterBlockAmino(cdc)
+}
+
+// RegisterGovernanceTxs registers the governance transactions on the codec.
+func RegisterGovernanceTxs(cdc *amino.Codec) {
+	cdc.RegisterInterface((*GovernanceTx)(nil), nil)
+	cdc.RegisterConcrete(&ConfigProposalTx{}, "dgrid/gov/ConfigProposal", nil)
+	cdc.RegisterConcrete(&ConfigVoteTx{}, "dgrid/gov/ConfigVote", nil)
+}
@@ -304,11 +304,25 @@ func (b *Block) Unmarshal(bs []byte) error {
 //
 // XXX: Panics on negative result.
 func MaxDataBytes(maxBytes int64, valsCount, evidenceCount int) int64 {
+	return maxDataBytes(maxBytes, int64(valsCount)*MaxVoteBytes, int64(evidenceCount)*MaxEvidenceBytes)
+}
+
+// MaxDataBytesOf is MaxDataBytes in a league with the validators vals,
+// whose votes and evidence are larger if some are threshold validators.
+//
+// XXX: Panics on negative result.
+func MaxDataBytesOf(maxBytes int64, vals *ValidatorSet, evidenceCount int) int64 {
+	return maxDataBytes(maxBytes,
+		int64(vals.Size())*MaxVoteBytesOf(vals),
+		int64(evidenceCount)*MaxEvidenceBytesOf(vals))
+}
+
+func maxDataBytes(maxBytes, votesBytes, evidenceBytes int64) int64 {
 	maxDataBytes := maxBytes -
 		MaxAminoOverheadForBlock -
 		MaxHeaderBytes -
-		int64(valsCount)*MaxVoteBytes -
-		int64(evidenceCount)*MaxEvidenceBytes
+		votesBytes -
+		evidenceBytes
 
 	if maxDataBytes < 0 {
 		panic(fmt.Sprintf(
@@ -328,11 +342,23 @@ func MaxDataBytes(maxBytes int64, valsCount, evidenceCount int) int64 {
 //
 // XXX: Panics on negative result.
 func MaxDataBytesUnknownEvidence(maxBytes int64, valsCount int) int64 {
+	return maxDataBytesUnknownEvidence(maxBytes, int64(valsCount)*MaxVoteBytes)
+}
+
+// MaxDataBytesUnknownEvidenceOf is MaxDataBytesUnknownEvidence in a league
+// with the validators vals, see MaxDataBytesOf.
+//
+// XXX: Panics on negative result.
+func MaxDataBytesUnknownEvidenceOf(maxBytes int64, vals *ValidatorSet) int64 {
+	return maxDataBytesUnknownEvidence(maxBytes, int64(vals.Size())*MaxVoteBytesOf(vals))
+}
+
+func maxDataBytesUnknownEvidence(maxBytes, votesBytes int64) int64 {
 	_, maxEvidenceBytes := MaxEvidencePerBlock(maxBytes)
 	maxDataBytes := maxBytes -
 		MaxAminoOverheadForBlock -
 		MaxHeaderBytes -
-		int64(valsCount)*MaxVoteBytes -
+		votesBytes -
 		maxEvidenceBytes
 
 	if maxDataBytes < 0 {
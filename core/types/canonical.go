@@ -41,6 +41,35 @@ type CanonicalVote struct {
 	LeagueID  string
 }
 
+type CanonicalSCPBallot struct {
+	Counter int64 `binary:"fixed64"`
+	Value   CanonicalBlockID
+}
+
+type CanonicalSCPStatement struct {
+	Type             SignedMsgType // type alias for byte
+	StatementType    SCPStatementType
+	Height           int64 `binary:"fixed64"`
+	ValidatorAddress Address
+	QuorumSetHash    cmn.HexBytes
+	Votes            []CanonicalBlockID
+	Accepted         []CanonicalBlockID
+	Ballot           *CanonicalSCPBallot
+	Prepared         *CanonicalSCPBallot
+	PreparedPrime    *CanonicalSCPBallot
+	NPrepared        int64 `binary:"fixed64"`
+	NCommit          int64 `binary:"fixed64"`
+	NHigh            int64 `binary:"fixed64"`
+	LeagueID         string
+}
+
+type CanonicalConfigVote struct {
+	Type             SignedMsgType // type alias for byte
+	ProposalID       cmn.HexBytes
+	ValidatorAddress Address
+	LeagueID         string
+}
+
 //-----------------------------------
 // Canonicalize the structs
 
@@ -81,6 +110,51 @@ func CanonicalizeVote(leagueID string, vote *Vote) CanonicalVote {
 	}
 }
 
+func CanonicalizeConfigVote(leagueID string, vote *ConfigVote) CanonicalConfigVote {
+	return CanonicalConfigVote{
+		Type:             ConfigVoteMsgType,
+		ProposalID:       vote.ProposalID,
+		ValidatorAddress: vote.ValidatorAddress,
+		LeagueID:         leagueID,
+	}
+}
+
+func CanonicalizeSCPBallot(ballot *SCPBallot) *CanonicalSCPBallot {
+	if ballot == nil {
+		return nil
+	}
+	return &CanonicalSCPBallot{
+		Counter: int64(ballot.Counter),
+		Value:   CanonicalizeBlockID(ballot.Value),
+	}
+}
+
+func CanonicalizeSCPStatement(leagueID string, stmt *SCPStatement) CanonicalSCPStatement {
+	cstmt := CanonicalSCPStatement{
+		Type:             SCPStatementMsgType,
+		StatementType:    stmt.Type,
+		Height:           stmt.Height,
+		ValidatorAddress: stmt.ValidatorAddress,
+		Ballot:           CanonicalizeSCPBallot(stmt.Ballot),
+		Prepared:         CanonicalizeSCPBallot(stmt.Prepared),
+		PreparedPrime:    CanonicalizeSCPBallot(stmt.PreparedPrime),
+		NPrepared:        int64(stmt.NPrepared),
+		NCommit:          int64(stmt.NCommit),
+		NHigh:            int64(stmt.NHigh),
+		LeagueID:         leagueID,
+	}
+	if stmt.QuorumSet != nil {
+		cstmt.QuorumSetHash = stmt.QuorumSet.Hash()
+	}
+	for _, blockID := range stmt.Votes {
+		cstmt.Votes = append(cstmt.Votes, CanonicalizeBlockID(blockID))
+	}
+	for _, blockID := range stmt.Accepted {
+		cstmt.Accepted = append(cstmt.Accepted, CanonicalizeBlockID(blockID))
+	}
+	return cstmt
+}
+
 // CanonicalTime can be used to stringify time in a canonical way.
 func CanonicalTime(t time.Time) string {
 	// Note that sending time over amino resets it to
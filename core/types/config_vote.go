@@ -0,0 +1,79 @@
+package types
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	cmn "github.com/teragrid/dgrid/pkg/common"
+	"github.com/teragrid/dgrid/pkg/crypto"
+	"github.com/teragrid/dgrid/pkg/crypto/tmhash"
+)
+
+var (
+	ErrConfigVoteInvalidSignature = errors.New("Invalid config vote signature")
+	ErrConfigVoteInvalidAddress   = errors.New("Invalid config vote validator address")
+)
+
+// ConfigVote is the approval, by a validator of the Base league, of a
+// proposal to update the configuration of a league.
+type ConfigVote struct {
+	ProposalID       cmn.HexBytes `json:"proposal_id"`
+	ValidatorAddress Address      `json:"validator_address"`
+	Signature        []byte       `json:"signature"`
+}
+
+// ValidateBasic performs basic validation.
+func (vote *ConfigVote) ValidateBasic() error {
+	if len(vote.ProposalID) != tmhash.Size {
+		return fmt.Errorf("Expected ProposalID size to be %d bytes, got %d bytes",
+			tmhash.Size,
+			len(vote.ProposalID),
+		)
+	}
+	if len(vote.ValidatorAddress) != crypto.AddressSize {
+		return fmt.Errorf("Expected ValidatorAddress size to be %d bytes, got %d bytes",
+			crypto.AddressSize,
+			len(vote.ValidatorAddress),
+		)
+	}
+	if len(vote.Signature) == 0 {
+		return errors.New("Signature is missing")
+	}
+	if len(vote.Signature) > MaxThresholdSignatureSize {
+		return fmt.Errorf("Signature is too big (max: %d)", MaxThresholdSignatureSize)
+	}
+	return nil
+}
+
+// SignBytes returns the ConfigVote bytes for signing.
+func (vote *ConfigVote) SignBytes(leagueID string) []byte {
+	bz, err := cdc.MarshalBinaryLengthPrefixed(CanonicalizeConfigVote(leagueID, vote))
+	if err != nil {
+		panic(err)
+	}
+	return bz
+}
+
+// Verify checks the vote was signed by the given public key.
+func (vote *ConfigVote) Verify(leagueID string, pubKey crypto.PubKey) error {
+	if !bytes.Equal(pubKey.Address(), vote.ValidatorAddress) {
+		return ErrConfigVoteInvalidAddress
+	}
+	if !pubKey.VerifyBytes(vote.SignBytes(leagueID), vote.Signature) {
+		return ErrConfigVoteInvalidSignature
+	}
+	return nil
+}
+
+// String returns a string representation of the ConfigVote.
+func (vote *ConfigVote) String() string {
+	if vote == nil {
+		return "nil-ConfigVote"
+	}
+	return fmt.Sprintf("ConfigVote{%X %X %X}",
+		cmn.Fingerprint(vote.ValidatorAddress),
+		cmn.Fingerprint(vote.ProposalID),
+		cmn.Fingerprint(vote.Signature),
+	)
+}
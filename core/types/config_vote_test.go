@@ -0,0 +1,50 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teragrid/dgrid/pkg/crypto/tmhash"
+)
+
+func TestConfigVoteSignAndVerify(t *testing.T) {
+	pv := NewMockPV()
+	pubKey := pv.GetPubKey()
+	vote := &ConfigVote{
+		ProposalID:       tmhash.Sum([]byte("proposal")),
+		ValidatorAddress: pubKey.Address(),
+	}
+	require.NoError(t, pv.SignConfigVote("base", vote))
+	require.NoError(t, vote.ValidateBasic())
+	assert.NoError(t, vote.Verify("base", pubKey))
+
+	// the signature is bound to the league
+	assert.Equal(t, ErrConfigVoteInvalidSignature, vote.Verify("other", pubKey))
+
+	// and to the validator
+	assert.Equal(t, ErrConfigVoteInvalidAddress, vote.Verify("base", NewMockPV().GetPubKey()))
+}
+
+func TestConfigVoteValidateBasic(t *testing.T) {
+	pv := NewMockPV()
+	testCases := []struct {
+		name     string
+		malleate func(*ConfigVote)
+	}{
+		{"short proposal id", func(vote *ConfigVote) { vote.ProposalID = []byte{1} }},
+		{"short address", func(vote *ConfigVote) { vote.ValidatorAddress = []byte{1} }},
+		{"no signature", func(vote *ConfigVote) { vote.Signature = nil }},
+		{"big signature", func(vote *ConfigVote) { vote.Signature = make([]byte, MaxThresholdSignatureSize+1) }},
+	}
+	for _, tc := range testCases {
+		vote := &ConfigVote{
+			ProposalID:       tmhash.Sum([]byte("proposal")),
+			ValidatorAddress: pv.GetPubKey().Address(),
+		}
+		require.NoError(t, pv.SignConfigVote("base", vote))
+		tc.malleate(vote)
+		assert.Error(t, vote.ValidateBasic(), tc.name)
+	}
+}
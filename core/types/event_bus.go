@@ -169,6 +169,21 @@ func (b *EventBus) PublishEventTx(data EventDataTx) error {
 	return nil
 }
 
+// PublishEventTxExpired publishes tx expired event. Note it will add the
+// predefined tags (EventTypeKey, TxHashKey).
+func (b *EventBus) PublishEventTxExpired(data EventDataTxExpired) error {
+	// no explicit deadline for publishing events
+	ctx := context.Background()
+
+	tags := map[string]string{
+		EventTypeKey: EventTxExpired,
+		TxHashKey:    fmt.Sprintf("%X", data.Tx.Hash()),
+	}
+
+	b.pubsub.PublishWithTags(ctx, data, tags)
+	return nil
+}
+
 func (b *EventBus) PublishEventNewRoundStep(data EventDataRoundState) error {
 	return b.Publish(EventNewRoundStep, data)
 }
@@ -246,6 +261,10 @@ func (NopEventBus) PublishEventTx(data EventDataTx) error {
 	return nil
 }
 
+func (NopEventBus) PublishEventTxExpired(data EventDataTxExpired) error {
+	return nil
+}
+
 func (NopEventBus) PublishEventNewRoundStep(data EventDataRoundState) error {
 	return nil
 }
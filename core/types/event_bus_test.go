@@ -55,6 +55,38 @@ func TestEventBusPublishEventTx(t *testing.T) {
 	}
 }
 
+func TestEventBusPublishEventTxExpired(t *testing.T) {
+	eventBus := NewEventBus()
+	err := eventBus.Start()
+	require.NoError(t, err)
+	defer eventBus.Stop()
+
+	tx := Tx("foo")
+
+	// PublishEventTxExpired adds these 2 tags, so the query below should work
+	query := fmt.Sprintf("tm.event='TxExpired' AND tx.hash='%X'", tx.Hash())
+	txsSub, err := eventBus.Subscribe(context.Background(), "test", tquery.MustParse(query))
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		msg := <-txsSub.Out()
+		edt := msg.Data().(EventDataTxExpired)
+		assert.Equal(t, int64(3), edt.Height)
+		assert.Equal(t, tx, edt.Tx)
+		close(done)
+	}()
+
+	err = eventBus.PublishEventTxExpired(EventDataTxExpired{Tx: tx, Height: 3})
+	assert.NoError(t, err)
+
+	select {
+	case <-done:
+	case <-time.After(1 * time.Second):
+		t.Fatal("did not receive a transaction after 1 sec.")
+	}
+}
+
 func TestEventBusPublishEventNewBlock(t *testing.T) {
 	eventBus := NewEventBus()
 	err := eventBus.Start()
@@ -139,7 +171,7 @@ func TestEventBusPublish(t *testing.T) {
 	require.NoError(t, err)
 	defer eventBus.Stop()
 
-	const numEventsExpected = 14
+	const numEventsExpected = 15
 
 	sub, err := eventBus.Subscribe(context.Background(), "test", tquery.Empty{}, numEventsExpected)
 	require.NoError(t, err)
@@ -184,6 +216,8 @@ func TestEventBusPublish(t *testing.T) {
 	require.NoError(t, err)
 	err = eventBus.PublishEventValidatorSetUpdates(EventDataValidatorSetUpdates{})
 	require.NoError(t, err)
+	err = eventBus.PublishEventTxExpired(EventDataTxExpired{})
+	require.NoError(t, err)
 
 	select {
 	case <-done:
@@ -21,6 +21,11 @@ const (
 	EventTx                  = "Tx"
 	EventValidatorSetUpdates = "ValidatorSetUpdates"
 
+	// Storage events.
+	// These are triggered from the storage package, when a tx is dropped
+	// before being committed.
+	EventTxExpired = "TxExpired"
+
 	// Internal consensus events.
 	// These are used for testing the consensus state machine.
 	// They can also be used to build real-time consensus visualizers.
@@ -56,6 +61,7 @@ func RegisterEventDatas(cdc *amino.Codec) {
 	cdc.RegisterConcrete(EventDataCompleteProposal{}, "teragrid/event/CompleteProposal", nil)
 	cdc.RegisterConcrete(EventDataVote{}, "teragrid/event/Vote", nil)
 	cdc.RegisterConcrete(EventDataValidatorSetUpdates{}, "teragrid/event/ValidatorSetUpdates", nil)
+	cdc.RegisterConcrete(EventDataTxExpired{}, "teragrid/event/TxExpired", nil)
 	cdc.RegisterConcrete(EventDataString(""), "teragrid/event/ProposalString", nil)
 }
 
@@ -120,6 +126,13 @@ type EventDataValidatorSetUpdates struct {
 	ValidatorUpdates []*Validator `json:"validator_updates"`
 }
 
+// EventDataTxExpired is fired when a tx is dropped from the storage because
+// it stayed there too long.
+type EventDataTxExpired struct {
+	Tx     Tx    `json:"tx"`
+	Height int64 `json:"height"` // height of the block after which the tx expired
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 // PUBSUB
 ///////////////////////////////////////////////////////////////////////////////
@@ -147,6 +160,7 @@ var (
 	EventQueryTimeoutPropose      = QueryForEvent(EventTimeoutPropose)
 	EventQueryTimeoutWait         = QueryForEvent(EventTimeoutWait)
 	EventQueryTx                  = QueryForEvent(EventTx)
+	EventQueryTxExpired           = QueryForEvent(EventTxExpired)
 	EventQueryUnlock              = QueryForEvent(EventUnlock)
 	EventQueryValidatorSetUpdates = QueryForEvent(EventValidatorSetUpdates)
 	EventQueryValidBlock          = QueryForEvent(EventValidBlock)
@@ -172,3 +186,8 @@ type BlockEventPublisher interface {
 type TxEventPublisher interface {
 	PublishEventTx(EventDataTx) error
 }
+
+// StorageEventPublisher publishes the events of the storage
+type StorageEventPublisher interface {
+	PublishEventTxExpired(EventDataTxExpired) error
+}
@@ -16,6 +16,10 @@ import (
 const (
 	// MaxEvidenceBytes is a maximum size of any evidence (including amino overhead).
 	MaxEvidenceBytes int64 = 484
+
+	// MaxThresholdEvidenceBytes is a maximum size of any evidence of a
+	// threshold validator, whose key and signatures are longer.
+	MaxThresholdEvidenceBytes int64 = 1189
 )
 
 // ErrEvidenceInvalid wraps a piece of evidence and the error denoting how or why it is invalid.
@@ -85,8 +89,18 @@ const (
 // of the maximum block size).
 // TODO: change to a constant, or to a fraction of the validator set size.
 func MaxEvidencePerBlock(blockMaxBytes int64) (int64, int64) {
+	return maxEvidencePerBlock(blockMaxBytes, MaxEvidenceBytes)
+}
+
+// MaxEvidencePerBlockOf is MaxEvidencePerBlock in a league with the
+// validators vals, see MaxEvidenceBytesOf.
+func MaxEvidencePerBlockOf(blockMaxBytes int64, vals *ValidatorSet) (int64, int64) {
+	return maxEvidencePerBlock(blockMaxBytes, MaxEvidenceBytesOf(vals))
+}
+
+func maxEvidencePerBlock(blockMaxBytes, maxEvidenceBytes int64) (int64, int64) {
 	maxBytes := blockMaxBytes / MaxEvidenceBytesDenominator
-	maxNum := maxBytes / MaxEvidenceBytes
+	maxNum := maxBytes / maxEvidenceBytes
 	return maxNum, maxBytes
 }
 
@@ -6,6 +6,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/teragrid/dgrid/pkg/crypto/multisig"
 	"github.com/teragrid/dgrid/pkg/crypto/secp256k1"
 	"github.com/teragrid/dgrid/pkg/crypto/tmhash"
 )
@@ -110,6 +111,15 @@ func TestMaxEvidenceBytes(t *testing.T) {
 	require.NoError(t, err)
 
 	assert.EqualValues(t, MaxEvidenceBytes, len(bz))
+
+	// the key and the signatures of a threshold validator are longer
+	ev.PubKey = multisig.NewPubKeyMultisigThreshold(MaxThresholdSigners, thresholdPubKeys(MaxThresholdSigners))
+	ev.VoteA.Signature = make([]byte, MaxThresholdSignatureSize)
+	ev.VoteB.Signature = make([]byte, MaxThresholdSignatureSize)
+	bz, err = cdc.MarshalBinaryLengthPrefixed(ev)
+	require.NoError(t, err)
+
+	assert.EqualValues(t, MaxThresholdEvidenceBytes, len(bz))
 }
 
 func randomDuplicatedVoteEvidence() *DuplicateVoteEvidence {
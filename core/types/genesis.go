@@ -24,11 +24,14 @@ const (
 // docs/teragrid-core/using-teragrid.md
 
 // GenesisValidator is an initial validator.
+// The QuorumSet declares its quorum slices in FBA leagues; validators
+// without one trust +2/3 of the genesis validators.
 type GenesisValidator struct {
-	Address Address       `json:"address"`
-	PubKey  crypto.PubKey `json:"pub_key"`
-	Power   int64         `json:"power"`
-	Name    string        `json:"name"`
+	Address   Address       `json:"address"`
+	PubKey    crypto.PubKey `json:"pub_key"`
+	Power     int64         `json:"power"`
+	Name      string        `json:"name"`
+	QuorumSet *QuorumSet    `json:"quorum_set,omitempty"`
 }
 
 // GenesisDoc defines the initial conditions for a teragrid blockchain, in particular its validator set.
@@ -82,6 +85,9 @@ func (genDoc *GenesisDoc) ValidateAndComplete() error {
 		if v.Power == 0 {
 			return cmn.NewError("The genesis file cannot contain validators with no voting power: %v", v)
 		}
+		if err := ValidateValidatorPubKey(v.PubKey); err != nil {
+			return cmn.NewError("Invalid key for validator %v in the genesis file: %v", v, err)
+		}
 		if len(v.Address) > 0 && !bytes.Equal(v.PubKey.Address(), v.Address) {
 			return cmn.NewError("Incorrect address for validator %v in the genesis file, should be %v", v, v.PubKey.Address())
 		}
@@ -90,6 +96,10 @@ func (genDoc *GenesisDoc) ValidateAndComplete() error {
 		}
 	}
 
+	if err := genDoc.validateQuorumSets(); err != nil {
+		return err
+	}
+
 	if genDoc.GenesisTime.IsZero() {
 		genDoc.GenesisTime = ttime.Now()
 	}
@@ -97,6 +107,49 @@ func (genDoc *GenesisDoc) ValidateAndComplete() error {
 	return nil
 }
 
+// validateQuorumSets checks the quorum sets of the validators are
+// well-formed and only reference genesis validators.
+func (genDoc *GenesisDoc) validateQuorumSets() error {
+	validators := make(map[string]bool, len(genDoc.Validators))
+	for _, v := range genDoc.Validators {
+		validators[string(v.Address)] = true
+	}
+	for _, v := range genDoc.Validators {
+		if v.QuorumSet == nil {
+			continue
+		}
+		if err := v.QuorumSet.ValidateBasic(); err != nil {
+			return cmn.NewError("Invalid quorum set for validator %v in the genesis file: %v", v.Address, err)
+		}
+		for _, addr := range v.QuorumSet.Members() {
+			if !validators[string(addr)] {
+				return cmn.NewError("Quorum set of validator %v references %v, which is not a genesis validator", v.Address, addr)
+			}
+		}
+	}
+	return nil
+}
+
+// QuorumSets returns the quorum set of every genesis validator, keyed by
+// string(address). Validators without one get +2/3 of the validators.
+func (genDoc *GenesisDoc) QuorumSets() map[string]*QuorumSet {
+	vals := make([]*Validator, len(genDoc.Validators))
+	for i, v := range genDoc.Validators {
+		vals[i] = NewValidator(v.PubKey, v.Power)
+	}
+	majority := NewMajorityQuorumSet(NewValidatorSet(vals))
+
+	qsets := make(map[string]*QuorumSet, len(genDoc.Validators))
+	for _, v := range genDoc.Validators {
+		qs := v.QuorumSet
+		if qs == nil {
+			qs = majority
+		}
+		qsets[string(v.PubKey.Address())] = qs
+	}
+	return qsets
+}
+
 //------------------------------------------------------------
 // Make genesis state from file
 
@@ -45,7 +45,7 @@ func TestGenesisGood(t *testing.T) {
 	// create a base gendoc from struct
 	baseGenDoc := &GenesisDoc{
 		LeagueID:    "abc",
-		Validators: []GenesisValidator{{pubkey.Address(), pubkey, 10, "myval"}},
+		Validators: []GenesisValidator{{pubkey.Address(), pubkey, 10, "myval", nil}},
 	}
 	genDocBytes, err = cdc.MarshalJSON(baseGenDoc)
 	assert.NoError(t, err, "error marshalling genDoc")
@@ -122,7 +122,33 @@ func randomGenesisDoc() *GenesisDoc {
 	return &GenesisDoc{
 		GenesisTime:     ttime.Now(),
 		LeagueID:         "abc",
-		Validators:      []GenesisValidator{{pubkey.Address(), pubkey, 10, "myval"}},
+		Validators:      []GenesisValidator{{pubkey.Address(), pubkey, 10, "myval", nil}},
 		ConsensusParams: DefaultConsensusParams(),
 	}
 }
+
+func TestGenesisQuorumSets(t *testing.T) {
+	pk1, pk2 := ed25519.GenPrivKey().PubKey(), ed25519.GenPrivKey().PubKey()
+	genDoc := &GenesisDoc{
+		LeagueID: "abc",
+		Validators: []GenesisValidator{
+			{PubKey: pk1, Power: 10, QuorumSet: &QuorumSet{Threshold: 1, Validators: []Address{pk2.Address()}}},
+			{PubKey: pk2, Power: 10},
+		},
+	}
+	require.NoError(t, genDoc.ValidateAndComplete())
+
+	qsets := genDoc.QuorumSets()
+	assert.Len(t, qsets, 2)
+	assert.Equal(t, 1, qsets[string(pk1.Address())].Threshold)
+	// validators without a quorum set trust +2/3 of the genesis validators
+	assert.Equal(t, 2, qsets[string(pk2.Address())].Threshold)
+
+	// unknown validator
+	genDoc.Validators[0].QuorumSet.Validators = []Address{ed25519.GenPrivKey().PubKey().Address()}
+	assert.Error(t, genDoc.ValidateAndComplete())
+
+	// malformed quorum set
+	genDoc.Validators[0].QuorumSet = &QuorumSet{Threshold: 2, Validators: []Address{pk2.Address()}}
+	assert.Error(t, genDoc.ValidateAndComplete())
+}
@@ -20,6 +20,8 @@ type ConsensusParams struct {
 	Block     BlockParams     `json:"block"`
 	Evidence  EvidenceParams  `json:"evidence"`
 	Validator ValidatorParams `json:"validator"`
+	Proposer  ProposerParams  `json:"proposer"`
+	Execution ExecutionParams `json:"execution"`
 }
 
 // HashedParams is a subset of ConsensusParams.
@@ -28,6 +30,12 @@ type ConsensusParams struct {
 type HashedParams struct {
 	BlockMaxBytes int64
 	BlockMaxGas   int64
+
+	// empty for the priority selection, so the hash of the params of the
+	// leagues using it doesn't change
+	ProposerSelection string
+	// likewise, false without pipelining
+	ExecutionPipelined bool
 }
 
 // BlockParams define limits on the block size and gas plus minimum time
@@ -51,12 +59,30 @@ type ValidatorParams struct {
 	PubKeyTypes []string `json:"pub_key_types"`
 }
 
+// ProposerParams determine how the proposer of each round is selected.
+type ProposerParams struct {
+	// ProposerSelectionPriority (or empty) or ProposerSelectionRandom
+	Selection string `json:"selection"`
+}
+
+// ExecutionParams determine how the committed blocks are executed.
+// They can only be set in the genesis.
+type ExecutionParams struct {
+	// Execute a block while the next height is proposed and voted. The
+	// AppHash and LastResultsHash of a header are then those of the block
+	// two heights before, instead of the previous one, and the validator
+	// updates take effect a height later.
+	Pipelined bool `json:"pipelined"`
+}
+
 // DefaultConsensusParams returns a default ConsensusParams.
 func DefaultConsensusParams() *ConsensusParams {
 	return &ConsensusParams{
 		DefaultBlockParams(),
 		DefaultEvidenceParams(),
 		DefaultValidatorParams(),
+		DefaultProposerParams(),
+		DefaultExecutionParams(),
 	}
 }
 
@@ -82,6 +108,18 @@ func DefaultValidatorParams() ValidatorParams {
 	return ValidatorParams{[]string{AsuraPubKeyTypeEd25519}}
 }
 
+// DefaultProposerParams returns a default ProposerParams, which selects the
+// proposers by priority.
+func DefaultProposerParams() ProposerParams {
+	return ProposerParams{ProposerSelectionPriority}
+}
+
+// DefaultExecutionParams returns a default ExecutionParams, which executes
+// each block before the next height starts.
+func DefaultExecutionParams() ExecutionParams {
+	return ExecutionParams{Pipelined: false}
+}
+
 func (params *ValidatorParams) IsValidPubkeyType(pubkeyType string) bool {
 	for i := 0; i < len(params.PubKeyTypes); i++ {
 		if params.PubKeyTypes[i] == pubkeyType {
@@ -131,18 +169,32 @@ func (params *ConsensusParams) Validate() error {
 		}
 	}
 
+	switch params.Proposer.Selection {
+	case "", ProposerSelectionPriority, ProposerSelectionRandom:
+	default:
+		return cmn.NewError("Proposer.Selection must be %q or %q. Got %q",
+			ProposerSelectionPriority, ProposerSelectionRandom, params.Proposer.Selection)
+	}
+
 	return nil
 }
 
 // Hash returns a hash of a subset of the parameters to store in the block header.
-// Only the Block.MaxBytes and Block.MaxGas are included in the hash.
+// Only the Block.MaxBytes, Block.MaxGas, the random proposer selection and
+// the pipelined execution are included in the hash.
 // This allows the ConsensusParams to evolve more without breaking the block
 // protocol. No need for a Merkle tree here, just a small struct to hash.
 func (params *ConsensusParams) Hash() []byte {
 	hasher := tmhash.New()
+	var proposerSelection string
+	if params.Proposer.Random() {
+		proposerSelection = ProposerSelectionRandom
+	}
 	bz := cdcEncode(HashedParams{
 		params.Block.MaxBytes,
 		params.Block.MaxGas,
+		proposerSelection,
+		params.Execution.Pipelined,
 	})
 	if bz == nil {
 		panic("cannot fail to encode ConsensusParams")
@@ -154,6 +206,8 @@ func (params *ConsensusParams) Hash() []byte {
 func (params *ConsensusParams) Equals(params2 *ConsensusParams) bool {
 	return params.Block == params2.Block &&
 		params.Evidence == params2.Evidence &&
+		params.Proposer.Random() == params2.Proposer.Random() &&
+		params.Execution == params2.Execution &&
 		cmn.StringSliceEqual(params.Validator.PubKeyTypes, params2.Validator.PubKeyTypes)
 }
 
@@ -128,3 +128,16 @@ func TestConsensusParamsUpdate(t *testing.T) {
 		assert.Equal(t, tc.updatedParams, tc.params.Update(tc.updates))
 	}
 }
+
+func TestConsensusParamsExecution(t *testing.T) {
+	params := makeParams(1, 2, 10, 3, valEd25519)
+	pipelined := params
+	pipelined.Execution.Pipelined = true
+	assert.NoError(t, pipelined.Validate())
+	assert.NotEqual(t, params.Hash(), pipelined.Hash())
+	assert.False(t, params.Equals(&pipelined))
+
+	// the application can't switch the pipelining on or off
+	updated := pipelined.Update(&asura.ConsensusParams{Block: &asura.BlockParams{MaxBytes: 100, MaxGas: 200}})
+	assert.True(t, updated.Execution.Pipelined)
+}
@@ -18,6 +18,18 @@ type Validator interface {
 	SignProposal(leagueID string, proposal *Proposal) error
 }
 
+// StatementSigner is implemented by validators which are able to sign the
+// statements of the federated (SCP) consensus protocol.
+type StatementSigner interface {
+	SignStatement(leagueID string, stmt *SCPStatement) error
+}
+
+// ConfigVoteSigner is implemented by validators which are able to approve
+// the proposals to update the configuration of a league.
+type ConfigVoteSigner interface {
+	SignConfigVote(leagueID string, vote *ConfigVote) error
+}
+
 //----------------------------------------
 // Misc.
 
@@ -94,6 +106,34 @@ func (pv *MockPV) SignProposal(leagueID string, proposal *Proposal) error {
 	return nil
 }
 
+// Implements StatementSigner.
+func (pv *MockPV) SignStatement(leagueID string, stmt *SCPStatement) error {
+	useLeagueID := leagueID
+	if pv.breakVoteSigning {
+		useLeagueID = "incorrect-chain-id"
+	}
+	sig, err := pv.privKey.Sign(stmt.SignBytes(useLeagueID))
+	if err != nil {
+		return err
+	}
+	stmt.Signature = sig
+	return nil
+}
+
+// Implements ConfigVoteSigner.
+func (pv *MockPV) SignConfigVote(leagueID string, vote *ConfigVote) error {
+	useLeagueID := leagueID
+	if pv.breakVoteSigning {
+		useLeagueID = "incorrect-chain-id"
+	}
+	sig, err := pv.privKey.Sign(vote.SignBytes(useLeagueID))
+	if err != nil {
+		return err
+	}
+	vote.Signature = sig
+	return nil
+}
+
 // String returns a string representation of the MockPV.
 func (pv *MockPV) String() string {
 	addr := pv.GetPubKey().Address()
@@ -122,6 +162,16 @@ func (pv *erroringMockPV) SignProposal(leagueID string, proposal *Proposal) erro
 	return ErroringMockPVErr
 }
 
+// Implements StatementSigner.
+func (pv *erroringMockPV) SignStatement(leagueID string, stmt *SCPStatement) error {
+	return ErroringMockPVErr
+}
+
+// Implements ConfigVoteSigner.
+func (pv *erroringMockPV) SignConfigVote(leagueID string, vote *ConfigVote) error {
+	return ErroringMockPVErr
+}
+
 // NewErroringMockPV returns a MockPV that fails on each signing request. Again, for testing only.
 func NewErroringMockPV() *erroringMockPV {
 	return &erroringMockPV{&MockPV{ed25519.GenPrivKey(), false, false}}
@@ -70,8 +70,8 @@ func (p *Proposal) ValidateBasic() error {
 	if len(p.Signature) == 0 {
 		return errors.New("Signature is missing")
 	}
-	if len(p.Signature) > MaxSignatureSize {
-		return fmt.Errorf("Signature is too big (max: %d)", MaxSignatureSize)
+	if len(p.Signature) > MaxThresholdSignatureSize {
+		return fmt.Errorf("Signature is too big (max: %d)", MaxThresholdSignatureSize)
 	}
 	return nil
 }
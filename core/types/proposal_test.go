@@ -121,7 +121,7 @@ func TestProposalValidateBasic(t *testing.T) {
 			p.Signature = make([]byte, 0)
 		}, true},
 		{"Too big Signature", func(p *Proposal) {
-			p.Signature = make([]byte, MaxSignatureSize+1)
+			p.Signature = make([]byte, MaxThresholdSignatureSize+1)
 		}, true},
 	}
 	blockID := makeBlockID(tmhash.Sum([]byte("blockhash")), math.MaxInt64, tmhash.Sum([]byte("partshash")))
@@ -0,0 +1,81 @@
+package types
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/teragrid/dgrid/pkg/crypto/tmhash"
+)
+
+// Proposer selections, see ProposerParams.
+const (
+	// ProposerSelectionPriority selects the validators in turn, by
+	// priority weighted by voting power: see IncrementProposerPriority.
+	// The schedule of the proposers is known in advance.
+	ProposerSelectionPriority = "priority"
+
+	// ProposerSelectionRandom selects the proposer of each round
+	// pseudo-randomly, weighted by voting power, from the precommits
+	// of an earlier height, see ProposerSeed.
+	ProposerSelectionRandom = "random"
+)
+
+// Random returns true if the proposers are selected at random.
+func (params ProposerParams) Random() bool {
+	return params.Selection == ProposerSelectionRandom
+}
+
+// ProposerSeed returns the value selecting the proposer of a round.
+// lastCommitHash is the LastCommitHash of the header of the previous height,
+// ie. the hash of the precommits committing the height before it, which
+// every validator holds in its block store. It is empty for the first two
+// heights, whose proposers only depend on the height and round.
+//
+// NOTE: the seed is not unpredictable nor unbiasable. The proposers of a
+// height are known as soon as the block before is proposed, and its
+// proposer can bias them by choosing which +2/3 of the precommits it
+// includes in its block, but unlike the content of a block, it can't grind
+// the precommits of the other validators. The selection only
+// spreads the proposals by voting power without following the priority
+// schedule.
+func ProposerSeed(lastCommitHash []byte, height int64, round int) []byte {
+	bz := make([]byte, len(lastCommitHash)+16)
+	copy(bz, lastCommitHash)
+	binary.BigEndian.PutUint64(bz[len(lastCommitHash):], uint64(height))
+	binary.BigEndian.PutUint64(bz[len(lastCommitHash)+8:], uint64(round))
+	return tmhash.Sum(bz)
+}
+
+// RandomProposer returns the proposer of a round with the random proposer
+// selection, see ProposerSeed. A validator is selected with a probability
+// proportional to its voting power. The priorities of the validators are
+// ignored.
+func (vals *ValidatorSet) RandomProposer(lastCommitHash []byte, height int64, round int) *Validator {
+	if vals.IsNilOrEmpty() {
+		return nil
+	}
+	seed := new(big.Int).SetBytes(ProposerSeed(lastCommitHash, height, round))
+	target := seed.Mod(seed, big.NewInt(vals.TotalVotingPower())).Int64()
+	for _, val := range vals.Validators {
+		if target < val.VotingPower {
+			return val
+		}
+		target -= val.VotingPower
+	}
+	panic("RandomProposer: target out of the total voting power")
+}
+
+// VerifyRandomProposer returns an error if address isn't the proposer of
+// one of the rounds up to maxRound with the random proposer selection. A
+// block committed in a round may have been proposed in an earlier one, so
+// maxRound is the round of its commit.
+func (vals *ValidatorSet) VerifyRandomProposer(lastCommitHash []byte, height int64, maxRound int, address Address) error {
+	for round := 0; round <= maxRound; round++ {
+		if proposer := vals.RandomProposer(lastCommitHash, height, round); proposer != nil && bytes.Equal(proposer.Address, address) {
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid proposer %X at height %d: not selected up to round %d", address, height, maxRound)
+}
@@ -0,0 +1,79 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teragrid/dgrid/pkg/crypto/tmhash"
+)
+
+func TestRandomProposer(t *testing.T) {
+	vals := NewValidatorSet([]*Validator{
+		newValidator([]byte("a"), 1),
+		newValidator([]byte("b"), 3),
+		newValidator([]byte("c"), 6),
+	})
+	lastCommitHash := tmhash.Sum([]byte("last commit"))
+
+	// deterministic, and independent of the priorities
+	proposer := vals.RandomProposer(lastCommitHash, 10, 0)
+	require.NotNil(t, proposer)
+	incremented := vals.CopyIncrementProposerPriority(5)
+	assert.Equal(t, proposer.Address, incremented.RandomProposer(lastCommitHash, 10, 0).Address)
+
+	// weighted by voting power
+	counts := make(map[string]int)
+	for height := int64(1); height <= 1000; height++ {
+		counts[string(vals.RandomProposer(lastCommitHash, height, 0).Address)]++
+	}
+	assert.InDelta(t, 100, counts["a"], 50)
+	assert.InDelta(t, 300, counts["b"], 75)
+	assert.InDelta(t, 600, counts["c"], 75)
+
+	// the seed depends on the last commit and the round
+	assert.NotEqual(t, ProposerSeed(lastCommitHash, 10, 0), ProposerSeed(lastCommitHash, 10, 1))
+	assert.NotEqual(t, ProposerSeed(lastCommitHash, 10, 0), ProposerSeed(nil, 10, 0))
+
+	assert.Nil(t, NewValidatorSet(nil).RandomProposer(lastCommitHash, 1, 0))
+}
+
+func TestVerifyRandomProposer(t *testing.T) {
+	vals := NewValidatorSet([]*Validator{
+		newValidator([]byte("a"), 1),
+		newValidator([]byte("b"), 1),
+		newValidator([]byte("c"), 1),
+	})
+	lastCommitHash := tmhash.Sum([]byte("last commit"))
+
+	proposer := vals.RandomProposer(lastCommitHash, 5, 0)
+	assert.NoError(t, vals.VerifyRandomProposer(lastCommitHash, 5, 0, proposer.Address))
+	assert.Error(t, vals.VerifyRandomProposer(lastCommitHash, 5, 0, []byte("unknown")))
+
+	// a block proposed in round 1 and committed in round 3
+	proposer = vals.RandomProposer(lastCommitHash, 5, 1)
+	assert.NoError(t, vals.VerifyRandomProposer(lastCommitHash, 5, 3, proposer.Address))
+}
+
+func TestConsensusParamsProposer(t *testing.T) {
+	params := makeParams(1, 2, 10, 3, valEd25519)
+	require.NoError(t, params.Validate())
+	assert.False(t, params.Proposer.Random())
+
+	// the priority selection doesn't change the hash of the params
+	priority := params
+	priority.Proposer = DefaultProposerParams()
+	assert.Equal(t, params.Hash(), priority.Hash())
+	assert.True(t, params.Equals(&priority))
+
+	random := params
+	random.Proposer.Selection = ProposerSelectionRandom
+	require.NoError(t, random.Validate())
+	assert.True(t, random.Proposer.Random())
+	assert.NotEqual(t, params.Hash(), random.Hash())
+	assert.False(t, params.Equals(&random))
+
+	random.Proposer.Selection = "dice"
+	assert.Error(t, random.Validate())
+}
@@ -8,6 +8,8 @@ import (
 	asura "github.com/teragrid/dgrid/asura/types"
 	"github.com/teragrid/dgrid/pkg/crypto"
 	"github.com/teragrid/dgrid/pkg/crypto/ed25519"
+	cryptoAmino "github.com/teragrid/dgrid/pkg/crypto/encoding/amino"
+	"github.com/teragrid/dgrid/pkg/crypto/multisig"
 	"github.com/teragrid/dgrid/pkg/crypto/secp256k1"
 )
 
@@ -22,12 +24,18 @@ const (
 const (
 	AsuraPubKeyTypeEd25519   = "ed25519"
 	AsuraPubKeyTypeSecp256k1 = "secp256k1"
+
+	// AsuraPubKeyTypeMultisigThreshold is the type of the key of a threshold
+	// validator, see ValidateThresholdPubKey. Its data is the amino encoded
+	// key.
+	AsuraPubKeyTypeMultisigThreshold = "multisig-threshold"
 )
 
 // TODO: Make non-global by allowing for registration of more pubkey types
 var AsuraPubKeyTypesToAminoNames = map[string]string{
-	AsuraPubKeyTypeEd25519:   ed25519.PubKeyAminoName,
-	AsuraPubKeyTypeSecp256k1: secp256k1.PubKeyAminoName,
+	AsuraPubKeyTypeEd25519:           ed25519.PubKeyAminoName,
+	AsuraPubKeyTypeSecp256k1:         secp256k1.PubKeyAminoName,
+	AsuraPubKeyTypeMultisigThreshold: multisig.PubKeyMultisigThresholdAminoRoute,
 }
 
 //-------------------------------------------------------
@@ -109,6 +117,11 @@ func (tm2pb) PubKey(pubKey crypto.PubKey) asura.PubKey {
 			Type: AsuraPubKeyTypeSecp256k1,
 			Data: pk[:],
 		}
+	case multisig.PubKeyMultisigThreshold:
+		return asura.PubKey{
+			Type: AsuraPubKeyTypeMultisigThreshold,
+			Data: pk.Bytes(),
+		}
 	default:
 		panic(fmt.Sprintf("unknown pubkey type: %v %v", pubKey, reflect.TypeOf(pubKey)))
 	}
@@ -204,6 +217,19 @@ func (pb2tm) PubKey(pubKey asura.PubKey) (crypto.PubKey, error) {
 		var pk secp256k1.PubKeySecp256k1
 		copy(pk[:], pubKey.Data)
 		return pk, nil
+	case AsuraPubKeyTypeMultisigThreshold:
+		pk, err := cryptoAmino.PubKeyFromBytes(pubKey.Data)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid PubKeyMultisigThreshold: %v", err)
+		}
+		thresholdKey, ok := pk.(multisig.PubKeyMultisigThreshold)
+		if !ok {
+			return nil, fmt.Errorf("Invalid PubKeyMultisigThreshold, got %T", pk)
+		}
+		if err := ValidateThresholdPubKey(thresholdKey); err != nil {
+			return nil, err
+		}
+		return thresholdKey, nil
 	default:
 		return nil, fmt.Errorf("Unknown pubkey type %v", pubKey.Type)
 	}
@@ -0,0 +1,210 @@
+package types
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/teragrid/dgrid/pkg/crypto"
+	"github.com/teragrid/dgrid/pkg/crypto/tmhash"
+)
+
+// MaxQuorumSetDepth is the maximum nesting of inner sets in a QuorumSet.
+const MaxQuorumSetDepth = 4
+
+// QuorumSet describes the quorum slices of a validator running the FBA
+// consensus protocol. A slice is any set of nodes which satisfies Threshold
+// of the entries of the quorum set, where an entry is either one of the
+// Validators or one of the (recursively defined) InnerSets.
+//
+// The entries weigh 1, unless Weights is set: Weights[i] is then the weight
+// of Validators[i], and Threshold a total weight.
+type QuorumSet struct {
+	Threshold  int          `json:"threshold"`
+	Validators []Address    `json:"validators"`
+	Weights    []int64      `json:"weights,omitempty"`
+	InnerSets  []*QuorumSet `json:"inner_sets,omitempty"`
+}
+
+// NewMajorityQuorumSet returns a flat quorum set over all the validators of
+// the given set, weighted by their voting power, with a threshold of +2/3 of
+// the total voting power.
+func NewMajorityQuorumSet(vals *ValidatorSet) *QuorumSet {
+	qs := &QuorumSet{}
+	vals.Iterate(func(index int, val *Validator) bool {
+		qs.Validators = append(qs.Validators, val.Address)
+		qs.Weights = append(qs.Weights, val.VotingPower)
+		return false
+	})
+	qs.Threshold = int(vals.TotalVotingPower()*2/3 + 1)
+	return qs
+}
+
+// ValidateBasic checks the quorum set is well-formed: every level has a
+// threshold between 1 and its number of entries, the addresses are valid,
+// no validator appears twice and the nesting is at most MaxQuorumSetDepth.
+func (qs *QuorumSet) ValidateBasic() error {
+	return qs.validateBasic(0, make(map[string]bool))
+}
+
+func (qs *QuorumSet) validateBasic(depth int, seen map[string]bool) error {
+	if depth > MaxQuorumSetDepth {
+		return fmt.Errorf("Quorum set is nested too deeply (max: %d)", MaxQuorumSetDepth)
+	}
+	if qs.Size() == 0 {
+		return errors.New("Quorum set has no validators nor inner sets")
+	}
+	if len(qs.Weights) > 0 && len(qs.Weights) != len(qs.Validators) {
+		return fmt.Errorf("Quorum set has %d weights for %d validators", len(qs.Weights), len(qs.Validators))
+	}
+	for _, weight := range qs.Weights {
+		if weight < 1 {
+			return fmt.Errorf("Quorum set weights must be positive, got %d", weight)
+		}
+	}
+	if total := qs.totalWeight(); qs.Threshold < 1 || int64(qs.Threshold) > total {
+		return fmt.Errorf("Quorum set threshold must be between 1 and %d, got %d", total, qs.Threshold)
+	}
+	for _, addr := range qs.Validators {
+		if len(addr) != crypto.AddressSize {
+			return fmt.Errorf("Expected validator address size to be %d bytes, got %d bytes",
+				crypto.AddressSize,
+				len(addr),
+			)
+		}
+		if seen[string(addr)] {
+			return fmt.Errorf("Validator %X appears more than once in the quorum set", []byte(addr))
+		}
+		seen[string(addr)] = true
+	}
+	for _, inner := range qs.InnerSets {
+		if inner == nil {
+			return errors.New("Quorum set has a nil inner set")
+		}
+		if err := inner.validateBasic(depth+1, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Size returns the number of entries (validators and inner sets) of the quorum set.
+func (qs *QuorumSet) Size() int {
+	return len(qs.Validators) + len(qs.InnerSets)
+}
+
+// weight returns the weight of the i-th validator of the quorum set.
+func (qs *QuorumSet) weight(i int) int64 {
+	if len(qs.Weights) == 0 {
+		return 1
+	}
+	return qs.Weights[i]
+}
+
+// totalWeight returns the total weight of the entries of the quorum set.
+func (qs *QuorumSet) totalWeight() int64 {
+	total := int64(len(qs.InnerSets))
+	for i := range qs.Validators {
+		total += qs.weight(i)
+	}
+	return total
+}
+
+// Hash returns the hash of the quorum set, which identifies it in SCP statements.
+func (qs *QuorumSet) Hash() []byte {
+	return tmhash.Sum(cdc.MustMarshalBinaryBare(qs))
+}
+
+// Members returns the addresses of all the validators referenced by the
+// quorum set or any of its inner sets, without duplicates.
+func (qs *QuorumSet) Members() []Address {
+	seen := make(map[string]bool)
+	var members []Address
+	qs.iterate(func(addr Address) {
+		if !seen[string(addr)] {
+			seen[string(addr)] = true
+			members = append(members, addr)
+		}
+	})
+	return members
+}
+
+func (qs *QuorumSet) iterate(fn func(addr Address)) {
+	for _, addr := range qs.Validators {
+		fn(addr)
+	}
+	for _, inner := range qs.InnerSets {
+		inner.iterate(fn)
+	}
+}
+
+// IsSliceSatisfied returns true if nodes (keyed by string(address)) contains
+// a quorum slice of this quorum set.
+func (qs *QuorumSet) IsSliceSatisfied(nodes map[string]bool) bool {
+	if qs == nil || qs.Threshold <= 0 {
+		return false
+	}
+	count := int64(0)
+	for i, addr := range qs.Validators {
+		if nodes[string(addr)] {
+			count += qs.weight(i)
+		}
+	}
+	for _, inner := range qs.InnerSets {
+		if inner.IsSliceSatisfied(nodes) {
+			count++
+		}
+	}
+	return count >= int64(qs.Threshold)
+}
+
+// IsVBlocking returns true if nodes (keyed by string(address)) intersects
+// every quorum slice of this quorum set, ie. if the owner of the quorum set
+// can't be part of a quorum without one of the nodes.
+func (qs *QuorumSet) IsVBlocking(nodes map[string]bool) bool {
+	if qs == nil || qs.Threshold <= 0 {
+		return false
+	}
+	leftTillBlock := qs.totalWeight() - int64(qs.Threshold) + 1
+	for i, addr := range qs.Validators {
+		if nodes[string(addr)] {
+			leftTillBlock -= qs.weight(i)
+			if leftTillBlock <= 0 {
+				return true
+			}
+		}
+	}
+	for _, inner := range qs.InnerSets {
+		if inner.IsVBlocking(nodes) {
+			leftTillBlock--
+			if leftTillBlock <= 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Equals returns true if both quorum sets have the same hash.
+func (qs *QuorumSet) Equals(other *QuorumSet) bool {
+	if qs == nil || other == nil {
+		return qs == other
+	}
+	return bytes.Equal(qs.Hash(), other.Hash())
+}
+
+// String returns a string representation of the QuorumSet.
+func (qs *QuorumSet) String() string {
+	if qs == nil {
+		return "nil-QuorumSet"
+	}
+	entries := make([]string, 0, qs.Size())
+	for _, addr := range qs.Validators {
+		entries = append(entries, fmt.Sprintf("%X", []byte(addr)))
+	}
+	for _, inner := range qs.InnerSets {
+		entries = append(entries, inner.String())
+	}
+	return fmt.Sprintf("QuorumSet{%d/[%s]}", qs.Threshold, strings.Join(entries, " "))
+}
@@ -0,0 +1,141 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func quorumNodes(addrs ...Address) map[string]bool {
+	nodes := make(map[string]bool)
+	for _, addr := range addrs {
+		nodes[string(addr)] = true
+	}
+	return nodes
+}
+
+func TestQuorumSetSliceSatisfied(t *testing.T) {
+	valSet, _ := RandValidatorSet(4, 1)
+	qs := NewMajorityQuorumSet(valSet)
+	assert.Equal(t, 3, qs.Threshold)
+
+	addrs := qs.Validators
+	assert.False(t, qs.IsSliceSatisfied(quorumNodes(addrs[0], addrs[1])))
+	assert.True(t, qs.IsSliceSatisfied(quorumNodes(addrs[0], addrs[1], addrs[2])))
+	assert.True(t, qs.IsSliceSatisfied(quorumNodes(addrs...)))
+
+	// nested: 2 of {a0, {1 of a1, a2}, a3}
+	nested := &QuorumSet{
+		Threshold:  2,
+		Validators: []Address{addrs[0], addrs[3]},
+		InnerSets:  []*QuorumSet{{Threshold: 1, Validators: []Address{addrs[1], addrs[2]}}},
+	}
+	assert.True(t, nested.IsSliceSatisfied(quorumNodes(addrs[0], addrs[2])))
+	assert.False(t, nested.IsSliceSatisfied(quorumNodes(addrs[1], addrs[2])))
+	assert.Len(t, nested.Members(), 4)
+
+	// weighted by the voting power: a0 holds more than 1/3 of it
+	vals := valSet.Copy().Validators
+	vals[0].VotingPower = 3
+	weighted := NewMajorityQuorumSet(NewValidatorSet(vals))
+	assert.Equal(t, 5, weighted.Threshold)
+	assert.NoError(t, weighted.ValidateBasic())
+	addrs = weighted.Validators
+	var a0 Address
+	for i, addr := range addrs {
+		if weighted.Weights[i] == 3 {
+			a0 = addr
+		}
+	}
+	others := quorumNodes(addrs...)
+	delete(others, string(a0))
+	assert.False(t, weighted.IsSliceSatisfied(others))
+	assert.True(t, weighted.IsVBlocking(quorumNodes(a0)))
+}
+
+func TestQuorumSetVBlocking(t *testing.T) {
+	valSet, _ := RandValidatorSet(4, 1)
+	qs := NewMajorityQuorumSet(valSet)
+
+	addrs := qs.Validators
+	// with a threshold of 3 out of 4, any 2 nodes intersect every slice
+	assert.False(t, qs.IsVBlocking(quorumNodes()))
+	assert.False(t, qs.IsVBlocking(quorumNodes(addrs[0])))
+	assert.True(t, qs.IsVBlocking(quorumNodes(addrs[0], addrs[1])))
+
+	empty := &QuorumSet{}
+	assert.False(t, empty.IsVBlocking(quorumNodes(addrs...)))
+	assert.False(t, empty.IsSliceSatisfied(quorumNodes(addrs...)))
+
+	// a nil inner set is never satisfied
+	withNil := &QuorumSet{Threshold: 1, InnerSets: []*QuorumSet{nil}}
+	assert.NotPanics(t, func() {
+		assert.False(t, withNil.IsVBlocking(quorumNodes(addrs...)))
+		assert.False(t, withNil.IsSliceSatisfied(quorumNodes(addrs...)))
+	})
+}
+
+func TestQuorumSetHash(t *testing.T) {
+	valSet, _ := RandValidatorSet(3, 1)
+	qs1 := NewMajorityQuorumSet(valSet)
+	qs2 := NewMajorityQuorumSet(valSet)
+	assert.True(t, qs1.Equals(qs2))
+
+	qs2.Threshold = 1
+	assert.False(t, qs1.Equals(qs2))
+}
+
+func TestQuorumSetValidateBasic(t *testing.T) {
+	valSet, _ := RandValidatorSet(3, 1)
+	qs := NewMajorityQuorumSet(valSet)
+	assert.NoError(t, qs.ValidateBasic())
+	addrs := qs.Validators
+
+	testCases := []struct {
+		name string
+		qs   *QuorumSet
+	}{
+		{"empty", &QuorumSet{Threshold: 1}},
+		{"zero threshold", &QuorumSet{Threshold: 0, Validators: addrs}},
+		{"threshold too high", &QuorumSet{Threshold: 4, Validators: addrs}},
+		{"bad address", &QuorumSet{Threshold: 1, Validators: []Address{{0x01}}}},
+		{"duplicate", &QuorumSet{Threshold: 1, Validators: []Address{addrs[0], addrs[0]}}},
+		{"duplicate in inner set", &QuorumSet{
+			Threshold:  1,
+			Validators: []Address{addrs[0]},
+			InnerSets:  []*QuorumSet{{Threshold: 1, Validators: []Address{addrs[0]}}},
+		}},
+		{"nil inner set", &QuorumSet{Threshold: 1, InnerSets: []*QuorumSet{nil}}},
+		{"missing weights", &QuorumSet{Threshold: 1, Validators: addrs, Weights: []int64{1}}},
+		{"zero weight", &QuorumSet{Threshold: 1, Validators: addrs[:1], Weights: []int64{0}}},
+		{"threshold over weights", &QuorumSet{Threshold: 4, Validators: addrs[:1], Weights: []int64{3}}},
+	}
+	for _, tc := range testCases {
+		assert.Error(t, tc.qs.ValidateBasic(), tc.name)
+	}
+
+	// too deep
+	deep := &QuorumSet{Threshold: 1, Validators: []Address{addrs[0]}}
+	for i := 0; i <= MaxQuorumSetDepth; i++ {
+		deep = &QuorumSet{Threshold: 1, InnerSets: []*QuorumSet{deep}}
+	}
+	assert.Error(t, deep.ValidateBasic())
+}
+
+func TestSCPStatementValidateBasicQuorumSet(t *testing.T) {
+	valSet, _ := RandValidatorSet(3, 1)
+	qs := NewMajorityQuorumSet(valSet)
+	stmt := &SCPStatement{
+		Type:             SCPNominateType,
+		ValidatorAddress: qs.Validators[0],
+		QuorumSet:        qs,
+		Signature:        []byte{0x01},
+	}
+	assert.NoError(t, stmt.ValidateBasic())
+
+	stmt.QuorumSet = &QuorumSet{Threshold: 1, InnerSets: []*QuorumSet{nil}}
+	assert.Error(t, stmt.ValidateBasic())
+
+	stmt.QuorumSet = &QuorumSet{Threshold: 4, Validators: qs.Validators}
+	assert.Error(t, stmt.ValidateBasic())
+}
@@ -0,0 +1,214 @@
+package types
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	cmn "github.com/teragrid/dgrid/pkg/common"
+	"github.com/teragrid/dgrid/pkg/crypto"
+)
+
+var (
+	ErrSCPStatementInvalidType      = errors.New("Invalid SCP statement type")
+	ErrSCPStatementInvalidSignature = errors.New("Invalid SCP statement signature")
+	ErrSCPStatementInvalidAddress   = errors.New("Invalid SCP statement validator address")
+)
+
+// SCPStatementType is the type of a statement of the federated (SCP) consensus.
+type SCPStatementType byte
+
+const (
+	// Nomination protocol
+	SCPNominateType SCPStatementType = 0x01
+
+	// Ballot protocol
+	SCPPrepareType     SCPStatementType = 0x02
+	SCPConfirmType     SCPStatementType = 0x03
+	SCPExternalizeType SCPStatementType = 0x04
+)
+
+// String returns a string representation of the statement type.
+func (t SCPStatementType) String() string {
+	switch t {
+	case SCPNominateType:
+		return "Nominate"
+	case SCPPrepareType:
+		return "Prepare"
+	case SCPConfirmType:
+		return "Confirm"
+	case SCPExternalizeType:
+		return "Externalize"
+	default:
+		return "Unknown"
+	}
+}
+
+// SCPBallot is a ballot of the SCP ballot protocol: a counter together with
+// the value (block) the ballot is trying to commit.
+type SCPBallot struct {
+	Counter int     `json:"counter"`
+	Value   BlockID `json:"value"`
+}
+
+// Compare returns -1, 0 or 1 if the ballot is less than, equal to or greater
+// than the other ballot. Ballots are ordered by counter, then by value hash.
+func (b *SCPBallot) Compare(other *SCPBallot) int {
+	switch {
+	case b.Counter < other.Counter:
+		return -1
+	case b.Counter > other.Counter:
+		return 1
+	}
+	return bytes.Compare(b.Value.Hash, other.Value.Hash)
+}
+
+// Compatible returns true if both ballots are for the same value.
+func (b *SCPBallot) Compatible(other *SCPBallot) bool {
+	return b.Value.Equals(other.Value)
+}
+
+// LessAndCompatible returns true if b <= other and both are for the same value.
+func (b *SCPBallot) LessAndCompatible(other *SCPBallot) bool {
+	return b.Compare(other) <= 0 && b.Compatible(other)
+}
+
+// LessAndIncompatible returns true if b <= other and they are for different values.
+func (b *SCPBallot) LessAndIncompatible(other *SCPBallot) bool {
+	return b.Compare(other) <= 0 && !b.Compatible(other)
+}
+
+// Copy returns a copy of the ballot, or nil if b is nil.
+func (b *SCPBallot) Copy() *SCPBallot {
+	if b == nil {
+		return nil
+	}
+	ballotCopy := *b
+	return &ballotCopy
+}
+
+// String returns a string representation of the SCPBallot.
+func (b *SCPBallot) String() string {
+	if b == nil {
+		return "nil-Ballot"
+	}
+	return fmt.Sprintf("(%d,%X)", b.Counter, cmn.Fingerprint(b.Value.Hash))
+}
+
+// SCPStatement is a signed statement of a validator about a height (slot)
+// in the federated (SCP) consensus. Which fields are meaningful depends on
+// the Type:
+//
+//	Nominate:    Votes, Accepted
+//	Prepare:     Ballot (b), Prepared (p), PreparedPrime (p'), NCommit (c.n), NHigh (h.n)
+//	Confirm:     Ballot (b), NPrepared (p.n), NCommit (c.n), NHigh (h.n)
+//	Externalize: Ballot (c), NHigh (h.n)
+//
+// Every statement carries the quorum set of its sender, so that peers can
+// evaluate quorums without an extra round-trip.
+type SCPStatement struct {
+	Type             SCPStatementType `json:"type"`
+	Height           int64            `json:"height"`
+	ValidatorAddress Address          `json:"validator_address"`
+	QuorumSet        *QuorumSet       `json:"quorum_set"`
+
+	Votes    []BlockID `json:"votes,omitempty"`
+	Accepted []BlockID `json:"accepted,omitempty"`
+
+	Ballot        *SCPBallot `json:"ballot,omitempty"`
+	Prepared      *SCPBallot `json:"prepared,omitempty"`
+	PreparedPrime *SCPBallot `json:"prepared_prime,omitempty"`
+	NPrepared     int        `json:"n_prepared"`
+	NCommit       int        `json:"n_commit"`
+	NHigh         int        `json:"n_high"`
+
+	Signature []byte `json:"signature"`
+}
+
+// ValidateBasic performs basic validation.
+func (stmt *SCPStatement) ValidateBasic() error {
+	if stmt.Height < 0 {
+		return errors.New("Negative Height")
+	}
+	if len(stmt.ValidatorAddress) != crypto.AddressSize {
+		return fmt.Errorf("Expected ValidatorAddress size to be %d bytes, got %d bytes",
+			crypto.AddressSize,
+			len(stmt.ValidatorAddress),
+		)
+	}
+	if stmt.QuorumSet == nil {
+		return errors.New("QuorumSet is missing")
+	}
+	if err := stmt.QuorumSet.ValidateBasic(); err != nil {
+		return fmt.Errorf("Wrong QuorumSet: %v", err)
+	}
+	switch stmt.Type {
+	case SCPNominateType:
+	case SCPPrepareType, SCPConfirmType, SCPExternalizeType:
+		if stmt.Ballot == nil {
+			return errors.New("Ballot is missing")
+		}
+		if stmt.Ballot.Counter < 0 || stmt.NPrepared < 0 || stmt.NCommit < 0 || stmt.NHigh < 0 {
+			return errors.New("Negative ballot counter")
+		}
+		if stmt.NCommit > stmt.NHigh {
+			return errors.New("NCommit is greater than NHigh")
+		}
+	default:
+		return ErrSCPStatementInvalidType
+	}
+	if len(stmt.Signature) == 0 {
+		return errors.New("Signature is missing")
+	}
+	if len(stmt.Signature) > MaxThresholdSignatureSize {
+		return fmt.Errorf("Signature is too big (max: %d)", MaxThresholdSignatureSize)
+	}
+	return nil
+}
+
+// SignBytes returns the SCPStatement bytes for signing.
+func (stmt *SCPStatement) SignBytes(leagueID string) []byte {
+	bz, err := cdc.MarshalBinaryLengthPrefixed(CanonicalizeSCPStatement(leagueID, stmt))
+	if err != nil {
+		panic(err)
+	}
+	return bz
+}
+
+// Verify checks the statement was signed by the given public key.
+func (stmt *SCPStatement) Verify(leagueID string, pubKey crypto.PubKey) error {
+	if !bytes.Equal(pubKey.Address(), stmt.ValidatorAddress) {
+		return ErrSCPStatementInvalidAddress
+	}
+	if !pubKey.VerifyBytes(stmt.SignBytes(leagueID), stmt.Signature) {
+		return ErrSCPStatementInvalidSignature
+	}
+	return nil
+}
+
+// String returns a string representation of the SCPStatement.
+func (stmt *SCPStatement) String() string {
+	if stmt == nil {
+		return "nil-SCPStatement"
+	}
+	var details string
+	switch stmt.Type {
+	case SCPNominateType:
+		details = fmt.Sprintf("votes:%d accepted:%d", len(stmt.Votes), len(stmt.Accepted))
+	case SCPPrepareType:
+		details = fmt.Sprintf("b:%v p:%v p':%v c.n:%d h.n:%d",
+			stmt.Ballot, stmt.Prepared, stmt.PreparedPrime, stmt.NCommit, stmt.NHigh)
+	case SCPConfirmType:
+		details = fmt.Sprintf("b:%v p.n:%d c.n:%d h.n:%d",
+			stmt.Ballot, stmt.NPrepared, stmt.NCommit, stmt.NHigh)
+	case SCPExternalizeType:
+		details = fmt.Sprintf("c:%v h.n:%d", stmt.Ballot, stmt.NHigh)
+	}
+	return fmt.Sprintf("SCPStatement{%X %v/%v %s %X}",
+		cmn.Fingerprint(stmt.ValidatorAddress),
+		stmt.Height,
+		stmt.Type,
+		details,
+		cmn.Fingerprint(stmt.Signature),
+	)
+}
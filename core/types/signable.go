@@ -1,8 +1,22 @@
 package types
 
 import (
-	"github.com/teragrid/dgrid/pkg/crypto/ed25519"
 	cmn "github.com/teragrid/dgrid/pkg/common"
+	"github.com/teragrid/dgrid/pkg/crypto"
+	"github.com/teragrid/dgrid/pkg/crypto/ed25519"
+	"github.com/teragrid/dgrid/pkg/crypto/multisig"
+)
+
+const (
+	// MaxThresholdSigners is the maximum number of co-signers of the key of
+	// a threshold validator, see ValidateThresholdPubKey.
+	MaxThresholdSigners = 5
+
+	// MaxThresholdSignatureSize is a maximum allowed signature size for the
+	// Proposal and Vote of a threshold validator: the amino encoded
+	// multisig.Multisignature of MaxThresholdSigners ed25519 signatures,
+	// 7 bytes of bit array, and 66 bytes per signature.
+	MaxThresholdSignatureSize = 7 + MaxThresholdSigners*(2+ed25519.SignatureSize)
 )
 
 var (
@@ -12,6 +26,16 @@ var (
 	MaxSignatureSize = cmn.MaxInt(ed25519.SignatureSize, 64)
 )
 
+// MaxSignatureSizeOf returns the maximum allowed signature size of the
+// validator with the given key: MaxThresholdSignatureSize for a threshold
+// validator, MaxSignatureSize otherwise.
+func MaxSignatureSizeOf(pubKey crypto.PubKey) int {
+	if _, ok := pubKey.(multisig.PubKeyMultisigThreshold); ok {
+		return MaxThresholdSignatureSize
+	}
+	return MaxSignatureSize
+}
+
 // Signable is an interface for all signable things.
 // It typically removes signatures before serializing.
 // SignBytes returns the bytes to be signed
@@ -10,6 +10,12 @@ const (
 
 	// Proposals
 	ProposalType SignedMsgType = 0x20
+
+	// Statements of the federated (SCP) consensus
+	SCPStatementMsgType SignedMsgType = 0x30
+
+	// Approvals of league configuration updates
+	ConfigVoteMsgType SignedMsgType = 0x40
 )
 
 // IsVoteTypeValid returns true if t is a valid vote type.
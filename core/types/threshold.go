@@ -0,0 +1,79 @@
+package types
+
+import (
+	"fmt"
+
+	"github.com/teragrid/dgrid/pkg/crypto"
+	"github.com/teragrid/dgrid/pkg/crypto/ed25519"
+	"github.com/teragrid/dgrid/pkg/crypto/multisig"
+)
+
+// ValidateValidatorPubKey returns an error if pubKey can't be the key of a
+// validator. The key of a threshold validator, a k of n
+// multisig.PubKeyMultisigThreshold, is checked with
+// ValidateThresholdPubKey.
+func ValidateValidatorPubKey(pubKey crypto.PubKey) error {
+	if pubKey == nil {
+		return fmt.Errorf("Missing validator public key")
+	}
+	if thresholdKey, ok := pubKey.(multisig.PubKeyMultisigThreshold); ok {
+		return ValidateThresholdPubKey(thresholdKey)
+	}
+	return nil
+}
+
+// ValidateThresholdPubKey returns an error if the key of a threshold
+// validator has more than MaxThresholdSigners co-signers, or co-signers with
+// keys other than ed25519, since its signatures must fit
+// MaxThresholdSignatureSize.
+// A vote of a threshold validator is only valid with the signatures of k of
+// its n co-signers.
+func ValidateThresholdPubKey(pubKey multisig.PubKeyMultisigThreshold) error {
+	n := len(pubKey.PubKeys)
+	if pubKey.K == 0 || int(pubKey.K) > n {
+		return fmt.Errorf("Invalid threshold %d of %d co-signers", pubKey.K, n)
+	}
+	if n > MaxThresholdSigners {
+		return fmt.Errorf("Too many co-signers: %d (max: %d)", n, MaxThresholdSigners)
+	}
+	for i, key := range pubKey.PubKeys {
+		if _, ok := key.(ed25519.PubKeyEd25519); !ok {
+			return fmt.Errorf("Co-signer %d must have an ed25519 key, got %T", i, key)
+		}
+	}
+	return nil
+}
+
+// HasThresholdValidators returns true if some validators of vals are
+// threshold validators.
+func (vals *ValidatorSet) HasThresholdValidators() bool {
+	if vals == nil {
+		return false
+	}
+	for _, val := range vals.Validators {
+		if _, ok := val.PubKey.(multisig.PubKeyMultisigThreshold); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxVoteBytesOf returns the maximum vote size of the validators vals:
+// MaxThresholdVoteBytes if some are threshold validators, MaxVoteBytes
+// otherwise. The larger votes only count against the blocks of the leagues
+// with threshold validators.
+func MaxVoteBytesOf(vals *ValidatorSet) int64 {
+	if vals.HasThresholdValidators() {
+		return MaxThresholdVoteBytes
+	}
+	return MaxVoteBytes
+}
+
+// MaxEvidenceBytesOf returns the maximum evidence size of the validators
+// vals, like MaxVoteBytesOf.
+func MaxEvidenceBytesOf(vals *ValidatorSet) int64 {
+	if vals.HasThresholdValidators() {
+		return MaxThresholdEvidenceBytes
+	}
+	return MaxEvidenceBytes
+}
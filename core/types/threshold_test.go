@@ -0,0 +1,82 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teragrid/dgrid/pkg/crypto"
+	"github.com/teragrid/dgrid/pkg/crypto/ed25519"
+	"github.com/teragrid/dgrid/pkg/crypto/multisig"
+	"github.com/teragrid/dgrid/pkg/crypto/secp256k1"
+)
+
+func thresholdPubKeys(n int) []crypto.PubKey {
+	pubKeys := make([]crypto.PubKey, n)
+	for i := range pubKeys {
+		pubKeys[i] = ed25519.GenPrivKey().PubKey()
+	}
+	return pubKeys
+}
+
+func TestValidateValidatorPubKey(t *testing.T) {
+	pubKeys := thresholdPubKeys(MaxThresholdSigners + 1)
+	secpKeys := append([]crypto.PubKey{secp256k1.GenPrivKey().PubKey()}, pubKeys[1:3]...)
+
+	testCases := []struct {
+		pubKey crypto.PubKey
+		valid  bool
+	}{
+		0: {nil, false},
+		1: {pubKeys[0], true},
+		2: {secp256k1.GenPrivKey().PubKey(), true},
+		3: {multisig.NewPubKeyMultisigThreshold(2, pubKeys[:3]), true},
+		4: {multisig.NewPubKeyMultisigThreshold(MaxThresholdSigners, pubKeys[:MaxThresholdSigners]), true},
+		5: {multisig.NewPubKeyMultisigThreshold(2, pubKeys), false},
+		6: {multisig.NewPubKeyMultisigThreshold(2, secpKeys), false},
+		7: {multisig.PubKeyMultisigThreshold{K: 4, PubKeys: pubKeys[:3]}, false},
+		8: {multisig.PubKeyMultisigThreshold{K: 0, PubKeys: pubKeys[:3]}, false},
+	}
+	for i, tc := range testCases {
+		err := ValidateValidatorPubKey(tc.pubKey)
+		if tc.valid {
+			assert.NoError(t, err, "#%v", i)
+		} else {
+			assert.Error(t, err, "#%v", i)
+		}
+	}
+}
+
+func TestThresholdPubKeyProto(t *testing.T) {
+	pubKey := multisig.NewPubKeyMultisigThreshold(2, thresholdPubKeys(3))
+	asuraPubKey := TM2PB.PubKey(pubKey)
+	assert.Equal(t, AsuraPubKeyTypeMultisigThreshold, asuraPubKey.Type)
+
+	pk, err := PB2TM.PubKey(asuraPubKey)
+	require.NoError(t, err)
+	assert.Equal(t, pubKey, pk)
+
+	tooMany := multisig.NewPubKeyMultisigThreshold(2, thresholdPubKeys(MaxThresholdSigners+1))
+	_, err = PB2TM.PubKey(TM2PB.PubKey(tooMany))
+	assert.Error(t, err)
+}
+
+func TestMaxBytesOfThresholdValidators(t *testing.T) {
+	val := NewValidator(ed25519.GenPrivKey().PubKey(), 10)
+	vals := NewValidatorSet([]*Validator{val})
+	assert.False(t, vals.HasThresholdValidators())
+	assert.Equal(t, MaxVoteBytes, MaxVoteBytesOf(vals))
+	assert.Equal(t, MaxEvidenceBytes, MaxEvidenceBytesOf(vals))
+	assert.Equal(t, MaxDataBytes(10000, 1, 1), MaxDataBytesOf(10000, vals, 1))
+	assert.Equal(t, MaxSignatureSize, MaxSignatureSizeOf(val.PubKey))
+
+	// only the leagues with threshold validators have the larger sizes
+	thresholdKey := multisig.NewPubKeyMultisigThreshold(2, thresholdPubKeys(3))
+	thresholdVals := NewValidatorSet([]*Validator{val, NewValidator(thresholdKey, 10)})
+	assert.True(t, thresholdVals.HasThresholdValidators())
+	assert.Equal(t, MaxThresholdVoteBytes, MaxVoteBytesOf(thresholdVals))
+	assert.Equal(t, MaxThresholdEvidenceBytes, MaxEvidenceBytesOf(thresholdVals))
+	assert.Equal(t, MaxDataBytes(10000, 0, 0)-2*MaxThresholdVoteBytes-MaxThresholdEvidenceBytes,
+		MaxDataBytesOf(10000, thresholdVals, 1))
+	assert.Equal(t, MaxThresholdSignatureSize, MaxSignatureSizeOf(thresholdKey))
+}
@@ -2,12 +2,34 @@ package time
 
 import (
 	"sort"
+	"sync/atomic"
 	"time"
 )
 
+// clockFunc returns the current time.
+type clockFunc func() time.Time
+
+// clock holds the clockFunc of Now. It is replaced by the virtual clock of
+// the simulations of the tests, see SetClock.
+var clock atomic.Value
+
+func init() {
+	clock.Store(clockFunc(time.Now))
+}
+
 // Now returns the current time in UTC with no monotonic component.
 func Now() time.Time {
-	return Canonical(time.Now())
+	return Canonical(clock.Load().(clockFunc)())
+}
+
+// SetClock makes Now read the time from the given clock, eg. a virtual one
+// driven by a simulation, and returns a function restoring the previous
+// clock. It is only meant for tests: the clock is shared by all the leagues
+// of the process.
+func SetClock(now func() time.Time) (restore func()) {
+	previous := clock.Load().(clockFunc)
+	clock.Store(clockFunc(now))
+	return func() { clock.Store(previous) }
 }
 
 // Canonical returns UTC time with no monotonic component.
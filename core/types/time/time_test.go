@@ -54,3 +54,26 @@ func TestWeightedMedian(t *testing.T) {
 	assert.Equal(t, true, (median.After(t1) || median.Equal(t1)) &&
 		(median.Before(t4) || median.Equal(t4)))
 }
+
+func TestSetClock(t *testing.T) {
+	virtual := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	restore := SetClock(func() time.Time { return virtual })
+	assert.Equal(t, virtual, Now())
+	restore()
+	assert.NotEqual(t, virtual, Now())
+}
+
+func TestSetClockConcurrently(t *testing.T) {
+	virtual := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			Now()
+		}
+	}()
+	restore := SetClock(func() time.Time { return virtual })
+	defer restore()
+	<-done
+	assert.Equal(t, virtual, Now())
+}
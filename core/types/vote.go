@@ -13,6 +13,10 @@ import (
 const (
 	// MaxVoteBytes is a maximum vote size (including amino overhead).
 	MaxVoteBytes int64 = 223
+
+	// MaxThresholdVoteBytes is a maximum vote size of a threshold validator,
+	// with a signature of MaxThresholdSignatureSize.
+	MaxThresholdVoteBytes int64 = 497
 )
 
 var (
@@ -114,6 +118,9 @@ func (vote *Vote) Verify(leagueID string, pubKey crypto.PubKey) error {
 		return ErrVoteInvalidValidatorAddress
 	}
 
+	if len(vote.Signature) > MaxSignatureSizeOf(pubKey) {
+		return ErrVoteInvalidSignature
+	}
 	if !pubKey.VerifyBytes(vote.SignBytes(leagueID), vote.Signature) {
 		return ErrVoteInvalidSignature
 	}
@@ -154,8 +161,8 @@ func (vote *Vote) ValidateBasic() error {
 	if len(vote.Signature) == 0 {
 		return errors.New("Signature is missing")
 	}
-	if len(vote.Signature) > MaxSignatureSize {
-		return fmt.Errorf("Signature is too big (max: %d)", MaxSignatureSize)
+	if len(vote.Signature) > MaxThresholdSignatureSize {
+		return fmt.Errorf("Signature is too big (max: %d)", MaxThresholdSignatureSize)
 	}
 	return nil
 }
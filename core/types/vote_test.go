@@ -250,6 +250,13 @@ func TestMaxVoteBytes(t *testing.T) {
 	require.NoError(t, err)
 
 	assert.EqualValues(t, MaxVoteBytes, len(bz))
+
+	// the signature of a threshold validator is longer
+	vote.Signature = make([]byte, MaxThresholdSignatureSize)
+	bz, err = cdc.MarshalBinaryLengthPrefixed(vote)
+	require.NoError(t, err)
+
+	assert.EqualValues(t, MaxThresholdVoteBytes, len(bz))
 }
 
 func TestVoteString(t *testing.T) {
@@ -281,7 +288,7 @@ func TestVoteValidateBasic(t *testing.T) {
 		{"Invalid Address", func(v *Vote) { v.ValidatorAddress = make([]byte, 1) }, true},
 		{"Invalid ValidatorIndex", func(v *Vote) { v.ValidatorIndex = -1 }, true},
 		{"Invalid Signature", func(v *Vote) { v.Signature = nil }, true},
-		{"Too big Signature", func(v *Vote) { v.Signature = make([]byte, MaxSignatureSize+1) }, true},
+		{"Too big Signature", func(v *Vote) { v.Signature = make([]byte, MaxThresholdSignatureSize+1) }, true},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.testName, func(t *testing.T) {
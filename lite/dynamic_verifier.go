@@ -34,6 +34,9 @@ type DynamicVerifier struct {
 	// pending map to synchronize concurrent verification requests
 	mtx                  sync.Mutex
 	pendingVerifications map[int64]chan struct{}
+
+	// how the league selects its proposers, see SetProposerParams
+	proposerParams types.ProposerParams
 }
 
 // NewDynamicVerifier returns a new DynamicVerifier. It uses the
@@ -59,6 +62,14 @@ func (dv *DynamicVerifier) SetLogger(logger log.Logger) {
 	dv.source.SetLogger(logger)
 }
 
+// SetProposerParams sets how the league selects its proposers. The headers
+// only hold the hash of the consensus params. If the proposers are selected
+// at random, the proposer of every verified header is checked too, see
+// VerifyProposer.
+func (dv *DynamicVerifier) SetProposerParams(params types.ProposerParams) {
+	dv.proposerParams = params
+}
+
 // Implements Verifier.
 func (dv *DynamicVerifier) ChainID() string {
 	return dv.chainID
@@ -151,6 +162,11 @@ func (dv *DynamicVerifier) Verify(shdr types.SignedHeader) error {
 	if err != nil {
 		return err
 	}
+	if dv.proposerParams.Random() {
+		if err := dv.verifyProposer(shdr, trustedFC); err != nil {
+			return err
+		}
+	}
 
 	// By now, the SignedHeader is fully validated and we're synced up to
 	// SignedHeader.Height - 1. To sync to SignedHeader.Height, we need
@@ -182,6 +198,25 @@ func (dv *DynamicVerifier) Verify(shdr types.SignedHeader) error {
 	return dv.trusted.SaveFullCommit(nfc)
 }
 
+// verifyProposer checks the proposer of shdr, selected at random from the
+// header of the height before. That header is the one of trustedFC, or is
+// fetched from the source, and checked against the LastBlockID of shdr.
+func (dv *DynamicVerifier) verifyProposer(shdr types.SignedHeader, trustedFC FullCommit) error {
+	var lastHeader *types.Header
+	if prevHeight := shdr.Height - 1; prevHeight > 0 {
+		lastFC := trustedFC
+		if trustedFC.Height() != prevHeight {
+			var err error
+			lastFC, err = dv.source.LatestFullCommit(dv.chainID, prevHeight, prevHeight)
+			if err != nil {
+				return err
+			}
+		}
+		lastHeader = lastFC.SignedHeader.Header
+	}
+	return VerifyProposer(shdr, lastHeader, trustedFC.NextValidators)
+}
+
 // verifyAndSave will verify if this is a valid source full commit given the
 // best match trusted full commit, and if good, persist to dv.trusted.
 // Returns ErrTooMuchChange when >2/3 of trustedFC did not sign sourceFC.
@@ -0,0 +1,36 @@
+package lite
+
+import (
+	"bytes"
+
+	"github.com/teragrid/dgrid/core/types"
+	cmn "github.com/teragrid/dgrid/pkg/common"
+)
+
+// VerifyProposer checks the proposer of a signed header of a league which
+// selects its proposers at random, see types.ProposerParams. lastHeader is
+// the header of the height before, nil for the first height: the seed of
+// the selection is its LastCommitHash. valset are the validators which
+// signed the header.
+func VerifyProposer(signedHeader types.SignedHeader, lastHeader *types.Header, valset *types.ValidatorSet) error {
+	lastBlockHash := signedHeader.LastBlockID.Hash
+	if (signedHeader.Height == 1) != (len(lastBlockHash) == 0) {
+		return cmn.NewError("Header %v/%d has a wrong last block hash %X",
+			signedHeader.LeagueID, signedHeader.Height, lastBlockHash)
+	}
+
+	var lastCommitHash []byte
+	if signedHeader.Height > 1 {
+		if lastHeader == nil || !bytes.Equal(lastHeader.Hash(), lastBlockHash) {
+			return cmn.NewError("Header %v/%d doesn't follow the given last header",
+				signedHeader.LeagueID, signedHeader.Height)
+		}
+		lastCommitHash = lastHeader.LastCommitHash
+	}
+	err := valset.VerifyRandomProposer(lastCommitHash, signedHeader.Height,
+		signedHeader.Commit.Round(), signedHeader.ProposerAddress)
+	if err != nil {
+		return cmn.ErrorWrap(err, "in verify proposer")
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package lite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teragrid/dgrid/core/types"
+	dbm "github.com/teragrid/dgrid/pkg/db"
+	log "github.com/teragrid/dgrid/pkg/log"
+)
+
+// genRandomProposerHeaders returns the signed headers of the heights 1 to n,
+// proposed in round 0 by the random proposer selection of vals.
+func genRandomProposerHeaders(keys privKeys, vals *types.ValidatorSet, chainID string, n int) []types.SignedHeader {
+	shdrs := make([]types.SignedHeader, n)
+	for i := range shdrs {
+		height := int64(i + 1)
+		header := genHeader(chainID, height, nil, vals, vals, nil, []byte("params"), nil)
+		var lastCommitHash []byte
+		if i > 0 {
+			last := shdrs[i-1]
+			header.LastBlockID = last.Commit.BlockID
+			header.LastCommitHash = last.Commit.Hash()
+			lastCommitHash = last.Header.LastCommitHash
+		}
+		header.ProposerAddress = vals.RandomProposer(lastCommitHash, height, 0).Address
+		shdrs[i] = types.SignedHeader{Header: header, Commit: keys.signHeader(header, 0, len(keys))}
+	}
+	return shdrs
+}
+
+// withUnselectedProposer returns shdr signed again, with a proposer which
+// wasn't selected up to the round of its commit.
+func withUnselectedProposer(t *testing.T, keys privKeys, vals *types.ValidatorSet, shdr types.SignedHeader, lastCommitHash []byte) types.SignedHeader {
+	header := *shdr.Header
+	for _, val := range vals.Validators {
+		if vals.VerifyRandomProposer(lastCommitHash, header.Height, shdr.Commit.Round(), val.Address) != nil {
+			header.ProposerAddress = val.Address
+			return types.SignedHeader{Header: &header, Commit: keys.signHeader(&header, 0, len(keys))}
+		}
+	}
+	require.FailNow(t, "all the validators were selected")
+	return shdr
+}
+
+func TestVerifyProposer(t *testing.T) {
+	keys := genPrivKeys(4)
+	vals := keys.ToValidators(10, 0)
+	shdrs := genRandomProposerHeaders(keys, vals, "proposer-test", 3)
+
+	var lastHeader *types.Header
+	for _, shdr := range shdrs {
+		assert.NoError(t, VerifyProposer(shdr, lastHeader, vals))
+		lastHeader = shdr.Header
+	}
+
+	// the seed is taken from the header of the height before only
+	assert.Error(t, VerifyProposer(shdrs[2], nil, vals))
+	assert.Error(t, VerifyProposer(shdrs[2], shdrs[0].Header, vals))
+
+	// a proposer which wasn't selected is rejected
+	bad := withUnselectedProposer(t, keys, vals, shdrs[2], shdrs[1].LastCommitHash)
+	assert.Error(t, VerifyProposer(bad, shdrs[1].Header, vals))
+}
+
+func TestDynamicVerifyRandomProposer(t *testing.T) {
+	chainID := "proposer-test"
+	keys := genPrivKeys(4)
+	vals := keys.ToValidators(10, 0)
+	shdrs := genRandomProposerHeaders(keys, vals, chainID, 4)
+
+	trust := NewDBProvider("trust", dbm.NewMemDB())
+	source := NewDBProvider("source", dbm.NewMemDB())
+	for _, shdr := range shdrs {
+		require.NoError(t, source.SaveFullCommit(NewFullCommit(shdr, vals, vals)))
+	}
+	require.NoError(t, trust.SaveFullCommit(NewFullCommit(shdrs[0], vals, vals)))
+	ver := NewDynamicVerifier(chainID, trust, source)
+	ver.SetLogger(log.TestingLogger())
+	ver.SetProposerParams(types.ProposerParams{Selection: types.ProposerSelectionRandom})
+
+	// the header of the height before is fetched from the source
+	bad := withUnselectedProposer(t, keys, vals, shdrs[2], shdrs[1].LastCommitHash)
+	assert.Error(t, ver.Verify(bad))
+	assert.NoError(t, ver.Verify(shdrs[2]))
+
+	// or taken from the trusted ones
+	bad = withUnselectedProposer(t, keys, vals, shdrs[3], shdrs[2].LastCommitHash)
+	assert.Error(t, ver.Verify(bad))
+	assert.NoError(t, ver.Verify(shdrs[3]))
+
+	// the proposers aren't checked with the priority selection
+	trust = NewDBProvider("trust", dbm.NewMemDB())
+	require.NoError(t, trust.SaveFullCommit(NewFullCommit(shdrs[0], vals, vals)))
+	ver = NewDynamicVerifier(chainID, trust, source)
+	ver.SetLogger(log.TestingLogger())
+	assert.NoError(t, ver.Verify(bad))
+}
@@ -218,6 +218,13 @@ func (g *Group) Buffered() int {
 	return g.headBuf.Buffered()
 }
 
+// Flush writes any buffered data to the underlying file.
+func (g *Group) Flush() error {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	return g.headBuf.Flush()
+}
+
 // FlushAndSync writes any buffered data to the underlying file and commits the
 // current content of the file to stable storage (fsync).
 func (g *Group) FlushAndSync() error {
@@ -320,6 +327,21 @@ func (g *Group) RotateFile() {
 	g.maxIndex++
 }
 
+// RemoveFilesBefore removes the files of the group before index. The head
+// is never removed.
+func (g *Group) RemoveFilesBefore(index int) error {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	for ; g.minIndex < index && g.minIndex < g.maxIndex; g.minIndex++ {
+		err := os.Remove(filePathForIndex(g.Head.Path, g.minIndex, g.maxIndex))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
 // NewReader returns a new group reader.
 // CONTRACT: Caller must close the returned GroupReader.
 func (g *Group) NewReader(index int) (*GroupReader, error) {
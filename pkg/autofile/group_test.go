@@ -236,3 +236,29 @@ func TestMaxIndex(t *testing.T) {
 	// Cleanup
 	destroyTestGroup(t, g)
 }
+
+func TestRemoveFilesBefore(t *testing.T) {
+	g := createTestGroupWithHeadSizeLimit(t, 0)
+
+	for i := 0; i < 3; i++ {
+		g.WriteLine("Line")
+		g.FlushAndSync()
+		g.RotateFile()
+	}
+	assert.Equal(t, 3, g.MaxIndex())
+
+	require.NoError(t, g.RemoveFilesBefore(2))
+	assert.Equal(t, 2, g.MinIndex())
+	assert.Equal(t, 2, g.ReadGroupInfo().MinIndex)
+
+	// the head is never removed
+	g.WriteLine("Line")
+	g.FlushAndSync()
+	require.NoError(t, g.RemoveFilesBefore(10))
+	assert.Equal(t, 3, g.MinIndex())
+	_, err := os.Stat(g.Head.Path)
+	assert.NoError(t, err)
+
+	// Cleanup
+	destroyTestGroup(t, g)
+}
@@ -0,0 +1,139 @@
+// Package keyfile encrypts the files of private keys, like the key of a
+// validator or the key of a node, with a passphrase.
+//
+// An encrypted key file is ASCII armored. Its body is the key file
+// encrypted with xsalsa20symmetric, with a secret derived from the
+// passphrase with scrypt. The armor headers hold the salt and the
+// parameters of scrypt.
+package keyfile
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+
+	"golang.org/x/crypto/scrypt"
+
+	cmn "github.com/teragrid/dgrid/pkg/common"
+	"github.com/teragrid/dgrid/pkg/crypto"
+	"github.com/teragrid/dgrid/pkg/crypto/armor"
+	"github.com/teragrid/dgrid/pkg/crypto/xsalsa20symmetric"
+)
+
+const (
+	armorType = "DGRID PRIVATE KEY"
+
+	kdfScrypt = "scrypt"
+	saltLen   = 16
+	secretLen = 32
+
+	// scrypt parameters of new key files
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+var (
+	// ErrEncrypted is returned when reading an encrypted key file without a
+	// passphrase.
+	ErrEncrypted = errors.New("key file is encrypted, a passphrase is needed")
+
+	// ErrInvalidPassphrase is returned when the passphrase doesn't decrypt a
+	// key file.
+	ErrInvalidPassphrase = errors.New("invalid passphrase")
+)
+
+// Encrypt returns the encrypted, ASCII armored, keyJSON.
+func Encrypt(keyJSON, passphrase []byte) ([]byte, error) {
+	salt := crypto.CRandBytes(saltLen)
+	secret, err := scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP, secretLen)
+	if err != nil {
+		return nil, err
+	}
+	headers := map[string]string{
+		"kdf":  kdfScrypt,
+		"salt": fmt.Sprintf("%X", salt),
+		"n":    strconv.Itoa(scryptN),
+		"r":    strconv.Itoa(scryptR),
+		"p":    strconv.Itoa(scryptP),
+	}
+	ciphertext := xsalsa20symmetric.EncryptSymmetric(keyJSON, secret)
+	return []byte(armor.EncodeArmor(armorType, headers, ciphertext)), nil
+}
+
+// Decrypt returns the key file encrypted by Encrypt.
+func Decrypt(data, passphrase []byte) ([]byte, error) {
+	blockType, headers, ciphertext, err := armor.DecodeArmor(string(data))
+	if err != nil {
+		return nil, err
+	}
+	if blockType != armorType {
+		return nil, fmt.Errorf("unrecognized armor type %q", blockType)
+	}
+	if headers["kdf"] != kdfScrypt {
+		return nil, fmt.Errorf("unrecognized KDF %q", headers["kdf"])
+	}
+	salt, err := hex.DecodeString(headers["salt"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid salt: %v", err)
+	}
+	var params [3]int
+	for i, name := range []string{"n", "r", "p"} {
+		if params[i], err = strconv.Atoi(headers[name]); err != nil {
+			return nil, fmt.Errorf("invalid scrypt parameter %v: %v", name, err)
+		}
+	}
+	secret, err := scrypt.Key(passphrase, salt, params[0], params[1], params[2], secretLen)
+	if err != nil {
+		return nil, err
+	}
+	keyJSON, err := xsalsa20symmetric.DecryptSymmetric(ciphertext, secret)
+	if err != nil {
+		return nil, ErrInvalidPassphrase
+	}
+	return keyJSON, nil
+}
+
+// IsEncrypted returns true if data is an encrypted key file.
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN "+armorType+"-----"))
+}
+
+// ReadFile reads a key file, and decrypts it with passphrase if it is
+// encrypted. It returns ErrEncrypted for an encrypted key file and a nil
+// passphrase.
+func ReadFile(filePath string, passphrase Passphrase) ([]byte, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+	if passphrase == nil {
+		return nil, ErrEncrypted
+	}
+	pass, err := passphrase()
+	if err != nil {
+		return nil, err
+	}
+	return Decrypt(data, pass)
+}
+
+// WriteFile writes a key file, encrypted with passphrase unless it is nil.
+func WriteFile(filePath string, keyJSON []byte, passphrase Passphrase) error {
+	data := keyJSON
+	if passphrase != nil {
+		pass, err := passphrase()
+		if err != nil {
+			return err
+		}
+		if data, err = Encrypt(keyJSON, pass); err != nil {
+			return err
+		}
+	}
+	return cmn.WriteFileAtomic(filePath, data, 0600)
+}
@@ -0,0 +1,101 @@
+package keyfile
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cmn "github.com/teragrid/dgrid/pkg/common"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	keyJSON := []byte(`{"priv_key":"secret"}`)
+	data, err := Encrypt(keyJSON, []byte("passphrase"))
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(data))
+	assert.False(t, IsEncrypted(keyJSON))
+	assert.NotContains(t, string(data), "secret")
+
+	decrypted, err := Decrypt(data, []byte("passphrase"))
+	require.NoError(t, err)
+	assert.Equal(t, keyJSON, decrypted)
+
+	_, err = Decrypt(data, []byte("wrong"))
+	assert.Equal(t, ErrInvalidPassphrase, err)
+}
+
+func TestReadWriteFile(t *testing.T) {
+	filePath := filepath.Join(os.TempDir(), cmn.RandStr(12)+"_key.json")
+	defer os.Remove(filePath)
+	keyJSON := []byte(`{"priv_key":"secret"}`)
+
+	// plaintext
+	require.NoError(t, WriteFile(filePath, keyJSON, nil))
+	read, err := ReadFile(filePath, nil)
+	require.NoError(t, err)
+	assert.Equal(t, keyJSON, read)
+
+	// encrypted
+	require.NoError(t, WriteFile(filePath, keyJSON, StaticPassphrase([]byte("passphrase"))))
+	_, err = ReadFile(filePath, nil)
+	assert.Equal(t, ErrEncrypted, err)
+	_, err = ReadFile(filePath, StaticPassphrase([]byte("wrong")))
+	assert.Equal(t, ErrInvalidPassphrase, err)
+	read, err = ReadFile(filePath, StaticPassphrase([]byte("passphrase")))
+	require.NoError(t, err)
+	assert.Equal(t, keyJSON, read)
+}
+
+func TestNewPassphrase(t *testing.T) {
+	name := "DGRID_TEST_PASSPHRASE_" + cmn.RandStr(6)
+	pass, err := NewPassphrase("env:" + name)
+	require.NoError(t, err)
+	_, err = pass()
+	assert.Error(t, err)
+
+	require.NoError(t, os.Setenv(name, "from env"))
+	defer os.Unsetenv(name)
+	pass, err = NewPassphrase("env:" + name)
+	require.NoError(t, err)
+	p, err := pass()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("from env"), p)
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	_, err = w.Write([]byte("from fd\nignored\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	pass, err = NewPassphrase(fmt.Sprintf("fd:%d", r.Fd()))
+	require.NoError(t, err)
+	p, err = pass()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("from fd"), p)
+	// the passphrase is read once
+	p, err = pass()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("from fd"), p)
+
+	for _, source := range []string{"", "env:", "fd:x", "file:/tmp/pass"} {
+		_, err := NewPassphrase(source)
+		assert.Error(t, err, source)
+	}
+}
+
+func TestReadPassphrase(t *testing.T) {
+	f, err := ioutil.TempFile("", "passphrase")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.Write([]byte("\r\n"))
+	require.NoError(t, err)
+	_, err = f.Seek(0, 0)
+	require.NoError(t, err)
+
+	_, err = readPassphrase(f)
+	assert.Error(t, err)
+}
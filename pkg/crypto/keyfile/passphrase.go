@@ -0,0 +1,115 @@
+package keyfile
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const (
+	// SourcePrompt prompts for the passphrase on the terminal.
+	SourcePrompt = "prompt"
+
+	sourceEnvPrefix = "env:"
+	sourceFDPrefix  = "fd:"
+)
+
+// Passphrase returns the passphrase of encrypted key files.
+type Passphrase func() ([]byte, error)
+
+// NewPassphrase returns the Passphrase read from source: SourcePrompt to
+// prompt on the terminal, "env:NAME" for the environment variable NAME, or
+// "fd:N" for the first line read from the file descriptor N, eg. a pipe
+// from a secret manager. The passphrase is read once, on the first call, so
+// a single prompt unlocks several key files.
+func NewPassphrase(source string) (Passphrase, error) {
+	var read Passphrase
+	switch {
+	case source == SourcePrompt:
+		read = func() ([]byte, error) {
+			return PromptPassphrase("Enter the passphrase of the key files: ")
+		}
+	case strings.HasPrefix(source, sourceEnvPrefix):
+		name := strings.TrimPrefix(source, sourceEnvPrefix)
+		if name == "" {
+			return nil, fmt.Errorf("missing environment variable in passphrase source %q", source)
+		}
+		read = func() ([]byte, error) {
+			pass, ok := os.LookupEnv(name)
+			if !ok {
+				return nil, fmt.Errorf("environment variable %v is not set", name)
+			}
+			return checkPassphrase([]byte(pass))
+		}
+	case strings.HasPrefix(source, sourceFDPrefix):
+		fd, err := strconv.ParseUint(strings.TrimPrefix(source, sourceFDPrefix), 10, 0)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file descriptor in passphrase source %q: %v", source, err)
+		}
+		read = func() ([]byte, error) {
+			f := os.NewFile(uintptr(fd), "passphrase")
+			defer f.Close() // nolint: errcheck
+			return readPassphrase(f)
+		}
+	default:
+		return nil, fmt.Errorf("unknown passphrase source %q (must be %q, \"env:NAME\" or \"fd:N\")",
+			source, SourcePrompt)
+	}
+
+	var (
+		once sync.Once
+		pass []byte
+		err  error
+	)
+	return func() ([]byte, error) {
+		once.Do(func() { pass, err = read() })
+		return pass, err
+	}, nil
+}
+
+// StaticPassphrase returns the Passphrase pass.
+func StaticPassphrase(pass []byte) Passphrase {
+	return func() ([]byte, error) {
+		return checkPassphrase(pass)
+	}
+}
+
+// PromptPassphrase prints prompt on the standard error, and reads a
+// passphrase on the terminal, without echoing it.
+func PromptPassphrase(prompt string) ([]byte, error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return nil, errors.New("can't prompt for the passphrase, the standard input is not a terminal")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	pass, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	return checkPassphrase(pass)
+}
+
+// readPassphrase reads the first line of r.
+func readPassphrase(r io.Reader) ([]byte, error) {
+	line, err := bufio.NewReader(r).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return checkPassphrase(bytes.TrimRight(line, "\r\n"))
+}
+
+func checkPassphrase(pass []byte) ([]byte, error) {
+	if len(pass) == 0 {
+		return nil, errors.New("empty passphrase")
+	}
+	return pass, nil
+}
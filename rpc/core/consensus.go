@@ -1,7 +1,6 @@
 package core
 
 import (
-	cm "github.com/teragrid/dgrid/consensus"
 	ctypes "github.com/teragrid/dgrid/rpc/core/types"
 	rpctypes "github.com/teragrid/dgrid/rpc/lib/types"
 	sm "github.com/teragrid/dgrid/state"
@@ -53,7 +52,7 @@ import (
 func Validators(ctx *rpctypes.Context, heightPtr *int64) (*ctypes.ResultValidators, error) {
 	// The latest validator that we know is the
 	// NextValidator of the last block.
-	height := consensusState.GetState().LastBlockHeight + 1
+	height := consensusEngine().GetState().LastBlockHeight + 1
 	height, err := getHeight(height, heightPtr)
 	if err != nil {
 		return nil, err
@@ -208,7 +207,7 @@ func DumpConsensusState(ctx *rpctypes.Context) (*ctypes.ResultDumpConsensusState
 	peers := p2pPeers.Peers().List()
 	peerStates := make([]ctypes.PeerStateInfo, len(peers))
 	for i, peer := range peers {
-		peerState, ok := peer.Get(types.PeerStateKey).(*cm.PeerState)
+		peerState, ok := peer.Get(types.PeerStateKey).(peerState)
 		if !ok { // peer does not have a state yet
 			continue
 		}
@@ -224,7 +223,7 @@ func DumpConsensusState(ctx *rpctypes.Context) (*ctypes.ResultDumpConsensusState
 		}
 	}
 	// Get self round state.
-	roundState, err := consensusState.GetRoundStateJSON()
+	roundState, err := consensusEngine().GetRoundStateJSON()
 	if err != nil {
 		return nil, err
 	}
@@ -282,7 +281,7 @@ func DumpConsensusState(ctx *rpctypes.Context) (*ctypes.ResultDumpConsensusState
 //```
 func ConsensusState(ctx *rpctypes.Context) (*ctypes.ResultConsensusState, error) {
 	// Get self round state.
-	bz, err := consensusState.GetRoundStateSimpleJSON()
+	bz, err := consensusEngine().GetRoundStateSimpleJSON()
 	return &ctypes.ResultConsensusState{RoundState: bz}, err
 }
 
@@ -324,7 +323,7 @@ func ConsensusState(ctx *rpctypes.Context) (*ctypes.ResultConsensusState, error)
 // }
 // ```
 func ConsensusParams(ctx *rpctypes.Context, heightPtr *int64) (*ctypes.ResultConsensusParams, error) {
-	height := consensusState.GetState().LastBlockHeight + 1
+	height := consensusEngine().GetState().LastBlockHeight + 1
 	height, err := getHeight(height, heightPtr)
 	if err != nil {
 		return nil, err
@@ -338,3 +337,9 @@ func ConsensusParams(ctx *rpctypes.Context, heightPtr *int64) (*ctypes.ResultCon
 		BlockHeight:     height,
 		ConsensusParams: consensusparams}, nil
 }
+
+// peerState is the consensus state the reactor of a protocol
+// may keep about each peer.
+type peerState interface {
+	ToJSON() ([]byte, error)
+}
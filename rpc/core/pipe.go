@@ -4,7 +4,7 @@ import (
 	"time"
 
 	cfg "github.com/teragrid/dgrid/core/config"
-	"github.com/teragrid/dgrid/consensus"
+	"github.com/teragrid/dgrid/core/consensus/protocols"
 	"github.com/teragrid/dgrid/pkg/crypto"
 	dbm "github.com/teragrid/dgrid/pkg/db"
 	"github.com/teragrid/dgrid/pkg/log"
@@ -29,13 +29,8 @@ const (
 //----------------------------------------------
 // These interfaces are used by RPC and must be thread safe
 
-type Consensus interface {
-	GetState() sm.State
-	GetValidators() (int64, []*types.Validator)
-	GetLastHeight() int64
-	GetRoundStateJSON() ([]byte, error)
-	GetRoundStateSimpleJSON() ([]byte, error)
-}
+// Consensus is the engine of the league, whatever its consensus protocol.
+type Consensus = protocols.ConsensusEngine
 
 type transport interface {
 	Listeners() []string
@@ -70,7 +65,7 @@ var (
 	genDoc           *types.GenesisDoc // cache the genesis structure
 	addrBook         p2p.AddrBook
 	txIndexer        txindex.TxIndexer
-	consensusReactor *consensus.ConsensusReactor
+	consensusReactor *protocols.ConsensusReactor
 	eventBus         *types.EventBus // thread safe
 	storage          *mempl.Storage
 
@@ -127,10 +122,19 @@ func SetTxIndexer(indexer txindex.TxIndexer) {
 	txIndexer = indexer
 }
 
-func SetConsensusReactor(conR *consensus.ConsensusReactor) {
+func SetConsensusReactor(conR *protocols.ConsensusReactor) {
 	consensusReactor = conR
 }
 
+// consensusEngine returns the engine of the league. The reactor switches
+// to another engine when the league migrates to another protocol.
+func consensusEngine() Consensus {
+	if consensusReactor != nil {
+		return consensusReactor.Engine()
+	}
+	return consensusState
+}
+
 func SetLogger(l log.Logger) {
 	logger = l
 }
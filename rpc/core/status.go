@@ -76,7 +76,7 @@ func Status(ctx *rpctypes.Context) (*ctypes.ResultStatus, error) {
 	if consensusReactor.FastSync() {
 		latestHeight = blockStore.Height()
 	} else {
-		latestHeight = consensusState.GetLastHeight()
+		latestHeight = consensusEngine().GetLastHeight()
 	}
 	var (
 		latestBlockMeta     *types.BlockMeta
@@ -121,7 +121,7 @@ func validatorAtHeight(h int64) *types.Validator {
 	privValAddress := pubKey.Address()
 
 	// If we're still at height h, search in the current validator set.
-	lastBlockHeight, vals := consensusState.GetValidators()
+	lastBlockHeight, vals := consensusEngine().GetValidators()
 	if lastBlockHeight == h {
 		for _, val := range vals {
 			if bytes.Equal(val.Address, privValAddress) {